@@ -7,18 +7,44 @@
 package bip39
 
 import (
+	"bytes"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"strings"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// ideographicSpace is the word separator used in Japanese mnemonics.
+	ideographicSpace = "\u3000"
+
+	// minPrefixLength is the minimum number of letters required to uniquely
+	// identify a word in a BIP39 word list.
+	minPrefixLength = 4
+
+	// WordListSize is the number of words every BIP39 word list contains,
+	// one for each possible 11 bit value.
+	WordListSize = 2048
 )
 
 var (
 	// Some bitwise operands for working with big.Ints.
+	last11BitsMask  = big.NewInt(2047)
 	shift11BitsMask = big.NewInt(2048)
 	bigOne          = big.NewInt(1)
+	bigTwo          = big.NewInt(2)
 
 	// Used to isolate the checksum bits from the entropy+checksum byte
 	// array.
@@ -39,40 +65,494 @@ var (
 		18: big.NewInt(4),
 		21: big.NewInt(2),
 	}
+
+	// wordList is the word list that is currently used for encoding and
+	// decoding mnemonics. It defaults to the English word list and can be
+	// changed with SetWordList.
+	wordList = English
+
+	// wordMap is the reverse lookup map of the active word list that maps
+	// each NFKD normalized word to its index. It is rebuilt whenever the
+	// active word list is changed.
+	wordMap = newWordMap(English)
+
+	// wordLists is the list of all word lists embedded in this package, in
+	// the order they are tried when detecting the language of a mnemonic.
+	wordLists = []namedWordList{
+		{language: "english", words: English},
+		{language: "japanese", words: Japanese},
+		{language: "korean", words: Korean},
+		{language: "spanish", words: Spanish},
+		{language: "chinese_simplified", words: ChineseSimplified},
+		{language: "chinese_traditional", words: ChineseTraditional},
+		{language: "french", words: French},
+		{language: "italian", words: Italian},
+		{language: "czech", words: Czech},
+		{language: "portuguese", words: Portuguese},
+	}
+
+	// detectLanguage defines whether EntropyFromMnemonic tries all
+	// embedded word lists if a word isn't found in the active list. It can
+	// be changed with SetLanguageDetection.
+	detectLanguage = false
+
+	// randReader is the source of randomness used when creating new
+	// entropy. It can be replaced in tests to produce deterministic seeds.
+	randReader io.Reader = rand.Reader
 )
 
+// namedWordList is a word list together with the name of its language.
+type namedWordList struct {
+	language string
+	words    []string
+}
+
+// SetRandReader replaces the source of randomness that is used by NewEntropy
+// and NewMnemonic and returns a function that restores the previous one. This
+// is only meant to be used in tests that need deterministic output and must not
+// be called concurrently with any of the functions that create entropy.
+func SetRandReader(reader io.Reader) func() {
+	oldReader := randReader
+	randReader = reader
+
+	return func() {
+		randReader = oldReader
+	}
+}
+
+// SetWordList sets the list of words to use for mnemonics. Callers must set the
+// list that matches the language of a mnemonic before trying to decode it with
+// EntropyFromMnemonic, otherwise any non-English words won't be found. The
+// list must contain exactly WordListSize words, sorted by their index.
+func SetWordList(list []string) error {
+	if len(list) != WordListSize {
+		return fmt.Errorf("%w, got %d words", ErrInvalidWordListSize,
+			len(list))
+	}
+
+	wordList = list
+	wordMap = newWordMap(list)
+
+	return nil
+}
+
+// GetWordList gets the list of words to use for mnemonics.
+func GetWordList() []string {
+	return wordList
+}
+
+// WordListSHA256 returns the SHA256 hash of the words of the active word list
+// joined by newlines, without a trailing newline. It can be used to make sure
+// the word list wasn't modified.
+func WordListSHA256() [32]byte {
+	return sha256.Sum256([]byte(strings.Join(wordList, "\n")))
+}
+
+// GetWordIndex returns the index of the given word in the active word list
+// and whether the word was found at all.
+func GetWordIndex(word string) (int, bool) {
+	index, ok := wordMap[norm.NFKD.String(word)]
+	return index, ok
+}
+
+// WordListLanguage returns the language of the given word list if it is one of
+// the word lists embedded in this package or "unknown" otherwise.
+func WordListLanguage(list []string) string {
+	for _, namedList := range wordLists {
+		if sameWordList(namedList.words, list) {
+			return namedList.language
+		}
+	}
+
+	return "unknown"
+}
+
+// Languages returns the names of the languages of all word lists embedded in
+// this package.
+func Languages() []string {
+	languages := make([]string, len(wordLists))
+	for idx, namedList := range wordLists {
+		languages[idx] = namedList.language
+	}
+
+	return languages
+}
+
+// WordListByLanguage returns the embedded word list of the given language.
+func WordListByLanguage(language string) ([]string, error) {
+	for _, namedList := range wordLists {
+		if namedList.language == language {
+			return namedList.words, nil
+		}
+	}
+
+	return nil, fmt.Errorf("word list for language %s is not available, "+
+		"must be one of %s", language, strings.Join(Languages(), ", "))
+}
+
+// sameWordList returns true if both slices are the same word list. Word lists
+// are never modified, so comparing the backing array is enough.
+func sameWordList(a, b []string) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// newWordMap creates the reverse lookup map for the given word list.
+func newWordMap(list []string) map[string]int {
+	reverseMap := make(map[string]int, len(list))
+	for i, v := range list {
+		reverseMap[norm.NFKD.String(v)] = i
+	}
+
+	return reverseMap
+}
+
+// SetLanguageDetection defines whether EntropyFromMnemonic tries all embedded
+// word lists if a word of a mnemonic isn't found in the active list. Like the
+// word list, it must not be changed while mnemonics are decoded concurrently.
+func SetLanguageDetection(enabled bool) {
+	detectLanguage = enabled
+}
+
+// DetectLanguage returns the embedded word list that contains all words of the
+// given mnemonic. Some lists share words (for example the simplified and
+// traditional Chinese lists), if more than one list contains all words, an
+// AmbiguousLanguageError with the names of all candidates is returned. An error
+// is also returned if no list contains all words of the mnemonic.
+func DetectLanguage(mnemonic string) ([]string, error) {
+	languages, err := DetectLanguages(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	if len(languages) > 1 {
+		return nil, &AmbiguousLanguageError{Languages: languages}
+	}
+
+	return WordListByLanguage(languages[0])
+}
+
+// DetectLanguages returns the names of the languages of all embedded word lists
+// that contain all words of the given mnemonic. An error is returned if no list
+// contains all words of the mnemonic.
+func DetectLanguages(mnemonic string) ([]string, error) {
+	words, err := normalizedWords(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, ErrInvalidMnemonic
+	}
+
+	var languages []string
+	for _, namedList := range wordLists {
+		if containsAllWords(namedList.words, words) {
+			languages = append(languages, namedList.language)
+		}
+	}
+	if len(languages) == 0 {
+		return nil, ErrUnknownLanguage
+	}
+
+	return languages, nil
+}
+
+// containsAllWords returns true if every one of the given words is contained
+// in the word list.
+func containsAllWords(list []string, words []string) bool {
+	wordSet := make(map[string]struct{}, len(list))
+	for _, word := range list {
+		wordSet[norm.NFKD.String(word)] = struct{}{}
+	}
+
+	for _, word := range words {
+		if _, ok := wordSet[word]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
 var (
 	// ErrInvalidMnemonic is returned when trying to use a malformed
 	// mnemonic.
 	ErrInvalidMnemonic = errors.New("invalid mnenomic")
 
+	// ErrInvalidWordCount is returned when a mnemonic doesn't consist of
+	// 12, 15, 18, 21 or 24 words. The error returned when decoding a
+	// mnemonic is a WordCountError that matches this error.
+	ErrInvalidWordCount = errors.New("invalid number of words in " +
+		"mnemonic")
+
+	// ErrWordNotFound is returned when a word of a mnemonic is not part of
+	// the active word list.
+	ErrWordNotFound = errors.New("word not found in word list")
+
 	// ErrChecksumIncorrect is returned when entropy has the incorrect
 	// checksum.
 	ErrChecksumIncorrect = errors.New("checksum incorrect")
+
+	// ErrUnknownLanguage is returned when none of the known word lists
+	// contains all words of a mnemonic.
+	ErrUnknownLanguage = errors.New("mnemonic language could not be " +
+		"detected")
+
+	// ErrAmbiguousLanguage is returned when more than one of the known
+	// word lists contains all words of a mnemonic. The error returned is
+	// an AmbiguousLanguageError that matches this error.
+	ErrAmbiguousLanguage = errors.New("mnemonic language is ambiguous")
+
+	// ErrInvalidWordListSize is returned when trying to use a word list
+	// that doesn't contain exactly WordListSize words.
+	ErrInvalidWordListSize = errors.New("word list must contain " +
+		"exactly 2048 words")
+
+	// ErrEntropyLengthInvalid is returned when trying to use an entropy
+	// set with an invalid size.
+	ErrEntropyLengthInvalid = errors.New("entropy length must be [128, " +
+		"256] and a multiple of 32")
 )
 
+// WordCountError is returned when a mnemonic doesn't consist of a valid number
+// of words. It matches ErrInvalidWordCount with errors.Is.
+type WordCountError struct {
+	// Count is the number of words that were found in the mnemonic.
+	Count int
+}
+
+// Error returns the error message including the number of words found and the
+// valid numbers of words.
+func (e *WordCountError) Error() string {
+	return fmt.Sprintf("%v: got %d words, expected 12, 15, 18, 21 or 24",
+		ErrInvalidWordCount, e.Count)
+}
+
+// Is returns true if the target is ErrInvalidWordCount.
+func (e *WordCountError) Is(target error) bool {
+	return target == ErrInvalidWordCount
+}
+
+// AmbiguousLanguageError is returned when the language of a mnemonic can't be
+// detected because more than one word list contains all of its words. It
+// matches ErrAmbiguousLanguage with errors.Is.
+type AmbiguousLanguageError struct {
+	// Languages are the names of all word lists that contain all words of
+	// the mnemonic.
+	Languages []string
+}
+
+// Error returns the error message including the names of all candidates.
+func (e *AmbiguousLanguageError) Error() string {
+	return fmt.Sprintf("%v, could be any of %s", ErrAmbiguousLanguage,
+		strings.Join(e.Languages, ", "))
+}
+
+// Is returns true if the target is ErrAmbiguousLanguage.
+func (e *AmbiguousLanguageError) Is(target error) bool {
+	return target == ErrAmbiguousLanguage
+}
+
+// NewEntropy will create random entropy bytes so long as the requested size
+// bitSize is an appropriate size.
+//
+// bitSize has to be a multiple 32 and be within the inclusive range of
+// {128, 256}.
+func NewEntropy(bitSize int) ([]byte, error) {
+	if err := validateEntropyBitSize(bitSize); err != nil {
+		return nil, err
+	}
+
+	entropy := make([]byte, bitSize/8)
+	if _, err := io.ReadFull(randReader, entropy); err != nil {
+		return nil, fmt.Errorf("unable to read random entropy: %w",
+			err)
+	}
+
+	return entropy, nil
+}
+
+// NewMnemonic creates new random entropy of the given size and returns the
+// mnemonic words that encode it.
+func NewMnemonic(bitSize int) (string, error) {
+	entropy, err := NewEntropy(bitSize)
+	if err != nil {
+		return "", err
+	}
+
+	return EntropyToMnemonic(entropy)
+}
+
+// EntropyToMnemonic will return a string consisting of the mnemonic words for
+// the given entropy.
+// If the provided entropy is invalid, an error will be returned.
+func EntropyToMnemonic(entropy []byte) (string, error) {
+	return entropyToMnemonic(entropy, wordList)
+}
+
+// entropyToMnemonic encodes the given entropy as a mnemonic with the words of
+// the given word list.
+func entropyToMnemonic(entropy []byte, list []string) (string, error) {
+	// Compute some lengths for convenience.
+	entropyBitLength := len(entropy) * 8
+	checksumBitLength := entropyBitLength / 32
+	sentenceLength := (entropyBitLength + checksumBitLength) / 11
+
+	// Validate that the requested size is supported.
+	err := validateEntropyBitSize(entropyBitLength)
+	if err != nil {
+		return "", err
+	}
+
+	// Add checksum to entropy.
+	entropy = addChecksum(entropy)
+
+	// Break entropy up into sentenceLength chunks of 11 bits. For each
+	// word AND mask the rightmost 11 bits and find the word at that index.
+	// Then bitshift entropy 11 bits right and repeat. Add to the last
+	// empty slot so we can work with LSBs instead of MSB.
+	entropyInt := new(big.Int).SetBytes(entropy)
+
+	// Slice to hold words in.
+	words := make([]string, sentenceLength)
+
+	// Throw away big.Int for AND masking.
+	word := big.NewInt(0)
+
+	for i := sentenceLength - 1; i >= 0; i-- {
+		// Get 11 right most bits and bitshift 11 to the right for next
+		// time.
+		word.And(entropyInt, last11BitsMask)
+		entropyInt.Div(entropyInt, shift11BitsMask)
+
+		// Get the bytes representing the 11 bits as a 2 byte slice.
+		wordBytes, err := padByteSlice(word.Bytes(), 2)
+		if err != nil {
+			return "", err
+		}
+
+		// Convert bytes to an index and add that word to the list.
+		words[i] = list[binary.BigEndian.Uint16(wordBytes)]
+	}
+
+	return strings.Join(words, " "), nil
+}
+
 // EntropyFromMnemonic takes a mnemonic generated by this library,
 // and returns the input entropy used to generate the given mnemonic.
-// An error is returned if the given mnemonic is invalid.
+// An error is returned if the given mnemonic is invalid. If the checksum is
+// incorrect because the mnemonic is an Electrum seed, ErrElectrumSeed is
+// returned. The mnemonic is normalized according to the active
+// NormalizationPolicy first. If language detection is enabled with
+// SetLanguageDetection and a word isn't in the active list, all embedded word
+// lists that contain every word of the mnemonic are tried.
 func EntropyFromMnemonic(mnemonic string) ([]byte, error) {
-	mnemonicSlice, isValid := splitMnemonicWords(mnemonic)
-	if !isValid {
-		return nil, ErrInvalidMnemonic
+	entropy, _, err := decodeMnemonic(mnemonic, wordMap)
+	if errors.Is(err, ErrWordNotFound) && detectLanguage {
+		entropy, err = entropyFromAnyLanguage(mnemonic, err)
 	}
+	if errors.Is(err, ErrChecksumIncorrect) {
+		if version, ok := IsElectrumSeed(mnemonic); ok {
+			return nil, fmt.Errorf("%w (seed version %s)",
+				ErrElectrumSeed, version)
+		}
+	}
+
+	return entropy, err
+}
 
-	wordMap := make(map[string]int)
-	for i, v := range English {
-		wordMap[v] = i
+// IndicesFromMnemonic validates the given mnemonic and returns the index of
+// each of its words in the active word list. The returned slice has the same
+// length as the mnemonic has words.
+func IndicesFromMnemonic(mnemonic string) ([]int, error) {
+	_, indices, err := decodeMnemonic(mnemonic, wordMap)
+	return indices, err
+}
+
+// entropyFromAnyLanguage decodes the given mnemonic with every embedded word
+// list that contains all of its words. The entropy is returned if exactly one
+// list results in a valid mnemonic, or if all valid ones encode the same
+// entropy. Otherwise an AmbiguousLanguageError is returned if more than one
+// list is valid, or the given error of the active word list if none is.
+func entropyFromAnyLanguage(mnemonic string, activeErr error) ([]byte, error) {
+	languages, err := DetectLanguages(mnemonic)
+	if err != nil {
+		return nil, activeErr
 	}
 
-	// Decode the words into a big.Int.
-	b := big.NewInt(0)
-	for _, v := range mnemonicSlice {
+	var (
+		entropy    []byte
+		candidates []string
+		firstErr   error
+	)
+	for _, language := range languages {
+		list, _ := WordListByLanguage(language)
+		decoded, _, err := decodeMnemonic(mnemonic, newWordMap(list))
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		if len(candidates) > 0 && !bytes.Equal(entropy, decoded) {
+			return nil, &AmbiguousLanguageError{
+				Languages: append(candidates, language),
+			}
+		}
+		entropy = decoded
+		candidates = append(candidates, language)
+	}
+
+	if len(candidates) == 0 {
+		return nil, firstErr
+	}
+
+	return entropy, nil
+}
+
+// decodeMnemonic validates the given mnemonic with the given reverse lookup map
+// of a word list and returns both the entropy it encodes and the word list
+// indices of its words.
+func decodeMnemonic(mnemonic string,
+	wordMap map[string]int) ([]byte, []int, error) {
+
+	mnemonicSlice, err := splitMnemonicWords(mnemonic)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	indices := make([]int, len(mnemonicSlice))
+	for idx, v := range mnemonicSlice {
 		index, found := wordMap[v]
 		if !found {
-			return nil, fmt.Errorf("word `%v` not found in "+
-				"reverse map", v)
+			return nil, nil, fmt.Errorf("%w: `%v`",
+				ErrWordNotFound, v)
 		}
+		indices[idx] = index
+	}
+
+	entropy, err := entropyFromIndices(indices)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return entropy, indices, nil
+}
+
+// entropyFromIndices decodes the entropy from the given word list indices and
+// verifies its checksum. The number of indices must be a valid mnemonic word
+// count.
+func entropyFromIndices(indices []int) ([]byte, error) {
+	// Decode the words into a big.Int.
+	b := big.NewInt(0)
+	for _, index := range indices {
+		// Each word only encodes 11 bits. A larger index (for example
+		// from a word list that is too long) would overflow into the
+		// previous word and silently produce wrong entropy.
+		if index < 0 || index >= 2048 {
+			return nil, fmt.Errorf("word index %d out of range",
+				index)
+		}
+
 		var wordBytes [2]byte
 		binary.BigEndian.PutUint16(wordBytes[:], uint16(index))
 		b = b.Mul(b, shift11BitsMask)
@@ -81,7 +561,7 @@ func EntropyFromMnemonic(mnemonic string) ([]byte, error) {
 
 	// Build and add the checksum to the big.Int.
 	checksum := big.NewInt(0)
-	checksumMask := wordLengthChecksumMasksMapping[len(mnemonicSlice)]
+	checksumMask := wordLengthChecksumMasksMapping[len(indices)]
 	checksum = checksum.And(b, checksumMask)
 
 	b.Div(b, big.NewInt(0).Add(checksumMask, bigOne))
@@ -90,13 +570,16 @@ func EntropyFromMnemonic(mnemonic string) ([]byte, error) {
 	// of all 0's are not returned so we pad the beginning of the slice with
 	// empty bytes if necessary.
 	entropy := b.Bytes()
-	entropy = padByteSlice(entropy, len(mnemonicSlice)/3*4)
+	entropy, err := padByteSlice(entropy, len(indices)/3*4)
+	if err != nil {
+		return nil, err
+	}
 
 	// Generate the checksum and compare with the one we got from the
 	// mneomnic.
 	entropyChecksumBytes := computeChecksum(entropy)
 	entropyChecksum := big.NewInt(int64(entropyChecksumBytes[0]))
-	if l := len(mnemonicSlice); l != 24 {
+	if l := len(indices); l != 24 {
 		checksumShift := wordLengthChecksumShiftMapping[l]
 		entropyChecksum.Div(entropyChecksum, checksumShift)
 	}
@@ -108,6 +591,168 @@ func EntropyFromMnemonic(mnemonic string) ([]byte, error) {
 	return entropy, nil
 }
 
+// EntropyFromMnemonicPrefix works like EntropyFromMnemonic but also accepts
+// mnemonics where some or all words are abbreviated to a prefix of at least
+// four letters, which is enough to uniquely identify any word in a BIP39 word
+// list. An error naming the offending word is returned if a prefix is too short
+// or doesn't match exactly one word of the active word list.
+func EntropyFromMnemonicPrefix(mnemonic string) ([]byte, error) {
+	mnemonicSlice, err := splitMnemonicWords(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+
+	words := make([]string, len(mnemonicSlice))
+	for idx, prefix := range mnemonicSlice {
+		word, err := resolvePrefix(prefix)
+		if err != nil {
+			return nil, err
+		}
+		words[idx] = word
+	}
+
+	return EntropyFromMnemonic(strings.Join(words, " "))
+}
+
+// resolvePrefix returns the word of the active word list that is uniquely
+// identified by the given prefix or an exact match if the prefix is a full
+// word.
+func resolvePrefix(prefix string) (string, error) {
+	var matches []string
+	for _, v := range wordList {
+		word := norm.NFKD.String(v)
+		if word == prefix {
+			return word, nil
+		}
+		if strings.HasPrefix(word, prefix) {
+			matches = append(matches, word)
+		}
+	}
+
+	switch {
+	case len(matches) == 0:
+		return "", fmt.Errorf("%w: no word with prefix `%v`",
+			ErrWordNotFound, prefix)
+
+	case utf8.RuneCountInString(prefix) < minPrefixLength:
+		return "", fmt.Errorf("word prefix `%v` is too short, need "+
+			"at least %d letters", prefix, minPrefixLength)
+
+	case len(matches) > 1:
+		return "", fmt.Errorf("word prefix `%v` is ambiguous, "+
+			"matches %v", prefix, strings.Join(matches, ", "))
+	}
+
+	return matches[0], nil
+}
+
+// addChecksum appends the first len(data)/4 bits of the SHA256 hash of the
+// data to the end of the data.
+func addChecksum(data []byte) []byte {
+	// Get first byte of sha256.
+	hash := computeChecksum(data)
+	firstChecksumByte := hash[0]
+
+	// len() is in bytes so we divide by 4.
+	checksumBitLength := uint(len(data) / 4)
+
+	// For each bit of check sum we want we shift the data one the left and
+	// then set the (new) right most bit equal to checksum bit at that
+	// index staring from the left.
+	dataBigInt := new(big.Int).SetBytes(data)
+	for i := uint(0); i < checksumBitLength; i++ {
+		// Bitshift 1 left.
+		dataBigInt.Mul(dataBigInt, bigTwo)
+
+		// Set rightmost bit if leftmost checksum bit is set.
+		if firstChecksumByte&(1<<(7-i)) > 0 {
+			dataBigInt.Or(dataBigInt, bigOne)
+		}
+	}
+
+	return dataBigInt.Bytes()
+}
+
+// NewSeedWithErrorChecking creates a hashed seed output given the mnemonic
+// string and a passphrase. An error that matches ErrInvalidMnemonic and states
+// the reason is returned if the mnemonic is not convertible to a byte array.
+// The seed is derived from the mnemonic as normalized by NormalizeMnemonic.
+func NewSeedWithErrorChecking(mnemonic, passphrase string) ([]byte, error) {
+	if _, err := EntropyFromMnemonic(mnemonic); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMnemonic, err)
+	}
+	mnemonic, err := NormalizeMnemonic(mnemonic)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMnemonic, err)
+	}
+
+	return NewSeed(mnemonic, passphrase), nil
+}
+
+// NewSeed creates a hashed seed output given a provided string and passphrase.
+// No checking is performed to validate that the string provided is a valid
+// mnemonic.
+// Both the mnemonic and the passphrase are only normalized with NFKD, as
+// mandated by BIP39, so the seed is derived from exactly the given mnemonic.
+// Use NormalizeMnemonic first to derive the seed of its canonical form.
+func NewSeed(mnemonic, passphrase string) []byte {
+	mnemonic = norm.NFKD.String(mnemonic)
+	passphrase = norm.NFKD.String(passphrase)
+
+	return pbkdf2.Key(
+		[]byte(mnemonic), []byte("mnemonic"+passphrase), 2048, 64,
+		sha512.New,
+	)
+}
+
+// MasterKeyFromMnemonic validates the given mnemonic, derives the BIP39 seed of
+// its normalized form with the given passphrase and returns the BIP32 HD master
+// key for the given network.
+func MasterKeyFromMnemonic(mnemonic, passphrase string,
+	net *chaincfg.Params) (*hdkeychain.ExtendedKey, error) {
+
+	if _, err := EntropyFromMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	mnemonic, err := NormalizeMnemonic(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+
+	seed := NewSeed(mnemonic, passphrase)
+	masterKey, err := hdkeychain.NewMaster(seed, net)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive master extended "+
+			"key: %w", err)
+	}
+
+	return masterKey, nil
+}
+
+// IsMnemonicValid attempts to verify that the provided mnemonic is valid.
+// Validity is determined by both the number of words being appropriate, the
+// words all existing in the word list and the checksum being correct.
+func IsMnemonicValid(mnemonic string) bool {
+	_, err := EntropyFromMnemonic(mnemonic)
+	return err == nil
+}
+
+// EqualMnemonic returns true if both mnemonics encode the same entropy. The
+// mnemonics are normalized the same way as for decoding, so differences in
+// whitespace or Unicode normalization don't matter. The entropy is compared in
+// constant time to not leak any information about the position of the first
+// difference. Invalid mnemonics are never considered equal, not even to
+// themselves.
+func EqualMnemonic(a, b string) bool {
+	entropyA, errA := EntropyFromMnemonic(a)
+	entropyB, errB := EntropyFromMnemonic(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(entropyA, entropyB) == 1
+}
+
 func computeChecksum(data []byte) []byte {
 	hasher := sha256.New()
 	_, _ = hasher.Write(data)
@@ -115,27 +760,53 @@ func computeChecksum(data []byte) []byte {
 }
 
 // padByteSlice returns a byte slice of the given size with contents of the
-// given slice left padded and any empty spaces filled with 0's.
-func padByteSlice(slice []byte, length int) []byte {
+// given slice left padded and any empty spaces filled with 0's. An error is
+// returned if the given slice is longer than the requested size, it is never
+// truncated.
+func padByteSlice(slice []byte, length int) ([]byte, error) {
 	offset := length - len(slice)
-	if offset <= 0 {
-		return slice
+	if offset < 0 {
+		return nil, fmt.Errorf("cannot pad slice of length %d to %d "+
+			"bytes", len(slice), length)
+	}
+	if offset == 0 {
+		return slice, nil
 	}
 	newSlice := make([]byte, length)
 	copy(newSlice[offset:], slice)
-	return newSlice
+	return newSlice, nil
 }
 
-func splitMnemonicWords(mnemonic string) ([]string, bool) {
-	// Create a list of all the words in the mnemonic sentence.
-	words := strings.Fields(mnemonic)
+// validateEntropyBitSize ensures that the entropy is the correct size for
+// being a mnemonic.
+func validateEntropyBitSize(bitSize int) error {
+	if (bitSize%32) != 0 || bitSize < 128 || bitSize > 256 {
+		return ErrEntropyLengthInvalid
+	}
+	return nil
+}
+
+// normalizeMnemonic applies the NFKD normalization mandated by BIP39 to the
+// given mnemonic and replaces all ideographic spaces (U+3000), as used in
+// Japanese mnemonics, with regular spaces.
+func normalizeMnemonic(mnemonic string) string {
+	mnemonic = norm.NFKD.String(mnemonic)
+	return strings.ReplaceAll(mnemonic, ideographicSpace, " ")
+}
+
+func splitMnemonicWords(mnemonic string) ([]string, error) {
+	// Create a list of all the words in the normalized mnemonic sentence.
+	words, err := normalizedWords(mnemonic)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get num of words.
 	numOfWords := len(words)
 
 	// The number of words should be 12, 15, 18, 21 or 24.
 	if numOfWords%3 != 0 || numOfWords < 12 || numOfWords > 24 {
-		return nil, false
+		return nil, &WordCountError{Count: numOfWords}
 	}
-	return words, true
+	return words, nil
 }
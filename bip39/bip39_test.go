@@ -0,0 +1,715 @@
+package bip39
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/require"
+)
+
+type vector struct {
+	entropy  string
+	mnemonic string
+	seed     string
+}
+
+// testVectors are taken from the official BIP39 test vectors at
+// https://github.com/trezor/python-mnemonic/blob/master/vectors.json. All
+// seeds are derived with the passphrase "TREZOR".
+var testVectors = []vector{{
+	entropy: "00000000000000000000000000000000",
+	mnemonic: "abandon abandon abandon abandon abandon abandon abandon " +
+		"abandon abandon abandon abandon about",
+	seed: "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53" +
+		"495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f00" +
+		"1698e7463b04",
+}, {
+	entropy: "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+	mnemonic: "legal winner thank year wave sausage worth useful legal " +
+		"winner thank yellow",
+	seed: "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c2" +
+		"8bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d" +
+		"9739fce1f607",
+}, {
+	entropy: "80808080808080808080808080808080",
+	mnemonic: "letter advice cage absurd amount doctor acoustic avoid " +
+		"letter advice cage above",
+	seed: "d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed55" +
+		"11a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778" +
+		"c1b370b652a8",
+}, {
+	entropy:  "ffffffffffffffffffffffffffffffff",
+	mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+	seed: "ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0" +
+		"a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e182" +
+		"31052e48c069",
+}, {
+	entropy: "000000000000000000000000000000000000000000000000",
+	mnemonic: "abandon abandon abandon abandon abandon abandon abandon " +
+		"abandon abandon abandon abandon abandon abandon abandon " +
+		"abandon abandon abandon agent",
+	seed: "035895f2f481b1b0f01fcf8c289c794660b289981a78f8106447707fdd9" +
+		"666ca06da5a9a565181599b79f53b844d8a71dd9f439c52a3d7b3e8a7" +
+		"9c906ac845fa",
+}, {
+	entropy: "ffffffffffffffffffffffffffffffffffffffffffffffffffffffff" +
+		"ffffffff",
+	mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo " +
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+	seed: "dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d224" +
+		"84e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba" +
+		"3f05a69890ad",
+}, {
+	entropy: "9e885d952ad362caeb4efe34a8e91bd2",
+	mnemonic: "ozone drill grab fiber curtain grace pudding thank " +
+		"cruise elder eight picnic",
+	seed: "274ddc525802f7c828d8ef7ddbcdc5304e87ac3535913611fbbfa986d0c" +
+		"9e5476c91689f9c8a54fd55bd38606aa6a8595ad213d4c9c9f9aca3fb" +
+		"217069a41028",
+}}
+
+// japaneseTestVectors are the official BIP39 test vectors of the Japanese word
+// list, taken from
+// https://github.com/bip32JP/bip32JP.github.io/blob/master/test_JP_BIP39.json
+// for the same entropies as the English vectors. The words are separated by
+// ideographic spaces (U+3000) and all seeds are derived with the heavily NFKD
+// normalized passphrase japaneseTestPassphrase.
+var japaneseTestVectors = []vector{{
+	entropy: "00000000000000000000000000000000",
+	mnemonic: "あいこくしん　あいこくしん　あいこくしん　あいこくしん　" +
+		"あいこくしん　あいこくしん　あいこくしん　あいこくしん　" +
+		"あいこくしん　あいこくしん　あいこくしん　あおぞら",
+	seed: "a262d6fb6122ecf45be09c50492b31f92e9beb7d9a845987a02cefda57a15f" +
+		"9c467a17872029a9e92299b5cbdf306e3a0ee620245cbd508959b6cb7ca6" +
+		"37bd55",
+}, {
+	entropy: "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+	mnemonic: "そつう　れきだい　ほんやく　わかす　りくつ　ばいか　" +
+		"ろせん　やちん　そつう　れきだい　ほんやく　わかめ",
+	seed: "aee025cbe6ca256862f889e48110a6a382365142f7d16f2b9545285b3af64e" +
+		"542143a577e9c144e101a6bdca18f8d97ec3366ebf5b088b1c1af9bc3134" +
+		"6e60d9",
+}, {
+	entropy: "80808080808080808080808080808080",
+	mnemonic: "そとづら　あまど　おおう　あこがれる　いくぶん　" +
+		"けいけん　あたえる　いよく　そとづら　あまど　おおう　" +
+		"あかちゃん",
+	seed: "e51736736ebdf77eda23fa17e31475fa1d9509c78f1deb6b4aacfbd760a7e2" +
+		"ad769c714352c95143b5c1241985bcb407df36d64e75dd5a2b78ca5d2ba8" +
+		"2a3544",
+}, {
+	entropy: "ffffffffffffffffffffffffffffffff",
+	mnemonic: "われる　われる　われる　われる　われる　われる　われる　" +
+		"われる　われる　われる　われる　ろんぶん",
+	seed: "4cd2ef49b479af5e1efbbd1e0bdc117f6a29b1010211df4f78e2ed40082865" +
+		"793e57949236c43b9fe591ec70e5bb4298b8b71dc4b267bb96ed4ed282c8" +
+		"f7761c",
+}, {
+	entropy: "000000000000000000000000000000000000000000000000",
+	mnemonic: "あいこくしん　あいこくしん　あいこくしん　あいこくしん　" +
+		"あいこくしん　あいこくしん　あいこくしん　あいこくしん　" +
+		"あいこくしん　あいこくしん　あいこくしん　あいこくしん　" +
+		"あいこくしん　あいこくしん　あいこくしん　あいこくしん　" +
+		"あいこくしん　あらいぐま",
+	seed: "d99e8f1ce2d4288d30b9c815ae981edd923c01aa4ffdc5dee1ab5fe0d4a3e1" +
+		"3966023324d119105aff266dac32e5cd11431eeca23bbd7202ff423f30d6" +
+		"776d69",
+}, {
+	entropy: "fffffffffffffffffffffffffffffffffffffffffffffffffffffffffff" +
+		"fffff",
+	mnemonic: "われる　われる　われる　われる　われる　われる　われる　" +
+		"われる　われる　われる　われる　われる　われる　われる　" +
+		"われる　われる　われる　われる　われる　われる　われる　" +
+		"われる　われる　らいう",
+	seed: "a44ba7054ac2f9226929d56505a51e13acdaa8a9097923ca07ea465c4c7e29" +
+		"4c038f3f4e7e4b373726ba0057191aced6e48ac8d183f3a11569c426f0de" +
+		"414623",
+}, {
+	entropy: "9e885d952ad362caeb4efe34a8e91bd2",
+	mnemonic: "ておくれ　げざん　しねま　こりる　きぼう　しねん　" +
+		"ななおし　ほんやく　きない　けむり　けまり　てんない",
+	seed: "125964bac1b499dc8e7c1ee54054f7c393083300cb71880cd14f80a1750258" +
+		"4b7a04730832bc0f023c8fcc421a3659e6fcdc6b7e298bbf72cca123dcfb" +
+		"5a95b4",
+}}
+
+// japaneseTestPassphrase is the passphrase of all Japanese test vectors.
+const japaneseTestPassphrase = "㍍ガバヴァぱばぐゞちぢ十人十色"
+
+func TestEntropyRoundTrip(t *testing.T) {
+	for _, v := range testVectors {
+		entropy, err := hex.DecodeString(v.entropy)
+		require.NoError(t, err)
+
+		mnemonic, err := EntropyToMnemonic(entropy)
+		require.NoError(t, err)
+		require.Equal(t, v.mnemonic, mnemonic)
+
+		decoded, err := EntropyFromMnemonic(mnemonic)
+		require.NoError(t, err)
+		require.Equal(t, entropy, decoded)
+	}
+}
+
+func TestEntropyToMnemonicInvalidLength(t *testing.T) {
+	for _, size := range []int{0, 8, 15, 17, 33, 36} {
+		_, err := EntropyToMnemonic(make([]byte, size))
+		require.ErrorIs(t, err, ErrEntropyLengthInvalid)
+	}
+}
+
+func TestNewEntropy(t *testing.T) {
+	for _, bitSize := range []int{128, 160, 192, 224, 256} {
+		entropy, err := NewEntropy(bitSize)
+		require.NoError(t, err)
+		require.Len(t, entropy, bitSize/8)
+	}
+
+	for _, bitSize := range []int{0, 64, 127, 129, 288} {
+		_, err := NewEntropy(bitSize)
+		require.ErrorIs(t, err, ErrEntropyLengthInvalid)
+	}
+}
+
+func TestNewMnemonicDeterministic(t *testing.T) {
+	// Feeding the entropy of all test vectors through the random reader
+	// must result in exactly the mnemonics of the vectors.
+	for _, v := range testVectors {
+		entropy, err := hex.DecodeString(v.entropy)
+		require.NoError(t, err)
+
+		restore := SetRandReader(bytes.NewReader(entropy))
+		mnemonic, err := NewMnemonic(len(entropy) * 8)
+		require.NoError(t, err)
+		require.Equal(t, v.mnemonic, mnemonic)
+
+		// An exhausted reader must result in an error, not in short
+		// entropy.
+		_, err = NewMnemonic(len(entropy) * 8)
+		require.Error(t, err)
+
+		restore()
+	}
+
+	// After restoring, the system's source of randomness is used again.
+	require.Equal(t, rand.Reader, randReader)
+}
+
+func TestIsMnemonicValid(t *testing.T) {
+	for _, v := range testVectors {
+		require.True(t, IsMnemonicValid(v.mnemonic))
+
+		// Extra whitespace should be ignored.
+		require.True(t, IsMnemonicValid("  "+v.mnemonic+"\n"))
+	}
+
+	invalid := []string{
+		"",
+		"   ",
+		"abandon",
+		// Wrong checksum.
+		"abandon abandon abandon abandon abandon abandon abandon " +
+			"abandon abandon abandon abandon abandon",
+		// Word not in the list.
+		"abandon abandon abandon abandon abandon abandon abandon " +
+			"abandon abandon abandon abandon chantools",
+		// Invalid word count.
+		"abandon abandon abandon abandon abandon abandon abandon " +
+			"abandon abandon abandon about",
+	}
+	for _, mnemonic := range invalid {
+		require.False(t, IsMnemonicValid(mnemonic))
+	}
+}
+
+func TestJapaneseVectors(t *testing.T) {
+	require.NoError(t, SetWordList(Japanese))
+	defer SetWordList(English) // nolint:errcheck
+
+	for _, v := range japaneseTestVectors {
+		entropy, err := EntropyFromMnemonic(v.mnemonic)
+		require.NoError(t, err)
+		require.Equal(t, v.entropy, hex.EncodeToString(entropy))
+
+		// Our mnemonics are separated by regular spaces, which are the
+		// same as ideographic spaces after the NFKD normalization.
+		mnemonic, err := EntropyToMnemonic(entropy)
+		require.NoError(t, err)
+		require.Equal(
+			t, strings.ReplaceAll(v.mnemonic, "\u3000", " "),
+			mnemonic,
+		)
+
+		for _, m := range []string{v.mnemonic, mnemonic} {
+			seed := NewSeed(m, japaneseTestPassphrase)
+			require.Equal(t, v.seed, hex.EncodeToString(seed))
+		}
+	}
+}
+
+func TestNewSeed(t *testing.T) {
+	for _, v := range testVectors {
+		seed := NewSeed(v.mnemonic, "TREZOR")
+		require.Equal(t, v.seed, hex.EncodeToString(seed))
+
+		seed, err := NewSeedWithErrorChecking(v.mnemonic, "TREZOR")
+		require.NoError(t, err)
+		require.Equal(t, v.seed, hex.EncodeToString(seed))
+	}
+
+	_, err := NewSeedWithErrorChecking("abandon about", "TREZOR")
+	require.ErrorIs(t, err, ErrInvalidMnemonic)
+	require.ErrorContains(t, err, "got 2 words")
+}
+
+func TestSetWordList(t *testing.T) {
+	defer SetWordList(English) // nolint:errcheck
+
+	require.Equal(t, English, GetWordList())
+
+	// Use a word list with all words reversed so we can check the active
+	// list is used in both directions.
+	reversed := make([]string, len(English))
+	for i, word := range English {
+		runes := []rune(word)
+		for l, r := 0, len(runes)-1; l < r; l, r = l+1, r-1 {
+			runes[l], runes[r] = runes[r], runes[l]
+		}
+		reversed[i] = string(runes)
+	}
+	err := SetWordList(reversed)
+	require.NoError(t, err)
+	require.Equal(t, reversed, GetWordList())
+
+	entropy, err := hex.DecodeString(testVectors[0].entropy)
+	require.NoError(t, err)
+
+	mnemonic, err := EntropyToMnemonic(entropy)
+	require.NoError(t, err)
+	require.Equal(t, "nodnaba nodnaba nodnaba nodnaba nodnaba nodnaba "+
+		"nodnaba nodnaba nodnaba nodnaba nodnaba tuoba", mnemonic)
+
+	decoded, err := EntropyFromMnemonic(mnemonic)
+	require.NoError(t, err)
+	require.Equal(t, entropy, decoded)
+
+	// The English mnemonic must no longer be accepted.
+	require.False(t, IsMnemonicValid(testVectors[0].mnemonic))
+}
+
+func TestSetWordListInvalidSize(t *testing.T) {
+	defer SetWordList(English) // nolint:errcheck
+
+	err := SetWordList(English[:WordListSize-1])
+	require.ErrorIs(t, err, ErrInvalidWordListSize)
+	require.ErrorContains(t, err, "got 2047 words")
+
+	err = SetWordList(append(English[:WordListSize:WordListSize], "zzz"))
+	require.ErrorIs(t, err, ErrInvalidWordListSize)
+
+	// The active word list must not have been changed.
+	require.Equal(t, English, GetWordList())
+}
+
+func TestWordListSHA256(t *testing.T) {
+	// The hash of the English word list from the BIP39 repository, joined
+	// by newlines without a trailing newline.
+	const englishHash = "187db04a869dd9bc7be80d21a86497d692c0db6abd3aa8cb" +
+		"6be5d618ff757fae"
+
+	require.Len(t, English, WordListSize)
+
+	hash := WordListSHA256()
+	require.Equal(t, englishHash, hex.EncodeToString(hash[:]))
+
+	// With a trailing newline, this is the hash of the english.txt file
+	// as published in the BIP39 repository.
+	fileHash := sha256.Sum256([]byte(strings.Join(English, "\n") + "\n"))
+	require.Equal(
+		t, "2f5eed53a4727b4bf8880d8f3f199efc90e58503646d9ff8eff3a2ed"+
+			"3b24dbda", hex.EncodeToString(fileHash[:]),
+	)
+}
+
+func TestDetectLanguage(t *testing.T) {
+	for _, v := range testVectors {
+		list, err := DetectLanguage(v.mnemonic)
+		require.NoError(t, err)
+		require.Equal(t, English, list)
+	}
+
+	_, err := DetectLanguage("abandon chantools")
+	require.ErrorIs(t, err, ErrUnknownLanguage)
+
+	_, err = DetectLanguage(" ")
+	require.ErrorIs(t, err, ErrInvalidMnemonic)
+
+	french := mnemonicInLanguage(t, French, testVectors[0].entropy)
+	list, err := DetectLanguage(french)
+	require.NoError(t, err)
+	require.Equal(t, French, list)
+
+	// Many Chinese characters are part of both lists.
+	chinese := mnemonicInLanguage(
+		t, ChineseSimplified, testVectors[0].entropy,
+	)
+	_, err = DetectLanguage(chinese)
+	require.ErrorIs(t, err, ErrAmbiguousLanguage)
+
+	var ambiguousErr *AmbiguousLanguageError
+	require.ErrorAs(t, err, &ambiguousErr)
+	require.Equal(t, []string{
+		"chinese_simplified", "chinese_traditional",
+	}, ambiguousErr.Languages)
+
+	languages, err := DetectLanguages(chinese)
+	require.NoError(t, err)
+	require.Equal(t, ambiguousErr.Languages, languages)
+}
+
+func TestEntropyFromMnemonicDetectLanguage(t *testing.T) {
+	defer SetWordList(English) // nolint:errcheck
+	defer SetLanguageDetection(false)
+
+	v := testVectors[0]
+	french := mnemonicInLanguage(t, French, v.entropy)
+	_, err := EntropyFromMnemonic(french)
+	require.ErrorIs(t, err, ErrWordNotFound)
+
+	SetLanguageDetection(true)
+	entropy, err := EntropyFromMnemonic(french)
+	require.NoError(t, err)
+	require.Equal(t, v.entropy, hex.EncodeToString(entropy))
+
+	// The Chinese lists share the index of every common word, so the
+	// entropy is the same with both.
+	chinese := mnemonicInLanguage(t, ChineseTraditional, v.entropy)
+	entropy, err = EntropyFromMnemonic(chinese)
+	require.NoError(t, err)
+	require.Equal(t, v.entropy, hex.EncodeToString(entropy))
+
+	// These words are valid in both English and French but encode
+	// different entropy.
+	require.NoError(t, SetWordList(Japanese))
+	_, err = EntropyFromMnemonic("abandon amateur angle animal aspect " +
+		"badge bicycle bonus brave canal amateur fragile")
+	require.ErrorIs(t, err, ErrAmbiguousLanguage)
+	require.ErrorContains(t, err, "could be any of english, french")
+
+	// The error of the active word list is returned if no list contains
+	// all words.
+	_, err = EntropyFromMnemonic(
+		strings.Replace(v.mnemonic, "about", "chantools", 1),
+	)
+	require.ErrorIs(t, err, ErrWordNotFound)
+}
+
+// mnemonicInLanguage encodes the given hex entropy with the given word list.
+func mnemonicInLanguage(t *testing.T, list []string, entropyHex string) string {
+	entropy, err := hex.DecodeString(entropyHex)
+	require.NoError(t, err)
+
+	mnemonic, err := entropyToMnemonic(entropy, list)
+	require.NoError(t, err)
+
+	return mnemonic
+}
+
+func TestWordListByLanguage(t *testing.T) {
+	require.Equal(t, []string{
+		"english", "japanese", "korean", "spanish",
+		"chinese_simplified", "chinese_traditional", "french",
+		"italian", "czech", "portuguese",
+	}, Languages())
+
+	list, err := WordListByLanguage("english")
+	require.NoError(t, err)
+	require.Equal(t, English, list)
+	require.Equal(t, "english", WordListLanguage(list))
+
+	list, err = WordListByLanguage("portuguese")
+	require.NoError(t, err)
+	require.Equal(t, Portuguese, list)
+	require.Equal(t, "portuguese", WordListLanguage(list))
+
+	_, err = WordListByLanguage("klingon")
+	require.ErrorContains(t, err, "must be one of english, japanese")
+}
+
+func TestWordLists(t *testing.T) {
+	defer SetWordList(English) // nolint:errcheck
+
+	entropy, err := hex.DecodeString(testVectors[3].entropy)
+	require.NoError(t, err)
+
+	for _, language := range Languages() {
+		list, err := WordListByLanguage(language)
+		require.NoError(t, err)
+		require.Len(t, list, WordListSize, language)
+
+		// All words must be unique, even after NFKD normalization.
+		require.Len(t, newWordMap(list), WordListSize, language)
+
+		// The entropy must survive a round trip with every list.
+		require.NoError(t, SetWordList(list))
+		mnemonic, err := EntropyToMnemonic(entropy)
+		require.NoError(t, err)
+
+		decoded, err := EntropyFromMnemonic(mnemonic)
+		require.NoError(t, err)
+		require.Equal(t, entropy, decoded, language)
+
+		if language != "english" {
+			require.False(t, IsMnemonicValid(testVectors[3].mnemonic))
+		}
+	}
+}
+
+func TestNormalization(t *testing.T) {
+	// Words separated by the ideographic space (U+3000) must be split
+	// correctly.
+	v := testVectors[1]
+	ideographic := strings.ReplaceAll(v.mnemonic, " ", "　")
+	entropy, err := EntropyFromMnemonic(ideographic)
+	require.NoError(t, err)
+	require.Equal(t, v.entropy, hex.EncodeToString(entropy))
+	require.Equal(
+		t, v.seed, hex.EncodeToString(NewSeed(ideographic, "TREZOR")),
+	)
+
+	// Full width characters are decomposed to their ASCII counterparts
+	// by NFKD, both in the mnemonic and the passphrase.
+	fullWidth := "ｌｅｇａｌ winner thank year wave sausage worth useful " +
+		"legal winner thank ｙｅｌｌｏｗ"
+	entropy, err = EntropyFromMnemonic(fullWidth)
+	require.NoError(t, err)
+	require.Equal(t, v.entropy, hex.EncodeToString(entropy))
+	require.Equal(
+		t, v.seed, hex.EncodeToString(NewSeed(fullWidth, "ＴＲＥＺＯＲ")),
+	)
+}
+
+func TestEntropyFromMnemonicPrefix(t *testing.T) {
+	for _, v := range testVectors {
+		// Abbreviate every word to its first four letters.
+		words := strings.Fields(v.mnemonic)
+		for idx, word := range words {
+			if len(word) > 4 {
+				words[idx] = word[:4]
+			}
+		}
+
+		entropy, err := EntropyFromMnemonicPrefix(
+			strings.Join(words, " "),
+		)
+		require.NoError(t, err)
+		require.Equal(t, v.entropy, hex.EncodeToString(entropy))
+
+		// Full words must still be accepted.
+		entropy, err = EntropyFromMnemonicPrefix(v.mnemonic)
+		require.NoError(t, err)
+		require.Equal(t, v.entropy, hex.EncodeToString(entropy))
+	}
+
+	prefixMnemonic := func(prefix string) string {
+		return "lega winn than year wave saus wort usef lega winn " +
+			"than " + prefix
+	}
+
+	_, err := EntropyFromMnemonicPrefix(prefixMnemonic("yel"))
+	require.ErrorContains(t, err, "`yel` is too short")
+
+	_, err = EntropyFromMnemonicPrefix(prefixMnemonic("xxxx"))
+	require.ErrorIs(t, err, ErrWordNotFound)
+	require.ErrorContains(t, err, "`xxxx`")
+
+	// "act" is a full word and therefore not ambiguous, even if it is a
+	// prefix of "action" and "actor".
+	_, err = EntropyFromMnemonicPrefix(prefixMnemonic("act"))
+	require.ErrorIs(t, err, ErrChecksumIncorrect)
+}
+
+func TestIndicesFromMnemonic(t *testing.T) {
+	indices, err := IndicesFromMnemonic(testVectors[0].mnemonic)
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 3}, indices)
+
+	for _, v := range testVectors {
+		indices, err := IndicesFromMnemonic(v.mnemonic)
+		require.NoError(t, err)
+
+		words := strings.Fields(v.mnemonic)
+		require.Len(t, indices, len(words))
+		for idx, word := range words {
+			require.Equal(t, word, English[indices[idx]])
+		}
+	}
+
+	// Unknown words must result in the same error as when decoding the
+	// entropy.
+	unknown := "abandon abandon abandon abandon abandon abandon " +
+		"abandon abandon abandon abandon abandon chantools"
+	_, entropyErr := EntropyFromMnemonic(unknown)
+	_, indicesErr := IndicesFromMnemonic(unknown)
+	require.Error(t, indicesErr)
+	require.Equal(t, entropyErr, indicesErr)
+}
+
+func TestEntropyFromMnemonicErrors(t *testing.T) {
+	_, err := EntropyFromMnemonic("")
+	require.ErrorIs(t, err, ErrInvalidWordCount)
+	require.ErrorContains(t, err, "got 0 words")
+
+	_, err = EntropyFromMnemonic("abandon abandon abandon abandon " +
+		"abandon abandon abandon abandon abandon abandon about")
+	require.ErrorIs(t, err, ErrInvalidWordCount)
+	require.ErrorContains(t, err, "got 11 words")
+
+	// A typical transcription slip, the error carries the number of words
+	// and lists the valid counts.
+	_, err = EntropyFromMnemonic("abandon abandon abandon abandon " +
+		"abandon abandon abandon abandon abandon abandon abandon " +
+		"abandon about")
+	require.EqualError(t, err, "invalid number of words in mnemonic: "+
+		"got 13 words, expected 12, 15, 18, 21 or 24")
+	var countErr *WordCountError
+	require.ErrorAs(t, err, &countErr)
+	require.Equal(t, 13, countErr.Count)
+
+	_, err = EntropyFromMnemonic("abandon abandon abandon abandon " +
+		"abandon abandon abandon abandon abandon abandon abandon " +
+		"chantools")
+	require.ErrorIs(t, err, ErrWordNotFound)
+	require.ErrorContains(t, err, "`chantools`")
+
+	_, err = EntropyFromMnemonic("abandon abandon abandon abandon " +
+		"abandon abandon abandon abandon abandon abandon abandon " +
+		"abandon")
+	require.ErrorIs(t, err, ErrChecksumIncorrect)
+}
+
+func BenchmarkIsMnemonicValid(b *testing.B) {
+	mnemonic := testVectors[5].mnemonic
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !IsMnemonicValid(mnemonic) {
+			b.Fatal("mnemonic should be valid")
+		}
+	}
+}
+
+func TestPadByteSlice(t *testing.T) {
+	padded, err := padByteSlice([]byte{0x01, 0x02}, 4)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x00, 0x00, 0x01, 0x02}, padded)
+
+	padded, err = padByteSlice([]byte{0x01, 0x02}, 2)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x01, 0x02}, padded)
+
+	// A slice that is too long must never be returned unchanged.
+	_, err = padByteSlice(make([]byte, 33), 32)
+	require.Error(t, err)
+}
+
+func TestEntropyToMnemonicOverLength(t *testing.T) {
+	// 33 bytes of entropy must be rejected instead of being silently
+	// accepted or truncated.
+	_, err := EntropyToMnemonic(bytes.Repeat([]byte{0xff}, 33))
+	require.ErrorIs(t, err, ErrEntropyLengthInvalid)
+}
+
+func TestEntropyFromIndicesOutOfRange(t *testing.T) {
+	indices := make([]int, 12)
+	indices[11] = 2048
+	_, err := entropyFromIndices(indices)
+	require.ErrorContains(t, err, "out of range")
+}
+
+func TestEqualMnemonic(t *testing.T) {
+	// For valid mnemonics, the result must be the same as a naive
+	// comparison.
+	for _, a := range testVectors {
+		for _, b := range testVectors {
+			require.Equal(
+				t, a.mnemonic == b.mnemonic,
+				EqualMnemonic(a.mnemonic, b.mnemonic),
+			)
+		}
+	}
+
+	// Whitespace and normalization differences must not matter.
+	v := testVectors[1]
+	require.True(t, EqualMnemonic(
+		v.mnemonic, "  "+strings.ReplaceAll(v.mnemonic, " ", "\t")+"\n",
+	))
+	require.True(t, EqualMnemonic(
+		v.mnemonic, strings.ReplaceAll(v.mnemonic, " ", "　"),
+	))
+
+	// Invalid mnemonics are never equal.
+	invalid := "abandon abandon abandon abandon abandon abandon abandon " +
+		"abandon abandon abandon abandon abandon"
+	require.False(t, EqualMnemonic(invalid, invalid))
+	require.False(t, EqualMnemonic(invalid, testVectors[0].mnemonic))
+	require.False(t, EqualMnemonic("", ""))
+}
+
+func TestMasterKeyFromMnemonic(t *testing.T) {
+	mnemonic := "uncover bargain diesel boss local host over divide " +
+		"orient cradle good crumble"
+	masterKey, err := MasterKeyFromMnemonic(
+		mnemonic, "", &chaincfg.RegressionNetParams,
+	)
+	require.NoError(t, err)
+	require.Equal(
+		t, "tprv8ZgxMBicQKsPdoVEZRN2MyzEgxGTqJepzhMc66b26zL1siLiWRQ"+
+			"AGh9rAgPPJuQeHWWpgcDcS45yi6KBTFeGkQMEb2RNTrP11evJcB4"+
+			"UVSh", masterKey.String(),
+	)
+
+	// The master key must match the one derived from the seed directly.
+	seed := NewSeed(testVectors[0].mnemonic, "TREZOR")
+	expected, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	require.NoError(t, err)
+	masterKey, err = MasterKeyFromMnemonic(
+		testVectors[0].mnemonic, "TREZOR", &chaincfg.MainNetParams,
+	)
+	require.NoError(t, err)
+	require.Equal(t, expected.String(), masterKey.String())
+
+	_, err = MasterKeyFromMnemonic(
+		"abandon abandon abandon abandon abandon abandon abandon "+
+			"abandon abandon abandon abandon abandon", "",
+		&chaincfg.MainNetParams,
+	)
+	require.ErrorIs(t, err, ErrChecksumIncorrect)
+}
+
+func TestWordListLanguage(t *testing.T) {
+	require.Equal(t, "english", WordListLanguage(English))
+	require.Equal(t, "english", WordListLanguage(GetWordList()))
+	require.Equal(t, "unknown", WordListLanguage([]string{"foo"}))
+	require.Equal(t, "unknown", WordListLanguage(nil))
+}
+
+func TestGetWordIndex(t *testing.T) {
+	index, ok := GetWordIndex("abandon")
+	require.True(t, ok)
+	require.Equal(t, 0, index)
+
+	index, ok = GetWordIndex("zoo")
+	require.True(t, ok)
+	require.Equal(t, 2047, index)
+
+	_, ok = GetWordIndex("chantools")
+	require.False(t, ok)
+}
@@ -0,0 +1,100 @@
+package bip39
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+)
+
+const (
+	// bip85Purpose is the purpose of the BIP85 derivation path, the first
+	// bytes of "SEED" in ASCII.
+	bip85Purpose = 83696968
+
+	// bip85AppBIP39 is the BIP85 application number for BIP39 mnemonics.
+	bip85AppBIP39 = 39
+)
+
+var (
+	// bip85HMACKey is the key of the HMAC that turns a derived private key
+	// into entropy.
+	bip85HMACKey = []byte("bip-entropy-from-k")
+
+	// bip85Languages are the names of the BIP39 word lists, indexed by the
+	// language number BIP85 uses in the derivation path.
+	bip85Languages = []string{
+		"english", "japanese", "korean", "spanish",
+		"chinese_simplified", "chinese_traditional", "french",
+		"italian", "czech",
+	}
+
+	// bip85EntropyBytes maps the number of words of a child mnemonic to the
+	// number of entropy bytes that are used for it.
+	bip85EntropyBytes = map[int]int{
+		12: 16,
+		18: 24,
+		24: 32,
+	}
+)
+
+// BIP85Mnemonic derives a child BIP39 mnemonic from the given master key as
+// defined by the BIP39 application of BIP85, using the derivation path
+// m/83696968'/39'/language'/words'/index'. The language is the number BIP85
+// assigns to each word list (0 is English), the number of words must be 12,
+// 18 or 24.
+//
+// The BIP85 spec can be found at
+// https://github.com/bitcoin/bips/blob/master/bip-0085.mediawiki
+func BIP85Mnemonic(masterKey *hdkeychain.ExtendedKey, language, words,
+	index int) (string, error) {
+
+	if !masterKey.IsPrivate() {
+		return "", fmt.Errorf("master key must be a private key")
+	}
+
+	if language < 0 || language >= len(bip85Languages) {
+		return "", fmt.Errorf("invalid language %d, must be between 0 "+
+			"and %d", language, len(bip85Languages)-1)
+	}
+	list, err := WordListByLanguage(bip85Languages[language])
+	if err != nil {
+		return "", err
+	}
+
+	entropyBytes, ok := bip85EntropyBytes[words]
+	if !ok {
+		return "", fmt.Errorf("invalid number of words %d, must be "+
+			"12, 18 or 24", words)
+	}
+
+	if index < 0 || int64(index) >= hdkeychain.HardenedKeyStart {
+		return "", fmt.Errorf("invalid index %d, must be between 0 "+
+			"and %d", index, hdkeychain.HardenedKeyStart-1)
+	}
+
+	key := masterKey
+	path := []uint32{
+		bip85Purpose, bip85AppBIP39, uint32(language), uint32(words),
+		uint32(index),
+	}
+	for _, pathPart := range path {
+		key, err = key.Derive(hdkeychain.HardenedKeyStart + pathPart)
+		if err != nil {
+			return "", fmt.Errorf("error deriving child key: %w",
+				err)
+		}
+	}
+
+	privKey, err := key.ECPrivKey()
+	if err != nil {
+		return "", fmt.Errorf("error deriving child key: %w", err)
+	}
+
+	mac := hmac.New(sha512.New, bip85HMACKey)
+	_, _ = mac.Write(privKey.Serialize())
+	entropy := mac.Sum(nil)[:entropyBytes]
+
+	return entropyToMnemonic(entropy, list)
+}
@@ -0,0 +1,78 @@
+package bip39
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/stretchr/testify/require"
+)
+
+// bip85MasterKey is the master key of the official BIP85 test vectors at
+// https://github.com/bitcoin/bips/blob/master/bip-0085.mediawiki.
+const bip85MasterKey = "xprv9s21ZrQH143K2LBWUUQRFXhucrQqBpKdRRxNVq2zBqsx8HVq" +
+	"Fk2uYo8kmbaLLHRdqtQpUm98uKfu3vca1LqdGhUtyoFnCNkfmXRyPXLjbKb"
+
+func TestBIP85Mnemonic(t *testing.T) {
+	masterKey, err := hdkeychain.NewKeyFromString(bip85MasterKey)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		words    int
+		mnemonic string
+	}{{
+		words: 12,
+		mnemonic: "girl mad pet galaxy egg matter matrix prison " +
+			"refuse sense ordinary nose",
+	}, {
+		words: 18,
+		mnemonic: "near account window bike charge season chef " +
+			"number sketch tomorrow excuse sniff circle vital " +
+			"hockey outdoor supply token",
+	}, {
+		words: 24,
+		mnemonic: "puppy ocean match cereal symbol another shed " +
+			"magic wrap hammer bulb intact gadget divorce twin " +
+			"tonight reason outdoor destroy simple truth cigar " +
+			"social volcano",
+	}}
+
+	for _, tc := range testCases {
+		mnemonic, err := BIP85Mnemonic(masterKey, 0, tc.words, 0)
+		require.NoError(t, err)
+		require.Equal(t, tc.mnemonic, mnemonic)
+		require.True(t, IsMnemonicValid(mnemonic))
+	}
+
+	// A different index results in a different mnemonic.
+	mnemonic, err := BIP85Mnemonic(masterKey, 0, 12, 1)
+	require.NoError(t, err)
+	require.NotEqual(t, testCases[0].mnemonic, mnemonic)
+
+	// Other languages use their own word list.
+	mnemonic, err = BIP85Mnemonic(masterKey, 1, 12, 0)
+	require.NoError(t, err)
+	require.False(t, IsMnemonicValid(mnemonic))
+
+	require.NoError(t, SetWordList(Japanese))
+	defer SetWordList(English) // nolint:errcheck
+	require.True(t, IsMnemonicValid(mnemonic))
+}
+
+func TestBIP85MnemonicInvalid(t *testing.T) {
+	masterKey, err := hdkeychain.NewKeyFromString(bip85MasterKey)
+	require.NoError(t, err)
+	pubKey, err := masterKey.Neuter()
+	require.NoError(t, err)
+
+	_, err = BIP85Mnemonic(pubKey, 0, 12, 0)
+	require.ErrorContains(t, err, "must be a private key")
+
+	_, err = BIP85Mnemonic(masterKey, 9, 12, 0)
+	require.ErrorContains(t, err, "invalid language 9")
+
+	_, err = BIP85Mnemonic(masterKey, 0, 15, 0)
+	require.ErrorContains(t, err, "invalid number of words 15")
+
+	_, err = BIP85Mnemonic(masterKey, 0, 12, -1)
+	require.ErrorContains(t, err, "invalid index -1")
+}
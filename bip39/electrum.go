@@ -0,0 +1,72 @@
+package bip39
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// ElectrumSeedStandard is the version of an Electrum seed for a
+	// standard (P2PKH) wallet.
+	ElectrumSeedStandard = "standard"
+
+	// ElectrumSeedSegwit is the version of an Electrum seed for a native
+	// SegWit (P2WPKH) wallet.
+	ElectrumSeedSegwit = "segwit"
+)
+
+var (
+	// ErrElectrumSeed is returned when a mnemonic that fails the BIP39
+	// checksum was detected to be an Electrum seed.
+	ErrElectrumSeed = errors.New("electrum seed detected, electrum " +
+		"seeds are not BIP39 compatible and need electrum's own key " +
+		"derivation")
+
+	// electrumSeedPrefixes maps the hex encoded prefix of the seed version
+	// HMAC to the Electrum seed version.
+	electrumSeedPrefixes = map[string]string{
+		"01":  ElectrumSeedStandard,
+		"100": ElectrumSeedSegwit,
+	}
+)
+
+// IsElectrumSeed checks if the given mnemonic is an Electrum seed by checking
+// the version prefix of the HMAC-SHA512 of the normalized mnemonic, keyed with
+// "Seed version". If it is, the seed version (ElectrumSeedStandard or
+// ElectrumSeedSegwit) is returned.
+func IsElectrumSeed(mnemonic string) (string, bool) {
+	mac := hmac.New(sha512.New, []byte("Seed version"))
+	_, _ = mac.Write([]byte(normalizeElectrumSeed(mnemonic)))
+	versionHex := hex.EncodeToString(mac.Sum(nil))
+
+	for prefix, version := range electrumSeedPrefixes {
+		if strings.HasPrefix(versionHex, prefix) {
+			return version, true
+		}
+	}
+
+	return "", false
+}
+
+// normalizeElectrumSeed normalizes a seed the same way Electrum does before
+// calculating its version: NFKD normalization, lower case, removal of all
+// combining marks (accents) and collapsing of all whitespace into a single
+// space. The removal of whitespace between CJK characters that Electrum also
+// does is not implemented, so only non-CJK seeds can be detected.
+func normalizeElectrumSeed(mnemonic string) string {
+	mnemonic = strings.ToLower(norm.NFKD.String(mnemonic))
+	mnemonic = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, mnemonic)
+
+	return strings.Join(strings.Fields(mnemonic), " ")
+}
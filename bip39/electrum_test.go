@@ -0,0 +1,44 @@
+package bip39
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// electrumSegwitSeed is taken from Electrum's own unit tests.
+	electrumSegwitSeed = "wild father tree among universe such mobile " +
+		"favorite target dynamic credit identify"
+
+	electrumStandardSeed = "urge still task resource matrix bright " +
+		"regular swallow primary season slender all"
+)
+
+func TestIsElectrumSeed(t *testing.T) {
+	version, ok := IsElectrumSeed(electrumSegwitSeed)
+	require.True(t, ok)
+	require.Equal(t, ElectrumSeedSegwit, version)
+
+	version, ok = IsElectrumSeed(electrumStandardSeed)
+	require.True(t, ok)
+	require.Equal(t, ElectrumSeedStandard, version)
+
+	// Case and whitespace must not matter.
+	version, ok = IsElectrumSeed("  WILD father tree among universe " +
+		"such mobile favorite target dynamic credit \t identify\n")
+	require.True(t, ok)
+	require.Equal(t, ElectrumSeedSegwit, version)
+
+	_, ok = IsElectrumSeed(testVectors[0].mnemonic)
+	require.False(t, ok)
+}
+
+func TestEntropyFromMnemonicElectrum(t *testing.T) {
+	seeds := []string{electrumSegwitSeed, electrumStandardSeed}
+	for _, seed := range seeds {
+		_, err := EntropyFromMnemonic(seed)
+		require.ErrorIs(t, err, ErrElectrumSeed)
+		require.False(t, IsMnemonicValid(seed))
+	}
+}
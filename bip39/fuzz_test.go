@@ -0,0 +1,54 @@
+//go:build go1.18
+// +build go1.18
+
+package bip39
+
+import (
+	"testing"
+)
+
+func FuzzEntropyFromMnemonic(f *testing.F) {
+	for _, v := range testVectors {
+		f.Add(v.mnemonic)
+	}
+	f.Add("abandon abandon abandon abandon abandon abandon abandon " +
+		"abandon abandon abandon abandon abandon")
+	f.Add("abandon　abandon abandon abandon abandon abandon abandon " +
+		"abandon abandon abandon abandon about")
+	f.Add("Abandon ABANDON abandon abandon abandon abandon abandon " +
+		"abandon abandon abandon abandon About")
+	f.Add("  abandon abandon  abandon\tabandon abandon abandon abandon " +
+		"abandon abandon abandon abandon   about\n")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, mnemonic string) {
+		entropy, err := EntropyFromMnemonic(mnemonic)
+		if err != nil {
+			return
+		}
+
+		if err := validateEntropyBitSize(len(entropy) * 8); err != nil {
+			t.Fatalf("decoded entropy %x of invalid size: %v",
+				entropy, err)
+		}
+
+		encoded, err := EntropyToMnemonic(entropy)
+		if err != nil {
+			t.Fatalf("error re-encoding entropy %x: %v", entropy,
+				err)
+		}
+
+		// The decoded words are normalized, so we can only compare
+		// against the normalized input. A mnemonic that could be
+		// decoded must always be normalizable.
+		normalized, err := NormalizeMnemonic(mnemonic)
+		if err != nil {
+			t.Fatalf("error normalizing decodable mnemonic %q: %v",
+				mnemonic, err)
+		}
+		if encoded != normalized {
+			t.Fatalf("mnemonic %q was re-encoded as %q", normalized,
+				encoded)
+		}
+	})
+}
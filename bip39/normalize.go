@@ -0,0 +1,142 @@
+package bip39
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// NormalizationPolicy defines how a mnemonic that isn't in its canonical form,
+// lower case words separated by a single space, is treated when it is decoded.
+type NormalizationPolicy uint8
+
+const (
+	// NormalizeLenient lower cases all words and ignores leading, trailing
+	// and duplicate whitespace, so the seed is always derived from the
+	// canonical form of the mnemonic. This is the default policy.
+	NormalizeLenient NormalizationPolicy = iota
+
+	// NormalizeStrict rejects any mnemonic that isn't in its canonical
+	// form, so the seed is only ever derived from exactly the given string.
+	// Only the NFKD normalization mandated by BIP39 is still applied.
+	NormalizeStrict
+)
+
+var (
+	// ErrMnemonicNotNormalized is returned when decoding a mnemonic that
+	// isn't in its canonical form with the NormalizeStrict policy.
+	ErrMnemonicNotNormalized = errors.New("mnemonic is not normalized")
+
+	// normalizationPolicy is the policy that is currently used for
+	// decoding mnemonics. It can be changed with SetNormalizationPolicy.
+	normalizationPolicy = NormalizeLenient
+)
+
+// String returns the name of the policy.
+func (p NormalizationPolicy) String() string {
+	switch p {
+	case NormalizeLenient:
+		return "lenient"
+
+	case NormalizeStrict:
+		return "strict"
+
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(p))
+	}
+}
+
+// SetNormalizationPolicy sets the policy that is used for all mnemonics that
+// are decoded afterwards. Like the word list, it must not be changed while
+// mnemonics are decoded concurrently.
+func SetNormalizationPolicy(policy NormalizationPolicy) error {
+	switch policy {
+	case NormalizeLenient, NormalizeStrict:
+		normalizationPolicy = policy
+		return nil
+
+	default:
+		return fmt.Errorf("unknown normalization policy %v", policy)
+	}
+}
+
+// GetNormalizationPolicy gets the policy that is used for decoding mnemonics.
+func GetNormalizationPolicy() NormalizationPolicy {
+	return normalizationPolicy
+}
+
+// NormalizeMnemonic returns the canonical form of the given mnemonic according
+// to the active normalization policy. With the NormalizeStrict policy, an error
+// that matches ErrMnemonicNotNormalized and names the violation is returned if
+// the mnemonic isn't in its canonical form already.
+func NormalizeMnemonic(mnemonic string) (string, error) {
+	words, err := normalizedWords(mnemonic)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// normalizedWords returns the words of the given mnemonic after applying the
+// active normalization policy.
+func normalizedWords(mnemonic string) ([]string, error) {
+	mnemonic = normalizeMnemonic(mnemonic)
+	if normalizationPolicy == NormalizeLenient {
+		return strings.Fields(strings.ToLower(mnemonic)), nil
+	}
+
+	if err := checkNormalized(mnemonic); err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(mnemonic), nil
+}
+
+// checkNormalized returns an error that names the first violation if the given
+// NFKD normalized mnemonic isn't in its canonical form. The words are only
+// referenced by their position so the error can be shown safely.
+func checkNormalized(mnemonic string) error {
+	switch {
+	case strings.TrimLeftFunc(mnemonic, unicode.IsSpace) != mnemonic:
+		return fmt.Errorf("%w: leading whitespace",
+			ErrMnemonicNotNormalized)
+
+	case strings.TrimRightFunc(mnemonic, unicode.IsSpace) != mnemonic:
+		return fmt.Errorf("%w: trailing whitespace",
+			ErrMnemonicNotNormalized)
+	}
+
+	word, afterSpace := 1, false
+	for _, r := range mnemonic {
+		switch {
+		case unicode.IsSpace(r) && afterSpace:
+			return fmt.Errorf("%w: duplicate whitespace after "+
+				"word %d", ErrMnemonicNotNormalized, word)
+
+		case unicode.IsSpace(r) && r != ' ':
+			return fmt.Errorf("%w: whitespace other than a single "+
+				"space after word %d", ErrMnemonicNotNormalized,
+				word)
+
+		case unicode.IsSpace(r):
+			afterSpace = true
+			continue
+
+		case unicode.ToLower(r) != r:
+			if afterSpace {
+				word++
+			}
+			return fmt.Errorf("%w: upper case letter in word %d",
+				ErrMnemonicNotNormalized, word)
+		}
+
+		if afterSpace {
+			word++
+			afterSpace = false
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package bip39
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeMnemonicLenient(t *testing.T) {
+	require.Equal(t, NormalizeLenient, GetNormalizationPolicy())
+
+	v := testVectors[1]
+	messy := "  " + strings.ToUpper(v.mnemonic[:5]) +
+		strings.ReplaceAll(v.mnemonic[5:], " ", " \t ") + "\r\n"
+
+	normalized, err := NormalizeMnemonic(messy)
+	require.NoError(t, err)
+	require.Equal(t, v.mnemonic, normalized)
+
+	entropy, err := EntropyFromMnemonic(messy)
+	require.NoError(t, err)
+	require.Equal(t, v.entropy, hex.EncodeToString(entropy))
+
+	// NewSeed derives the seed from exactly the given string, like BIP39
+	// mandates. Only the decoding functions derive it from the canonical
+	// form of the mnemonic.
+	seed := NewSeed(messy, "TREZOR")
+	require.NotEqual(t, v.seed, hex.EncodeToString(seed))
+
+	seed, err = NewSeedWithErrorChecking(messy, "TREZOR")
+	require.NoError(t, err)
+	require.Equal(t, v.seed, hex.EncodeToString(seed))
+
+	masterKey, err := MasterKeyFromMnemonic(
+		messy, "TREZOR", &chaincfg.MainNetParams,
+	)
+	require.NoError(t, err)
+	expectedKey, err := hdkeychain.NewMaster(
+		NewSeed(normalized, "TREZOR"), &chaincfg.MainNetParams,
+	)
+	require.NoError(t, err)
+	require.Equal(t, expectedKey.String(), masterKey.String())
+}
+
+func TestNormalizeMnemonicStrict(t *testing.T) {
+	require.NoError(t, SetNormalizationPolicy(NormalizeStrict))
+	defer SetNormalizationPolicy(NormalizeLenient) // nolint:errcheck
+
+	require.Equal(t, NormalizeStrict, GetNormalizationPolicy())
+
+	v := testVectors[1]
+	for _, mnemonic := range []string{
+		v.mnemonic, strings.ReplaceAll(v.mnemonic, " ", "　"),
+	} {
+		normalized, err := NormalizeMnemonic(mnemonic)
+		require.NoError(t, err)
+		require.Equal(t, v.mnemonic, normalized)
+		require.Equal(
+			t, v.seed,
+			hex.EncodeToString(NewSeed(mnemonic, "TREZOR")),
+		)
+	}
+
+	words := strings.Fields(v.mnemonic)
+	testCases := []struct {
+		name      string
+		mnemonic  string
+		violation string
+	}{{
+		name:      "leading space",
+		mnemonic:  " " + v.mnemonic,
+		violation: "leading whitespace",
+	}, {
+		name:      "trailing newline",
+		mnemonic:  v.mnemonic + "\n",
+		violation: "trailing whitespace",
+	}, {
+		name: "duplicate space",
+		mnemonic: strings.Join(words[:3], " ") + "  " +
+			strings.Join(words[3:], " "),
+		violation: "duplicate whitespace after word 3",
+	}, {
+		name:      "tab",
+		mnemonic:  strings.ReplaceAll(v.mnemonic, " ", "\t"),
+		violation: "whitespace other than a single space after word 1",
+	}, {
+		name:      "first word upper case",
+		mnemonic:  "L" + v.mnemonic[1:],
+		violation: "upper case letter in word 1",
+	}, {
+		name:      "upper case",
+		mnemonic:  strings.Replace(v.mnemonic, "thank", "thAnk", 1),
+		violation: "upper case letter in word 3",
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NormalizeMnemonic(tc.mnemonic)
+			require.ErrorIs(t, err, ErrMnemonicNotNormalized)
+			require.ErrorContains(t, err, tc.violation)
+
+			_, err = EntropyFromMnemonic(tc.mnemonic)
+			require.ErrorIs(t, err, ErrMnemonicNotNormalized)
+		})
+	}
+
+	// The same mnemonic results in a different seed if it isn't
+	// normalized, that's why strict mode rejects it.
+	seed := NewSeed(" "+v.mnemonic, "TREZOR")
+	require.NotEqual(t, v.seed, hex.EncodeToString(seed))
+}
+
+func TestSetNormalizationPolicyInvalid(t *testing.T) {
+	err := SetNormalizationPolicy(NormalizationPolicy(7))
+	require.ErrorContains(t, err, "unknown normalization policy unknown(7)")
+	require.Equal(t, NormalizeLenient, GetNormalizationPolicy())
+}
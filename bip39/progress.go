@@ -0,0 +1,145 @@
+package bip39
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultProgressInterval is the default time between two progress
+	// reports of a brute force search.
+	DefaultProgressInterval = time.Second
+
+	// progressBatchSize is the number of candidates a worker checks before
+	// it adds them to the shared counters. This keeps the contention on the
+	// counters low in the hot loop.
+	progressBatchSize = 256
+)
+
+// SearchProgress is the progress of a brute force search for missing words.
+type SearchProgress struct {
+	// Tried is the number of candidates that were checked so far.
+	Tried uint64
+
+	// Total is the number of candidates that are checked in total.
+	Total uint64
+
+	// Found is the number of candidates with a valid checksum that were
+	// found so far.
+	Found uint64
+
+	// Elapsed is the time since the search was started.
+	Elapsed time.Duration
+
+	// Remaining is the estimated time until all candidates are checked.
+	// It is zero as long as no candidate was checked.
+	Remaining time.Duration
+}
+
+// ProgressFunc is called periodically with the progress of a brute force
+// search. It is called from a separate goroutine, so it must not block for
+// long.
+type ProgressFunc func(progress *SearchProgress)
+
+// progressTracker counts the candidates of a search and reports the progress
+// to a ProgressFunc in regular intervals. A nil tracker is valid and doesn't
+// track anything.
+type progressTracker struct {
+	// The counters are accessed atomically, so they must be the first
+	// fields of the struct to be 64-bit aligned on 32-bit platforms.
+	tried uint64
+	found uint64
+
+	total    uint64
+	start    time.Time
+	progress ProgressFunc
+
+	quit     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// newProgressTracker starts reporting the progress of a search over the given
+// total number of candidates in the given interval. If the progress function
+// is nil, no tracker is created.
+func newProgressTracker(progress ProgressFunc, interval time.Duration,
+	total uint64) *progressTracker {
+
+	if progress == nil {
+		return nil
+	}
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+
+	p := &progressTracker{
+		total:    total,
+		start:    time.Now(),
+		progress: progress,
+		quit:     make(chan struct{}),
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.report()
+
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+// add adds the given number of tried and found candidates to the counters.
+func (p *progressTracker) add(tried, found uint64) {
+	if p == nil {
+		return
+	}
+
+	atomic.AddUint64(&p.tried, tried)
+	if found > 0 {
+		atomic.AddUint64(&p.found, found)
+	}
+}
+
+// stop stops the periodic reports and reports the final progress once.
+func (p *progressTracker) stop() {
+	if p == nil {
+		return
+	}
+
+	p.stopOnce.Do(func() {
+		close(p.quit)
+		p.wg.Wait()
+		p.report()
+	})
+}
+
+// report calls the progress function with the current progress.
+func (p *progressTracker) report() {
+	tried := atomic.LoadUint64(&p.tried)
+	progress := &SearchProgress{
+		Tried:   tried,
+		Total:   p.total,
+		Found:   atomic.LoadUint64(&p.found),
+		Elapsed: time.Since(p.start),
+	}
+	if tried > 0 && tried < p.total {
+		remaining := float64(progress.Elapsed) *
+			float64(p.total-tried) / float64(tried)
+		progress.Remaining = time.Duration(remaining)
+	}
+
+	p.progress(progress)
+}
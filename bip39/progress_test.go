@@ -0,0 +1,40 @@
+package bip39
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressTrackerRemaining(t *testing.T) {
+	var reports []*SearchProgress
+	tracker := newProgressTracker(func(progress *SearchProgress) {
+		reports = append(reports, progress)
+	}, time.Hour, 1000)
+
+	// Pretend the search was started a second ago. A quarter of the
+	// candidates were checked, so three more seconds are needed.
+	tracker.start = time.Now().Add(-time.Second)
+	tracker.add(250, 1)
+	tracker.report()
+
+	require.Len(t, reports, 1)
+	require.EqualValues(t, 250, reports[0].Tried)
+	require.EqualValues(t, 1, reports[0].Found)
+	require.InDelta(
+		t, 3*time.Second, reports[0].Remaining,
+		float64(100*time.Millisecond),
+	)
+
+	// Stopping reports the final progress only once.
+	tracker.stop()
+	tracker.stop()
+	require.Len(t, reports, 2)
+
+	// A nil tracker does nothing.
+	tracker = newProgressTracker(nil, 0, 1000)
+	require.Nil(t, tracker)
+	tracker.add(1, 1)
+	tracker.stop()
+}
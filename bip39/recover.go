@@ -0,0 +1,257 @@
+package bip39
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FindMissingWord tries to recover a single missing word of a mnemonic. The
+// given mnemonic must contain all known words in their correct order, the
+// missing word is inserted at missingIndex (starting at zero). Every word of
+// the active word list is tried at that position and all resulting mnemonics
+// that have a valid checksum are returned. Because the checksum only consists
+// of a few bits, there usually is more than one valid completion.
+func FindMissingWord(mnemonic string, missingIndex int) ([]string, error) {
+	return FindMissingWordProgress(mnemonic, missingIndex, nil, 0)
+}
+
+// FindMissingWordProgress works like FindMissingWord but calls the progress
+// function with the number of candidates tried and found so far in the given
+// interval (DefaultProgressInterval if zero) and once more when the search is
+// done. The progress function can be nil.
+func FindMissingWordProgress(mnemonic string, missingIndex int,
+	progress ProgressFunc, interval time.Duration) ([]string, error) {
+
+	words, err := normalizedWords(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+
+	knownIndices, err := knownWordIndices(words)
+	if err != nil {
+		return nil, err
+	}
+
+	if missingIndex < 0 || missingIndex > len(knownIndices) {
+		return nil, fmt.Errorf("missing word index %d out of range, "+
+			"must be between 0 and %d", missingIndex,
+			len(knownIndices))
+	}
+
+	tracker := newProgressTracker(
+		progress, interval, uint64(len(wordList)),
+	)
+	validCandidates := findCandidates(knownIndices, missingIndex, tracker)
+	tracker.stop()
+
+	var mnemonics []string
+	for candidate, valid := range validCandidates {
+		if !valid {
+			continue
+		}
+
+		indices := insertIndex(knownIndices, missingIndex, candidate)
+		mnemonics = append(mnemonics, indicesToMnemonic(indices))
+	}
+
+	return mnemonics, nil
+}
+
+// MissingWordResult is a mnemonic with a valid checksum that was found by
+// inserting a word at the given index of the known words.
+type MissingWordResult struct {
+	// Index is the position (starting at zero) the missing word was
+	// inserted at.
+	Index int
+
+	// Mnemonic is the full mnemonic including the inserted word.
+	Mnemonic string
+}
+
+// FindMissingWordAnyPosition tries to recover a single missing word of a
+// mnemonic without knowing its position. The known words must be in their
+// correct order and be exactly one word short of a valid mnemonic. Every word
+// of the active word list is tried at every position and all resulting
+// mnemonics with a valid checksum are returned, together with the position the
+// word was inserted at.
+func FindMissingWordAnyPosition(knownWords []string) ([]MissingWordResult,
+	error) {
+
+	quit := make(chan struct{})
+	defer close(quit)
+
+	resultChan, err := StreamMissingWordAnyPosition(knownWords, quit)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []MissingWordResult
+	for result := range resultChan {
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// StreamMissingWordAnyPosition works like FindMissingWordAnyPosition but
+// streams the results over the returned channel as soon as they are found,
+// one position after the other. The channel is closed once all positions have
+// been tried or the quit channel is closed. Because only a single word can be
+// missing, at most (len(knownWords)+1)*2048 candidates are checked. The same
+// mnemonic can result from inserting a word at different positions (for
+// example next to the same word), it is only reported for the first position.
+func StreamMissingWordAnyPosition(knownWords []string,
+	quit <-chan struct{}) (<-chan MissingWordResult, error) {
+
+	return StreamMissingWordAnyPositionProgress(knownWords, quit, nil, 0)
+}
+
+// StreamMissingWordAnyPositionProgress works like StreamMissingWordAnyPosition
+// but calls the progress function in the given interval
+// (DefaultProgressInterval if zero) and once more before the result channel
+// is closed. As the same mnemonic can have a valid checksum at several
+// positions, the number of found candidates can be higher than the number of
+// results. The progress function can be nil.
+func StreamMissingWordAnyPositionProgress(knownWords []string,
+	quit <-chan struct{}, progress ProgressFunc,
+	interval time.Duration) (<-chan MissingWordResult, error) {
+
+	words, err := normalizedWords(strings.Join(knownWords, " "))
+	if err != nil {
+		return nil, err
+	}
+
+	knownIndices, err := knownWordIndices(words)
+	if err != nil {
+		return nil, err
+	}
+
+	numPositions := uint64(len(knownIndices) + 1)
+	tracker := newProgressTracker(
+		progress, interval, numPositions*uint64(len(wordList)),
+	)
+
+	results := make(chan MissingWordResult)
+	go func() {
+		defer close(results)
+		defer tracker.stop()
+
+		seen := make(map[string]struct{})
+		for position := 0; position <= len(knownIndices); position++ {
+			valid := findCandidates(knownIndices, position, tracker)
+			for candidate, isValid := range valid {
+				if !isValid {
+					continue
+				}
+
+				mnemonic := indicesToMnemonic(insertIndex(
+					knownIndices, position, candidate,
+				))
+				if _, ok := seen[mnemonic]; ok {
+					continue
+				}
+				seen[mnemonic] = struct{}{}
+
+				select {
+				case results <- MissingWordResult{
+					Index:    position,
+					Mnemonic: mnemonic,
+				}:
+
+				case <-quit:
+					return
+				}
+			}
+		}
+	}()
+
+	return results, nil
+}
+
+// knownWordIndices looks up the word list indices of the given known words of
+// a mnemonic that is missing exactly one word.
+func knownWordIndices(words []string) ([]int, error) {
+	if _, ok := wordLengthChecksumMasksMapping[len(words)+1]; !ok {
+		return nil, fmt.Errorf("%w: got %d known words, expected one "+
+			"less than a valid mnemonic", ErrInvalidWordCount,
+			len(words))
+	}
+
+	indices := make([]int, len(words))
+	for idx, word := range words {
+		index, found := wordMap[word]
+		if !found {
+			return nil, fmt.Errorf("%w: `%v`", ErrWordNotFound,
+				word)
+		}
+		indices[idx] = index
+	}
+
+	return indices, nil
+}
+
+// findCandidates inserts every index of the active word list at the given
+// position of the known indices and returns which of those candidates result
+// in a valid checksum. The work is split across all available CPUs. The
+// checked candidates are added to the tracker, which can be nil.
+func findCandidates(knownIndices []int, position int,
+	tracker *progressTracker) []bool {
+
+	var (
+		numWorkers = runtime.NumCPU()
+		valid      = make([]bool, len(wordList))
+		wg         sync.WaitGroup
+	)
+	for worker := 0; worker < numWorkers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+
+			// Each worker only writes to its own elements of the
+			// result slice, so no locking is required.
+			var tried, found uint64
+			for c := worker; c < len(wordList); c += numWorkers {
+				indices := insertIndex(
+					knownIndices, position, c,
+				)
+				_, err := entropyFromIndices(indices)
+				valid[c] = err == nil
+
+				tried++
+				if valid[c] {
+					found++
+				}
+				if tried == progressBatchSize {
+					tracker.add(tried, found)
+					tried, found = 0, 0
+				}
+			}
+			tracker.add(tried, found)
+		}(worker)
+	}
+	wg.Wait()
+
+	return valid
+}
+
+// insertIndex returns a copy of the given indices with the index inserted at
+// the given position.
+func insertIndex(indices []int, position, index int) []int {
+	result := make([]int, 0, len(indices)+1)
+	result = append(result, indices[:position]...)
+	result = append(result, index)
+	return append(result, indices[position:]...)
+}
+
+// indicesToMnemonic returns the mnemonic for the given word list indices.
+func indicesToMnemonic(indices []int) string {
+	words := make([]string, len(indices))
+	for idx, index := range indices {
+		words[idx] = wordList[index]
+	}
+
+	return strings.Join(words, " ")
+}
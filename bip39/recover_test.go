@@ -0,0 +1,176 @@
+package bip39
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindMissingWord(t *testing.T) {
+	for _, v := range testVectors {
+		words := strings.Fields(v.mnemonic)
+		positions := []int{0, len(words) / 2, len(words) - 1}
+		for _, missingIndex := range positions {
+			known := make([]string, 0, len(words)-1)
+			known = append(known, words[:missingIndex]...)
+			known = append(known, words[missingIndex+1:]...)
+
+			mnemonics, err := FindMissingWord(
+				strings.Join(known, " "), missingIndex,
+			)
+			require.NoError(t, err)
+			require.Contains(t, mnemonics, v.mnemonic)
+
+			for _, mnemonic := range mnemonics {
+				require.True(t, IsMnemonicValid(mnemonic))
+			}
+		}
+	}
+}
+
+func TestFindMissingWordErrors(t *testing.T) {
+	known := strings.Fields(testVectors[0].mnemonic)[1:]
+
+	_, err := FindMissingWord(strings.Join(known, " "), -1)
+	require.ErrorContains(t, err, "out of range")
+
+	_, err = FindMissingWord(strings.Join(known, " "), len(known)+1)
+	require.ErrorContains(t, err, "out of range")
+
+	_, err = FindMissingWord(strings.Join(known[1:], " "), 0)
+	require.ErrorIs(t, err, ErrInvalidWordCount)
+
+	known[3] = "chantools"
+	_, err = FindMissingWord(strings.Join(known, " "), 0)
+	require.ErrorIs(t, err, ErrWordNotFound)
+}
+
+func TestFindMissingWordNormalization(t *testing.T) {
+	v := testVectors[1]
+	words := strings.Fields(v.mnemonic)
+	messy := "  " + strings.ToUpper(words[0]) + "\t " +
+		strings.Join(words[1:len(words)-1], "  ") + "\n"
+
+	// The known words are normalized the same way as when decoding a
+	// mnemonic.
+	mnemonics, err := FindMissingWord(messy, len(words)-1)
+	require.NoError(t, err)
+	require.Contains(t, mnemonics, v.mnemonic)
+
+	require.NoError(t, SetNormalizationPolicy(NormalizeStrict))
+	defer SetNormalizationPolicy(NormalizeLenient) // nolint:errcheck
+
+	_, err = FindMissingWord(messy, len(words)-1)
+	require.ErrorIs(t, err, ErrMnemonicNotNormalized)
+}
+
+func TestFindMissingWordAnyPosition(t *testing.T) {
+	v := testVectors[6]
+	words := strings.Fields(v.mnemonic)
+	known := append([]string{}, words[:4]...)
+	known = append(known, words[5:]...)
+
+	results, err := FindMissingWordAnyPosition(known)
+	require.NoError(t, err)
+
+	found := false
+	seen := make(map[string]struct{})
+	for _, result := range results {
+		require.True(t, IsMnemonicValid(result.Mnemonic))
+		require.Equal(
+			t, result.Mnemonic,
+			strings.Join(insertWord(
+				known, result.Index,
+				strings.Fields(result.Mnemonic)[result.Index],
+			), " "),
+		)
+
+		// Every mnemonic must only be reported once.
+		require.NotContains(t, seen, result.Mnemonic)
+		seen[result.Mnemonic] = struct{}{}
+
+		if result.Mnemonic == v.mnemonic && result.Index == 4 {
+			found = true
+		}
+	}
+	require.True(t, found)
+
+	_, err = FindMissingWordAnyPosition(words)
+	require.ErrorIs(t, err, ErrInvalidWordCount)
+}
+
+func TestStreamMissingWordAnyPositionQuit(t *testing.T) {
+	known := strings.Fields(testVectors[0].mnemonic)[1:]
+
+	quit := make(chan struct{})
+	results, err := StreamMissingWordAnyPosition(known, quit)
+	require.NoError(t, err)
+
+	// Read a single result, then abort. The channel must be closed
+	// afterwards.
+	<-results
+	close(quit)
+	for range results {
+	}
+}
+
+func TestFindMissingWordProgress(t *testing.T) {
+	v := testVectors[0]
+	words := strings.Fields(v.mnemonic)
+	known := strings.Join(words[1:], " ")
+
+	var reports []*SearchProgress
+	mnemonics, err := FindMissingWordProgress(
+		known, 0, func(progress *SearchProgress) {
+			reports = append(reports, progress)
+		}, time.Hour,
+	)
+	require.NoError(t, err)
+	require.Contains(t, mnemonics, v.mnemonic)
+
+	// The interval is too long for a periodic report, so there's only the
+	// final one.
+	require.Len(t, reports, 1)
+	require.EqualValues(t, 2048, reports[0].Tried)
+	require.EqualValues(t, 2048, reports[0].Total)
+	require.EqualValues(t, len(mnemonics), reports[0].Found)
+	require.Zero(t, reports[0].Remaining)
+}
+
+func TestStreamMissingWordAnyPositionProgress(t *testing.T) {
+	known := strings.Fields(testVectors[0].mnemonic)[1:]
+
+	var (
+		mtx   sync.Mutex
+		final *SearchProgress
+	)
+	results, err := StreamMissingWordAnyPositionProgress(
+		known, nil, func(progress *SearchProgress) {
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			final = progress
+		}, time.Millisecond,
+	)
+	require.NoError(t, err)
+
+	numResults := 0
+	for range results {
+		numResults++
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.EqualValues(t, 12*2048, final.Tried)
+	require.EqualValues(t, 12*2048, final.Total)
+	require.GreaterOrEqual(t, final.Found, uint64(numResults))
+}
+
+func insertWord(words []string, position int, word string) []string {
+	result := append([]string{}, words[:position]...)
+	result = append(result, word)
+	return append(result, words[position:]...)
+}
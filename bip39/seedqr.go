@@ -0,0 +1,60 @@
+package bip39
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrSeedQRWordCount is returned when trying to encode a mnemonic as
+	// a SeedQR that doesn't have 12 or 24 words, the only lengths the
+	// SeedQR format supports.
+	ErrSeedQRWordCount = errors.New("SeedQR only supports 12 and 24 " +
+		"word mnemonics")
+)
+
+// SeedQRStandard returns the payload of a standard SeedQR for the given
+// mnemonic. Each word is encoded as its four digit, zero padded index in the
+// word list, so the payload of a 12 word mnemonic has 48 digits. The payload
+// must be encoded in the numeric mode of a QR code.
+func SeedQRStandard(mnemonic string) (string, error) {
+	indices, err := seedQRIndices(mnemonic)
+	if err != nil {
+		return "", err
+	}
+
+	var digits strings.Builder
+	for _, index := range indices {
+		digits.WriteString(fmt.Sprintf("%04d", index))
+	}
+
+	return digits.String(), nil
+}
+
+// SeedQRCompact returns the payload of a compact SeedQR for the given
+// mnemonic, which is the raw entropy without the checksum. The payload must be
+// encoded in the byte mode of a QR code.
+func SeedQRCompact(mnemonic string) ([]byte, error) {
+	if _, err := seedQRIndices(mnemonic); err != nil {
+		return nil, err
+	}
+
+	return EntropyFromMnemonic(mnemonic)
+}
+
+// seedQRIndices validates the given mnemonic, makes sure it can be encoded
+// as a SeedQR and returns the word list indices of its words.
+func seedQRIndices(mnemonic string) ([]int, error) {
+	indices, err := IndicesFromMnemonic(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(indices) != 12 && len(indices) != 24 {
+		return nil, fmt.Errorf("%w, got %d words", ErrSeedQRWordCount,
+			len(indices))
+	}
+
+	return indices, nil
+}
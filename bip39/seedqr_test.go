@@ -0,0 +1,44 @@
+package bip39
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// seedQRTestVectors contain the standard SeedQR test vectors of the SeedQR
+// specification. The compact payload is the entropy of the mnemonic.
+var seedQRTestVectors = []struct {
+	mnemonic string
+	standard string
+	compact  string
+}{{
+	mnemonic: "attack pizza motion avocado network gather crop fresh " +
+		"patrol unusual wild holiday candy pony ranch winter theme " +
+		"error hybrid van cereal salon goddess expire",
+	standard: "0115132511540127119007710415074212891906200808700266134" +
+		"31420201617920614089619290300152408010643",
+	compact: "0e74b64107f94cc0ccfae6a13dcbec3662154fec67e0e00999c0789" +
+		"2597d190a",
+}}
+
+func TestSeedQR(t *testing.T) {
+	for _, v := range seedQRTestVectors {
+		standard, err := SeedQRStandard(v.mnemonic)
+		require.NoError(t, err)
+		require.Equal(t, v.standard, standard)
+
+		compact, err := SeedQRCompact(v.mnemonic)
+		require.NoError(t, err)
+		require.Equal(t, v.compact, hex.EncodeToString(compact))
+	}
+
+	// Only 12 and 24 word mnemonics can be encoded.
+	mnemonic, err := EntropyToMnemonic(make([]byte, 28))
+	require.NoError(t, err)
+	_, err = SeedQRStandard(mnemonic)
+	require.ErrorIs(t, err, ErrSeedQRWordCount)
+	_, err = SeedQRCompact(mnemonic)
+	require.ErrorIs(t, err, ErrSeedQRWordCount)
+}
@@ -0,0 +1,126 @@
+package bip39
+
+import "strings"
+
+// SuggestWords returns up to max words of the active word list that start
+// with the given prefix, in word list order.
+func SuggestWords(prefix string, max int) []string {
+	if max <= 0 {
+		return nil
+	}
+
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+
+	var suggestions []string
+	for _, word := range wordList {
+		if !strings.HasPrefix(word, prefix) {
+			continue
+		}
+
+		suggestions = append(suggestions, word)
+		if len(suggestions) == max {
+			break
+		}
+	}
+
+	return suggestions
+}
+
+// ClosestWords returns up to max words of the active word list that have the
+// smallest Levenshtein distance to the given word, closest first. Words with
+// the same distance are returned in word list order.
+func ClosestWords(word string, max int) []string {
+	if max <= 0 {
+		return nil
+	}
+
+	word = strings.ToLower(strings.TrimSpace(word))
+
+	type candidate struct {
+		word     string
+		distance int
+	}
+
+	var (
+		target = []rune(word)
+
+		// The two rows of the distance matrix are re-used for all
+		// words to avoid allocations.
+		prev = make([]int, len(target)+1)
+		curr = make([]int, len(target)+1)
+
+		closest = make([]candidate, 0, max)
+	)
+	for _, listWord := range wordList {
+		distance := levenshtein(target, listWord, prev, curr)
+
+		// Skip the word if our list is full and the word isn't closer
+		// than the last one in the list.
+		if len(closest) == max &&
+			distance >= closest[len(closest)-1].distance {
+
+			continue
+		}
+
+		// Find the insert position that keeps the list sorted by
+		// distance and, for equal distances, by word list order.
+		pos := len(closest)
+		for pos > 0 && closest[pos-1].distance > distance {
+			pos--
+		}
+
+		if len(closest) < max {
+			closest = append(closest, candidate{})
+		}
+		copy(closest[pos+1:], closest[pos:])
+		closest[pos] = candidate{word: listWord, distance: distance}
+	}
+
+	words := make([]string, len(closest))
+	for idx, c := range closest {
+		words[idx] = c.word
+	}
+
+	return words
+}
+
+// levenshtein returns the Levenshtein distance between the target runes and
+// the given word. The prev and curr slices must be of length len(target)+1 and
+// are used as scratch space.
+func levenshtein(target []rune, word string, prev, curr []int) int {
+	for i := range prev {
+		prev[i] = i
+	}
+
+	row := 1
+	for _, r := range word {
+		curr[0] = row
+		for i := 1; i <= len(target); i++ {
+			cost := 1
+			if target[i-1] == r {
+				cost = 0
+			}
+
+			curr[i] = min3(prev[i]+1, curr[i-1]+1, prev[i-1]+cost)
+		}
+
+		prev, curr = curr, prev
+		row++
+	}
+
+	return prev[len(target)]
+}
+
+// min3 returns the smallest of the three given values.
+func min3(a, b, c int) int {
+	switch {
+	case a <= b && a <= c:
+		return a
+
+	case b <= c:
+		return b
+
+	default:
+		return c
+	}
+}
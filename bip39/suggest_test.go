@@ -0,0 +1,60 @@
+package bip39
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestWords(t *testing.T) {
+	require.Equal(
+		t, []string{"abandon", "ability", "able"},
+		SuggestWords("ab", 3),
+	)
+	require.Equal(
+		t, []string{"zebra", "zero", "zone", "zoo"},
+		SuggestWords(" Z ", 10),
+	)
+	require.Equal(t, []string{"about"}, SuggestWords("abou", 10))
+	require.Empty(t, SuggestWords("xyz", 10))
+	require.Empty(t, SuggestWords("ab", 0))
+}
+
+func TestClosestWords(t *testing.T) {
+	// An exact match is always the closest word.
+	require.Equal(t, []string{"walnut"}, ClosestWords("walnut", 1))
+
+	// A single typo should result in the intended word.
+	require.Equal(t, []string{"bargain"}, ClosestWords("bargian", 1))
+	require.Equal(t, []string{"diesel"}, ClosestWords("diesl", 1))
+
+	// Words with the same distance are returned in word list order.
+	require.Equal(
+		t, []string{"walk", "wall", "way", "all", "away"},
+		ClosestWords("wal", 5),
+	)
+	require.Empty(t, ClosestWords("wal", 0))
+}
+
+func TestLevenshtein(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"zoo", "zoo", 0},
+	}
+	for _, tc := range testCases {
+		target := []rune(tc.a)
+		prev := make([]int, len(target)+1)
+		curr := make([]int, len(target)+1)
+		require.Equal(
+			t, tc.distance, levenshtein(target, tc.b, prev, curr),
+			"%s -> %s", tc.a, tc.b,
+		)
+	}
+}
@@ -0,0 +1,59 @@
+package bip39
+
+import (
+	"runtime"
+	"sync"
+)
+
+// MnemonicResult is the result of validating a single mnemonic.
+type MnemonicResult struct {
+	// Mnemonic is the mnemonic as it was read from the input channel.
+	Mnemonic string
+
+	// Entropy is the entropy encoded by the mnemonic, if it is valid.
+	Entropy []byte
+
+	// Err is the reason the mnemonic is invalid, as returned by
+	// EntropyFromMnemonic, or nil if it is valid.
+	Err error
+}
+
+// ValidateMnemonics validates all mnemonics read from the input channel with a
+// pool of the given number of workers (one per CPU if zero or negative) and
+// sends one result for each of them to the returned channel. The results are
+// NOT guaranteed to be in the same order as the input. The returned channel is
+// closed after the input channel was closed and all mnemonics read from it are
+// validated, so the caller must keep reading results until then. The active
+// word list and normalization policy must not be changed while the validation
+// is running.
+func ValidateMnemonics(in <-chan string, workers int) <-chan MnemonicResult {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make(chan MnemonicResult, workers)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for mnemonic := range in {
+				entropy, err := EntropyFromMnemonic(mnemonic)
+				results <- MnemonicResult{
+					Mnemonic: mnemonic,
+					Entropy:  entropy,
+					Err:      err,
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
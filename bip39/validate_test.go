@@ -0,0 +1,78 @@
+package bip39
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMnemonics(t *testing.T) {
+	invalid := []string{
+		"abandon about",
+		"abandon abandon abandon abandon abandon abandon abandon " +
+			"abandon abandon abandon abandon abandon",
+	}
+
+	for _, workers := range []int{0, 1, 4} {
+		in := make(chan string)
+		go func() {
+			defer close(in)
+
+			for _, v := range testVectors {
+				in <- v.mnemonic
+			}
+			for _, mnemonic := range invalid {
+				in <- mnemonic
+			}
+		}()
+
+		// The results can be in any order, so we index them by their
+		// mnemonic.
+		results := make(map[string]MnemonicResult)
+		for result := range ValidateMnemonics(in, workers) {
+			results[result.Mnemonic] = result
+		}
+		require.Len(t, results, len(testVectors)+len(invalid))
+
+		for _, v := range testVectors {
+			result := results[v.mnemonic]
+			require.NoError(t, result.Err)
+			require.Equal(
+				t, v.entropy, hex.EncodeToString(result.Entropy),
+			)
+		}
+
+		require.ErrorIs(
+			t, results[invalid[0]].Err, ErrInvalidWordCount,
+		)
+		require.ErrorIs(
+			t, results[invalid[1]].Err, ErrChecksumIncorrect,
+		)
+		for _, mnemonic := range invalid {
+			require.Nil(t, results[mnemonic].Entropy)
+		}
+	}
+}
+
+func BenchmarkValidateMnemonics(b *testing.B) {
+	mnemonic := testVectors[5].mnemonic
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	in := make(chan string)
+	go func() {
+		defer close(in)
+
+		for i := 0; i < b.N; i++ {
+			in <- mnemonic
+		}
+	}()
+
+	for result := range ValidateMnemonics(in, 0) {
+		if result.Err != nil {
+			b.Fatal(result.Err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package bip39
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrInvalidShareCount is returned when trying to split entropy into
+	// less than two shares.
+	ErrInvalidShareCount = errors.New("number of shares must be at " +
+		"least 2")
+)
+
+// SplitEntropy splits the given BIP39 entropy into the given number of XOR
+// shares. All shares except for the last one are random, the last one is the
+// XOR of the entropy and all random shares. Each share has the same length as
+// the entropy and can therefore be encoded as a mnemonic itself. All shares are
+// required to recover the entropy with CombineEntropy, any subset of them
+// reveals nothing about the entropy.
+func SplitEntropy(entropy []byte, shares int) ([][]byte, error) {
+	if err := validateEntropyBitSize(len(entropy) * 8); err != nil {
+		return nil, err
+	}
+
+	if shares < 2 {
+		return nil, fmt.Errorf("%w, got %d", ErrInvalidShareCount,
+			shares)
+	}
+
+	result := make([][]byte, shares)
+	lastShare := make([]byte, len(entropy))
+	copy(lastShare, entropy)
+	for idx := 0; idx < shares-1; idx++ {
+		result[idx] = make([]byte, len(entropy))
+		_, err := io.ReadFull(randReader, result[idx])
+		if err != nil {
+			return nil, fmt.Errorf("error creating random "+
+				"share: %w", err)
+		}
+
+		xorBytes(lastShare, result[idx])
+	}
+	result[shares-1] = lastShare
+
+	return result, nil
+}
+
+// CombineEntropy combines the XOR shares created by SplitEntropy into the
+// original entropy. All shares must be given, in any order. The result is only
+// the original entropy if no share is missing, nil is returned if no shares
+// are given or they don't all have the same length.
+func CombineEntropy(shares [][]byte) []byte {
+	if len(shares) == 0 {
+		return nil
+	}
+
+	entropy := make([]byte, len(shares[0]))
+	for _, share := range shares {
+		if len(share) != len(entropy) {
+			return nil
+		}
+
+		xorBytes(entropy, share)
+	}
+
+	return entropy
+}
+
+// xorBytes XORs the source into the target, both must have the same length.
+func xorBytes(target, source []byte) {
+	for idx := range target {
+		target[idx] ^= source[idx]
+	}
+}
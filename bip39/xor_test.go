@@ -0,0 +1,69 @@
+package bip39
+
+import (
+	"encoding/hex"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCombineEntropy(t *testing.T) {
+	// A seeded pseudo random reader makes the test deterministic.
+	restore := SetRandReader(rand.New(rand.NewSource(39)))
+	defer restore()
+
+	for _, v := range testVectors {
+		entropy, err := hex.DecodeString(v.entropy)
+		require.NoError(t, err)
+
+		for _, numShares := range []int{2, 3, 5} {
+			shares, err := SplitEntropy(entropy, numShares)
+			require.NoError(t, err)
+			require.Len(t, shares, numShares)
+
+			for _, share := range shares {
+				require.Len(t, share, len(entropy))
+
+				// Each share can be written down as a
+				// mnemonic.
+				_, err := EntropyToMnemonic(share)
+				require.NoError(t, err)
+			}
+
+			// All shares in any order result in the entropy.
+			require.Equal(t, entropy, CombineEntropy(shares))
+			reversed := make([][]byte, numShares)
+			for idx, share := range shares {
+				reversed[numShares-1-idx] = share
+			}
+			require.Equal(t, entropy, CombineEntropy(reversed))
+
+			// Any subset of the shares doesn't.
+			for subset := 1; subset < 1<<numShares-1; subset++ {
+				var partial [][]byte
+				for idx, share := range shares {
+					if subset&(1<<idx) != 0 {
+						partial = append(partial, share)
+					}
+				}
+				require.NotEqual(
+					t, entropy, CombineEntropy(partial),
+				)
+			}
+		}
+	}
+}
+
+func TestSplitCombineEntropyErrors(t *testing.T) {
+	entropy := make([]byte, 16)
+
+	_, err := SplitEntropy(entropy, 1)
+	require.ErrorIs(t, err, ErrInvalidShareCount)
+
+	_, err = SplitEntropy(make([]byte, 15), 2)
+	require.ErrorIs(t, err, ErrEntropyLengthInvalid)
+
+	require.Nil(t, CombineEntropy(nil))
+	require.Nil(t, CombineEntropy([][]byte{entropy, make([]byte, 32)}))
+}
@@ -25,14 +25,68 @@ const (
 func ReadMnemonicFromTerminal(params *chaincfg.Params) (*hdkeychain.ExtendedKey,
 	error) {
 
-	var err error
-	reader := bufio.NewReader(os.Stdin)
-
 	// To automate things with chantools, we also offer reading the seed
 	// from environment variables.
-	mnemonicStr := strings.TrimSpace(os.Getenv(BIP39MnemonicEnvName))
+	return ReadMnemonicWithSecrets(
+		params, os.Getenv(BIP39MnemonicEnvName),
+		os.Getenv(BIP39PassphraseEnvName),
+	)
+}
+
+// ReadMnemonicWithSecrets derives the root key of the BIP39 seed with the given
+// mnemonic and passphrase. If the mnemonic or the passphrase is empty, it is
+// read from the terminal. A passphrase of a single dash (-) means no
+// passphrase is used. The seed is derived from the mnemonic as is, so wallets
+// with their own word lists or checksums can be recovered too. The checksum is
+// only verified if the passphrase is read from the terminal.
+func ReadMnemonicWithSecrets(params *chaincfg.Params, mnemonicStr,
+	passphrase string) (*hdkeychain.ExtendedKey, error) {
+
+	return readMnemonic(params, mnemonicStr, passphrase, masterKeyFromSeed)
+}
+
+// ReadValidMnemonicWithSecrets works like ReadMnemonicWithSecrets but always
+// makes sure the mnemonic consists of words of one of the official BIP39 word
+// lists and has a valid checksum before deriving the root key.
+func ReadValidMnemonicWithSecrets(params *chaincfg.Params, mnemonicStr,
+	passphrase string) (*hdkeychain.ExtendedKey, error) {
+
+	bip39.SetLanguageDetection(true)
+	defer bip39.SetLanguageDetection(false)
 
-	if mnemonicStr == "" {
+	return readMnemonic(
+		params, mnemonicStr, passphrase, bip39.MasterKeyFromMnemonic,
+	)
+}
+
+// masterKeyFromSeed derives the BIP32 HD master key from the BIP39 seed of the
+// given mnemonic and passphrase without validating the mnemonic.
+func masterKeyFromSeed(mnemonic, passphrase string,
+	params *chaincfg.Params) (*hdkeychain.ExtendedKey, error) {
+
+	seed := bip39.NewSeed(mnemonic, passphrase)
+	rootKey, err := hdkeychain.NewMaster(seed, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive master extended "+
+			"key: %w", err)
+	}
+	return rootKey, nil
+}
+
+// masterKeyFunc is a function that derives the BIP32 HD master key from a
+// BIP39 mnemonic and passphrase.
+type masterKeyFunc func(mnemonic, passphrase string,
+	params *chaincfg.Params) (*hdkeychain.ExtendedKey, error)
+
+// readMnemonic reads the mnemonic and passphrase if they are empty and derives
+// the root key from them with the given function.
+func readMnemonic(params *chaincfg.Params, mnemonicStr, passphrase string,
+	deriveKey masterKeyFunc) (*hdkeychain.ExtendedKey, error) {
+
+	var err error
+	reader := bufio.NewReader(os.Stdin)
+
+	if strings.TrimSpace(mnemonicStr) == "" {
 		// If there's no value in the environment, we'll now prompt the
 		// user to enter in their 12 to 24 word mnemonic.
 		fmt.Printf("Input your 12 to 24 word mnemonic separated by " +
@@ -44,10 +98,15 @@ func ReadMnemonicFromTerminal(params *chaincfg.Params) (*hdkeychain.ExtendedKey,
 		fmt.Println()
 	}
 
-	// We'll trim off extra spaces, and ensure the mnemonic is all
-	// lower case.
-	mnemonicStr = strings.TrimSpace(mnemonicStr)
-	mnemonicStr = strings.ToLower(mnemonicStr)
+	// The line break isn't part of the mnemonic. Extra spaces and upper
+	// case letters are removed or rejected depending on the normalization
+	// policy of the bip39 package.
+	mnemonicStr, err = bip39.NormalizeMnemonic(
+		strings.TrimRight(mnemonicStr, "\r\n"),
+	)
+	if err != nil {
+		return nil, err
+	}
 
 	mnemonicWords := strings.Split(mnemonicStr, " ")
 	if len(mnemonicWords) < 12 || len(mnemonicWords) > 24 {
@@ -57,8 +116,7 @@ func ReadMnemonicFromTerminal(params *chaincfg.Params) (*hdkeychain.ExtendedKey,
 
 	// Additionally, the user may have a passphrase, that will also need to
 	// be provided so the daemon can properly decipher the cipher seed.
-	// Try the environment variable first.
-	passphrase := strings.TrimSpace(os.Getenv(BIP39PassphraseEnvName))
+	passphrase = strings.TrimSpace(passphrase)
 
 	// Because we cannot differentiate between an empty and a non-existent
 	// environment variable, we need a special character that indicates that
@@ -66,7 +124,6 @@ func ReadMnemonicFromTerminal(params *chaincfg.Params) (*hdkeychain.ExtendedKey,
 	// that would be too short for a passphrase anyway.
 	var (
 		passphraseBytes []byte
-		seed            []byte
 		choice          string
 	)
 	switch {
@@ -113,40 +170,24 @@ func ReadMnemonicFromTerminal(params *chaincfg.Params) (*hdkeychain.ExtendedKey,
 		passphraseBytes = []byte(passphrase)
 	}
 
+	var bip39Passphrase string
 	switch strings.TrimSpace(choice) {
 	case "", "0":
-		seed = pbkdf2.Key(
-			[]byte(mnemonicStr), append(
-				[]byte("mnemonic"), passphraseBytes...,
-			), 2048, 64, sha512.New,
-		)
+		bip39Passphrase = string(passphraseBytes)
 
 	case "1":
-		p := []byte(hex.EncodeToString(passphraseBytes))
-		seed = pbkdf2.Key(
-			[]byte(mnemonicStr), append([]byte("mnemonic"), p...),
-			2048, 64, sha512.New,
-		)
+		bip39Passphrase = hex.EncodeToString(passphraseBytes)
 
 	case "2":
-		p := hex.EncodeToString(pbkdf2.Key(
+		bip39Passphrase = hex.EncodeToString(pbkdf2.Key(
 			passphraseBytes, []byte("Digital Bitbox"), 20480, 64,
 			sha512.New,
 		))
-		seed = pbkdf2.Key(
-			[]byte(mnemonicStr), append([]byte("mnemonic"), p...),
-			2048, 64, sha512.New,
-		)
 
 	default:
 		return nil, fmt.Errorf("invalid mode selected: %v",
 			choice)
 	}
 
-	rootKey, err := hdkeychain.NewMaster(seed, params)
-	if err != nil {
-		return nil, fmt.Errorf("failed to derive master extended "+
-			"key: %w", err)
-	}
-	return rootKey, nil
+	return deriveKey(mnemonicStr, bip39Passphrase, params)
 }
@@ -9,7 +9,6 @@ import (
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/chaincfg"
-	"github.com/btcsuite/btcd/wire"
 	"github.com/guggero/chantools/lnd"
 )
 
@@ -110,6 +109,15 @@ func SeedBirthdayToBlock(params *chaincfg.Params,
 		genesisTimestamp =
 			chaincfg.TestNet3Params.GenesisBlock.Header.Timestamp
 
+	case "testnet4":
+		genesisTimestamp =
+			TestNet4Params.GenesisBlock.Header.Timestamp
+
+	// Custom signets use the same genesis block as the default signet.
+	case "signet":
+		genesisTimestamp =
+			chaincfg.SigNetParams.GenesisBlock.Header.Timestamp
+
 	case "regtest", "simnet":
 		return 0
 
@@ -140,10 +148,7 @@ func (c *Cli) Format(hdKey *hdkeychain.ExtendedKey, params *chaincfg.Params,
 	if err != nil {
 		return "", fmt.Errorf("could not encode WIF: %w", err)
 	}
-	flags := ""
-	if params.Net == wire.TestNet || params.Net == wire.TestNet3 {
-		flags = " -testnet"
-	}
+	flags := bitcoinCliNetworkFlag(params)
 	return fmt.Sprintf("bitcoin-cli%s importprivkey %s \"%s/%d/%d/\" false",
 		flags, wif.String(), path, branch, index), nil
 }
@@ -166,10 +171,7 @@ func (c *CliWatchOnly) Format(hdKey *hdkeychain.ExtendedKey,
 	if err != nil {
 		return "", fmt.Errorf("could not derive private key: %w", err)
 	}
-	flags := ""
-	if params.Net == wire.TestNet || params.Net == wire.TestNet3 {
-		flags = " -testnet"
-	}
+	flags := bitcoinCliNetworkFlag(params)
 	return fmt.Sprintf("bitcoin-cli%s importpubkey %x \"%s/%d/%d/\" false",
 		flags, pubKey.SerializeCompressed(), path, branch, index), nil
 }
@@ -0,0 +1,409 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// BitcoindRPC is a chain backend that uses the JSON-RPC interface of a
+// bitcoind full node. Looking up arbitrary transactions requires the node to
+// run with -txindex. Addresses are looked up with scantxoutset, so only their
+// unspent outputs can be found.
+type BitcoindRPC struct {
+	client *rpcclient.Client
+	params *chaincfg.Params
+}
+
+// NewBitcoindRPC creates a new bitcoind chain backend that connects to the
+// RPC interface at the given host with the given credentials.
+func NewBitcoindRPC(host, user, pass string,
+	params *chaincfg.Params) (*BitcoindRPC, error) {
+
+	client, err := rpcclient.New(&rpcclient.ConnConfig{
+		Host:         host,
+		User:         user,
+		Pass:         pass,
+		HTTPPostMode: true,
+		DisableTLS:   true,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating bitcoind RPC client: %w",
+			err)
+	}
+
+	return &BitcoindRPC{
+		client: client,
+		params: params,
+	}, nil
+}
+
+func (b *BitcoindRPC) Transaction(txid string) (*TX, error) {
+	rawTx, err := b.rawTransactionVerbose(txid)
+	if err != nil {
+		return nil, err
+	}
+	msgTx, err := decodeTx(rawTx.Hex)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := b.status(rawTx)
+	if err != nil {
+		return nil, err
+	}
+	tx := &TX{
+		TXID:     rawTx.Txid,
+		Locktime: msgTx.LockTime,
+		Status:   status,
+	}
+
+	for _, txIn := range msgTx.TxIn {
+		vin := &Vin{
+			Tixid:    txIn.PreviousOutPoint.Hash.String(),
+			Vout:     int(txIn.PreviousOutPoint.Index),
+			Sequence: txIn.Sequence,
+		}
+
+		// A coinbase input doesn't have a previous output.
+		if !isCoinBase(msgTx) {
+			prevTx, err := b.msgTx(vin.Tixid)
+			if err != nil {
+				return nil, fmt.Errorf("error fetching "+
+					"previous TX %s: %w", vin.Tixid, err)
+			}
+			prevIndex := txIn.PreviousOutPoint.Index
+			if int(prevIndex) >= len(prevTx.TxOut) {
+				return nil, fmt.Errorf("invalid output index "+
+					"%d of TX %s", prevIndex, vin.Tixid)
+			}
+			vin.Prevout = b.vout(prevTx.TxOut[prevIndex])
+		}
+
+		tx.Vin = append(tx.Vin, vin)
+	}
+
+	hash := msgTx.TxHash()
+	for idx, txOut := range msgTx.TxOut {
+		vout := b.vout(txOut)
+
+		// We only know whether an output is spent, not by which
+		// transaction, as bitcoind doesn't index spends.
+		utxo, err := b.client.GetTxOut(&hash, uint32(idx), true)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching TX out %v:%d: "+
+				"%w", hash, idx, err)
+		}
+		vout.Outspend = &Outspend{
+			Spent: utxo == nil,
+		}
+
+		tx.Vout = append(tx.Vout, vout)
+	}
+
+	return tx, nil
+}
+
+func (b *BitcoindRPC) RawTransaction(txid string) (string, error) {
+	rawTx, err := b.rawTransactionVerbose(txid)
+	if err != nil {
+		return "", err
+	}
+
+	return rawTx.Hex, nil
+}
+
+func (b *BitcoindRPC) TxStatus(txid string) (*Status, error) {
+	rawTx, err := b.rawTransactionVerbose(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.status(rawTx)
+}
+
+func (b *BitcoindRPC) BlockHeight() (uint32, error) {
+	height, err := b.client.GetBlockCount()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(height), nil
+}
+
+// FeeEstimate returns the fee rate in sat/vByte that bitcoind estimates for a
+// transaction to confirm within the given number of blocks.
+func (b *BitcoindRPC) FeeEstimate(confTarget uint32) (float64, error) {
+	mode := btcjson.EstimateModeConservative
+	result, err := b.client.EstimateSmartFee(int64(confTarget), &mode)
+	if err != nil {
+		return 0, err
+	}
+	if result.FeeRate == nil {
+		return 0, fmt.Errorf("no fee estimate available for a "+
+			"confirmation target of %d blocks: %s", confTarget,
+			strings.Join(result.Errors, ", "))
+	}
+
+	// The fee rate is returned in BTC/kvByte.
+	return *result.FeeRate * btcutil.SatoshiPerBitcoin / 1000, nil
+}
+
+// Outpoint returns the transaction of the first unspent output of the given
+// address. Spent outputs can't be found with bitcoind.
+func (b *BitcoindRPC) Outpoint(addr string) (*TX, int, error) {
+	unspents, err := b.scanTxOutSet(addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(unspents) == 0 {
+		return nil, 0, fmt.Errorf("no tx found")
+	}
+
+	tx, err := b.Transaction(unspents[0].Txid)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return tx, int(unspents[0].Vout), nil
+}
+
+func (b *BitcoindRPC) Unspent(addr string) ([]*Vout, error) {
+	unspents, err := b.scanTxOutSet(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var outputs []*Vout
+	for _, unspent := range unspents {
+		amount, err := btcutil.NewAmount(unspent.Amount)
+		if err != nil {
+			return nil, err
+		}
+
+		// The Esplora backend uses the outspend to point to the
+		// output itself, we do the same.
+		outputs = append(outputs, &Vout{
+			ScriptPubkey:     unspent.ScriptPubKey,
+			ScriptPubkeyAddr: addr,
+			Value:            uint64(amount),
+			Outspend: &Outspend{
+				Txid: unspent.Txid,
+				Vin:  int(unspent.Vout),
+			},
+		})
+	}
+
+	return outputs, nil
+}
+
+func (b *BitcoindRPC) Address(outpoint string) (string, error) {
+	parts := strings.Split(outpoint, ":")
+
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid outpoint: %v", outpoint)
+	}
+
+	tx, err := b.msgTx(parts[0])
+	if err != nil {
+		return "", err
+	}
+
+	vout, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", err
+	}
+
+	if len(tx.TxOut) <= vout {
+		return "", fmt.Errorf("invalid output index: %d", vout)
+	}
+
+	return b.vout(tx.TxOut[vout]).ScriptPubkeyAddr, nil
+}
+
+func (b *BitcoindRPC) AddressUsed(addr string) (bool, error) {
+	// Without an address index we can only look at the UTXO set, which
+	// doesn't contain addresses that were used and then spent from.
+	unspents, err := b.scanTxOutSet(addr)
+	if err != nil {
+		return false, err
+	}
+
+	return len(unspents) > 0, nil
+}
+
+func (b *BitcoindRPC) PublishTx(rawTxHex string) (string, error) {
+	tx, err := decodeTx(rawTxHex)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := b.client.SendRawTransaction(tx, false)
+	if err != nil {
+		return "", err
+	}
+
+	return hash.String(), nil
+}
+
+func (b *BitcoindRPC) rawTransactionVerbose(
+	txid string) (*btcjson.TxRawResult, error) {
+
+	hash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing TXID: %w", err)
+	}
+
+	rawTx, err := b.client.GetRawTransactionVerbose(hash)
+	if err != nil {
+		var rpcErr *btcjson.RPCError
+		if errors.As(err, &rpcErr) &&
+			rpcErr.Code == btcjson.ErrRPCNoTxInfo {
+
+			return nil, ErrTxNotFound
+		}
+		return nil, err
+	}
+
+	return rawTx, nil
+}
+
+func (b *BitcoindRPC) msgTx(txid string) (*wire.MsgTx, error) {
+	rawTx, err := b.RawTransaction(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeTx(rawTx)
+}
+
+func (b *BitcoindRPC) status(rawTx *btcjson.TxRawResult) (*Status, error) {
+	if rawTx.Confirmations == 0 || rawTx.BlockHash == "" {
+		return &Status{}, nil
+	}
+
+	blockHash, err := chainhash.NewHashFromStr(rawTx.BlockHash)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing block hash: %w", err)
+	}
+	header, err := b.client.GetBlockHeaderVerbose(blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching block header %v: %w",
+			blockHash, err)
+	}
+
+	return &Status{
+		Confirmed:   true,
+		BlockHeight: int(header.Height),
+		BlockHash:   rawTx.BlockHash,
+	}, nil
+}
+
+// vout converts the given transaction output into the Esplora format.
+func (b *BitcoindRPC) vout(txOut *wire.TxOut) *Vout {
+	vout := &Vout{
+		ScriptPubkey: hex.EncodeToString(txOut.PkScript),
+		Value:        uint64(txOut.Value),
+	}
+
+	class, addrs, _, err := txscript.ExtractPkScriptAddrs(
+		txOut.PkScript, b.params,
+	)
+	if err == nil {
+		vout.ScriptPubkeyType = class.String()
+		if len(addrs) == 1 {
+			vout.ScriptPubkeyAddr = addrs[0].EncodeAddress()
+		}
+	}
+
+	return vout
+}
+
+// scanTxOutUnspent is a single unspent output found by scantxoutset.
+type scanTxOutUnspent struct {
+	Txid         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	ScriptPubKey string  `json:"scriptPubKey"`
+	Amount       float64 `json:"amount"`
+	Height       uint32  `json:"height"`
+}
+
+// scanTxOutSet uses the scantxoutset RPC to find all unspent outputs of the
+// given address. This doesn't require an address index but takes a while as
+// the whole UTXO set is scanned.
+func (b *BitcoindRPC) scanTxOutSet(addr string) ([]*scanTxOutUnspent,
+	error) {
+
+	action, err := json.Marshal("start")
+	if err != nil {
+		return nil, err
+	}
+	descriptors, err := json.Marshal([]string{
+		fmt.Sprintf("addr(%s)", addr),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.client.RawRequest(
+		"scantxoutset", []json.RawMessage{action, descriptors},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error scanning UTXO set for %s: %w",
+			addr, err)
+	}
+
+	var result struct {
+		Success  bool                `json:"success"`
+		Unspents []*scanTxOutUnspent `json:"unspents"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return nil, fmt.Errorf("error decoding scantxoutset result: "+
+			"%w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("scantxoutset for %s was not "+
+			"successful", addr)
+	}
+
+	return result.Unspents, nil
+}
+
+func decodeTx(rawTxHex string) (*wire.MsgTx, error) {
+	txBytes, err := hex.DecodeString(rawTxHex)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding TX hex: %w", err)
+	}
+
+	tx := &wire.MsgTx{}
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, fmt.Errorf("error parsing TX: %w", err)
+	}
+
+	return tx, nil
+}
+
+// isCoinBase returns true if the given transaction is a coinbase
+// transaction, which has a single input with an all zero previous outpoint.
+func isCoinBase(tx *wire.MsgTx) bool {
+	if len(tx.TxIn) != 1 {
+		return false
+	}
+
+	prevOut := tx.TxIn[0].PreviousOutPoint
+	return prevOut.Index == math.MaxUint32 &&
+		prevOut.Hash == chainhash.Hash{}
+}
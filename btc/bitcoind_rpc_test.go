@@ -0,0 +1,162 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+type rpcRequest struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	ID     interface{}       `json:"id"`
+}
+
+func TestBitcoindRPC(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		bytes.Repeat([]byte{0x01}, 20), params,
+	)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	prevTx := wire.NewMsgTx(2)
+	prevTx.TxIn = []*wire.TxIn{{
+		PreviousOutPoint: wire.OutPoint{Index: 7},
+	}}
+	prevTx.TxOut = []*wire.TxOut{{Value: 200_000, PkScript: pkScript}}
+
+	tx := wire.NewMsgTx(2)
+	tx.TxIn = []*wire.TxIn{{
+		PreviousOutPoint: wire.OutPoint{Hash: prevTx.TxHash()},
+	}}
+	tx.TxOut = []*wire.TxOut{
+		{Value: 100_000, PkScript: pkScript},
+		{Value: 90_000, PkScript: pkScript},
+	}
+
+	txHex := func(tx *wire.MsgTx) string {
+		var buf bytes.Buffer
+		require.NoError(t, tx.Serialize(&buf))
+		return hex.EncodeToString(buf.Bytes())
+	}
+	blockHash := strings.Repeat("00", 31) + "01"
+	scanResult := map[string]interface{}{
+		"success": true,
+		"unspents": []map[string]interface{}{{
+			"txid":         tx.TxHash().String(),
+			"vout":         0,
+			"scriptPubKey": hex.EncodeToString(pkScript),
+			"amount":       0.001,
+		}},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req rpcRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+			var result interface{}
+			switch req.Method {
+			case "getrawtransaction":
+				var txid string
+				require.NoError(
+					t, json.Unmarshal(req.Params[0], &txid),
+				)
+				raw := map[string]interface{}{
+					"txid": txid,
+				}
+				if txid == tx.TxHash().String() {
+					raw["hex"] = txHex(tx)
+					raw["blockhash"] = blockHash
+					raw["confirmations"] = 3
+				} else {
+					raw["hex"] = txHex(prevTx)
+				}
+				result = raw
+
+			case "gettxout":
+				// Only the first output is unspent.
+				if string(req.Params[1]) == "0" {
+					result = map[string]interface{}{
+						"value": 0.001,
+					}
+				}
+
+			case "getblockheader":
+				result = map[string]interface{}{
+					"hash":   blockHash,
+					"height": 1234,
+				}
+
+			case "estimatesmartfee":
+				result = map[string]interface{}{
+					"feerate": 0.00025,
+					"blocks":  6,
+				}
+
+			case "scantxoutset":
+				result = scanResult
+
+			default:
+				t.Fatalf("unexpected method %s", req.Method)
+			}
+
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"result": result,
+				"error":  nil,
+				"id":     req.ID,
+			})
+		},
+	))
+	defer server.Close()
+
+	backend, err := NewBitcoindRPC(
+		strings.TrimPrefix(server.URL, "http://"), "user", "pass",
+		params,
+	)
+	require.NoError(t, err)
+
+	result, err := backend.Transaction(tx.TxHash().String())
+	require.NoError(t, err)
+	require.True(t, result.Status.Confirmed)
+	require.Equal(t, 1234, result.Status.BlockHeight)
+	require.Len(t, result.Vin, 1)
+	require.EqualValues(t, 200_000, result.Vin[0].Prevout.Value)
+	require.Len(t, result.Vout, 2)
+	require.EqualValues(t, 100_000, result.Vout[0].Value)
+	require.Equal(t, addr.String(), result.Vout[0].ScriptPubkeyAddr)
+	require.False(t, result.Vout[0].Outspend.Spent)
+	require.True(t, result.Vout[1].Outspend.Spent)
+
+	// The previous transaction isn't confirmed yet.
+	status, err := backend.TxStatus(prevTx.TxHash().String())
+	require.NoError(t, err)
+	require.False(t, status.Confirmed)
+
+	// 0.00025 BTC/kvByte is 25 sat/vByte.
+	feeRate, err := backend.FeeEstimate(6)
+	require.NoError(t, err)
+	require.InDelta(t, 25, feeRate, 0.0001)
+
+	unspent, err := backend.Unspent(addr.String())
+	require.NoError(t, err)
+	require.Len(t, unspent, 1)
+	require.EqualValues(t, 100_000, unspent[0].Value)
+	require.Equal(t, tx.TxHash().String(), unspent[0].Outspend.Txid)
+
+	outpointAddr, err := backend.Address(tx.TxHash().String() + ":1")
+	require.NoError(t, err)
+	require.Equal(t, addr.String(), outpointAddr)
+}
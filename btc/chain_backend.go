@@ -0,0 +1,51 @@
+package btc
+
+const (
+	ChainBackendEsplora  = "esplora"
+	ChainBackendBitcoind = "bitcoind"
+)
+
+// ChainBackend is the interface all commands use to read data from and
+// publish transactions to the chain. The results are modeled after the
+// Esplora API, other backends convert their results to the same format.
+type ChainBackend interface {
+	// Transaction returns the transaction with the given ID, including
+	// the spend status of all its outputs.
+	Transaction(txid string) (*TX, error)
+
+	// RawTransaction returns the hex encoded raw transaction with the
+	// given ID.
+	RawTransaction(txid string) (string, error)
+
+	// TxStatus returns the confirmation status of the transaction with
+	// the given ID.
+	TxStatus(txid string) (*Status, error)
+
+	// BlockHeight returns the height of the best known block.
+	BlockHeight() (uint32, error)
+
+	// FeeEstimate returns the estimated fee rate in sat/vByte for a
+	// transaction to confirm within the given number of blocks.
+	FeeEstimate(confTarget uint32) (float64, error)
+
+	// Outpoint returns a transaction that pays to the given address and
+	// the index of the output.
+	Outpoint(addr string) (*TX, int, error)
+
+	// Unspent returns the unspent outputs of the given address.
+	Unspent(addr string) ([]*Vout, error)
+
+	// Address returns the address the given outpoint pays to.
+	Address(outpoint string) (string, error)
+
+	// AddressUsed returns true if the given address ever received any
+	// funds. The bitcoind backend can only see unspent outputs, so an
+	// address whose funds were all spent is reported as unused.
+	AddressUsed(addr string) (bool, error)
+
+	// PublishTx publishes the hex encoded raw transaction.
+	PublishTx(rawTxHex string) (string, error)
+}
+
+var _ ChainBackend = (*ExplorerAPI)(nil)
+var _ ChainBackend = (*BitcoindRPC)(nil)
@@ -0,0 +1,295 @@
+package btc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/guggero/chantools/lnd"
+)
+
+const (
+	FormatDescriptors = "descriptors"
+
+	// descriptorInputCharset and descriptorChecksumCharset are the
+	// character sets of the descriptor checksum as defined in BIP-0380.
+	descriptorInputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+		"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+		"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+var descriptorGenerator = [5]uint64{
+	0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd,
+}
+
+// importDescriptor is one entry of the JSON array that bitcoind's
+// importdescriptors RPC expects.
+type importDescriptor struct {
+	Desc      string    `json:"desc"`
+	Timestamp int64     `json:"timestamp"`
+	Active    bool      `json:"active"`
+	Internal  bool      `json:"internal"`
+	Range     [2]uint32 `json:"range"`
+}
+
+// DescriptorChecksum calculates the BIP-0380 checksum of the given output
+// descriptor.
+func DescriptorChecksum(desc string) (string, error) {
+	var (
+		symbols []uint64
+		groups  []uint64
+	)
+	for _, c := range desc {
+		value := strings.IndexRune(descriptorInputCharset, c)
+		if value < 0 {
+			return "", fmt.Errorf("invalid character '%c' in "+
+				"descriptor", c)
+		}
+
+		symbols = append(symbols, uint64(value&31))
+		groups = append(groups, uint64(value>>5))
+		if len(groups) == 3 {
+			symbols = append(
+				symbols, groups[0]*9+groups[1]*3+groups[2],
+			)
+			groups = nil
+		}
+	}
+	switch len(groups) {
+	case 1:
+		symbols = append(symbols, groups[0])
+	case 2:
+		symbols = append(symbols, groups[0]*3+groups[1])
+	}
+	symbols = append(symbols, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	checksum := descriptorPolymod(symbols) ^ 1
+	result := make([]byte, 8)
+	for i := range result {
+		result[i] = descriptorChecksumCharset[(checksum>>(5*(7-i)))&31]
+	}
+
+	return string(result), nil
+}
+
+func descriptorPolymod(symbols []uint64) uint64 {
+	chk := uint64(1)
+	for _, value := range symbols {
+		top := chk >> 35
+		chk = (chk&0x7ffffffff)<<5 ^ value
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= descriptorGenerator[i]
+			}
+		}
+	}
+	return chk
+}
+
+// AccountDescriptor returns the ranged output descriptor including checksum
+// for the external or internal branch of the account with the given
+// derivation path. The script type is chosen by the purpose of the path; lnd's
+// own key families (purpose 1017) are P2WKH.
+func AccountDescriptor(extendedKey *hdkeychain.ExtendedKey, strPath string,
+	path []uint32, branch uint32) (string, error) {
+
+	if len(path) == 0 {
+		return "", fmt.Errorf("path cannot be empty")
+	}
+
+	rootPubKey, err := extendedKey.ECPubKey()
+	if err != nil {
+		return "", fmt.Errorf("could not derive root pubkey: %w", err)
+	}
+	fingerprint := btcutil.Hash160(rootPubKey.SerializeCompressed())[:4]
+
+	accountKey, err := lnd.DeriveChildren(extendedKey, path)
+	if err != nil {
+		return "", fmt.Errorf("could not derive account key: %w", err)
+	}
+
+	// We use the "h" notation for hardened derivation so the descriptor
+	// can be wrapped in single quotes on the command line.
+	origin := strings.ReplaceAll(
+		strings.TrimPrefix(strPath, "m/"), "'", "h",
+	)
+	key := fmt.Sprintf("[%s/%s]%s/%d/*", hex.EncodeToString(fingerprint),
+		origin, accountKey.String(), branch)
+
+	var desc string
+	switch path[0] - hdkeychain.HardenedKeyStart {
+	case 44:
+		desc = fmt.Sprintf("pkh(%s)", key)
+
+	case 49:
+		desc = fmt.Sprintf("sh(wpkh(%s))", key)
+
+	case 84, 1017:
+		desc = fmt.Sprintf("wpkh(%s)", key)
+
+	case 86:
+		desc = fmt.Sprintf("tr(%s)", key)
+
+	default:
+		return "", fmt.Errorf("unsupported purpose in path %s", strPath)
+	}
+
+	checksum, err := DescriptorChecksum(desc)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s#%s", desc, checksum), nil
+}
+
+// DescriptorAddress returns the address at the given index of a ranged wpkh()
+// output descriptor like wpkh([d34db33f/84h/0h/0h]xpub.../0/*)#checksum. The
+// checksum is required to make sure the descriptor wasn't mistyped. Only
+// wpkh() descriptors are supported as they are the only ones all our sweeps can
+// pay to with accurate fee estimation.
+func DescriptorAddress(desc string, index uint32,
+	params *chaincfg.Params) (btcutil.Address, error) {
+
+	desc = strings.TrimSpace(desc)
+	hashIndex := strings.LastIndex(desc, "#")
+	if hashIndex < 0 {
+		return nil, fmt.Errorf("descriptor checksum is missing")
+	}
+
+	body, checksum := desc[:hashIndex], desc[hashIndex+1:]
+	expectedChecksum, err := DescriptorChecksum(body)
+	if err != nil {
+		return nil, err
+	}
+	if checksum != expectedChecksum {
+		return nil, fmt.Errorf("invalid descriptor checksum %s, "+
+			"expected %s", checksum, expectedChecksum)
+	}
+
+	if !strings.HasPrefix(body, "wpkh(") || !strings.HasSuffix(body, ")") {
+		return nil, fmt.Errorf("only wpkh() descriptors are supported")
+	}
+	key := strings.TrimSuffix(strings.TrimPrefix(body, "wpkh("), ")")
+
+	// The key origin is only informational, we derive from the extended
+	// key itself.
+	if strings.HasPrefix(key, "[") {
+		originEnd := strings.Index(key, "]")
+		if originEnd < 0 {
+			return nil, fmt.Errorf("invalid key origin in " +
+				"descriptor")
+		}
+		key = key[originEnd+1:]
+	}
+
+	parts := strings.Split(key, "/")
+	if len(parts) < 2 || parts[len(parts)-1] != "*" {
+		return nil, fmt.Errorf("descriptor must be ranged with a " +
+			"non-hardened /* at the end of the key")
+	}
+
+	extendedKey, err := ParseExtendedKey(parts[0], params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended key in descriptor: "+
+			"%w", err)
+	}
+
+	var path []uint32
+	if len(parts) > 2 {
+		strPath := strings.Join(parts[1:len(parts)-1], "/")
+		path, err = lnd.ParsePath(
+			"m/" + strings.ReplaceAll(strPath, "h", "'"),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path in descriptor: "+
+				"%w", err)
+		}
+	}
+	if index >= hdkeychain.HardenedKeyStart {
+		return nil, fmt.Errorf("index %d is out of range", index)
+	}
+
+	childKey, err := lnd.DeriveChildren(extendedKey, append(path, index))
+	if err != nil {
+		return nil, fmt.Errorf("could not derive key %d: %w", index,
+			err)
+	}
+	pubKey, err := childKey.ECPubKey()
+	if err != nil {
+		return nil, fmt.Errorf("could not derive pubkey %d: %w", index,
+			err)
+	}
+
+	return btcutil.NewAddressWitnessPubKeyHash(
+		btcutil.Hash160(pubKey.SerializeCompressed()), params,
+	)
+}
+
+// ExportDescriptors writes a bitcoin-cli importdescriptors command to the
+// writer that imports the external and internal branch of all given
+// derivation paths as ranged descriptors. The standard wallet accounts (BIP44,
+// BIP49, BIP84 and BIP86) are imported as active descriptors so bitcoind will
+// also use them to generate new addresses. If the birthday is unknown, the
+// whole chain is rescanned.
+func ExportDescriptors(extendedKey *hdkeychain.ExtendedKey, strPaths []string,
+	paths [][]uint32, params *chaincfg.Params, recoveryWindow uint32,
+	birthday time.Time, writer io.Writer) error {
+
+	// A timestamp of 0 means bitcoind will rescan the whole chain.
+	timestamp := int64(0)
+	if birthday.Unix() > 0 {
+		timestamp = birthday.Unix()
+	}
+
+	var descriptors []*importDescriptor
+	for idx, strPath := range strPaths {
+		path := paths[idx]
+		purpose := path[0] - hdkeychain.HardenedKeyStart
+		active := purpose == 44 || purpose == 49 || purpose == 84 ||
+			purpose == 86
+
+		for _, branch := range []uint32{0, 1} {
+			desc, err := AccountDescriptor(
+				extendedKey, strPath, path, branch,
+			)
+			if err != nil {
+				return err
+			}
+
+			descriptors = append(descriptors, &importDescriptor{
+				Desc:      desc,
+				Timestamp: timestamp,
+				Active:    active,
+				Internal:  branch == 1,
+				Range:     [2]uint32{0, recoveryWindow - 1},
+			})
+		}
+	}
+
+	descriptorBytes, err := json.MarshalIndent(descriptors, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding descriptors: %w", err)
+	}
+
+	flags := bitcoinCliNetworkFlag(params)
+
+	_, _ = fmt.Fprintf(
+		writer, "# Wallet dump created by chantools on %s\n",
+		time.Now().UTC(),
+	)
+	_, _ = fmt.Fprintf(writer, "# Paste the following command into a "+
+		"command line window. The descriptors\n# contain private "+
+		"keys, the target wallet must be a descriptor wallet with\n"+
+		"# private keys enabled.\n")
+	_, _ = fmt.Fprintf(writer, "bitcoin-cli%s importdescriptors '%s'\n",
+		flags, descriptorBytes)
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/guggero/chantools/bip39"
+	"github.com/guggero/chantools/lnd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescriptorChecksum(t *testing.T) {
+	// Test vectors from Bitcoin Core's doc/descriptors.md.
+	testCases := []struct {
+		desc     string
+		checksum string
+	}{{
+		desc: "pkh(02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef" +
+			"3ca7abac09b95c709ee5)",
+		checksum: "8fhd9pwu",
+	}, {
+		desc: "sh(wpkh(03fff97bd5755eeea420453a14355235d382f647" +
+			"2f8568a18b2f057a1460297556))",
+		checksum: "qkrrc7je",
+	}, {
+		desc: "wpkh([d34db33f/84h/0h/0h]xpub6DJ2dNUysrn5Vt36jH2K" +
+			"LBT2i1auw1tTSSomg8PhqNiUtx8QX2SvC9nrHu81fT41fvDUn" +
+			"hMjEzQgXnQjKEu3oaqMSzhSrHMxyyoEAmUHQbY/0/*)",
+		checksum: "cjjspncu",
+	}}
+
+	for _, tc := range testCases {
+		checksum, err := DescriptorChecksum(tc.desc)
+		require.NoError(t, err)
+		require.Equal(t, tc.checksum, checksum)
+	}
+
+	_, err := DescriptorChecksum("wpkh(ä)")
+	require.ErrorContains(t, err, "invalid character")
+}
+
+func TestExportDescriptors(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	rootKey, err := hdkeychain.NewMaster(
+		bytes.Repeat([]byte{0x42}, 32), params,
+	)
+	require.NoError(t, err)
+
+	strPaths := []string{
+		lnd.WalletDefaultDerivationPath,
+		lnd.WalletBIP86DerivationPath,
+		"m/1017'/1'/0'",
+	}
+	paths := make([][]uint32, len(strPaths))
+	for idx, strPath := range strPaths {
+		paths[idx], err = lnd.ParsePath(strPath)
+		require.NoError(t, err)
+	}
+
+	birthday := time.Unix(1600000000, 0)
+	var buf bytes.Buffer
+	err = ExportDescriptors(
+		rootKey, strPaths, paths, params, 100, birthday, &buf,
+	)
+	require.NoError(t, err)
+
+	output := buf.String()
+	start := strings.Index(output, "importdescriptors '")
+	require.GreaterOrEqual(t, start, 0)
+	jsonStr := output[start+len("importdescriptors '"):]
+	jsonStr = strings.TrimSuffix(jsonStr, "'\n")
+
+	var descriptors []*importDescriptor
+	require.NoError(t, json.Unmarshal([]byte(jsonStr), &descriptors))
+	require.Len(t, descriptors, 6)
+
+	prefixes := []string{"wpkh(", "wpkh(", "tr(", "tr(", "wpkh(", "wpkh("}
+	for idx, desc := range descriptors {
+		require.True(t, strings.HasPrefix(desc.Desc, prefixes[idx]))
+		require.Equal(t, idx%2 == 1, desc.Internal)
+		require.Equal(t, idx < 4, desc.Active)
+		require.Equal(t, birthday.Unix(), desc.Timestamp)
+		require.Equal(t, [2]uint32{0, 99}, desc.Range)
+
+		// The checksum must match the descriptor.
+		parts := strings.Split(desc.Desc, "#")
+		require.Len(t, parts, 2)
+		checksum, err := DescriptorChecksum(parts[0])
+		require.NoError(t, err)
+		require.Equal(t, checksum, parts[1])
+	}
+
+	// The key origin starts with the fingerprint of the root key and uses
+	// the "h" notation for hardened derivation.
+	require.Contains(t, descriptors[2].Desc, "/86h/0h/0h]tprv")
+	require.Contains(t, descriptors[3].Desc, "/1/*)#")
+}
+
+func TestDescriptorAddress(t *testing.T) {
+	// Test vectors from BIP-0084.
+	params := &chaincfg.MainNetParams
+	rootKey, err := bip39.MasterKeyFromMnemonic(
+		"abandon abandon abandon abandon abandon abandon abandon "+
+			"abandon abandon abandon abandon about", "", params,
+	)
+	require.NoError(t, err)
+
+	path, err := lnd.ParsePath(lnd.WalletDefaultDerivationPath)
+	require.NoError(t, err)
+	desc, err := AccountDescriptor(
+		rootKey, lnd.WalletDefaultDerivationPath, path, 0,
+	)
+	require.NoError(t, err)
+
+	addr, err := DescriptorAddress(desc, 0, params)
+	require.NoError(t, err)
+	require.Equal(t, "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu",
+		addr.String())
+
+	// The same address must be derived from the account xpub.
+	accountKey, err := lnd.DeriveChildren(rootKey, path)
+	require.NoError(t, err)
+	accountPub, err := accountKey.Neuter()
+	require.NoError(t, err)
+	body := "wpkh(" + accountPub.String() + "/0/*)"
+	checksum, err := DescriptorChecksum(body)
+	require.NoError(t, err)
+	pubDesc := body + "#" + checksum
+
+	addr, err = DescriptorAddress(pubDesc, 1, params)
+	require.NoError(t, err)
+	require.Equal(t, "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g",
+		addr.String())
+
+	_, err = DescriptorAddress(body, 0, params)
+	require.ErrorContains(t, err, "checksum is missing")
+
+	_, err = DescriptorAddress(body+"#aaaaaaaa", 0, params)
+	require.ErrorContains(t, err, "invalid descriptor checksum")
+
+	_, err = DescriptorAddress(pubDesc, 0, &chaincfg.TestNet3Params)
+	require.ErrorIs(t, err, ErrWrongNetwork)
+
+	trBody := "tr(" + accountPub.String() + "/0/*)"
+	checksum, err = DescriptorChecksum(trBody)
+	require.NoError(t, err)
+	_, err = DescriptorAddress(trBody+"#"+checksum, 0, params)
+	require.ErrorContains(t, err, "only wpkh()")
+
+	fixedBody := "wpkh(" + accountPub.String() + "/0/0)"
+	checksum, err = DescriptorChecksum(fixedBody)
+	require.NoError(t, err)
+	_, err = DescriptorAddress(fixedBody+"#"+checksum, 0, params)
+	require.ErrorContains(t, err, "must be ranged")
+}
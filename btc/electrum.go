@@ -0,0 +1,73 @@
+package btc
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/guggero/chantools/lnd"
+)
+
+const (
+	FormatElectrumMasterKey = "electrum-masterkey"
+
+	electrumMasterKeyFormat = `# Electrum master keys for the %s account %s,
+# created by chantools on %s.
+#
+# In Electrum, create a new "Standard wallet", choose "Use a master key" and
+# paste the master private key below to be able to see and spend the funds.
+# To only watch the funds, paste the master public key instead.
+Master private key: %s
+Master public key:  %s
+`
+)
+
+// ExportElectrumMasterKey writes the SLIP-0132 encoded extended private and
+// public key of the account with the given derivation path to the writer.
+// Electrum can restore a wallet with all addresses of that account from these
+// keys, the version bytes tell it to use P2WKH (BIP84 accounts) or P2SH
+// wrapped P2WKH (BIP49 accounts) addresses.
+func ExportElectrumMasterKey(extendedKey *hdkeychain.ExtendedKey,
+	strPath string, path []uint32, params *chaincfg.Params,
+	writer io.Writer) error {
+
+	if len(path) != 3 {
+		return fmt.Errorf("path %s is not an account path, must be of "+
+			"the form m/purpose'/coin'/account'", strPath)
+	}
+
+	var addrType string
+	switch path[0] {
+	case lnd.HardenedKey(49):
+		addrType = "p2sh-p2wkh"
+
+	case lnd.HardenedKey(84):
+		addrType = "p2wkh"
+
+	default:
+		return fmt.Errorf("unsupported purpose in path %s, Electrum "+
+			"only supports BIP49 and BIP84 accounts", strPath)
+	}
+
+	accountKey, err := lnd.DeriveChildren(extendedKey, path)
+	if err != nil {
+		return fmt.Errorf("could not derive account key: %w", err)
+	}
+	xPriv, err := lnd.PrivateForPurpose(accountKey, path[0], params)
+	if err != nil {
+		return err
+	}
+	xPub, err := lnd.NeuterForPurpose(accountKey, path[0], params)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(
+		writer, electrumMasterKeyFormat, addrType, strPath,
+		time.Now().UTC(), xPriv.String(), xPub.String(),
+	)
+
+	return nil
+}
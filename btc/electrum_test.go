@@ -0,0 +1,50 @@
+package btc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/guggero/chantools/lnd"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// The root key and BIP84 account keys of the BIP84 test vector
+	// mnemonic "abandon abandon ... about".
+	testVectorRootKey = "xprv9s21ZrQH143K3GJpoapnV8SFfukcVBSfeCficPSGfubm" +
+		"SFDxo1kuHnLisriDvSnRRuL2Qrg5ggqHKNVpxR86QEC8w35uxmGoggxtQ" +
+		"TPvfUu"
+	testVectorZPrv = "zprvAdG4iTXWBoARxkkzNpNh8r6Qag3irQB8PzEMkAFeTRXxHp" +
+		"bF9z4QgEvBRmfvqWvGp42t42nvgGpNgYSJA9iefm1yYNZKEm7z6qUWCroSQnE"
+	testVectorZPub = "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAc" +
+		"vPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+)
+
+func TestExportElectrumMasterKey(t *testing.T) {
+	rootKey, err := hdkeychain.NewKeyFromString(testVectorRootKey)
+	require.NoError(t, err)
+
+	path, err := lnd.ParsePath(lnd.WalletDefaultDerivationPath)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = ExportElectrumMasterKey(
+		rootKey, lnd.WalletDefaultDerivationPath, path,
+		&chaincfg.MainNetParams, &buf,
+	)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "p2wkh account m/84'/0'/0'")
+	require.Contains(t, buf.String(), "Master private key: "+testVectorZPrv)
+	require.Contains(t, buf.String(), "Master public key:  "+testVectorZPub)
+
+	// Electrum doesn't know about taproot master keys.
+	path, err = lnd.ParsePath(lnd.WalletBIP86DerivationPath)
+	require.NoError(t, err)
+	err = ExportElectrumMasterKey(
+		rootKey, lnd.WalletBIP86DerivationPath, path,
+		&chaincfg.MainNetParams, &buf,
+	)
+	require.ErrorContains(t, err, "unsupported purpose")
+}
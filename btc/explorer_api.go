@@ -6,22 +6,59 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTorProxy is the default SOCKS5 proxy address of a local Tor
+	// daemon that is used for .onion API URLs if no proxy is configured.
+	DefaultTorProxy = "localhost:9050"
+
+	// apiTimeout is the timeout for a single request to the API.
+	apiTimeout = time.Minute
 )
 
 var (
 	ErrTxNotFound = errors.New("transaction not found")
 )
 
+// ExplorerAPI is a client for an Esplora compatible block explorer API. If a
+// request to the base URL fails, the fallback URL (if set) is tried instead.
+// Responses that can't change anymore are cached for the lifetime of the
+// client, so they are only fetched once per run: raw transactions, confirmed
+// transactions and the spend information of outputs that were spent in a
+// confirmed transaction. Unconfirmed transactions and spends, address and chain
+// information can change at any time and are never cached.
 type ExplorerAPI struct {
 	BaseURL string
+
+	// FallbackURL is an optional second API URL that is used if the
+	// request to BaseURL fails.
+	FallbackURL string
+
+	// TorProxy is the host:port of the SOCKS5 proxy of a Tor daemon. If
+	// set, all requests are sent through it. If not set, .onion URLs use
+	// DefaultTorProxy.
+	TorProxy string
+
+	// Timeout is the timeout for a single request to the API. If not set,
+	// a timeout of one minute is used.
+	Timeout time.Duration
+
+	cacheMtx sync.Mutex
+	cache    map[string][]byte
 }
 
 type TX struct {
-	TXID string  `json:"txid"`
-	Vin  []*Vin  `json:"vin"`
-	Vout []*Vout `json:"vout"`
+	TXID     string  `json:"txid"`
+	Locktime uint32  `json:"locktime"`
+	Vin      []*Vin  `json:"vin"`
+	Vout     []*Vout `json:"vout"`
+	Status   *Status `json:"status"`
 }
 
 type Vin struct {
@@ -69,16 +106,18 @@ type AddressStats struct {
 
 func (a *ExplorerAPI) Transaction(txid string) (*TX, error) {
 	tx := &TX{}
-	err := fetchJSON(fmt.Sprintf("%s/tx/%s", a.BaseURL, txid), tx)
+	err := a.fetchJSON(fmt.Sprintf("/tx/%s", txid), tx, func() bool {
+		return isConfirmed(tx.Status)
+	})
 	if err != nil {
 		return nil, err
 	}
 	for idx, vout := range tx.Vout {
-		url := fmt.Sprintf(
-			"%s/tx/%s/outspend/%d", a.BaseURL, txid, idx,
-		)
+		path := fmt.Sprintf("/tx/%s/outspend/%d", txid, idx)
 		outspend := Outspend{}
-		err := fetchJSON(url, &outspend)
+		err := a.fetchJSON(path, &outspend, func() bool {
+			return outspend.Spent && isConfirmed(outspend.Status)
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -87,9 +126,94 @@ func (a *ExplorerAPI) Transaction(txid string) (*TX, error) {
 	return tx, nil
 }
 
+func (a *ExplorerAPI) RawTransaction(txid string) (string, error) {
+	path := fmt.Sprintf("/tx/%s/hex", txid)
+	body, ok := a.cached(path)
+	if ok {
+		return strings.TrimSpace(string(body)), nil
+	}
+
+	body, status, err := a.get(path)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK {
+		if string(body) == "Transaction not found" {
+			return "", ErrTxNotFound
+		}
+		return "", fmt.Errorf("error fetching transaction %s: %s",
+			txid, body)
+	}
+
+	// The raw transaction can't change anymore once it's known.
+	a.storeCache(path, body)
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+func (a *ExplorerAPI) TxStatus(txid string) (*Status, error) {
+	status := &Status{}
+	err := a.fetchJSONNoCache(fmt.Sprintf("/tx/%s/status", txid), status)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (a *ExplorerAPI) BlockHeight() (uint32, error) {
+	body, _, err := a.get("/blocks/tip/height")
+	if err != nil {
+		return 0, err
+	}
+
+	heightStr := strings.TrimSpace(string(body))
+	height, err := strconv.ParseUint(heightStr, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing block height %s: %w",
+			heightStr, err)
+	}
+	return uint32(height), nil
+}
+
+// FeeEstimate returns the fee rate in sat/vByte that the API estimates for a
+// transaction to confirm within the given number of blocks. The API only
+// provides estimates for some targets, so the estimate for the highest target
+// that isn't larger than the requested one is used.
+func (a *ExplorerAPI) FeeEstimate(confTarget uint32) (float64, error) {
+	estimates := make(map[string]float64)
+	err := a.fetchJSONNoCache("/fee-estimates", &estimates)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		bestTarget uint64
+		feeRate    float64
+	)
+	for targetStr, estimate := range estimates {
+		target, err := strconv.ParseUint(targetStr, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing fee estimate "+
+				"target %s: %w", targetStr, err)
+		}
+		if target > uint64(confTarget) || target <= bestTarget {
+			continue
+		}
+
+		bestTarget = target
+		feeRate = estimate
+	}
+	if bestTarget == 0 {
+		return 0, fmt.Errorf("no fee estimate available for a "+
+			"confirmation target of %d blocks", confTarget)
+	}
+
+	return feeRate, nil
+}
+
 func (a *ExplorerAPI) Outpoint(addr string) (*TX, int, error) {
 	var txs []*TX
-	err := fetchJSON(fmt.Sprintf("%s/address/%s/txs", a.BaseURL, addr), &txs)
+	err := a.fetchJSONNoCache(fmt.Sprintf("/address/%s/txs", addr), &txs)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -111,7 +235,7 @@ func (a *ExplorerAPI) Unspent(addr string) ([]*Vout, error) {
 		txs     []*TX
 		err     error
 	)
-	err = fetchJSON(fmt.Sprintf("%s/address/%s", a.BaseURL, addr), &stats)
+	err = a.fetchJSONNoCache(fmt.Sprintf("/address/%s", addr), &stats)
 	if err != nil {
 		return nil, err
 	}
@@ -125,7 +249,7 @@ func (a *ExplorerAPI) Unspent(addr string) ([]*Vout, error) {
 		return nil, nil
 	}
 
-	err = fetchJSON(fmt.Sprintf("%s/address/%s/txs", a.BaseURL, addr), &txs)
+	err = a.fetchJSONNoCache(fmt.Sprintf("/address/%s/txs", addr), &txs)
 	if err != nil {
 		return nil, err
 	}
@@ -168,38 +292,220 @@ func (a *ExplorerAPI) Address(outpoint string) (string, error) {
 	return tx.Vout[vout].ScriptPubkeyAddr, nil
 }
 
-func (a *ExplorerAPI) PublishTx(rawTxHex string) (string, error) {
-	url := fmt.Sprintf("%s/tx", a.BaseURL)
-	resp, err := http.Post(url, "text/plain", strings.NewReader(rawTxHex))
+func (a *ExplorerAPI) AddressUsed(addr string) (bool, error) {
+	stats := &AddressStats{}
+	err := a.fetchJSONNoCache(fmt.Sprintf("/address/%s", addr), &stats)
 	if err != nil {
-		return "", err
+		return false, err
 	}
-	defer resp.Body.Close()
-	body := new(bytes.Buffer)
-	_, err = body.ReadFrom(resp.Body)
-	if err != nil {
-		return "", err
+
+	var txCount uint32
+	if stats.ChainStats != nil {
+		txCount += stats.ChainStats.TXCount
+	}
+	if stats.MempoolStats != nil {
+		txCount += stats.MempoolStats.TXCount
 	}
-	return body.String(), nil
+
+	return txCount > 0, nil
 }
 
-func fetchJSON(url string, target interface{}) error {
-	resp, err := http.Get(url)
+func (a *ExplorerAPI) PublishTx(rawTxHex string) (string, error) {
+	var (
+		body    []byte
+		lastErr error
+	)
+	for _, baseURL := range a.baseURLs() {
+		client, err := a.httpClient(baseURL)
+		if err != nil {
+			return "", err
+		}
+
+		resp, err := client.Post(
+			baseURL+"/tx", "text/plain",
+			strings.NewReader(rawTxHex),
+		)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		body, err = readBody(resp)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			lastErr = fmt.Errorf("error publishing TX: %s", body)
+			continue
+		}
+
+		return string(body), nil
+	}
+
+	return "", lastErr
+}
+
+// fetchJSON decodes the response for the given path into the target, using the
+// cache if possible. A fetched response is only cached if isFinal returns true
+// after it was decoded, which means it can't change anymore.
+func (a *ExplorerAPI) fetchJSON(path string, target interface{},
+	isFinal func() bool) error {
+
+	if body, ok := a.cached(path); ok {
+		return json.Unmarshal(body, target)
+	}
+
+	body, err := a.decodeJSON(path, target)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	if isFinal() {
+		a.storeCache(path, body)
+	}
 
-	body := new(bytes.Buffer)
-	_, err = body.ReadFrom(resp.Body)
+	return nil
+}
+
+func (a *ExplorerAPI) fetchJSONNoCache(path string,
+	target interface{}) error {
+
+	_, err := a.decodeJSON(path, target)
+	return err
+}
+
+func (a *ExplorerAPI) decodeJSON(path string, target interface{}) ([]byte,
+	error) {
+
+	body, _, err := a.get(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = json.Unmarshal(body.Bytes(), target)
+	err = json.Unmarshal(body, target)
 	if err != nil {
-		if body.String() == "Transaction not found" {
-			return ErrTxNotFound
+		if string(body) == "Transaction not found" {
+			return nil, ErrTxNotFound
 		}
+		return nil, err
 	}
-	return err
+	return body, nil
+}
+
+// cached returns the cached response body for the given path, if there is one.
+func (a *ExplorerAPI) cached(path string) ([]byte, bool) {
+	a.cacheMtx.Lock()
+	defer a.cacheMtx.Unlock()
+
+	body, ok := a.cache[path]
+	return body, ok
+}
+
+// storeCache caches the response body for the given path. It must only be
+// called for responses that can't change anymore.
+func (a *ExplorerAPI) storeCache(path string, body []byte) {
+	a.cacheMtx.Lock()
+	defer a.cacheMtx.Unlock()
+
+	if a.cache == nil {
+		a.cache = make(map[string][]byte)
+	}
+	a.cache[path] = body
+}
+
+// isConfirmed returns true if the given status is that of a confirmed
+// transaction.
+func isConfirmed(status *Status) bool {
+	return status != nil && status.Confirmed
+}
+
+// get sends a GET request for the given path to the base URL and, if that
+// fails, to the fallback URL. The response body and status code are returned.
+func (a *ExplorerAPI) get(path string) ([]byte, int, error) {
+	var lastErr error
+	for _, baseURL := range a.baseURLs() {
+		client, err := a.httpClient(baseURL)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		resp, err := client.Get(baseURL + path)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		body, err := readBody(resp)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		// A server error means the API has a problem, a 4xx error
+		// means the API doesn't know what we're asking for and would
+		// most likely be the same for the fallback.
+		if resp.StatusCode >= http.StatusInternalServerError {
+			lastErr = fmt.Errorf("error fetching %s: status %d: %s",
+				path, resp.StatusCode, body)
+			continue
+		}
+
+		return body, resp.StatusCode, nil
+	}
+
+	return nil, 0, lastErr
+}
+
+// baseURLs returns the base URL and, if set, the fallback URL.
+func (a *ExplorerAPI) baseURLs() []string {
+	urls := []string{strings.TrimSuffix(a.BaseURL, "/")}
+	if a.FallbackURL != "" {
+		urls = append(urls, strings.TrimSuffix(a.FallbackURL, "/"))
+	}
+
+	return urls
+}
+
+// httpClient returns the HTTP client to use for the given base URL. Requests
+// to .onion URLs or to any URL if a Tor proxy is configured are sent through
+// the Tor SOCKS5 proxy.
+func (a *ExplorerAPI) httpClient(baseURL string) (*http.Client, error) {
+	parsedURL, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing API URL %s: %w", baseURL,
+			err)
+	}
+
+	timeout := a.Timeout
+	if timeout == 0 {
+		timeout = apiTimeout
+	}
+
+	proxy := a.TorProxy
+	if proxy == "" && strings.HasSuffix(parsedURL.Hostname(), ".onion") {
+		proxy = DefaultTorProxy
+	}
+	if proxy == "" {
+		return &http.Client{Timeout: timeout}, nil
+	}
+
+	// The Go SOCKS5 client sends host names to the proxy unresolved, so
+	// the Tor daemon can resolve .onion addresses.
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyURL(&url.URL{
+				Scheme: "socks5",
+				Host:   proxy,
+			}),
+		},
+	}, nil
+}
+
+func readBody(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+
+	return body.Bytes(), nil
 }
@@ -0,0 +1,163 @@
+package btc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplorerAPIFallbackAndCache(t *testing.T) {
+	broken := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+	))
+	defer broken.Close()
+
+	var numRequests int32
+	working := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&numRequests, 1)
+
+			switch r.URL.Path {
+			case "/tx/abcd/hex":
+				_, _ = w.Write([]byte("0200\n"))
+
+			case "/blocks/tip/height":
+				_, _ = w.Write([]byte("1234"))
+
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte("Transaction not found"))
+			}
+		},
+	))
+	defer working.Close()
+
+	api := &ExplorerAPI{
+		BaseURL:     broken.URL,
+		FallbackURL: working.URL + "/",
+	}
+
+	// The first request goes to the fallback, the second one is served
+	// from the cache.
+	for i := 0; i < 2; i++ {
+		rawTx, err := api.RawTransaction("abcd")
+		require.NoError(t, err)
+		require.Equal(t, "0200", rawTx)
+	}
+	require.EqualValues(t, 1, atomic.LoadInt32(&numRequests))
+
+	// The block height must never be cached.
+	for i := 0; i < 2; i++ {
+		height, err := api.BlockHeight()
+		require.NoError(t, err)
+		require.EqualValues(t, 1234, height)
+	}
+	require.EqualValues(t, 3, atomic.LoadInt32(&numRequests))
+
+	// A 404 isn't a reason to use the fallback and isn't cached either.
+	_, err := api.RawTransaction("ef01")
+	require.ErrorIs(t, err, ErrTxNotFound)
+	_, err = api.RawTransaction("ef01")
+	require.ErrorIs(t, err, ErrTxNotFound)
+	require.EqualValues(t, 5, atomic.LoadInt32(&numRequests))
+
+	// Without a fallback, the error of the broken API is returned.
+	api = &ExplorerAPI{BaseURL: broken.URL}
+	_, err = api.BlockHeight()
+	require.ErrorContains(t, err, "status 503")
+}
+
+func TestExplorerAPICacheStatusChange(t *testing.T) {
+	var (
+		numRequests int32
+		confirmed   int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&numRequests, 1)
+			status := &Status{
+				Confirmed: atomic.LoadInt32(&confirmed) == 1,
+			}
+
+			switch r.URL.Path {
+			case "/tx/abcd":
+				_ = json.NewEncoder(w).Encode(&TX{
+					TXID:   "abcd",
+					Vout:   []*Vout{{Value: 1234}},
+					Status: status,
+				})
+
+			case "/tx/abcd/outspend/0":
+				_ = json.NewEncoder(w).Encode(&Outspend{
+					Spent:  true,
+					Txid:   "ef01",
+					Status: status,
+				})
+
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		},
+	))
+	defer server.Close()
+
+	api := &ExplorerAPI{BaseURL: server.URL}
+
+	// As long as the transaction and its spend are unconfirmed, both are
+	// fetched again on every call.
+	for i := 0; i < 2; i++ {
+		tx, err := api.Transaction("abcd")
+		require.NoError(t, err)
+		require.False(t, tx.Status.Confirmed)
+		require.False(t, tx.Vout[0].Outspend.Status.Confirmed)
+	}
+	require.EqualValues(t, 4, atomic.LoadInt32(&numRequests))
+
+	// Once they confirm, the new status must be returned and both
+	// responses are cached from then on.
+	atomic.StoreInt32(&confirmed, 1)
+	for i := 0; i < 2; i++ {
+		tx, err := api.Transaction("abcd")
+		require.NoError(t, err)
+		require.True(t, tx.Status.Confirmed)
+		require.True(t, tx.Vout[0].Outspend.Status.Confirmed)
+	}
+	require.EqualValues(t, 6, atomic.LoadInt32(&numRequests))
+}
+
+func TestExplorerAPITorProxy(t *testing.T) {
+	api := &ExplorerAPI{}
+
+	client, err := api.httpClient("https://blockstream.info/api")
+	require.NoError(t, err)
+	require.Nil(t, client.Transport)
+
+	onionURL := "http://explorerzydxu5ecjrkwceayqybizmpjjznk5izmitf2mo" +
+		"dhcusuqlid.onion/api"
+	client, err = api.httpClient(onionURL)
+	require.NoError(t, err)
+	assertProxy(t, client, onionURL, DefaultTorProxy)
+
+	api.TorProxy = "127.0.0.1:9150"
+	client, err = api.httpClient("https://blockstream.info/api")
+	require.NoError(t, err)
+	assertProxy(t, client, "https://blockstream.info/api", api.TorProxy)
+}
+
+func assertProxy(t *testing.T, client *http.Client, reqURL, proxy string) {
+	transport, ok := client.Transport.(*http.Transport)
+	require.True(t, ok)
+
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	require.NoError(t, err)
+	proxyURL, err := transport.Proxy(req)
+	require.NoError(t, err)
+	require.Equal(t, "socks5", proxyURL.Scheme)
+	require.Equal(t, proxy, proxyURL.Host)
+}
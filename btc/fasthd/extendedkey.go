@@ -38,6 +38,11 @@ func (k *FastDerivation) PubKeyBytes() []byte {
 	return pubKey.SerializeCompressed()
 }
 
+func (k *FastDerivation) PrivKey() *btcec.PrivateKey {
+	privKey, _ := btcec.PrivKeyFromBytes(k.key)
+	return privKey
+}
+
 func (k *FastDerivation) Child(i uint32) error {
 	isChildHardened := i >= HardenedKeyStart
 	if isChildHardened {
@@ -0,0 +1,124 @@
+package btc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/guggero/chantools/bip39"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	// numWordSuggestions is the maximum number of words that are suggested
+	// if a word isn't in the word list.
+	numWordSuggestions = 5
+)
+
+// WordReader reads a single line of input after showing the given prompt.
+type WordReader func(prompt string) (string, error)
+
+// ReadTerminalWord is a WordReader that reads from the terminal without
+// echoing the input, so the words don't show up on the screen or in the
+// terminal's scroll back.
+func ReadTerminalWord(prompt string) (string, error) {
+	fmt.Print(prompt)
+	word, err := terminal.ReadPassword(int(syscall.Stdin)) // nolint
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+
+	return string(word), nil
+}
+
+// ReadMnemonicInteractive reads a mnemonic of the given number of words one
+// word at a time. Each word is validated against the active BIP39 word list
+// and prompted for again, with suggestions for the intended word, if it isn't
+// in the list. If numWords is zero, the number of words is asked for first and
+// the checksum of the complete mnemonic is verified as a BIP39 mnemonic.
+// Otherwise, the caller is responsible for verifying the checksum, for example
+// for the aezeed which uses the same word list but a different checksum.
+func ReadMnemonicInteractive(numWords int, readWord WordReader) (string,
+	error) {
+
+	checkBIP39 := numWords == 0
+	if checkBIP39 {
+		var err error
+		numWords, err = readWordCount(readWord)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	words := make([]string, 0, numWords)
+	for len(words) < numWords {
+		word, err := readWord(fmt.Sprintf("Input word %d of %d: ",
+			len(words)+1, numWords))
+		if err != nil {
+			return "", err
+		}
+
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word == "" {
+			continue
+		}
+
+		if _, ok := bip39.GetWordIndex(word); !ok {
+			fmt.Printf("Word %d is not in the word list%s\n",
+				len(words)+1, formatSuggestions(word))
+			continue
+		}
+
+		words = append(words, word)
+	}
+
+	mnemonic := strings.Join(words, " ")
+	if checkBIP39 {
+		if _, err := bip39.EntropyFromMnemonic(mnemonic); err != nil {
+			return "", fmt.Errorf("mnemonic is invalid, all words "+
+				"are in the word list so at least one word is "+
+				"wrong or in the wrong position: %w", err)
+		}
+	}
+
+	return mnemonic, nil
+}
+
+// readWordCount asks for the number of words of a BIP39 mnemonic until a valid
+// number is given.
+func readWordCount(readWord WordReader) (int, error) {
+	for {
+		input, err := readWord("Input the number of words of your " +
+			"mnemonic (12, 15, 18, 21 or 24): ")
+		if err != nil {
+			return 0, err
+		}
+
+		numWords, err := strconv.Atoi(strings.TrimSpace(input))
+		if err == nil && numWords >= 12 && numWords <= 24 &&
+			numWords%3 == 0 {
+
+			return numWords, nil
+		}
+
+		fmt.Println("Invalid number of words, must be 12, 15, 18, " +
+			"21 or 24")
+	}
+}
+
+// formatSuggestions returns a hint with the words of the word list that start
+// with the given word or, if there are none, the words closest to it.
+func formatSuggestions(word string) string {
+	suggestions := bip39.SuggestWords(word, numWordSuggestions)
+	if len(suggestions) == 0 {
+		suggestions = bip39.ClosestWords(word, numWordSuggestions)
+	}
+	if len(suggestions) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(", did you mean one of: %s",
+		strings.Join(suggestions, ", "))
+}
@@ -0,0 +1,81 @@
+package btc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/guggero/chantools/bip39"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testMnemonic = "uncover bargain diesel boss local host over divide " +
+		"orient cradle good crumble"
+)
+
+// scriptedWordReader returns a WordReader that returns the given inputs in
+// order and records the prompts it was called with.
+func scriptedWordReader(inputs []string, prompts *[]string) WordReader {
+	return func(prompt string) (string, error) {
+		*prompts = append(*prompts, prompt)
+		if len(inputs) == 0 {
+			return "", errors.New("no more input")
+		}
+
+		input := inputs[0]
+		inputs = inputs[1:]
+		return input, nil
+	}
+}
+
+func TestReadMnemonicInteractive(t *testing.T) {
+	words := strings.Split(testMnemonic, " ")
+
+	// Ask for the word count, get an invalid count first, then the words
+	// with an empty line, a typo and upper case letters in between.
+	inputs := []string{"13", "12"}
+	inputs = append(inputs, words[:3]...)
+	inputs = append(inputs, "", "bos", " BOSS ")
+	inputs = append(inputs, words[4:]...)
+
+	var prompts []string
+	mnemonic, err := ReadMnemonicInteractive(
+		0, scriptedWordReader(inputs, &prompts),
+	)
+	require.NoError(t, err)
+	require.Equal(t, testMnemonic, mnemonic)
+
+	// Two prompts for the word count, plus the empty line and the typo
+	// that are asked for again.
+	require.Len(t, prompts, 2+len(words)+2)
+	require.Equal(t, "Input word 4 of 12: ", prompts[len(prompts)-9])
+	require.Equal(t, "Input word 12 of 12: ", prompts[len(prompts)-1])
+}
+
+func TestReadMnemonicInteractiveChecksum(t *testing.T) {
+	words := strings.Split(testMnemonic, " ")
+	words[0], words[1] = words[1], words[0]
+
+	var prompts []string
+	_, err := ReadMnemonicInteractive(
+		0, scriptedWordReader(append([]string{"12"}, words...), &prompts),
+	)
+	require.ErrorIs(t, err, bip39.ErrChecksumIncorrect)
+
+	// Without a BIP39 checksum check, the words are returned as is.
+	prompts = nil
+	mnemonic, err := ReadMnemonicInteractive(
+		len(words), scriptedWordReader(words, &prompts),
+	)
+	require.NoError(t, err)
+	require.Equal(t, strings.Join(words, " "), mnemonic)
+	require.Len(t, prompts, len(words))
+}
+
+func TestFormatSuggestions(t *testing.T) {
+	require.Equal(
+		t, ", did you mean one of: boss", formatSuggestions("bos"),
+	)
+	require.Contains(t, formatSuggestions("bosx"), "boss")
+}
@@ -0,0 +1,292 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/input"
+)
+
+// MultisigKey is the extended key of one participant of a multisig wallet.
+type MultisigKey struct {
+	// Key is the extended public or private key of the participant.
+	Key *hdkeychain.ExtendedKey
+
+	// Fingerprint is the fingerprint of the master key Key was derived
+	// from, encoded as little endian like in a PSBT.
+	Fingerprint uint32
+
+	// Path is the derivation path from the master key to Key.
+	Path []uint32
+}
+
+// ParseMultisigKey parses an extended public or private key, optionally
+// prefixed with its key origin in the output descriptor notation, for example
+// [d34db33f/48'/0'/0'/2']xpub.... Without a key origin, the key itself is
+// assumed to be the master key. The key must belong to the given network.
+func ParseMultisigKey(keyStr string, params *chaincfg.Params) (*MultisigKey,
+	error) {
+
+	keyStr = strings.TrimSpace(keyStr)
+
+	var (
+		fingerprint []byte
+		path        []uint32
+	)
+	if strings.HasPrefix(keyStr, "[") {
+		end := strings.Index(keyStr, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("key origin of %s is not "+
+				"closed", keyStr)
+		}
+		origin := strings.SplitN(keyStr[1:end], "/", 2)
+		keyStr = keyStr[end+1:]
+
+		var err error
+		fingerprint, err = hex.DecodeString(origin[0])
+		if err != nil || len(fingerprint) != 4 {
+			return nil, fmt.Errorf("invalid fingerprint %s in key "+
+				"origin", origin[0])
+		}
+
+		if len(origin) == 2 {
+			path, err = lnd.ParsePath(
+				"m/" + strings.ReplaceAll(origin[1], "h", "'"),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path in key "+
+					"origin: %w", err)
+			}
+		}
+	}
+
+	key, err := ParseExtendedKey(keyStr, params)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing extended key: %w", err)
+	}
+
+	if fingerprint == nil {
+		pubKey, err := key.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+		fingerprint = btcutil.Hash160(pubKey.SerializeCompressed())[:4]
+	}
+
+	return &MultisigKey{
+		Key:         key,
+		Fingerprint: binary.LittleEndian.Uint32(fingerprint),
+		Path:        path,
+	}, nil
+}
+
+// Multisig is an N-of-M P2WSH multisig wallet. The keys of all addresses are
+// derived from the participants' extended keys with the non-hardened path
+// <branch>/<index>.
+type Multisig struct {
+	Keys      []*MultisigKey
+	Threshold int
+
+	// Sorted indicates that the public keys are sorted in the script as
+	// defined in BIP-0067 (sortedmulti). Otherwise they appear in the
+	// order of Keys.
+	Sorted bool
+
+	Params *chaincfg.Params
+}
+
+// MultisigDerivedKey is the key of one participant for a single multisig
+// address.
+type MultisigDerivedKey struct {
+	// Key is the derived extended key, it's a private key if the
+	// participant's key was given as a private key.
+	Key *hdkeychain.ExtendedKey
+
+	PubKey     *btcec.PublicKey
+	Derivation *psbt.Bip32Derivation
+}
+
+// MultisigAddress is a single address of a multisig wallet.
+type MultisigAddress struct {
+	Branch uint32
+	Index  uint32
+
+	WitnessScript []byte
+	PkScript      []byte
+	Address       btcutil.Address
+
+	// Keys are the derived keys in the same order as in the witness
+	// script.
+	Keys []*MultisigDerivedKey
+}
+
+// Validate makes sure the threshold and number of keys are within the limits
+// of an OP_CHECKMULTISIG script.
+func (m *Multisig) Validate() error {
+	switch {
+	case len(m.Keys) == 0:
+		return fmt.Errorf("at least one key is required")
+
+	case len(m.Keys) > txscript.MaxPubKeysPerMultiSig:
+		return fmt.Errorf("at most %d keys are allowed, got %d",
+			txscript.MaxPubKeysPerMultiSig, len(m.Keys))
+
+	case m.Threshold < 1 || m.Threshold > len(m.Keys):
+		return fmt.Errorf("threshold must be between 1 and %d, got %d",
+			len(m.Keys), m.Threshold)
+	}
+
+	return nil
+}
+
+// Derive derives the multisig address with the given branch and index.
+func (m *Multisig) Derive(branch, index uint32) (*MultisigAddress, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	keys := make([]*MultisigDerivedKey, len(m.Keys))
+	for idx, key := range m.Keys {
+		derived, err := lnd.DeriveChildren(
+			key.Key, []uint32{branch, index},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error deriving key %d: %w",
+				idx, err)
+		}
+		pubKey, err := derived.ECPubKey()
+		if err != nil {
+			return nil, fmt.Errorf("error deriving pubkey %d: %w",
+				idx, err)
+		}
+
+		path := make([]uint32, 0, len(key.Path)+2)
+		path = append(path, key.Path...)
+		path = append(path, branch, index)
+		pubKeyBytes := pubKey.SerializeCompressed()
+		keys[idx] = &MultisigDerivedKey{
+			Key:    derived,
+			PubKey: pubKey,
+			Derivation: &psbt.Bip32Derivation{
+				PubKey:               pubKeyBytes,
+				MasterKeyFingerprint: key.Fingerprint,
+				Bip32Path:            path,
+			},
+		}
+	}
+
+	if m.Sorted {
+		sort.Slice(keys, func(i, j int) bool {
+			return bytes.Compare(
+				keys[i].Derivation.PubKey,
+				keys[j].Derivation.PubKey,
+			) < 0
+		})
+	}
+
+	builder := txscript.NewScriptBuilder()
+	builder.AddInt64(int64(m.Threshold))
+	for _, key := range keys {
+		builder.AddData(key.Derivation.PubKey)
+	}
+	builder.AddInt64(int64(len(keys)))
+	builder.AddOp(txscript.OP_CHECKMULTISIG)
+	witnessScript, err := builder.Script()
+	if err != nil {
+		return nil, fmt.Errorf("error building script: %w", err)
+	}
+
+	pkScript, err := input.WitnessScriptHash(witnessScript)
+	if err != nil {
+		return nil, err
+	}
+	address, err := btcutil.NewAddressWitnessScriptHash(
+		pkScript[2:], m.Params,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MultisigAddress{
+		Branch:        branch,
+		Index:         index,
+		WitnessScript: witnessScript,
+		PkScript:      pkScript,
+		Address:       address,
+		Keys:          keys,
+	}, nil
+}
+
+// Descriptor returns the ranged output descriptor including checksum of the
+// given branch of the multisig wallet. Only the public keys are part of the
+// descriptor.
+func (m *Multisig) Descriptor(branch uint32) (string, error) {
+	if err := m.Validate(); err != nil {
+		return "", err
+	}
+
+	keys := make([]string, len(m.Keys))
+	for idx, key := range m.Keys {
+		pubKey, err := key.Key.Neuter()
+		if err != nil {
+			return "", err
+		}
+
+		// Bitcoin Core only knows the default xpub version, so we
+		// don't use any SLIP-0132 version bytes.
+		pubKey, err = pubKey.CloneWithVersion(m.Params.HDPublicKeyID[:])
+		if err != nil {
+			return "", err
+		}
+
+		var fingerprint [4]byte
+		binary.LittleEndian.PutUint32(fingerprint[:], key.Fingerprint)
+		origin := hex.EncodeToString(fingerprint[:])
+		for _, pathPart := range key.Path {
+			if pathPart >= hdkeychain.HardenedKeyStart {
+				origin += fmt.Sprintf("/%dh", pathPart-
+					hdkeychain.HardenedKeyStart)
+			} else {
+				origin += fmt.Sprintf("/%d", pathPart)
+			}
+		}
+		keys[idx] = fmt.Sprintf("[%s]%s/%d/*", origin, pubKey.String(),
+			branch)
+	}
+
+	multi := "multi"
+	if m.Sorted {
+		multi = "sortedmulti"
+	}
+	desc := fmt.Sprintf("wsh(%s(%d,%s))", multi, m.Threshold,
+		strings.Join(keys, ","))
+
+	checksum, err := DescriptorChecksum(desc)
+	if err != nil {
+		return "", err
+	}
+
+	return desc + "#" + checksum, nil
+}
+
+// MultisigWitnessSize returns the size of the witness that spends a multisig
+// output with the given witness script and threshold.
+func MultisigWitnessSize(witnessScript []byte, threshold int) int {
+	return 1 + // Number of witness elements.
+		1 + // Empty element for the OP_CHECKMULTISIG bug.
+		threshold*(1+73) + // Signatures.
+		wire.VarIntSerializeSize(uint64(len(witnessScript))) +
+		len(witnessScript)
+}
@@ -0,0 +1,133 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/guggero/chantools/lnd"
+	"github.com/stretchr/testify/require"
+)
+
+// testMultisigKey returns the account key m/48'/1'/0'/2' of a master key that
+// is created from the given seed byte, prefixed with its key origin.
+func testMultisigKey(t *testing.T, seed byte, private bool) string {
+	master, err := hdkeychain.NewMaster(
+		bytes.Repeat([]byte{seed}, 32), &chaincfg.RegressionNetParams,
+	)
+	require.NoError(t, err)
+	masterPubKey, err := master.ECPubKey()
+	require.NoError(t, err)
+	fingerprint := btcutil.Hash160(masterPubKey.SerializeCompressed())[:4]
+
+	account, err := lnd.DeriveChildren(master, []uint32{
+		lnd.HardenedKey(48), lnd.HardenedKey(1), lnd.HardenedKey(0),
+		lnd.HardenedKey(2),
+	})
+	require.NoError(t, err)
+	if !private {
+		account, err = account.Neuter()
+		require.NoError(t, err)
+	}
+
+	return fmt.Sprintf("[%x/48h/1h/0h/2h]%s", fingerprint, account.String())
+}
+
+func TestParseMultisigKey(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	keyStr := testMultisigKey(t, 1, false)
+	key, err := ParseMultisigKey(keyStr, params)
+	require.NoError(t, err)
+	require.False(t, key.Key.IsPrivate())
+	require.Equal(t, []uint32{
+		lnd.HardenedKey(48), lnd.HardenedKey(1), lnd.HardenedKey(0),
+		lnd.HardenedKey(2),
+	}, key.Path)
+
+	var fingerprint [4]byte
+	binary.LittleEndian.PutUint32(fingerprint[:], key.Fingerprint)
+	require.Equal(t, keyStr[1:9], fmt.Sprintf("%x", fingerprint))
+
+	// Without an origin, the key is its own master key.
+	plainKey := keyStr[strings.Index(keyStr, "]")+1:]
+	key, err = ParseMultisigKey(plainKey, params)
+	require.NoError(t, err)
+	require.Empty(t, key.Path)
+
+	_, err = ParseMultisigKey("[d34db33f/48h"+plainKey, params)
+	require.ErrorContains(t, err, "not closed")
+
+	_, err = ParseMultisigKey("[d34db3/48h]"+plainKey, params)
+	require.ErrorContains(t, err, "invalid fingerprint")
+
+	_, err = ParseMultisigKey("[d34db33f/48x]"+plainKey, params)
+	require.ErrorContains(t, err, "invalid path")
+
+	_, err = ParseMultisigKey(keyStr, &chaincfg.MainNetParams)
+	require.ErrorIs(t, err, ErrWrongNetwork)
+}
+
+func TestMultisigDerive(t *testing.T) {
+	var keys []*MultisigKey
+	for seed := byte(1); seed <= 3; seed++ {
+		key, err := ParseMultisigKey(
+			testMultisigKey(t, seed, false),
+			&chaincfg.RegressionNetParams,
+		)
+		require.NoError(t, err)
+		keys = append(keys, key)
+	}
+	multisig := &Multisig{
+		Keys:      keys,
+		Threshold: 2,
+		Sorted:    true,
+		Params:    &chaincfg.RegressionNetParams,
+	}
+
+	addr, err := multisig.Derive(1, 5)
+	require.NoError(t, err)
+	require.Equal(
+		t, txscript.MultiSigTy,
+		txscript.GetScriptClass(addr.WitnessScript),
+	)
+	require.True(t, strings.HasPrefix(addr.Address.String(), "bcrt1q"))
+
+	// The keys are sorted as they appear in the script and carry the full
+	// derivation path.
+	pubKeys, numSigs, err := txscript.CalcMultiSigStats(addr.WitnessScript)
+	require.NoError(t, err)
+	require.Equal(t, 3, pubKeys)
+	require.Equal(t, 2, numSigs)
+	for idx := 1; idx < len(addr.Keys); idx++ {
+		require.Negative(t, bytes.Compare(
+			addr.Keys[idx-1].Derivation.PubKey,
+			addr.Keys[idx].Derivation.PubKey,
+		))
+	}
+	for _, key := range addr.Keys {
+		require.Len(t, key.Derivation.Bip32Path, 6)
+		require.Equal(t, []uint32{1, 5}, key.Derivation.Bip32Path[4:])
+	}
+
+	descriptor, err := multisig.Descriptor(0)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(descriptor, "wsh(sortedmulti(2,["))
+	checksum, err := DescriptorChecksum(descriptor[:len(descriptor)-9])
+	require.NoError(t, err)
+	require.Equal(t, checksum, descriptor[len(descriptor)-8:])
+
+	// The limits of OP_CHECKMULTISIG are enforced.
+	multisig.Threshold = 4
+	_, err = multisig.Derive(0, 0)
+	require.ErrorContains(t, err, "threshold must be between 1 and 3")
+
+	multisig.Keys = nil
+	_, err = multisig.Derive(0, 0)
+	require.ErrorContains(t, err, "at least one key")
+}
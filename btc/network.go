@@ -0,0 +1,221 @@
+package btc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	NetworkMainnet  = "mainnet"
+	NetworkTestnet  = "testnet"
+	NetworkTestnet3 = "testnet3"
+	NetworkTestnet4 = "testnet4"
+	NetworkSignet   = "signet"
+	NetworkRegtest  = "regtest"
+
+	// testNet4Magic is the network magic of testnet4 as defined in
+	// BIP94.
+	testNet4Magic wire.BitcoinNet = 0x283f161c
+)
+
+var (
+	// ErrWrongNetwork is returned when an extended key doesn't belong to
+	// the selected network.
+	ErrWrongNetwork = errors.New("extended key is for a different network")
+
+	// extendedKeyNetworks are the groups of networks that share the same
+	// version bytes for their extended keys.
+	extendedKeyNetworks = []struct {
+		names  string
+		params *chaincfg.Params
+	}{{
+		names:  NetworkMainnet,
+		params: &chaincfg.MainNetParams,
+	}, {
+		names: fmt.Sprintf("%s, %s, %s or %s", NetworkTestnet,
+			NetworkTestnet4, NetworkSignet, NetworkRegtest),
+		params: &chaincfg.TestNet3Params,
+	}, {
+		names:  chaincfg.SimNetParams.Name,
+		params: &chaincfg.SimNetParams,
+	}}
+
+	// testNet4GenesisMsg is the message in the coinbase of the testnet4
+	// genesis block.
+	testNet4GenesisMsg = []byte("03/May/2024 000000000000000000001ebd58" +
+		"c244970b3aa9d783bb001011fbe8ea8e98e00e")
+
+	// TestNet4Params are the network parameters of testnet4 as defined in
+	// BIP94. Addresses and extended keys use the same encoding as testnet3
+	// but the genesis block and network magic are different.
+	TestNet4Params = testNet4Params()
+)
+
+// testNet4Params creates the testnet4 network parameters from the testnet3
+// parameters.
+func testNet4Params() chaincfg.Params {
+	// The coinbase of the genesis block is created like the one of all
+	// other networks, pushing the compact difficulty target, the number 4
+	// and the message.
+	sigScript := []byte{0x04, 0xff, 0xff, 0x00, 0x1d, 0x01, 0x04, 0x4c}
+	sigScript = append(sigScript, byte(len(testNet4GenesisMsg)))
+	sigScript = append(sigScript, testNet4GenesisMsg...)
+
+	// Unlike the other networks, the genesis output pays to an all zero
+	// public key.
+	pkScript := append([]byte{0x21}, make([]byte, 33)...)
+	pkScript = append(pkScript, 0xac)
+
+	coinbaseTx := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{
+				Index: wire.MaxPrevOutIndex,
+			},
+			SignatureScript: sigScript,
+			Sequence:        wire.MaxTxInSequenceNum,
+		}},
+		TxOut: []*wire.TxOut{{
+			Value:    50 * 1e8,
+			PkScript: pkScript,
+		}},
+	}
+	genesisBlock := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:    1,
+			MerkleRoot: coinbaseTx.TxHash(),
+			Timestamp:  time.Unix(1714777860, 0),
+			Bits:       0x1d00ffff,
+			Nonce:      393743547,
+		},
+		Transactions: []*wire.MsgTx{coinbaseTx},
+	}
+	genesisHash := genesisBlock.BlockHash()
+
+	params := chaincfg.TestNet3Params
+	params.Name = NetworkTestnet4
+	params.Net = testNet4Magic
+	params.DefaultPort = "48333"
+	params.DNSSeeds = []chaincfg.DNSSeed{{
+		Host:         "seed.testnet4.bitcoin.sprovoost.nl",
+		HasFiltering: true,
+	}, {
+		Host:         "seed.testnet4.wiz.biz",
+		HasFiltering: true,
+	}}
+	params.GenesisBlock = genesisBlock
+	params.GenesisHash = &genesisHash
+	params.Checkpoints = nil
+
+	return params
+}
+
+// NetworkParams returns the parameters of the network with the given name. A
+// signet challenge can only be given for signet, in which case the parameters
+// of the custom signet with that challenge are returned.
+func NetworkParams(network string, signetChallenge []byte) (*chaincfg.Params,
+	error) {
+
+	if len(signetChallenge) > 0 && network != NetworkSignet {
+		return nil, fmt.Errorf("a signet challenge can only be used "+
+			"with network %s", NetworkSignet)
+	}
+
+	switch network {
+	case NetworkMainnet, "":
+		return &chaincfg.MainNetParams, nil
+
+	case NetworkTestnet, NetworkTestnet3:
+		return &chaincfg.TestNet3Params, nil
+
+	case NetworkTestnet4:
+		return &TestNet4Params, nil
+
+	case NetworkSignet:
+		if len(signetChallenge) == 0 {
+			return &chaincfg.SigNetParams, nil
+		}
+
+		params := chaincfg.CustomSignetParams(signetChallenge, nil)
+		return &params, nil
+
+	case NetworkRegtest:
+		return &chaincfg.RegressionNetParams, nil
+
+	default:
+		return nil, fmt.Errorf("unknown network %s, must be one of "+
+			"%s, %s, %s, %s or %s", network, NetworkMainnet,
+			NetworkTestnet, NetworkTestnet4, NetworkSignet,
+			NetworkRegtest)
+	}
+}
+
+// IsSignet returns true if the given parameters are the ones of the default
+// signet or of a custom signet. Custom signets have their own network magic,
+// so only the name can be compared.
+func IsSignet(params *chaincfg.Params) bool {
+	return params.Name == chaincfg.SigNetParams.Name
+}
+
+// bitcoinCliNetworkFlag returns the bitcoin-cli flag that selects the given
+// network, including a leading space, or an empty string for mainnet.
+func bitcoinCliNetworkFlag(params *chaincfg.Params) string {
+	switch {
+	case params.Net == wire.TestNet3:
+		return " -testnet"
+
+	case params.Net == testNet4Magic:
+		return " -testnet4"
+
+	case IsSignet(params):
+		return " -signet"
+
+	case params.Net == wire.TestNet:
+		return " -regtest"
+
+	default:
+		return ""
+	}
+}
+
+// ParseExtendedKey parses the given extended key and makes sure its version
+// bytes belong to the given network. If they don't, the returned error matches
+// ErrWrongNetwork and names the network of the key, so the user knows which
+// network to select instead of getting confusing derivation results.
+func ParseExtendedKey(key string,
+	params *chaincfg.Params) (*hdkeychain.ExtendedKey, error) {
+
+	extendedKey, err := hdkeychain.NewKeyFromString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, err
+	}
+
+	if extendedKey.IsForNet(params) {
+		return extendedKey, nil
+	}
+
+	// The first four characters name the key type, for example xprv or
+	// tpub.
+	keyType := extendedKey.String()[:4]
+	for _, network := range extendedKeyNetworks {
+		if !extendedKey.IsForNet(network.params) {
+			continue
+		}
+
+		return nil, fmt.Errorf("%w: the %s key is for %s but the "+
+			"selected network is %s, select the key's network "+
+			"with --network", ErrWrongNetwork, keyType,
+			network.names, params.Name)
+	}
+
+	return nil, fmt.Errorf("%w: the %s key has the unknown version %x, "+
+		"SLIP-0132 keys (like zprv or vpub) must be converted to the "+
+		"standard xprv/xpub or tprv/tpub format first",
+		ErrWrongNetwork, keyType, extendedKey.Version())
+}
@@ -0,0 +1,169 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestNet4Params(t *testing.T) {
+	// Genesis block hash and merkle root as defined in BIP94.
+	require.Equal(
+		t, "00000000da84f2bafbbc53dee25a72ae507ff4914b867c565be350b0"+
+			"da8bf043", TestNet4Params.GenesisHash.String(),
+	)
+	require.Equal(
+		t, "7aa0a7ae1e223414cb807e40cd57e667b718e42aaf9306db9102fe28"+
+			"912b7b4e",
+		TestNet4Params.GenesisBlock.Header.MerkleRoot.String(),
+	)
+
+	// Changing the copy must not change the testnet3 parameters.
+	require.Equal(t, "testnet3", chaincfg.TestNet3Params.Name)
+	require.NotEqual(t, TestNet4Params.Net, chaincfg.TestNet3Params.Net)
+}
+
+func TestNetworkParams(t *testing.T) {
+	testCases := []struct {
+		network   string
+		challenge string
+		params    *chaincfg.Params
+		err       string
+	}{{
+		network: "",
+		params:  &chaincfg.MainNetParams,
+	}, {
+		network: NetworkMainnet,
+		params:  &chaincfg.MainNetParams,
+	}, {
+		network: NetworkTestnet,
+		params:  &chaincfg.TestNet3Params,
+	}, {
+		network: NetworkTestnet3,
+		params:  &chaincfg.TestNet3Params,
+	}, {
+		network: NetworkTestnet4,
+		params:  &TestNet4Params,
+	}, {
+		network: NetworkSignet,
+		params:  &chaincfg.SigNetParams,
+	}, {
+		network: NetworkRegtest,
+		params:  &chaincfg.RegressionNetParams,
+	}, {
+		network:   NetworkTestnet,
+		challenge: "51",
+		err:       "can only be used with network signet",
+	}, {
+		network: "simnet",
+		err:     "unknown network simnet",
+	}}
+
+	for _, tc := range testCases {
+		challenge, err := hex.DecodeString(tc.challenge)
+		require.NoError(t, err)
+
+		params, err := NetworkParams(tc.network, challenge)
+		if tc.err != "" {
+			require.ErrorContains(t, err, tc.err)
+			continue
+		}
+
+		require.NoError(t, err)
+		require.Equal(t, tc.params, params)
+	}
+
+	// A custom signet has its own network magic but uses the same address
+	// encoding as the default signet.
+	params, err := NetworkParams(NetworkSignet, []byte{0x51})
+	require.NoError(t, err)
+	require.True(t, IsSignet(params))
+	require.NotEqual(t, chaincfg.SigNetParams.Net, params.Net)
+	require.Equal(t, "tb", params.Bech32HRPSegwit)
+}
+
+func TestNetworkEncoding(t *testing.T) {
+	seed := make([]byte, hdkeychain.RecommendedSeedLen)
+	pubKeyHash := make([]byte, 20)
+
+	for _, params := range []*chaincfg.Params{
+		&TestNet4Params, &chaincfg.SigNetParams,
+	} {
+		key, err := hdkeychain.NewMaster(seed, params)
+		require.NoError(t, err)
+		require.Equal(t, "tprv", key.String()[:4])
+
+		addr, err := btcutil.NewAddressWitnessPubKeyHash(
+			pubKeyHash, params,
+		)
+		require.NoError(t, err)
+		require.Equal(t, "tb1q", addr.String()[:4])
+	}
+
+	require.Equal(t, " -testnet4", bitcoinCliNetworkFlag(&TestNet4Params))
+	require.Equal(
+		t, " -signet", bitcoinCliNetworkFlag(&chaincfg.SigNetParams),
+	)
+	require.Equal(
+		t, " -regtest",
+		bitcoinCliNetworkFlag(&chaincfg.RegressionNetParams),
+	)
+	require.Empty(t, bitcoinCliNetworkFlag(&chaincfg.MainNetParams))
+}
+
+func TestParseExtendedKey(t *testing.T) {
+	seed := bytes.Repeat([]byte{0x42}, 32)
+	mainnetKey, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	require.NoError(t, err)
+	testnetKey, err := hdkeychain.NewMaster(seed, &chaincfg.TestNet3Params)
+	require.NoError(t, err)
+
+	key, err := ParseExtendedKey(
+		" "+mainnetKey.String()+"\n", &chaincfg.MainNetParams,
+	)
+	require.NoError(t, err)
+	require.Equal(t, mainnetKey.String(), key.String())
+
+	// All test networks share the same version bytes.
+	for _, params := range []*chaincfg.Params{
+		&chaincfg.TestNet3Params, &TestNet4Params,
+		&chaincfg.SigNetParams, &chaincfg.RegressionNetParams,
+	} {
+		_, err := ParseExtendedKey(testnetKey.String(), params)
+		require.NoError(t, err)
+	}
+
+	_, err = ParseExtendedKey(mainnetKey.String(), &chaincfg.TestNet3Params)
+	require.ErrorIs(t, err, ErrWrongNetwork)
+	require.ErrorContains(
+		t, err, "the xprv key is for mainnet but the selected network "+
+			"is testnet3",
+	)
+
+	testnetPub, err := testnetKey.Neuter()
+	require.NoError(t, err)
+	_, err = ParseExtendedKey(testnetPub.String(), &chaincfg.MainNetParams)
+	require.ErrorIs(t, err, ErrWrongNetwork)
+	require.ErrorContains(
+		t, err, "the tpub key is for testnet, testnet4, signet or "+
+			"regtest but the selected network is mainnet",
+	)
+
+	// A SLIP-0132 zprv has a version that doesn't belong to any network.
+	zprv, err := mainnetKey.CloneWithVersion([]byte{
+		0x04, 0xb2, 0x43, 0x0c,
+	})
+	require.NoError(t, err)
+	_, err = ParseExtendedKey(zprv.String(), &chaincfg.MainNetParams)
+	require.ErrorIs(t, err, ErrWrongNetwork)
+	require.ErrorContains(t, err, "the zprv key has the unknown version")
+
+	_, err = ParseExtendedKey("xprv-invalid", &chaincfg.MainNetParams)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrWrongNetwork)
+}
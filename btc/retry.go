@@ -0,0 +1,248 @@
+package btc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btclog"
+)
+
+const (
+	// DefaultRetries is the default number of times a failed chain
+	// backend call is retried.
+	DefaultRetries = 3
+
+	// DefaultInitialBackoff is the default time to wait before the first
+	// retry. The wait time is doubled for every further retry.
+	DefaultInitialBackoff = time.Second
+
+	// maxBackoff is the maximum time to wait between two retries.
+	maxBackoff = time.Minute
+)
+
+var (
+	// ErrTimeout is returned when a chain backend call didn't return
+	// within the configured timeout.
+	ErrTimeout = errors.New("chain backend call timed out")
+)
+
+// RetryBackend is a chain backend that wraps another backend and retries
+// calls that failed with a transient error, like a timeout or a server error,
+// with an exponential backoff. Errors that won't go away by retrying, like a
+// transaction that isn't known or an error returned by bitcoind for the
+// request itself, are returned immediately.
+type RetryBackend struct {
+	// Backend is the chain backend that is wrapped.
+	Backend ChainBackend
+
+	// Retries is the number of times a failed call is retried.
+	Retries int
+
+	// Timeout is the maximum time a single call may take. Zero means no
+	// timeout.
+	Timeout time.Duration
+
+	// InitialBackoff is the time to wait before the first retry. If not
+	// set, DefaultInitialBackoff is used.
+	InitialBackoff time.Duration
+
+	// Ctx aborts all pending calls and retries when canceled. If not set,
+	// calls can't be canceled.
+	Ctx context.Context
+
+	// Log is used to log the retries at the debug level.
+	Log btclog.Logger
+}
+
+var _ ChainBackend = (*RetryBackend)(nil)
+
+// outpointResult is the result of the Outpoint call.
+type outpointResult struct {
+	tx    *TX
+	index int
+}
+
+func (r *RetryBackend) Transaction(txid string) (*TX, error) {
+	result, err := r.call("Transaction", func() (interface{}, error) {
+		return r.Backend.Transaction(txid)
+	})
+	tx, _ := result.(*TX)
+	return tx, err
+}
+
+func (r *RetryBackend) RawTransaction(txid string) (string, error) {
+	result, err := r.call("RawTransaction", func() (interface{}, error) {
+		return r.Backend.RawTransaction(txid)
+	})
+	rawTx, _ := result.(string)
+	return rawTx, err
+}
+
+func (r *RetryBackend) TxStatus(txid string) (*Status, error) {
+	result, err := r.call("TxStatus", func() (interface{}, error) {
+		return r.Backend.TxStatus(txid)
+	})
+	status, _ := result.(*Status)
+	return status, err
+}
+
+func (r *RetryBackend) BlockHeight() (uint32, error) {
+	result, err := r.call("BlockHeight", func() (interface{}, error) {
+		return r.Backend.BlockHeight()
+	})
+	height, _ := result.(uint32)
+	return height, err
+}
+
+func (r *RetryBackend) FeeEstimate(confTarget uint32) (float64, error) {
+	result, err := r.call("FeeEstimate", func() (interface{}, error) {
+		return r.Backend.FeeEstimate(confTarget)
+	})
+	feeRate, _ := result.(float64)
+	return feeRate, err
+}
+
+func (r *RetryBackend) Outpoint(addr string) (*TX, int, error) {
+	result, err := r.call("Outpoint", func() (interface{}, error) {
+		tx, index, err := r.Backend.Outpoint(addr)
+		return &outpointResult{tx: tx, index: index}, err
+	})
+	outpoint, ok := result.(*outpointResult)
+	if !ok {
+		return nil, 0, err
+	}
+	return outpoint.tx, outpoint.index, err
+}
+
+func (r *RetryBackend) Unspent(addr string) ([]*Vout, error) {
+	result, err := r.call("Unspent", func() (interface{}, error) {
+		return r.Backend.Unspent(addr)
+	})
+	unspent, _ := result.([]*Vout)
+	return unspent, err
+}
+
+func (r *RetryBackend) Address(outpoint string) (string, error) {
+	result, err := r.call("Address", func() (interface{}, error) {
+		return r.Backend.Address(outpoint)
+	})
+	addr, _ := result.(string)
+	return addr, err
+}
+
+func (r *RetryBackend) AddressUsed(addr string) (bool, error) {
+	result, err := r.call("AddressUsed", func() (interface{}, error) {
+		return r.Backend.AddressUsed(addr)
+	})
+	used, _ := result.(bool)
+	return used, err
+}
+
+func (r *RetryBackend) PublishTx(rawTxHex string) (string, error) {
+	result, err := r.call("PublishTx", func() (interface{}, error) {
+		return r.Backend.PublishTx(rawTxHex)
+	})
+	txid, _ := result.(string)
+	return txid, err
+}
+
+// call executes the given call and retries it with an exponential backoff as
+// long as it fails with a transient error and retries are left.
+func (r *RetryBackend) call(name string,
+	fn func() (interface{}, error)) (interface{}, error) {
+
+	ctx := r.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	backoff := r.InitialBackoff
+	if backoff == 0 {
+		backoff = DefaultInitialBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		result, err := r.attempt(ctx, fn)
+		if err == nil || attempt >= r.Retries ||
+			!isTransient(ctx, err) {
+
+			return result, err
+		}
+
+		if r.Log != nil {
+			r.Log.Debugf("Chain backend call %s failed (attempt "+
+				"%d of %d), retrying in %v: %v", name,
+				attempt+1, r.Retries+1, backoff, err)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// attempt executes the given call once. The call is abandoned if it takes
+// longer than the timeout or if the context is canceled.
+func (r *RetryBackend) attempt(ctx context.Context,
+	fn func() (interface{}, error)) (interface{}, error) {
+
+	if r.Timeout == 0 && ctx.Done() == nil {
+		return fn()
+	}
+
+	type callResult struct {
+		value interface{}
+		err   error
+	}
+
+	// The channel is buffered so an abandoned call doesn't block forever.
+	resultChan := make(chan *callResult, 1)
+	go func() {
+		value, err := fn()
+		resultChan <- &callResult{value: value, err: err}
+	}()
+
+	var timeout <-chan time.Time
+	if r.Timeout > 0 {
+		timer := time.NewTimer(r.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case result := <-resultChan:
+		return result.value, result.err
+
+	case <-timeout:
+		return nil, fmt.Errorf("%w after %v", ErrTimeout, r.Timeout)
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// isTransient returns true if the given error might go away when the call is
+// retried.
+func isTransient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrTxNotFound) {
+		return false
+	}
+
+	// An error returned by bitcoind means the node processed the request,
+	// so retrying won't help unless the node is still starting up.
+	var rpcErr *btcjson.RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code == btcjson.ErrRPCInWarmup
+	}
+
+	return true
+}
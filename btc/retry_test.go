@@ -0,0 +1,91 @@
+package btc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryBackend(t *testing.T) {
+	var numRequests int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			num := atomic.AddInt32(&numRequests, 1)
+
+			switch r.URL.Path {
+			case "/blocks/tip/height":
+				// The first two requests fail with a server
+				// error.
+				if num <= 2 {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				_, _ = w.Write([]byte("1234"))
+
+			case "/tx/slow/hex":
+				time.Sleep(200 * time.Millisecond)
+				_, _ = w.Write([]byte("0200"))
+
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte("Transaction not found"))
+			}
+		},
+	))
+	defer server.Close()
+
+	backend := &RetryBackend{
+		Backend:        &ExplorerAPI{BaseURL: server.URL},
+		Retries:        2,
+		InitialBackoff: time.Millisecond,
+	}
+
+	// Transient errors are retried until the call succeeds.
+	height, err := backend.BlockHeight()
+	require.NoError(t, err)
+	require.EqualValues(t, 1234, height)
+	require.EqualValues(t, 3, atomic.LoadInt32(&numRequests))
+
+	// An unknown transaction is not retried.
+	atomic.StoreInt32(&numRequests, 0)
+	_, err = backend.RawTransaction("abcd")
+	require.ErrorIs(t, err, ErrTxNotFound)
+	require.EqualValues(t, 1, atomic.LoadInt32(&numRequests))
+
+	// A call that takes too long is aborted and retried.
+	atomic.StoreInt32(&numRequests, 0)
+	backend.Timeout = 50 * time.Millisecond
+	_, err = backend.RawTransaction("slow")
+	require.ErrorIs(t, err, ErrTimeout)
+	require.EqualValues(t, 3, atomic.LoadInt32(&numRequests))
+
+	// A canceled context stops the retries.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	backend.Ctx = ctx
+	_, err = backend.RawTransaction("slow")
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestIsTransient(t *testing.T) {
+	ctx := context.Background()
+
+	require.True(t, isTransient(ctx, ErrTimeout))
+	require.False(t, isTransient(ctx, ErrTxNotFound))
+	require.False(t, isTransient(ctx, &btcjson.RPCError{
+		Code: btcjson.ErrRPCInvalidParameter,
+	}))
+	require.True(t, isTransient(ctx, &btcjson.RPCError{
+		Code: btcjson.ErrRPCInWarmup,
+	}))
+
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	require.False(t, isTransient(canceledCtx, ErrTimeout))
+}
@@ -0,0 +1,96 @@
+package btc
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/guggero/chantools/slip39"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	SLIP39SharesEnvName     = "SEED_SLIP39_SHARES"
+	SLIP39PassphraseEnvName = "SEED_PASSPHRASE"
+)
+
+// ReadSlip39SharesWithSecrets derives the root key of the SLIP-0039 master
+// secret that is recovered from the given shares and passphrase. The shares are
+// separated by new lines or commas. If the shares or the passphrase are empty,
+// they are read from the terminal. A passphrase of a single dash (-) means no
+// passphrase is used.
+func ReadSlip39SharesWithSecrets(params *chaincfg.Params, sharesStr,
+	passphrase string) (*hdkeychain.ExtendedKey, error) {
+
+	shares := splitSlip39Shares(sharesStr)
+	if len(shares) == 0 {
+		// If there's no value in the environment, we'll now prompt the
+		// user to enter their shares one by one.
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			fmt.Printf("Input SLIP-0039 share %d (press enter "+
+				"without a share when done): ", len(shares)+1)
+			share, err := reader.ReadString('\n')
+			if err != nil {
+				return nil, err
+			}
+			fmt.Println()
+
+			share = strings.TrimSpace(share)
+			if share == "" {
+				break
+			}
+
+			// Parse the share right away so a typo doesn't go
+			// unnoticed until all shares are entered.
+			if _, err := slip39.ParseShare(share); err != nil {
+				return nil, fmt.Errorf("error parsing share "+
+					"%d: %w", len(shares)+1, err)
+			}
+			shares = append(shares, share)
+		}
+	}
+
+	passphrase = strings.TrimSpace(passphrase)
+	switch passphrase {
+	// The user indicated in the environment variable that no passphrase
+	// should be used.
+	case "-":
+		passphrase = ""
+
+	// The environment variable didn't contain anything, we'll read the
+	// passphrase from the terminal.
+	case "":
+		fmt.Printf("Input your SLIP-0039 passphrase (press enter if " +
+			"your shares don't have a passphrase): ")
+		passphraseBytes, err := terminal.ReadPassword(
+			int(syscall.Stdin), // nolint
+		)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println()
+		passphrase = string(passphraseBytes)
+	}
+
+	return slip39.MasterKeyFromShares(shares, passphrase, params)
+}
+
+// splitSlip39Shares splits a list of shares separated by new lines or commas.
+func splitSlip39Shares(sharesStr string) []string {
+	var shares []string
+	for _, share := range strings.FieldsFunc(sharesStr, func(r rune) bool {
+		return r == '\n' || r == ','
+	}) {
+		share = strings.TrimSpace(share)
+		if share != "" {
+			shares = append(shares, share)
+		}
+	}
+
+	return shares
+}
@@ -58,6 +58,22 @@ func SummarizeChannels(apiURL string, channels []*dataformat.SummaryEntry,
 		}
 	}
 
+	// The maturity of force closed channels can only be reported if we
+	// know the current best block.
+	if summaryFile.ForceClosedChannels > 0 {
+		height, err := api.BlockHeight()
+		if err != nil {
+			log.Warnf("Could not fetch current block height, not "+
+				"reporting CSV maturity: %v", err)
+			return summaryFile, nil
+		}
+
+		summaryFile.BlockHeight = height
+		for _, channel := range channels {
+			channel.UpdateMaturity(height)
+		}
+	}
+
 	return summaryFile, nil
 }
 
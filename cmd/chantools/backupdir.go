@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/chanbackup"
+	"github.com/lightningnetwork/lnd/keychain"
+)
+
+// extractMulti reads the multi backup of the given channel.backup file. If a
+// directory is given instead, all channel backup files in it are merged into
+// one multi backup.
+func extractMulti(fileName string,
+	ring keychain.KeyRing) (*chanbackup.Multi, error) {
+
+	info, err := os.Stat(fileName)
+	if err == nil && info.IsDir() {
+		return extractMultiFromDir(fileName, ring)
+	}
+
+	multiFile := chanbackup.NewMultiFile(fileName)
+	multi, err := multiFile.ExtractMulti(ring)
+	if err != nil {
+		return nil, fmt.Errorf("could not extract multi file: %w", err)
+	}
+
+	return multi, nil
+}
+
+// extractMultiFromDir merges all channel backup files in the given directory
+// into one multi backup and reports the files that could not be decrypted.
+func extractMultiFromDir(dir string,
+	ring keychain.KeyRing) (*chanbackup.Multi, error) {
+
+	result, err := lnd.ReadBackupDir(dir, ring)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Found %d file(s) in backup directory %s, %d of them "+
+		"could be decrypted, %d channel(s) in total", result.NumFiles,
+		dir, result.NumFiles-len(result.FailedFiles),
+		len(result.Multi.StaticBackups))
+
+	failedFiles := make([]string, 0, len(result.FailedFiles))
+	for fileName := range result.FailedFiles {
+		failedFiles = append(failedFiles, fileName)
+	}
+	sort.Strings(failedFiles)
+	for _, fileName := range failedFiles {
+		log.Warnf("Could not decrypt backup file %s: %v", fileName,
+			result.FailedFiles[fileName])
+	}
+
+	if len(result.Multi.StaticBackups) == 0 {
+		return nil, fmt.Errorf("no channel backup found in directory "+
+			"%s", dir)
+	}
+
+	return result.Multi, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/chanbackup"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractMultiFromDir(t *testing.T) {
+	h := newHarness(t)
+
+	csvContent := testFakeNodePubKey + "," + testFakeTXID + ",0,100000," +
+		"legacy,566222x300x0\n" +
+		testFakeNodePubKey + "," + testFakeTXID + ",1,200000," +
+		"anchors,566222x301x1\n"
+	singles, err := singlesFromCSV([]byte(csvContent))
+	require.NoError(t, err)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	keyRing := &lnd.HDKeyRing{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+
+	backupDir := h.tempFile("backups")
+	require.NoError(t, os.Mkdir(backupDir, 0700))
+	require.NoError(t, os.Mkdir(backupDir+"/subdir", 0700))
+
+	// A multi backup with both channels and a hex encoded single backup of
+	// the second channel, which must only be added once.
+	multiFile := chanbackup.NewMultiFile(backupDir + "/channel.backup")
+	require.NoError(t, writeBackups(singles, keyRing, multiFile))
+
+	var b bytes.Buffer
+	require.NoError(t, singles[1].PackToWriter(&b, keyRing))
+	err = ioutil.WriteFile(
+		backupDir+"/single.backup",
+		[]byte(hex.EncodeToString(b.Bytes())+"\n"), 0600,
+	)
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(
+		backupDir+"/garbage.backup", []byte("garbage"), 0600,
+	)
+	require.NoError(t, err)
+
+	multi, err := extractMulti(backupDir, keyRing)
+	require.NoError(t, err)
+	require.Len(t, multi.StaticBackups, 2)
+	require.Equal(
+		t, testFakeTXID+":0",
+		multi.StaticBackups[0].FundingOutpoint.String(),
+	)
+	require.Equal(
+		t, testFakeTXID+":1",
+		multi.StaticBackups[1].FundingOutpoint.String(),
+	)
+
+	h.assertLogContains("Found 3 file(s) in backup directory")
+	h.assertLogContains("2 of them could be decrypted, 2 channel(s) in " +
+		"total")
+	h.assertLogContains("Could not decrypt backup file garbage.backup")
+
+	// A single file is still read as a multi backup.
+	multi, err = extractMulti(backupDir+"/channel.backup", keyRing)
+	require.NoError(t, err)
+	require.Len(t, multi.StaticBackups, 2)
+
+	// A directory without any valid backup is an error.
+	require.NoError(t, os.Remove(backupDir+"/channel.backup"))
+	require.NoError(t, os.Remove(backupDir+"/single.backup"))
+	_, err = extractMulti(backupDir, keyRing)
+	require.ErrorContains(t, err, "no channel backup found")
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/spf13/cobra"
+)
+
+type bumpFeeCommand struct {
+	APIURL         string
+	Publish        bool
+	DryRun         bool
+	SweepTx        string
+	FeeRate        uint16
+	ConfTarget     uint32
+	RecoveryWindow uint32
+
+	rootKey *rootKey
+	inputs  *inputFlags
+	cmd     *cobra.Command
+}
+
+func newBumpFeeCommand() *cobra.Command {
+	cc := &bumpFeeCommand{}
+	cc.cmd = &cobra.Command{
+		Use: "bumpfee",
+		Short: "Replace a sweep transaction with one that pays a " +
+			"higher fee",
+		Long: `This command replaces a sweep transaction that was
+created with the sweepremoteclosed or sweeptimelock command and that is stuck
+because of a too low fee rate with a new transaction that pays a higher fee
+(BIP125 replace-by-fee).
+
+The new transaction spends exactly the same inputs and sends the funds to the
+same destination as the original transaction, only the fee is increased. The
+original transaction must signal replace-by-fee. For sweepremoteclosed this
+means it must have been created with the --rbf flag, sweeps of time locked
+outputs always signal replace-by-fee.
+
+To re-sign time locked outputs that were swept with the sweeptimelock command,
+the same channel input file (for example --fromsummary) must be specified.
+Transactions created with the sweeptimelockmanual command can't be bumped with
+this command, just run sweeptimelockmanual again with a higher --feerate.`,
+		Example: `chantools bumpfee \
+	--sweeptx 0200000000010... \
+	--feerate 50
+
+chantools bumpfee \
+	--sweeptx abcdef0123... \
+	--fromsummary results/forceclose-xxxx-yyyy.json \
+	--conftarget 2 \
+	--publish`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
+			"be esplora compatible)",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.Publish, "publish", false, "publish the replacement TX to "+
+			"the chain API instead of just printing the TX",
+	)
+	addDryRunFlag(cc.cmd, &cc.DryRun)
+	cc.cmd.Flags().StringVar(
+		&cc.SweepTx, "sweeptx", "", "the sweep transaction to "+
+			"replace, either as raw hex or its TXID to fetch it "+
+			"from the chain API",
+	)
+	cc.cmd.Flags().Uint16Var(
+		&cc.FeeRate, "feerate", defaultFeeSatPerVByte, "new fee rate "+
+			"to use for the replacement transaction in sat/vByte",
+	)
+	addConfTargetFlag(cc.cmd, &cc.ConfTarget)
+	cc.cmd.Flags().Uint32Var(
+		&cc.RecoveryWindow, "recoverywindow",
+		sweepRemoteClosedDefaultRecoveryWindow, "number of keys to "+
+			"scan per derivation path when looking for the keys "+
+			"of the inputs",
+	)
+
+	cc.rootKey = newRootKey(cc.cmd, "signing the replacement")
+	cc.inputs = newInputFlags(cc.cmd)
+
+	return cc.cmd
+}
+
+func (c *bumpFeeCommand) Execute(_ *cobra.Command, _ []string) error {
+	extendedKey, err := c.rootKey.read()
+	if err != nil {
+		return fmt.Errorf("error reading root key: %w", err)
+	}
+
+	if c.SweepTx == "" {
+		return fmt.Errorf("sweep TX is required")
+	}
+
+	api, err := newChainBackend(c.APIURL)
+	if err != nil {
+		return err
+	}
+	sweepTx, err := parseSweepTx(api, c.SweepTx)
+	if err != nil {
+		return err
+	}
+
+	// The time locked outputs can only be re-signed with the information
+	// from the channel input file.
+	var timeLockTargets []*sweepTarget
+	if c.inputs.isSet() {
+		entries, err := c.inputs.parseInputType()
+		if err != nil {
+			return err
+		}
+
+		timeLockTargets, err = sweepTargetsFromSummary(entries)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Set default values.
+	if c.RecoveryWindow == 0 {
+		c.RecoveryWindow = sweepRemoteClosedDefaultRecoveryWindow
+	}
+	if c.FeeRate == 0 {
+		c.FeeRate = defaultFeeSatPerVByte
+	}
+	c.FeeRate = sweepFeeRate(c.APIURL, c.ConfTarget, c.FeeRate)
+
+	publish := shouldPublish(c.Publish, c.DryRun, c.APIURL)
+	return bumpFee(
+		extendedKey, api, sweepTx, timeLockTargets, c.RecoveryWindow,
+		c.FeeRate, publish,
+	)
+}
+
+// parseSweepTx parses the given raw transaction hex or, if a TXID is given,
+// fetches the raw transaction from the chain API first.
+func parseSweepTx(api btc.ChainBackend, txStr string) (*wire.MsgTx, error) {
+	if len(txStr) == chainhash.MaxHashStringSize {
+		txHash, err := chainhash.NewHashFromStr(txStr)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing TXID: %w", err)
+		}
+
+		txStr, err = api.RawTransaction(txHash.String())
+		if err != nil {
+			return nil, fmt.Errorf("error fetching TX %v: %w",
+				txHash, err)
+		}
+	}
+
+	txBytes, err := hex.DecodeString(txStr)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding TX hex: %w", err)
+	}
+
+	tx := &wire.MsgTx{}
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, fmt.Errorf("error parsing TX: %w", err)
+	}
+
+	return tx, nil
+}
+
+// signalsRBF returns true if at least one of the inputs of the transaction
+// signals replace-by-fee as defined in BIP125.
+func signalsRBF(tx *wire.MsgTx) bool {
+	for _, txIn := range tx.TxIn {
+		if txIn.Sequence <= rbfSequence {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bumpInput is an input of the original sweep transaction together with the
+// information needed to sign it again.
+type bumpInput struct {
+	signDesc *input.SignDescriptor
+	witness  func(signer input.Signer, desc *input.SignDescriptor,
+		tx *wire.MsgTx) (wire.TxWitness, error)
+}
+
+func bumpFee(extendedKey *hdkeychain.ExtendedKey, api btc.ChainBackend,
+	sweepTx *wire.MsgTx, timeLockTargets []*sweepTarget,
+	recoveryWindow uint32, feeRate uint16, publish bool) error {
+
+	if len(sweepTx.TxOut) != 1 {
+		return fmt.Errorf("sweep TX must have exactly one output, got "+
+			"%d", len(sweepTx.TxOut))
+	}
+	if !signalsRBF(sweepTx) {
+		return fmt.Errorf("sweep TX %v doesn't signal replace-by-fee",
+			sweepTx.TxHash())
+	}
+
+	var (
+		estimator  input.TxWeightEstimator
+		inputs     = make([]*bumpInput, len(sweepTx.TxIn))
+		totalValue int64
+		keyRing    = &lnd.HDKeyRing{
+			ExtendedKey: extendedKey,
+			ChainParams: chainParams,
+		}
+	)
+	for idx, txIn := range sweepTx.TxIn {
+		prevOut, err := fetchPrevOut(api, txIn.PreviousOutPoint)
+		if err != nil {
+			return err
+		}
+
+		bumpIn, err := bumpInputFor(
+			keyRing, txIn, prevOut, timeLockTargets, recoveryWindow,
+			&estimator,
+		)
+		if err != nil {
+			return fmt.Errorf("error preparing input %d: %w", idx,
+				err)
+		}
+
+		inputs[idx] = bumpIn
+		totalValue += prevOut.Value
+	}
+	estimator.AddTxOutput(sweepTx.TxOut[0])
+
+	// The replacement must pay a higher absolute fee than the original
+	// transaction plus the fee for its own size at the minimum relay fee
+	// rate.
+	oldFee := totalValue - sweepTx.TxOut[0].Value
+	feeRateKWeight := chainfee.SatPerKVByte(1000 * feeRate).FeePerKWeight()
+	newFee := int64(feeRateKWeight.FeeForWeight(int64(estimator.Weight())))
+	minFee := oldFee + int64(estimator.VSize())
+	if newFee < minFee {
+		return fmt.Errorf("new fee of %d sats is not enough to "+
+			"replace the original fee of %d sats, need at least "+
+			"%d sats, increase the fee rate", newFee, oldFee,
+			minFee)
+	}
+	if totalValue-newFee < sweepDustLimit {
+		return fmt.Errorf("new fee of %d sats would leave an output "+
+			"below the dust limit of %d", newFee, sweepDustLimit)
+	}
+
+	log.Infof("Replacing fee of %d sats with %d sats of %d total amount "+
+		"(estimated weight %d)", oldFee, newFee, totalValue,
+		estimator.Weight())
+
+	// The replacement spends the same inputs with the same sequence (and
+	// therefore CSV) values to the same destination.
+	replacementTx := wire.NewMsgTx(sweepTx.Version)
+	replacementTx.LockTime = sweepTx.LockTime
+	for _, txIn := range sweepTx.TxIn {
+		replacementTx.TxIn = append(replacementTx.TxIn, &wire.TxIn{
+			PreviousOutPoint: txIn.PreviousOutPoint,
+			Sequence:         txIn.Sequence,
+		})
+	}
+	replacementTx.TxOut = []*wire.TxOut{{
+		Value:    totalValue - newFee,
+		PkScript: sweepTx.TxOut[0].PkScript,
+	}}
+
+	signDescs := make([]*input.SignDescriptor, len(inputs))
+	for idx, bumpIn := range inputs {
+		signDescs[idx] = bumpIn.signDesc
+	}
+	err := validateSweepTx(
+		api, replacementTx, signDescs, int64(estimator.VSize()),
+		feeRate,
+	)
+	if err != nil {
+		return fmt.Errorf("error validating replacement TX: %w", err)
+	}
+
+	// Sign the transaction now.
+	var (
+		signer = &lnd.Signer{
+			ExtendedKey: extendedKey,
+			ChainParams: chainParams,
+		}
+		prevOuts  = lnd.PrevOutputFetcher(replacementTx, signDescs)
+		sigHashes = txscript.NewTxSigHashes(replacementTx, prevOuts)
+	)
+	for idx, bumpIn := range inputs {
+		bumpIn.signDesc.SigHashes = sigHashes
+		bumpIn.signDesc.PrevOutputFetcher = prevOuts
+		bumpIn.signDesc.InputIndex = idx
+		witness, err := bumpIn.witness(
+			signer, bumpIn.signDesc, replacementTx,
+		)
+		if err != nil {
+			return err
+		}
+		replacementTx.TxIn[idx].Witness = witness
+	}
+
+	return publishTx(api, replacementTx, newFee, publish)
+}
+
+// fetchPrevOut looks up the output the given outpoint spends.
+func fetchPrevOut(api btc.ChainBackend, op wire.OutPoint) (*wire.TxOut,
+	error) {
+
+	tx, err := api.Transaction(op.Hash.String())
+	if err != nil {
+		return nil, fmt.Errorf("error fetching TX %v: %w", op.Hash, err)
+	}
+	if int(op.Index) >= len(tx.Vout) {
+		return nil, fmt.Errorf("invalid output index %d of TX %v",
+			op.Index, op.Hash)
+	}
+
+	vout := tx.Vout[op.Index]
+	pkScript, err := hex.DecodeString(vout.ScriptPubkey)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding pk script: %w", err)
+	}
+
+	return &wire.TxOut{
+		Value:    int64(vout.Value),
+		PkScript: pkScript,
+	}, nil
+}
+
+// bumpInputFor identifies the type of output the given input of a sweep
+// transaction spends by looking at its witness and returns the information
+// required to sign it again. The weight of the input is added to the
+// estimator.
+func bumpInputFor(keyRing *lnd.HDKeyRing, txIn *wire.TxIn,
+	prevOut *wire.TxOut, timeLockTargets []*sweepTarget,
+	recoveryWindow uint32,
+	estimator *input.TxWeightEstimator) (*bumpInput, error) {
+
+	witness := txIn.Witness
+	switch {
+	// A P2WKH output of a channel without anchors that was swept by the
+	// sweepremoteclosed command. The witness is <sig> <pubkey>.
+	case txscript.IsPayToWitnessPubKeyHash(prevOut.PkScript) &&
+		len(witness) == 2:
+
+		keyDesc, err := findPaymentBaseKey(
+			keyRing, witness[1], recoveryWindow,
+		)
+		if err != nil {
+			return nil, err
+		}
+		estimator.AddP2WKHInput()
+
+		// The txscript library expects the witness script of a P2WKH
+		// descriptor to be set to the pkScript of the output...
+		return &bumpInput{
+			signDesc: &input.SignDescriptor{
+				KeyDesc:       *keyDesc,
+				WitnessScript: prevOut.PkScript,
+				Output:        prevOut,
+				HashType:      txscript.SigHashAll,
+			},
+			witness: func(signer input.Signer,
+				desc *input.SignDescriptor,
+				tx *wire.MsgTx) (wire.TxWitness, error) {
+
+				return input.CommitSpendNoDelay(
+					signer, desc, tx, true,
+				)
+			},
+		}, nil
+
+	// The to_remote output of an anchor channel that was swept by the
+	// sweepremoteclosed command. The witness is <sig> <script> and the
+	// script starts with the push of our payment base key.
+	case txscript.IsPayToWitnessScriptHash(prevOut.PkScript) &&
+		len(witness) == 2:
+
+		script := witness[1]
+		if len(script) < 34 || script[0] != txscript.OP_DATA_33 {
+			return nil, fmt.Errorf("unknown witness script %x",
+				script)
+		}
+		keyDesc, err := findPaymentBaseKey(
+			keyRing, script[1:34], recoveryWindow,
+		)
+		if err != nil {
+			return nil, err
+		}
+		estimator.AddWitnessInput(input.ToRemoteConfirmedWitnessSize)
+
+		return &bumpInput{
+			signDesc: &input.SignDescriptor{
+				KeyDesc:       *keyDesc,
+				WitnessScript: script,
+				Output:        prevOut,
+				HashType:      txscript.SigHashAll,
+			},
+			witness: input.CommitSpendToRemoteConfirmed,
+		}, nil
+
+	// The time locked to_local output of a commitment transaction that
+	// was swept by the sweeptimelock command. The witness is
+	// <sig> <empty> <script>.
+	case txscript.IsPayToWitnessScriptHash(prevOut.PkScript) &&
+		len(witness) == 3:
+
+		target, err := findTimeLockTarget(
+			timeLockTargets, txIn.PreviousOutPoint,
+		)
+		if err != nil {
+			return nil, err
+		}
+		estimator.AddWitnessInput(input.ToLocalTimeoutWitnessSize)
+
+		return &bumpInput{
+			signDesc: &input.SignDescriptor{
+				KeyDesc: *target.delayBasePointDesc,
+				SingleTweak: input.SingleTweakBytes(
+					target.commitPoint,
+					target.delayBasePointDesc.PubKey,
+				),
+				WitnessScript: witness[2],
+				Output:        prevOut,
+				HashType:      txscript.SigHashAll,
+			},
+			witness: input.CommitSpendTimeout,
+		}, nil
+
+	// The to_remote or time locked to_local output of a simple taproot
+	// channel that was swept by the sweepremoteclosed or sweeptimelock
+	// command. The witness is <sig> <script> <control block>.
+	case txscript.IsPayToTaproot(prevOut.PkScript) && len(witness) == 3:
+		return taprootBumpInput(
+			keyRing, txIn, prevOut, timeLockTargets,
+			recoveryWindow, estimator,
+		)
+
+	default:
+		return nil, fmt.Errorf("unsupported input spending %v",
+			txIn.PreviousOutPoint)
+	}
+}
+
+// taprootBumpInput returns the information required to sign the script spend of
+// a to_remote or to_local output of a simple taproot channel again. The same
+// leaf script and control block as in the original sweep transaction are used.
+func taprootBumpInput(keyRing *lnd.HDKeyRing, txIn *wire.TxIn,
+	prevOut *wire.TxOut, timeLockTargets []*sweepTarget,
+	recoveryWindow uint32,
+	estimator *input.TxWeightEstimator) (*bumpInput, error) {
+
+	script := txIn.Witness[1]
+	signDesc := &input.SignDescriptor{
+		WitnessScript: script,
+		Output:        prevOut,
+		HashType:      txscript.SigHashDefault,
+		SignMethod:    input.TaprootScriptSpendSignMethod,
+		ControlBlock:  txIn.Witness[2],
+	}
+	if len(script) < 34 || script[0] != txscript.OP_DATA_32 {
+		return nil, fmt.Errorf("unknown tapscript %x", script)
+	}
+
+	// The to_remote script is <key> OP_CHECKSIG OP_1 OP_CSV OP_DROP and
+	// is signed with our payment base key.
+	if len(script) == input.TaprootToRemoteScriptSize &&
+		script[34] == txscript.OP_1 {
+
+		keyDesc, err := findPaymentBaseKey(
+			keyRing, script[1:33], recoveryWindow,
+		)
+		if err != nil {
+			return nil, err
+		}
+		signDesc.KeyDesc = *keyDesc
+		estimator.AddWitnessInput(input.TaprootToRemoteWitnessSize)
+
+		return &bumpInput{
+			signDesc: signDesc,
+			witness: func(signer input.Signer,
+				desc *input.SignDescriptor,
+				tx *wire.MsgTx) (wire.TxWitness, error) {
+
+				return input.TaprootCommitRemoteSpend(
+					signer, desc, tx, nil,
+				)
+			},
+		}, nil
+	}
+
+	// Otherwise it's the delay leaf of the to_local output, which is
+	// signed with our tweaked delay base key.
+	target, err := findTimeLockTarget(
+		timeLockTargets, txIn.PreviousOutPoint,
+	)
+	if err != nil {
+		return nil, err
+	}
+	signDesc.KeyDesc = *target.delayBasePointDesc
+	signDesc.SingleTweak = input.SingleTweakBytes(
+		target.commitPoint, target.delayBasePointDesc.PubKey,
+	)
+	estimator.AddWitnessInput(input.TaprootToLocalWitnessSize)
+
+	return &bumpInput{
+		signDesc: signDesc,
+		witness:  commitSpendTimeout,
+	}, nil
+}
+
+// findTimeLockTarget returns the time locked output with the given outpoint.
+func findTimeLockTarget(timeLockTargets []*sweepTarget,
+	op wire.OutPoint) (*sweepTarget, error) {
+
+	for _, target := range timeLockTargets {
+		if target.txid == op.Hash && target.index == op.Index {
+			return target, nil
+		}
+	}
+
+	return nil, fmt.Errorf("time locked output %v not found, specify the "+
+		"channel input file that was used for the sweep", op)
+}
+
+// findPaymentBaseKey scans the payment base key family for the given public
+// key and returns its key descriptor.
+func findPaymentBaseKey(keyRing *lnd.HDKeyRing, pubKeyBytes []byte,
+	recoveryWindow uint32) (*keychain.KeyDescriptor, error) {
+
+	// Taproot scripts only contain the x-only key.
+	parsePubKey := btcec.ParsePubKey
+	xOnly := len(pubKeyBytes) == schnorr.PubKeyBytesLen
+	if xOnly {
+		parsePubKey = schnorr.ParsePubKey
+	}
+	pubKey, err := parsePubKey(pubKeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing pub key: %w", err)
+	}
+
+	for index := uint32(0); index < recoveryWindow; index++ {
+		keyDesc, err := keyRing.DeriveKey(keychain.KeyLocator{
+			Family: keychain.KeyFamilyPaymentBase,
+			Index:  index,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error deriving key: %w", err)
+		}
+
+		if keyDesc.PubKey.IsEqual(pubKey) || (xOnly && bytes.Equal(
+			schnorr.SerializePubKey(keyDesc.PubKey), pubKeyBytes,
+		)) {
+
+			return &keyDesc, nil
+		}
+	}
+
+	return nil, fmt.Errorf("key %x not found in the first %d payment "+
+		"base keys, try increasing --recoverywindow", pubKeyBytes,
+		recoveryWindow)
+}
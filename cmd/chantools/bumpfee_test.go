@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"regexp"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	bumpFeePrevValue = 100_000
+	bumpFeeOldFee    = 1_000
+)
+
+var transactionRegex = regexp.MustCompile("Transaction: ([0-9a-f]+)")
+
+// newBumpFeeTestSweep creates a signed sweep of a P2WKH output of the payment
+// base key with the given index and a fake chain backend that knows about the
+// swept output.
+func newBumpFeeTestSweep(t *testing.T, extendedKey *hdkeychain.ExtendedKey,
+	index uint32, sequence uint32) (*wire.MsgTx, *wire.TxOut,
+	*fakeChainBackend) {
+
+	keyRing := &lnd.HDKeyRing{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+	keyDesc, err := keyRing.DeriveKey(keychain.KeyLocator{
+		Family: keychain.KeyFamilyPaymentBase,
+		Index:  index,
+	})
+	require.NoError(t, err)
+
+	addr, err := lnd.P2WKHAddr(keyDesc.PubKey, chainParams)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	prevOut := &wire.TxOut{Value: bumpFeePrevValue, PkScript: pkScript}
+	prevTx := wire.NewMsgTx(2)
+	prevTx.TxOut = []*wire.TxOut{prevOut}
+
+	sweepTx := wire.NewMsgTx(2)
+	sweepTx.TxIn = []*wire.TxIn{{
+		PreviousOutPoint: wire.OutPoint{Hash: prevTx.TxHash()},
+		Sequence:         sequence,
+	}}
+	sweepTx.TxOut = []*wire.TxOut{{
+		Value:    bumpFeePrevValue - bumpFeeOldFee,
+		PkScript: pkScript,
+	}}
+
+	signer := &lnd.Signer{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+	witness, err := input.CommitSpendNoDelay(signer, &input.SignDescriptor{
+		KeyDesc:       keyDesc,
+		WitnessScript: pkScript,
+		Output:        prevOut,
+		HashType:      txscript.SigHashAll,
+		SigHashes:     input.NewTxSigHashesV0Only(sweepTx),
+	}, sweepTx, true)
+	require.NoError(t, err)
+	sweepTx.TxIn[0].Witness = witness
+
+	chain := newFakeChainBackend()
+	chain.addTx(t, prevTx, nil)
+	chain.spend(
+		t, sweepTx.TxIn[0].PreviousOutPoint, sweepTx.TxHash().String(),
+		nil,
+	)
+
+	return sweepTx, prevOut, chain
+}
+
+func TestBumpFee(t *testing.T) {
+	h := newHarness(t)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+
+	sweepTx, prevOut, api := newBumpFeeTestSweep(
+		t, extendedKey, 7, rbfSequence,
+	)
+	err = bumpFee(extendedKey, api, sweepTx, nil, 10, 50, false)
+	require.NoError(t, err)
+
+	matches := transactionRegex.FindStringSubmatch(h.getLog())
+	require.Len(t, matches, 2)
+	txBytes, err := hex.DecodeString(matches[1])
+	require.NoError(t, err)
+	replacementTx := &wire.MsgTx{}
+	err = replacementTx.Deserialize(bytes.NewReader(txBytes))
+	require.NoError(t, err)
+
+	// The replacement must spend the same input to the same destination
+	// but pay a higher fee.
+	require.Len(t, replacementTx.TxIn, 1)
+	require.Equal(
+		t, sweepTx.TxIn[0].PreviousOutPoint,
+		replacementTx.TxIn[0].PreviousOutPoint,
+	)
+	require.Equal(
+		t, sweepTx.TxIn[0].Sequence, replacementTx.TxIn[0].Sequence,
+	)
+	require.Len(t, replacementTx.TxOut, 1)
+	require.Equal(
+		t, sweepTx.TxOut[0].PkScript, replacementTx.TxOut[0].PkScript,
+	)
+	require.Less(t, replacementTx.TxOut[0].Value, sweepTx.TxOut[0].Value)
+
+	// And the new signature must be valid.
+	prevOutFetcher := txscript.NewCannedPrevOutputFetcher(
+		prevOut.PkScript, prevOut.Value,
+	)
+	vm, err := txscript.NewEngine(
+		prevOut.PkScript, replacementTx, 0,
+		txscript.StandardVerifyFlags, nil,
+		txscript.NewTxSigHashes(replacementTx, prevOutFetcher),
+		prevOut.Value, prevOutFetcher,
+	)
+	require.NoError(t, err)
+	require.NoError(t, vm.Execute())
+}
+
+func TestBumpFeeErrors(t *testing.T) {
+	_ = newHarness(t)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+
+	// A transaction that doesn't signal RBF can't be replaced.
+	sweepTx, _, api := newBumpFeeTestSweep(
+		t, extendedKey, 3, wire.MaxTxInSequenceNum,
+	)
+	err = bumpFee(extendedKey, api, sweepTx, nil, 10, 50, false)
+	require.ErrorContains(t, err, "doesn't signal replace-by-fee")
+
+	// The new fee must be higher than the old one.
+	sweepTx, _, api = newBumpFeeTestSweep(
+		t, extendedKey, 3, rbfSequence,
+	)
+	err = bumpFee(extendedKey, api, sweepTx, nil, 10, 5, false)
+	require.ErrorContains(t, err, "not enough to replace")
+
+	// The key must be found within the recovery window.
+	err = bumpFee(extendedKey, api, sweepTx, nil, 2, 50, false)
+	require.ErrorContains(t, err, "try increasing --recoverywindow")
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChainBackend is an in-memory chain backend for the tests. It reports the
+// transactions that were added to it the same way the Esplora API does. For
+// commands that create their own backend from an API URL, it can also be
+// served as an Esplora compatible HTTP API.
+type fakeChainBackend struct {
+	sync.Mutex
+
+	height      uint32
+	feeEstimate float64
+	txs         map[string]*btc.TX
+	rawTxs      map[string]string
+
+	// txids are the IDs of all transactions in the order they were added.
+	txids []string
+
+	// published are the hex encoded raw transactions that were published.
+	published []string
+
+	// requests are the paths of all requests to the served HTTP API.
+	requests []string
+
+	// publishErrs are the errors that are returned when publishing the
+	// transactions with the given IDs.
+	publishErrs map[string]error
+}
+
+var _ btc.ChainBackend = (*fakeChainBackend)(nil)
+
+// newFakeChainBackend returns an empty fake chain backend.
+func newFakeChainBackend() *fakeChainBackend {
+	return &fakeChainBackend{
+		txs:         make(map[string]*btc.TX),
+		rawTxs:      make(map[string]string),
+		publishErrs: make(map[string]error),
+	}
+}
+
+// addTx adds the given transaction with the given confirmation status and all
+// its outputs unspent. A transaction that was added before is replaced.
+func (c *fakeChainBackend) addTx(t *testing.T, tx *wire.MsgTx,
+	status *btc.Status) {
+
+	var buf bytes.Buffer
+	require.NoError(t, tx.Serialize(&buf))
+
+	apiTx := &btc.TX{
+		TXID:     tx.TxHash().String(),
+		Locktime: tx.LockTime,
+		Status:   status,
+	}
+	for _, txIn := range tx.TxIn {
+		apiTx.Vin = append(apiTx.Vin, &btc.Vin{
+			Tixid:    txIn.PreviousOutPoint.Hash.String(),
+			Vout:     int(txIn.PreviousOutPoint.Index),
+			Sequence: txIn.Sequence,
+		})
+	}
+	for _, txOut := range tx.TxOut {
+		vout := &btc.Vout{
+			ScriptPubkey: hex.EncodeToString(txOut.PkScript),
+			Value:        uint64(txOut.Value),
+		}
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+			txOut.PkScript, chainParams,
+		)
+		if err == nil && len(addrs) == 1 {
+			vout.ScriptPubkeyAddr = addrs[0].EncodeAddress()
+		}
+		apiTx.Vout = append(apiTx.Vout, vout)
+	}
+
+	c.addAPITx(apiTx)
+
+	c.Lock()
+	c.rawTxs[apiTx.TXID] = hex.EncodeToString(buf.Bytes())
+	c.Unlock()
+}
+
+// addAPITx adds a copy of the given transaction as it is reported by the API.
+// This can be used for transactions that are only known partially. Outputs
+// without spend information are unspent. A transaction that was added before
+// is replaced.
+func (c *fakeChainBackend) addAPITx(tx *btc.TX) {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.txs[tx.TXID]; !ok {
+		c.txids = append(c.txids, tx.TXID)
+	}
+	c.txs[tx.TXID] = copyAPITx(tx)
+}
+
+// spend marks the given output as spent by the given transaction with the
+// given confirmation status.
+func (c *fakeChainBackend) spend(t *testing.T, op wire.OutPoint,
+	spender string, status *btc.Status) {
+
+	c.Lock()
+	defer c.Unlock()
+
+	tx, ok := c.txs[op.Hash.String()]
+	require.True(t, ok, "unknown TX %v", op.Hash)
+	require.Less(t, int(op.Index), len(tx.Vout))
+
+	tx.Vout[op.Index].Outspend = &btc.Outspend{
+		Spent:  true,
+		Txid:   spender,
+		Status: status,
+	}
+}
+
+// setHeight sets the height of the best block.
+func (c *fakeChainBackend) setHeight(height uint32) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.height = height
+}
+
+// rejectTx makes publishing the transaction with the given ID fail with the
+// given error.
+func (c *fakeChainBackend) rejectTx(txid string, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.publishErrs[txid] = err
+}
+
+// setFeeEstimate sets the fee rate in sat/vByte that is estimated for all
+// confirmation targets. No estimate is available if it is 0.
+func (c *fakeChainBackend) setFeeEstimate(feeRate float64) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.feeEstimate = feeRate
+}
+
+// publishedTxs returns the hex encoded raw transactions that were published.
+func (c *fakeChainBackend) publishedTxs() []string {
+	c.Lock()
+	defer c.Unlock()
+
+	return append([]string(nil), c.published...)
+}
+
+// numRequests returns the number of requests to the served HTTP API with a
+// path that starts with the given prefix.
+func (c *fakeChainBackend) numRequests(prefix string) int {
+	c.Lock()
+	defer c.Unlock()
+
+	num := 0
+	for _, path := range c.requests {
+		if strings.HasPrefix(path, prefix) {
+			num++
+		}
+	}
+
+	return num
+}
+
+// Transaction returns a copy of the transaction with the given ID, including
+// the spend status of all its outputs.
+func (c *fakeChainBackend) Transaction(txid string) (*btc.TX, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.transaction(txid)
+}
+
+// RawTransaction returns the hex encoded raw transaction with the given ID.
+func (c *fakeChainBackend) RawTransaction(txid string) (string, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	rawTx, ok := c.rawTxs[txid]
+	if !ok {
+		return "", btc.ErrTxNotFound
+	}
+
+	return rawTx, nil
+}
+
+// TxStatus returns the confirmation status of the transaction with the given
+// ID.
+func (c *fakeChainBackend) TxStatus(txid string) (*btc.Status, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	tx, err := c.transaction(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.Status, nil
+}
+
+// BlockHeight returns the height of the best block.
+func (c *fakeChainBackend) BlockHeight() (uint32, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.height, nil
+}
+
+// FeeEstimate returns the fee rate that was set with setFeeEstimate.
+func (c *fakeChainBackend) FeeEstimate(confTarget uint32) (float64, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.feeEstimate == 0 {
+		return 0, fmt.Errorf("no fee estimate available for a "+
+			"confirmation target of %d blocks", confTarget)
+	}
+
+	return c.feeEstimate, nil
+}
+
+// Outpoint returns the first transaction that pays to the given address and
+// the index of the output.
+func (c *fakeChainBackend) Outpoint(addr string) (*btc.TX, int, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, tx := range c.addressTxs(addr) {
+		for idx, vout := range tx.Vout {
+			if vout.ScriptPubkeyAddr == addr {
+				return tx, idx, nil
+			}
+		}
+	}
+
+	return nil, 0, fmt.Errorf("no tx found")
+}
+
+// Unspent returns the outputs of the given address. Like the Esplora API, all
+// outputs that ever paid to the address are returned as long as any of them is
+// unspent.
+func (c *fakeChainBackend) Unspent(addr string) ([]*btc.Vout, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	stats := c.addressStats(addr)
+	unspent := stats.ChainStats.FundedTXOSum -
+		stats.ChainStats.SpentTXOSum +
+		stats.MempoolStats.FundedTXOSum -
+		stats.MempoolStats.SpentTXOSum
+	if unspent == 0 {
+		return nil, nil
+	}
+
+	var outputs []*btc.Vout
+	for _, tx := range c.addressTxs(addr) {
+		for idx, vout := range tx.Vout {
+			if vout.ScriptPubkeyAddr != addr {
+				continue
+			}
+
+			vout.Outspend = &btc.Outspend{
+				Txid: tx.TXID,
+				Vin:  idx,
+			}
+			outputs = append(outputs, vout)
+		}
+	}
+
+	return outputs, nil
+}
+
+// Address returns the address the given outpoint pays to.
+func (c *fakeChainBackend) Address(outpoint string) (string, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	parts := strings.Split(outpoint, ":")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid outpoint: %v", outpoint)
+	}
+	tx, err := c.transaction(parts[0])
+	if err != nil {
+		return "", err
+	}
+	idx, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", err
+	}
+	if idx >= len(tx.Vout) {
+		return "", fmt.Errorf("invalid output index: %d", idx)
+	}
+
+	return tx.Vout[idx].ScriptPubkeyAddr, nil
+}
+
+// AddressUsed returns true if any transaction pays to the given address.
+func (c *fakeChainBackend) AddressUsed(addr string) (bool, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	return len(c.addressTxs(addr)) > 0, nil
+}
+
+// PublishTx records the given raw transaction and returns its ID, or the error
+// that was set for it with rejectTx.
+func (c *fakeChainBackend) PublishTx(rawTxHex string) (string, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	txBytes, err := hex.DecodeString(rawTxHex)
+	if err != nil {
+		return "", err
+	}
+	tx := &wire.MsgTx{}
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return "", err
+	}
+	c.published = append(c.published, rawTxHex)
+
+	txid := tx.TxHash().String()
+	if err := c.publishErrs[txid]; err != nil {
+		return "", err
+	}
+
+	return txid, nil
+}
+
+// transaction returns a copy of the transaction with the given ID, so it can't
+// be changed by the caller. The mutex must be held.
+func (c *fakeChainBackend) transaction(txid string) (*btc.TX, error) {
+	tx, ok := c.txs[txid]
+	if !ok {
+		return nil, btc.ErrTxNotFound
+	}
+
+	return copyAPITx(tx), nil
+}
+
+// copyAPITx returns a deep copy of the given transaction in which all outputs
+// have spend information and the transaction has a status.
+func copyAPITx(tx *btc.TX) *btc.TX {
+	txCopy := *tx
+	txCopy.Vin = make([]*btc.Vin, len(tx.Vin))
+	for idx, vin := range tx.Vin {
+		vinCopy := *vin
+		txCopy.Vin[idx] = &vinCopy
+	}
+	txCopy.Vout = make([]*btc.Vout, len(tx.Vout))
+	for idx, vout := range tx.Vout {
+		voutCopy := *vout
+		voutCopy.Outspend = &btc.Outspend{}
+		if vout.Outspend != nil {
+			*voutCopy.Outspend = *vout.Outspend
+		}
+		txCopy.Vout[idx] = &voutCopy
+	}
+	txCopy.Status = &btc.Status{}
+	if tx.Status != nil {
+		*txCopy.Status = *tx.Status
+	}
+
+	return &txCopy
+}
+
+// addressTxs returns copies of all transactions that pay to the given address
+// in the order they were added. The mutex must be held.
+func (c *fakeChainBackend) addressTxs(addr string) []*btc.TX {
+	var txs []*btc.TX
+	for _, txid := range c.txids {
+		tx := c.txs[txid]
+		for _, vout := range tx.Vout {
+			if vout.ScriptPubkeyAddr != addr {
+				continue
+			}
+
+			txs = append(txs, copyAPITx(tx))
+			break
+		}
+	}
+
+	return txs
+}
+
+// addressStats returns the statistics of the given address. Outputs of
+// unconfirmed transactions are counted as mempool statistics. The mutex must
+// be held.
+func (c *fakeChainBackend) addressStats(addr string) *btc.AddressStats {
+	stats := &btc.AddressStats{
+		Address:      addr,
+		ChainStats:   &btc.Stats{},
+		MempoolStats: &btc.Stats{},
+	}
+	for _, tx := range c.addressTxs(addr) {
+		txStats := stats.ChainStats
+		if !tx.Status.Confirmed {
+			txStats = stats.MempoolStats
+		}
+		txStats.TXCount++
+
+		for _, vout := range tx.Vout {
+			if vout.ScriptPubkeyAddr != addr {
+				continue
+			}
+
+			txStats.FundedTXOCount++
+			txStats.FundedTXOSum += vout.Value
+			if vout.Outspend.Spent {
+				txStats.SpentTXOCount++
+				txStats.SpentTXOSum += vout.Value
+			}
+		}
+	}
+
+	return stats
+}
+
+// serve starts an Esplora compatible HTTP API for the fake chain backend that
+// is stopped at the end of the test and returns its URL.
+func (c *fakeChainBackend) serve(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			c.Lock()
+			c.requests = append(c.requests, r.URL.Path)
+			c.Unlock()
+
+			response, err := c.apiResponse(r)
+			switch {
+			case errors.Is(err, btc.ErrTxNotFound):
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte("Transaction not found"))
+
+			case err != nil:
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(err.Error()))
+
+			case response == nil:
+				http.NotFound(w, r)
+
+			default:
+				if text, ok := response.(string); ok {
+					_, _ = w.Write([]byte(text))
+					return
+				}
+				require.NoError(
+					t, json.NewEncoder(w).Encode(response),
+				)
+			}
+		},
+	))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// apiResponse returns the response of the Esplora API for the given request.
+// Plain text responses are returned as a string.
+func (c *fakeChainBackend) apiResponse(r *http.Request) (interface{},
+	error) {
+
+	if r.Method == http.MethodPost && r.URL.Path == "/tx" {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		return c.PublishTx(string(body))
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/blocks/tip/height":
+		return strconv.FormatUint(uint64(c.height), 10), nil
+
+	case r.URL.Path == "/fee-estimates":
+		estimates := make(map[string]float64)
+		if c.feeEstimate != 0 {
+			estimates["1"] = c.feeEstimate
+		}
+		return estimates, nil
+
+	case len(parts) == 2 && parts[0] == "address":
+		return c.addressStats(parts[1]), nil
+
+	case len(parts) == 3 && parts[0] == "address" && parts[2] == "txs":
+		return c.addressTxs(parts[1]), nil
+
+	case parts[0] != "tx" || len(parts) < 2:
+		return nil, nil
+	}
+
+	tx, err := c.transaction(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(parts) == 2:
+		return tx, nil
+
+	case len(parts) == 3 && parts[2] == "hex":
+		rawTx, ok := c.rawTxs[tx.TXID]
+		if !ok {
+			return nil, btc.ErrTxNotFound
+		}
+		return rawTx, nil
+
+	case len(parts) == 3 && parts[2] == "status":
+		return tx.Status, nil
+
+	case len(parts) == 4 && parts[2] == "outspend":
+		idx, err := strconv.Atoi(parts[3])
+		if err != nil || idx >= len(tx.Vout) {
+			return nil, nil
+		}
+		return tx.Vout[idx].Outspend, nil
+	}
+
+	return nil, nil
+}
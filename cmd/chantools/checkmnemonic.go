@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/guggero/chantools/bip39"
+	"github.com/spf13/cobra"
+)
+
+const (
+	checkMnemonicFormat = `
+Mnemonic valid:		%v
+Word count:		%d
+Language:		%s
+`
+
+	numWordSuggestions = 3
+)
+
+type checkMnemonicCommand struct {
+	Mnemonic  string
+	SeedQR    string
+	SeedQRPNG string
+
+	cmd *cobra.Command
+}
+
+func newCheckMnemonicCommand() *cobra.Command {
+	cc := &checkMnemonicCommand{}
+	cc.cmd = &cobra.Command{
+		Use:   "checkmnemonic",
+		Short: "Check that a BIP39 mnemonic is valid",
+		Long: `This command checks that the given BIP39 mnemonic only
+consists of valid words and that its checksum is correct. This can be used to
+make sure a mnemonic was transcribed correctly before trusting it, without the
+need to derive any keys from it. The language of the mnemonic is detected from
+the words, all official BIP39 word lists are supported. If the words are part of
+more than one list, all possible languages are shown.
+
+If the mnemonic is invalid, the reason is shown (for example which word could
+not be found in the word list) and the command exits with a non-zero exit
+code.
+
+With --seedqr or --seedqr-png, a valid 12 or 24 word mnemonic is also encoded as
+a standard or compact SeedQR code that can be scanned by air-gapped signers like
+SeedSigner, so a recovered mnemonic doesn't need to be typed in again.`,
+		Example: `chantools checkmnemonic
+
+echo "abandon ... about" | chantools checkmnemonic
+
+chantools checkmnemonic --mnemonic "abandon ... about"
+
+chantools checkmnemonic --seedqr-png seed.png`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.Mnemonic, "mnemonic", "", "the mnemonic to check; leave "+
+			"empty to read it from the terminal or stdin",
+	)
+	addSeedQRFlags(cc.cmd, &cc.SeedQR, &cc.SeedQRPNG)
+
+	return cc.cmd
+}
+
+func (c *checkMnemonicCommand) Execute(_ *cobra.Command, _ []string) error {
+	mnemonic := c.Mnemonic
+	switch {
+	case mnemonic != "":
+		warnSecretOnCommandLine("mnemonic")
+
+	default:
+		fmt.Printf("Input your 12 to 24 word mnemonic separated by " +
+			"spaces: ")
+		reader := bufio.NewReader(os.Stdin)
+
+		var err error
+		mnemonic, err = reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+
+	// Extra spaces and upper case letters are normalized or rejected by
+	// the bip39 package, depending on the --mnemonic-strict flag.
+	mnemonic = strings.TrimRight(mnemonic, "\r\n")
+	words := strings.Fields(strings.ToLower(mnemonic))
+
+	// Some words are part of more than one list, so we show all languages
+	// that are possible.
+	language := "unknown"
+	if languages, err := bip39.DetectLanguages(mnemonic); err == nil {
+		language = strings.Join(languages, " or ")
+	}
+
+	// The mnemonic doesn't need to be in the language of the active word
+	// list to be valid.
+	bip39.SetLanguageDetection(true)
+	_, err := bip39.EntropyFromMnemonic(mnemonic)
+	bip39.SetLanguageDetection(false)
+	result := fmt.Sprintf(
+		checkMnemonicFormat, err == nil, len(words), language,
+	)
+	if err != nil {
+		result += explainMnemonicError(err, words)
+	}
+	if err == nil && (c.SeedQR != "" || c.SeedQRPNG != "") {
+		qrResult, err := seedQR(mnemonic, c.SeedQR, c.SeedQRPNG)
+		if err != nil {
+			return err
+		}
+		result += qrResult
+	}
+	fmt.Println(result)
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(result)
+
+	if err != nil {
+		return fmt.Errorf("mnemonic is invalid: %w", err)
+	}
+
+	return nil
+}
+
+// explainMnemonicError returns a human readable explanation of why a mnemonic
+// is invalid.
+func explainMnemonicError(err error, words []string) string {
+	switch {
+	case errors.Is(err, bip39.ErrInvalidWordCount):
+		return fmt.Sprintf("Problem:\t\twrong number of words (%d), "+
+			"must be 12, 15, 18, 21 or 24\n", len(words))
+
+	case errors.Is(err, bip39.ErrWordNotFound):
+		result := ""
+		for idx, word := range words {
+			if _, ok := bip39.GetWordIndex(word); ok {
+				continue
+			}
+
+			result += fmt.Sprintf("Problem:\t\tword %d (%s) not "+
+				"found in word list, did you mean: %s?\n",
+				idx+1, word, strings.Join(bip39.ClosestWords(
+					word, numWordSuggestions,
+				), ", "))
+		}
+		return result
+
+	case errors.Is(err, bip39.ErrAmbiguousLanguage):
+		return "Problem:\t\tthe words are valid in more than one " +
+			"language but encode different entropy, so the " +
+			"language can't be determined\n"
+
+	case errors.Is(err, bip39.ErrElectrumSeed):
+		return "Problem:\t\tthis is an Electrum seed, not a BIP39 " +
+			"mnemonic; it needs to be restored with Electrum\n"
+
+	case errors.Is(err, bip39.ErrChecksumIncorrect):
+		return "Problem:\t\tall words are valid but the checksum " +
+			"doesn't match; at least one word is wrong or the " +
+			"words are in the wrong order\n"
+
+	default:
+		return fmt.Sprintf("Problem:\t\t%v\n", err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/guggero/chantools/bip39"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckMnemonic(t *testing.T) {
+	h := newHarness(t)
+
+	check := &checkMnemonicCommand{
+		Mnemonic: seedBip39,
+	}
+
+	err := check.Execute(nil, nil)
+	require.NoError(t, err)
+
+	h.assertLogContains("Mnemonic valid:\t\ttrue")
+	h.assertLogContains("Word count:\t\t12")
+	h.assertLogContains("Language:\t\tenglish")
+}
+
+func TestCheckMnemonicInvalid(t *testing.T) {
+	h := newHarness(t)
+
+	// Misspell the second word.
+	check := &checkMnemonicCommand{
+		Mnemonic: "uncover bargian diesel boss local host over " +
+			"divide orient cradle good crumble",
+	}
+
+	err := check.Execute(nil, nil)
+	require.ErrorIs(t, err, bip39.ErrWordNotFound)
+
+	h.assertLogContains("Mnemonic valid:\t\tfalse")
+	h.assertLogContains("word 2 (bargian) not found in word list, did " +
+		"you mean: bargain")
+
+	// Swap two words to break the checksum.
+	h.clearLog()
+	check.Mnemonic = "bargain uncover diesel boss local host over " +
+		"divide orient cradle good crumble"
+	err = check.Execute(nil, nil)
+	require.ErrorIs(t, err, bip39.ErrChecksumIncorrect)
+	h.assertLogContains("checksum doesn't match")
+
+	h.clearLog()
+	check.Mnemonic = "uncover bargain diesel"
+	err = check.Execute(nil, nil)
+	require.ErrorIs(t, err, bip39.ErrInvalidWordCount)
+	h.assertLogContains("wrong number of words (3)")
+}
+
+func TestCheckMnemonicStrict(t *testing.T) {
+	h := newHarness(t)
+
+	// By default, extra whitespace and upper case letters are normalized.
+	check := &checkMnemonicCommand{
+		Mnemonic: " Uncover  bargain diesel boss local host over " +
+			"divide orient cradle good crumble\n",
+	}
+	err := check.Execute(nil, nil)
+	require.NoError(t, err)
+	h.assertLogContains("Mnemonic valid:\t\ttrue")
+	h.assertLogContains("Word count:\t\t12")
+
+	require.NoError(t, bip39.SetNormalizationPolicy(bip39.NormalizeStrict))
+	defer bip39.SetNormalizationPolicy( // nolint:errcheck
+		bip39.NormalizeLenient,
+	)
+
+	// A trailing line break is never part of the mnemonic.
+	h.clearLog()
+	check.Mnemonic = seedBip39 + "\n"
+	err = check.Execute(nil, nil)
+	require.NoError(t, err)
+
+	h.clearLog()
+	check.Mnemonic = " " + seedBip39
+	err = check.Execute(nil, nil)
+	require.ErrorIs(t, err, bip39.ErrMnemonicNotNormalized)
+	h.assertLogContains("Mnemonic valid:\t\tfalse")
+	h.assertLogContains("leading whitespace")
+
+	h.clearLog()
+	check.Mnemonic = "Uncover bargain diesel boss local host over " +
+		"divide orient cradle good crumble"
+	err = check.Execute(nil, nil)
+	require.ErrorIs(t, err, bip39.ErrMnemonicNotNormalized)
+	h.assertLogContains("upper case letter in word 1")
+}
+
+func TestCheckMnemonicLanguage(t *testing.T) {
+	h := newHarness(t)
+
+	// The mnemonic of the all zero entropy in French.
+	check := &checkMnemonicCommand{
+		Mnemonic: "abaisser abaisser abaisser abaisser abaisser " +
+			"abaisser abaisser abaisser abaisser abaisser " +
+			"abaisser abeille",
+	}
+	err := check.Execute(nil, nil)
+	require.NoError(t, err)
+	h.assertLogContains("Mnemonic valid:\t\ttrue")
+	h.assertLogContains("Language:\t\tfrench")
+
+	// The active word list isn't changed.
+	require.Equal(t, bip39.English, bip39.GetWordList())
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
-	"github.com/guggero/chantools/btc"
 	"github.com/guggero/chantools/lnd"
 	"github.com/lightninglabs/pool/poolscript"
 	"github.com/lightningnetwork/lnd/input"
@@ -37,13 +36,20 @@ var (
 )
 
 type closePoolAccountCommand struct {
-	APIURL        string
-	Outpoint      string
-	AuctioneerKey string
-	Publish       bool
-	SweepAddr     string
-	FeeRate       uint16
-
+	APIURL         string
+	Outpoint       string
+	AuctioneerKey  string
+	Publish        bool
+	DryRun         bool
+	SweepAddr      string
+	DestDescriptor string
+	DestIndex      uint32
+	DestGapLimit   uint32
+	FeeRate        uint16
+	Psbt           bool
+
+	Expiry          uint32
+	TraderKey       string
 	MinExpiry       uint32
 	MaxNumBlocks    uint32
 	MaxNumAccounts  uint32
@@ -64,12 +70,30 @@ expired already, otherwise this command doesn't work since a signature from the
 auctioneer is necessary.
 
 You need to know the account's last unspent outpoint. That can either be
-obtained by running 'pool accounts list' `,
+obtained by running 'pool accounts list'.
+
+If the account's expiry height and trader key are known (for example from the
+output of 'pool accounts list'), they can be specified with --expiry and
+--traderkey to avoid brute forcing them. The account output is time locked with
+an absolute (CLTV) time lock until the expiry height. If that height isn't
+reached yet, the command refuses to create the transaction and shows how many
+blocks are remaining.
+
+With the --psbt flag, an unsigned PSBT is created instead of a signed
+transaction. See the sweeptimelock command for the details.`,
 		Example: `chantools closepoolaccount \
 	--outpoint xxxxxxxxx:y \
 	--sweepaddr bc1q..... \
 	--feerate 10 \
-  	--publish`,
+  	--publish
+
+chantools closepoolaccount \
+	--outpoint xxxxxxxxx:y \
+	--expiry 700000 \
+	--traderkey 03xxxxxxx \
+	--sweepaddr bc1q..... \
+	--feerate 10 \
+	--psbt`,
 		RunE: cc.Execute,
 	}
 	cc.cmd.Flags().StringVar(
@@ -88,13 +112,28 @@ obtained by running 'pool accounts list' `,
 		&cc.Publish, "publish", false, "publish sweep TX to the chain "+
 			"API instead of just printing the TX",
 	)
+	addDryRunFlag(cc.cmd, &cc.DryRun)
 	cc.cmd.Flags().StringVar(
 		&cc.SweepAddr, "sweepaddr", "", "address to sweep the funds to",
 	)
+	addDestDescriptorFlags(
+		cc.cmd, &cc.DestDescriptor, &cc.DestIndex, &cc.DestGapLimit,
+	)
 	cc.cmd.Flags().Uint16Var(
 		&cc.FeeRate, "feerate", defaultFeeSatPerVByte, "fee rate to "+
 			"use for the sweep transaction in sat/vByte",
 	)
+	addPsbtFlag(cc.cmd, &cc.Psbt)
+	cc.cmd.Flags().Uint32Var(
+		&cc.Expiry, "expiry", 0, "the account's expiry block height "+
+			"if it is known; if set, the expiry is not brute "+
+			"forced and --minexpiry and --maxnumblocks are ignored",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.TraderKey, "traderkey", "", "the account's trader public "+
+			"key if it is known; if set, only the account index "+
+			"that matches this key is tried",
+	)
 	cc.cmd.Flags().Uint32Var(
 		&cc.MinExpiry, "minexpiry", poolMainnetFirstBatchBlock,
 		"the block to start brute forcing the expiry from",
@@ -124,10 +163,23 @@ func (c *closePoolAccountCommand) Execute(_ *cobra.Command, _ []string) error {
 		return fmt.Errorf("error reading root key: %w", err)
 	}
 
+	// The sweep address can also be derived from a descriptor.
+	c.SweepAddr, err = sweepDestination(
+		c.SweepAddr, c.DestDescriptor, c.DestIndex, c.DestGapLimit,
+		c.APIURL,
+	)
+	if err != nil {
+		return err
+	}
+
 	// Make sure sweep addr is set.
 	if c.SweepAddr == "" {
 		return fmt.Errorf("sweep addr is required")
 	}
+	if c.Psbt && c.Publish {
+		return fmt.Errorf("cannot publish a PSBT, it must be signed " +
+			"first")
+	}
 
 	// Parse account outpoint and auctioneer key.
 	outpoint, err := lnd.ParseOutpoint(c.Outpoint)
@@ -145,27 +197,50 @@ func (c *closePoolAccountCommand) Execute(_ *cobra.Command, _ []string) error {
 		return fmt.Errorf("error parsing auctioneer key: %w", err)
 	}
 
+	var traderKey *btcec.PublicKey
+	if c.TraderKey != "" {
+		traderKeyBytes, err := hex.DecodeString(c.TraderKey)
+		if err != nil {
+			return fmt.Errorf("error decoding trader key: %w", err)
+		}
+		traderKey, err = btcec.ParsePubKey(traderKeyBytes)
+		if err != nil {
+			return fmt.Errorf("error parsing trader key: %w", err)
+		}
+	}
+
+	// If we know the expiry, there's nothing to brute force.
+	minExpiry, maxExpiry := c.MinExpiry, c.MinExpiry+c.MaxNumBlocks
+	if c.Expiry != 0 {
+		minExpiry, maxExpiry = c.Expiry, c.Expiry
+	}
+
 	// Set default values.
 	if c.FeeRate == 0 {
 		c.FeeRate = defaultFeeSatPerVByte
 	}
+	publish := shouldPublish(c.Publish, c.DryRun, c.APIURL)
 	return closePoolAccount(
-		extendedKey, c.APIURL, outpoint, auctioneerKey, c.SweepAddr,
-		c.Publish, c.FeeRate, c.MinExpiry, c.MinExpiry+c.MaxNumBlocks,
-		c.MaxNumAccounts, c.MaxNumBatchKeys,
+		extendedKey, c.APIURL, outpoint, auctioneerKey, traderKey,
+		c.SweepAddr, publish, c.FeeRate, minExpiry, maxExpiry,
+		c.MaxNumAccounts, c.MaxNumBatchKeys, c.Psbt,
 	)
 }
 
 func closePoolAccount(extendedKey *hdkeychain.ExtendedKey, apiURL string,
-	outpoint *wire.OutPoint, auctioneerKey *btcec.PublicKey,
+	outpoint *wire.OutPoint, auctioneerKey, traderKey *btcec.PublicKey,
 	sweepAddr string, publish bool, feeRate uint16, minExpiry,
-	maxNumBlocks, maxNumAccounts, maxNumBatchKeys uint32) error {
+	maxNumBlocks, maxNumAccounts, maxNumBatchKeys uint32,
+	createPsbt bool) error {
 
 	signer := &lnd.Signer{
 		ExtendedKey: extendedKey,
 		ChainParams: chainParams,
 	}
-	api := &btc.ExplorerAPI{BaseURL: apiURL}
+	api, err := newChainBackend(apiURL)
+	if err != nil {
+		return err
+	}
 
 	tx, err := api.Transaction(outpoint.Hash.String())
 	if err != nil {
@@ -200,8 +275,8 @@ func closePoolAccount(extendedKey *hdkeychain.ExtendedKey, apiURL string,
 
 	// Try our luck.
 	acct, err := bruteForceAccountScript(
-		accountBaseKey, auctioneerKey, minExpiry, maxNumBlocks,
-		maxNumAccounts, maxNumBatchKeys, pkScript,
+		accountBaseKey, auctioneerKey, traderKey, minExpiry,
+		maxNumBlocks, maxNumAccounts, maxNumBatchKeys, pkScript,
 	)
 	if err != nil {
 		return fmt.Errorf("error brute forcing account script: %w", err)
@@ -209,6 +284,19 @@ func closePoolAccount(extendedKey *hdkeychain.ExtendedKey, apiURL string,
 
 	log.Debugf("Found pool account %s", acct.String())
 
+	// The account output can only be spent by us alone once the CLTV
+	// time lock has expired.
+	bestHeight, err := api.BlockHeight()
+	if err != nil {
+		return fmt.Errorf("error querying best block height: %w", err)
+	}
+	matured, blocksLeft := cltvMatured(acct.expiry, bestHeight)
+	if !matured {
+		return fmt.Errorf("account expires at block height %d, time "+
+			"lock expires in %d block(s), run the command again "+
+			"then", acct.expiry, blocksLeft)
+	}
+
 	sweepTx := wire.NewMsgTx(2)
 	sweepTx.LockTime = acct.expiry
 	sweepValue := int64(txOut.Value)
@@ -258,35 +346,34 @@ func closePoolAccount(extendedKey *hdkeychain.ExtendedKey, apiURL string,
 		SigHashes:  sigHashes,
 		HashType:   txscript.SigHashAll,
 	}
-	sig, err := signer.SignOutputRaw(sweepTx, signDesc)
-	if err != nil {
-		return fmt.Errorf("error signing sweep tx: %w", err)
-	}
-	ourSig := append(sig.Serialize(), byte(signDesc.HashType))
-	sweepTx.TxIn[0].Witness = poolscript.SpendExpiry(
-		acct.witnessScript, ourSig,
+	signDescs := []*input.SignDescriptor{signDesc}
+	err = validateSweepTx(
+		api, sweepTx, signDescs, int64(estimator.VSize()), feeRate,
 	)
-
-	var buf bytes.Buffer
-	err = sweepTx.Serialize(&buf)
 	if err != nil {
-		return err
+		return fmt.Errorf("error validating sweep TX: %w", err)
 	}
 
-	// Publish TX.
-	if publish {
-		response, err := api.PublishTx(
-			hex.EncodeToString(buf.Bytes()),
-		)
+	// Instead of signing the transaction ourselves, we create a PSBT that
+	// can be signed on another machine.
+	if createPsbt {
+		packet, err := newSweepPsbt(extendedKey, sweepTx, signDescs)
 		if err != nil {
 			return err
 		}
-		log.Infof("Published TX %s, response: %s",
-			sweepTx.TxHash().String(), response)
+		return logPsbt(packet)
+	}
+
+	sig, err := signer.SignOutputRaw(sweepTx, signDesc)
+	if err != nil {
+		return fmt.Errorf("error signing sweep tx: %w", err)
 	}
+	ourSig := append(sig.Serialize(), byte(signDesc.HashType))
+	sweepTx.TxIn[0].Witness = poolscript.SpendExpiry(
+		acct.witnessScript, ourSig,
+	)
 
-	log.Infof("Transaction: %x", buf.Bytes())
-	return nil
+	return publishTx(api, sweepTx, int64(totalFee), publish)
 }
 
 type poolAccount struct {
@@ -305,9 +392,21 @@ func (a *poolAccount) String() string {
 		a.witnessScript)
 }
 
+// cltvMatured returns true if a transaction with the given lock time can be
+// included in the next block. If not, the number of blocks until then is
+// returned.
+func cltvMatured(lockTime, bestHeight uint32) (bool, uint32) {
+	if bestHeight >= lockTime {
+		return true, 0
+	}
+
+	return false, lockTime - bestHeight
+}
+
 func bruteForceAccountScript(accountBaseKey *hdkeychain.ExtendedKey,
-	auctioneerKey *btcec.PublicKey, minExpiry, maxNumBlocks, maxNumAccounts,
-	maxNumBatchKeys uint32, targetScript []byte) (*poolAccount, error) {
+	auctioneerKey, traderKey *btcec.PublicKey, minExpiry, maxNumBlocks,
+	maxNumAccounts, maxNumBatchKeys uint32,
+	targetScript []byte) (*poolAccount, error) {
 
 	// The outer-most loop is over the possible accounts.
 	for i := uint32(0); i < maxNumAccounts; i++ {
@@ -322,8 +421,15 @@ func bruteForceAccountScript(accountBaseKey *hdkeychain.ExtendedKey,
 			return nil, fmt.Errorf("error deriving private key: "+
 				"%w", err)
 		}
+
+		// If we know the trader key, we don't need to try any other
+		// account index.
+		accountPubKey := accountPrivKey.PubKey()
+		if traderKey != nil && !traderKey.IsEqual(accountPubKey) {
+			continue
+		}
 		log.Debugf("Trying trader key %x...",
-			accountPrivKey.PubKey().SerializeCompressed())
+			accountPubKey.SerializeCompressed())
 
 		sharedKey, err := lnd.ECDH(accountPrivKey, auctioneerKey)
 		if err != nil {
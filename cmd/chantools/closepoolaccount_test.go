@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightninglabs/pool/poolscript"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBruteForceAccountScript(t *testing.T) {
+	_ = newHarness(t)
+
+	rootKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	accountBaseKey, err := lnd.DeriveChildren(rootKey, []uint32{
+		lnd.HardenedKey(uint32(keychain.BIP0043Purpose)),
+		lnd.HardenedKey(chainParams.HDCoinType),
+		lnd.HardenedKey(uint32(poolscript.AccountKeyFamily)),
+		0,
+	})
+	require.NoError(t, err)
+
+	auctioneerKeyBytes, err := hex.DecodeString(mainnetAuctioneerKeyHex)
+	require.NoError(t, err)
+	auctioneerKey, err := btcec.ParsePubKey(auctioneerKeyBytes)
+	require.NoError(t, err)
+
+	// Create the account script of the second account, two batches after
+	// the initial one.
+	const expiry = 700_010
+	accountKey, err := accountBaseKey.DeriveNonStandard(1)
+	require.NoError(t, err)
+	accountPrivKey, err := accountKey.ECPrivKey()
+	require.NoError(t, err)
+	sharedKey, err := lnd.ECDH(accountPrivKey, auctioneerKey)
+	require.NoError(t, err)
+	batchKey := poolscript.IncrementKey(
+		poolscript.IncrementKey(initialBatchKey),
+	)
+	witnessScript, err := poolscript.AccountWitnessScript(
+		expiry, accountPrivKey.PubKey(), auctioneerKey, batchKey,
+		sharedKey,
+	)
+	require.NoError(t, err)
+	pkScript, err := input.WitnessScriptHash(witnessScript)
+	require.NoError(t, err)
+
+	acct, err := bruteForceAccountScript(
+		accountBaseKey, auctioneerKey, nil, 700_000, 700_020, 3, 5,
+		pkScript,
+	)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, acct.keyIndex)
+	require.EqualValues(t, expiry, acct.expiry)
+	require.Equal(t, witnessScript, acct.witnessScript)
+
+	// With a known expiry and trader key nothing needs to be brute forced.
+	acct, err = bruteForceAccountScript(
+		accountBaseKey, auctioneerKey, accountPrivKey.PubKey(), expiry,
+		expiry, 3, 5, pkScript,
+	)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, acct.keyIndex)
+
+	// A different trader key doesn't find the account.
+	_, err = bruteForceAccountScript(
+		accountBaseKey, auctioneerKey, auctioneerKey, expiry, expiry,
+		3, 5, pkScript,
+	)
+	require.ErrorContains(t, err, "account script not derived")
+}
+
+func TestCltvMatured(t *testing.T) {
+	matured, blocksLeft := cltvMatured(700_000, 699_990)
+	require.False(t, matured)
+	require.EqualValues(t, 10, blocksLeft)
+
+	matured, blocksLeft = cltvMatured(700_000, 700_000)
+	require.True(t, matured)
+	require.EqualValues(t, 0, blocksLeft)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/guggero/chantools/bip39"
+	"github.com/spf13/cobra"
+)
+
+type combineSeedCommand struct {
+	Shares []string
+
+	cmd *cobra.Command
+}
+
+func newCombineSeedCommand() *cobra.Command {
+	cc := &combineSeedCommand{}
+	cc.cmd = &cobra.Command{
+		Use: "combineseed",
+		Short: "Combine the XOR shares created by splitseed into the " +
+			"original BIP39 mnemonic",
+		Long: `This command combines all shares that were created with
+the 'chantools splitseed' command into the original BIP39 mnemonic. The shares
+can be given in any order but all of them are required. Because a missing share
+can't be detected, make sure to pass every share that was created.`,
+		Example: `chantools combineseed
+
+chantools combineseed \
+	--share "abandon ... about" \
+	--share "zoo ... wrong"`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringArrayVar(
+		&cc.Shares, "share", nil, "a share mnemonic, must be "+
+			"specified once for each share; leave empty to read "+
+			"the shares from the terminal or stdin",
+	)
+
+	return cc.cmd
+}
+
+func (c *combineSeedCommand) Execute(_ *cobra.Command, _ []string) error {
+	shares := c.Shares
+	switch {
+	case len(shares) > 0:
+		warnSecretOnCommandLine("share")
+
+	default:
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			fmt.Printf("Input share %d (press enter without a "+
+				"share when done): ", len(shares)+1)
+			share, err := reader.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			fmt.Println()
+
+			share = strings.TrimRight(share, "\r\n")
+			if strings.TrimSpace(share) == "" {
+				break
+			}
+			shares = append(shares, share)
+		}
+	}
+
+	mnemonic, err := combineMnemonics(shares)
+	if err != nil {
+		return err
+	}
+
+	result := fmt.Sprintf("Mnemonic:\t%s\n", mnemonic)
+	fmt.Println(result)
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(result)
+
+	return nil
+}
+
+// combineMnemonics combines the given XOR share mnemonics into the original
+// mnemonic.
+func combineMnemonics(shareMnemonics []string) (string, error) {
+	if len(shareMnemonics) < 2 {
+		return "", fmt.Errorf("need at least two shares to combine")
+	}
+
+	shares := make([][]byte, len(shareMnemonics))
+	for idx, share := range shareMnemonics {
+		var err error
+		shares[idx], err = bip39.EntropyFromMnemonic(share)
+		if err != nil {
+			return "", fmt.Errorf("share %d is invalid: %w", idx+1,
+				err)
+		}
+	}
+
+	entropy := bip39.CombineEntropy(shares)
+	if entropy == nil {
+		return "", fmt.Errorf("all shares must have the same number " +
+			"of words")
+	}
+
+	return bip39.EntropyToMnemonic(entropy)
+}
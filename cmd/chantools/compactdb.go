@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/coreos/bbolt"
 	"github.com/spf13/cobra"
@@ -10,12 +11,24 @@ import (
 const (
 	dbFilePermission = 0600
 	defaultTxMaxSize = 65536
+
+	// defaultCompactThreshold is the default minimum size of a source DB
+	// file for compaction to be worth it.
+	defaultCompactThreshold = 10 * 1024 * 1024
+
+	// numBoltMetaPages is the number of pages any bbolt DB has in addition
+	// to the pages that contain the data: two meta pages, the free list
+	// page and the root bucket page.
+	numBoltMetaPages = 4
 )
 
 type compactDBCommand struct {
-	TxMaxSize int64
-	SourceDB  string
-	DestDB    string
+	TxMaxSize       int64
+	SourceDB        string
+	DestDB          string
+	DryRun          bool
+	Threshold       int64
+	IgnoreThreshold bool
 
 	cmd *cobra.Command
 }
@@ -27,8 +40,19 @@ func newCompactDBCommand() *cobra.Command {
 		Short: "Create a copy of a channel.db file in safe/read-only " +
 			"mode",
 		Long: `This command opens a database in read-only mode and tries
-to create a copy of it to a destination file, compacting it in the process.`,
-		Example: `chantools compactdb \
+to create a copy of it to a destination file, compacting it in the process.
+
+The size, number of buckets and number of keys of the source and destination
+database are reported. To find out whether compacting is worth the downtime,
+run the command with --dryrun first. That only walks the source database and
+estimates how much space would be reclaimed, without writing anything.
+
+Source database files smaller than --threshold bytes are not compacted, unless
+--ignore_threshold is set.`,
+		Example: `chantools compactdb --dryrun \
+	--sourcedb ~/.lnd/data/graph/mainnet/channel.db
+
+chantools compactdb \
 	--sourcedb ~/.lnd/data/graph/mainnet/channel.db \
 	--destdb ./results/compacted.db`,
 		RunE: cc.Execute,
@@ -45,6 +69,20 @@ to create a copy of it to a destination file, compacting it in the process.`,
 		&cc.DestDB, "destdb", "", "new lnd channel.db file to copy "+
 			"the compacted database to",
 	)
+	cc.cmd.Flags().BoolVar(
+		&cc.DryRun, "dryrun", false, "only walk the source DB and "+
+			"estimate the reclaimable space, don't write the "+
+			"destination DB",
+	)
+	cc.cmd.Flags().Int64Var(
+		&cc.Threshold, "threshold", defaultCompactThreshold, "minimum "+
+			"size in bytes of the source DB file for compaction "+
+			"to be worth it",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.IgnoreThreshold, "ignore_threshold", false, "compact the "+
+			"source DB even if it is smaller than the --threshold",
+	)
 
 	return cc.cmd
 }
@@ -54,7 +92,7 @@ func (c *compactDBCommand) Execute(_ *cobra.Command, _ []string) error {
 	if c.SourceDB == "" {
 		return fmt.Errorf("source channel DB is required")
 	}
-	if c.DestDB == "" {
+	if c.DestDB == "" && !c.DryRun {
 		return fmt.Errorf("destination channel DB is required")
 	}
 	if c.TxMaxSize <= 0 {
@@ -66,6 +104,40 @@ func (c *compactDBCommand) Execute(_ *cobra.Command, _ []string) error {
 	}
 	defer func() { _ = src.Close() }()
 
+	srcSize, err := fileSize(c.SourceDB)
+	if err != nil {
+		return err
+	}
+	srcStats, err := dbStats(src)
+	if err != nil {
+		return fmt.Errorf("error reading source DB stats: %w", err)
+	}
+	log.Infof("Source DB %s: %d bytes, %d buckets, %d keys", c.SourceDB,
+		srcSize, srcStats.BucketN, srcStats.KeyN)
+
+	// The compacted DB will roughly only contain the pages that are
+	// actually used, fully filled.
+	pageSize := int64(src.Info().PageSize)
+	estimatedSize := int64(srcStats.BranchInuse+srcStats.LeafInuse) +
+		numBoltMetaPages*pageSize
+	reclaimable := srcSize - estimatedSize
+	if reclaimable < 0 {
+		reclaimable = 0
+	}
+	log.Infof("Estimated size after compaction: %d bytes, estimated "+
+		"reclaimable space: %d bytes (%.1f%%)", estimatedSize,
+		reclaimable, percent(reclaimable, srcSize))
+
+	if c.DryRun {
+		return nil
+	}
+
+	if srcSize < c.Threshold && !c.IgnoreThreshold {
+		return fmt.Errorf("source DB is only %d bytes which is below "+
+			"the threshold of %d bytes, use --ignore_threshold "+
+			"to compact it anyway", srcSize, c.Threshold)
+	}
+
 	dst, err := c.openDB(c.DestDB, false)
 	if err != nil {
 		return fmt.Errorf("error opening destination DB: %w", err)
@@ -76,6 +148,21 @@ func (c *compactDBCommand) Execute(_ *cobra.Command, _ []string) error {
 	if err != nil {
 		return fmt.Errorf("error compacting DB: %w", err)
 	}
+
+	dstStats, err := dbStats(dst)
+	if err != nil {
+		return fmt.Errorf("error reading destination DB stats: %w",
+			err)
+	}
+	dstSize, err := fileSize(c.DestDB)
+	if err != nil {
+		return err
+	}
+	log.Infof("Destination DB %s: %d bytes, %d buckets, %d keys, "+
+		"reclaimed %d bytes (%.1f%%)", c.DestDB, dstSize,
+		dstStats.BucketN, dstStats.KeyN, srcSize-dstSize,
+		percent(srcSize-dstSize, srcSize))
+
 	return nil
 }
 
@@ -169,6 +256,45 @@ func (c *compactDBCommand) compact(dst, src *bbolt.DB) error {
 	return tx.Commit()
 }
 
+// dbStats returns the summed up statistics of all top level buckets of the
+// given DB.
+func dbStats(db *bbolt.DB) (*bbolt.BucketStats, error) {
+	stats := &bbolt.BucketStats{}
+	err := db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(_ []byte, b *bbolt.Bucket) error {
+			if b != nil {
+				stats.Add(b.Stats())
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// fileSize returns the size of the given file in bytes.
+func fileSize(fileName string) (int64, error) {
+	stat, err := os.Stat(fileName)
+	if err != nil {
+		return 0, fmt.Errorf("error reading file size of %s: %w",
+			fileName, err)
+	}
+
+	return stat.Size(), nil
+}
+
+// percent returns the given part of the total as a percentage.
+func percent(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(part) * 100 / float64(total)
+}
+
 // walkFunc is the type of the function called for keys (buckets and "normal"
 // values) discovered by Walk. keys is the list of keys to descend to the bucket
 // owning the discovered key/value pair k/v.
@@ -11,14 +11,16 @@ func TestCompactDBAndDumpChannels(t *testing.T) {
 
 	// Compact the test DB.
 	compact := &compactDBCommand{
-		SourceDB: h.testdataFile("channel.db"),
-		DestDB:   h.tempFile("compacted.db"),
+		SourceDB:        h.testdataFile("channel.db"),
+		DestDB:          h.tempFile("compacted.db"),
+		IgnoreThreshold: true,
 	}
 
 	err := compact.Execute(nil, nil)
 	require.NoError(t, err)
 
 	require.FileExists(t, compact.DestDB)
+	h.assertLogContains("Source DB " + compact.SourceDB + ": 106496 bytes")
 
 	// Compacting small DBs actually increases the size slightly. But we
 	// just want to make sure the contents match.
@@ -44,3 +46,34 @@ func TestCompactDBAndDumpChannels(t *testing.T) {
 
 	h.assertLogEqual(sourceDump, destDump)
 }
+
+func TestCompactDBDryRunAndThreshold(t *testing.T) {
+	h := newHarness(t)
+
+	// A dry run only estimates the reclaimable space and doesn't need a
+	// destination DB.
+	compact := &compactDBCommand{
+		SourceDB: h.testdataFile("channel.db"),
+		DryRun:   true,
+	}
+	err := compact.Execute(nil, nil)
+	require.NoError(t, err)
+	h.assertLogContains("estimated reclaimable space")
+	require.NotContains(t, h.getLog(), "Destination DB")
+
+	// A DB smaller than the threshold is only compacted with
+	// --ignore_threshold, the global --force flag only applies to output
+	// files.
+	Force = true
+	defer func() {
+		Force = false
+	}()
+	compact = &compactDBCommand{
+		SourceDB:  h.testdataFile("channel.db"),
+		DestDB:    h.tempFile("compacted.db"),
+		Threshold: defaultCompactThreshold,
+	}
+	err = compact.Execute(nil, nil)
+	require.ErrorContains(t, err, "use --ignore_threshold")
+	require.NoFileExists(t, compact.DestDB)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// configDirName is the name of the directory of the config file in the
+	// user's config directory.
+	configDirName = "chantools"
+
+	// configFileName is the name of the default config file.
+	configFileName = "chantools.yaml"
+
+	// configCommandsKey is the key of the section in the config file that
+	// contains the flags of the individual commands.
+	configCommandsKey = "commands"
+)
+
+var (
+	// secretFlags are the flags that are never read from the config file
+	// because the file is usually not protected as well as a secret
+	// should be. Flags like --xpub that accept public keys but also private
+	// keys are not part of the list, private keys are rejected by their
+	// value instead.
+	secretFlags = map[string]struct{}{
+		"bitcoind.rpcpass": {},
+		"entropy":          {},
+		"mnemonic":         {},
+		"passphrase":       {},
+		"preimage":         {},
+		"rootkey":          {},
+		"share":            {},
+	}
+)
+
+// defaultConfigFile returns the path of the config file in the user's config
+// directory, which is $XDG_CONFIG_HOME or ~/.config if that isn't set.
+func defaultConfigFile() string {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configDir = filepath.Join(homeDir, ".config")
+	}
+
+	return filepath.Join(configDir, configDirName, configFileName)
+}
+
+// loadConfigFile reads the given config file, or the default config file if
+// none is given, and uses its values for all flags of the command that were
+// not set on the command line. The top level keys of the file are the names
+// of the global flags, the flags of a single command can be set in a section
+// with the name of the command below the "commands" key. The path of the file
+// that was loaded is returned, or an empty string if there is no default
+// config file. Secrets in the file are ignored and returned as warnings.
+func loadConfigFile(cmd *cobra.Command, configFile string) (string, []string,
+	error) {
+
+	if configFile == "" {
+		configFile = defaultConfigFile()
+		if configFile == "" {
+			return "", nil, nil
+		}
+		_, err := os.Stat(configFile)
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil, nil
+		}
+	}
+
+	configBytes, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return "", nil, fmt.Errorf("error reading config file: %w",
+			err)
+	}
+
+	var config map[string]interface{}
+	if err := yaml.Unmarshal(configBytes, &config); err != nil {
+		return "", nil, fmt.Errorf("error parsing config file %s: %w",
+			configFile, err)
+	}
+
+	// The flags of the command contain the global flags as well, but we
+	// only want to allow flags that are available for all commands at the
+	// top level.
+	globalFlags := make(map[string]interface{}, len(config))
+	for name, value := range config {
+		if name == configCommandsKey {
+			continue
+		}
+		if cmd.Root().PersistentFlags().Lookup(name) == nil {
+			return "", nil, fmt.Errorf("unknown global flag %s in "+
+				"config file %s", name, configFile)
+		}
+		globalFlags[name] = value
+	}
+	warnings, err := applyConfigValues(cmd.Flags(), globalFlags)
+	if err != nil {
+		return "", nil, fmt.Errorf("error in config file %s: %w",
+			configFile, err)
+	}
+
+	commands, ok := config[configCommandsKey]
+	if !ok || commands == nil {
+		return configFile, warnings, nil
+	}
+	commandSections, ok := commands.(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("%s in config file %s must be a "+
+			"map of command names to flags", configCommandsKey,
+			configFile)
+	}
+
+	// Only the section of the command that is executed is applied, the
+	// other sections may contain flags this command doesn't know.
+	section, ok := commandSections[cmd.Name()]
+	if !ok || section == nil {
+		return configFile, warnings, nil
+	}
+	commandFlags, ok := section.(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("%s.%s in config file %s must be "+
+			"a map of flag names to values", configCommandsKey,
+			cmd.Name(), configFile)
+	}
+	commandWarnings, err := applyConfigValues(cmd.Flags(), commandFlags)
+	if err != nil {
+		return "", nil, fmt.Errorf("error in section %s.%s of config "+
+			"file %s: %w", configCommandsKey, cmd.Name(),
+			configFile, err)
+	}
+
+	return configFile, append(warnings, commandWarnings...), nil
+}
+
+// applyConfigValues sets the given values on the flags that were not set on
+// the command line already. List values are added one by one, so they can be
+// used for slice flags.
+func applyConfigValues(flags *pflag.FlagSet,
+	values map[string]interface{}) ([]string, error) {
+
+	// We apply the values in a stable order to get deterministic errors.
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var warnings []string
+	for _, name := range names {
+		if _, ok := secretFlags[name]; ok {
+			warnings = append(warnings, fmt.Sprintf("Ignoring "+
+				"secret --%s in config file, use a file or an "+
+				"environment variable instead", name))
+			continue
+		}
+
+		flag := flags.Lookup(name)
+		if flag == nil {
+			return nil, fmt.Errorf("unknown flag %s", name)
+		}
+
+		// Flags on the command line override the config file.
+		if flag.Changed {
+			continue
+		}
+
+		var configValues []interface{}
+		switch value := values[name].(type) {
+		case []interface{}:
+			configValues = value
+
+		case map[string]interface{}:
+			return nil, fmt.Errorf("flag %s must not be a map",
+				name)
+
+		case nil:
+			continue
+
+		default:
+			configValues = []interface{}{value}
+		}
+
+		for _, value := range configValues {
+			if isPrivateKey(fmt.Sprint(value)) {
+				return nil, fmt.Errorf("flag %s contains a "+
+					"private key, private keys must never "+
+					"be stored in the config file", name)
+			}
+
+			err := flag.Value.Set(fmt.Sprint(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %v for "+
+					"flag %s: %w", value, name, err)
+			}
+		}
+	}
+
+	return warnings, nil
+}
+
+// isPrivateKey returns true if the given value is an extended private key or a
+// private key in the WIF format, optionally prefixed with a key origin or a
+// script type like electrum does.
+func isPrivateKey(value string) bool {
+	value = strings.TrimSpace(value)
+	if idx := strings.LastIndexAny(value, "]:"); idx >= 0 {
+		value = value[idx+1:]
+	}
+	if idx := strings.Index(value, "/"); idx >= 0 {
+		value = value[:idx]
+	}
+
+	extendedKey, err := hdkeychain.NewKeyFromString(value)
+	if err == nil && extendedKey.IsPrivate() {
+		return true
+	}
+
+	_, err = btcutil.DecodeWIF(value)
+	return err == nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+network: testnet
+bitcoind.rpcpass: secret
+commands:
+  sweep:
+    apiurl: https://example.com/api
+    walletutxo:
+      - aa
+      - bb
+    preimage: cc
+    feerate: 5
+  other:
+    unknownflag: true
+`
+
+type configTestFlags struct {
+	network     string
+	rpcPass     string
+	apiURL      string
+	walletUtxos []string
+	feeRate     uint16
+}
+
+// newConfigTestCommand returns a root command with global flags and a sweep
+// sub command with its own flags, parsed with the given arguments.
+func newConfigTestCommand(t *testing.T,
+	args ...string) (*cobra.Command, *configTestFlags) {
+
+	flags := &configTestFlags{}
+	root := &cobra.Command{Use: "chantools"}
+	root.PersistentFlags().StringVar(&flags.network, "network", "", "")
+	root.PersistentFlags().StringVar(
+		&flags.rpcPass, "bitcoind.rpcpass", "", "",
+	)
+
+	cmd := &cobra.Command{Use: "sweep"}
+	cmd.Flags().StringVar(&flags.apiURL, "apiurl", defaultAPIURL, "")
+	cmd.Flags().StringSliceVar(
+		&flags.walletUtxos, "walletutxo", nil, "",
+	)
+	cmd.Flags().Uint16Var(&flags.feeRate, "feerate", 30, "")
+	root.AddCommand(cmd)
+
+	// Merge the persistent flags into the flags of the sub command, as
+	// cobra does when executing it.
+	cmd.InheritedFlags()
+	require.NoError(t, cmd.Flags().Parse(args))
+
+	return cmd, flags
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	h := newHarness(t)
+
+	configFile := h.tempFile("chantools.yaml")
+	require.NoError(t, ioutil.WriteFile(
+		configFile, []byte(testConfig), 0600,
+	))
+
+	cmd, flags := newConfigTestCommand(t, "--feerate", "10")
+	loaded, warnings, err := loadConfigFile(cmd, configFile)
+	require.NoError(t, err)
+	require.Equal(t, configFile, loaded)
+
+	// Flags on the command line take precedence, secrets are ignored.
+	require.Equal(t, "testnet", flags.network)
+	require.Equal(t, "https://example.com/api", flags.apiURL)
+	require.Equal(t, []string{"aa", "bb"}, flags.walletUtxos)
+	require.EqualValues(t, 10, flags.feeRate)
+	require.Empty(t, flags.rpcPass)
+	require.Len(t, warnings, 2)
+	require.Contains(t, warnings[0], "--bitcoind.rpcpass")
+	require.Contains(t, warnings[1], "--preimage")
+
+	// Only the section of the executed command is checked for flags it
+	// doesn't know.
+	cmd, _ = newConfigTestCommand(t)
+	cmd.Use = "other"
+	_, _, err = loadConfigFile(cmd, configFile)
+	require.ErrorContains(t, err, "unknown flag unknownflag")
+
+	// A slice flag given on the command line replaces the list.
+	cmd, flags = newConfigTestCommand(t, "--walletutxo", "cc")
+	_, _, err = loadConfigFile(cmd, configFile)
+	require.NoError(t, err)
+	require.Equal(t, []string{"cc"}, flags.walletUtxos)
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	h := newHarness(t)
+
+	cmd, _ := newConfigTestCommand(t)
+	_, _, err := loadConfigFile(cmd, h.tempFile("missing.yaml"))
+	require.ErrorContains(t, err, "error reading config file")
+
+	testCases := map[string]string{
+		"network: [":            "error parsing config file",
+		"apiurl: x":             "unknown global flag apiurl",
+		"commands: x":           "must be a map of command names",
+		"commands:\n  sweep: x": "must be a map of flag names",
+		"network:\n  a: b":      "flag network must not be a map",
+		"commands:\n  sweep:\n    feerate: x": "invalid value x for " +
+			"flag feerate",
+	}
+	for config, expectedErr := range testCases {
+		configFile := h.tempFile("chantools.yaml")
+		require.NoError(t, ioutil.WriteFile(
+			configFile, []byte(config), 0600,
+		))
+
+		cmd, _ := newConfigTestCommand(t)
+		_, _, err := loadConfigFile(cmd, configFile)
+		require.ErrorContains(t, err, expectedErr, config)
+	}
+
+	// Private keys are rejected in any flag, also in the ones that accept
+	// public keys.
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	privKey, err := extendedKey.ECPrivKey()
+	require.NoError(t, err)
+	wif, err := btcutil.NewWIF(privKey, chainParams, true)
+	require.NoError(t, err)
+	xpub, err := extendedKey.Neuter()
+	require.NoError(t, err)
+
+	for _, value := range []string{
+		rootKeyAezeed,
+		"[0102abcd/48h/1h/0h/2h]" + rootKeyAezeed + "/0/*",
+		wif.String(),
+		"p2wpkh:" + wif.String(),
+	} {
+		configFile := h.tempFile("chantools.yaml")
+		require.NoError(t, ioutil.WriteFile(configFile, []byte(
+			"commands:\n  sweep:\n    walletutxo:\n      - aa\n"+
+				"      - '"+value+"'",
+		), 0600))
+
+		cmd, flags := newConfigTestCommand(t)
+		_, _, err := loadConfigFile(cmd, configFile)
+		require.ErrorContains(t, err, "flag walletutxo contains a "+
+			"private key", value)
+		require.NotContains(t, flags.walletUtxos, value)
+	}
+
+	configFile := h.tempFile("chantools.yaml")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(
+		"commands:\n  sweep:\n    walletutxo: "+xpub.String(),
+	), 0600))
+	cmd, flags := newConfigTestCommand(t)
+	_, _, err = loadConfigFile(cmd, configFile)
+	require.NoError(t, err)
+	require.Equal(t, []string{xpub.String()}, flags.walletUtxos)
+}
+
+func TestDefaultConfigFile(t *testing.T) {
+	h := newHarness(t)
+
+	// A missing default config file is not an error.
+	require.NoError(t, os.Setenv("XDG_CONFIG_HOME", h.tempDir))
+	cmd, flags := newConfigTestCommand(t)
+	loaded, _, err := loadConfigFile(cmd, "")
+	require.NoError(t, err)
+	require.Empty(t, loaded)
+
+	configFile := filepath.Join(h.tempDir, configDirName, configFileName)
+	require.Equal(t, configFile, defaultConfigFile())
+	require.NoError(t, os.MkdirAll(filepath.Dir(configFile), 0700))
+	require.NoError(t, ioutil.WriteFile(
+		configFile, []byte("network: signet"), 0600,
+	))
+
+	loaded, _, err = loadConfigFile(cmd, "")
+	require.NoError(t, err)
+	require.Equal(t, configFile, loaded)
+	require.Equal(t, "signet", flags.network)
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/bip39"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/aezeed"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	convertSeedToBIP39  = "bip39"
+	convertSeedToAezeed = "aezeed"
+
+	convertSeedFormat = `
+Entropy:			%x
+Aezeed birthday:		%s
+Aezeed:				%s
+BIP39 mnemonic:			%s
+lnd HD root key (aezeed):	%v
+BIP39 HD root key:		%v
+`
+
+	convertSeedWarning = "!!! WARNING !!! The aezeed and the BIP39 " +
+		"mnemonic encode the same entropy, but lnd and BIP39 " +
+		"wallets derive a DIFFERENT root key from it (see the root " +
+		"keys above). A wallet restored from the converted seed " +
+		"will NOT contain the funds of the original one!"
+)
+
+type convertSeedCommand struct {
+	To       string
+	Birthday string
+
+	cmd *cobra.Command
+}
+
+func newConvertSeedCommand() *cobra.Command {
+	cc := &convertSeedCommand{}
+	cc.cmd = &cobra.Command{
+		Use: "convertseed",
+		Short: "Convert the entropy of an lnd aezeed to a BIP39 " +
+			"mnemonic or vice versa",
+		Long: `This command reads an lnd aezeed (with its passphrase)
+and prints the 128 bits of entropy it contains, encoded as a 12 word BIP39
+mnemonic. With --to aezeed, a 12 word BIP39 mnemonic is read instead and its
+entropy is encrypted into a new aezeed with the given passphrase and birthday.
+
+**The wallet of the converted seed is NOT the same as the original one!**
+lnd uses the aezeed entropy directly as the BIP32 seed, while BIP39 wallets
+derive the BIP32 seed from the mnemonic words and passphrase. So the same
+entropy results in completely different keys and addresses. This command is
+only meant to move the entropy between the two formats, for example to store it
+on a device that only supports one of them. To access the funds of an lnd
+wallet, always use the original aezeed.
+
+The aezeed is read from the AEZEED_MNEMONIC and AEZEED_PASSPHRASE environment
+variables or the terminal, the BIP39 mnemonic from SEED_MNEMONIC or the
+terminal. The passphrase of a new aezeed is read from AEZEED_PASSPHRASE or the
+terminal.`,
+		Example: `chantools convertseed
+
+chantools convertseed --to aezeed --birthday 2022-01-31`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.To, "to", convertSeedToBIP39, "the format to convert the "+
+			"seed to; must be either bip39 (reads an aezeed) or "+
+			"aezeed (reads a BIP39 mnemonic)",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.Birthday, "birthday", "", "the birthday of the new aezeed "+
+			"in the format YYYY-MM-DD, lnd rescans the chain "+
+			"from that date (rounded down to full days since the "+
+			"genesis block); defaults to the genesis block",
+	)
+
+	return cc.cmd
+}
+
+func (c *convertSeedCommand) Execute(_ *cobra.Command, _ []string) error {
+	var (
+		cipherSeed *aezeed.CipherSeed
+		passphrase string
+		err        error
+	)
+	switch c.To {
+	case convertSeedToBIP39:
+		cipherSeed, err = lnd.ReadCipherSeedWithSecrets(
+			os.Getenv(lnd.MnemonicEnvName),
+			os.Getenv(lnd.PassphraseEnvName),
+		)
+		if err != nil {
+			return fmt.Errorf("error reading aezeed: %w", err)
+		}
+
+	case convertSeedToAezeed:
+		birthday := aezeed.BitcoinGenesisDate
+		if c.Birthday != "" {
+			birthday, err = time.Parse("2006-01-02", c.Birthday)
+			if err != nil {
+				return fmt.Errorf("error parsing birthday: %w",
+					err)
+			}
+		}
+
+		mnemonic, err := readBIP39Mnemonic()
+		if err != nil {
+			return err
+		}
+		passphrase, err = readNewAezeedPassphrase()
+		if err != nil {
+			return err
+		}
+
+		cipherSeed, err = bip39ToCipherSeed(mnemonic, birthday)
+		if err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("invalid --to %s, must be either %s or %s",
+			c.To, convertSeedToBIP39, convertSeedToAezeed)
+	}
+
+	result, err := convertSeedResult(cipherSeed, passphrase)
+	if err != nil {
+		return err
+	}
+	fmt.Println(result)
+	log.Warn(convertSeedWarning)
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(result)
+
+	return nil
+}
+
+// bip39ToCipherSeed creates an aezeed cipher seed with the entropy of the given
+// 12 word BIP39 mnemonic.
+func bip39ToCipherSeed(mnemonic string,
+	birthday time.Time) (*aezeed.CipherSeed, error) {
+
+	mnemonic = strings.TrimRight(mnemonic, "\r\n")
+	entropy, err := bip39.EntropyFromMnemonic(mnemonic)
+	if err != nil {
+		return nil, fmt.Errorf("mnemonic is invalid: %w", err)
+	}
+
+	if len(entropy) != aezeed.EntropySize {
+		return nil, fmt.Errorf("an aezeed contains %d bits of "+
+			"entropy, only a 12 word BIP39 mnemonic can be "+
+			"converted, got %d words", aezeed.EntropySize*8,
+			len(strings.Fields(mnemonic)))
+	}
+
+	if birthday.Before(aezeed.BitcoinGenesisDate) {
+		birthday = aezeed.BitcoinGenesisDate
+	}
+
+	var seedEntropy [aezeed.EntropySize]byte
+	copy(seedEntropy[:], entropy)
+	cipherSeed, err := aezeed.New(
+		aezeed.CipherSeedVersion, &seedEntropy, birthday,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating aezeed: %w", err)
+	}
+
+	return cipherSeed, nil
+}
+
+// convertSeedResult formats the entropy of the given cipher seed in both seed
+// formats, together with the root keys both wallet types derive from it.
+func convertSeedResult(cipherSeed *aezeed.CipherSeed,
+	passphrase string) (string, error) {
+
+	aezeedMnemonic, err := cipherSeed.ToMnemonic([]byte(passphrase))
+	if err != nil {
+		return "", fmt.Errorf("error encoding aezeed: %w", err)
+	}
+
+	mnemonic, err := bip39.EntropyToMnemonic(cipherSeed.Entropy[:])
+	if err != nil {
+		return "", fmt.Errorf("error encoding BIP39 mnemonic: %w", err)
+	}
+
+	lndRootKey, err := hdkeychain.NewMaster(
+		cipherSeed.Entropy[:], chainParams,
+	)
+	if err != nil {
+		return "", fmt.Errorf("error deriving lnd root key: %w", err)
+	}
+	bip39RootKey, err := bip39.MasterKeyFromMnemonic(
+		mnemonic, "", chainParams,
+	)
+	if err != nil {
+		return "", fmt.Errorf("error deriving BIP39 root key: %w", err)
+	}
+
+	return fmt.Sprintf(
+		convertSeedFormat, cipherSeed.Entropy[:],
+		cipherSeed.BirthdayTime().Format("2006-01-02"),
+		strings.Join(aezeedMnemonic[:], " "), mnemonic, lndRootKey,
+		bip39RootKey,
+	), nil
+}
+
+// readBIP39Mnemonic reads a BIP39 mnemonic from the environment or the
+// terminal.
+func readBIP39Mnemonic() (string, error) {
+	mnemonic := os.Getenv(btc.BIP39MnemonicEnvName)
+	if mnemonic != "" {
+		return mnemonic, nil
+	}
+
+	fmt.Printf("Input your 12 word BIP39 mnemonic separated by spaces: ")
+	reader := bufio.NewReader(os.Stdin)
+	mnemonic, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	fmt.Println()
+
+	return mnemonic, nil
+}
+
+// readNewAezeedPassphrase reads the passphrase to encrypt a new aezeed with
+// from the environment or the terminal. A passphrase of a single dash (-) in
+// the environment means no passphrase is used.
+func readNewAezeedPassphrase() (string, error) {
+	passphrase := strings.TrimSpace(os.Getenv(lnd.PassphraseEnvName))
+	switch passphrase {
+	case "-":
+		return "", nil
+
+	case "":
+		fmt.Printf("Input the passphrase to encrypt the new aezeed " +
+			"with (press enter for no passphrase): ")
+		passphraseBytes, err := terminal.ReadPassword(
+			int(syscall.Stdin), // nolint
+		)
+		if err != nil {
+			return "", err
+		}
+		fmt.Println()
+
+		return string(passphraseBytes), nil
+
+	default:
+		return passphrase, nil
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/aezeed"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertSeedToBIP39(t *testing.T) {
+	h := newHarness(t)
+
+	convert := &convertSeedCommand{
+		To: convertSeedToBIP39,
+	}
+
+	t.Setenv(lnd.MnemonicEnvName, seedAezeedNoPassphrase)
+	t.Setenv(lnd.PassphraseEnvName, "-")
+
+	err := convert.Execute(nil, nil)
+	require.NoError(t, err)
+
+	h.assertLogContains(seedAezeedNoPassphrase)
+	h.assertLogContains(rootKeyAezeed)
+	h.assertLogContains("BIP39 mnemonic:")
+}
+
+func TestConvertSeedRoundTrip(t *testing.T) {
+	h := newHarness(t)
+
+	convert := &convertSeedCommand{
+		To:       convertSeedToAezeed,
+		Birthday: "2022-01-31",
+	}
+
+	t.Setenv(btc.BIP39MnemonicEnvName, seedBip39)
+	t.Setenv(lnd.PassphraseEnvName, testPassPhrase)
+
+	err := convert.Execute(nil, nil)
+	require.NoError(t, err)
+
+	// The aezeed birthday is rounded down to full days since the genesis
+	// block, which was mined in the evening.
+	h.assertLogContains("Aezeed birthday:\t\t2022-01-30")
+	h.assertLogContains(rootKeyBip39)
+
+	birthday := time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC)
+	cipherSeed, err := bip39ToCipherSeed(seedBip39, birthday)
+	require.NoError(t, err)
+	mnemonic, err := cipherSeed.ToMnemonic([]byte(testPassPhrase))
+	require.NoError(t, err)
+
+	decoded, err := lnd.ReadCipherSeedWithSecrets(
+		strings.Join(mnemonic[:], " "), testPassPhrase,
+	)
+	require.NoError(t, err)
+	require.Equal(t, cipherSeed.Entropy, decoded.Entropy)
+
+	result, err := convertSeedResult(decoded, testPassPhrase)
+	require.NoError(t, err)
+	require.Contains(t, result, "BIP39 mnemonic:\t\t\t"+seedBip39)
+}
+
+func TestConvertSeedInvalidLength(t *testing.T) {
+	mnemonic24 := strings.Repeat("abandon ", 23) + "art"
+
+	_, err := bip39ToCipherSeed(mnemonic24, aezeed.BitcoinGenesisDate)
+	require.ErrorContains(t, err, "only a 12 word BIP39 mnemonic")
+}
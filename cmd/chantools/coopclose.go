@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/spf13/cobra"
+)
+
+const coopCloseFormat = `Cooperative close transaction %s created.
+
+Funding output:			%v (%d sats)
+Our balance:			%d sats
+Their balance:			%d sats
+Fee:				%d sats
+Our multisig pubkey:		%x
+
+Send the PSBT below to the peer and ask them to verify and co-sign it, for
+example with the following command:
+
+chantools signrescuefunding --amount %d --remotepubkey %x --psbt <psbt>
+
+%s
+`
+
+type coopCloseCommand struct {
+	ChannelDB        string
+	ChannelPoint     string
+	SweepAddr        string
+	DestDescriptor   string
+	DestIndex        uint32
+	DestGapLimit     uint32
+	RemoteAddr       string
+	FeeRate          uint16
+	PeerCommitHeight int64
+	APIURL           string
+
+	rootKey *rootKey
+	cmd     *cobra.Command
+}
+
+func newCoopCloseCommand() *cobra.Command {
+	cc := &coopCloseCommand{}
+	cc.cmd = &cobra.Command{
+		Use: "coopclose",
+		Short: "Create a cooperative close transaction from the " +
+			"latest channel state in the channel.db for the peer " +
+			"to co-sign",
+		Long: `If the channel.db of a node is intact but lnd can't be
+started anymore, this command can be used to cooperatively close a channel
+offline, with the help of the peer.
+
+The latest state of the channel is read from the channel.db and a cooperative
+close transaction that pays both parties their settled balance to the given
+delivery addresses is created. The channel initiator pays the fee. If an
+upfront shutdown script was negotiated for a party, the delivery address of
+that party must pay to that script, it is used if no address is given.
+
+Before anything is signed, the command makes sure the channel state in the DB
+is final: there must not be any HTLCs or unrevoked commitments in flight and
+the funding output must still be unspent. To make sure the DB isn't stale, the
+peer should report the number of updates of the channel (for example num_updates
+in the output of lncli listchannels), which must match the commitment height
+recorded in the DB.
+
+The result is a PSBT that contains our signature. The peer can verify and
+co-sign it with the signrescuefunding command (or any other PSBT capable
+signer) and then publish the final transaction.
+
+This is a much gentler way of recovering the funds than force-closing the
+channel, as there are no time locks and no risk of publishing a revoked state.`,
+		Example: `chantools coopclose \
+	--channeldb ~/.lnd/data/graph/mainnet/channel.db \
+	--channelpoint xxxxxxx:xx \
+	--sweepaddr bc1qxxxxxxxxx \
+	--remoteaddr bc1qyyyyyyyyy \
+	--peercommitheight 1234 \
+	--feerate 10`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.ChannelDB, "channeldb", "", "lnd channel.db file to read "+
+			"the latest channel state from",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.ChannelPoint, "channelpoint", "", "funding transaction "+
+			"outpoint of the channel to close (<txid>:<txindex>)",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.SweepAddr, "sweepaddr", "", "address our balance should "+
+			"be paid to; can be omitted if an upfront shutdown "+
+			"script was negotiated",
+	)
+	addDestDescriptorFlags(
+		cc.cmd, &cc.DestDescriptor, &cc.DestIndex, &cc.DestGapLimit,
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.RemoteAddr, "remoteaddr", "", "address the balance of the "+
+			"peer should be paid to; can be omitted if the peer "+
+			"negotiated an upfront shutdown script",
+	)
+	cc.cmd.Flags().Uint16Var(
+		&cc.FeeRate, "feerate", defaultFeeSatPerVByte, "fee rate to "+
+			"use for the close transaction in sat/vByte",
+	)
+	cc.cmd.Flags().Int64Var(
+		&cc.PeerCommitHeight, "peercommitheight", -1, "the number "+
+			"of updates of the channel as reported by the peer; "+
+			"if set, it must match the commitment height in the "+
+			"DB, otherwise the DB might be stale",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
+			"be esplora compatible)",
+	)
+
+	cc.rootKey = newRootKey(cc.cmd, "signing the close transaction")
+
+	return cc.cmd
+}
+
+func (c *coopCloseCommand) Execute(_ *cobra.Command, _ []string) error {
+	extendedKey, err := c.rootKey.read()
+	if err != nil {
+		return fmt.Errorf("error reading root key: %w", err)
+	}
+
+	if c.ChannelDB == "" {
+		return fmt.Errorf("channel DB is required")
+	}
+	if c.ChannelPoint == "" {
+		return fmt.Errorf("channel point is required")
+	}
+	chanPoint, err := lnd.ParseOutpoint(c.ChannelPoint)
+	if err != nil {
+		return fmt.Errorf("error parsing channel point: %w", err)
+	}
+
+	db, err := lnd.OpenDB(c.ChannelDB, true)
+	if err != nil {
+		return fmt.Errorf("error opening channel DB: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	channel, err := db.ChannelStateDB().FetchChannel(nil, *chanPoint)
+	if err != nil {
+		return fmt.Errorf("error loading channel %v from DB: %w",
+			chanPoint, err)
+	}
+
+	if c.PeerCommitHeight < 0 {
+		log.Warnf("The commitment height of the peer wasn't given " +
+			"with --peercommitheight, can't verify the channel " +
+			"DB isn't stale")
+	}
+
+	// The delivery addresses can also be derived from a descriptor.
+	c.SweepAddr, err = sweepDestination(
+		c.SweepAddr, c.DestDescriptor, c.DestIndex, c.DestGapLimit,
+		c.APIURL,
+	)
+	if err != nil {
+		return err
+	}
+	localScript, err := deliveryScript(
+		c.SweepAddr, channel.LocalShutdownScript, "our",
+	)
+	if err != nil {
+		return err
+	}
+	remoteScript, err := deliveryScript(
+		c.RemoteAddr, channel.RemoteShutdownScript, "the peer's",
+	)
+	if err != nil {
+		return err
+	}
+
+	api, err := newChainBackend(c.APIURL)
+	if err != nil {
+		return err
+	}
+	signer := &lnd.Signer{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+
+	packet, err := coopClose(
+		api, signer, channel, c.PeerCommitHeight, localScript,
+		remoteScript, btcutil.Amount(c.FeeRate),
+	)
+	if err != nil {
+		return err
+	}
+
+	// We're done, we can now output the partially signed PSBT.
+	written, err := writePsbtOutput(packet)
+	if err != nil || written {
+		return err
+	}
+	base64, err := packet.B64Encode()
+	if err != nil {
+		return fmt.Errorf("error encoding PSBT: %w", err)
+	}
+
+	var (
+		closeTx   = packet.UnsignedTx
+		ourKey    = channel.LocalChanCfg.MultiSigKey.PubKey
+		ourValue  int64
+		fee       = int64(channel.Capacity)
+		peerValue int64
+	)
+	for _, out := range closeTx.TxOut {
+		fee -= out.Value
+		switch {
+		case bytes.Equal(out.PkScript, localScript):
+			ourValue = out.Value
+
+		case bytes.Equal(out.PkScript, remoteScript):
+			peerValue = out.Value
+		}
+	}
+	result := fmt.Sprintf(
+		coopCloseFormat, closeTx.TxHash(), chanPoint,
+		int64(channel.Capacity), ourValue, peerValue, fee,
+		ourKey.SerializeCompressed(), int64(channel.Capacity),
+		ourKey.SerializeCompressed(), base64,
+	)
+	fmt.Println(result)
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(result)
+
+	return nil
+}
+
+// deliveryScript returns the output script for the given delivery address. If
+// an upfront shutdown script was negotiated, the address must pay to it and
+// can be omitted.
+func deliveryScript(addr string, upfrontScript []byte, party string) ([]byte,
+	error) {
+
+	if addr == "" {
+		if len(upfrontScript) == 0 {
+			return nil, fmt.Errorf("%s delivery address is "+
+				"required, no upfront shutdown script was "+
+				"negotiated", party)
+		}
+
+		return upfrontScript, nil
+	}
+
+	parsedAddr, err := btcutil.DecodeAddress(addr, chainParams)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s delivery address: %w",
+			party, err)
+	}
+	if !parsedAddr.IsForNet(chainParams) {
+		return nil, fmt.Errorf("%s delivery address %s is not valid "+
+			"for network %s", party, addr, chainParams.Name)
+	}
+	script, err := txscript.PayToAddrScript(parsedAddr)
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s delivery script: %w",
+			party, err)
+	}
+
+	if len(upfrontScript) > 0 && !bytes.Equal(script, upfrontScript) {
+		return nil, fmt.Errorf("%s delivery address %s doesn't match "+
+			"the upfront shutdown script %x that was negotiated",
+			party, addr, upfrontScript)
+	}
+
+	return script, nil
+}
+
+// verifyCoopCloseState makes sure the latest state of the channel in the DB is
+// final, so it can be used for a cooperative close. If the peer commitment
+// height isn't negative, it must match the height of the remote commitment.
+func verifyCoopCloseState(channel *channeldb.OpenChannel,
+	peerCommitHeight int64) error {
+
+	if channel.ChanType.IsTaproot() {
+		return lnd.ErrSimpleTaprootUnsupported
+	}
+
+	switch {
+	case channel.HasChanStatus(channeldb.ChanStatusRestored):
+		return fmt.Errorf("channel was restored from a backup, the " +
+			"DB doesn't contain its state")
+
+	case channel.HasChanStatus(channeldb.ChanStatusLocalDataLoss):
+		return fmt.Errorf("channel state in the DB is marked as lost")
+
+	case channel.HasChanStatus(channeldb.ChanStatusCommitBroadcasted):
+		return fmt.Errorf("channel was already force-closed")
+	}
+
+	localCommit := channel.LocalCommitment
+	remoteCommit := channel.RemoteCommitment
+	if len(localCommit.Htlcs) > 0 || len(remoteCommit.Htlcs) > 0 {
+		return fmt.Errorf("channel has %d HTLC(s) on our and %d "+
+			"HTLC(s) on the remote commitment, it can only be "+
+			"closed cooperatively without HTLCs",
+			len(localCommit.Htlcs), len(remoteCommit.Htlcs))
+	}
+
+	// An unrevoked commitment of the peer means the balances are still
+	// being updated.
+	pendingCommit, err := channel.RemoteCommitChainTip()
+	switch {
+	case err == nil:
+		return fmt.Errorf("remote commitment at height %d isn't "+
+			"revoked yet, the channel state is still being "+
+			"updated", pendingCommit.Commitment.CommitHeight)
+
+	case !errors.Is(err, channeldb.ErrNoPendingCommit):
+		return fmt.Errorf("error reading pending commitment: %w", err)
+	}
+
+	if localCommit.LocalBalance != remoteCommit.LocalBalance ||
+		localCommit.RemoteBalance != remoteCommit.RemoteBalance {
+
+		return fmt.Errorf("balances of our commitment (height %d) "+
+			"and the remote commitment (height %d) differ, the "+
+			"channel state is still being updated",
+			localCommit.CommitHeight, remoteCommit.CommitHeight)
+	}
+
+	// The number of updates the peer reports is the height of their
+	// commitment, which is our remote commitment. If theirs is higher,
+	// our DB is stale and the balances would be wrong.
+	if peerCommitHeight >= 0 &&
+		uint64(peerCommitHeight) != remoteCommit.CommitHeight {
+
+		return fmt.Errorf("peer reports commitment height %d but the "+
+			"DB contains height %d, refusing to propose a "+
+			"stale state", peerCommitHeight,
+			remoteCommit.CommitHeight)
+	}
+
+	return nil
+}
+
+// coopClose creates a PSBT of the cooperative close transaction of the given
+// channel that already contains our signature.
+func coopClose(api btc.ChainBackend, signer *lnd.Signer,
+	channel *channeldb.OpenChannel, peerCommitHeight int64, localScript,
+	remoteScript []byte, feeRate btcutil.Amount) (*psbt.Packet, error) {
+
+	err := verifyCoopCloseState(channel, peerCommitHeight)
+	if err != nil {
+		return nil, fmt.Errorf("cannot close channel %v: %w",
+			channel.FundingOutpoint, err)
+	}
+
+	lc := &lnd.LightningChannel{
+		LocalChanCfg:  channel.LocalChanCfg,
+		RemoteChanCfg: channel.RemoteChanCfg,
+		ChannelState:  channel,
+		TXSigner:      signer,
+	}
+	if err := lc.CreateSignDesc(); err != nil {
+		return nil, err
+	}
+
+	// Make sure the funding output wasn't spent in the meantime.
+	chanPoint := channel.FundingOutpoint
+	tx, err := api.Transaction(chanPoint.Hash.String())
+	if err != nil {
+		return nil, fmt.Errorf("error fetching funding TX %v: %w",
+			chanPoint.Hash, err)
+	}
+	if int(chanPoint.Index) >= len(tx.Vout) {
+		return nil, fmt.Errorf("funding TX %v has no output with "+
+			"index %d", chanPoint.Hash, chanPoint.Index)
+	}
+	fundingOut := tx.Vout[chanPoint.Index]
+	if fundingOut.Outspend != nil && fundingOut.Outspend.Spent {
+		return nil, fmt.Errorf("funding output %v is already spent "+
+			"by TX %s", chanPoint, fundingOut.Outspend.Txid)
+	}
+
+	// The initiator pays the fee for the close transaction.
+	var estimator input.TxWeightEstimator
+	estimator.AddWitnessInput(MultiSigWitnessSize)
+	estimator.AddTxOutput(&wire.TxOut{PkScript: localScript})
+	estimator.AddTxOutput(&wire.TxOut{PkScript: remoteScript})
+	feeRateKWeight := chainfee.SatPerKVByte(1000 * feeRate).FeePerKWeight()
+	totalFee := feeRateKWeight.FeeForWeight(int64(estimator.Weight()))
+
+	ourBalance, theirBalance, err := lnwallet.CoopCloseBalance(
+		channel.ChanType, channel.IsInitiator, totalFee,
+		channel.LocalCommitment,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	closeTx := lnwallet.CreateCooperativeCloseTx(
+		*wire.NewTxIn(&chanPoint, nil, nil),
+		channel.LocalChanCfg.DustLimit, channel.RemoteChanCfg.DustLimit,
+		ourBalance, theirBalance, localScript, remoteScript,
+	)
+	err = blockchain.CheckTransactionSanity(btcutil.NewTx(closeTx))
+	if err != nil {
+		return nil, fmt.Errorf("invalid close transaction: %w", err)
+	}
+
+	packet, err := psbt.NewFromUnsignedTx(closeTx)
+	if err != nil {
+		return nil, fmt.Errorf("error creating PSBT: %w", err)
+	}
+	packet.Inputs[0] = psbt.PInput{
+		WitnessUtxo:   lc.SignDesc.Output,
+		WitnessScript: lc.SignDesc.WitnessScript,
+		Unknowns: []*psbt.Unknown{{
+			// We add the public key the peer needs to sign with as
+			// a proprietary field, so the signrescuefunding command
+			// can be used to co-sign.
+			Key: PsbtKeyTypeOutputMissingSigPubkey,
+			Value: lc.RemoteChanCfg.MultiSigKey.PubKey.
+				SerializeCompressed(),
+		}},
+	}
+
+	// Outputs below the dust limit are added to the fee, so the fee rate
+	// can be higher than the chosen one and only the hard cap applies.
+	err = validatePsbt(api, packet, int64(estimator.VSize()), 0)
+	if err != nil {
+		return nil, fmt.Errorf("error validating close TX: %w", err)
+	}
+
+	err = signer.AddPartialSignature(
+		packet, lc.LocalChanCfg.MultiSigKey, lc.SignDesc.Output,
+		lc.SignDesc.WitnessScript, 0,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error adding partial signature: %w",
+			err)
+	}
+
+	log.Infof("Created cooperative close TX %v for channel %v at "+
+		"commitment height %d", closeTx.TxHash(), chanPoint,
+		channel.LocalCommitment.CommitHeight)
+
+	return packet, nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	coopCloseInitiatorChannel = "10279f62619634058b6133cb7ac6c1693a8e6df7c" +
+		"aa91c6263ca3d0bf704ad4d:0"
+	coopCloseResponderChannel = "9e7004ccf0cb19eb2d967aa0142e3476b4d27874d" +
+		"a7048e61b61fdbacf9200d3:0"
+)
+
+// newFundingTestChain returns a fake chain backend that knows the funding TXs
+// of all channels in the test channel DB with the given confirmation status,
+// with the funding output at the index of the channel point. The channel points
+// are returned as well.
+func newFundingTestChain(t *testing.T, h *harness,
+	status *btc.Status) (*fakeChainBackend, []wire.OutPoint) {
+
+	db, err := lnd.OpenDB(h.testdataFile("channel.db"), true)
+	require.NoError(t, err)
+	channels, err := db.ChannelStateDB().FetchAllChannels()
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	var (
+		chain      = newFakeChainBackend()
+		chanPoints []wire.OutPoint
+	)
+	for _, channel := range channels {
+		_, fundingOut, err := input.GenFundingPkScript(
+			channel.LocalChanCfg.MultiSigKey.PubKey.
+				SerializeCompressed(),
+			channel.RemoteChanCfg.MultiSigKey.PubKey.
+				SerializeCompressed(),
+			int64(channel.Capacity),
+		)
+		require.NoError(t, err)
+
+		chanPoint := channel.FundingOutpoint
+		vout := make([]*btc.Vout, chanPoint.Index+1)
+		for idx := range vout {
+			vout[idx] = &btc.Vout{}
+		}
+		vout[chanPoint.Index] = &btc.Vout{
+			ScriptPubkey: hex.EncodeToString(fundingOut.PkScript),
+			Value:        uint64(fundingOut.Value),
+		}
+		chain.addAPITx(&btc.TX{
+			TXID:   chanPoint.Hash.String(),
+			Vout:   vout,
+			Status: status,
+		})
+		chanPoints = append(chanPoints, chanPoint)
+	}
+
+	return chain, chanPoints
+}
+
+// testDeliveryAddr returns a regtest P2WKH address with the given byte as
+// the key hash.
+func testDeliveryAddr(t *testing.T, b byte) string {
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		bytes.Repeat([]byte{b}, 20), chainParams,
+	)
+	require.NoError(t, err)
+
+	return addr.String()
+}
+
+func TestCoopClose(t *testing.T) {
+	h := newHarness(t)
+
+	chain, chanPoints := newFundingTestChain(t, h, nil)
+	coopClose := &coopCloseCommand{
+		ChannelDB:        h.testdataFile("channel.db"),
+		ChannelPoint:     coopCloseInitiatorChannel,
+		SweepAddr:        testDeliveryAddr(t, 0x01),
+		RemoteAddr:       testDeliveryAddr(t, 0x02),
+		FeeRate:          10,
+		PeerCommitHeight: 0,
+		APIURL:           chain.serve(t),
+		rootKey:          &rootKey{RootKey: rootKeyAezeed},
+	}
+	require.NoError(t, coopClose.Execute(nil, nil))
+
+	// We are the initiator, so we pay the fee. The peer has no balance, so
+	// their output is omitted.
+	h.assertLogContains("Our balance:			15998310 sats")
+	h.assertLogContains("Their balance:			0 sats")
+	h.assertLogContains("Fee:				1690 sats")
+
+	// The peer pays the fee if they opened the channel.
+	h.clearLog()
+	coopClose.ChannelPoint = coopCloseResponderChannel
+	require.NoError(t, coopClose.Execute(nil, nil))
+	h.assertLogContains("Our balance:			0 sats")
+	h.assertLogContains("Their balance:			14998310 sats")
+
+	// A stale DB is detected by the commitment height.
+	coopClose.PeerCommitHeight = 3
+	err := coopClose.Execute(nil, nil)
+	require.ErrorContains(t, err, "refusing to propose a stale state")
+
+	// The funding output must not be spent yet.
+	for _, chanPoint := range chanPoints {
+		chain.spend(t, chanPoint, scbCloseTxid, nil)
+	}
+	coopClose.PeerCommitHeight = -1
+	err = coopClose.Execute(nil, nil)
+	require.ErrorContains(t, err, "is already spent")
+}
+
+func TestVerifyCoopCloseState(t *testing.T) {
+	h := newHarness(t)
+
+	db, err := lnd.OpenDB(h.testdataFile("channel.db"), true)
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	chanPoint, err := lnd.ParseOutpoint(coopCloseInitiatorChannel)
+	require.NoError(t, err)
+	channel, err := db.ChannelStateDB().FetchChannel(nil, *chanPoint)
+	require.NoError(t, err)
+
+	require.NoError(t, verifyCoopCloseState(channel, -1))
+	require.NoError(t, verifyCoopCloseState(channel, 0))
+
+	channel.LocalCommitment.Htlcs = []channeldb.HTLC{{}}
+	err = verifyCoopCloseState(channel, -1)
+	require.ErrorContains(t, err, "closed cooperatively without HTLCs")
+	channel.LocalCommitment.Htlcs = nil
+
+	channel.RemoteCommitment.LocalBalance -= 1000
+	err = verifyCoopCloseState(channel, -1)
+	require.ErrorContains(t, err, "still being updated")
+	channel.RemoteCommitment.LocalBalance += 1000
+
+	err = verifyCoopCloseState(channel, 1)
+	require.ErrorContains(t, err, "refusing to propose a stale state")
+}
+
+func TestCoopCloseSignature(t *testing.T) {
+	h := newHarness(t)
+
+	chain, _ := newFundingTestChain(t, h, nil)
+
+	db, err := lnd.OpenDB(h.testdataFile("channel.db"), true)
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	chanPoint, err := lnd.ParseOutpoint(coopCloseInitiatorChannel)
+	require.NoError(t, err)
+	channel, err := db.ChannelStateDB().FetchChannel(nil, *chanPoint)
+	require.NoError(t, err)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	signer := &lnd.Signer{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+
+	localScript, err := deliveryScript(
+		testDeliveryAddr(t, 0x01), nil, "our",
+	)
+	require.NoError(t, err)
+	remoteScript, err := deliveryScript(
+		testDeliveryAddr(t, 0x02), nil, "the peer's",
+	)
+	require.NoError(t, err)
+	packet, err := coopClose(
+		chain, signer, channel, 0, localScript, remoteScript, 10,
+	)
+	require.NoError(t, err)
+
+	// The PSBT must contain a valid signature of our multisig key and the
+	// key the peer needs to sign with.
+	require.Len(t, packet.UnsignedTx.TxOut, 1)
+	pIn := packet.Inputs[0]
+	require.Len(t, pIn.PartialSigs, 1)
+	require.Len(t, pIn.Unknowns, 1)
+	require.Equal(
+		t, channel.RemoteChanCfg.MultiSigKey.PubKey.
+			SerializeCompressed(), pIn.Unknowns[0].Value,
+	)
+
+	partialSig := pIn.PartialSigs[0]
+	ourKey, err := btcec.ParsePubKey(partialSig.PubKey)
+	require.NoError(t, err)
+	require.True(t, ourKey.IsEqual(channel.LocalChanCfg.MultiSigKey.PubKey))
+
+	sigHashes := txscript.NewTxSigHashes(
+		packet.UnsignedTx, txscript.NewCannedPrevOutputFetcher(
+			pIn.WitnessUtxo.PkScript, pIn.WitnessUtxo.Value,
+		),
+	)
+	sigHash, err := txscript.CalcWitnessSigHash(
+		pIn.WitnessScript, sigHashes, txscript.SigHashAll,
+		packet.UnsignedTx, 0, pIn.WitnessUtxo.Value,
+	)
+	require.NoError(t, err)
+	sig, err := ecdsa.ParseDERSignature(
+		partialSig.Signature[:len(partialSig.Signature)-1],
+	)
+	require.NoError(t, err)
+	require.True(t, sig.Verify(sigHash, ourKey))
+}
+
+func TestDeliveryScript(t *testing.T) {
+	_ = newHarness(t)
+
+	addr := testDeliveryAddr(t, 0x01)
+	script, err := deliveryScript(addr, nil, "our")
+	require.NoError(t, err)
+	require.Len(t, script, 22)
+
+	// The upfront shutdown script is used if no address is given, and a
+	// given address must match it.
+	upfront, err := deliveryScript("", script, "our")
+	require.NoError(t, err)
+	require.Equal(t, script, upfront)
+
+	_, err = deliveryScript(testDeliveryAddr(t, 0x02), script, "our")
+	require.ErrorContains(t, err, "doesn't match the upfront shutdown")
+
+	_, err = deliveryScript("", nil, "the peer's")
+	require.ErrorContains(t, err, "the peer's delivery address is required")
+}
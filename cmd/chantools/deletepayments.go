@@ -2,14 +2,30 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/kvdb"
 	"github.com/spf13/cobra"
 )
 
+const (
+	// beforeDateFormat is the format of the --before flag if only a date
+	// is given.
+	beforeDateFormat = "2006-01-02"
+)
+
+var (
+	// paymentsRootBucket is the name of the top level bucket lnd stores
+	// all payments in, see channeldb/payments.go.
+	paymentsRootBucket = []byte("payments-root-bucket")
+)
+
 type deletePaymentsCommand struct {
 	ChannelDB  string
 	FailedOnly bool
+	Before     string
 
 	cmd *cobra.Command
 }
@@ -23,10 +39,19 @@ func newDeletePaymentsCommand() *cobra.Command {
 If only the failed payments should be deleted (and not the successful ones), the
 --failedonly flag can be specified.
 
+If the --before flag is set, only payments that were created before the given
+date are deleted. Payments that are still in flight are never deleted, the
+channel state is not touched either. The number of deleted payments and the
+number of bytes they used are reported. The file size of the channel DB only
+shrinks after running the compactdb command.
+
 CAUTION: Running this command will make it impossible to use the channel DB
 with an older version of lnd. Downgrading is not possible and you'll need to
-run lnd v0.15.1-beta or later after using this command!'`,
+run lnd v0.17.5-beta or later after using this command!'`,
 		Example: `chantools deletepayments --failedonly \
+	--channeldb ~/.lnd/data/graph/mainnet/channel.db
+
+chantools deletepayments --before 2022-01-01 \
 	--channeldb ~/.lnd/data/graph/mainnet/channel.db`,
 		RunE: cc.Execute,
 	}
@@ -38,6 +63,11 @@ run lnd v0.15.1-beta or later after using this command!'`,
 		&cc.FailedOnly, "failedonly", false, "don't delete all "+
 			"payments, only failed ones",
 	)
+	cc.cmd.Flags().StringVar(
+		&cc.Before, "before", "", "only delete payments created "+
+			"before the given date (YYYY-MM-DD, UTC) or time "+
+			"(RFC3339)",
+	)
 
 	return cc.cmd
 }
@@ -47,11 +77,141 @@ func (c *deletePaymentsCommand) Execute(_ *cobra.Command, _ []string) error {
 	if c.ChannelDB == "" {
 		return fmt.Errorf("channel DB is required")
 	}
+
+	var before time.Time
+	if c.Before != "" {
+		var err error
+		before, err = parseBefore(c.Before)
+		if err != nil {
+			return err
+		}
+	}
+
 	db, err := lnd.OpenDB(c.ChannelDB, false)
 	if err != nil {
 		return fmt.Errorf("error opening rescue DB: %w", err)
 	}
 	defer func() { _ = db.Close() }()
 
-	return db.DeletePayments(c.FailedOnly, false)
+	numDeleted, numBytes, err := deletePayments(db, c.FailedOnly, before)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Deleted %d payments using %d bytes, run compactdb to "+
+		"reclaim the space on disk", numDeleted, numBytes)
+
+	return nil
+}
+
+// parseBefore parses the value of the --before flag, which is either a date
+// or a full RFC3339 timestamp.
+func parseBefore(before string) (time.Time, error) {
+	t, err := time.Parse(beforeDateFormat, before)
+	if err == nil {
+		return t, nil
+	}
+
+	t, err = time.Parse(time.RFC3339, before)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing date %s, must "+
+			"be in the format YYYY-MM-DD or RFC3339", before)
+	}
+
+	return t, nil
+}
+
+// deletePayments deletes all completed and failed payments, or only the failed
+// ones if failedOnly is set. If before is set, only payments created before
+// that time are deleted. The number of deleted payments and the number of
+// bytes of their keys and values are returned.
+func deletePayments(db *channeldb.DB, failedOnly bool,
+	before time.Time) (int, int64, error) {
+
+	payments, err := db.FetchPayments()
+	if err != nil {
+		return 0, 0, fmt.Errorf("error fetching payments: %w", err)
+	}
+
+	var (
+		numDeleted int
+		numBytes   int64
+	)
+	for _, payment := range payments {
+		switch {
+		// We can't safely delete payments that are still in flight.
+		case payment.Status == channeldb.StatusInFlight:
+			continue
+
+		case failedOnly && payment.Status != channeldb.StatusFailed:
+			continue
+
+		case !before.IsZero() &&
+			!payment.Info.CreationTime.Before(before):
+
+			continue
+		}
+
+		hash := payment.Info.PaymentIdentifier
+		size, err := paymentSize(db, hash[:])
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if err := db.DeletePayment(hash, false); err != nil {
+			return 0, 0, fmt.Errorf("error deleting payment %v: %w",
+				hash, err)
+		}
+
+		numDeleted++
+		numBytes += size
+	}
+
+	return numDeleted, numBytes, nil
+}
+
+// paymentSize returns the number of bytes of all keys and values stored in the
+// bucket of the payment with the given hash.
+func paymentSize(db kvdb.Backend, hash []byte) (int64, error) {
+	var size int64
+	err := kvdb.View(db, func(tx kvdb.RTx) error {
+		payments := tx.ReadBucket(paymentsRootBucket)
+		if payments == nil {
+			return nil
+		}
+
+		bucket := payments.NestedReadBucket(hash)
+		if bucket == nil {
+			return nil
+		}
+
+		size = int64(len(hash)) + bucketSize(bucket)
+		return nil
+	}, func() {
+		size = 0
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error reading payment %x: %w", hash, err)
+	}
+
+	return size, nil
+}
+
+// bucketSize returns the number of bytes of all keys and values stored in the
+// given bucket and its nested buckets.
+func bucketSize(bucket kvdb.RBucket) int64 {
+	var size int64
+	_ = bucket.ForEach(func(k, v []byte) error {
+		size += int64(len(k) + len(v))
+
+		if v == nil {
+			nested := bucket.NestedReadBucket(k)
+			if nested != nil {
+				size += bucketSize(nested)
+			}
+		}
+		return nil
+	})
+
+	return size
 }
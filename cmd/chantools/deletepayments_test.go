@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lntypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeletePaymentsBefore(t *testing.T) {
+	_ = newHarness(t)
+
+	db, err := channeldb.Open(t.TempDir())
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	// Create two failed payments, one before and one after the cutoff,
+	// and one old payment that is still in flight.
+	var (
+		control = channeldb.NewPaymentControl(db)
+		cutoff  = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+	addPayment := func(id byte, creationTime time.Time,
+		fail bool) lntypes.Hash {
+
+		hash := lntypes.Hash{id}
+		err := control.InitPayment(hash, &channeldb.PaymentCreationInfo{
+			PaymentIdentifier: hash,
+			Value:             1000,
+			CreationTime:      creationTime,
+			PaymentRequest:    []byte("lnbc1"),
+		})
+		require.NoError(t, err)
+
+		if fail {
+			_, err = control.Fail(
+				hash, channeldb.FailureReasonNoRoute,
+			)
+			require.NoError(t, err)
+		}
+
+		return hash
+	}
+	addPayment(1, cutoff.Add(-time.Hour), true)
+	newPayment := addPayment(2, cutoff.Add(time.Hour), true)
+	inFlight := addPayment(3, cutoff.Add(-time.Hour), false)
+
+	numDeleted, numBytes, err := deletePayments(db, true, cutoff)
+	require.NoError(t, err)
+	require.Equal(t, 1, numDeleted)
+	require.Greater(t, numBytes, int64(0))
+
+	payments, err := db.FetchPayments()
+	require.NoError(t, err)
+	require.Len(t, payments, 2)
+	require.Equal(t, newPayment, payments[0].Info.PaymentIdentifier)
+	require.Equal(t, inFlight, payments[1].Info.PaymentIdentifier)
+
+	// Without a cutoff all payments that aren't in flight are deleted.
+	numDeleted, _, err = deletePayments(db, false, time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, 1, numDeleted)
+
+	payments, err = db.FetchPayments()
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	require.Equal(t, inFlight, payments[0].Info.PaymentIdentifier)
+}
+
+func TestParseBefore(t *testing.T) {
+	before, err := parseBefore("2022-01-01")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), before)
+
+	before, err = parseBefore("2022-01-01T12:00:00Z")
+	require.NoError(t, err)
+	require.Equal(t, 12, before.Hour())
+
+	_, err = parseBefore("01/01/2022")
+	require.ErrorContains(t, err, "error parsing date")
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"strings"
 
+	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/guggero/chantools/lnd"
@@ -18,12 +22,22 @@ Address: 			%v
 Legacy address: 		%v
 Private key (WIF): 		%s
 Extended private key (xprv):	%s
-`
+%s`
+
+const (
+	addrTypeP2PKH  = "p2pkh"
+	addrTypeP2WKH  = "p2wkh"
+	addrTypeNP2WKH = "np2wkh"
+	addrTypeP2TR   = "p2tr"
+)
 
 type deriveKeyCommand struct {
 	Path     string
+	PathFile string
+	Strict   bool
 	Neuter   bool
 	Identity bool
+	AddrType []string
 
 	rootKey *rootKey
 	cmd     *cobra.Command
@@ -39,17 +53,39 @@ derivation path from the root key and prints it to the console.`,
 		Example: `chantools derivekey --path "m/1017'/0'/5'/0/0'" \
 	--neuter
 
-chantools derivekey --identity`,
+chantools derivekey --identity
+
+chantools derivekey --pathfile paths.txt --neuter
+
+chantools derivekey --path "m/86'/0'/0'/0/0" --addrtype p2tr`,
 		RunE: cc.Execute,
 	}
 	cc.cmd.Flags().StringVar(
 		&cc.Path, "path", "", "BIP32 derivation path to derive; must "+
 			"start with \"m/\"",
 	)
+	cc.cmd.Flags().StringVar(
+		&cc.PathFile, "pathfile", "", "file containing one BIP32 "+
+			"derivation path per line to derive; empty lines and "+
+			"lines starting with # are ignored",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.Strict, "strict", false, "abort if any line of the "+
+			"--pathfile cannot be derived instead of only "+
+			"reporting it",
+	)
 	cc.cmd.Flags().BoolVar(
 		&cc.Neuter, "neuter", false, "don't output private key(s), "+
 			"only public key(s)",
 	)
+	cc.cmd.Flags().StringSliceVar(
+		&cc.AddrType, "addrtype", nil, "additional address type(s) "+
+			"to show for the derived key; can be specified "+
+			"multiple times or as a comma separated list of "+
+			addrTypeP2PKH+", "+addrTypeP2WKH+", "+addrTypeNP2WKH+
+			" (P2SH wrapped P2WKH) or "+addrTypeP2TR+" (BIP86 "+
+			"key spend only taproot)",
+	)
 	cc.cmd.Flags().BoolVar(
 		&cc.Identity, "identity", false, "derive the lnd "+
 			"identity_pubkey",
@@ -71,11 +107,58 @@ func (c *deriveKeyCommand) Execute(_ *cobra.Command, _ []string) error {
 		c.Neuter = true
 	}
 
-	return deriveKey(extendedKey, c.Path, c.Neuter)
+	if c.PathFile != "" {
+		return c.deriveFromFile(extendedKey)
+	}
+
+	return deriveKey(extendedKey, c.Path, c.Neuter, c.AddrType)
+}
+
+func (c *deriveKeyCommand) deriveFromFile(
+	extendedKey *hdkeychain.ExtendedKey) error {
+
+	content, err := readInput(c.PathFile)
+	if err != nil {
+		return fmt.Errorf("error reading path file: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNum := 0
+	numErrors := 0
+	for scanner.Scan() {
+		lineNum++
+
+		path := strings.TrimSpace(scanner.Text())
+		if path == "" || strings.HasPrefix(path, "#") {
+			continue
+		}
+
+		err := deriveKey(extendedKey, path, c.Neuter, c.AddrType)
+		if err != nil {
+			err = fmt.Errorf("error deriving path on line %d: %w",
+				lineNum, err)
+			if c.Strict {
+				return err
+			}
+
+			log.Errorf("%v", err)
+			numErrors++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading path file: %w", err)
+	}
+
+	if numErrors > 0 {
+		log.Warnf("%d line(s) of %s could not be derived", numErrors,
+			c.PathFile)
+	}
+
+	return nil
 }
 
 func deriveKey(extendedKey *hdkeychain.ExtendedKey, path string,
-	neuter bool) error {
+	neuter bool, addrTypes []string) error {
 
 	child, pubKey, wif, err := lnd.DeriveKey(extendedKey, path, chainParams)
 	if err != nil {
@@ -99,6 +182,16 @@ func deriveKey(extendedKey *hdkeychain.ExtendedKey, path string,
 		return fmt.Errorf("could not create address: %w", err)
 	}
 
+	var extraAddrs string
+	for _, addrType := range addrTypes {
+		addr, err := addressOfType(pubKey, addrType)
+		if err != nil {
+			return err
+		}
+		extraAddrs += fmt.Sprintf("Address (%s): \t\t%v\n", addrType,
+			addr)
+	}
+
 	privKey, xPriv := na, na
 	if !neuter {
 		privKey, xPriv = wif.String(), child.String()
@@ -107,7 +200,7 @@ func deriveKey(extendedKey *hdkeychain.ExtendedKey, path string,
 	result := fmt.Sprintf(
 		deriveKeyFormat, path, chainParams.Name,
 		pubKey.SerializeCompressed(), neutered, addrP2WKH, addrP2PKH,
-		privKey, xPriv,
+		privKey, xPriv, extraAddrs,
 	)
 	fmt.Println(result)
 
@@ -116,3 +209,26 @@ func deriveKey(extendedKey *hdkeychain.ExtendedKey, path string,
 
 	return nil
 }
+
+// addressOfType returns the address of the given type for the public key on
+// the currently selected network.
+func addressOfType(pubKey *btcec.PublicKey,
+	addrType string) (btcutil.Address, error) {
+
+	switch strings.ToLower(strings.TrimSpace(addrType)) {
+	case addrTypeP2PKH:
+		return lnd.P2PKHAddr(pubKey, chainParams)
+
+	case addrTypeP2WKH:
+		return lnd.P2WKHAddr(pubKey, chainParams)
+
+	case addrTypeNP2WKH:
+		return lnd.NP2WKHAddr(pubKey, chainParams)
+
+	case addrTypeP2TR:
+		return lnd.P2TRAddr(pubKey, chainParams)
+
+	default:
+		return nil, fmt.Errorf("unknown address type %s", addrType)
+	}
+}
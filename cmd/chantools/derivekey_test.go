@@ -1,8 +1,13 @@
 package main
 
 import (
+	"encoding/hex"
+	"io/ioutil"
 	"testing"
 
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/guggero/chantools/bip39"
 	"github.com/guggero/chantools/btc"
 	"github.com/guggero/chantools/lnd"
 	"github.com/stretchr/testify/require"
@@ -82,3 +87,125 @@ func TestDeriveKeySeedBip39(t *testing.T) {
 
 	h.assertLogContains(keyContentBIP39)
 }
+
+func TestDeriveKeySeedBip39InvalidChecksum(t *testing.T) {
+	h := newHarness(t)
+
+	derive := &deriveKeyCommand{
+		Path:    testPath,
+		rootKey: &rootKey{BIP39: true},
+	}
+
+	// Some wallets use their own checksum, so the seed is derived from
+	// any mnemonic that isn't read from the terminal.
+	mnemonic := "bargain uncover diesel boss local host over divide " +
+		"orient cradle good crumble"
+	t.Setenv(btc.BIP39MnemonicEnvName, mnemonic)
+	t.Setenv(btc.BIP39PassphraseEnvName, "-")
+
+	err := derive.Execute(nil, nil)
+	require.NoError(t, err)
+
+	masterKey, err := hdkeychain.NewMaster(
+		bip39.NewSeed(mnemonic, ""), chainParams,
+	)
+	require.NoError(t, err)
+	key, err := lnd.DeriveChildren(masterKey, []uint32{
+		lnd.HardenedKeyStart + 123, lnd.HardenedKeyStart + 45,
+		lnd.HardenedKeyStart + 67, 8, 9,
+	})
+	require.NoError(t, err)
+	pubKey, err := key.ECPubKey()
+	require.NoError(t, err)
+	h.assertLogContains(hex.EncodeToString(pubKey.SerializeCompressed()))
+	require.NotContains(t, h.getLog(), keyContentBIP39)
+}
+
+func TestDeriveKeyPathFile(t *testing.T) {
+	h := newHarness(t)
+
+	pathFile := h.tempFile("paths.txt")
+	err := ioutil.WriteFile(pathFile, []byte(`# Comment line
+m/123'/45'/67'/8/9
+
+not-a-path
+m/84'/1'/0'/0/0
+`), 0644)
+	require.NoError(t, err)
+
+	derive := &deriveKeyCommand{
+		PathFile: pathFile,
+		Neuter:   true,
+		rootKey:  &rootKey{RootKey: rootKeyAezeed},
+	}
+
+	err = derive.Execute(nil, nil)
+	require.NoError(t, err)
+
+	h.assertLogContains(keyContent)
+	h.assertLogContains("m/84'/1'/0'/0/0")
+	h.assertLogContains("error deriving path on line 4")
+	h.assertLogContains("1 line(s) of " + pathFile)
+
+	// In strict mode, the invalid line must abort the run.
+	h.clearLog()
+	derive.Strict = true
+	err = derive.Execute(nil, nil)
+	require.ErrorContains(t, err, "line 4")
+	require.NotContains(t, h.getLog(), "m/84'/1'/0'/0/0")
+}
+
+func TestDeriveKeyAddrTypes(t *testing.T) {
+	h := newHarness(t)
+
+	// We use the test vectors of BIP44, BIP84 and BIP86 which all use the
+	// same mainnet seed.
+	chainParams = &chaincfg.MainNetParams
+	masterKey, err := bip39.MasterKeyFromMnemonic(
+		"abandon abandon abandon abandon abandon abandon abandon "+
+			"abandon abandon abandon abandon about", "",
+		chainParams,
+	)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		path     string
+		addrType string
+		addr     string
+	}{{
+		path:     "m/44'/0'/0'/0/0",
+		addrType: addrTypeP2PKH,
+		addr:     "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA",
+	}, {
+		path:     "m/84'/0'/0'/0/0",
+		addrType: addrTypeP2WKH,
+		addr:     "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu",
+	}, {
+		path:     "m/86'/0'/0'/0/0",
+		addrType: addrTypeP2TR,
+		addr: "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwu" +
+			"dpxqkedrcr",
+	}}
+	for _, tc := range testCases {
+		derive := &deriveKeyCommand{
+			Path:     tc.path,
+			AddrType: []string{tc.addrType},
+			rootKey:  &rootKey{RootKey: masterKey.String()},
+		}
+
+		err := derive.Execute(nil, nil)
+		require.NoError(t, err)
+
+		h.assertLogContains(
+			"Address (" + tc.addrType + "): \t\t" + tc.addr,
+		)
+	}
+
+	derive := &deriveKeyCommand{
+		Path:     testCases[0].path,
+		AddrType: []string{addrTypeNP2WKH, "p2foo"},
+		rootKey:  &rootKey{RootKey: masterKey.String()},
+	}
+	err = derive.Execute(nil, nil)
+	require.ErrorContains(t, err, "unknown address type p2foo")
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
@@ -13,7 +14,9 @@ import (
 	"github.com/lightningnetwork/lnd/chainreg"
 	"github.com/lightningnetwork/lnd/channeldb"
 	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/kvdb"
 	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
 	"github.com/spf13/cobra"
 )
 
@@ -29,6 +32,8 @@ type dropChannelGraphCommand struct {
 	FixOnly         bool
 
 	SingleChannel uint64
+	ChannelPoint  string
+	PruneNodes    bool
 
 	cmd *cobra.Command
 }
@@ -41,12 +46,16 @@ func newDropChannelGraphCommand() *cobra.Command {
 		Long: `This command removes all graph data from a channel DB,
 forcing the lnd node to do a full graph sync.
 
-Or if a single channel is specified, that channel is purged from the graph
-without removing any other data.
+Or if a single channel is specified, either by its short channel ID with
+--single_channel or by its funding outpoint with --channelpoint, that channel
+edge and its policies are purged from the graph without removing any other
+data. With --prune_nodes the node announcements of the channel's two nodes are
+removed as well if no other channel in the graph references them (the own
+node is never removed).
 
 CAUTION: Running this command will make it impossible to use the channel DB
 with an older version of lnd. Downgrading is not possible and you'll need to
-run lnd v0.15.1-beta or later after using this command!'`,
+run lnd v0.17.5-beta or later after using this command!'`,
 		Example: `chantools dropchannelgraph \
 	--channeldb ~/.lnd/data/graph/mainnet/channel.db \
 	--node_identity_key 03......
@@ -54,6 +63,12 @@ run lnd v0.15.1-beta or later after using this command!'`,
 chantools dropchannelgraph \
 	--channeldb ~/.lnd/data/graph/mainnet/channel.db \
 	--single_channel 726607861215512345
+	--node_identity_key 03......
+
+chantools dropchannelgraph \
+	--channeldb ~/.lnd/data/graph/mainnet/channel.db \
+	--channelpoint bd278162......:0 \
+	--prune_nodes \
 	--node_identity_key 03......`,
 		RunE: cc.Execute,
 	}
@@ -66,6 +81,16 @@ chantools dropchannelgraph \
 			"identified by its short channel ID (CID) to remove "+
 			"from the graph",
 	)
+	cc.cmd.Flags().StringVar(
+		&cc.ChannelPoint, "channelpoint", "", "the single channel "+
+			"identified by its funding outpoint (<txid>:<index>) "+
+			"to remove from the graph",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.PruneNodes, "prune_nodes", false, "when removing a "+
+			"single channel, also remove the node announcements "+
+			"of its nodes if no other channel references them",
+	)
 	cc.cmd.Flags().StringVar(
 		&cc.NodeIdentityKey, "node_identity_key", "", "your node's "+
 			"identity public key",
@@ -103,10 +128,26 @@ func (c *dropChannelGraphCommand) Execute(_ *cobra.Command, _ []string) error {
 		return fmt.Errorf("error parsing node identity key: %w", err)
 	}
 
+	if c.SingleChannel != 0 && c.ChannelPoint != "" {
+		return fmt.Errorf("cannot specify both --single_channel and " +
+			"--channelpoint")
+	}
+	if c.ChannelPoint != "" {
+		chanPoint, err := lnd.ParseOutpoint(c.ChannelPoint)
+		if err != nil {
+			return fmt.Errorf("error parsing channel point: %w",
+				err)
+		}
+		c.SingleChannel, err = db.ChannelGraph().ChannelID(chanPoint)
+		if err != nil {
+			return fmt.Errorf("error looking up channel %v in "+
+				"graph: %w", chanPoint, err)
+		}
+	}
+
 	if c.SingleChannel != 0 {
-		log.Infof("Removing single channel %d", c.SingleChannel)
-		return db.ChannelGraph().DeleteChannelEdges(
-			true, false, c.SingleChannel,
+		return removeSingleChannel(
+			db.ChannelGraph(), c.SingleChannel, idKey, c.PruneNodes,
 		)
 	}
 
@@ -136,6 +177,73 @@ func (c *dropChannelGraphCommand) Execute(_ *cobra.Command, _ []string) error {
 	return insertOwnNodeAndChannels(idKey, db)
 }
 
+// removeSingleChannel removes the channel edge with the given short channel ID
+// and its policies from the graph. If pruneNodes is set, the node
+// announcements of both nodes of the channel are removed too if they don't
+// have any other channels.
+func removeSingleChannel(graph *channeldb.ChannelGraph, chanID uint64,
+	ownNode *btcec.PublicKey, pruneNodes bool) error {
+
+	edgeInfo, _, _, err := graph.FetchChannelEdgesByID(chanID)
+	if err != nil {
+		return fmt.Errorf("error fetching channel %d: %w", chanID, err)
+	}
+
+	log.Infof("Removing single channel %d", chanID)
+	err = graph.DeleteChannelEdges(true, false, chanID)
+	if err != nil {
+		return err
+	}
+
+	if !pruneNodes {
+		return nil
+	}
+
+	ownVertex := route.NewVertex(ownNode)
+	for _, nodePub := range []route.Vertex{
+		edgeInfo.NodeKey1Bytes, edgeInfo.NodeKey2Bytes,
+	} {
+		if nodePub == ownVertex {
+			continue
+		}
+
+		node, err := graph.FetchLightningNode(nodePub)
+		if errors.Is(err, channeldb.ErrGraphNodeNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error fetching node %x: %w",
+				nodePub[:], err)
+		}
+
+		numChannels := 0
+		countChannel := func(_ kvdb.RTx, _ *channeldb.ChannelEdgeInfo,
+			_, _ *channeldb.ChannelEdgePolicy) error {
+
+			numChannels++
+			return nil
+		}
+		err = node.ForEachChannel(nil, countChannel)
+		if err != nil {
+			return fmt.Errorf("error counting channels of node "+
+				"%x: %w", nodePub[:], err)
+		}
+		if numChannels > 0 {
+			log.Infof("Keeping node %x, it has %d other "+
+				"channel(s)", nodePub[:], numChannels)
+			continue
+		}
+
+		log.Infof("Removing node %x without any channels", nodePub[:])
+		if err := graph.DeleteLightningNode(nodePub); err != nil {
+			return fmt.Errorf("error removing node %x: %w",
+				nodePub[:], err)
+		}
+	}
+
+	return nil
+}
+
 func insertOwnNodeAndChannels(idKey *btcec.PublicKey, db *channeldb.DB) error {
 	openChannels, err := db.ChannelStateDB().FetchAllOpenChannels()
 	if err != nil {
@@ -242,7 +350,7 @@ func newChanAnnouncement(localPubKey, remotePubKey *btcec.PublicKey,
 
 	// Our channel update message flags will signal that we support the
 	// max_htlc field.
-	msgFlags := lnwire.ChanUpdateOptionMaxHtlc
+	msgFlags := lnwire.ChanUpdateRequiredMaxHtlc
 
 	// We announce the channel with the default values. Some of
 	// these values can later be changed by crafting a new ChannelUpdate.
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveSingleChannel(t *testing.T) {
+	h := newHarness(t)
+
+	db, err := channeldb.Open(
+		h.tempDir, channeldb.OptionSetUseGraphCache(false),
+	)
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	graph := db.ChannelGraph()
+
+	newKey := func() *btcec.PublicKey {
+		privKey, err := btcec.NewPrivateKey()
+		require.NoError(t, err)
+		return privKey.PubKey()
+	}
+	ownNode, nodeB, nodeC := newKey(), newKey(), newKey()
+
+	// Create the channels own node <-> B and B <-> C.
+	addChannel := func(node1, node2 *btcec.PublicKey, id uint64) {
+		edge, _, err := newChanAnnouncement(
+			node1, node2, &keychain.KeyDescriptor{
+				PubKey: newKey(),
+			}, newKey(), lnwire.NewShortChanIDFromInt(id), 1,
+			1_000_000, 100_000, wire.OutPoint{
+				Hash:  chainhash.Hash{byte(id)},
+				Index: 0,
+			},
+		)
+		require.NoError(t, err)
+		require.NoError(t, graph.AddChannelEdge(edge))
+	}
+	addChannel(ownNode, nodeB, 1)
+	addChannel(nodeB, nodeC, 2)
+
+	// Node B still has another channel, our own node is never removed.
+	err = removeSingleChannel(graph, 1, ownNode, true)
+	require.NoError(t, err)
+	h.assertLogContains("other channel(s)")
+
+	_, _, _, err = graph.FetchChannelEdgesByID(1)
+	require.ErrorIs(t, err, channeldb.ErrEdgeNotFound)
+	_, err = graph.FetchLightningNode(route.NewVertex(ownNode))
+	require.NoError(t, err)
+	_, err = graph.FetchLightningNode(route.NewVertex(nodeB))
+	require.NoError(t, err)
+
+	// Without pruning, the nodes of the last channel stay in the graph.
+	addChannel(nodeB, nodeC, 3)
+	err = removeSingleChannel(graph, 3, ownNode, false)
+	require.NoError(t, err)
+	_, err = graph.FetchLightningNode(route.NewVertex(nodeC))
+	require.NoError(t, err)
+
+	// Removing the last channel of B and C removes both nodes.
+	err = removeSingleChannel(graph, 2, ownNode, true)
+	require.NoError(t, err)
+	_, err = graph.FetchLightningNode(route.NewVertex(nodeB))
+	require.ErrorIs(t, err, channeldb.ErrGraphNodeNotFound)
+	_, err = graph.FetchLightningNode(route.NewVertex(nodeC))
+	require.ErrorIs(t, err, channeldb.ErrGraphNodeNotFound)
+}
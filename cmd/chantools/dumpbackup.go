@@ -1,7 +1,12 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/guggero/chantools/dump"
@@ -13,6 +18,9 @@ import (
 
 type dumpBackupCommand struct {
 	MultiFile string
+	JSON      bool
+	Table     bool
+	ShowKeys  bool
 
 	rootKey *rootKey
 	cmd     *cobra.Command
@@ -24,14 +32,49 @@ func newDumpBackupCommand() *cobra.Command {
 		Use:   "dumpbackup",
 		Short: "Dump the content of a channel.backup file",
 		Long: `This command dumps all information that is inside a 
-channel.backup file in a human readable format.`,
+channel.backup file in a human readable format.
+
+With the --table flag, only the static parameters of each channel (remote node,
+funding outpoint, short channel ID, capacity, channel type and the remote
+node's addresses) are printed as a table. This is useful to quickly check that
+a backup contains all the channels it should before attempting a recovery.
+With the --json flag, the same information is printed as JSON instead.
+
+The backup doesn't contain any private keys, so none of the formats print any
+secrets.
+
+For auditing or debugging a recovery, the --show-keys flag prints the public
+keys of our and the remote node's multisig, revocation, payment, delay and HTLC
+base points of each channel. Our keys are derived from the seed with the key
+locators stored in the backup. The public keys reveal which on-chain outputs
+belong to the channels, so the command asks for confirmation before printing
+them. Private keys are never printed.`,
 		Example: `chantools dumpbackup \
+	--multi_file ~/.lnd/data/chain/bitcoin/mainnet/channel.backup
+
+chantools dumpbackup --table \
+	--multi_file ~/.lnd/data/chain/bitcoin/mainnet/channel.backup
+
+chantools dumpbackup --show-keys \
 	--multi_file ~/.lnd/data/chain/bitcoin/mainnet/channel.backup`,
 		RunE: cc.Execute,
 	}
 	cc.cmd.Flags().StringVar(
-		&cc.MultiFile, "multi_file", "", "lnd channel.backup file to "+
-			"dump",
+		&cc.MultiFile, "multi_file", "", "lnd channel.backup file "+
+			"(or a directory of channel backup files) to dump",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.JSON, "json", false, "dump the static channel "+
+			"parameters as JSON",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.Table, "table", false, "dump the static channel "+
+			"parameters as a table",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.ShowKeys, "show-keys", false, "print the public keys of "+
+			"our and the remote node's channel base points, "+
+			"derived from the seed; asks for confirmation first",
 	)
 
 	cc.rootKey = newRootKey(cc.cmd, "decrypting the backup")
@@ -49,21 +92,38 @@ func (c *dumpBackupCommand) Execute(_ *cobra.Command, _ []string) error {
 	if c.MultiFile == "" {
 		return fmt.Errorf("backup file is required")
 	}
-	multiFile := chanbackup.NewMultiFile(c.MultiFile)
+	if c.JSON && c.Table {
+		return fmt.Errorf("cannot use --json and --table at the same " +
+			"time")
+	}
+	if c.ShowKeys && (c.JSON || c.Table) {
+		return fmt.Errorf("cannot use --show-keys together with " +
+			"--json or --table")
+	}
 	keyRing := &lnd.HDKeyRing{
 		ExtendedKey: extendedKey,
 		ChainParams: chainParams,
 	}
-	return dumpChannelBackup(multiFile, keyRing)
-}
+	multi, err := extractMulti(c.MultiFile, keyRing)
+	if err != nil {
+		return err
+	}
 
-func dumpChannelBackup(multiFile *chanbackup.MultiFile,
-	ring keychain.KeyRing) error {
+	switch {
+	case c.JSON:
+		return printJSONDump(dump.BackupJSONDump(multi))
 
-	multi, err := multiFile.ExtractMulti(ring)
-	if err != nil {
-		return fmt.Errorf("could not extract multi file: %w", err)
+	case c.Table:
+		return dumpChannelBackupTable(multi)
+
+	case c.ShowKeys:
+		return dumpChannelBackupKeys(multi, keyRing)
 	}
+
+	return dumpChannelBackup(multi)
+}
+
+func dumpChannelBackup(multi *chanbackup.Multi) error {
 	content := dump.BackupMulti{
 		Version:       multi.Version,
 		StaticBackups: dump.BackupDump(multi, chainParams),
@@ -75,3 +135,133 @@ func dumpChannelBackup(multiFile *chanbackup.MultiFile,
 
 	return nil
 }
+
+// dumpChannelBackupTable prints the static parameters of all channels in the
+// given multi backup as a table.
+func dumpChannelBackupTable(multi *chanbackup.Multi) error {
+	var buf bytes.Buffer
+	writer := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "REMOTE NODE\tFUNDING OUTPOINT\t"+
+		"SHORT CHAN ID\tCAPACITY\tTYPE\tINITIATOR\tADDRESSES")
+	for _, single := range dump.BackupJSONDump(multi) {
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%s\t%v\t%s\n",
+			single.RemotePubkey, single.FundingOutpoint,
+			single.ShortChannelID, single.Capacity, single.ChanType,
+			single.IsInitiator, strings.Join(single.Addresses, ","))
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Print(buf.String())
+	fmt.Printf("\nBackup version %d contains %d channel(s)\n",
+		multi.Version, len(multi.StaticBackups))
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(buf.String())
+
+	return nil
+}
+
+// backupKey is a single base point of a channel in a backup.
+type backupKey struct {
+	name   string
+	local  keychain.KeyDescriptor
+	remote keychain.KeyDescriptor
+}
+
+// dumpChannelBackupKeys prints the public keys of our and the remote node's
+// base points of all channels in the given multi backup after the user
+// confirmed it. Our keys are derived from the key locators stored in the
+// backup.
+func dumpChannelBackupKeys(multi *chanbackup.Multi,
+	keyRing *lnd.HDKeyRing) error {
+
+	fmt.Printf("\n!!! WARNING !!! This prints the public keys of all " +
+		"channels in the backup. They don't allow anyone to spend " +
+		"your funds, but they identify the on-chain outputs of your " +
+		"channels and therefore your funds. Only share them with " +
+		"people you trust to help with your recovery.\n\n")
+	fmt.Printf("Press <enter> to continue and print the keys or " +
+		"<ctrl+c> to abort: ")
+	_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+
+	var buf bytes.Buffer
+	for _, single := range multi.StaticBackups {
+		local, remote := single.LocalChanCfg, single.RemoteChanCfg
+		keys := []*backupKey{{
+			name:   "multisig",
+			local:  local.MultiSigKey,
+			remote: remote.MultiSigKey,
+		}, {
+			name:   "revocation base",
+			local:  local.RevocationBasePoint,
+			remote: remote.RevocationBasePoint,
+		}, {
+			name:   "payment base",
+			local:  local.PaymentBasePoint,
+			remote: remote.PaymentBasePoint,
+		}, {
+			name:   "delay base",
+			local:  local.DelayBasePoint,
+			remote: remote.DelayBasePoint,
+		}, {
+			name:   "htlc base",
+			local:  local.HtlcBasePoint,
+			remote: remote.HtlcBasePoint,
+		}}
+
+		_, _ = fmt.Fprintf(&buf, "Channel %v with remote node %x:\n",
+			single.FundingOutpoint,
+			single.RemoteNodePub.SerializeCompressed())
+
+		writer := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(writer, "  KEY\tKEY LOCATOR\tLOCAL PUBKEY"+
+			"\tREMOTE PUBKEY")
+		for _, key := range keys {
+			localKey, err := deriveBackupKey(keyRing, key.local)
+			if err != nil {
+				return fmt.Errorf("error deriving %s key of "+
+					"channel %v: %w", key.name,
+					single.FundingOutpoint, err)
+			}
+
+			_, _ = fmt.Fprintf(writer, "  %s\t%d/%d\t%s\t%s\n",
+				key.name, key.local.Family, key.local.Index,
+				localKey,
+				dump.PubKeyToString(key.remote.PubKey))
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(&buf)
+	}
+
+	fmt.Print(buf.String())
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(buf.String())
+
+	return nil
+}
+
+// deriveBackupKey derives the public key of the given key descriptor of our
+// node. If the backup contains the public key as well, it must match the
+// derived one.
+func deriveBackupKey(keyRing *lnd.HDKeyRing,
+	keyDesc keychain.KeyDescriptor) (string, error) {
+
+	derived, err := keyRing.DeriveKey(keyDesc.KeyLocator)
+	if err != nil {
+		return "", err
+	}
+
+	if keyDesc.PubKey != nil && !keyDesc.PubKey.IsEqual(derived.PubKey) {
+		return "", fmt.Errorf("key %x in the backup doesn't match "+
+			"the key %x derived from the seed, wrong seed?",
+			keyDesc.PubKey.SerializeCompressed(),
+			derived.PubKey.SerializeCompressed())
+	}
+
+	return dump.PubKeyToString(derived.PubKey), nil
+}
@@ -1,4 +1,126 @@
 package main
 
-// This file is empty for now, the dumpbackup command is covered by the test in
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/dump"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/stretchr/testify/require"
+)
+
+// The plain dump of the dumpbackup command is covered by the test in
 // chanbackup_test.go.
+
+func TestDumpBackupTableAndJSON(t *testing.T) {
+	h := newHarness(t)
+
+	makeBackup := &chanBackupCommand{
+		ChannelDB: h.testdataFile("channel.db"),
+		MultiFile: h.tempFile("extracted.backup"),
+		rootKey:   &rootKey{RootKey: rootKeyAezeed},
+	}
+	require.NoError(t, makeBackup.Execute(nil, nil))
+
+	dumpBackup := &dumpBackupCommand{
+		MultiFile: makeBackup.MultiFile,
+		Table:     true,
+		rootKey:   &rootKey{RootKey: rootKeyAezeed},
+	}
+	h.clearLog()
+	require.NoError(t, dumpBackup.Execute(nil, nil))
+	h.assertLogContains("REMOTE NODE")
+	h.assertLogContains("10279f62619634058b6133cb7ac6c1693a8e6df7caa91c6" +
+		"263ca3d0bf704ad4d:0")
+
+	dumpBackup.Table = false
+	dumpBackup.JSON = true
+	h.clearLog()
+	require.NoError(t, dumpBackup.Execute(nil, nil))
+
+	// The JSON is the last thing that is logged.
+	logContent := h.getLog()
+	jsonContent := logContent[strings.Index(logContent, "[\n"):]
+	var singles []dump.BackupSingleJSON
+	require.NoError(t, json.Unmarshal([]byte(jsonContent), &singles))
+	require.NotEmpty(t, singles)
+	for _, single := range singles {
+		require.NotEmpty(t, single.FundingOutpoint)
+		require.NotEmpty(t, single.RemotePubkey)
+		require.NotEmpty(t, single.ChanType)
+		require.NotZero(t, single.Capacity)
+	}
+
+	dumpBackup.Table = true
+	err := dumpBackup.Execute(nil, nil)
+	require.ErrorContains(t, err, "cannot use --json and --table")
+}
+
+func TestDumpBackupShowKeys(t *testing.T) {
+	h := newHarness(t)
+
+	makeBackup := &chanBackupCommand{
+		ChannelDB: h.testdataFile("channel.db"),
+		MultiFile: h.tempFile("extracted.backup"),
+		rootKey:   &rootKey{RootKey: rootKeyAezeed},
+	}
+	require.NoError(t, makeBackup.Execute(nil, nil))
+
+	dumpBackup := &dumpBackupCommand{
+		MultiFile: makeBackup.MultiFile,
+		ShowKeys:  true,
+		rootKey:   &rootKey{RootKey: rootKeyAezeed},
+	}
+	h.clearLog()
+	require.NoError(t, dumpBackup.Execute(nil, nil))
+	h.assertLogContains("Channel 10279f62619634058b6133cb7ac6c1693a8e6df" +
+		"7caa91c6263ca3d0bf704ad4d:0 with remote node")
+	h.assertLogContains("revocation base")
+
+	// Only public keys are printed, never the extended private key.
+	require.NotContains(t, h.getLog(), "tprv")
+
+	dumpBackup.Table = true
+	err := dumpBackup.Execute(nil, nil)
+	require.ErrorContains(t, err, "cannot use --show-keys together")
+}
+
+func TestDeriveBackupKey(t *testing.T) {
+	_ = newHarness(t)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	keyRing := &lnd.HDKeyRing{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+
+	keyLoc := keychain.KeyLocator{
+		Family: keychain.KeyFamilyMultiSig,
+		Index:  1,
+	}
+	derived, err := keyRing.DeriveKey(keyLoc)
+	require.NoError(t, err)
+
+	// Without a public key in the backup, the derived key is returned.
+	pubKey, err := deriveBackupKey(keyRing, keychain.KeyDescriptor{
+		KeyLocator: keyLoc,
+	})
+	require.NoError(t, err)
+	require.Equal(t, dump.PubKeyToString(derived.PubKey), pubKey)
+
+	// A public key in the backup must match the derived key.
+	other, err := keyRing.DeriveKey(keychain.KeyLocator{
+		Family: keychain.KeyFamilyMultiSig,
+		Index:  2,
+	})
+	require.NoError(t, err)
+	_, err = deriveBackupKey(keyRing, keychain.KeyDescriptor{
+		KeyLocator: keyLoc,
+		PubKey:     other.PubKey,
+	})
+	require.ErrorContains(t, err, "wrong seed?")
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/davecgh/go-spew/spew"
@@ -15,6 +16,7 @@ type dumpChannelsCommand struct {
 	Closed       bool
 	Pending      bool
 	WaitingClose bool
+	JSON         bool
 
 	cmd *cobra.Command
 }
@@ -26,9 +28,20 @@ func newDumpChannelsCommand() *cobra.Command {
 		Short: "Dump all channel information from an lnd channel " +
 			"database",
 		Long: `This command dumps all open and pending channels from the
-given lnd channel.db gile in a human readable format.`,
+given lnd channel.db gile in a human readable format.
+
+With the --json flag, a compact JSON array is printed instead that contains the
+funding outpoint, short channel ID, capacity, balances, commitment heights and
+revocation state of each channel.
+
+The channel.db is opened read-only and is never modified. lnd holds an exclusive
+lock on the database while it is running, so lnd must be shut down first,
+otherwise the command fails after a timeout.`,
 		Example: `chantools dumpchannels \
-	--channeldb ~/.lnd/data/graph/mainnet/channel.db`,
+	--channeldb ~/.lnd/data/graph/mainnet/channel.db
+
+chantools dumpchannels --json \
+	--channeldb ~/.lnd/data/graph/mainnet/channel.db > channels.json`,
 		RunE: cc.Execute,
 	}
 	cc.cmd.Flags().StringVar(
@@ -47,6 +60,10 @@ given lnd channel.db gile in a human readable format.`,
 		&cc.WaitingClose, "waiting_close", false, "dump waiting close "+
 			"channels instead of open",
 	)
+	cc.cmd.Flags().BoolVar(
+		&cc.JSON, "json", false, "dump the channels as compact JSON "+
+			"instead of the human readable format",
+	)
 
 	return cc.cmd
 }
@@ -70,24 +87,30 @@ func (c *dumpChannelsCommand) Execute(_ *cobra.Command, _ []string) error {
 	}
 
 	if c.Closed {
-		return dumpClosedChannelInfo(db.ChannelStateDB())
+		return dumpClosedChannelInfo(db.ChannelStateDB(), c.JSON)
 	}
 	if c.Pending {
-		return dumpPendingChannelInfo(db.ChannelStateDB())
+		return dumpPendingChannelInfo(db.ChannelStateDB(), c.JSON)
 	}
 	if c.WaitingClose {
-		return dumpWaitingCloseChannelInfo(db.ChannelStateDB())
+		return dumpWaitingCloseChannelInfo(db.ChannelStateDB(), c.JSON)
 	}
 
-	return dumpOpenChannelInfo(db.ChannelStateDB())
+	return dumpOpenChannelInfo(db.ChannelStateDB(), c.JSON)
 }
 
-func dumpOpenChannelInfo(chanDb *channeldb.ChannelStateDB) error {
+func dumpOpenChannelInfo(chanDb *channeldb.ChannelStateDB,
+	asJSON bool) error {
+
 	channels, err := chanDb.FetchAllChannels()
 	if err != nil {
 		return err
 	}
 
+	if asJSON {
+		return dumpOpenChannelJSON(channels)
+	}
+
 	dumpChannels, err := dump.OpenChannelDump(channels, chainParams)
 	if err != nil {
 		return fmt.Errorf("error converting to dump format: %w", err)
@@ -101,12 +124,18 @@ func dumpOpenChannelInfo(chanDb *channeldb.ChannelStateDB) error {
 	return nil
 }
 
-func dumpClosedChannelInfo(chanDb *channeldb.ChannelStateDB) error {
+func dumpClosedChannelInfo(chanDb *channeldb.ChannelStateDB,
+	asJSON bool) error {
+
 	channels, err := chanDb.FetchClosedChannels(false)
 	if err != nil {
 		return err
 	}
 
+	if asJSON {
+		return printJSONDump(dump.ClosedChannelJSONDump(channels))
+	}
+
 	dumpChannels, err := dump.ClosedChannelDump(channels, chainParams)
 	if err != nil {
 		return fmt.Errorf("error converting to dump format: %w", err)
@@ -120,12 +149,18 @@ func dumpClosedChannelInfo(chanDb *channeldb.ChannelStateDB) error {
 	return nil
 }
 
-func dumpPendingChannelInfo(chanDb *channeldb.ChannelStateDB) error {
+func dumpPendingChannelInfo(chanDb *channeldb.ChannelStateDB,
+	asJSON bool) error {
+
 	channels, err := chanDb.FetchPendingChannels()
 	if err != nil {
 		return err
 	}
 
+	if asJSON {
+		return dumpOpenChannelJSON(channels)
+	}
+
 	dumpChannels, err := dump.OpenChannelDump(channels, chainParams)
 	if err != nil {
 		return fmt.Errorf("error converting to dump format: %w", err)
@@ -139,12 +174,18 @@ func dumpPendingChannelInfo(chanDb *channeldb.ChannelStateDB) error {
 	return nil
 }
 
-func dumpWaitingCloseChannelInfo(chanDb *channeldb.ChannelStateDB) error {
+func dumpWaitingCloseChannelInfo(chanDb *channeldb.ChannelStateDB,
+	asJSON bool) error {
+
 	channels, err := chanDb.FetchWaitingCloseChannels()
 	if err != nil {
 		return err
 	}
 
+	if asJSON {
+		return dumpOpenChannelJSON(channels)
+	}
+
 	dumpChannels, err := dump.OpenChannelDump(channels, chainParams)
 	if err != nil {
 		return fmt.Errorf("error converting to dump format: %w", err)
@@ -157,3 +198,26 @@ func dumpWaitingCloseChannelInfo(chanDb *channeldb.ChannelStateDB) error {
 
 	return nil
 }
+
+func dumpOpenChannelJSON(channels []*channeldb.OpenChannel) error {
+	dumpChannels, err := dump.OpenChannelJSONDump(channels)
+	if err != nil {
+		return fmt.Errorf("error converting to dump format: %w", err)
+	}
+
+	return printJSONDump(dumpChannels)
+}
+
+func printJSONDump(dumpChannels interface{}) error {
+	dumpBytes, err := json.MarshalIndent(dumpChannels, "", " ")
+	if err != nil {
+		return fmt.Errorf("error encoding JSON: %w", err)
+	}
+
+	fmt.Println(string(dumpBytes))
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(string(dumpBytes))
+
+	return nil
+}
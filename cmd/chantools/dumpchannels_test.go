@@ -1,4 +1,45 @@
 package main
 
-// This file is empty for now, the dumpchannels command is covered by the test
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/guggero/chantools/dump"
+	"github.com/stretchr/testify/require"
+)
+
+// The human readable dump of the dumpchannels command is covered by the test
 // in compactdb_test.go.
+
+func TestDumpChannelsJSON(t *testing.T) {
+	h := newHarness(t)
+
+	dumpCmd := &dumpChannelsCommand{
+		ChannelDB: h.testdataFile("channel.db"),
+		JSON:      true,
+	}
+	err := dumpCmd.Execute(nil, nil)
+	require.NoError(t, err)
+
+	// The JSON is logged after the log line prefix.
+	logged := h.getLog()
+	logged = logged[strings.Index(logged, "CHAN: ")+len("CHAN: "):]
+
+	var channels []dump.OpenChannelJSON
+	err = json.Unmarshal([]byte(logged), &channels)
+	require.NoError(t, err)
+	require.Len(t, channels, 4)
+
+	channel := channels[0]
+	require.Equal(
+		t, "10279f62619634058b6133cb7ac6c1693a8e6df7caa91c6263ca3d0bf7"+
+			"04ad4d:0", channel.FundingOutpoint,
+	)
+	require.Equal(t, "125:2:0", channel.ShortChannelID)
+	require.EqualValues(t, 16_000_000, channel.Capacity)
+	require.Equal(
+		t, "02de22394a9afded22d95b0ed0fd83d01805e2a248b7eb7da50cc1c33d"+
+			"ac3b4725", channel.PerCommitPoint,
+	)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/lnd"
+	"github.com/spf13/cobra"
+)
+
+type exportKeysCommand struct {
+	RecoveryWindow   uint32
+	GapLimit         uint32
+	GapLimitInternal uint32
+	MaxIndex         uint32
+	APIURL           string
+	Resume           string
+
+	rootKey *rootKey
+	cmd     *cobra.Command
+}
+
+func newExportKeysCommand() *cobra.Command {
+	cc := &exportKeysCommand{}
+	cc.cmd = &cobra.Command{
+		Use: "exportkeys",
+		Short: "Export the private keys of all addresses with funds " +
+			"that are found by scanning the wallet",
+		Long: `This command scans the same addresses as the
+sweepremoteclosed command and exports the private key (in the WIF format of the
+selected network) and the derivation path of every address that has unspent
+outputs, so the funds can be swept with any other wallet. The external and
+internal (change) branches of the lnd wallet (BIP84, m/84'/coin_type'/0') are
+scanned as well, until --gap-limit and --gap-limit-internal consecutive
+addresses without funds were found.
+
+The P2WKH keys are prefixed with p2wpkh: so they can be imported into Electrum
+directly. Outputs of anchor and simple taproot channels are locked in a P2WSH
+script or tapscript leaf with a CSV delay of one block that most wallets can't
+spend, even with the private key. Those keys are exported as well but should be
+swept with the sweepremoteclosed command instead.
+
+Anyone who has access to the exported keys can steal the funds! Use the global
+--output-file flag to write the keys to a file instead of the terminal, so they
+don't end up in the scroll back buffer or a log of the terminal session.`,
+		Example: `chantools exportkeys \
+	--recoverywindow 300 \
+	--output-file keys.txt`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().Uint32Var(
+		&cc.RecoveryWindow, "recoverywindow",
+		sweepRemoteClosedDefaultRecoveryWindow, "number of keys to "+
+			"scan per derivation path",
+	)
+	addGapLimitFlags(cc.cmd, &cc.GapLimit, &cc.MaxIndex)
+	addGapLimitInternalFlag(cc.cmd, &cc.GapLimitInternal)
+	cc.cmd.Flags().StringVar(
+		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
+			"be esplora compatible)",
+	)
+	addResumeFlag(cc.cmd, &cc.Resume)
+
+	cc.rootKey = newRootKey(cc.cmd, "deriving the keys")
+
+	return cc.cmd
+}
+
+func (c *exportKeysCommand) Execute(_ *cobra.Command, _ []string) error {
+	extendedKey, err := c.rootKey.read()
+	if err != nil {
+		return fmt.Errorf("error reading root key: %w", err)
+	}
+
+	// Set default values.
+	if c.RecoveryWindow == 0 {
+		c.RecoveryWindow = sweepRemoteClosedDefaultRecoveryWindow
+	}
+
+	targets, err := scanRemoteClosed(
+		extendedKey, c.APIURL, c.RecoveryWindow, c.GapLimit,
+		c.MaxIndex, c.Resume,
+	)
+	if err != nil {
+		return err
+	}
+
+	api, err := newChainBackend(c.APIURL)
+	if err != nil {
+		return err
+	}
+	utxos, err := listWalletUtxos(
+		extendedKey, api, c.GapLimit, c.GapLimitInternal, c.MaxIndex,
+	)
+	if err != nil {
+		return fmt.Errorf("error listing wallet UTXOs: %w", err)
+	}
+
+	keys, numAddrs, err := exportTargetKeys(extendedKey, targets, utxos)
+	if err != nil {
+		return err
+	}
+	if numAddrs == 0 {
+		return fmt.Errorf("no addresses with funds found")
+	}
+
+	fmt.Printf("\n!!! WARNING !!! Found %d address(es) with funds. This "+
+		"exports their private keys, anyone who has access to them "+
+		"can steal your funds. Never share them with anyone!\n\n",
+		numAddrs)
+	fmt.Printf("Press <enter> to continue and export the keys or " +
+		"<ctrl+c> to abort: ")
+	_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+
+	if OutputFile != "" {
+		fileName, err := writeOutputFile([]byte(keys))
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Wrote private keys of %d address(es) to %s",
+			numAddrs, fileName)
+		return nil
+	}
+
+	fmt.Println(keys)
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(keys)
+
+	return nil
+}
+
+// exportTargetKeys returns the private keys of all targets and the addresses
+// of the wallet UTXOs, each one preceded by a comment with the address,
+// derivation path and balance. The number of exported keys is returned as well.
+func exportTargetKeys(extendedKey *hdkeychain.ExtendedKey,
+	targets []*targetAddr, utxos []*walletUtxo) (string, int, error) {
+
+	var result strings.Builder
+	_, _ = fmt.Fprintf(
+		&result, "# Private keys of all addresses with funds, "+
+			"exported by chantools on %s\n", time.Now().UTC(),
+	)
+	for _, target := range targets {
+		_, _, wif, err := lnd.DeriveKey(
+			extendedKey, target.path, chainParams,
+		)
+		if err != nil {
+			return "", 0, fmt.Errorf("could not derive key %s: "+
+				"%w", target.path, err)
+		}
+
+		balance := uint64(0)
+		for _, vout := range target.vouts {
+			balance += vout.Value
+		}
+		_, _ = fmt.Fprintf(
+			&result, "# addr=%s path=%s balance=%d outputs=%d\n",
+			target.addr.EncodeAddress(), target.path, balance,
+			len(target.vouts),
+		)
+
+		// A simple taproot channel output is a tapscript leaf, the key
+		// alone doesn't tell a wallet how to spend it either.
+		if target.scriptTree != nil {
+			_, _ = fmt.Fprintf(
+				&result, "# P2TR tapscript %x, can't be spent "+
+					"with the key alone, use "+
+					"sweepremoteclosed\n%s\n",
+				target.script, wif.String(),
+			)
+			continue
+		}
+
+		// An anchor channel output is a P2WSH script, the key alone
+		// doesn't tell a wallet how to spend it.
+		if len(target.script) > 0 {
+			_, _ = fmt.Fprintf(
+				&result, "# P2WSH script %x, can't be spent "+
+					"with the key alone, use "+
+					"sweepremoteclosed\n%s\n",
+				target.script, wif.String(),
+			)
+			continue
+		}
+
+		_, _ = fmt.Fprintf(&result, "p2wpkh:%s\n", wif.String())
+	}
+
+	// A wallet address can have more than one UTXO, but its key only needs
+	// to be exported once.
+	var (
+		paths    []string
+		balances = make(map[string]uint64)
+		outputs  = make(map[string]int)
+		addrs    = make(map[string]string)
+	)
+	for _, utxo := range utxos {
+		if _, ok := balances[utxo.path]; !ok {
+			paths = append(paths, utxo.path)
+		}
+		balances[utxo.path] += uint64(utxo.value)
+		outputs[utxo.path]++
+		addrs[utxo.path] = utxo.addr.EncodeAddress()
+	}
+	for _, path := range paths {
+		_, _, wif, err := lnd.DeriveKey(extendedKey, path, chainParams)
+		if err != nil {
+			return "", 0, fmt.Errorf("could not derive key %s: "+
+				"%w", path, err)
+		}
+
+		_, _ = fmt.Fprintf(
+			&result, "# addr=%s path=%s balance=%d outputs=%d\n"+
+				"p2wpkh:%s\n", addrs[path], path,
+			balances[path], outputs[path], wif.String(),
+		)
+	}
+
+	return result.String(), len(targets) + len(paths), nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportKeys(t *testing.T) {
+	h := newHarness(t)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+
+	// There are funds on the P2WKH address of the payment base key with
+	// index 2 and on the anchor address of the one with index 4.
+	const (
+		p2wkhPath  = "m/1017'/1'/3'/0/2"
+		anchorPath = "m/1017'/1'/3'/0/4"
+	)
+	_, p2wkhPubKey, p2wkhWIF, err := lnd.DeriveKey(
+		extendedKey, p2wkhPath, chainParams,
+	)
+	require.NoError(t, err)
+	p2wkhAddr, err := lnd.P2WKHAddr(p2wkhPubKey, chainParams)
+	require.NoError(t, err)
+	_, anchorPubKey, anchorWIF, err := lnd.DeriveKey(
+		extendedKey, anchorPath, chainParams,
+	)
+	require.NoError(t, err)
+	anchorAddr, _, err := lnd.P2AnchorStaticRemote(
+		anchorPubKey, chainParams,
+	)
+	require.NoError(t, err)
+
+	// And there are funds on the change address with index 3 of the lnd
+	// wallet.
+	const walletPath = "m/84'/1'/0'/1/3"
+	_, walletPubKey, walletWIF, err := lnd.DeriveKey(
+		extendedKey, walletPath, chainParams,
+	)
+	require.NoError(t, err)
+	walletAddr, err := lnd.P2WKHAddr(walletPubKey, chainParams)
+	require.NoError(t, err)
+
+	chain := newFakeChainBackend()
+	funds := []struct {
+		addr  string
+		value uint64
+	}{
+		{p2wkhAddr.EncodeAddress(), 50_000},
+		{anchorAddr.EncodeAddress(), 30_000},
+		{walletAddr.EncodeAddress(), 20_000},
+	}
+	for idx, fund := range funds {
+		chain.addAPITx(&btc.TX{
+			TXID: strings.Repeat(fmt.Sprintf("%02x", idx+1), 32),
+			Vout: []*btc.Vout{{
+				ScriptPubkeyAddr: fund.addr,
+				Value:            fund.value,
+			}},
+			Status: &btc.Status{Confirmed: true},
+		})
+	}
+
+	export := &exportKeysCommand{
+		RecoveryWindow:   10,
+		GapLimit:         defaultGapLimit,
+		GapLimitInternal: defaultGapLimitInternal,
+		APIURL:           chain.serve(t),
+		rootKey:          &rootKey{RootKey: rootKeyAezeed},
+	}
+	require.NoError(t, export.Execute(nil, nil))
+	h.assertLogContains("# addr=" + p2wkhAddr.EncodeAddress() + " path=" +
+		p2wkhPath + " balance=50000 outputs=1\np2wpkh:" +
+		p2wkhWIF.String() + "\n")
+	h.assertLogContains("# addr=" + anchorAddr.EncodeAddress() + " path=" +
+		anchorPath + " balance=30000 outputs=1\n")
+	h.assertLogContains("use sweepremoteclosed\n" + anchorWIF.String())
+	h.assertLogContains("# addr=" + walletAddr.EncodeAddress() + " path=" +
+		walletPath + " balance=20000 outputs=1\np2wpkh:" +
+		walletWIF.String() + "\n")
+
+	// With --output-file the keys are only written to the file.
+	h.clearLog()
+	OutputFile = h.tempFile("keys.txt")
+	defer func() {
+		OutputFile = ""
+	}()
+	require.NoError(t, export.Execute(nil, nil))
+	require.NotContains(t, h.getLog(), p2wkhWIF.String())
+	h.assertLogContains("Wrote private keys of 3 address(es) to")
+
+	content, err := ioutil.ReadFile(OutputFile)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "p2wpkh:"+p2wkhWIF.String())
+	require.Contains(t, string(content), anchorWIF.String())
+
+	// Without any funds there is nothing to export.
+	export.APIURL = newFakeChainBackend().serve(t)
+	err = export.Execute(nil, nil)
+	require.ErrorContains(t, err, "no addresses with funds found")
+}
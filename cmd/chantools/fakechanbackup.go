@@ -2,8 +2,10 @@ package main
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"strconv"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/gogo/protobuf/jsonpb"
 	"github.com/guggero/chantools/lnd"
@@ -31,6 +34,7 @@ type fakeChanBackupCommand struct {
 	Capacity     uint64
 
 	FromChannelGraph string
+	FromCSV          string
 
 	MultiFile string
 
@@ -56,16 +60,29 @@ faked/skeleton channel.backup file that at least lets us talk to the other node
 and ask them to do their part. Then we can later brute-force the private key for
 the transaction output of our part of the funds (see rescueclosed command).
 
-There are two versions of this command: The first one is to create a fake
-backup for a single channel where all flags (except --from_channel_graph) need
-to be set. This is the easiest to use since it only relies on data that is
-publicly available (for example on 1ml.com) but involves more manual work.
+There are three versions of this command: The first one is to create a fake
+backup for a single channel where all flags (except --from_channel_graph and
+--from_csv) need to be set. This is the easiest to use since it only relies on
+data that is publicly available (for example on 1ml.com) but involves more
+manual work.
 The second version of the command only takes the --from_channel_graph and
 --multi_file flags and tries to assemble all channels found in the public
 network graph (must be provided in the JSON format that the 
 'lncli describegraph' command returns) into a fake backup file. This is the
 most convenient way to use this command but requires one to have a fully synced
 lnd node.
+The third version of the command only takes the --from_csv and --multi_file
+flags and creates a fake backup file for all channels listed in the CSV file.
+Each line describes one channel with the following columns:
+node_pubkey,funding_txid,funding_vout,capacity,channel_address_type,
+short_channel_id[,node_addr]
+The channel_address_type must be one of legacy, static_remote_key, anchors or
+anchors_zero_fee_htlc. The short_channel_id is expected in the format
+<blockheight>x<transactionindex>x<outputindex>. The last column is optional and
+contains the remote node's address in the format host:port. A header line
+starting with node_pubkey and lines starting with # are ignored. All lines are
+validated before the backup is created, any invalid lines are reported with
+their line number.
 
 Any fake channel backup _needs_ to be used with the custom fork of lnd
 specifically built for this purpose: https://github.com/guggero/lnd/releases
@@ -81,6 +98,9 @@ for the rescueclosed command.
 	--multi_file fake.backup
 
 chantools fakechanbackup --from_channel_graph lncli_describegraph.json \
+	--multi_file fake.backup
+
+chantools fakechanbackup --from_csv channels.csv \
 	--multi_file fake.backup`,
 		RunE: cc.Execute,
 	}
@@ -108,6 +128,12 @@ chantools fakechanbackup --from_channel_graph lncli_describegraph.json \
 			"LN channel graph in the JSON format that the "+
 			"'lncli describegraph' returns",
 	)
+	cc.cmd.Flags().StringVar(
+		&cc.FromCSV, "from_csv", "", "a CSV file with one channel "+
+			"per line in the format node_pubkey,funding_txid,"+
+			"funding_vout,capacity,channel_address_type,"+
+			"short_channel_id[,node_addr]",
+	)
 	multiFileName := fmt.Sprintf("results/fake-%s.backup",
 		time.Now().Format("2006-01-02-15-04-05"))
 	cc.cmd.Flags().StringVar(
@@ -147,6 +173,25 @@ func (c *fakeChanBackupCommand) Execute(_ *cobra.Command, _ []string) error {
 		return backupFromGraph(graph, keyRing, multiFile)
 	}
 
+	if c.FromCSV != "" {
+		csvBytes, err := ioutil.ReadFile(c.FromCSV)
+		if err != nil {
+			return fmt.Errorf("error reading CSV file %s: %w",
+				c.FromCSV, err)
+		}
+
+		singles, err := singlesFromCSV(csvBytes)
+		if err != nil {
+			return fmt.Errorf("error parsing CSV file %s: %w",
+				c.FromCSV, err)
+		}
+
+		log.Infof("Creating fake backup with %d channel(s)",
+			len(singles))
+
+		return writeBackups(singles, keyRing, multiFile)
+	}
+
 	// Parse channel point of channel to fake.
 	chanOp, err := lnd.ParseOutpoint(c.ChannelPoint)
 	if err != nil {
@@ -167,55 +212,19 @@ func (c *fakeChanBackupCommand) Execute(_ *cobra.Command, _ []string) error {
 	if err != nil {
 		return fmt.Errorf("could not parse pubkey: %w", err)
 	}
-	host, portStr, err := net.SplitHostPort(splitNodeInfo[1])
+	addr, err := parseAddr(splitNodeInfo[1])
 	if err != nil {
-		return fmt.Errorf("could not split host and port: %w", err)
-	}
-
-	var addr net.Addr
-	if tor.IsOnionHost(host) {
-		port, err := strconv.Atoi(portStr)
-		if err != nil {
-			return fmt.Errorf("could not parse port: %w", err)
-		}
-		addr = &tor.OnionAddr{
-			OnionService: host,
-			Port:         port,
-		}
-	} else {
-		addr, err = net.ResolveTCPAddr("tcp", splitNodeInfo[1])
-		if err != nil {
-			return fmt.Errorf("could not parse addr: %w", err)
-		}
+		return err
 	}
 
 	// Parse the short channel ID.
-	splitChanID := strings.Split(c.ShortChanID, "x")
-	if len(splitChanID) != 3 {
-		return fmt.Errorf("--short_channel_id expected in format: " +
-			"<blockheight>x<transactionindex>x<outputindex>",
-		)
-	}
-	blockHeight, err := strconv.ParseInt(splitChanID[0], 10, 32)
-	if err != nil {
-		return fmt.Errorf("could not parse block height: %w", err)
-	}
-	txIndex, err := strconv.ParseInt(splitChanID[1], 10, 32)
-	if err != nil {
-		return fmt.Errorf("could not parse transaction index: %w", err)
-	}
-	chanOutputIdx, err := strconv.ParseInt(splitChanID[2], 10, 32)
+	shortChanID, err := parseShortChanID(c.ShortChanID)
 	if err != nil {
-		return fmt.Errorf("could not parse output index: %w", err)
-	}
-	shortChanID := lnwire.ShortChannelID{
-		BlockHeight: uint32(blockHeight),
-		TxIndex:     uint32(txIndex),
-		TxPosition:  uint16(chanOutputIdx),
+		return err
 	}
 
 	// Is the outpoint and/or short channel ID correct?
-	if uint32(chanOutputIdx) != chanOp.Index {
+	if uint32(shortChanID.TxPosition) != chanOp.Index {
 		return fmt.Errorf("output index of --short_channel_id must " +
 			"be equal to index on --channelpoint")
 	}
@@ -303,6 +312,218 @@ func backupFromGraph(graph *lnrpc.ChannelGraph, keyRing *lnd.HDKeyRing,
 	return writeBackups(singles, keyRing, multiFile)
 }
 
+// singlesFromCSV parses the given CSV content and creates a fake single channel
+// backup for each line. All lines are validated and all errors are returned
+// together, prefixed with their line number.
+func singlesFromCSV(csvBytes []byte) ([]chanbackup.Single, error) {
+	reader := csv.NewReader(bytes.NewReader(csvBytes))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var (
+		singles  []chanbackup.Single
+		lineErrs []string
+	)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		// A syntax error (for example a broken quote) can't be
+		// recovered from, the error already contains the line number.
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		// Skip an optional header line.
+		if len(singles) == 0 && len(lineErrs) == 0 &&
+			strings.TrimSpace(record[0]) == "node_pubkey" {
+
+			continue
+		}
+
+		single, err := singleFromCSVRecord(record)
+		if err != nil {
+			lineErrs = append(lineErrs, fmt.Sprintf("line %d: %v",
+				line, err))
+			continue
+		}
+		singles = append(singles, *single)
+	}
+
+	if len(lineErrs) > 0 {
+		return nil, fmt.Errorf("%d invalid line(s):\n%s", len(lineErrs),
+			strings.Join(lineErrs, "\n"))
+	}
+	if len(singles) == 0 {
+		return nil, fmt.Errorf("no channels found")
+	}
+
+	return singles, nil
+}
+
+// singleFromCSVRecord creates a fake single channel backup from the fields of
+// one CSV line.
+func singleFromCSVRecord(record []string) (*chanbackup.Single, error) {
+	if len(record) != 6 && len(record) != 7 {
+		return nil, fmt.Errorf("expected 6 or 7 columns, got %d",
+			len(record))
+	}
+	for idx := range record {
+		record[idx] = strings.TrimSpace(record[idx])
+	}
+
+	pubKeyBytes, err := hex.DecodeString(record[0])
+	if err != nil {
+		return nil, fmt.Errorf("could not parse node_pubkey hex "+
+			"string: %w", err)
+	}
+	nodePubkey, err := btcec.ParsePubKey(pubKeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse node_pubkey: %w", err)
+	}
+
+	txid, err := chainhash.NewHashFromStr(record[1])
+	if err != nil {
+		return nil, fmt.Errorf("could not parse funding_txid: %w", err)
+	}
+	vout, err := strconv.ParseUint(record[2], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse funding_vout: %w", err)
+	}
+
+	capacity, err := strconv.ParseUint(record[3], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse capacity: %w", err)
+	}
+	if capacity == 0 {
+		return nil, fmt.Errorf("capacity must be greater than zero")
+	}
+
+	version, err := parseChannelAddressType(record[4])
+	if err != nil {
+		return nil, err
+	}
+
+	shortChanID, err := parseShortChanID(record[5])
+	if err != nil {
+		return nil, err
+	}
+	if uint32(shortChanID.TxPosition) != uint32(vout) {
+		return nil, fmt.Errorf("output index of short_channel_id " +
+			"must be equal to funding_vout")
+	}
+
+	var addrs []net.Addr
+	if len(record) == 7 && record[6] != "" {
+		addr, err := parseAddr(record[6])
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+
+	chanOp := wire.OutPoint{
+		Hash:  *txid,
+		Index: uint32(vout),
+	}
+	single := newSingle(
+		chanOp, shortChanID, nodePubkey, addrs,
+		btcutil.Amount(capacity),
+	)
+	single.Version = version
+
+	return &single, nil
+}
+
+// parseChannelAddressType returns the single channel backup version for the
+// given channel address type.
+func parseChannelAddressType(
+	addrType string) (chanbackup.SingleBackupVersion, error) {
+
+	switch strings.ToLower(addrType) {
+	case "legacy":
+		return chanbackup.DefaultSingleVersion, nil
+
+	case "static_remote_key":
+		return chanbackup.TweaklessCommitVersion, nil
+
+	case "anchors":
+		return chanbackup.AnchorsCommitVersion, nil
+
+	case "anchors_zero_fee_htlc":
+		return chanbackup.AnchorsZeroFeeHtlcTxCommitVersion, nil
+
+	default:
+		return 0, fmt.Errorf("unknown channel_address_type %s, must "+
+			"be one of legacy, static_remote_key, anchors or "+
+			"anchors_zero_fee_htlc", addrType)
+	}
+}
+
+// parseShortChanID parses a short channel ID in the format
+// <blockheight>x<transactionindex>x<outputindex>.
+func parseShortChanID(shortChanID string) (lnwire.ShortChannelID, error) {
+	splitChanID := strings.Split(shortChanID, "x")
+	if len(splitChanID) != 3 {
+		return lnwire.ShortChannelID{}, fmt.Errorf("short channel ID " +
+			"expected in format: <blockheight>x<transactionindex>" +
+			"x<outputindex>")
+	}
+	blockHeight, err := strconv.ParseUint(splitChanID[0], 10, 24)
+	if err != nil {
+		return lnwire.ShortChannelID{}, fmt.Errorf("could not parse "+
+			"block height: %w", err)
+	}
+	txIndex, err := strconv.ParseUint(splitChanID[1], 10, 24)
+	if err != nil {
+		return lnwire.ShortChannelID{}, fmt.Errorf("could not parse "+
+			"transaction index: %w", err)
+	}
+	chanOutputIdx, err := strconv.ParseUint(splitChanID[2], 10, 16)
+	if err != nil {
+		return lnwire.ShortChannelID{}, fmt.Errorf("could not parse "+
+			"output index: %w", err)
+	}
+
+	return lnwire.ShortChannelID{
+		BlockHeight: uint32(blockHeight),
+		TxIndex:     uint32(txIndex),
+		TxPosition:  uint16(chanOutputIdx),
+	}, nil
+}
+
+// parseAddr parses a node address in the format host:port, which can also be
+// a Tor onion address.
+func parseAddr(hostPort string) (net.Addr, error) {
+	host, portStr, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, fmt.Errorf("could not split host and port: %w", err)
+	}
+
+	if tor.IsOnionHost(host) {
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse port: %w", err)
+		}
+		return &tor.OnionAddr{
+			OnionService: host,
+			Port:         port,
+		}, nil
+	}
+
+	addr, err := net.ResolveTCPAddr("tcp", hostPort)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse addr: %w", err)
+	}
+
+	return addr, nil
+}
+
 func writeBackups(singles []chanbackup.Single, keyRing keychain.KeyRing,
 	multiFile *chanbackup.MultiFile) error {
 
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/chanbackup"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testFakeNodePubKey = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dc" +
+		"e28d959f2815b16f81798"
+	testFakeTXID = "10279f62619634058b6133cb7ac6c1693a8e6df7caa91c6263c" +
+		"a3d0bf704ad4d"
+)
+
+func TestSinglesFromCSV(t *testing.T) {
+	csvContent := "node_pubkey,funding_txid,funding_vout,capacity," +
+		"channel_address_type,short_channel_id,node_addr\n" +
+		"# A comment.\n" +
+		testFakeNodePubKey + "," + testFakeTXID + ",0,100000," +
+		"legacy,566222x300x0\n" +
+		testFakeNodePubKey + ", " + testFakeTXID + ", 1, 200000, " +
+		"anchors, 566222x301x1, 127.0.0.1:9735\n"
+
+	singles, err := singlesFromCSV([]byte(csvContent))
+	require.NoError(t, err)
+	require.Len(t, singles, 2)
+
+	require.EqualValues(
+		t, chanbackup.DefaultSingleVersion, singles[0].Version,
+	)
+	require.Equal(t, testFakeTXID+":0", singles[0].FundingOutpoint.String())
+	require.EqualValues(t, 100_000, singles[0].Capacity)
+	require.Equal(t, "566222:300:0", singles[0].ShortChannelID.String())
+	require.Empty(t, singles[0].Addresses)
+
+	require.EqualValues(
+		t, chanbackup.AnchorsCommitVersion, singles[1].Version,
+	)
+	require.EqualValues(t, 200_000, singles[1].Capacity)
+	require.Len(t, singles[1].Addresses, 1)
+	require.Equal(t, "127.0.0.1:9735", singles[1].Addresses[0].String())
+
+	// The created backup must be readable again.
+	h := newHarness(t)
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	keyRing := &lnd.HDKeyRing{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+	backupFile := h.tempFile("fake.backup")
+	multiFile := chanbackup.NewMultiFile(backupFile)
+	require.NoError(t, writeBackups(singles, keyRing, multiFile))
+
+	packed, err := ioutil.ReadFile(backupFile)
+	require.NoError(t, err)
+	multi := &chanbackup.Multi{}
+	err = multi.UnpackFromReader(bytes.NewReader(packed), keyRing)
+	require.NoError(t, err)
+	require.Len(t, multi.StaticBackups, 2)
+}
+
+func TestSinglesFromCSVErrors(t *testing.T) {
+	csvContent := testFakeNodePubKey + ",nottxid,0,100000,legacy," +
+		"566222x300x0\n" +
+		testFakeNodePubKey + "," + testFakeTXID + ",0,0,legacy," +
+		"566222x300x0\n" +
+		testFakeNodePubKey + "," + testFakeTXID + ",0,100000,taproot," +
+		"566222x300x0\n" +
+		testFakeNodePubKey + "," + testFakeTXID + ",1,100000,legacy," +
+		"566222x300x0\n" +
+		testFakeNodePubKey + "," + testFakeTXID + ",0,100000,legacy\n" +
+		testFakeNodePubKey + "," + testFakeTXID + ",0,100000,legacy," +
+		"566222x300x0\n"
+
+	_, err := singlesFromCSV([]byte(csvContent))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "5 invalid line(s)")
+	require.Contains(t, err.Error(), "line 1: could not parse funding_txid")
+	require.Contains(t, err.Error(), "line 2: capacity must be greater")
+	require.Contains(
+		t, err.Error(), "line 3: unknown channel_address_type taproot",
+	)
+	require.Contains(t, err.Error(), "line 4: output index of")
+	require.Contains(t, err.Error(), "line 5: expected 6 or 7 columns")
+	require.NotContains(t, err.Error(), "line 6")
+
+	_, err = singlesFromCSV([]byte("\n"))
+	require.ErrorContains(t, err, "no channels found")
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"encoding/hex"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/guggero/chantools/lnd"
 	"github.com/lightningnetwork/lnd/chanbackup"
 	"github.com/lightningnetwork/lnd/keychain"
@@ -13,8 +16,12 @@ import (
 )
 
 type filterBackupCommand struct {
-	MultiFile string
-	Discard   string
+	MultiFile    string
+	Discard      string
+	MinCapacity  uint64
+	MaxCapacity  uint64
+	Peers        []string
+	ExcludePeers []string
 
 	rootKey *rootKey
 	cmd     *cobra.Command
@@ -27,21 +34,51 @@ func newFilterBackupCommand() *cobra.Command {
 		Short: "Filter an lnd channel.backup file and remove certain " +
 			"channels",
 		Long: `Filter an lnd channel.backup file by removing certain 
-channels (identified by their funding transaction outpoints).`,
+channels (identified by their funding transaction outpoints).
+
+Channels can also be filtered by their capacity and by the peer they are with.
+With --peer only the channels with the given peers are kept, with
+--exclude_peer the channels with the given peers are removed. A channel is only
+kept if it matches all of the given filters. The number of kept and dropped
+channels is reported and the kept channels are written to a new, encrypted
+channel.backup file.`,
 		Example: `chantools filterbackup \
 	--multi_file ~/.lnd/data/chain/bitcoin/mainnet/channel.backup \
-	--discard 2abcdef2b2bffaaa...db0abadd:1,4abcdef2b2bffaaa...db8abadd:0`,
+	--discard 2abcdef2b2bffaaa...db0abadd:1,4abcdef2b2bffaaa...db8abadd:0
+
+chantools filterbackup \
+	--multi_file ~/.lnd/data/chain/bitcoin/mainnet/channel.backup \
+	--min_capacity 100000 \
+	--exclude_peer 03abcdef......`,
 		RunE: cc.Execute,
 	}
 	cc.cmd.Flags().StringVar(
-		&cc.MultiFile, "multi_file", "", "lnd channel.backup file to "+
-			"filter",
+		&cc.MultiFile, "multi_file", "", "lnd channel.backup file "+
+			"(or a directory of channel backup files) to filter",
 	)
 	cc.cmd.Flags().StringVar(
 		&cc.Discard, "discard", "", "comma separated list of channel "+
 			"funding outpoints (format <fundingTXID>:<index>) to "+
 			"remove from the backup file",
 	)
+	cc.cmd.Flags().Uint64Var(
+		&cc.MinCapacity, "min_capacity", 0, "remove all channels "+
+			"with a capacity below this amount in satoshis",
+	)
+	cc.cmd.Flags().Uint64Var(
+		&cc.MaxCapacity, "max_capacity", 0, "remove all channels "+
+			"with a capacity above this amount in satoshis",
+	)
+	cc.cmd.Flags().StringSliceVar(
+		&cc.Peers, "peer", nil, "only keep the channels with these "+
+			"peers (identity public keys, can be specified "+
+			"multiple times or comma separated)",
+	)
+	cc.cmd.Flags().StringSliceVar(
+		&cc.ExcludePeers, "exclude_peer", nil, "remove the channels "+
+			"with these peers (identity public keys, can be "+
+			"specified multiple times or comma separated)",
+	)
 
 	cc.rootKey = newRootKey(cc.cmd, "decrypting the backup")
 
@@ -54,42 +91,109 @@ func (c *filterBackupCommand) Execute(_ *cobra.Command, _ []string) error {
 		return fmt.Errorf("error reading root key: %w", err)
 	}
 
-	// Parse discard filter.
-	discard := strings.Split(c.Discard, ",")
+	// Parse the filters.
+	filter := &backupFilter{
+		discard:     strings.Split(c.Discard, ","),
+		minCapacity: btcutil.Amount(c.MinCapacity),
+		maxCapacity: btcutil.Amount(c.MaxCapacity),
+	}
+	filter.peers, err = parsePeers(c.Peers)
+	if err != nil {
+		return err
+	}
+	filter.excludePeers, err = parsePeers(c.ExcludePeers)
+	if err != nil {
+		return err
+	}
 
 	// Check that we have a backup file.
 	if c.MultiFile == "" {
 		return fmt.Errorf("backup file is required")
 	}
-	multiFile := chanbackup.NewMultiFile(c.MultiFile)
 	keyRing := &lnd.HDKeyRing{
 		ExtendedKey: extendedKey,
 		ChainParams: chainParams,
 	}
-	return filterChannelBackup(multiFile, keyRing, discard)
+	return filterChannelBackup(c.MultiFile, keyRing, filter)
 }
 
-func filterChannelBackup(multiFile *chanbackup.MultiFile, ring keychain.KeyRing,
-	discard []string) error {
+// backupFilter decides which channels of a backup are kept.
+type backupFilter struct {
+	discard      []string
+	minCapacity  btcutil.Amount
+	maxCapacity  btcutil.Amount
+	peers        map[string]struct{}
+	excludePeers map[string]struct{}
+}
 
-	multi, err := multiFile.ExtractMulti(ring)
+// keep returns true if the given channel matches all filters and should be
+// kept in the backup.
+func (f *backupFilter) keep(single *chanbackup.Single) bool {
+	for _, discardChanPoint := range f.discard {
+		if single.FundingOutpoint.String() == discardChanPoint {
+			return false
+		}
+	}
+
+	if single.Capacity < f.minCapacity {
+		return false
+	}
+	if f.maxCapacity != 0 && single.Capacity > f.maxCapacity {
+		return false
+	}
+
+	peer := hex.EncodeToString(single.RemoteNodePub.SerializeCompressed())
+	if _, ok := f.excludePeers[peer]; ok {
+		return false
+	}
+	if len(f.peers) > 0 {
+		if _, ok := f.peers[peer]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parsePeers parses the given hex encoded identity public keys into a set.
+func parsePeers(peers []string) (map[string]struct{}, error) {
+	result := make(map[string]struct{}, len(peers))
+	for _, peer := range peers {
+		peerBytes, err := hex.DecodeString(peer)
+		if err != nil {
+			return nil, fmt.Errorf("error hex decoding peer %s: %w",
+				peer, err)
+		}
+		pubKey, err := btcec.ParsePubKey(peerBytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing peer %s: %w",
+				peer, err)
+		}
+
+		result[hex.EncodeToString(pubKey.SerializeCompressed())] =
+			struct{}{}
+	}
+
+	return result, nil
+}
+
+func filterChannelBackup(multiFile string, ring keychain.KeyRing,
+	filter *backupFilter) error {
+
+	multi, err := extractMulti(multiFile, ring)
 	if err != nil {
-		return fmt.Errorf("could not extract multi file: %w", err)
+		return err
 	}
 
 	keep := make([]chanbackup.Single, 0, len(multi.StaticBackups))
-	for _, single := range multi.StaticBackups {
-		found := false
-		for _, discardChanPoint := range discard {
-			if single.FundingOutpoint.String() == discardChanPoint {
-				found = true
-			}
-		}
-		if found {
-			continue
+	for idx := range multi.StaticBackups {
+		single := multi.StaticBackups[idx]
+		if filter.keep(&single) {
+			keep = append(keep, single)
 		}
-		keep = append(keep, single)
 	}
+	log.Infof("Kept %d channel(s), dropped %d channel(s)", len(keep),
+		len(multi.StaticBackups)-len(keep))
 	multi.StaticBackups = keep
 
 	fileName := fmt.Sprintf("results/backup-filtered-%s.backup",
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/chanbackup"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupFilter(t *testing.T) {
+	newPeer := func() *btcec.PublicKey {
+		privKey, err := btcec.NewPrivateKey()
+		require.NoError(t, err)
+		return privKey.PubKey()
+	}
+	peerA, peerB := newPeer(), newPeer()
+	peerAHex := hex.EncodeToString(peerA.SerializeCompressed())
+	peerBHex := hex.EncodeToString(peerB.SerializeCompressed())
+
+	newSingle := func(peer *btcec.PublicKey, capacity int64,
+		index uint32) *chanbackup.Single {
+
+		return &chanbackup.Single{
+			FundingOutpoint: wire.OutPoint{
+				Hash:  chainhash.Hash{1},
+				Index: index,
+			},
+			RemoteNodePub: peer,
+			Capacity:      btcutil.Amount(capacity),
+		}
+	}
+	small := newSingle(peerA, 50_000, 0)
+	big := newSingle(peerB, 5_000_000, 1)
+
+	peers, err := parsePeers([]string{peerAHex})
+	require.NoError(t, err)
+	excludePeers, err := parsePeers([]string{peerBHex})
+	require.NoError(t, err)
+
+	_, err = parsePeers([]string{"02abcd"})
+	require.ErrorContains(t, err, "error parsing peer")
+
+	testCases := []struct {
+		name      string
+		filter    *backupFilter
+		keepSmall bool
+		keepBig   bool
+	}{{
+		name:      "no filter",
+		filter:    &backupFilter{},
+		keepSmall: true,
+		keepBig:   true,
+	}, {
+		name: "discard",
+		filter: &backupFilter{
+			discard: []string{small.FundingOutpoint.String()},
+		},
+		keepBig: true,
+	}, {
+		name:    "min capacity",
+		filter:  &backupFilter{minCapacity: 100_000},
+		keepBig: true,
+	}, {
+		name:      "max capacity",
+		filter:    &backupFilter{maxCapacity: 1_000_000},
+		keepSmall: true,
+	}, {
+		name:      "peer",
+		filter:    &backupFilter{peers: peers},
+		keepSmall: true,
+	}, {
+		name:      "exclude peer",
+		filter:    &backupFilter{excludePeers: excludePeers},
+		keepSmall: true,
+	}, {
+		name: "all filters must match",
+		filter: &backupFilter{
+			peers:       peers,
+			minCapacity: 100_000,
+		},
+	}}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.keepSmall, tc.filter.keep(small))
+			require.Equal(t, tc.keepBig, tc.filter.keep(big))
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/guggero/chantools/dump"
 	"github.com/guggero/chantools/lnd"
 	"github.com/lightningnetwork/lnd/chanbackup"
 	"github.com/lightningnetwork/lnd/keychain"
@@ -30,7 +31,13 @@ func newFixOldBackupCommand() *cobra.Command {
 lnd issue [#3881](https://github.com/lightningnetwork/lnd/issues/3881)
 (<code>[lncli] unable to restore chan backups: rpc error: code = Unknown desc =
 unable to unpack chan backup: unable to derive shachain root key: unable to
-derive private key</code>).`,
+derive private key</code>).
+
+The command also reports the backup format version of each channel in the
+file. All versions ever written by lnd (including the ones written by lnd
+versions before v0.13) can still be read by chantools and lnd, so the only
+conversion that is needed for old backups is fixing the shachain root key
+descriptor. If no channel is affected, the command doesn't write a new file.`,
 		Example: `chantools fixoldbackup \
 	--multi_file ~/.lnd/data/chain/bitcoin/mainnet/channel.backup`,
 		RunE: cc.Execute,
@@ -71,6 +78,8 @@ func fixOldChannelBackup(multiFile *chanbackup.MultiFile,
 		return fmt.Errorf("could not extract multi file: %w", err)
 	}
 
+	logBackupVersions(multi)
+
 	log.Infof("Checking shachain root of %d channels, this might take a "+
 		"while.", len(multi.StaticBackups))
 	fixedChannels := 0
@@ -102,7 +111,8 @@ func fixOldChannelBackup(multiFile *chanbackup.MultiFile,
 		}
 	}
 	if fixedChannels == 0 {
-		log.Info("No channels were affected by issue #3881, nothing " +
+		log.Info("No channels were affected by issue #3881, the " +
+			"backup is already in the current format, nothing " +
 			"to fix.")
 		return nil
 	}
@@ -122,3 +132,26 @@ func fixOldChannelBackup(multiFile *chanbackup.MultiFile,
 	}
 	return nil
 }
+
+// logBackupVersions logs how many channels of each backup version the given
+// multi backup contains.
+func logBackupVersions(multi *chanbackup.Multi) {
+	versions := make(map[chanbackup.SingleBackupVersion]int)
+	for _, single := range multi.StaticBackups {
+		versions[single.Version]++
+	}
+
+	log.Infof("Backup file has version %d and contains %d channels",
+		multi.Version, len(multi.StaticBackups))
+	for version := chanbackup.SingleBackupVersion(0); version <=
+		chanbackup.ScriptEnforcedLeaseVersion; version++ {
+
+		if versions[version] == 0 {
+			continue
+		}
+
+		log.Infof(" --> %d channel(s) with backup version %d (%s)",
+			versions[version], version,
+			dump.SingleVersionName(version))
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/chanbackup"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixOldBackupNothingToFix(t *testing.T) {
+	h := newHarness(t)
+
+	// Create a channel backup from a channel DB file.
+	makeBackup := &chanBackupCommand{
+		ChannelDB: h.testdataFile("channel.db"),
+		MultiFile: h.tempFile("extracted.backup"),
+		rootKey:   &rootKey{RootKey: rootKeyAezeed},
+	}
+	err := makeBackup.Execute(nil, nil)
+	require.NoError(t, err)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+
+	h.clearLog()
+	err = fixOldChannelBackup(
+		chanbackup.NewMultiFile(makeBackup.MultiFile), &lnd.HDKeyRing{
+			ExtendedKey: extendedKey,
+			ChainParams: chainParams,
+		},
+	)
+	require.NoError(t, err)
+
+	h.assertLogContains("Backup file has version 0 and contains 4 channels")
+	h.assertLogContains("with backup version")
+	h.assertLogContains("already in the current format, nothing to fix")
+}
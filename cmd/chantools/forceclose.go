@@ -4,14 +4,16 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"time"
 
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
 	"github.com/btcsuite/btcd/txscript"
-	"github.com/guggero/chantools/btc"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/guggero/chantools/dataformat"
 	"github.com/guggero/chantools/lnd"
 	"github.com/lightningnetwork/lnd/channeldb"
@@ -23,6 +25,8 @@ type forceCloseCommand struct {
 	APIURL    string
 	ChannelDB string
 	Publish   bool
+	DryRun    bool
+	Psbt      bool
 
 	rootKey *rootKey
 	inputs  *inputFlags
@@ -46,7 +50,13 @@ the remote node *could* punish you by taking the whole channel amount *if* they
 come online before you can sweep the funds from the time locked (144 - 2000
 blocks) transaction *or* they have a watch tower looking out for them.
 
-**This should absolutely be the last resort and you have been warned!**`,
+**This should absolutely be the last resort and you have been warned!**
+
+With the --psbt flag, the commitment transactions are not signed. Instead a
+PSBT is created for each channel that contains the remote party's signature,
+the funding output, the multisig witness script and the BIP32 derivation path
+of the local multisig key, so it can be signed and finalized on another
+machine.`,
 		Example: `chantools forceclose \
 	--fromsummary results/summary-xxxx-yyyy.json
 	--channeldb ~/.lnd/data/graph/mainnet/channel.db \
@@ -65,6 +75,8 @@ blocks) transaction *or* they have a watch tower looking out for them.
 		&cc.Publish, "publish", false, "publish force-closing TX to "+
 			"the chain API instead of just printing the TX",
 	)
+	addDryRunFlag(cc.cmd, &cc.DryRun)
+	addPsbtFlag(cc.cmd, &cc.Psbt)
 
 	cc.rootKey = newRootKey(cc.cmd, "decrypting the backup")
 	cc.inputs = newInputFlags(cc.cmd)
@@ -82,6 +94,10 @@ func (c *forceCloseCommand) Execute(_ *cobra.Command, _ []string) error {
 	if c.ChannelDB == "" {
 		return fmt.Errorf("rescue DB is required")
 	}
+	if c.Psbt && c.Publish {
+		return fmt.Errorf("cannot publish a PSBT, it must be signed " +
+			"first")
+	}
 	db, err := lnd.OpenDB(c.ChannelDB, true)
 	if err != nil {
 		return fmt.Errorf("error opening rescue DB: %w", err)
@@ -92,20 +108,25 @@ func (c *forceCloseCommand) Execute(_ *cobra.Command, _ []string) error {
 	if err != nil {
 		return err
 	}
+	publish := shouldPublish(c.Publish, c.DryRun, c.APIURL)
 	return forceCloseChannels(
-		c.APIURL, extendedKey, entries, db.ChannelStateDB(), c.Publish,
+		c.APIURL, extendedKey, entries, db.ChannelStateDB(), publish,
+		c.Psbt,
 	)
 }
 
 func forceCloseChannels(apiURL string, extendedKey *hdkeychain.ExtendedKey,
 	entries []*dataformat.SummaryEntry, chanDb *channeldb.ChannelStateDB,
-	publish bool) error {
+	publish, createPsbt bool) error {
 
 	channels, err := chanDb.FetchAllChannels()
 	if err != nil {
 		return err
 	}
-	api := &btc.ExplorerAPI{BaseURL: apiURL}
+	api, err := newChainBackend(apiURL)
+	if err != nil {
+		return err
+	}
 	signer := &lnd.Signer{
 		ExtendedKey: extendedKey,
 		ChainParams: chainParams,
@@ -144,22 +165,68 @@ func forceCloseChannels(apiURL string, extendedKey *hdkeychain.ExtendedKey,
 			TXSigner:      signer,
 		}
 		err := lc.CreateSignDesc()
+		if errors.Is(err, lnd.ErrSimpleTaprootUnsupported) {
+			log.Errorf("Cannot force-close channel %s: %v",
+				channelEntry.ChannelPoint, err)
+
+			continue
+		}
 		if err != nil {
 			return err
 		}
 
-		// Serialize transaction.
-		signedTx, err := lc.SignedCommitTx()
+		// The fee of the commitment TX was negotiated with the peer,
+		// so only the hard cap of the fee rate applies.
+		signDescs := []*input.SignDescriptor{lc.SignDesc}
+		err = validateSweepTx(
+			api, localCommitTx, signDescs,
+			multiSigVSize(localCommitTx), 0,
+		)
 		if err != nil {
-			return err
+			return fmt.Errorf("error validating commitment TX of "+
+				"channel %s: %w", channelEntry.ChannelPoint,
+				err)
 		}
-		var buf bytes.Buffer
-		err = signedTx.Serialize(io.Writer(&buf))
-		if err != nil {
-			return err
+
+		// The TXID doesn't depend on the witness, so it's the same for
+		// the signed TX and the PSBT.
+		var (
+			hash       = localCommitTx.TxHash()
+			signedTx   *wire.MsgTx
+			serialized string
+			packetB64  string
+		)
+		if createPsbt {
+			packet, err := commitTxPsbt(extendedKey, lc)
+			if err != nil {
+				return err
+			}
+			packetB64, err = packet.B64Encode()
+			if err != nil {
+				return fmt.Errorf("error encoding PSBT: %w",
+					err)
+			}
+			written, err := writePsbtOutput(packet)
+			if err != nil {
+				return err
+			}
+			if !written {
+				log.Infof("PSBT for channel %s: %s",
+					channelEntry.ChannelPoint, packetB64)
+			}
+		} else {
+			// Serialize transaction.
+			signedTx, err = lc.SignedCommitTx()
+			if err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			err = signedTx.Serialize(io.Writer(&buf))
+			if err != nil {
+				return err
+			}
+			serialized = hex.EncodeToString(buf.Bytes())
 		}
-		hash := signedTx.TxHash()
-		serialized := hex.EncodeToString(buf.Bytes())
 
 		// Calculate commit point.
 		basepoint := channel.LocalChanCfg.DelayBasePoint
@@ -178,6 +245,7 @@ func forceCloseChannels(apiURL string, extendedKey *hdkeychain.ExtendedKey,
 		channelEntry.ForceClose = &dataformat.ForceClose{
 			TXID:       hash.String(),
 			Serialized: serialized,
+			Psbt:       packetB64,
 			DelayBasePoint: &dataformat.BasePoint{
 				Family: uint16(basepoint.Family),
 				Index:  basepoint.Index,
@@ -210,14 +278,17 @@ func forceCloseChannels(apiURL string, extendedKey *hdkeychain.ExtendedKey,
 			}
 		}
 
-		// Publish TX.
-		if publish {
-			response, err := api.PublishTx(serialized)
+		// Publish TX. The commitment TX spends the funding output, so
+		// everything that isn't paid to an output is the fee.
+		if signedTx != nil {
+			fee := int64(channel.Capacity)
+			for _, out := range signedTx.TxOut {
+				fee -= out.Value
+			}
+			err := publishTx(api, signedTx, fee, publish)
 			if err != nil {
 				return err
 			}
-			log.Infof("Published TX %s, response: %s",
-				hash.String(), response)
 		}
 	}
 
@@ -232,3 +303,31 @@ func forceCloseChannels(apiURL string, extendedKey *hdkeychain.ExtendedKey,
 	log.Infof("Writing result to %s", fileName)
 	return ioutil.WriteFile(fileName, summaryBytes, 0644)
 }
+
+// commitTxPsbt creates a PSBT of the local commitment transaction of the given
+// channel that already contains the remote party's signature.
+func commitTxPsbt(extendedKey *hdkeychain.ExtendedKey,
+	lc *lnd.LightningChannel) (*psbt.Packet, error) {
+
+	localCommit := lc.ChannelState.LocalCommitment
+	packet, err := newSweepPsbt(
+		extendedKey, localCommit.CommitTx.Copy(),
+		[]*input.SignDescriptor{lc.SignDesc},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature in the channel DB doesn't contain the sighash flag.
+	theirSig := make([]byte, 0, len(localCommit.CommitSig)+1)
+	theirSig = append(theirSig, localCommit.CommitSig...)
+	theirSig = append(theirSig, byte(txscript.SigHashAll))
+
+	theirKey := lc.RemoteChanCfg.MultiSigKey.PubKey
+	packet.Inputs[0].PartialSigs = []*psbt.PartialSig{{
+		PubKey:    theirKey.SerializeCompressed(),
+		Signature: theirSig,
+	}}
+
+	return packet, nil
+}
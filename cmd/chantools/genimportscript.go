@@ -22,6 +22,7 @@ type genImportScriptCommand struct {
 	RecoveryWindow uint32
 	RescanFrom     uint32
 	Stdout         bool
+	AddrType       string
 
 	rootKey *rootKey
 	cmd     *cobra.Command
@@ -49,16 +50,34 @@ The following script formats are currently supported:
 * bitcoin-importwallet: Creates a text output that is compatible with
   bitcoind's importwallet command.
 * electrum: Creates a text output that contains one private key per line with
-  the address type as the prefix, the way Electrum expects them.`,
+  the address type as the prefix, the way Electrum expects them.
+* descriptors: Creates a bitcoin-cli importdescriptors command that imports
+  ranged output descriptors (with checksum and key origin) for the external and
+  internal branch of each account into a Bitcoin Core descriptor wallet. If no
+  derivation path is specified, the BIP49, BIP84 and BIP86 (taproot) accounts of
+  the lnd wallet are used. The descriptors contain the private account keys.
+  Because bitcoind expects a time instead of a block to rescan from, the wallet
+  birthday of the aezeed is used if available, otherwise the whole chain is
+  rescanned.
+* electrum-masterkey: Creates the SLIP-0132 encoded master private and public
+  key (zprv/zpub or yprv/ypub) of the wallet account selected with --addrtype
+  that can be used to restore a standard wallet in Electrum with the "Use a
+  master key" option. Unlike the electrum format this covers all addresses of
+  the account, not just the ones within the recovery window.`,
 		Example: `chantools genimportscript --format bitcoin-cli \
-	--recoverywindow 5000`,
+	--recoverywindow 5000
+
+chantools genimportscript --format descriptors --stdout
+
+chantools genimportscript --format electrum-masterkey --addrtype np2wkh`,
 		RunE: cc.Execute,
 	}
 	cc.cmd.Flags().StringVar(
 		&cc.Format, "format", "bitcoin-importwallet", "format of the "+
 			"generated import script; currently supported are: "+
 			"bitcoin-importwallet, bitcoin-cli, "+
-			"bitcoin-cli-watchonly and electrum",
+			"bitcoin-cli-watchonly, electrum, electrum-masterkey "+
+			"and descriptors",
 	)
 	cc.cmd.Flags().BoolVar(
 		&cc.LndPaths, "lndpaths", false, "use all derivation paths "+
@@ -85,6 +104,12 @@ The following script formats are currently supported:
 	cc.cmd.Flags().BoolVar(
 		&cc.Stdout, "stdout", false, "write generated import script "+
 			"to standard out instead of writing it to a file")
+	cc.cmd.Flags().StringVar(
+		&cc.AddrType, "addrtype", addrTypeP2WKH, "address type of "+
+			"the wallet account to export with the "+
+			"electrum-masterkey format; can be "+addrTypeP2WKH+
+			" or "+addrTypeNP2WKH+" (P2SH wrapped P2WKH)",
+	)
 
 	cc.rootKey = newRootKey(cc.cmd, "decrypting the backup")
 
@@ -119,6 +144,8 @@ func (c *genImportScriptCommand) Execute(_ *cobra.Command, _ []string) error {
 	}
 
 	// Decide what derivation path(s) to use.
+	descriptors := c.Format == btc.FormatDescriptors
+	masterKey := c.Format == btc.FormatElectrumMasterKey
 	switch {
 	default:
 		c.DerivationPath = lnd.WalletDefaultDerivationPath
@@ -141,6 +168,43 @@ func (c *genImportScriptCommand) Execute(_ *cobra.Command, _ []string) error {
 		if err != nil {
 			return fmt.Errorf("error getting lnd paths: %w", err)
 		}
+
+	// Electrum can only restore one account from a master key, the address
+	// type decides which one.
+	case masterKey:
+		switch c.AddrType {
+		case addrTypeP2WKH:
+			c.DerivationPath = lnd.WalletDefaultDerivationPath
+
+		case addrTypeNP2WKH:
+			c.DerivationPath = lnd.WalletBIP49DerivationPath
+
+		default:
+			return fmt.Errorf("unsupported address type %s",
+				c.AddrType)
+		}
+		derivationPath, err := lnd.ParsePath(c.DerivationPath)
+		if err != nil {
+			return fmt.Errorf("error parsing path: %w", err)
+		}
+		strPaths = []string{c.DerivationPath}
+		paths = [][]uint32{derivationPath}
+
+	// A descriptor wallet can import all accounts of the lnd wallet at
+	// once, including the taproot one.
+	case descriptors:
+		strPaths = []string{
+			lnd.WalletBIP49DerivationPath,
+			lnd.WalletDefaultDerivationPath,
+			lnd.WalletBIP86DerivationPath,
+		}
+		for _, strPath := range strPaths {
+			path, err := lnd.ParsePath(strPath)
+			if err != nil {
+				return fmt.Errorf("error parsing path: %w", err)
+			}
+			paths = append(paths, path)
+		}
 	}
 
 	writer := os.Stdout
@@ -158,6 +222,39 @@ func (c *genImportScriptCommand) Execute(_ *cobra.Command, _ []string) error {
 		}
 	}
 
+	if descriptors {
+		// Only the aezeed knows its birthday, for all other root key
+		// sources we get the unix epoch.
+		if birthday.Unix() > 0 {
+			birthday = birthday.Add(-48 * time.Hour)
+		}
+		err = btc.ExportDescriptors(
+			extendedKey, strPaths, paths, chainParams,
+			c.RecoveryWindow, birthday, writer,
+		)
+		if err != nil {
+			return fmt.Errorf("error exporting descriptors: %w",
+				err)
+		}
+
+		return nil
+	}
+
+	if masterKey {
+		if len(strPaths) != 1 {
+			return fmt.Errorf("can only export one account as an " +
+				"Electrum master key")
+		}
+		err = btc.ExportElectrumMasterKey(
+			extendedKey, strPaths[0], paths[0], chainParams, writer,
+		)
+		if err != nil {
+			return fmt.Errorf("error exporting master key: %w", err)
+		}
+
+		return nil
+	}
+
 	exporter := btc.ParseFormat(c.Format)
 	err = btc.ExportKeys(
 		extendedKey, strPaths, paths, chainParams, c.RecoveryWindow,
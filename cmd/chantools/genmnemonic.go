@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/guggero/chantools/bip39"
+	"github.com/spf13/cobra"
+)
+
+const genMnemonicFormat = `
+Mnemonic:			%s
+Entropy: 			%x
+BIP39 seed: 			%x
+BIP32 HD root key: 		%v
+`
+
+type genMnemonicCommand struct {
+	Bits       int
+	Entropy    string
+	Passphrase string
+	JSON       bool
+	SeedQR     string
+	SeedQRPNG  string
+
+	cmd *cobra.Command
+}
+
+type genMnemonicResult struct {
+	Mnemonic string `json:"mnemonic"`
+	Entropy  string `json:"entropy"`
+	Seed     string `json:"seed"`
+	RootKey  string `json:"root_key"`
+}
+
+func newGenMnemonicCommand() *cobra.Command {
+	cc := &genMnemonicCommand{}
+	cc.cmd = &cobra.Command{
+		Use:   "genmnemonic",
+		Short: "Generate a new BIP39 mnemonic",
+		Long: `This command generates a new BIP39 mnemonic from random
+entropy (or from the entropy given with --entropy) and prints the mnemonic, the
+raw entropy, the BIP39 seed and the BIP32 HD root key derived from it with the
+optional passphrase.
+
+Passing the entropy allows to reproduce the mnemonic deterministically, for
+example to verify a backup.
+
+With --seedqr or --seedqr-png, the mnemonic is also encoded as a standard or
+compact SeedQR code that can be scanned by air-gapped signers like SeedSigner.
+Only 12 and 24 word mnemonics can be encoded as SeedQR.`,
+		Example: `chantools genmnemonic --bits 256
+
+chantools genmnemonic --entropy 00112233445566778899aabbccddeeff --json
+
+chantools genmnemonic --bits 128 --seedqr compact`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().IntVar(
+		&cc.Bits, "bits", 256, "number of bits of random entropy to "+
+			"generate, must be one of 128, 160, 192, 224 or 256",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.Entropy, "entropy", "", "hex encoded entropy to use "+
+			"instead of generating random entropy; overrides "+
+			"--bits",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.Passphrase, "passphrase", "", "optional BIP39 passphrase "+
+			"to use when deriving the seed",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.JSON, "json", false, "print the result in the JSON format",
+	)
+	addSeedQRFlags(cc.cmd, &cc.SeedQR, &cc.SeedQRPNG)
+
+	return cc.cmd
+}
+
+func (c *genMnemonicCommand) Execute(_ *cobra.Command, _ []string) error {
+	if c.Passphrase != "" {
+		warnSecretOnCommandLine("passphrase")
+	}
+	if c.JSON && c.SeedQR != "" && c.SeedQRPNG == "" {
+		return fmt.Errorf("a SeedQR code can't be shown in the JSON " +
+			"format, use --seedqr-png to write it to a file")
+	}
+
+	var (
+		entropy []byte
+		err     error
+	)
+	switch {
+	case c.Entropy != "":
+		entropy, err = hex.DecodeString(c.Entropy)
+		if err != nil {
+			return fmt.Errorf("error decoding entropy: %w", err)
+		}
+
+	default:
+		entropy, err = bip39.NewEntropy(c.Bits)
+		if err != nil {
+			return fmt.Errorf("error creating entropy: %w", err)
+		}
+	}
+
+	mnemonic, err := bip39.EntropyToMnemonic(entropy)
+	if err != nil {
+		return fmt.Errorf("error creating mnemonic: %w", err)
+	}
+
+	seed := bip39.NewSeed(mnemonic, c.Passphrase)
+	rootKey, err := bip39.MasterKeyFromMnemonic(
+		mnemonic, c.Passphrase, chainParams,
+	)
+	if err != nil {
+		return fmt.Errorf("error deriving root key: %w", err)
+	}
+
+	var result string
+	if c.JSON {
+		resultBytes, err := json.MarshalIndent(&genMnemonicResult{
+			Mnemonic: mnemonic,
+			Entropy:  hex.EncodeToString(entropy),
+			Seed:     hex.EncodeToString(seed),
+			RootKey:  rootKey.String(),
+		}, "", " ")
+		if err != nil {
+			return fmt.Errorf("error encoding result: %w", err)
+		}
+		result = string(resultBytes)
+	} else {
+		result = fmt.Sprintf(
+			genMnemonicFormat, mnemonic, entropy, seed, rootKey,
+		)
+	}
+
+	if c.SeedQR != "" || c.SeedQRPNG != "" {
+		qrResult, err := seedQR(mnemonic, c.SeedQR, c.SeedQRPNG)
+		if err != nil {
+			return err
+		}
+
+		if c.JSON {
+			log.Info(strings.TrimSpace(qrResult))
+		} else {
+			result += "\n" + qrResult
+		}
+	}
+	fmt.Println(result)
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(result)
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/guggero/chantools/bip39"
+	"github.com/stretchr/testify/require"
+)
+
+const testEntropyBip39 = "eca254f60d1834dc2779ff9c863d919a"
+
+func TestGenMnemonicEntropy(t *testing.T) {
+	h := newHarness(t)
+
+	// Re-create the BIP39 test seed from its entropy.
+	gen := &genMnemonicCommand{
+		Entropy: testEntropyBip39,
+	}
+
+	err := gen.Execute(nil, nil)
+	require.NoError(t, err)
+
+	h.assertLogContains(seedBip39)
+	h.assertLogContains(rootKeyBip39)
+}
+
+func TestGenMnemonicJSON(t *testing.T) {
+	h := newHarness(t)
+
+	gen := &genMnemonicCommand{
+		Entropy: testEntropyBip39,
+		JSON:    true,
+	}
+
+	err := gen.Execute(nil, nil)
+	require.NoError(t, err)
+
+	h.assertLogContains(`"mnemonic": "` + seedBip39 + `"`)
+	h.assertLogContains(`"entropy": "` + testEntropyBip39 + `"`)
+	h.assertLogContains(`"root_key": "` + rootKeyBip39 + `"`)
+}
+
+func TestGenMnemonicRandom(t *testing.T) {
+	h := newHarness(t)
+
+	// With a deterministic source of randomness, the BIP39 test seed is
+	// created.
+	entropy, err := hex.DecodeString(testEntropyBip39)
+	require.NoError(t, err)
+	restore := bip39.SetRandReader(bytes.NewReader(entropy))
+	defer restore()
+
+	gen := &genMnemonicCommand{
+		Bits: 128,
+	}
+
+	err = gen.Execute(nil, nil)
+	require.NoError(t, err)
+	h.assertLogContains("Mnemonic:")
+	h.assertLogContains(seedBip39)
+	h.assertLogContains(rootKeyBip39)
+
+	gen.Bits = 100
+	err = gen.Execute(nil, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/guggero/chantools/bip39"
+	"github.com/spf13/cobra"
+)
+
+type listWordsCommand struct {
+	Language string
+	Grep     string
+
+	cmd *cobra.Command
+}
+
+func newListWordsCommand() *cobra.Command {
+	cc := &listWordsCommand{}
+	cc.cmd = &cobra.Command{
+		Use:   "listwords",
+		Short: "Print the BIP39 word list with the index of each word",
+		Long: `This command prints all words of a BIP39 word list
+together with their zero-based index in the list. This is useful to cross-check
+a transcribed mnemonic against the canonical word list or to create a SeedQR
+code by hand, which encodes the index of each word as a four digit decimal
+number.
+
+With --grep, only the words that start with the given prefix are printed.`,
+		Example: `chantools listwords
+
+chantools listwords --language english --grep ab`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.Language, "language", "english", "the language of the "+
+			"word list to print; must be one of "+
+			strings.Join(bip39.Languages(), ", "),
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.Grep, "grep", "", "only print the words that start with "+
+			"the given prefix",
+	)
+
+	return cc.cmd
+}
+
+func (c *listWordsCommand) Execute(_ *cobra.Command, _ []string) error {
+	list, err := bip39.WordListByLanguage(c.Language)
+	if err != nil {
+		return err
+	}
+
+	// The suggestions are based on the active word list, so we switch to
+	// the selected one and restore the previous one afterwards.
+	previousList := bip39.GetWordList()
+	if err := bip39.SetWordList(list); err != nil {
+		return err
+	}
+	defer func() {
+		_ = bip39.SetWordList(previousList)
+	}()
+
+	words := list
+	if c.Grep != "" {
+		words = bip39.SuggestWords(c.Grep, bip39.WordListSize)
+		if len(words) == 0 {
+			return fmt.Errorf("no word in the %s word list starts "+
+				"with %q", c.Language, c.Grep)
+		}
+	}
+
+	var result strings.Builder
+	for _, word := range words {
+		index, _ := bip39.GetWordIndex(word)
+		_, _ = fmt.Fprintf(&result, "%04d %s\n", index, word)
+	}
+
+	fmt.Print(result.String())
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(result.String())
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/guggero/chantools/bip39"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListWords(t *testing.T) {
+	h := newHarness(t)
+
+	listWords := &listWordsCommand{
+		Language: "english",
+	}
+	require.NoError(t, listWords.Execute(nil, nil))
+	wordLines := regexp.MustCompile(`(?m)\b\d{4} [a-z]+$`)
+	require.Len(
+		t, wordLines.FindAllString(h.getLog(), -1), bip39.WordListSize,
+	)
+	h.assertLogContains("0000 abandon\n")
+	h.assertLogContains("2047 zoo\n")
+
+	h.clearLog()
+	listWords.Grep = "zo"
+	require.NoError(t, listWords.Execute(nil, nil))
+	require.Contains(t, h.getLog(), "2046 zone\n2047 zoo\n")
+	require.NotContains(t, h.getLog(), "zero")
+
+	listWords.Grep = "xyz"
+	err := listWords.Execute(nil, nil)
+	require.ErrorContains(
+		t, err, `no word in the english word list starts with "xyz"`,
+	)
+
+	listWords.Language = "klingon"
+	err = listWords.Execute(nil, nil)
+	require.ErrorContains(t, err, "must be one of english")
+
+	// The active word list is restored.
+	require.Equal(t, bip39.English, bip39.GetWordList())
+}
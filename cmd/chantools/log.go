@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btclog"
+	"github.com/guggero/chantools/bip39"
+	"github.com/jrick/logrotate/rotator"
+	"github.com/lightningnetwork/lnd/build"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+
+	// logTimeFormat is the format of the timestamp of text log messages,
+	// the same one btclog uses.
+	logTimeFormat = "2006-01-02 15:04:05.000"
+
+	// redactedValue replaces sensitive values in log messages.
+	redactedValue = "[REDACTED]"
+
+	// minMnemonicWords is the minimum number of consecutive BIP39 words
+	// that are considered a mnemonic and redacted from log messages.
+	minMnemonicWords = 12
+)
+
+var (
+	// logLevelNames are the names of the log levels in JSON log messages.
+	logLevelNames = map[btclog.Level]string{
+		btclog.LevelTrace:    "trace",
+		btclog.LevelDebug:    "debug",
+		btclog.LevelInfo:     "info",
+		btclog.LevelWarn:     "warn",
+		btclog.LevelError:    "error",
+		btclog.LevelCritical: "critical",
+	}
+
+	// extendedPrivKeyRegex matches BIP32 extended private keys of all
+	// networks (xprv, tprv, ...).
+	extendedPrivKeyRegex = regexp.MustCompile(
+		`\b[a-zA-Z]prv[1-9A-HJ-NP-Za-km-z]{100,}\b`,
+	)
+
+	// wifRegex matches private keys in the wallet import format, both
+	// compressed and uncompressed, of mainnet and the test networks.
+	wifRegex = regexp.MustCompile(`\b[5KLc9][1-9A-HJ-NP-Za-km-z]{50,51}\b`)
+
+	// wordsRegex matches runs of at least minMnemonicWords words. Upper
+	// case letters are matched too, as a mnemonic that was typed in with
+	// them is still a valid mnemonic.
+	wordsRegex = regexp.MustCompile(fmt.Sprintf(
+		`(?i)\b[a-z]+(?:\s+[a-z]+){%d,}\b`, minMnemonicWords-1,
+	))
+
+	// logOutput is where all log messages are written to.
+	logOutput io.Writer = os.Stdout
+	logMtx    sync.Mutex
+
+	// logSecrets are values that are redacted from all log messages, in
+	// addition to keys and mnemonics.
+	logSecrets []string
+)
+
+// logger is a leveled logger that writes log messages either as text, in the
+// same format as btclog, or as JSON objects, one per line, depending on the
+// global --logformat flag. Private keys, mnemonics and passphrases are
+// redacted from all messages.
+type logger struct {
+	subsystem string
+	level     uint32
+}
+
+// A compile time check to make sure logger implements the btclog.Logger
+// interface.
+var _ btclog.Logger = (*logger)(nil)
+
+// newLogger creates a new logger for the given subsystem with the info level.
+func newLogger(subsystem string) *logger {
+	return &logger{
+		subsystem: subsystem,
+		level:     uint32(btclog.LevelInfo),
+	}
+}
+
+// jsonLogMessage is a single log message in the JSON format.
+type jsonLogMessage struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Subsystem string `json:"subsystem"`
+	Message   string `json:"message"`
+}
+
+// write formats the given message and writes it to the log output if the level
+// is enabled.
+func (l *logger) write(level btclog.Level, msg string) {
+	if level < l.Level() {
+		return
+	}
+
+	var (
+		now  = time.Now()
+		line []byte
+	)
+	msg = redactSecrets(msg)
+	if LogFormat == logFormatJSON {
+		line, _ = json.Marshal(&jsonLogMessage{
+			Time:      now.UTC().Format(time.RFC3339Nano),
+			Level:     logLevelNames[level],
+			Subsystem: l.subsystem,
+			Message:   msg,
+		})
+	} else {
+		line = []byte(fmt.Sprintf("%s [%s] %s: %s", now.Format(
+			logTimeFormat,
+		), level, l.subsystem, msg))
+	}
+	line = append(line, '\n')
+
+	logMtx.Lock()
+	defer logMtx.Unlock()
+
+	_, _ = logOutput.Write(line)
+}
+
+// Tracef formats the message according to the format specifier and writes it
+// with the trace level.
+func (l *logger) Tracef(format string, params ...interface{}) {
+	l.write(btclog.LevelTrace, fmt.Sprintf(format, params...))
+}
+
+// Debugf formats the message according to the format specifier and writes it
+// with the debug level.
+func (l *logger) Debugf(format string, params ...interface{}) {
+	l.write(btclog.LevelDebug, fmt.Sprintf(format, params...))
+}
+
+// Infof formats the message according to the format specifier and writes it
+// with the info level.
+func (l *logger) Infof(format string, params ...interface{}) {
+	l.write(btclog.LevelInfo, fmt.Sprintf(format, params...))
+}
+
+// Warnf formats the message according to the format specifier and writes it
+// with the warn level.
+func (l *logger) Warnf(format string, params ...interface{}) {
+	l.write(btclog.LevelWarn, fmt.Sprintf(format, params...))
+}
+
+// Errorf formats the message according to the format specifier and writes it
+// with the error level.
+func (l *logger) Errorf(format string, params ...interface{}) {
+	l.write(btclog.LevelError, fmt.Sprintf(format, params...))
+}
+
+// Criticalf formats the message according to the format specifier and writes
+// it with the critical level.
+func (l *logger) Criticalf(format string, params ...interface{}) {
+	l.write(btclog.LevelCritical, fmt.Sprintf(format, params...))
+}
+
+// Trace formats the message using the default formats for its operands and
+// writes it with the trace level.
+func (l *logger) Trace(v ...interface{}) {
+	l.write(btclog.LevelTrace, fmt.Sprint(v...))
+}
+
+// Debug formats the message using the default formats for its operands and
+// writes it with the debug level.
+func (l *logger) Debug(v ...interface{}) {
+	l.write(btclog.LevelDebug, fmt.Sprint(v...))
+}
+
+// Info formats the message using the default formats for its operands and
+// writes it with the info level.
+func (l *logger) Info(v ...interface{}) {
+	l.write(btclog.LevelInfo, fmt.Sprint(v...))
+}
+
+// Warn formats the message using the default formats for its operands and
+// writes it with the warn level.
+func (l *logger) Warn(v ...interface{}) {
+	l.write(btclog.LevelWarn, fmt.Sprint(v...))
+}
+
+// Error formats the message using the default formats for its operands and
+// writes it with the error level.
+func (l *logger) Error(v ...interface{}) {
+	l.write(btclog.LevelError, fmt.Sprint(v...))
+}
+
+// Critical formats the message using the default formats for its operands and
+// writes it with the critical level.
+func (l *logger) Critical(v ...interface{}) {
+	l.write(btclog.LevelCritical, fmt.Sprint(v...))
+}
+
+// Level returns the current logging level.
+func (l *logger) Level() btclog.Level {
+	return btclog.Level(atomic.LoadUint32(&l.level))
+}
+
+// SetLevel changes the logging level to the passed level.
+func (l *logger) SetLevel(level btclog.Level) {
+	atomic.StoreUint32(&l.level, uint32(level))
+}
+
+// initLogFile makes all log messages also be written to the given log file,
+// which is rotated once it reaches maxLogFileSize kilobytes.
+func initLogFile(logFile string, maxLogFileSize, maxLogFiles int) error {
+	logDir, _ := filepath.Split(logFile)
+	if err := os.MkdirAll(logDir, 0700); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	logRotator, err := rotator.New(
+		logFile, int64(maxLogFileSize*1024), false, maxLogFiles,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create file rotator: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		if err := logRotator.Run(pr); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "failed to run file "+
+				"rotator: %v\n", err)
+		}
+	}()
+
+	logMtx.Lock()
+	defer logMtx.Unlock()
+
+	logOutput = &build.LogWriter{RotatorPipe: pw}
+
+	return nil
+}
+
+// redactLogSecret makes sure the given value never appears in any log message.
+func redactLogSecret(secret string) {
+	if secret == "" {
+		return
+	}
+
+	logMtx.Lock()
+	defer logMtx.Unlock()
+
+	logSecrets = append(logSecrets, secret)
+}
+
+// redactSecrets replaces all private keys, mnemonics and registered secrets in
+// the given message.
+func redactSecrets(msg string) string {
+	logMtx.Lock()
+	secrets := logSecrets
+	logMtx.Unlock()
+
+	for _, secret := range secrets {
+		msg = strings.ReplaceAll(msg, secret, redactedValue)
+	}
+
+	// Only replace what actually decodes as a private key, so we don't
+	// redact other values that happen to look similar.
+	msg = extendedPrivKeyRegex.ReplaceAllStringFunc(
+		msg, func(match string) string {
+			key, err := hdkeychain.NewKeyFromString(match)
+			if err != nil || !key.IsPrivate() {
+				return match
+			}
+			return redactedValue
+		},
+	)
+	msg = wifRegex.ReplaceAllStringFunc(msg, func(match string) string {
+		if _, err := btcutil.DecodeWIF(match); err != nil {
+			return match
+		}
+		return redactedValue
+	})
+
+	return wordsRegex.ReplaceAllStringFunc(msg, redactMnemonics)
+}
+
+// redactMnemonics replaces all runs of at least minMnemonicWords words of the
+// BIP39 word list, which the aezeed mnemonics use as well, in the given
+// whitespace separated words.
+func redactMnemonics(words string) string {
+	var (
+		fields = strings.Fields(words)
+		result = make([]string, 0, len(fields))
+		run    []string
+	)
+	flushRun := func() {
+		if len(run) >= minMnemonicWords {
+			result = append(result, redactedValue)
+		} else {
+			result = append(result, run...)
+		}
+		run = nil
+	}
+	for _, word := range fields {
+		_, ok := bip39.GetWordIndex(strings.ToLower(word))
+		if ok {
+			run = append(run, word)
+			continue
+		}
+
+		flushRun()
+		result = append(result, word)
+	}
+	flushRun()
+
+	// Keep the original formatting if there was nothing to redact.
+	if len(result) == len(fields) {
+		return words
+	}
+
+	return strings.Join(result, " ")
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btclog"
+	"github.com/guggero/chantools/lnd"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLogger returns a logger for the given subsystem that writes to the
+// returned buffer with the given format.
+func newTestLogger(t *testing.T, subsystem, format string) (*logger,
+	*bytes.Buffer) {
+
+	buf := &bytes.Buffer{}
+	prevOutput, prevFormat := logOutput, LogFormat
+	logOutput, LogFormat = buf, format
+	t.Cleanup(func() {
+		logOutput, LogFormat = prevOutput, prevFormat
+	})
+
+	return newLogger(subsystem), buf
+}
+
+func TestLoggerText(t *testing.T) {
+	l, buf := newTestLogger(t, "CHAN", logFormatText)
+
+	// Messages below the level are dropped.
+	l.Debugf("not %s", "shown")
+	require.Empty(t, buf.String())
+
+	l.Infof("sweeping %d outputs", 3)
+	l.Warn("fee rate ", 10, " is low")
+	require.Regexp(t, `^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d{3} `+
+		`\[INF\] CHAN: sweeping 3 outputs\n`, buf.String())
+	require.Contains(t, buf.String(), "[WRN] CHAN: fee rate 10 is low\n")
+
+	buf.Reset()
+	l.SetLevel(btclog.LevelTrace)
+	l.Tracef("details")
+	require.Contains(t, buf.String(), "[TRC] CHAN: details\n")
+}
+
+func TestLoggerJSON(t *testing.T) {
+	l, buf := newTestLogger(t, "CHDB", logFormatJSON)
+
+	l.Errorf("could not open %s", "channel.db")
+	l.Info("second message")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var msg jsonLogMessage
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &msg))
+	require.Equal(t, "error", msg.Level)
+	require.Equal(t, "CHDB", msg.Subsystem)
+	require.Equal(t, "could not open channel.db", msg.Message)
+	require.NotEmpty(t, msg.Time)
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &msg))
+	require.Equal(t, "info", msg.Level)
+}
+
+func TestRedactSecrets(t *testing.T) {
+	_ = newHarness(t)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	pubKey, err := extendedKey.Neuter()
+	require.NoError(t, err)
+	_, _, wif, err := lnd.DeriveKey(
+		extendedKey, "m/84'/1'/0'/0/0", chainParams,
+	)
+	require.NoError(t, err)
+	words := strings.Fields(seedBip39)
+	for idx, word := range words {
+		words[idx] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	capitalized := strings.Join(words, " ")
+
+	testCases := []struct {
+		name     string
+		msg      string
+		redacted string
+	}{{
+		name:     "extended private key",
+		msg:      "root key: " + rootKeyAezeed,
+		redacted: "root key: " + redactedValue,
+	}, {
+		name: "extended public key",
+		msg:  "account key: " + pubKey.String(),
+	}, {
+		name:     "WIF",
+		msg:      "p2wpkh:" + wif.String() + ".",
+		redacted: "p2wpkh:" + redactedValue + ".",
+	}, {
+		name: "TXID",
+		msg: "TX 3b8dd19ebaaa7fb28b3d22ea0488736d4a880c972c26232d1b" +
+			"6fcbb2cfc28cb9",
+	}, {
+		name:     "mnemonic",
+		msg:      "mnemonic " + seedBip39 + " loaded",
+		redacted: "mnemonic " + redactedValue + " loaded",
+	}, {
+		name:     "capitalized mnemonic",
+		msg:      "mnemonic " + capitalized + " loaded",
+		redacted: "mnemonic " + redactedValue + " loaded",
+	}, {
+		name:     "upper case mnemonic",
+		msg:      strings.ToUpper(seedBip39),
+		redacted: redactedValue,
+	}, {
+		name:     "aezeed mnemonic",
+		msg:      seedAezeedNoPassphrase,
+		redacted: redactedValue,
+	}, {
+		name: "short run of words",
+		msg: "we found the address with funds but the fee is too " +
+			"high to sweep it now",
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			expected := tc.redacted
+			if expected == "" {
+				expected = tc.msg
+			}
+			require.Equal(t, expected, redactSecrets(tc.msg))
+		})
+	}
+
+	// Registered secrets are redacted from all messages, in any format.
+	prevSecrets := logSecrets
+	defer func() {
+		logSecrets = prevSecrets
+	}()
+	redactLogSecret(testPassPhrase)
+
+	l, buf := newTestLogger(t, "CHAN", logFormatJSON)
+	l.Infof("passphrase is %s", testPassPhrase)
+	require.NotContains(t, buf.String(), testPassPhrase)
+	require.Contains(t, buf.String(), "passphrase is "+redactedValue)
+}
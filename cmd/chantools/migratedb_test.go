@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/kvdb"
+	"github.com/lightningnetwork/lnd/lncfg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyDB(t *testing.T) {
+	h := newHarness(t)
+
+	src, err := lnd.OpenBackend(h.testdataFile("channel.db"), true)
+	require.NoError(t, err)
+	defer func() { _ = src.Close() }()
+
+	dst, err := kvdb.GetBoltBackend(&kvdb.BoltBackendConfig{
+		DBPath:     h.tempDir,
+		DBFileName: "copied.db",
+		DBTimeout:  lnd.DefaultOpenTimeout,
+	})
+	require.NoError(t, err)
+	defer func() { _ = dst.Close() }()
+
+	err = copyDB(src, dst)
+	require.NoError(t, err)
+	h.assertLogContains("Successfully copied")
+
+	srcBuckets, srcKeys, err := lnd.CountDB(src)
+	require.NoError(t, err)
+	require.NotZero(t, srcBuckets)
+	require.NotZero(t, srcKeys)
+
+	// Copying again must fail because the top level buckets already exist
+	// in the destination DB.
+	err = copyDB(src, dst)
+	require.Error(t, err)
+}
+
+func TestMigrateDBSqlite(t *testing.T) {
+	h := newHarness(t)
+
+	migrate := &migrateDBCommand{
+		ChannelDB:      h.testdataFile("channel.db"),
+		DestSqliteFile: h.tempFile(lncfg.SqliteChannelDBName),
+	}
+
+	err := migrate.Execute(nil, nil)
+	if !kvdb.SqliteBackend {
+		require.ErrorContains(t, err, "kvdb_sqlite build tag")
+		return
+	}
+	require.NoError(t, err)
+	h.assertLogContains("Successfully copied")
+	require.FileExists(t, migrate.DestSqliteFile)
+
+	// Only one destination can be given.
+	migrate.DestPostgresDSN = "postgres://localhost/lnd"
+	err = migrate.Execute(nil, nil)
+	require.ErrorContains(t, err, "only one of --dest_postgres_dsn and "+
+		"--dest_sqlite_file")
+}
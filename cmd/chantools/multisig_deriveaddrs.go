@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultMultisigNumAddrs = 20
+)
+
+type multisigDeriveAddrsCommand struct {
+	Branch   uint32
+	Start    uint32
+	NumAddrs uint32
+
+	multisigFlags
+
+	cmd *cobra.Command
+}
+
+func newMultisigDeriveAddrsCommand() *cobra.Command {
+	cc := &multisigDeriveAddrsCommand{}
+	cc.cmd = &cobra.Command{
+		Use:   "deriveaddrs",
+		Short: "Derive the addresses of a multisig wallet",
+		Long: `Derives a range of P2WSH addresses of an N-of-M
+multisig wallet and prints them together with their witness script. The output
+descriptor of the wallet is printed as well, it can be imported into a watch
+only wallet of Bitcoin Core to scan for funds.`,
+		Example: `chantools multisig deriveaddrs \
+	--xpub [d34db33f/48h/0h/0h/2h]xpub... \
+	--xpub [b00b1e55/48h/0h/0h/2h]xpub... \
+	--xpub [c0ffee00/48h/0h/0h/2h]xpub... \
+	--threshold 2 --branch 0 --numaddrs 20`,
+		RunE: cc.Execute,
+	}
+	addMultisigFlags(cc.cmd, &cc.multisigFlags)
+	cc.cmd.Flags().Uint32Var(
+		&cc.Branch, "branch", 0, "the branch to derive the addresses "+
+			"from, usually 0 for receiving and 1 for change "+
+			"addresses",
+	)
+	cc.cmd.Flags().Uint32Var(
+		&cc.Start, "start", 0, "the index of the first address to "+
+			"derive",
+	)
+	cc.cmd.Flags().Uint32Var(
+		&cc.NumAddrs, "numaddrs", defaultMultisigNumAddrs, "the "+
+			"number of addresses to derive",
+	)
+
+	return cc.cmd
+}
+
+func (c *multisigDeriveAddrsCommand) Execute(_ *cobra.Command,
+	_ []string) error {
+
+	multisig, err := c.multisig()
+	if err != nil {
+		return err
+	}
+
+	descriptor, err := multisig.Descriptor(c.Branch)
+	if err != nil {
+		return fmt.Errorf("error creating descriptor: %w", err)
+	}
+
+	result := fmt.Sprintf("%d-of-%d multisig wallet, descriptor: %s\n",
+		multisig.Threshold, len(multisig.Keys), descriptor)
+	for index := c.Start; index < c.Start+c.NumAddrs; index++ {
+		addr, err := multisig.Derive(c.Branch, index)
+		if err != nil {
+			return fmt.Errorf("error deriving address %d: %w",
+				index, err)
+		}
+
+		result += fmt.Sprintf("%d/%d: %s, witness script: %s\n",
+			c.Branch, index, addr.Address.String(),
+			hex.EncodeToString(addr.WitnessScript))
+	}
+
+	fmt.Print(result)
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(result)
+
+	return nil
+}
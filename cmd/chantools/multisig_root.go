@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/guggero/chantools/btc"
+	"github.com/spf13/cobra"
+)
+
+type multisigCommand struct {
+	cmd *cobra.Command
+}
+
+func newMultisigCommand() *cobra.Command {
+	cc := &multisigCommand{}
+	cc.cmd = &cobra.Command{
+		Use:   "multisig",
+		Short: "Derive and sweep N-of-M P2WSH multisig addresses",
+		Long: `A sub command that hosts a set of further sub commands
+to derive the addresses of an N-of-M P2WSH multisig wallet from the extended
+keys of all participants and to sweep funds from such an address.
+
+The extended keys are given with the repeated --xpub flag. They can optionally
+be prefixed with their key origin in the output descriptor notation, for
+example [d34db33f/48h/0h/0h/2h]xpub..., which is needed for co-signers to
+recognize their keys in a PSBT. The keys of each address are derived from the
+extended keys with the non-hardened path <branch>/<index> and are sorted as
+defined in BIP-0067 (sortedmulti) unless --unsorted is set.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				_ = cmd.Help()
+				os.Exit(0)
+			}
+		},
+	}
+
+	cc.cmd.AddCommand(
+		newMultisigDeriveAddrsCommand(),
+		newMultisigSweepCommand(),
+	)
+
+	return cc.cmd
+}
+
+// multisigFlags are the flags that describe a multisig wallet.
+type multisigFlags struct {
+	Keys      []string
+	Threshold int
+	Unsorted  bool
+}
+
+// addMultisigFlags adds the flags that describe a multisig wallet to the given
+// command.
+func addMultisigFlags(cmd *cobra.Command, f *multisigFlags) {
+	cmd.Flags().StringArrayVar(
+		&f.Keys, "xpub", nil, "extended public key of one of the "+
+			"participants, optionally prefixed with its key "+
+			"origin; an extended private key can be used instead "+
+			"to sign with it; must be repeated for every "+
+			"participant",
+	)
+	cmd.Flags().IntVar(
+		&f.Threshold, "threshold", 0, "number of signatures that "+
+			"are required to spend from the multisig wallet",
+	)
+	cmd.Flags().BoolVar(
+		&f.Unsorted, "unsorted", false, "don't sort the public keys "+
+			"in the script (multi instead of sortedmulti), they "+
+			"appear in the order of the --xpub flags",
+	)
+}
+
+// multisig parses the flags into a multisig wallet.
+func (f *multisigFlags) multisig() (*btc.Multisig, error) {
+	if len(f.Keys) == 0 {
+		return nil, fmt.Errorf("at least one --xpub is required")
+	}
+
+	keys := make([]*btc.MultisigKey, len(f.Keys))
+	for idx, keyStr := range f.Keys {
+		key, err := btc.ParseMultisigKey(keyStr, chainParams)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing key %d: %w", idx,
+				err)
+		}
+		keys[idx] = key
+	}
+
+	multisig := &btc.Multisig{
+		Keys:      keys,
+		Threshold: f.Threshold,
+		Sorted:    !f.Unsorted,
+		Params:    chainParams,
+	}
+	if err := multisig.Validate(); err != nil {
+		return nil, err
+	}
+
+	return multisig, nil
+}
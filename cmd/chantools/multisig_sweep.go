@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultMultisigLookahead = 1000
+)
+
+type multisigSweepCommand struct {
+	APIURL         string
+	Outpoint       string
+	Lookahead      uint32
+	SweepAddr      string
+	DestDescriptor string
+	DestIndex      uint32
+	DestGapLimit   uint32
+	FeeRate        uint16
+	ConfTarget     uint32
+	Publish        bool
+	DryRun         bool
+	Psbt           bool
+
+	multisigFlags
+
+	cmd *cobra.Command
+}
+
+func newMultisigSweepCommand() *cobra.Command {
+	cc := &multisigSweepCommand{}
+	cc.cmd = &cobra.Command{
+		Use:   "sweep",
+		Short: "Sweep a UTXO of a multisig wallet",
+		Long: `Sweeps a single UTXO that was sent to an address of an
+N-of-M P2WSH multisig wallet. The first --lookahead addresses of the receiving
+and change branch are derived to find the address of the UTXO.
+
+Every key that is given as an extended private key with --xpub signs the sweep
+transaction. If there are enough signatures, the final transaction is printed
+or published. Otherwise a PSBT that contains the signatures and the BIP32
+derivation info of all keys is created, which can be passed to the co-signers
+to add their signatures. The same happens if --psbt is set.`,
+		Example: `chantools multisig sweep \
+	--xpub [d34db33f/48h/0h/0h/2h]xprv... \
+	--xpub [b00b1e55/48h/0h/0h/2h]xpub... \
+	--xpub [c0ffee00/48h/0h/0h/2h]xpub... \
+	--threshold 2 \
+	--outpoint xxxxxxxxx:y \
+	--sweepaddr bc1q..... \
+	--feerate 10`,
+		RunE: cc.Execute,
+	}
+	addMultisigFlags(cc.cmd, &cc.multisigFlags)
+	cc.cmd.Flags().StringVar(
+		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
+			"be esplora compatible)",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.Outpoint, "outpoint", "", "the outpoint of the UTXO to "+
+			"sweep (<txid>:<txindex>)",
+	)
+	cc.cmd.Flags().Uint32Var(
+		&cc.Lookahead, "lookahead", defaultMultisigLookahead, "the "+
+			"number of addresses per branch to derive when "+
+			"looking for the address of the UTXO",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.SweepAddr, "sweepaddr", "", "address to sweep the funds to",
+	)
+	addDestDescriptorFlags(
+		cc.cmd, &cc.DestDescriptor, &cc.DestIndex, &cc.DestGapLimit,
+	)
+	cc.cmd.Flags().Uint16Var(
+		&cc.FeeRate, "feerate", defaultFeeSatPerVByte, "fee rate to "+
+			"use for the sweep transaction in sat/vByte",
+	)
+	addConfTargetFlag(cc.cmd, &cc.ConfTarget)
+	cc.cmd.Flags().BoolVar(
+		&cc.Publish, "publish", false, "publish sweep TX to the chain "+
+			"API instead of just printing the TX",
+	)
+	addDryRunFlag(cc.cmd, &cc.DryRun)
+	addPsbtFlag(cc.cmd, &cc.Psbt)
+
+	return cc.cmd
+}
+
+func (c *multisigSweepCommand) Execute(_ *cobra.Command, _ []string) error {
+	multisig, err := c.multisig()
+	if err != nil {
+		return err
+	}
+
+	// The sweep address can also be derived from a descriptor.
+	c.SweepAddr, err = sweepDestination(
+		c.SweepAddr, c.DestDescriptor, c.DestIndex, c.DestGapLimit,
+		c.APIURL,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Make sure all required flags are set.
+	switch {
+	case c.Outpoint == "":
+		return fmt.Errorf("outpoint is required")
+
+	case c.SweepAddr == "":
+		return fmt.Errorf("sweep addr is required")
+	}
+	if c.Psbt && c.Publish {
+		return fmt.Errorf("cannot publish a PSBT, it must be signed " +
+			"first")
+	}
+
+	outpoint, err := lnd.ParseOutpoint(c.Outpoint)
+	if err != nil {
+		return fmt.Errorf("error parsing outpoint: %w", err)
+	}
+
+	// Set default values.
+	if c.Lookahead == 0 {
+		c.Lookahead = defaultMultisigLookahead
+	}
+	if c.FeeRate == 0 {
+		c.FeeRate = defaultFeeSatPerVByte
+	}
+	c.FeeRate = sweepFeeRate(c.APIURL, c.ConfTarget, c.FeeRate)
+
+	api, err := newChainBackend(c.APIURL)
+	if err != nil {
+		return err
+	}
+	publish := shouldPublish(c.Publish, c.DryRun, c.APIURL)
+	return sweepMultisig(
+		api, multisig, outpoint, c.Lookahead, c.SweepAddr, c.FeeRate,
+		publish, c.Psbt,
+	)
+}
+
+func sweepMultisig(api btc.ChainBackend, multisig *btc.Multisig,
+	outpoint *wire.OutPoint, lookahead uint32, sweepAddr string,
+	feeRate uint16, publish, createPsbt bool) error {
+
+	tx, err := api.Transaction(outpoint.Hash.String())
+	if err != nil {
+		return fmt.Errorf("error looking up TX %s: %w",
+			outpoint.Hash.String(), err)
+	}
+	if int(outpoint.Index) >= len(tx.Vout) {
+		return fmt.Errorf("TX %s has no output with index %d",
+			outpoint.Hash.String(), outpoint.Index)
+	}
+
+	txOut := tx.Vout[outpoint.Index]
+	if txOut.Outspend != nil && txOut.Outspend.Spent {
+		return fmt.Errorf("UTXO %v is already spent", outpoint)
+	}
+
+	pkScript, err := hex.DecodeString(txOut.ScriptPubkey)
+	if err != nil {
+		return fmt.Errorf("error decoding pk script %s: %w",
+			txOut.ScriptPubkey, err)
+	}
+
+	addr, err := findMultisigAddress(multisig, pkScript, lookahead)
+	if err != nil {
+		return err
+	}
+	log.Infof("Found multisig address %s with path %d/%d",
+		addr.Address.String(), addr.Branch, addr.Index)
+
+	sweepScript, err := lnd.GetP2WPKHScript(sweepAddr, chainParams)
+	if err != nil {
+		return err
+	}
+
+	// Calculate the fee based on the given fee rate and our weight
+	// estimation.
+	sweepValue := int64(txOut.Value)
+	var estimator input.TxWeightEstimator
+	estimator.AddWitnessInput(btc.MultisigWitnessSize(
+		addr.WitnessScript, multisig.Threshold,
+	))
+	estimator.AddP2WKHOutput()
+	feeRateKWeight := chainfee.SatPerKVByte(1000 * feeRate).FeePerKWeight()
+	totalFee := feeRateKWeight.FeeForWeight(int64(estimator.Weight()))
+	if sweepValue-int64(totalFee) < sweepDustLimit {
+		return fmt.Errorf("fee of %d sats would leave an output "+
+			"below the dust limit of %d", totalFee, sweepDustLimit)
+	}
+
+	log.Infof("Fee %d sats of %d total amount (estimated weight %d)",
+		totalFee, sweepValue, estimator.Weight())
+
+	sweepTx := wire.NewMsgTx(2)
+	sweepTx.TxIn = []*wire.TxIn{wire.NewTxIn(outpoint, nil, nil)}
+	sweepTx.TxOut = []*wire.TxOut{{
+		Value:    sweepValue - int64(totalFee),
+		PkScript: sweepScript,
+	}}
+
+	signDesc := &input.SignDescriptor{
+		WitnessScript: addr.WitnessScript,
+		Output: &wire.TxOut{
+			Value:    sweepValue,
+			PkScript: pkScript,
+		},
+		HashType: txscript.SigHashAll,
+	}
+	err = validateSweepTx(
+		api, sweepTx, []*input.SignDescriptor{signDesc},
+		int64(estimator.VSize()), feeRate,
+	)
+	if err != nil {
+		return fmt.Errorf("error validating sweep TX: %w", err)
+	}
+
+	packet, err := psbt.NewFromUnsignedTx(sweepTx)
+	if err != nil {
+		return fmt.Errorf("error creating PSBT: %w", err)
+	}
+	pIn := &packet.Inputs[0]
+	pIn.WitnessUtxo = signDesc.Output
+	pIn.WitnessScript = signDesc.WitnessScript
+	pIn.SighashType = signDesc.HashType
+	for _, key := range addr.Keys {
+		pIn.Bip32Derivation = append(
+			pIn.Bip32Derivation, key.Derivation,
+		)
+	}
+
+	// Sign with all private keys we have, but not with more than needed.
+	// The PSBT finalizer expects exactly the number of signatures the
+	// script requires.
+	prevOuts := txscript.NewCannedPrevOutputFetcher(pkScript, sweepValue)
+	sigHashes := txscript.NewTxSigHashes(sweepTx, prevOuts)
+	for _, key := range addr.Keys {
+		if !key.Key.IsPrivate() ||
+			len(pIn.PartialSigs) == multisig.Threshold {
+
+			continue
+		}
+
+		privKey, err := key.Key.ECPrivKey()
+		if err != nil {
+			return fmt.Errorf("error deriving private key: %w", err)
+		}
+		sig, err := txscript.RawTxInWitnessSignature(
+			sweepTx, sigHashes, 0, sweepValue, addr.WitnessScript,
+			txscript.SigHashAll, privKey,
+		)
+		if err != nil {
+			return fmt.Errorf("error signing: %w", err)
+		}
+
+		pIn.PartialSigs = append(pIn.PartialSigs, &psbt.PartialSig{
+			PubKey:    key.Derivation.PubKey,
+			Signature: sig,
+		})
+	}
+
+	numSigs := len(pIn.PartialSigs)
+	if createPsbt || numSigs < multisig.Threshold {
+		log.Infof("Signed with %d of %d required keys, created PSBT "+
+			"for the co-signers", numSigs, multisig.Threshold)
+		return logPsbt(packet)
+	}
+
+	if err := psbt.MaybeFinalizeAll(packet); err != nil {
+		return fmt.Errorf("error finalizing PSBT: %w", err)
+	}
+	finalTx, err := psbt.Extract(packet)
+	if err != nil {
+		return fmt.Errorf("error extracting final TX: %w", err)
+	}
+
+	return publishTx(api, finalTx, int64(totalFee), publish)
+}
+
+// findMultisigAddress derives the first lookahead addresses of the receiving
+// and change branch of the multisig wallet and returns the one that has the
+// given pkScript.
+func findMultisigAddress(multisig *btc.Multisig, pkScript []byte,
+	lookahead uint32) (*btc.MultisigAddress, error) {
+
+	for index := uint32(0); index < lookahead; index++ {
+		for branch := uint32(0); branch <= 1; branch++ {
+			addr, err := multisig.Derive(branch, index)
+			if err != nil {
+				return nil, fmt.Errorf("error deriving "+
+					"address %d/%d: %w", branch, index, err)
+			}
+
+			if bytes.Equal(addr.PkScript, pkScript) {
+				return addr, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("none of the first %d addresses of the "+
+		"multisig wallet matches the output script %x, check the keys "+
+		"and the threshold", lookahead, pkScript)
+}
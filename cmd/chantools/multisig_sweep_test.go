@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/stretchr/testify/require"
+)
+
+// multisigTestKey returns the extended private and public account key of a
+// test participant, prefixed with the key origin.
+func multisigTestKey(t *testing.T, seed byte) (string, string) {
+	master, err := hdkeychain.NewMaster(
+		bytes.Repeat([]byte{seed}, 32), chainParams,
+	)
+	require.NoError(t, err)
+	masterPubKey, err := master.ECPubKey()
+	require.NoError(t, err)
+	fingerprint := btcutil.Hash160(masterPubKey.SerializeCompressed())[:4]
+
+	account, err := lnd.DeriveChildren(master, []uint32{
+		lnd.HardenedKey(48), lnd.HardenedKey(1), lnd.HardenedKey(0),
+		lnd.HardenedKey(2),
+	})
+	require.NoError(t, err)
+	accountPub, err := account.Neuter()
+	require.NoError(t, err)
+
+	origin := fmt.Sprintf("[%x/48h/1h/0h/2h]", fingerprint)
+	return origin + account.String(), origin + accountPub.String()
+}
+
+func TestMultisigSweep(t *testing.T) {
+	h := newHarness(t)
+
+	xprv1, xpub1 := multisigTestKey(t, 1)
+	xprv2, xpub2 := multisigTestKey(t, 2)
+	_, xpub3 := multisigTestKey(t, 3)
+	newMultisig := func(keys ...string) *btc.Multisig {
+		flags := &multisigFlags{Keys: keys, Threshold: 2}
+		multisig, err := flags.multisig()
+		require.NoError(t, err)
+		return multisig
+	}
+
+	// The derived addresses are the same, no matter if public or private
+	// keys are used.
+	derive := &multisigDeriveAddrsCommand{
+		NumAddrs: 5,
+		multisigFlags: multisigFlags{
+			Keys:      []string{xpub1, xpub2, xpub3},
+			Threshold: 2,
+		},
+	}
+	require.NoError(t, derive.Execute(nil, nil))
+	h.assertLogContains("2-of-3 multisig wallet, descriptor: " +
+		"wsh(sortedmulti(2,")
+
+	addr, err := newMultisig(xprv1, xprv2, xpub3).Derive(1, 3)
+	require.NoError(t, err)
+
+	fundingTx := wire.NewMsgTx(2)
+	fundingTx.TxIn = []*wire.TxIn{{}}
+	fundingTx.TxOut = []*wire.TxOut{{
+		Value:    200_000,
+		PkScript: addr.PkScript,
+	}}
+	api := newFakeChainBackend()
+	api.addTx(t, fundingTx, &btc.Status{})
+
+	sweepAddr, err := lnd.P2WKHAddr(addr.Keys[0].PubKey, chainParams)
+	require.NoError(t, err)
+	sweep := func(multisig *btc.Multisig) string {
+		h.clearLog()
+		err := sweepMultisig(
+			api, multisig, &wire.OutPoint{Hash: fundingTx.TxHash()},
+			10, sweepAddr.String(), 10, false, false,
+		)
+		require.NoError(t, err)
+		h.assertLogContains("Found multisig address " +
+			addr.Address.String() + " with path 1/3")
+
+		logLines := strings.Split(strings.TrimSpace(h.getLog()), "\n")
+		lastLine := logLines[len(logLines)-1]
+		return lastLine[strings.LastIndex(lastLine, " ")+1:]
+	}
+
+	// With only one private key, a PSBT for the co-signers is created.
+	packet, err := psbt.NewFromRawBytes(
+		strings.NewReader(sweep(newMultisig(xprv1, xpub2, xpub3))),
+		true,
+	)
+	require.NoError(t, err)
+	h.assertLogContains("Signed with 1 of 2 required keys")
+	require.Len(t, packet.Inputs[0].PartialSigs, 1)
+	require.Len(t, packet.Inputs[0].Bip32Derivation, 3)
+	require.Equal(t, addr.WitnessScript, packet.Inputs[0].WitnessScript)
+
+	// With two private keys, the TX is fully signed.
+	sweepTx, err := parseSweepTx(api, sweep(newMultisig(xprv1, xprv2, xpub3)))
+	require.NoError(t, err)
+
+	prevOuts := txscript.NewCannedPrevOutputFetcher(addr.PkScript, 200_000)
+	vm, err := txscript.NewEngine(
+		addr.PkScript, sweepTx, 0, txscript.StandardVerifyFlags, nil,
+		txscript.NewTxSigHashes(sweepTx, prevOuts), 200_000, prevOuts,
+	)
+	require.NoError(t, err)
+	require.NoError(t, vm.Execute())
+
+	// A wallet with different keys doesn't find the address.
+	err = sweepMultisig(
+		api, newMultisig(xprv1, xpub2), &wire.OutPoint{
+			Hash: fundingTx.TxHash(),
+		}, 10, sweepAddr.String(), 10, false, false,
+	)
+	require.ErrorContains(t, err, "none of the first 10 addresses")
+}
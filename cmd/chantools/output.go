@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	outputFormatHex  = "hex"
+	outputFormatPsbt = "psbt"
+	outputFormatJSON = "json"
+)
+
+// outputTx is the JSON format of a signed transaction written to the output
+// file.
+type outputTx struct {
+	TXID    string  `json:"txid"`
+	Hex     string  `json:"hex"`
+	VSize   int64   `json:"vsize"`
+	Fee     int64   `json:"fee"`
+	FeeRate float64 `json:"fee_rate"`
+}
+
+// outputPsbt is the JSON format of a PSBT written to the output file.
+type outputPsbt struct {
+	TXID string `json:"txid"`
+	Psbt string `json:"psbt"`
+}
+
+// numOutputFiles is the number of output files that were written in this run.
+// If a command creates more than one transaction, all but the first file name
+// get a numeric suffix.
+var numOutputFiles int
+
+// writeTxOutput writes the given signed transaction to the file set with
+// --output-file, if any, and returns true if it was written. A signed TX can be
+// written as raw hex (the default), as finalized PSBT or as JSON.
+func writeTxOutput(tx *wire.MsgTx, fee int64) (bool, error) {
+	if OutputFile == "" {
+		return false, nil
+	}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return false, fmt.Errorf("error serializing TX: %w", err)
+	}
+
+	var content []byte
+	switch OutputFormat {
+	case outputFormatHex, "":
+		content = []byte(hex.EncodeToString(buf.Bytes()))
+
+	case outputFormatPsbt:
+		packet, err := finalizedPsbt(tx)
+		if err != nil {
+			return false, err
+		}
+		packetB64, err := packet.B64Encode()
+		if err != nil {
+			return false, fmt.Errorf("error encoding PSBT: %w", err)
+		}
+		content = []byte(packetB64)
+
+	case outputFormatJSON:
+		vSize := txVSize(tx)
+		var err error
+		content, err = json.MarshalIndent(&outputTx{
+			TXID:    tx.TxHash().String(),
+			Hex:     hex.EncodeToString(buf.Bytes()),
+			VSize:   vSize,
+			Fee:     fee,
+			FeeRate: float64(fee) / float64(vSize),
+		}, "", "  ")
+		if err != nil {
+			return false, err
+		}
+
+	default:
+		return false, errUnknownOutputFormat()
+	}
+
+	fileName, err := writeOutputFile(content)
+	if err != nil {
+		return false, err
+	}
+
+	log.Infof("Wrote TX %v to %s", tx.TxHash(), fileName)
+	return true, nil
+}
+
+// writeFinalTxOutput writes the final transaction that was extracted from the
+// given PSBT to the file set with --output-file, if any, and returns true if it
+// was written. The fee is calculated from the witness UTXOs of the PSBT.
+func writeFinalTxOutput(packet *psbt.Packet, finalTx *wire.MsgTx) (bool,
+	error) {
+
+	if OutputFile == "" {
+		return false, nil
+	}
+
+	var fee int64
+	for idx, pIn := range packet.Inputs {
+		if pIn.WitnessUtxo == nil {
+			return false, fmt.Errorf("input %d has no witness UTXO",
+				idx)
+		}
+		fee += pIn.WitnessUtxo.Value
+	}
+	for _, txOut := range finalTx.TxOut {
+		fee -= txOut.Value
+	}
+
+	return writeTxOutput(finalTx, fee)
+}
+
+// writePsbtOutput writes the given PSBT to the file set with --output-file, if
+// any, and returns true if it was written. A PSBT can be written as base64
+// (the default), as hex of the binary PSBT or as JSON.
+func writePsbtOutput(packet *psbt.Packet) (bool, error) {
+	if OutputFile == "" {
+		return false, nil
+	}
+
+	var buf bytes.Buffer
+	if err := packet.Serialize(&buf); err != nil {
+		return false, fmt.Errorf("error serializing PSBT: %w", err)
+	}
+	packetB64, err := packet.B64Encode()
+	if err != nil {
+		return false, fmt.Errorf("error encoding PSBT: %w", err)
+	}
+
+	var content []byte
+	switch OutputFormat {
+	case outputFormatPsbt, "":
+		content = []byte(packetB64)
+
+	case outputFormatHex:
+		content = []byte(hex.EncodeToString(buf.Bytes()))
+
+	case outputFormatJSON:
+		content, err = json.MarshalIndent(&outputPsbt{
+			TXID: packet.UnsignedTx.TxHash().String(),
+			Psbt: packetB64,
+		}, "", "  ")
+		if err != nil {
+			return false, err
+		}
+
+	default:
+		return false, errUnknownOutputFormat()
+	}
+
+	fileName, err := writeOutputFile(content)
+	if err != nil {
+		return false, err
+	}
+
+	log.Infof("Wrote PSBT to %s", fileName)
+	return true, nil
+}
+
+// checkTxOutput makes sure the next signed transaction can be written to the
+// file set with --output-file, if any. This must be called before a transaction
+// is published, so it isn't only published but also lost because it can't be
+// written.
+func checkTxOutput() error {
+	if OutputFile == "" {
+		return nil
+	}
+
+	switch OutputFormat {
+	case outputFormatHex, outputFormatPsbt, outputFormatJSON, "":
+	default:
+		return errUnknownOutputFormat()
+	}
+
+	fileName, err := outputFileName()
+	if err != nil {
+		return err
+	}
+
+	// The only way to really know whether the directory is writable is to
+	// create a file in it.
+	tempFile, err := ioutil.TempFile(
+		filepath.Dir(fileName), filepath.Base(fileName)+".tmp",
+	)
+	if err != nil {
+		return fmt.Errorf("output file %s can't be written: %w",
+			fileName, err)
+	}
+	_ = tempFile.Close()
+
+	return os.Remove(tempFile.Name())
+}
+
+// outputFileName returns the name of the next output file. An error is
+// returned if the file already exists and --force isn't set.
+func outputFileName() (string, error) {
+	fileName := OutputFile
+	if numOutputFiles > 0 {
+		ext := filepath.Ext(fileName)
+		fileName = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(
+			fileName, ext,
+		), numOutputFiles+1, ext)
+	}
+
+	if _, err := os.Stat(fileName); err == nil && !Force {
+		return "", fmt.Errorf("output file %s already exists, use "+
+			"--force to overwrite it", fileName)
+	}
+
+	return fileName, nil
+}
+
+// writeOutputFile atomically writes the content to the output file by first
+// writing it to a temporary file in the same directory and then moving that
+// into place. An existing file is only overwritten if --force is set. The name
+// of the written file is returned.
+func writeOutputFile(content []byte) (string, error) {
+	fileName, err := outputFileName()
+	if err != nil {
+		return "", err
+	}
+
+	tempFile, err := ioutil.TempFile(
+		filepath.Dir(fileName), filepath.Base(fileName)+".tmp",
+	)
+	if err != nil {
+		return "", fmt.Errorf("error creating output file: %w", err)
+	}
+	tempName := tempFile.Name()
+	defer func() {
+		_ = os.Remove(tempName)
+	}()
+
+	_, err = tempFile.Write(append(content, '\n'))
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("error writing output file: %w", err)
+	}
+
+	// A hard link fails if the target exists, so we can't accidentally
+	// overwrite a file that was created in the meantime.
+	if Force {
+		err = os.Rename(tempName, fileName)
+	} else {
+		err = os.Link(tempName, fileName)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error writing output file: %w", err)
+	}
+
+	numOutputFiles++
+	return fileName, nil
+}
+
+// finalizedPsbt converts the given signed transaction into a finalized PSBT.
+func finalizedPsbt(tx *wire.MsgTx) (*psbt.Packet, error) {
+	unsignedTx := tx.Copy()
+	for _, txIn := range unsignedTx.TxIn {
+		txIn.SignatureScript = nil
+		txIn.Witness = nil
+	}
+
+	packet, err := psbt.NewFromUnsignedTx(unsignedTx)
+	if err != nil {
+		return nil, fmt.Errorf("error creating PSBT: %w", err)
+	}
+
+	for idx, txIn := range tx.TxIn {
+		packet.Inputs[idx].FinalScriptSig = txIn.SignatureScript
+
+		if len(txIn.Witness) == 0 {
+			continue
+		}
+		var buf bytes.Buffer
+		err := wire.WriteVarInt(&buf, 0, uint64(len(txIn.Witness)))
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range txIn.Witness {
+			err := wire.WriteVarBytes(&buf, 0, item)
+			if err != nil {
+				return nil, err
+			}
+		}
+		packet.Inputs[idx].FinalScriptWitness = buf.Bytes()
+	}
+
+	return packet, nil
+}
+
+func errUnknownOutputFormat() error {
+	return fmt.Errorf("unknown output format %s, must be one of %s, %s "+
+		"or %s", OutputFormat, outputFormatHex, outputFormatPsbt,
+		outputFormatJSON)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// setOutputFlags sets the global output file flags for the duration of the
+// test.
+func setOutputFlags(t *testing.T, file, format string, force bool) {
+	OutputFile, OutputFormat, Force = file, format, force
+	numOutputFiles = 0
+
+	t.Cleanup(func() {
+		OutputFile, OutputFormat, Force = "", "", false
+		numOutputFiles = 0
+	})
+}
+
+func newOutputTestTx() *wire.MsgTx {
+	tx := wire.NewMsgTx(2)
+	tx.TxIn = []*wire.TxIn{{
+		PreviousOutPoint: wire.OutPoint{Index: 1},
+		Witness: wire.TxWitness{
+			bytes.Repeat([]byte{1}, 72),
+			bytes.Repeat([]byte{2}, 33),
+		},
+	}}
+	tx.TxOut = []*wire.TxOut{{
+		Value:    10_000,
+		PkScript: bytes.Repeat([]byte{3}, 22),
+	}}
+
+	return tx
+}
+
+func readOutputFile(t *testing.T, fileName string) string {
+	content, err := ioutil.ReadFile(fileName)
+	require.NoError(t, err)
+
+	return strings.TrimSpace(string(content))
+}
+
+func TestWriteTxOutput(t *testing.T) {
+	h := newHarness(t)
+
+	tx := newOutputTestTx()
+	var buf bytes.Buffer
+	require.NoError(t, tx.Serialize(&buf))
+	txHex := hex.EncodeToString(buf.Bytes())
+
+	// Without an output file nothing is written.
+	setOutputFlags(t, "", "", false)
+	written, err := writeTxOutput(tx, 1_000)
+	require.NoError(t, err)
+	require.False(t, written)
+
+	// The default format for a TX is hex.
+	fileName := h.tempFile("tx.txt")
+	setOutputFlags(t, fileName, "", false)
+	written, err = writeTxOutput(tx, 1_000)
+	require.NoError(t, err)
+	require.True(t, written)
+	require.Equal(t, txHex, readOutputFile(t, fileName))
+	h.assertLogContains("Wrote TX " + tx.TxHash().String())
+
+	// A second TX of the same run gets a suffix.
+	written, err = writeTxOutput(tx, 1_000)
+	require.NoError(t, err)
+	require.True(t, written)
+	require.Equal(t, txHex, readOutputFile(t, h.tempFile("tx-2.txt")))
+
+	// An existing file is only overwritten with --force.
+	setOutputFlags(t, fileName, outputFormatJSON, false)
+	_, err = writeTxOutput(tx, 1_000)
+	require.ErrorContains(t, err, "already exists")
+	require.Equal(t, txHex, readOutputFile(t, fileName))
+
+	setOutputFlags(t, fileName, outputFormatJSON, true)
+	_, err = writeTxOutput(tx, 1_000)
+	require.NoError(t, err)
+
+	var jsonTx outputTx
+	err = json.Unmarshal([]byte(readOutputFile(t, fileName)), &jsonTx)
+	require.NoError(t, err)
+	require.Equal(t, tx.TxHash().String(), jsonTx.TXID)
+	require.Equal(t, txHex, jsonTx.Hex)
+	require.EqualValues(t, 1_000, jsonTx.Fee)
+	require.EqualValues(t, txVSize(tx), jsonTx.VSize)
+
+	// A signed TX can also be written as a finalized PSBT.
+	fileName = h.tempFile("tx.psbt")
+	setOutputFlags(t, fileName, outputFormatPsbt, false)
+	_, err = writeTxOutput(tx, 1_000)
+	require.NoError(t, err)
+
+	packet, err := psbt.NewFromRawBytes(
+		strings.NewReader(readOutputFile(t, fileName)), true,
+	)
+	require.NoError(t, err)
+	finalTx, err := psbt.Extract(packet)
+	require.NoError(t, err)
+	require.Equal(t, tx, finalTx)
+
+	setOutputFlags(t, h.tempFile("tx.bin"), "binary", false)
+	_, err = writeTxOutput(tx, 1_000)
+	require.ErrorContains(t, err, "unknown output format binary")
+}
+
+func TestWritePsbtOutput(t *testing.T) {
+	h := newHarness(t)
+
+	unsignedTx := newOutputTestTx()
+	unsignedTx.TxIn[0].Witness = nil
+	packet, err := psbt.NewFromUnsignedTx(unsignedTx)
+	require.NoError(t, err)
+	packetB64, err := packet.B64Encode()
+	require.NoError(t, err)
+
+	// The default format for a PSBT is base64.
+	fileName := h.tempFile("offer.psbt")
+	setOutputFlags(t, fileName, "", false)
+	require.NoError(t, logPsbt(packet))
+	require.Equal(t, packetB64, readOutputFile(t, fileName))
+	h.assertLogContains("Wrote PSBT to " + fileName)
+	require.NotContains(t, h.getLog(), packetB64)
+
+	fileName = h.tempFile("offer.json")
+	setOutputFlags(t, fileName, outputFormatJSON, false)
+	written, err := writePsbtOutput(packet)
+	require.NoError(t, err)
+	require.True(t, written)
+
+	var jsonPsbt outputPsbt
+	err = json.Unmarshal([]byte(readOutputFile(t, fileName)), &jsonPsbt)
+	require.NoError(t, err)
+	require.Equal(t, unsignedTx.TxHash().String(), jsonPsbt.TXID)
+	require.Equal(t, packetB64, jsonPsbt.Psbt)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// PsbtKeyTypeInputSignatureTweakSingle is the key of the proprietary
+	// PSBT input field that lnd uses for the single tweak that needs to be
+	// applied to the private key before signing.
+	PsbtKeyTypeInputSignatureTweakSingle = []byte{0x51}
+)
+
+// addPsbtFlag adds the --psbt flag to the given command.
+func addPsbtFlag(cmd *cobra.Command, createPsbt *bool) {
+	cmd.Flags().BoolVar(
+		createPsbt, "psbt", false, "create an unsigned PSBT with all "+
+			"input information instead of signing the TX, so it "+
+			"can be signed on another machine",
+	)
+}
+
+// newSweepPsbt creates a PSBT from the given unsigned sweep transaction and
+// adds all the information an external signer needs to sign the inputs
+// described by the sign descriptors.
+func newSweepPsbt(extendedKey *hdkeychain.ExtendedKey, tx *wire.MsgTx,
+	signDescs []*input.SignDescriptor) (*psbt.Packet, error) {
+
+	packet, err := psbt.NewFromUnsignedTx(tx)
+	if err != nil {
+		return nil, fmt.Errorf("error creating PSBT: %w", err)
+	}
+
+	for idx, signDesc := range signDescs {
+		err := addPsbtInputInfo(extendedKey, packet, idx, signDesc)
+		if err != nil {
+			return nil, fmt.Errorf("error adding info of input "+
+				"%d: %w", idx, err)
+		}
+	}
+
+	return packet, nil
+}
+
+// addPsbtInputInfo adds the witness UTXO, witness script, sighash type, BIP32
+// derivation path, the taproot spend info and, if the key needs to be tweaked,
+// the single tweak of the given sign descriptor to the input of the PSBT with
+// the given index.
+func addPsbtInputInfo(extendedKey *hdkeychain.ExtendedKey,
+	packet *psbt.Packet, idx int, signDesc *input.SignDescriptor) error {
+
+	derivation, err := bip32Derivation(extendedKey, signDesc.KeyDesc)
+	if err != nil {
+		return err
+	}
+
+	pIn := &packet.Inputs[idx]
+	pIn.WitnessUtxo = signDesc.Output
+	pIn.SighashType = signDesc.HashType
+	pIn.Bip32Derivation = []*psbt.Bip32Derivation{derivation}
+
+	// A P2WKH input doesn't have a witness script, even though some of
+	// the lnd functions expect the pkScript to be set as such.
+	if txscript.IsPayToWitnessScriptHash(signDesc.Output.PkScript) {
+		pIn.WitnessScript = signDesc.WitnessScript
+	}
+
+	if len(signDesc.SingleTweak) > 0 {
+		pIn.Unknowns = append(pIn.Unknowns, &psbt.Unknown{
+			Key:   PsbtKeyTypeInputSignatureTweakSingle,
+			Value: signDesc.SingleTweak,
+		})
+	}
+
+	if txscript.IsPayToTaproot(signDesc.Output.PkScript) {
+		addTaprootPsbtInputInfo(pIn, signDesc, derivation)
+	}
+
+	return nil
+}
+
+// addTaprootPsbtInputInfo adds the taproot BIP32 derivation path and either the
+// leaf script and control block of a script spend or the merkle root of a key
+// spend to the PSBT input.
+func addTaprootPsbtInputInfo(pIn *psbt.PInput, signDesc *input.SignDescriptor,
+	derivation *psbt.Bip32Derivation) {
+
+	tapDerivation := &psbt.TaprootBip32Derivation{
+		XOnlyPubKey:          derivation.PubKey[1:],
+		MasterKeyFingerprint: derivation.MasterKeyFingerprint,
+		Bip32Path:            derivation.Bip32Path,
+	}
+
+	if signDesc.SignMethod == input.TaprootScriptSpendSignMethod {
+		leaf := txscript.NewBaseTapLeaf(signDesc.WitnessScript)
+		leafHash := leaf.TapHash()
+		tapDerivation.LeafHashes = [][]byte{leafHash[:]}
+		pIn.TaprootLeafScript = []*psbt.TaprootTapLeafScript{{
+			ControlBlock: signDesc.ControlBlock,
+			Script:       signDesc.WitnessScript,
+			LeafVersion:  leaf.LeafVersion,
+		}}
+	} else {
+		pIn.TaprootMerkleRoot = signDesc.TapTweak
+	}
+
+	pIn.TaprootBip32Derivation = []*psbt.TaprootBip32Derivation{
+		tapDerivation,
+	}
+}
+
+// bip32Derivation returns the BIP32 derivation info of the lnd key with the
+// given key locator.
+func bip32Derivation(extendedKey *hdkeychain.ExtendedKey,
+	keyDesc keychain.KeyDescriptor) (*psbt.Bip32Derivation, error) {
+
+	path := []uint32{
+		lnd.HardenedKey(uint32(keychain.BIP0043Purpose)),
+		lnd.HardenedKey(chainParams.HDCoinType),
+		lnd.HardenedKey(uint32(keyDesc.Family)),
+		0,
+		keyDesc.Index,
+	}
+	derivedKey, err := lnd.DeriveChildren(extendedKey, path)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving key: %w", err)
+	}
+	pubKey, err := derivedKey.ECPubKey()
+	if err != nil {
+		return nil, fmt.Errorf("error deriving pubkey: %w", err)
+	}
+
+	// Make sure the key descriptor actually describes our key, otherwise
+	// an external signer would sign with the wrong key.
+	if keyDesc.PubKey != nil && !keyDesc.PubKey.IsEqual(pubKey) {
+		return nil, fmt.Errorf("key %x does not match derived key %x",
+			keyDesc.PubKey.SerializeCompressed(),
+			pubKey.SerializeCompressed())
+	}
+
+	rootPubKey, err := extendedKey.ECPubKey()
+	if err != nil {
+		return nil, fmt.Errorf("error deriving root pubkey: %w", err)
+	}
+	fingerprint := btcutil.Hash160(rootPubKey.SerializeCompressed())[:4]
+
+	return &psbt.Bip32Derivation{
+		PubKey:               pubKey.SerializeCompressed(),
+		MasterKeyFingerprint: binary.LittleEndian.Uint32(fingerprint),
+		Bip32Path:            path,
+	}, nil
+}
+
+// logPsbt logs the base64 encoded PSBT or writes it to the output file.
+func logPsbt(packet *psbt.Packet) error {
+	written, err := writePsbtOutput(packet)
+	if err != nil || written {
+		return err
+	}
+
+	base64, err := packet.B64Encode()
+	if err != nil {
+		return fmt.Errorf("error encoding PSBT: %w", err)
+	}
+
+	log.Infof("PSBT: %s", base64)
+	return nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSweepPsbt(t *testing.T) {
+	_ = newHarness(t)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+
+	keyRing := &lnd.HDKeyRing{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+	delayDesc, err := keyRing.DeriveKey(keychain.KeyLocator{
+		Family: keychain.KeyFamilyDelayBase,
+		Index:  4,
+	})
+	require.NoError(t, err)
+	paymentDesc, err := keyRing.DeriveKey(keychain.KeyLocator{
+		Family: keychain.KeyFamilyPaymentBase,
+		Index:  2,
+	})
+	require.NoError(t, err)
+
+	// A time locked output with a tweaked key and a P2WKH output.
+	commitPoint := paymentDesc.PubKey
+	script, err := input.CommitScriptToSelf(
+		144, input.TweakPubKey(delayDesc.PubKey, commitPoint),
+		paymentDesc.PubKey,
+	)
+	require.NoError(t, err)
+	scriptHash, err := input.WitnessScriptHash(script)
+	require.NoError(t, err)
+	p2wkhAddr, err := lnd.P2WKHAddr(paymentDesc.PubKey, chainParams)
+	require.NoError(t, err)
+	p2wkhScript, err := txscript.PayToAddrScript(p2wkhAddr)
+	require.NoError(t, err)
+
+	tweak := input.SingleTweakBytes(commitPoint, delayDesc.PubKey)
+	signDescs := []*input.SignDescriptor{{
+		KeyDesc:       delayDesc,
+		SingleTweak:   tweak,
+		WitnessScript: script,
+		Output: &wire.TxOut{
+			PkScript: scriptHash,
+			Value:    50_000,
+		},
+		HashType: txscript.SigHashAll,
+	}, {
+		KeyDesc: paymentDesc,
+		Output: &wire.TxOut{
+			PkScript: p2wkhScript,
+			Value:    60_000,
+		},
+		HashType: txscript.SigHashAll,
+	}}
+
+	tx := wire.NewMsgTx(2)
+	tx.TxIn = []*wire.TxIn{{}, {PreviousOutPoint: wire.OutPoint{Index: 1}}}
+	tx.TxOut = []*wire.TxOut{{Value: 100_000, PkScript: p2wkhScript}}
+
+	packet, err := newSweepPsbt(extendedKey, tx, signDescs)
+	require.NoError(t, err)
+
+	// Make sure the PSBT survives a serialization round trip.
+	var buf bytes.Buffer
+	require.NoError(t, packet.Serialize(&buf))
+	require.NoError(t, packet.SanityCheck())
+
+	rootPubKey, err := extendedKey.ECPubKey()
+	require.NoError(t, err)
+	fingerprint := binary.LittleEndian.Uint32(
+		btcutil.Hash160(rootPubKey.SerializeCompressed())[:4],
+	)
+
+	pIn := packet.Inputs[0]
+	require.Equal(t, signDescs[0].Output, pIn.WitnessUtxo)
+	require.Equal(t, script, pIn.WitnessScript)
+	require.Equal(t, txscript.SigHashAll, pIn.SighashType)
+	require.Len(t, pIn.Bip32Derivation, 1)
+	require.Equal(
+		t, delayDesc.PubKey.SerializeCompressed(),
+		pIn.Bip32Derivation[0].PubKey,
+	)
+	require.Equal(
+		t, fingerprint, pIn.Bip32Derivation[0].MasterKeyFingerprint,
+	)
+	require.Equal(t, []uint32{
+		lnd.HardenedKey(1017), lnd.HardenedKey(chainParams.HDCoinType),
+		lnd.HardenedKey(uint32(keychain.KeyFamilyDelayBase)), 0, 4,
+	}, pIn.Bip32Derivation[0].Bip32Path)
+	require.Len(t, pIn.Unknowns, 1)
+	require.Equal(
+		t, PsbtKeyTypeInputSignatureTweakSingle, pIn.Unknowns[0].Key,
+	)
+	require.Equal(t, tweak, pIn.Unknowns[0].Value)
+
+	// The P2WKH input has no witness script and no tweak.
+	pIn = packet.Inputs[1]
+	require.Equal(t, signDescs[1].Output, pIn.WitnessUtxo)
+	require.Empty(t, pIn.WitnessScript)
+	require.Empty(t, pIn.Unknowns)
+	require.Equal(t, []uint32{
+		lnd.HardenedKey(1017), lnd.HardenedKey(chainParams.HDCoinType),
+		lnd.HardenedKey(uint32(keychain.KeyFamilyPaymentBase)), 0, 2,
+	}, pIn.Bip32Derivation[0].Bip32Path)
+
+	// A key descriptor that doesn't match the derived key is refused.
+	signDescs[1].KeyDesc.Index = 3
+	_, err = newSweepPsbt(extendedKey, tx, signDescs)
+	require.ErrorContains(t, err, "does not match derived key")
+}
+
+func TestNewSweepPsbtTaproot(t *testing.T) {
+	_ = newHarness(t)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+
+	keyRing := &lnd.HDKeyRing{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+	paymentDesc, err := keyRing.DeriveKey(keychain.KeyLocator{
+		Family: keychain.KeyFamilyPaymentBase,
+		Index:  2,
+	})
+	require.NoError(t, err)
+
+	// The to_remote output of a simple taproot channel is spent with
+	// the script path.
+	addr, scriptTree, err := lnd.P2TaprootStaticRemote(
+		paymentDesc.PubKey, chainParams,
+	)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+	signDesc := &input.SignDescriptor{
+		KeyDesc:       paymentDesc,
+		WitnessScript: scriptTree.SettleLeaf.Script,
+		Output: &wire.TxOut{
+			PkScript: pkScript,
+			Value:    50_000,
+		},
+	}
+	err = lnd.SetTaprootScriptSpend(signDesc, &scriptTree.ScriptTree)
+	require.NoError(t, err)
+
+	tx := wire.NewMsgTx(2)
+	tx.TxIn = []*wire.TxIn{{}}
+	tx.TxOut = []*wire.TxOut{{Value: 40_000, PkScript: pkScript}}
+
+	packet, err := newSweepPsbt(
+		extendedKey, tx, []*input.SignDescriptor{signDesc},
+	)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, packet.Serialize(&buf))
+	require.NoError(t, packet.SanityCheck())
+
+	pIn := packet.Inputs[0]
+	require.Empty(t, pIn.WitnessScript)
+	require.Equal(t, txscript.SigHashDefault, pIn.SighashType)
+	require.Len(t, pIn.TaprootLeafScript, 1)
+	leafScript := pIn.TaprootLeafScript[0]
+	require.Equal(t, scriptTree.SettleLeaf.Script, leafScript.Script)
+	require.Equal(t, signDesc.ControlBlock, leafScript.ControlBlock)
+	require.Len(t, pIn.TaprootBip32Derivation, 1)
+	require.Equal(
+		t, schnorr.SerializePubKey(paymentDesc.PubKey),
+		pIn.TaprootBip32Derivation[0].XOnlyPubKey,
+	)
+	leafHash := scriptTree.SettleLeaf.TapHash()
+	require.Equal(
+		t, [][]byte{leafHash[:]},
+		pIn.TaprootBip32Derivation[0].LeafHashes,
+	)
+
+	// A script that isn't part of the tree can't be spent.
+	signDesc.WitnessScript = []byte{txscript.OP_TRUE}
+	err = lnd.SetTaprootScriptSpend(signDesc, &scriptTree.ScriptTree)
+	require.ErrorContains(t, err, "is not part of the script tree")
+}
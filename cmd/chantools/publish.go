@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// maxSweepFeeRate is the highest fee rate in sat/vByte a transaction
+	// is allowed to pay if its fee rate wasn't chosen by the user. This is
+	// the same as the default maximum fee rate of the lnd sweeper.
+	maxSweepFeeRate = 1000
+)
+
+// addDryRunFlag adds the --dry-run flag to the given command.
+func addDryRunFlag(cmd *cobra.Command, dryRun *bool) {
+	cmd.Flags().BoolVar(
+		dryRun, "dry-run", false, "build and sign the TX and print "+
+			"its TXID, raw hex, size and fee but never publish "+
+			"it, even if --publish is set",
+	)
+}
+
+// shouldPublish returns true if a transaction should be published. Nothing is
+// published in dry run mode or if there is no API to publish to.
+func shouldPublish(publish, dryRun bool, apiURL string) bool {
+	if !publish {
+		return false
+	}
+
+	if dryRun {
+		log.Infof("Dry run, not publishing TX")
+		return false
+	}
+
+	backend := ChainBackend
+	if backend == "" {
+		backend = btc.ChainBackendEsplora
+	}
+	if backend == btc.ChainBackendEsplora && apiURL == "" {
+		log.Warnf("No API URL set, not publishing TX")
+		return false
+	}
+
+	return true
+}
+
+// publishTx logs a summary of the given signed transaction and publishes it
+// if publish is true. The fee is the total fee in satoshis the transaction
+// pays. The raw transaction is either logged or written to the output file.
+// The output file is checked first, so a transaction is never published without
+// also being written to it.
+func publishTx(api btc.ChainBackend, tx *wire.MsgTx, fee int64,
+	publish bool) error {
+
+	if err := checkTxOutput(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return fmt.Errorf("error serializing TX: %w", err)
+	}
+
+	vSize := txVSize(tx)
+	log.Infof("TX %v: virtual size %d vbytes, fee %d sats, fee rate "+
+		"%.2f sat/vbyte", tx.TxHash(), vSize, fee,
+		float64(fee)/float64(vSize))
+
+	if publish {
+		response, err := api.PublishTx(
+			hex.EncodeToString(buf.Bytes()),
+		)
+		if err != nil {
+			return err
+		}
+		log.Infof("Published TX %s, response: %s",
+			tx.TxHash().String(), response)
+	}
+
+	written, err := writeTxOutput(tx, fee)
+	if err != nil {
+		return err
+	}
+	if !written {
+		log.Infof("Transaction: %x", buf.Bytes())
+	}
+
+	return nil
+}
+
+// validateSweepTx verifies a sweep transaction before it is signed. Every input
+// must be signed with the amount and script of the output it spends, as
+// reported by the chain backend, because both are committed to by segwit
+// signatures and a wrong value only results in an invalid signature that is
+// rejected when the transaction is published. All inputs must be signed with
+// SIGHASH_ALL (or SIGHASH_DEFAULT for taproot), so the signatures commit to
+// all inputs and outputs. The fee that is left when subtracting the outputs
+// from the inputs must be positive, must not spend all inputs and must not pay
+// more than the given fee rate in sat/vByte for the estimated virtual size of
+// the signed transaction. A fee rate of 0 means the fee rate wasn't chosen by
+// the user, so only the hard cap of maxSweepFeeRate is enforced.
+func validateSweepTx(api btc.ChainBackend, tx *wire.MsgTx,
+	signDescs []*input.SignDescriptor, vSize int64,
+	maxFeeRate uint16) error {
+
+	if len(signDescs) != len(tx.TxIn) {
+		return fmt.Errorf("sweep TX has %d inputs but %d sign "+
+			"descriptors", len(tx.TxIn), len(signDescs))
+	}
+
+	var inputSum, outputSum int64
+	for idx, txIn := range tx.TxIn {
+		op := txIn.PreviousOutPoint
+		signDesc := signDescs[idx]
+		if signDesc.Output == nil {
+			return fmt.Errorf("input %d (%v) has no output to sign",
+				idx, op)
+		}
+
+		pkScript := signDesc.Output.PkScript
+		switch {
+		case signDesc.HashType == txscript.SigHashAll:
+
+		case signDesc.HashType == txscript.SigHashDefault &&
+			txscript.IsPayToTaproot(pkScript):
+
+		default:
+			return fmt.Errorf("input %d (%v) would be signed with "+
+				"sighash type %v, only SIGHASH_ALL is allowed",
+				idx, op, signDesc.HashType)
+		}
+
+		prevOut, err := fetchPrevOut(api, op)
+		if err != nil {
+			return fmt.Errorf("error verifying input %d: %w", idx,
+				err)
+		}
+		if prevOut.Value != signDesc.Output.Value {
+			return fmt.Errorf("input %d (%v) spends %d sats "+
+				"according to the chain backend but would be "+
+				"signed for %d sats", idx, op, prevOut.Value,
+				signDesc.Output.Value)
+		}
+		if !bytes.Equal(prevOut.PkScript, pkScript) {
+			return fmt.Errorf("input %d (%v) spends the script %x "+
+				"according to the chain backend but would be "+
+				"signed for the script %x", idx, op,
+				prevOut.PkScript, pkScript)
+		}
+
+		inputSum += prevOut.Value
+	}
+
+	for _, txOut := range tx.TxOut {
+		outputSum += txOut.Value
+	}
+
+	fee := inputSum - outputSum
+	switch {
+	case fee <= 0:
+		return fmt.Errorf("outputs of %d sats spend all inputs of %d "+
+			"sats, the fee of %d sats must be positive", outputSum,
+			inputSum, fee)
+
+	case fee >= inputSum:
+		return fmt.Errorf("fee of %d sats would spend all inputs of "+
+			"%d sats", fee, inputSum)
+
+	case vSize <= 0:
+		return fmt.Errorf("invalid virtual size of %d vbytes", vSize)
+	}
+
+	if maxFeeRate == 0 {
+		maxFeeRate = maxSweepFeeRate
+	}
+	feeRate := float64(fee) / float64(vSize)
+	if feeRate > float64(maxFeeRate) {
+		return fmt.Errorf("fee of %d sats for %d vbytes is a fee rate "+
+			"of %.2f sat/vbyte, which is above the maximum of %d "+
+			"sat/vbyte", fee, vSize, feeRate, maxFeeRate)
+	}
+
+	return nil
+}
+
+// validatePsbt verifies all inputs of a PSBT with validateSweepTx before any
+// of them is signed. Every input must have a witness UTXO, which is the output
+// it is signed for. Inputs without an explicit sighash type are signed with
+// SIGHASH_ALL (or SIGHASH_DEFAULT for taproot).
+func validatePsbt(api btc.ChainBackend, packet *psbt.Packet, vSize int64,
+	maxFeeRate uint16) error {
+
+	signDescs := make([]*input.SignDescriptor, len(packet.Inputs))
+	for idx, pIn := range packet.Inputs {
+		if pIn.WitnessUtxo == nil {
+			return fmt.Errorf("input %d has no witness UTXO", idx)
+		}
+
+		hashType := pIn.SighashType
+		isTaproot := txscript.IsPayToTaproot(pIn.WitnessUtxo.PkScript)
+		if hashType == 0 && !isTaproot {
+			hashType = txscript.SigHashAll
+		}
+		signDescs[idx] = &input.SignDescriptor{
+			Output:   pIn.WitnessUtxo,
+			HashType: hashType,
+		}
+	}
+
+	return validateSweepTx(
+		api, packet.UnsignedTx, signDescs, vSize, maxFeeRate,
+	)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldPublish(t *testing.T) {
+	h := newHarness(t)
+
+	require.False(t, shouldPublish(false, false, defaultAPIURL))
+	require.True(t, shouldPublish(true, false, defaultAPIURL))
+
+	require.False(t, shouldPublish(true, true, defaultAPIURL))
+	h.assertLogContains("Dry run, not publishing TX")
+
+	require.False(t, shouldPublish(true, false, ""))
+	h.assertLogContains("No API URL set, not publishing TX")
+}
+
+func TestPublishTx(t *testing.T) {
+	h := newHarness(t)
+
+	tx := wire.NewMsgTx(2)
+	tx.TxIn = []*wire.TxIn{{
+		PreviousOutPoint: wire.OutPoint{Index: 1},
+		Witness:          wire.TxWitness{bytes.Repeat([]byte{1}, 72)},
+	}}
+	tx.TxOut = []*wire.TxOut{{
+		Value:    10_000,
+		PkScript: bytes.Repeat([]byte{2}, 22),
+	}}
+	var buf bytes.Buffer
+	require.NoError(t, tx.Serialize(&buf))
+	txHex := hex.EncodeToString(buf.Bytes())
+
+	api := newFakeChainBackend()
+
+	// In dry run mode, only the summary is logged.
+	require.NoError(t, publishTx(api, tx, 1_000, false))
+	require.Empty(t, api.publishedTxs())
+	h.assertLogContains(tx.TxHash().String())
+	h.assertLogContains("fee 1000 sats")
+	h.assertLogContains("Transaction: " + txHex)
+
+	h.clearLog()
+	require.NoError(t, publishTx(api, tx, 1_000, true))
+	require.Equal(t, []string{txHex}, api.publishedTxs())
+	h.assertLogContains("Published TX " + tx.TxHash().String())
+
+	// If the output file can't be written, the TX must not be published
+	// either.
+	api = newFakeChainBackend()
+	setOutputFlags(t, h.tempFile("missing/tx.txt"), "", false)
+	err := publishTx(api, tx, 1_000, true)
+	require.ErrorContains(t, err, "can't be written")
+	require.Empty(t, api.publishedTxs())
+
+	fileName := h.tempFile("tx.txt")
+	require.NoError(t, ioutil.WriteFile(fileName, nil, 0600))
+	setOutputFlags(t, fileName, "", false)
+	err = publishTx(api, tx, 1_000, true)
+	require.ErrorContains(t, err, "already exists")
+	require.Empty(t, api.publishedTxs())
+
+	setOutputFlags(t, fileName, "binary", true)
+	err = publishTx(api, tx, 1_000, true)
+	require.ErrorContains(t, err, "unknown output format binary")
+	require.Empty(t, api.publishedTxs())
+}
+
+func TestValidateSweepTx(t *testing.T) {
+	pkScript := append([]byte{0x00, 0x14}, bytes.Repeat([]byte{1}, 20)...)
+	prevTxid := bytes.Repeat([]byte{0xab}, 32)
+	prevTx := &btc.TX{
+		TXID: hex.EncodeToString(prevTxid),
+		Vout: []*btc.Vout{{
+			ScriptPubkey: hex.EncodeToString(pkScript),
+			Value:        50_000,
+		}},
+	}
+	api := newFakeChainBackend()
+	api.addAPITx(prevTx)
+
+	newTx := func(index uint32, value int64) *wire.MsgTx {
+		op, err := lnd.ParseOutpoint(fmt.Sprintf(
+			"%s:%d", prevTx.TXID, index,
+		))
+		require.NoError(t, err)
+
+		tx := wire.NewMsgTx(2)
+		tx.TxIn = []*wire.TxIn{{PreviousOutPoint: *op}}
+		tx.TxOut = []*wire.TxOut{{Value: value, PkScript: pkScript}}
+		return tx
+	}
+	newSignDesc := func(value int64, script []byte,
+		hashType txscript.SigHashType) []*input.SignDescriptor {
+
+		return []*input.SignDescriptor{{
+			Output: &wire.TxOut{
+				Value:    value,
+				PkScript: script,
+			},
+			HashType: hashType,
+		}}
+	}
+
+	testCases := []struct {
+		name       string
+		tx         *wire.MsgTx
+		signDescs  []*input.SignDescriptor
+		vSize      int64
+		maxFeeRate uint16
+		err        string
+	}{{
+		name: "valid",
+		tx:   newTx(0, 49_000),
+		signDescs: newSignDesc(
+			50_000, pkScript, txscript.SigHashAll,
+		),
+		vSize:      110,
+		maxFeeRate: 10,
+	}, {
+		name:  "missing sign descriptor",
+		tx:    newTx(0, 49_000),
+		vSize: 110,
+		err:   "sweep TX has 1 inputs but 0 sign descriptors",
+	}, {
+		name: "wrong amount",
+		tx:   newTx(0, 59_000),
+		signDescs: newSignDesc(
+			60_000, pkScript, txscript.SigHashAll,
+		),
+		vSize: 110,
+		err: "spends 50000 sats according to the chain backend but " +
+			"would be signed for 60000 sats",
+	}, {
+		name: "wrong script",
+		tx:   newTx(0, 49_000),
+		signDescs: newSignDesc(
+			50_000, []byte{0x00, 0x14}, txscript.SigHashAll,
+		),
+		vSize: 110,
+		err:   "would be signed for the script 0014",
+	}, {
+		name: "sighash single",
+		tx:   newTx(0, 49_000),
+		signDescs: newSignDesc(
+			50_000, pkScript, txscript.SigHashSingle,
+		),
+		vSize: 110,
+		err:   "only SIGHASH_ALL is allowed",
+	}, {
+		name: "sighash default for segwit v0",
+		tx:   newTx(0, 49_000),
+		signDescs: newSignDesc(
+			50_000, pkScript, txscript.SigHashDefault,
+		),
+		vSize: 110,
+		err:   "only SIGHASH_ALL is allowed",
+	}, {
+		name: "unknown output",
+		tx:   newTx(1, 49_000),
+		signDescs: newSignDesc(
+			50_000, pkScript, txscript.SigHashAll,
+		),
+		vSize: 110,
+		err:   "invalid output index 1",
+	}, {
+		name: "zero fee",
+		tx:   newTx(0, 50_000),
+		signDescs: newSignDesc(
+			50_000, pkScript, txscript.SigHashAll,
+		),
+		vSize: 110,
+		err: "outputs of 50000 sats spend all inputs of 50000 sats, " +
+			"the fee of 0 sats must be positive",
+	}, {
+		name: "negative fee",
+		tx:   newTx(0, 50_500),
+		signDescs: newSignDesc(
+			50_000, pkScript, txscript.SigHashAll,
+		),
+		vSize: 110,
+		err:   "the fee of -500 sats must be positive",
+	}, {
+		name: "fee spends all inputs",
+		tx:   newTx(0, 0),
+		signDescs: newSignDesc(
+			50_000, pkScript, txscript.SigHashAll,
+		),
+		vSize: 110,
+		err:   "fee of 50000 sats would spend all inputs of 50000 sats",
+	}, {
+		name: "negative output",
+		tx:   newTx(0, -1_000),
+		signDescs: newSignDesc(
+			50_000, pkScript, txscript.SigHashAll,
+		),
+		vSize: 110,
+		err:   "fee of 51000 sats would spend all inputs of 50000 sats",
+	}, {
+		name: "missing virtual size",
+		tx:   newTx(0, 49_000),
+		signDescs: newSignDesc(
+			50_000, pkScript, txscript.SigHashAll,
+		),
+		err: "invalid virtual size of 0 vbytes",
+	}, {
+		name: "fee rate above chosen fee rate",
+		tx:   newTx(0, 48_000),
+		signDescs: newSignDesc(
+			50_000, pkScript, txscript.SigHashAll,
+		),
+		vSize:      110,
+		maxFeeRate: 10,
+		err: "fee of 2000 sats for 110 vbytes is a fee rate of " +
+			"18.18 sat/vbyte, which is above the maximum of 10 " +
+			"sat/vbyte",
+	}, {
+		name: "fee rate below hard cap",
+		tx:   newTx(0, 48_000),
+		signDescs: newSignDesc(
+			50_000, pkScript, txscript.SigHashAll,
+		),
+		vSize: 110,
+	}, {
+		name: "fee rate above hard cap",
+		tx:   newTx(0, 1_000),
+		signDescs: newSignDesc(
+			50_000, pkScript, txscript.SigHashAll,
+		),
+		vSize: 40,
+		err: "fee rate of 1225.00 sat/vbyte, which is above the " +
+			"maximum of 1000 sat/vbyte",
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateSweepTx(
+				api, tc.tx, tc.signDescs, tc.vSize,
+				tc.maxFeeRate,
+			)
+			if tc.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+
+	// The inputs of a PSBT are signed for their witness UTXO, with
+	// SIGHASH_ALL if no sighash type is set.
+	newPacket := func(value int64,
+		hashType txscript.SigHashType) *psbt.Packet {
+
+		packet, err := psbt.NewFromUnsignedTx(newTx(0, 49_000))
+		require.NoError(t, err)
+		packet.Inputs[0].WitnessUtxo = &wire.TxOut{
+			Value:    value,
+			PkScript: pkScript,
+		}
+		packet.Inputs[0].SighashType = hashType
+		return packet
+	}
+	require.NoError(t, validatePsbt(api, newPacket(50_000, 0), 110, 10))
+	require.NoError(t, validatePsbt(
+		api, newPacket(50_000, txscript.SigHashAll), 110, 10,
+	))
+
+	err := validatePsbt(
+		api, newPacket(50_000, txscript.SigHashNone), 110, 10,
+	)
+	require.ErrorContains(t, err, "only SIGHASH_ALL is allowed")
+
+	err = validatePsbt(api, newPacket(51_000, 0), 110, 10)
+	require.ErrorContains(t, err, "spends 50000 sats according to the "+
+		"chain backend but would be signed for 51000 sats")
+
+	packet := newPacket(50_000, 0)
+	packet.Inputs[0].WitnessUtxo = nil
+	err = validatePsbt(api, packet, 110, 10)
+	require.ErrorContains(t, err, "input 0 has no witness UTXO")
+}
@@ -0,0 +1,621 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// anchorOutputValue is the value of each of the two anchor outputs of
+	// an anchor channel commitment transaction.
+	anchorOutputValue = 330
+
+	// minRelayFeeRate is the default minimum relay fee rate of bitcoind in
+	// sat/vByte.
+	minRelayFeeRate = 1
+
+	// mempoolMinFeeConfTarget is the highest confirmation target the chain
+	// API provides a fee estimate for. We use that estimate as an
+	// approximation of the minimum fee rate the mempool currently accepts.
+	mempoolMinFeeConfTarget = 1008
+)
+
+type pullAnchorCommand struct {
+	APIURL           string
+	CommitTxid       string
+	WalletUtxos      []string
+	SelectUtxos      bool
+	GapLimit         uint32
+	GapLimitInternal uint32
+	ChangeAddr       string
+	FeeRate          uint16
+	ConfTarget       uint32
+	RecoveryWindow   uint32
+	Publish          bool
+	DryRun           bool
+
+	rootKey *rootKey
+	cmd     *cobra.Command
+}
+
+func newPullAnchorCommand() *cobra.Command {
+	cc := &pullAnchorCommand{}
+	cc.cmd = &cobra.Command{
+		Use: "pullanchor",
+		Short: "Bump the fee of an unconfirmed force-close " +
+			"commitment transaction of an anchor channel with CPFP",
+		Long: `When a commitment transaction of an anchor output channel
+doesn't confirm because its fee is too low, it can be accelerated by spending
+our anchor output in a child transaction that pays a higher fee (child pays for
+parent, CPFP).
+
+This command looks up the commitment transaction with the given TXID, finds the
+anchor output (330 satoshis) that belongs to our funding key (or, for a simple
+taproot channel that was force-closed by the remote peer, to our payment base
+key) and creates a child transaction that spends that anchor output together
+with one or more P2WKH UTXOs of the lnd wallet (BIP84, m/84'/coin_type'/0'). The
+child pays enough fees for the commitment and the child transaction together
+(the package) to reach the given fee rate. The change is sent to the given
+change address or, if none is given, to the first unused change address of the
+lnd wallet.
+
+The wallet UTXOs can either be specified with --walletutxo (multiple times) or,
+with --select-utxos, be chosen from a list of all UTXOs that are found by
+scanning the lnd wallet until --gap-limit consecutive addresses without funds
+on the external and --gap-limit-internal on the internal (change) branch (but
+at most --recoverywindow addresses per branch). All wallet UTXOs are checked to
+be unspent before they are used.
+
+The combined package fee rate is printed and a warning is shown if it is below
+the minimum fee rate the mempool currently accepts, as estimated by the chain
+API. Bitcoin Core only considers the package fee rate when the commitment
+transaction is already in its mempool, a commitment transaction that was
+evicted needs to be broadcast again first.`,
+		Example: `chantools pullanchor \
+	--committxid 0123abcd... \
+	--walletutxo 4567ef89...:1 \
+	--changeaddr bc1q..... \
+	--feerate 50 \
+	--publish
+
+chantools pullanchor \
+	--committxid 0123abcd... \
+	--select-utxos \
+	--feerate 50`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
+			"be esplora compatible)",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.CommitTxid, "committxid", "", "the TXID of the "+
+			"unconfirmed commitment transaction to bump",
+	)
+	cc.cmd.Flags().StringSliceVar(
+		&cc.WalletUtxos, "walletutxo", nil, "the outpoint "+
+			"(<txid>:<idx>) of a confirmed P2WKH UTXO of the lnd "+
+			"wallet that pays for the fees; can be specified "+
+			"multiple times",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.SelectUtxos, "select-utxos", false, "scan the lnd wallet "+
+			"for UTXOs and choose interactively which ones pay "+
+			"for the fees",
+	)
+	addGapLimitFlag(cc.cmd, &cc.GapLimit)
+	addGapLimitInternalFlag(cc.cmd, &cc.GapLimitInternal)
+	cc.cmd.Flags().StringVar(
+		&cc.ChangeAddr, "changeaddr", "", "the address to send the "+
+			"change of the child transaction to; if empty, the "+
+			"first unused change address of the lnd wallet is "+
+			"used",
+	)
+	cc.cmd.Flags().Uint16Var(
+		&cc.FeeRate, "feerate", defaultFeeSatPerVByte, "fee rate to "+
+			"use for the package of commitment and child "+
+			"transaction in Satoshis/vByte",
+	)
+	addConfTargetFlag(cc.cmd, &cc.ConfTarget)
+	cc.cmd.Flags().Uint32Var(
+		&cc.RecoveryWindow, "recoverywindow",
+		sweepRemoteClosedDefaultRecoveryWindow, "number of keys to "+
+			"scan for the funding key and the wallet UTXO key",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.Publish, "publish", false, "publish the child "+
+			"transaction to the network",
+	)
+	addDryRunFlag(cc.cmd, &cc.DryRun)
+
+	cc.rootKey = newRootKey(cc.cmd, "signing the transaction")
+
+	return cc.cmd
+}
+
+func (c *pullAnchorCommand) Execute(_ *cobra.Command, _ []string) error {
+	extendedKey, err := c.rootKey.read()
+	if err != nil {
+		return fmt.Errorf("error reading root key: %w", err)
+	}
+
+	if c.CommitTxid == "" {
+		return fmt.Errorf("commitment TXID is required")
+	}
+	switch {
+	case len(c.WalletUtxos) == 0 && !c.SelectUtxos:
+		return fmt.Errorf("wallet UTXO is required, use --walletutxo " +
+			"or --select-utxos")
+
+	case len(c.WalletUtxos) > 0 && c.SelectUtxos:
+		return fmt.Errorf("only one of --walletutxo and " +
+			"--select-utxos can be used")
+	}
+
+	var walletUtxos []wire.OutPoint
+	for _, utxo := range c.WalletUtxos {
+		walletUtxo, err := lnd.ParseOutpoint(utxo)
+		if err != nil {
+			return fmt.Errorf("error parsing wallet UTXO: %w", err)
+		}
+		walletUtxos = append(walletUtxos, *walletUtxo)
+	}
+
+	// Set default values.
+	if c.RecoveryWindow == 0 {
+		c.RecoveryWindow = sweepRemoteClosedDefaultRecoveryWindow
+	}
+	if c.GapLimit == 0 {
+		c.GapLimit = defaultGapLimit
+	}
+	if c.GapLimitInternal == 0 {
+		c.GapLimitInternal = defaultGapLimitInternal
+	}
+	if c.FeeRate == 0 {
+		c.FeeRate = defaultFeeSatPerVByte
+	}
+	c.FeeRate = sweepFeeRate(c.APIURL, c.ConfTarget, c.FeeRate)
+
+	api, err := newChainBackend(c.APIURL)
+	if err != nil {
+		return err
+	}
+
+	if c.SelectUtxos {
+		utxos, err := listWalletUtxos(
+			extendedKey, api, c.GapLimit, c.GapLimitInternal,
+			c.RecoveryWindow-1,
+		)
+		if err != nil {
+			return fmt.Errorf("error listing wallet UTXOs: %w", err)
+		}
+		walletUtxos, err = selectWalletUtxos(
+			utxos, bufio.NewReader(os.Stdin),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	changeAddr := c.ChangeAddr
+	if changeAddr == "" {
+		addr, err := freshChangeAddr(extendedKey, api, c.RecoveryWindow)
+		if err != nil {
+			return err
+		}
+		changeAddr = addr.EncodeAddress()
+	}
+
+	publish := shouldPublish(c.Publish, c.DryRun, c.APIURL)
+	return pullAnchor(
+		extendedKey, api, c.CommitTxid, walletUtxos, changeAddr,
+		c.FeeRate, c.RecoveryWindow, publish,
+	)
+}
+
+func pullAnchor(extendedKey *hdkeychain.ExtendedKey, api btc.ChainBackend,
+	commitTxid string, walletUtxos []wire.OutPoint, changeAddr string,
+	feeRate uint16, recoveryWindow uint32, publish bool) error {
+
+	commitTx, err := parseSweepTx(api, commitTxid)
+	if err != nil {
+		return err
+	}
+	commitFee, err := commitTxFee(api, commitTx)
+	if err != nil {
+		return err
+	}
+	commitVSize := txVSize(commitTx)
+
+	keyRing := &lnd.HDKeyRing{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+	anchorIndex, anchorDesc, err := findLocalAnchor(
+		keyRing, commitTx, recoveryWindow,
+	)
+	if err != nil {
+		return err
+	}
+	anchorOut := commitTx.TxOut[anchorIndex]
+	log.Infof("Found our anchor output %v:%d (key %x)",
+		commitTx.TxHash(), anchorIndex,
+		anchorDesc.KeyDesc.PubKey.SerializeCompressed())
+
+	anchorWitnessSize := input.AnchorWitnessSize
+	if txscript.IsPayToTaproot(anchorOut.PkScript) {
+		anchorWitnessSize = input.TaprootAnchorWitnessSize
+	}
+
+	var (
+		estimator       input.TxWeightEstimator
+		totalValue      = anchorOut.Value
+		walletOuts      = make([]*wire.TxOut, len(walletUtxos))
+		walletKeys      = make([]*btcec.PrivateKey, len(walletUtxos))
+		confirmedInputs = true
+	)
+	estimator.AddWitnessInput(anchorWitnessSize)
+	for idx, walletUtxo := range walletUtxos {
+		confirmed, err := checkUnspent(api, walletUtxo)
+		if err != nil {
+			return fmt.Errorf("error checking wallet UTXO: %w", err)
+		}
+		confirmedInputs = confirmedInputs && confirmed
+
+		walletOuts[idx], err = fetchPrevOut(api, walletUtxo)
+		if err != nil {
+			return err
+		}
+		walletKeys[idx], err = findWalletKey(
+			extendedKey, walletOuts[idx].PkScript, recoveryWindow,
+		)
+		if err != nil {
+			return err
+		}
+
+		estimator.AddP2WKHInput()
+		totalValue += walletOuts[idx].Value
+	}
+	if !confirmedInputs {
+		log.Warnf("Spending unconfirmed wallet UTXOs, their parent " +
+			"transactions are not accounted for in the package " +
+			"fee rate")
+	}
+
+	changeScript, err := lnd.GetP2WPKHScript(changeAddr, chainParams)
+	if err != nil {
+		return fmt.Errorf("error parsing change addr: %w", err)
+	}
+
+	estimator.AddP2WKHOutput()
+	childVSize := int64(estimator.VSize())
+
+	childFee := cpfpChildFee(feeRate, commitFee, commitVSize, childVSize)
+	if totalValue-childFee < sweepDustLimit {
+		return fmt.Errorf("child fee of %d sats would leave a change "+
+			"output below the dust limit of %d, use more or "+
+			"larger wallet UTXOs", childFee, sweepDustLimit)
+	}
+
+	packageRate := packageFeeRate(
+		commitFee, commitVSize, childFee, childVSize,
+	)
+	log.Infof("Commitment TX pays %d sats for %d vBytes (%.2f "+
+		"sat/vByte), child pays %d sats for %d vBytes, package fee "+
+		"rate is %.2f sat/vByte", commitFee, commitVSize,
+		float64(commitFee)/float64(commitVSize), childFee, childVSize,
+		packageRate)
+
+	minFeeRate := mempoolMinFeeRate(api)
+	if packageRate < minFeeRate {
+		log.Warnf("Package fee rate of %.2f sat/vByte is below the "+
+			"current mempool minimum fee rate of %.2f sat/vByte, "+
+			"the package might not be relayed, increase the fee "+
+			"rate", packageRate, minFeeRate)
+	}
+
+	childTx := wire.NewMsgTx(2)
+	childTx.TxIn = []*wire.TxIn{{
+		PreviousOutPoint: wire.OutPoint{
+			Hash:  commitTx.TxHash(),
+			Index: anchorIndex,
+		},
+		Sequence: rbfSequence,
+	}}
+	for _, walletUtxo := range walletUtxos {
+		childTx.TxIn = append(childTx.TxIn, &wire.TxIn{
+			PreviousOutPoint: walletUtxo,
+			Sequence:         rbfSequence,
+		})
+	}
+	childTx.TxOut = []*wire.TxOut{{
+		Value:    totalValue - childFee,
+		PkScript: changeScript,
+	}}
+	log.Infof("Sending %d sats of change to %s", totalValue-childFee,
+		changeAddr)
+
+	signDescs := []*input.SignDescriptor{anchorDesc}
+	for idx, walletOut := range walletOuts {
+		signDescs = append(signDescs, &input.SignDescriptor{
+			Output:     walletOut,
+			HashType:   txscript.SigHashAll,
+			InputIndex: idx + 1,
+		})
+	}
+	prevOuts := lnd.PrevOutputFetcher(childTx, signDescs)
+	sigHashes := txscript.NewTxSigHashes(childTx, prevOuts)
+	for _, desc := range signDescs {
+		desc.SigHashes = sigHashes
+		desc.PrevOutputFetcher = prevOuts
+	}
+
+	// The child also pays for the parent, so its own fee rate is above the
+	// chosen one and only the hard cap applies.
+	err = validateSweepTx(api, childTx, signDescs, childVSize, 0)
+	if err != nil {
+		return fmt.Errorf("error validating child TX: %w", err)
+	}
+
+	// Sign the anchor input with our funding key and the wallet inputs
+	// with the keys of the UTXOs.
+	signer := &lnd.Signer{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+	spendAnchor := input.CommitSpendAnchor
+	if txscript.IsPayToTaproot(anchorOut.PkScript) {
+		spendAnchor = input.TaprootAnchorSpend
+	}
+	anchorWitness, err := spendAnchor(signer, anchorDesc, childTx)
+	if err != nil {
+		return fmt.Errorf("error signing anchor input: %w", err)
+	}
+	childTx.TxIn[0].Witness = anchorWitness
+
+	for idx, walletKey := range walletKeys {
+		desc := signDescs[idx+1]
+		walletWitness, err := txscript.WitnessSignature(
+			childTx, sigHashes, desc.InputIndex, desc.Output.Value,
+			desc.Output.PkScript, desc.HashType, walletKey, true,
+		)
+		if err != nil {
+			return fmt.Errorf("error signing wallet input %d: %w",
+				idx, err)
+		}
+		childTx.TxIn[desc.InputIndex].Witness = walletWitness
+	}
+
+	return publishTx(api, childTx, childFee, publish)
+}
+
+// commitTxFee returns the fee the given unconfirmed commitment transaction
+// pays, looked up with the chain API.
+func commitTxFee(api btc.ChainBackend, commitTx *wire.MsgTx) (int64,
+	error) {
+
+	txid := commitTx.TxHash().String()
+	tx, err := api.Transaction(txid)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching TX %v: %w", txid, err)
+	}
+	if tx.Status != nil && tx.Status.Confirmed {
+		return 0, fmt.Errorf("commitment TX %v is already confirmed "+
+			"in block %d", txid, tx.Status.BlockHeight)
+	}
+
+	var inputValue, outputValue int64
+	for _, vin := range tx.Vin {
+		if vin.Prevout == nil {
+			return 0, fmt.Errorf("API didn't return the previous "+
+				"output of TX %v", txid)
+		}
+		inputValue += int64(vin.Prevout.Value)
+	}
+	for _, txOut := range commitTx.TxOut {
+		outputValue += txOut.Value
+	}
+
+	return inputValue - outputValue, nil
+}
+
+// txVSize returns the virtual size of the given transaction.
+func txVSize(tx *wire.MsgTx) int64 {
+	weight := tx.SerializeSizeStripped()*(4-1) + tx.SerializeSize()
+	return int64((weight + 3) / 4)
+}
+
+// findLocalAnchor scans the multisig key family for the funding key that
+// one of the anchor outputs of the commitment transaction pays to. The anchor
+// outputs of simple taproot channels pay to the delay key of the local and the
+// payment base key of the remote party instead, so the payment base key family
+// is scanned for those. The index of the output and the sign descriptor to
+// spend it are returned.
+func findLocalAnchor(keyRing *lnd.HDKeyRing, commitTx *wire.MsgTx,
+	recoveryWindow uint32) (uint32, *input.SignDescriptor, error) {
+
+	anchors := make(map[uint32][]byte)
+	for idx, txOut := range commitTx.TxOut {
+		if txOut.Value == anchorOutputValue &&
+			(txscript.IsPayToWitnessScriptHash(txOut.PkScript) ||
+				txscript.IsPayToTaproot(txOut.PkScript)) {
+
+			anchors[uint32(idx)] = txOut.PkScript
+		}
+	}
+	if len(anchors) == 0 {
+		return 0, nil, fmt.Errorf("commitment TX %v has no anchor "+
+			"outputs", commitTx.TxHash())
+	}
+
+	for index := uint32(0); index < recoveryWindow; index++ {
+		keyDesc, err := keyRing.DeriveKey(keychain.KeyLocator{
+			Family: keychain.KeyFamilyMultiSig,
+			Index:  index,
+		})
+		if err != nil {
+			return 0, nil, fmt.Errorf("error deriving key: %w", err)
+		}
+
+		script, err := input.CommitScriptAnchor(keyDesc.PubKey)
+		if err != nil {
+			return 0, nil, err
+		}
+		pkScript, err := input.WitnessScriptHash(script)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		for outIndex, anchorScript := range anchors {
+			if bytes.Equal(pkScript, anchorScript) {
+				return outIndex, &input.SignDescriptor{
+					KeyDesc:       keyDesc,
+					WitnessScript: script,
+					Output:        commitTx.TxOut[outIndex],
+					HashType:      txscript.SigHashAll,
+				}, nil
+			}
+		}
+
+		keyDesc, err = keyRing.DeriveKey(keychain.KeyLocator{
+			Family: keychain.KeyFamilyPaymentBase,
+			Index:  index,
+		})
+		if err != nil {
+			return 0, nil, fmt.Errorf("error deriving key: %w", err)
+		}
+
+		scriptTree, err := input.NewAnchorScriptTree(keyDesc.PubKey)
+		if err != nil {
+			return 0, nil, err
+		}
+		pkScript, err = input.PayToTaprootScript(scriptTree.TaprootKey)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		// The anchor is spent with the key path, the key is tweaked
+		// with the root of the script tree.
+		signDesc := &input.SignDescriptor{
+			KeyDesc:    keyDesc,
+			HashType:   txscript.SigHashDefault,
+			SignMethod: input.TaprootKeySpendSignMethod,
+			TapTweak:   scriptTree.TapscriptRoot,
+		}
+		for outIndex, anchorScript := range anchors {
+			if bytes.Equal(pkScript, anchorScript) {
+				signDesc.Output = commitTx.TxOut[outIndex]
+				return outIndex, signDesc, nil
+			}
+		}
+	}
+
+	return 0, nil, fmt.Errorf("none of the anchor outputs of TX %v "+
+		"belongs to the first %d funding or payment base keys, try "+
+		"increasing --recoverywindow; the anchor of our own "+
+		"commitment of a simple taproot channel is not supported",
+		commitTx.TxHash(), recoveryWindow)
+}
+
+// findWalletKey scans the external and internal branch of the default lnd
+// wallet account (BIP84) for the private key of the given P2WKH output script.
+func findWalletKey(extendedKey *hdkeychain.ExtendedKey, pkScript []byte,
+	recoveryWindow uint32) (*btcec.PrivateKey, error) {
+
+	if !txscript.IsPayToWitnessPubKeyHash(pkScript) {
+		return nil, fmt.Errorf("wallet UTXO must be a P2WKH output")
+	}
+
+	path, err := lnd.ParsePath(lnd.WalletDefaultDerivationPath)
+	if err != nil {
+		return nil, err
+	}
+	path[1] = lnd.HardenedKey(chainParams.HDCoinType)
+	accountKey, err := lnd.DeriveChildren(extendedKey, path)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving account key: %w", err)
+	}
+
+	for _, branch := range []uint32{0, 1} {
+		for index := uint32(0); index < recoveryWindow; index++ {
+			key, err := lnd.DeriveChildren(
+				accountKey, []uint32{branch, index},
+			)
+			if err != nil {
+				return nil, fmt.Errorf("error deriving key: "+
+					"%w", err)
+			}
+			privKey, err := key.ECPrivKey()
+			if err != nil {
+				return nil, err
+			}
+
+			addr, err := lnd.P2WKHAddr(
+				privKey.PubKey(), chainParams,
+			)
+			if err != nil {
+				return nil, err
+			}
+			script, err := txscript.PayToAddrScript(addr)
+			if err != nil {
+				return nil, err
+			}
+			if bytes.Equal(script, pkScript) {
+				return privKey, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("wallet UTXO not found in the first %d keys "+
+		"of the wallet, try increasing --recoverywindow",
+		recoveryWindow)
+}
+
+// cpfpChildFee returns the fee the child transaction needs to pay for the
+// package of parent and child to reach the given fee rate. The child always
+// pays at least the minimum relay fee for its own size.
+func cpfpChildFee(feeRate uint16, parentFee, parentVSize,
+	childVSize int64) int64 {
+
+	childFee := int64(feeRate)*(parentVSize+childVSize) - parentFee
+	if childFee < minRelayFeeRate*childVSize {
+		childFee = minRelayFeeRate * childVSize
+	}
+
+	return childFee
+}
+
+// packageFeeRate returns the combined fee rate of a parent and child
+// transaction in sat/vByte.
+func packageFeeRate(parentFee, parentVSize, childFee,
+	childVSize int64) float64 {
+
+	return float64(parentFee+childFee) / float64(parentVSize+childVSize)
+}
+
+// mempoolMinFeeRate returns an estimate of the minimum fee rate in sat/vByte
+// the mempool currently accepts. If the chain API can't be queried, the
+// default minimum relay fee rate is returned.
+func mempoolMinFeeRate(api btc.ChainBackend) float64 {
+	estimate, err := api.FeeEstimate(mempoolMinFeeConfTarget)
+	if err != nil {
+		log.Warnf("Could not estimate mempool minimum fee rate, "+
+			"assuming %d sat/vByte: %v", minRelayFeeRate, err)
+		return minRelayFeeRate
+	}
+
+	return math.Max(estimate, minRelayFeeRate)
+}
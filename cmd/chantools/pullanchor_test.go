@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullAnchor(t *testing.T) {
+	t.Run("anchors", func(t *testing.T) {
+		testPullAnchor(t, false)
+	})
+	t.Run("simple taproot", func(t *testing.T) {
+		testPullAnchor(t, true)
+	})
+}
+
+func testPullAnchor(t *testing.T, taproot bool) {
+	h := newHarness(t)
+
+	rootKey, err := hdkeychain.NewKeyFromString(rootKeyBip39)
+	require.NoError(t, err)
+	otherKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+
+	// The anchors of a simple taproot channel that was force-closed by the
+	// remote peer pay to our payment base key.
+	anchorScript := func(key *hdkeychain.ExtendedKey, index uint32) []byte {
+		ring := &lnd.HDKeyRing{
+			ExtendedKey: key,
+			ChainParams: chainParams,
+		}
+		family := keychain.KeyFamilyMultiSig
+		if taproot {
+			family = keychain.KeyFamilyPaymentBase
+		}
+		desc, err := ring.DeriveKey(keychain.KeyLocator{
+			Family: family,
+			Index:  index,
+		})
+		require.NoError(t, err)
+		if taproot {
+			key, err := input.TaprootOutputKeyAnchor(desc.PubKey)
+			require.NoError(t, err)
+			pkScript, err := input.PayToTaprootScript(key)
+			require.NoError(t, err)
+			return pkScript
+		}
+		script, err := input.CommitScriptAnchor(desc.PubKey)
+		require.NoError(t, err)
+		pkScript, err := input.WitnessScriptHash(script)
+		require.NoError(t, err)
+		return pkScript
+	}
+
+	// The commitment transaction has the anchor of the remote party first
+	// and our anchor for the key with index 7 second.
+	const commitFee = 1_000
+	commitTx := wire.NewMsgTx(2)
+	commitTx.TxIn = []*wire.TxIn{{}}
+	commitTx.TxOut = []*wire.TxOut{{
+		Value:    anchorOutputValue,
+		PkScript: anchorScript(otherKey, 7),
+	}, {
+		Value:    anchorOutputValue,
+		PkScript: anchorScript(rootKey, 7),
+	}, {
+		Value:    100_000,
+		PkScript: anchorScript(otherKey, 1),
+	}}
+	fundingValue := uint64(100_000 + 2*anchorOutputValue + commitFee)
+
+	// The wallet UTXO is on the internal branch of the BIP84 account.
+	walletKey, err := lnd.DeriveChildren(rootKey, []uint32{
+		lnd.HardenedKey(84), lnd.HardenedKey(chainParams.HDCoinType),
+		lnd.HardenedKey(0), 1, 3,
+	})
+	require.NoError(t, err)
+	walletPubKey, err := walletKey.ECPubKey()
+	require.NoError(t, err)
+	walletAddr, err := lnd.P2WKHAddr(walletPubKey, chainParams)
+	require.NoError(t, err)
+	walletScript, err := txscript.PayToAddrScript(walletAddr)
+	require.NoError(t, err)
+	walletTx := wire.NewMsgTx(2)
+	walletTx.TxOut = []*wire.TxOut{{
+		Value:    50_000,
+		PkScript: walletScript,
+	}}
+
+	apiTx := func(tx *wire.MsgTx, prevOutValue uint64) *btc.TX {
+		result := &btc.TX{
+			TXID: tx.TxHash().String(),
+			Vin: []*btc.Vin{{
+				Prevout: &btc.Vout{Value: prevOutValue},
+			}},
+			Status: &btc.Status{},
+		}
+		for _, txOut := range tx.TxOut {
+			result.Vout = append(result.Vout, &btc.Vout{
+				ScriptPubkey: hex.EncodeToString(
+					txOut.PkScript,
+				),
+				Value: uint64(txOut.Value),
+			})
+		}
+		return result
+	}
+	api := newFakeChainBackend()
+	api.addAPITx(apiTx(commitTx, fundingValue))
+	api.addAPITx(apiTx(walletTx, 0))
+	api.setFeeEstimate(2.5)
+
+	var buf bytes.Buffer
+	require.NoError(t, commitTx.Serialize(&buf))
+
+	err = pullAnchor(
+		rootKey, api, hex.EncodeToString(buf.Bytes()), []wire.OutPoint{{
+			Hash: walletTx.TxHash(),
+		}}, walletAddr.String(), 10, 10, false,
+	)
+	require.NoError(t, err)
+	h.assertLogContains("Found our anchor output")
+	require.NotContains(t, h.getLog(), "below the current mempool minimum")
+
+	logLines := strings.Split(strings.TrimSpace(h.getLog()), "\n")
+	lastLine := logLines[len(logLines)-1]
+	txHex := lastLine[strings.LastIndex(lastLine, " ")+1:]
+	childTx, err := parseSweepTx(api, txHex)
+	require.NoError(t, err)
+	require.Len(t, childTx.TxIn, 2)
+	require.EqualValues(t, 1, childTx.TxIn[0].PreviousOutPoint.Index)
+
+	// Both inputs must be signed correctly.
+	prevOuts := txscript.NewMultiPrevOutFetcher(
+		map[wire.OutPoint]*wire.TxOut{
+			childTx.TxIn[0].PreviousOutPoint: commitTx.TxOut[1],
+			childTx.TxIn[1].PreviousOutPoint: walletTx.TxOut[0],
+		},
+	)
+	sigHashes := txscript.NewTxSigHashes(childTx, prevOuts)
+	for idx, txIn := range childTx.TxIn {
+		prevOut := prevOuts.FetchPrevOutput(txIn.PreviousOutPoint)
+		vm, err := txscript.NewEngine(
+			prevOut.PkScript, childTx, idx,
+			txscript.StandardVerifyFlags, nil, sigHashes,
+			prevOut.Value, prevOuts,
+		)
+		require.NoError(t, err)
+		require.NoError(t, vm.Execute())
+	}
+
+	// The package of both transactions pays the requested fee rate.
+	childFee := anchorOutputValue + walletTx.TxOut[0].Value -
+		childTx.TxOut[0].Value
+	rate := packageFeeRate(
+		commitFee, txVSize(commitTx), childFee, txVSize(childTx),
+	)
+	require.InDelta(t, 10, rate, 0.1)
+
+	// A wallet UTXO that was spent already is rejected.
+	api.spend(
+		t, wire.OutPoint{Hash: walletTx.TxHash()},
+		strings.Repeat("cd", 32), nil,
+	)
+	err = pullAnchor(
+		rootKey, api, hex.EncodeToString(buf.Bytes()), []wire.OutPoint{{
+			Hash: walletTx.TxHash(),
+		}}, walletAddr.String(), 10, 10, false,
+	)
+	require.ErrorIs(t, err, errUtxoSpent)
+
+	// A commitment transaction without our anchor is rejected.
+	commitTx.TxOut = commitTx.TxOut[:1]
+	_, _, err = findLocalAnchor(&lnd.HDKeyRing{
+		ExtendedKey: rootKey,
+		ChainParams: chainParams,
+	}, commitTx, 10)
+	require.ErrorContains(t, err, "none of the anchor outputs")
+}
+
+func TestCpfpChildFee(t *testing.T) {
+	// The child makes up the difference to the package fee rate.
+	childFee := cpfpChildFee(10, 500, 200, 150)
+	require.EqualValues(t, 3_000, childFee)
+	require.EqualValues(t, 10, packageFeeRate(500, 200, childFee, 150))
+
+	// If the parent already pays enough, the child still pays the minimum
+	// relay fee for itself.
+	childFee = cpfpChildFee(10, 5_000, 200, 150)
+	require.EqualValues(t, 150, childFee)
+}
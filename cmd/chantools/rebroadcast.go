@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/spf13/cobra"
+)
+
+const (
+	rebroadcastAccepted     = "accepted"
+	rebroadcastAlreadyKnown = "already known"
+	rebroadcastRejected     = "rejected"
+)
+
+var (
+	// psbtMagic is the magic prefix of a binary PSBT.
+	psbtMagic = []byte{0x70, 0x73, 0x62, 0x74, 0xff}
+
+	// alreadyKnownReasons are the parts of the reject reasons of bitcoind
+	// (and Esplora, which forwards them) that tell us that the transaction
+	// is already known, which is not an error for a rebroadcast.
+	alreadyKnownReasons = []string{
+		"already in block chain",
+		"txn-already-known",
+		"txn-already-in-mempool",
+	}
+)
+
+type rebroadcastCommand struct {
+	APIURL  string
+	Txs     []string
+	TxFiles []string
+
+	cmd *cobra.Command
+}
+
+func newRebroadcastCommand() *cobra.Command {
+	cc := &rebroadcastCommand{}
+	cc.cmd = &cobra.Command{
+		Use: "rebroadcast",
+		Short: "Publish already signed transactions again, for " +
+			"example if they were dropped from the mempool",
+		Long: `During times of high fees, unconfirmed recovery
+transactions are sometimes dropped from the mempools of most nodes. This command
+pushes the given signed transactions to the chain backend again and reports for
+each of them whether it was accepted or rejected (with the reason of the node).
+
+The transactions can be given as raw hex with --tx or as files written by the
+--output-file flag of any other command with --txfile (raw hex, finalized PSBT
+or JSON format). A file in hex format can also contain one transaction per line.
+
+Transactions that are already confirmed are skipped, so the command is safe to
+be run repeatedly (for example by a cron job) until all transactions are
+confirmed.`,
+		Example: `chantools rebroadcast \
+	--tx 02000000000101...
+
+chantools rebroadcast \
+	--txfile sweep.json --txfile sweep-2.json`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
+			"be esplora compatible)",
+	)
+	cc.cmd.Flags().StringSliceVar(
+		&cc.Txs, "tx", nil, "the raw hex of a signed transaction to "+
+			"publish, can be specified multiple times",
+	)
+	cc.cmd.Flags().StringSliceVar(
+		&cc.TxFiles, "txfile", nil, "a file with signed "+
+			"transactions, as written by --output-file, can be "+
+			"specified multiple times",
+	)
+
+	return cc.cmd
+}
+
+func (c *rebroadcastCommand) Execute(_ *cobra.Command, _ []string) error {
+	if len(c.Txs) == 0 && len(c.TxFiles) == 0 {
+		return fmt.Errorf("need to specify at least one --tx or " +
+			"--txfile")
+	}
+
+	var txs []*wire.MsgTx
+	for idx, txHex := range c.Txs {
+		tx, err := parseRebroadcastTx(txHex)
+		if err != nil {
+			return fmt.Errorf("error parsing TX %d: %w", idx, err)
+		}
+		txs = append(txs, tx)
+	}
+	for _, fileName := range c.TxFiles {
+		content, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return fmt.Errorf("error reading file %s: %w", fileName,
+				err)
+		}
+
+		fileTxs, err := parseRebroadcastFile(content)
+		if err != nil {
+			return fmt.Errorf("error parsing file %s: %w", fileName,
+				err)
+		}
+		txs = append(txs, fileTxs...)
+	}
+
+	api, err := newChainBackend(c.APIURL)
+	if err != nil {
+		return err
+	}
+
+	return rebroadcast(api, txs)
+}
+
+// rebroadcast publishes all given transactions that aren't confirmed yet and
+// logs the result for each of them. An error is only returned after all
+// transactions were tried, if at least one of them was rejected.
+func rebroadcast(api btc.ChainBackend, txs []*wire.MsgTx) error {
+	numRejected := 0
+	for _, tx := range txs {
+		txid := tx.TxHash().String()
+
+		status, err := api.TxStatus(txid)
+		switch {
+		case err == nil && status.Confirmed:
+			log.Infof("TX %s: %s, confirmed in block %d", txid,
+				rebroadcastAlreadyKnown, status.BlockHeight)
+			continue
+
+		// If we can't look up the status, we'll just try to publish
+		// the transaction and see what the node says.
+		case err != nil && !errors.Is(err, btc.ErrTxNotFound):
+			log.Debugf("Could not fetch status of TX %s: %v", txid,
+				err)
+		}
+
+		result, reason := publishRebroadcastTx(api, tx)
+		if result == rebroadcastRejected {
+			numRejected++
+			log.Errorf("TX %s: %s, reason: %s", txid, result,
+				reason)
+			continue
+		}
+
+		log.Infof("TX %s: %s", txid, result)
+	}
+
+	if numRejected > 0 {
+		return fmt.Errorf("%d of %d transactions were rejected",
+			numRejected, len(txs))
+	}
+
+	return nil
+}
+
+// publishRebroadcastTx publishes a single transaction and returns the result
+// and, if it was rejected, the reason the node gave.
+func publishRebroadcastTx(api btc.ChainBackend, tx *wire.MsgTx) (string,
+	string) {
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return rebroadcastRejected, err.Error()
+	}
+
+	// Esplora responds with the TXID if the transaction was accepted and
+	// with the reject reason of the node otherwise, bitcoind returns the
+	// reason as an error.
+	response, err := api.PublishTx(hex.EncodeToString(buf.Bytes()))
+	reason := strings.TrimSpace(response)
+	if err != nil {
+		reason = err.Error()
+	}
+	if err == nil && reason == tx.TxHash().String() {
+		return rebroadcastAccepted, ""
+	}
+
+	for _, knownReason := range alreadyKnownReasons {
+		if strings.Contains(reason, knownReason) {
+			return rebroadcastAlreadyKnown, ""
+		}
+	}
+
+	return rebroadcastRejected, reason
+}
+
+// parseRebroadcastFile parses the signed transactions in a file that was
+// written by --output-file in any of the supported formats.
+func parseRebroadcastFile(content []byte) ([]*wire.MsgTx, error) {
+	content = bytes.TrimSpace(content)
+	if bytes.HasPrefix(content, []byte("{")) {
+		var outTx struct {
+			Hex  string `json:"hex"`
+			Psbt string `json:"psbt"`
+		}
+		if err := json.Unmarshal(content, &outTx); err != nil {
+			return nil, fmt.Errorf("error parsing JSON: %w", err)
+		}
+
+		txStr := outTx.Hex
+		if txStr == "" {
+			txStr = outTx.Psbt
+		}
+		tx, err := parseRebroadcastTx(txStr)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*wire.MsgTx{tx}, nil
+	}
+
+	var txs []*wire.MsgTx
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, len(content)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		tx, err := parseRebroadcastTx(line)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing TX %d: %w",
+				len(txs), err)
+		}
+		txs = append(txs, tx)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(txs) == 0 {
+		return nil, fmt.Errorf("no transactions found")
+	}
+
+	return txs, nil
+}
+
+// parseRebroadcastTx parses a signed transaction from raw hex or from a
+// finalized PSBT that is either base64 or hex encoded.
+func parseRebroadcastTx(txStr string) (*wire.MsgTx, error) {
+	txStr = strings.TrimSpace(txStr)
+	txBytes, err := hex.DecodeString(txStr)
+	switch {
+	case err == nil && bytes.HasPrefix(txBytes, psbtMagic):
+		return extractFinalTx(bytes.NewReader(txBytes), false)
+
+	case err == nil:
+		tx := &wire.MsgTx{}
+		if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+			return nil, fmt.Errorf("error parsing TX: %w", err)
+		}
+		return tx, nil
+
+	default:
+		return extractFinalTx(strings.NewReader(txStr), true)
+	}
+}
+
+// extractFinalTx extracts the signed transaction from a finalized PSBT.
+func extractFinalTx(r io.Reader, b64 bool) (*wire.MsgTx, error) {
+	packet, err := psbt.NewFromRawBytes(r, b64)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing TX or PSBT: %w", err)
+	}
+
+	tx, err := psbt.Extract(packet)
+	if err != nil {
+		return nil, fmt.Errorf("error extracting TX from PSBT, is it "+
+			"finalized? %w", err)
+	}
+
+	return tx, nil
+}
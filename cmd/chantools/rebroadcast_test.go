@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/stretchr/testify/require"
+)
+
+// rebroadcastTestTx returns a signed dummy transaction and its raw hex.
+func rebroadcastTestTx(t *testing.T, index uint32) (*wire.MsgTx, string) {
+	tx := wire.NewMsgTx(2)
+	tx.TxIn = []*wire.TxIn{{
+		PreviousOutPoint: wire.OutPoint{Index: index},
+		Witness:          wire.TxWitness{bytes.Repeat([]byte{1}, 72)},
+	}}
+	tx.TxOut = []*wire.TxOut{{
+		Value:    10_000,
+		PkScript: bytes.Repeat([]byte{2}, 22),
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, tx.Serialize(&buf))
+
+	return tx, hex.EncodeToString(buf.Bytes())
+}
+
+func TestRebroadcast(t *testing.T) {
+	h := newHarness(t)
+
+	confirmedTx, _ := rebroadcastTestTx(t, 0)
+	acceptedTx, acceptedHex := rebroadcastTestTx(t, 1)
+	knownTx, knownHex := rebroadcastTestTx(t, 2)
+	rejectedTx, rejectedHex := rebroadcastTestTx(t, 3)
+
+	api := newFakeChainBackend()
+	api.addTx(t, confirmedTx, &btc.Status{
+		Confirmed:   true,
+		BlockHeight: 123,
+	})
+	api.rejectTx(knownTx.TxHash().String(), errors.New(
+		"sendrawtransaction RPC error: {\"code\":-27,\"message\":"+
+			"\"Transaction already in block chain\"}",
+	))
+	api.rejectTx(rejectedTx.TxHash().String(), errors.New(
+		"sendrawtransaction RPC error: {\"code\":-26,\"message\":"+
+			"\"min relay fee not met\"}",
+	))
+
+	err := rebroadcast(api, []*wire.MsgTx{
+		confirmedTx, acceptedTx, knownTx, rejectedTx,
+	})
+	require.ErrorContains(t, err, "1 of 4 transactions were rejected")
+
+	// The confirmed transaction is never published again.
+	require.Equal(
+		t, []string{acceptedHex, knownHex, rejectedHex},
+		api.publishedTxs(),
+	)
+	h.assertLogContains(fmt.Sprintf(
+		"TX %v: already known, confirmed in block 123",
+		confirmedTx.TxHash(),
+	))
+	h.assertLogContains(fmt.Sprintf("TX %v: accepted", acceptedTx.TxHash()))
+	h.assertLogContains(fmt.Sprintf(
+		"TX %v: already known", knownTx.TxHash(),
+	))
+	h.assertLogContains(fmt.Sprintf(
+		"TX %v: rejected, reason: sendrawtransaction RPC error: "+
+			"{\"code\":-26,\"message\":\"min relay fee not met\"}",
+		rejectedTx.TxHash(),
+	))
+
+	// Running it again without the rejected transaction succeeds.
+	require.NoError(t, rebroadcast(api, []*wire.MsgTx{
+		confirmedTx, acceptedTx, knownTx,
+	}))
+}
+
+func TestParseRebroadcastFile(t *testing.T) {
+	tx1, tx1Hex := rebroadcastTestTx(t, 1)
+	tx2, tx2Hex := rebroadcastTestTx(t, 2)
+
+	packet, err := finalizedPsbt(tx1)
+	require.NoError(t, err)
+	packetB64, err := packet.B64Encode()
+	require.NoError(t, err)
+	var packetBuf bytes.Buffer
+	require.NoError(t, packet.Serialize(&packetBuf))
+
+	jsonContent, err := json.Marshal(&outputTx{
+		TXID: tx1.TxHash().String(),
+		Hex:  tx1Hex,
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		content string
+		txids   []chainhash.Hash
+		err     string
+	}{{
+		name:    "hex",
+		content: tx1Hex + "\n",
+		txids:   []chainhash.Hash{tx1.TxHash()},
+	}, {
+		name:    "hex one per line",
+		content: tx1Hex + "\n\n" + tx2Hex + "\n",
+		txids:   []chainhash.Hash{tx1.TxHash(), tx2.TxHash()},
+	}, {
+		name:    "psbt base64",
+		content: packetB64,
+		txids:   []chainhash.Hash{tx1.TxHash()},
+	}, {
+		name:    "psbt hex",
+		content: hex.EncodeToString(packetBuf.Bytes()),
+		txids:   []chainhash.Hash{tx1.TxHash()},
+	}, {
+		name:    "json",
+		content: string(jsonContent),
+		txids:   []chainhash.Hash{tx1.TxHash()},
+	}, {
+		name:    "empty",
+		content: "\n",
+		err:     "no transactions found",
+	}, {
+		name:    "invalid",
+		content: "not a transaction",
+		err:     "error parsing TX or PSBT",
+	}}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			txs, err := parseRebroadcastFile([]byte(tc.content))
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, txs, len(tc.txids))
+			for idx, tx := range txs {
+				require.Equal(t, tc.txids[idx], tx.TxHash())
+			}
+		})
+	}
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lntypes"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// loopKeyFamily is the key family Loop uses to derive the HTLC keys
+	// of a swap, see swap.KeyFamily in the Loop repository.
+	loopKeyFamily = keychain.KeyFamily(99)
+
+	defaultLoopNumTries = 1000
+)
+
+type recoverLoopInCommand struct {
+	APIURL         string
+	Outpoint       string
+	SwapHash       string
+	ServerKey      string
+	CltvExpiry     uint32
+	NumTries       uint32
+	SweepAddr      string
+	DestDescriptor string
+	DestIndex      uint32
+	DestGapLimit   uint32
+	FeeRate        uint16
+	ConfTarget     uint32
+	Publish        bool
+	DryRun         bool
+	Psbt           bool
+
+	rootKey *rootKey
+	cmd     *cobra.Command
+}
+
+func newRecoverLoopInCommand() *cobra.Command {
+	cc := &recoverLoopInCommand{}
+	cc.cmd = &cobra.Command{
+		Use:   "recoverloopin",
+		Short: "Sweep the on-chain HTLC of a failed Loop In swap",
+		Long: `If a Loop In swap fails, the on-chain HTLC output that
+was published by the client can be swept back to the client's wallet through
+the timeout path of the HTLC once the CLTV expiry height is reached. Usually
+loopd does that automatically, this command can be used if that's not
+possible anymore.
+
+The swap hash, the CLTV expiry height and the server's HTLC key can be found in
+the loop.db or the output of 'loop listswaps'. The client's HTLC key is derived
+from the node's seed, the first --numtries keys of the Loop key family are tried
+until the one is found that the HTLC output pays to. The command refuses to
+create the transaction if the HTLC script doesn't match the output or if the
+CLTV expiry height isn't reached yet.
+
+Only HTLCs of version 2 (P2WSH and NP2WSH) are supported. Taproot HTLCs of
+newer Loop versions can't be swept with this command.
+
+With the --psbt flag, an unsigned PSBT is created instead of a signed
+transaction. See the sweeptimelock command for the details.`,
+		Example: `chantools recoverloopin \
+	--outpoint xxxxxxxxx:y \
+	--swaphash xxxxxxxxx \
+	--serverkey 03xxxxxxx \
+	--cltvexpiry 700000 \
+	--sweepaddr bc1q..... \
+	--feerate 10 \
+	--publish`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
+			"be esplora compatible)",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.Outpoint, "outpoint", "", "the outpoint of the HTLC "+
+			"output to sweep (<txid>:<txindex>)",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.SwapHash, "swaphash", "", "the hex encoded hash of the "+
+			"swap",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.ServerKey, "serverkey", "", "the hex encoded HTLC public "+
+			"key of the Loop server (the receiver of the HTLC)",
+	)
+	cc.cmd.Flags().Uint32Var(
+		&cc.CltvExpiry, "cltvexpiry", 0, "the block height at which "+
+			"the HTLC times out",
+	)
+	cc.cmd.Flags().Uint32Var(
+		&cc.NumTries, "numtries", defaultLoopNumTries, "the number "+
+			"of HTLC key indices to try at most",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.SweepAddr, "sweepaddr", "", "address to sweep the funds to",
+	)
+	addDestDescriptorFlags(
+		cc.cmd, &cc.DestDescriptor, &cc.DestIndex, &cc.DestGapLimit,
+	)
+	cc.cmd.Flags().Uint16Var(
+		&cc.FeeRate, "feerate", defaultFeeSatPerVByte, "fee rate to "+
+			"use for the sweep transaction in sat/vByte",
+	)
+	addConfTargetFlag(cc.cmd, &cc.ConfTarget)
+	cc.cmd.Flags().BoolVar(
+		&cc.Publish, "publish", false, "publish sweep TX to the chain "+
+			"API instead of just printing the TX",
+	)
+	addDryRunFlag(cc.cmd, &cc.DryRun)
+	addPsbtFlag(cc.cmd, &cc.Psbt)
+
+	cc.rootKey = newRootKey(cc.cmd, "deriving the HTLC key")
+
+	return cc.cmd
+}
+
+func (c *recoverLoopInCommand) Execute(_ *cobra.Command, _ []string) error {
+	extendedKey, err := c.rootKey.read()
+	if err != nil {
+		return fmt.Errorf("error reading root key: %w", err)
+	}
+
+	// The sweep address can also be derived from a descriptor.
+	c.SweepAddr, err = sweepDestination(
+		c.SweepAddr, c.DestDescriptor, c.DestIndex, c.DestGapLimit,
+		c.APIURL,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Make sure all required flags are set.
+	switch {
+	case c.Outpoint == "":
+		return fmt.Errorf("HTLC outpoint is required")
+
+	case c.SwapHash == "":
+		return fmt.Errorf("swap hash is required")
+
+	case c.ServerKey == "":
+		return fmt.Errorf("server key is required")
+
+	case c.CltvExpiry == 0:
+		return fmt.Errorf("CLTV expiry is required")
+
+	case c.SweepAddr == "":
+		return fmt.Errorf("sweep addr is required")
+	}
+	if c.Psbt && c.Publish {
+		return fmt.Errorf("cannot publish a PSBT, it must be signed " +
+			"first")
+	}
+
+	outpoint, err := lnd.ParseOutpoint(c.Outpoint)
+	if err != nil {
+		return fmt.Errorf("error parsing HTLC outpoint: %w", err)
+	}
+
+	swapHash, err := lntypes.MakeHashFromStr(c.SwapHash)
+	if err != nil {
+		return fmt.Errorf("error parsing swap hash: %w", err)
+	}
+
+	serverKeyBytes, err := hex.DecodeString(c.ServerKey)
+	if err != nil {
+		return fmt.Errorf("error decoding server key: %w", err)
+	}
+	serverKey, err := btcec.ParsePubKey(serverKeyBytes)
+	if err != nil {
+		return fmt.Errorf("error parsing server key: %w", err)
+	}
+
+	// Set default values.
+	if c.NumTries == 0 {
+		c.NumTries = defaultLoopNumTries
+	}
+	if c.FeeRate == 0 {
+		c.FeeRate = defaultFeeSatPerVByte
+	}
+	c.FeeRate = sweepFeeRate(c.APIURL, c.ConfTarget, c.FeeRate)
+
+	api, err := newChainBackend(c.APIURL)
+	if err != nil {
+		return err
+	}
+	publish := shouldPublish(c.Publish, c.DryRun, c.APIURL)
+	return recoverLoopIn(
+		extendedKey, api, outpoint, swapHash, serverKey, c.CltvExpiry,
+		c.NumTries, c.SweepAddr, c.FeeRate, publish, c.Psbt,
+	)
+}
+
+func recoverLoopIn(extendedKey *hdkeychain.ExtendedKey, api btc.ChainBackend,
+	outpoint *wire.OutPoint, swapHash lntypes.Hash,
+	serverKey *btcec.PublicKey, cltvExpiry, numTries uint32,
+	sweepAddr string, feeRate uint16, publish, createPsbt bool) error {
+
+	tx, err := api.Transaction(outpoint.Hash.String())
+	if err != nil {
+		return fmt.Errorf("error looking up TX %s: %w",
+			outpoint.Hash.String(), err)
+	}
+	if int(outpoint.Index) >= len(tx.Vout) {
+		return fmt.Errorf("TX %s has no output with index %d",
+			outpoint.Hash.String(), outpoint.Index)
+	}
+
+	txOut := tx.Vout[outpoint.Index]
+	if txOut.Outspend != nil && txOut.Outspend.Spent {
+		return fmt.Errorf("HTLC %v is already spent", outpoint)
+	}
+
+	pkScript, err := hex.DecodeString(txOut.ScriptPubkey)
+	if err != nil {
+		return fmt.Errorf("error decoding pk script %s: %w",
+			txOut.ScriptPubkey, err)
+	}
+
+	// Find our HTLC key by trying to re-create the HTLC script.
+	keyRing := &lnd.HDKeyRing{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+	htlc, err := findLoopInHtlc(
+		keyRing, swapHash, serverKey, cltvExpiry, numTries, pkScript,
+	)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Found HTLC key with index %d, HTLC is %s",
+		htlc.keyDesc.Index, htlc.outputType)
+
+	// The HTLC can only be swept by us once the CLTV has expired.
+	bestHeight, err := api.BlockHeight()
+	if err != nil {
+		return fmt.Errorf("error querying best block height: %w", err)
+	}
+	matured, blocksLeft := cltvMatured(cltvExpiry, bestHeight)
+	if !matured {
+		return fmt.Errorf("HTLC times out at block height %d, time "+
+			"lock expires in %d block(s), run the command again "+
+			"then", cltvExpiry, blocksLeft)
+	}
+
+	sweepScript, err := lnd.GetP2WPKHScript(sweepAddr, chainParams)
+	if err != nil {
+		return err
+	}
+
+	// The sequence must not be final for the CLTV to be enforced.
+	sweepValue := int64(txOut.Value)
+	sweepTx := wire.NewMsgTx(2)
+	sweepTx.LockTime = cltvExpiry
+	sweepTx.TxIn = []*wire.TxIn{{
+		PreviousOutPoint: *outpoint,
+		Sequence:         wire.MaxTxInSequenceNum - 1,
+		SignatureScript:  htlc.sigScript,
+	}}
+
+	// Calculate the fee based on the given fee rate and our weight
+	// estimation.
+	var estimator input.TxWeightEstimator
+	witnessSize := loopHtlcTimeoutWitnessSize(htlc.witnessScript)
+	if htlc.sigScript != nil {
+		estimator.AddNestedP2WSHInput(witnessSize)
+	} else {
+		estimator.AddWitnessInput(witnessSize)
+	}
+	estimator.AddP2WKHOutput()
+	feeRateKWeight := chainfee.SatPerKVByte(1000 * feeRate).FeePerKWeight()
+	totalFee := feeRateKWeight.FeeForWeight(int64(estimator.Weight()))
+	if sweepValue-int64(totalFee) < sweepDustLimit {
+		return fmt.Errorf("fee of %d sats would leave an output "+
+			"below the dust limit of %d", totalFee, sweepDustLimit)
+	}
+
+	sweepTx.TxOut = []*wire.TxOut{{
+		Value:    sweepValue - int64(totalFee),
+		PkScript: sweepScript,
+	}}
+
+	log.Infof("Fee %d sats of %d total amount (estimated weight %d)",
+		totalFee, sweepValue, estimator.Weight())
+
+	signDesc := &input.SignDescriptor{
+		KeyDesc:       *htlc.keyDesc,
+		WitnessScript: htlc.witnessScript,
+		Output: &wire.TxOut{
+			PkScript: pkScript,
+			Value:    sweepValue,
+		},
+		InputIndex: 0,
+		SigHashes:  input.NewTxSigHashesV0Only(sweepTx),
+		HashType:   txscript.SigHashAll,
+	}
+	signDescs := []*input.SignDescriptor{signDesc}
+	err = validateSweepTx(
+		api, sweepTx, signDescs, int64(estimator.VSize()), feeRate,
+	)
+	if err != nil {
+		return fmt.Errorf("error validating sweep TX: %w", err)
+	}
+
+	// Instead of signing the transaction ourselves, we create a PSBT that
+	// can be signed on another machine.
+	if createPsbt {
+		sweepTx.TxIn[0].SignatureScript = nil
+		packet, err := newSweepPsbt(extendedKey, sweepTx, signDescs)
+		if err != nil {
+			return err
+		}
+
+		// A nested P2WSH input also needs the redeem script.
+		if htlc.sigScript != nil {
+			packet.Inputs[0].WitnessScript = htlc.witnessScript
+			packet.Inputs[0].RedeemScript = htlc.sigScript[1:]
+		}
+		return logPsbt(packet)
+	}
+
+	signer := &lnd.Signer{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+	sig, err := signer.SignOutputRaw(sweepTx, signDesc)
+	if err != nil {
+		return fmt.Errorf("error signing sweep TX: %w", err)
+	}
+	sweepTx.TxIn[0].Witness = loopHtlcTimeoutWitness(
+		append(sig.Serialize(), byte(signDesc.HashType)),
+		htlc.keyDesc.PubKey, htlc.witnessScript,
+	)
+
+	return publishTx(api, sweepTx, int64(totalFee), publish)
+}
+
+// loopInHtlc is the HTLC of a Loop In swap that pays to one of our keys.
+type loopInHtlc struct {
+	keyDesc       *keychain.KeyDescriptor
+	witnessScript []byte
+	outputType    string
+
+	// sigScript is only set for nested P2WSH outputs.
+	sigScript []byte
+}
+
+// findLoopInHtlc tries the first numTries keys of the Loop key family to find
+// the one that is used as the sender key in the HTLC script that the given
+// pkScript pays to.
+func findLoopInHtlc(keyRing *lnd.HDKeyRing, swapHash lntypes.Hash,
+	serverKey *btcec.PublicKey, cltvExpiry, numTries uint32,
+	pkScript []byte) (*loopInHtlc, error) {
+
+	for idx := uint32(0); idx < numTries; idx++ {
+		keyDesc, err := keyRing.DeriveKey(keychain.KeyLocator{
+			Family: loopKeyFamily,
+			Index:  idx,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error deriving HTLC key: %w",
+				err)
+		}
+
+		witnessScript, err := loopHtlcScriptV2(
+			cltvExpiry, keyDesc.PubKey, serverKey, swapHash,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		p2wshScript, err := input.WitnessScriptHash(witnessScript)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(pkScript, p2wshScript) {
+			return &loopInHtlc{
+				keyDesc:       &keyDesc,
+				witnessScript: witnessScript,
+				outputType:    "P2WSH",
+			}, nil
+		}
+
+		np2wshScript, err := input.GenerateP2SH(p2wshScript)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(pkScript, np2wshScript) {
+			sigScript, err := txscript.NewScriptBuilder().AddData(
+				p2wshScript,
+			).Script()
+			if err != nil {
+				return nil, err
+			}
+
+			return &loopInHtlc{
+				keyDesc:       &keyDesc,
+				witnessScript: witnessScript,
+				outputType:    "NP2WSH",
+				sigScript:     sigScript,
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("none of the first %d HTLC keys matches the "+
+		"output script %x, check the swap hash, server key and CLTV "+
+		"expiry", numTries, pkScript)
+}
+
+// loopHtlcScriptV2 returns the witness script of a version 2 Loop HTLC, see
+// swap/htlc.go in the Loop repository:
+//
+//	<receiverHtlcKey> OP_CHECKSIG OP_NOTIF
+//	  OP_DUP OP_HASH160 <HASH160(senderHtlcKey)> OP_EQUALVERIFY
+//	  OP_CHECKSIGVERIFY <cltv timeout> OP_CHECKLOCKTIMEVERIFY
+//	OP_ELSE
+//	  OP_SIZE <32> OP_EQUALVERIFY OP_HASH160 <ripemd(swapHash)>
+//	  OP_EQUALVERIFY 1 OP_CHECKSEQUENCEVERIFY
+//	OP_ENDIF
+func loopHtlcScriptV2(cltvExpiry uint32, senderKey,
+	receiverKey *btcec.PublicKey, swapHash lntypes.Hash) ([]byte, error) {
+
+	builder := txscript.NewScriptBuilder()
+	builder.AddData(receiverKey.SerializeCompressed())
+	builder.AddOp(txscript.OP_CHECKSIG)
+	builder.AddOp(txscript.OP_NOTIF)
+	builder.AddOp(txscript.OP_DUP)
+	builder.AddOp(txscript.OP_HASH160)
+	builder.AddData(btcutil.Hash160(senderKey.SerializeCompressed()))
+	builder.AddOp(txscript.OP_EQUALVERIFY)
+	builder.AddOp(txscript.OP_CHECKSIGVERIFY)
+	builder.AddInt64(int64(cltvExpiry))
+	builder.AddOp(txscript.OP_CHECKLOCKTIMEVERIFY)
+	builder.AddOp(txscript.OP_ELSE)
+	builder.AddOp(txscript.OP_SIZE)
+	builder.AddInt64(32)
+	builder.AddOp(txscript.OP_EQUALVERIFY)
+	builder.AddOp(txscript.OP_HASH160)
+	builder.AddData(input.Ripemd160H(swapHash[:]))
+	builder.AddOp(txscript.OP_EQUALVERIFY)
+	builder.AddInt64(1)
+	builder.AddOp(txscript.OP_CHECKSEQUENCEVERIFY)
+	builder.AddOp(txscript.OP_ENDIF)
+
+	return builder.Script()
+}
+
+// loopHtlcTimeoutWitness returns the witness that spends a version 2 Loop HTLC
+// through the timeout path. The empty element makes the receiver's signature
+// check fail, which selects the timeout branch.
+func loopHtlcTimeoutWitness(senderSig []byte, senderKey *btcec.PublicKey,
+	witnessScript []byte) wire.TxWitness {
+
+	return wire.TxWitness{
+		senderSig,
+		senderKey.SerializeCompressed(),
+		{},
+		witnessScript,
+	}
+}
+
+// loopHtlcTimeoutWitnessSize returns the size of the timeout witness for the
+// given witness script.
+func loopHtlcTimeoutWitnessSize(witnessScript []byte) int {
+	return 1 + // Number of witness elements.
+		1 + 73 + // Sender signature.
+		1 + 33 + // Sender key.
+		1 + // Empty element.
+		1 + len(witnessScript)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lntypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverLoopIn(t *testing.T) {
+	h := newHarness(t)
+
+	rootKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	serverPrivKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	serverKey := serverPrivKey.PubKey()
+
+	// The HTLC pays to our Loop key with index 3.
+	const cltvExpiry = 700_000
+	swapHash := lntypes.Hash{1, 2, 3}
+	keyDesc, err := (&lnd.HDKeyRing{
+		ExtendedKey: rootKey,
+		ChainParams: chainParams,
+	}).DeriveKey(keychain.KeyLocator{Family: loopKeyFamily, Index: 3})
+	require.NoError(t, err)
+	witnessScript, err := loopHtlcScriptV2(
+		cltvExpiry, keyDesc.PubKey, serverKey, swapHash,
+	)
+	require.NoError(t, err)
+	p2wshScript, err := input.WitnessScriptHash(witnessScript)
+	require.NoError(t, err)
+	np2wshScript, err := input.GenerateP2SH(p2wshScript)
+	require.NoError(t, err)
+
+	htlcTx := wire.NewMsgTx(2)
+	htlcTx.TxIn = []*wire.TxIn{{}}
+	htlcTx.TxOut = []*wire.TxOut{{
+		Value:    100_000,
+		PkScript: p2wshScript,
+	}, {
+		Value:    100_000,
+		PkScript: np2wshScript,
+	}}
+
+	api := newFakeChainBackend()
+	api.addTx(t, htlcTx, &btc.Status{})
+	api.setHeight(699_990)
+
+	sweepAddr, err := lnd.P2WKHAddr(keyDesc.PubKey, chainParams)
+	require.NoError(t, err)
+	sweep := func(index uint32, hash lntypes.Hash) error {
+		return recoverLoopIn(
+			rootKey, api, &wire.OutPoint{
+				Hash:  htlcTx.TxHash(),
+				Index: index,
+			}, hash, serverKey, cltvExpiry, 5, sweepAddr.String(),
+			10, false, false,
+		)
+	}
+
+	// The HTLC can't be swept before the CLTV expired.
+	err = sweep(0, swapHash)
+	require.ErrorContains(t, err, "expires in 10 block(s)")
+
+	// A wrong swap hash results in a different script.
+	api.setHeight(cltvExpiry)
+	err = sweep(0, lntypes.Hash{3, 2, 1})
+	require.ErrorContains(t, err, "none of the first 5 HTLC keys")
+
+	for index, outputType := range []string{"P2WSH", "NP2WSH"} {
+		h.clearLog()
+		require.NoError(t, sweep(uint32(index), swapHash))
+		h.assertLogContains("Found HTLC key with index 3, HTLC is " +
+			outputType)
+
+		logLines := strings.Split(strings.TrimSpace(h.getLog()), "\n")
+		lastLine := logLines[len(logLines)-1]
+		txHex := lastLine[strings.LastIndex(lastLine, " ")+1:]
+		sweepTx, err := parseSweepTx(api, txHex)
+		require.NoError(t, err)
+		require.EqualValues(t, cltvExpiry, sweepTx.LockTime)
+
+		// The timeout path must be signed correctly.
+		prevOut := htlcTx.TxOut[index]
+		prevOuts := txscript.NewCannedPrevOutputFetcher(
+			prevOut.PkScript, prevOut.Value,
+		)
+		vm, err := txscript.NewEngine(
+			prevOut.PkScript, sweepTx, 0,
+			txscript.StandardVerifyFlags, nil,
+			txscript.NewTxSigHashes(sweepTx, prevOuts),
+			prevOut.Value, prevOuts,
+		)
+		require.NoError(t, err)
+		require.NoError(t, vm.Execute())
+	}
+}
@@ -1,20 +1,29 @@
 package main
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
-	"strconv"
-	"strings"
+	"io"
+	"os"
+	"time"
 
-	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/guggero/chantools/lnd"
 	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/kvdb"
 	"github.com/spf13/cobra"
 )
 
+var (
+	closedChannelBucket     = []byte("closed-chan-bucket")
+	historicalChannelBucket = []byte("historical-chan-bucket")
+)
+
 type removeChannelCommand struct {
-	ChannelDB string
-	Channel   string
+	ChannelDB         string
+	Channel           string
+	AllowPendingClose bool
 
 	cmd *cobra.Command
 }
@@ -27,18 +36,28 @@ func newRemoveChannelCommand() *cobra.Command {
 		Long: `Opens the given channel DB in write mode and removes one
 single channel from it. This means giving up on any state (and therefore coins)
 of that channel and should only be used if the funding transaction of the
-channel was never confirmed on chain!
+channel was never confirmed on chain or if the channel's state is corrupt and
+prevents lnd from starting!
+
+Before anything is changed, a backup copy of the channel DB is written next to
+it. The channel is then removed from the open and closed channel buckets and
+its edge is removed from the channel graph.
+
+If the channel still has a pending on-chain resolution (a closing transaction
+was already published or the close isn't fully resolved yet), the command
+refuses to remove it unless --allow_pending_close is set. Removing such a channel means lnd
+won't sweep any of the funds of that channel anymore.
 
 CAUTION: Running this command will make it impossible to use the channel DB
 with an older version of lnd. Downgrading is not possible and you'll need to
-run lnd v0.15.1-beta or later after using this command!`,
+run lnd v0.17.5-beta or later after using this command!`,
 		Example: `chantools removechannel \
 	--channeldb ~/.lnd/data/graph/mainnet/channel.db \
 	--channel 3149764effbe82718b280de425277e5e7b245a4573aa4a0203ac12cee1c37816:0`,
 		RunE: cc.Execute,
 	}
 	cc.cmd.Flags().StringVar(
-		&cc.ChannelDB, "channeldb", "", "lnd channel.backup file to "+
+		&cc.ChannelDB, "channeldb", "", "lnd channel.db file to "+
 			"remove the channel from",
 	)
 	cc.cmd.Flags().StringVar(
@@ -46,6 +65,12 @@ run lnd v0.15.1-beta or later after using this command!`,
 			"file, identified by its channel point "+
 			"(<txid>:<txindex>)",
 	)
+	cc.cmd.Flags().BoolVar(
+		&cc.AllowPendingClose, "allow_pending_close", false, "also "+
+			"remove the channel if it still has a pending "+
+			"on-chain resolution; lnd won't sweep the funds of "+
+			"the channel anymore",
+	)
 
 	return cc.cmd
 }
@@ -55,51 +80,178 @@ func (c *removeChannelCommand) Execute(_ *cobra.Command, _ []string) error {
 	if c.ChannelDB == "" {
 		return fmt.Errorf("channel DB is required")
 	}
+
+	chanPoint, err := lnd.ParseOutpoint(c.Channel)
+	if err != nil {
+		return fmt.Errorf("error parsing channel point: %w", err)
+	}
+
+	// Opening the DB in write mode might already change the file, so we
+	// create the backup first.
+	backupFile, err := backupDBFile(c.ChannelDB)
+	if err != nil {
+		return fmt.Errorf("error creating backup of channel DB: %w",
+			err)
+	}
+
+	// If we can't open the DB, lnd is most likely still running and
+	// writing to it, so the copy can't be trusted.
 	db, err := lnd.OpenDB(c.ChannelDB, false)
 	if err != nil {
+		_ = os.Remove(backupFile)
 		return fmt.Errorf("error opening channel DB: %w", err)
 	}
 	defer func() {
 		if err := db.Close(); err != nil {
-			log.Errorf("Error closing DB: %w", err)
+			log.Errorf("Error closing DB: %v", err)
 		}
 	}()
+	log.Infof("Wrote backup of channel DB to %s", backupFile)
 
-	parts := strings.Split(c.Channel, ":")
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid channel point format: %v", c.Channel)
+	return removeChannel(db, chanPoint, c.AllowPendingClose)
+}
+
+// removeChannel removes the channel with the given channel point from the open
+// and closed channel buckets and its edge from the channel graph. A channel
+// that still has a pending on-chain resolution is only removed if
+// allowPendingClose is set.
+func removeChannel(db *channeldb.DB, chanPoint *wire.OutPoint,
+	allowPendingClose bool) error {
+
+	chanStateDB := db.ChannelStateDB()
+	dbChan, err := chanStateDB.FetchChannel(nil, *chanPoint)
+	if err != nil && err != channeldb.ErrChannelNotFound {
+		return fmt.Errorf("error fetching open channel: %w", err)
 	}
-	hash, err := chainhash.NewHashFromStr(parts[0])
-	if err != nil {
-		return err
+	closeSummary, err := chanStateDB.FetchClosedChannel(chanPoint)
+	if err != nil && err != channeldb.ErrClosedChannelNotFound {
+		return fmt.Errorf("error fetching closed channel: %w", err)
+	}
+	if dbChan == nil && closeSummary == nil {
+		return fmt.Errorf("channel %v not found in channel DB",
+			chanPoint)
+	}
+
+	pendingReason := ""
+	switch {
+	case dbChan != nil &&
+		dbChan.HasChanStatus(channeldb.ChanStatusCommitBroadcasted):
+
+		pendingReason = "commitment transaction was published"
+
+	case dbChan != nil &&
+		dbChan.HasChanStatus(channeldb.ChanStatusCoopBroadcasted):
+
+		pendingReason = "cooperative close transaction was published"
+
+	case closeSummary != nil && closeSummary.IsPending:
+		pendingReason = "channel close is not fully resolved"
 	}
-	index, err := strconv.ParseUint(parts[1], 10, 64)
+	if pendingReason != "" {
+		if !allowPendingClose {
+			return fmt.Errorf("channel %v still has a pending "+
+				"on-chain resolution (%s), use "+
+				"--allow_pending_close to remove it anyway",
+				chanPoint, pendingReason)
+		}
+
+		log.Warnf("Removing channel %v with a pending on-chain "+
+			"resolution (%s), lnd won't sweep its funds anymore",
+			chanPoint, pendingReason)
+	}
+
+	if dbChan != nil {
+		log.Infof("Removing open channel %v", chanPoint)
+		if err := dbChan.MarkBorked(); err != nil {
+			return err
+		}
+
+		// Abandoning a channel is a three step process: remove from
+		// the open channel state, remove from the graph, remove from
+		// the contract court. Between any step it's possible that the
+		// users restarts the process all over again. As a result, each
+		// of the steps below are intended to be idempotent.
+		err := chanStateDB.AbandonChannel(chanPoint, uint32(100000))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Abandoning the channel created a close summary and a historical
+	// copy of the channel, we remove both of them as well.
+	log.Infof("Removing closed channel summary of %v", chanPoint)
+	err = kvdb.Update(db, func(tx kvdb.RwTx) error {
+		var chanKey bytes.Buffer
+		_, _ = chanKey.Write(chanPoint.Hash[:])
+		err := binary.Write(&chanKey, binary.BigEndian, chanPoint.Index)
+		if err != nil {
+			return err
+		}
+
+		key := chanKey.Bytes()
+
+		closedBucket := tx.ReadWriteBucket(closedChannelBucket)
+		if closedBucket != nil {
+			if err := closedBucket.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		hist := tx.ReadWriteBucket(historicalChannelBucket)
+		if hist == nil || hist.NestedReadBucket(key) == nil {
+			return nil
+		}
+
+		return hist.DeleteNestedBucket(key)
+	}, func() {})
 	if err != nil {
-		return err
+		return fmt.Errorf("error removing closed channel: %w", err)
+	}
+
+	graph := db.ChannelGraph()
+	chanID, err := graph.ChannelID(chanPoint)
+	switch {
+	case err == channeldb.ErrEdgeNotFound:
+		log.Infof("Channel %v not found in graph", chanPoint)
+		return nil
+
+	case err != nil:
+		return fmt.Errorf("error looking up channel %v in graph: %w",
+			chanPoint, err)
 	}
 
-	return removeChannel(db.ChannelStateDB(), &wire.OutPoint{
-		Hash:  *hash,
-		Index: uint32(index),
-	})
+	return removeSingleChannel(graph, chanID, nil, false)
 }
 
-func removeChannel(db *channeldb.ChannelStateDB,
-	chanPoint *wire.OutPoint) error {
+// backupDBFile copies the given DB file to a new file with the current time in
+// its name in the same directory and returns the name of the copy.
+func backupDBFile(dbFile string) (string, error) {
+	backupFile := fmt.Sprintf("%s.%s.bak", dbFile,
+		time.Now().Format("2006-01-02-15-04-05"))
+
+	src, err := os.Open(dbFile)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = src.Close() }()
 
-	dbChan, err := db.FetchChannel(nil, *chanPoint)
+	dest, err := os.OpenFile(
+		backupFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600,
+	)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	if err := dbChan.MarkBorked(); err != nil {
-		return err
+	_, err = io.Copy(dest, src)
+	if err == nil {
+		err = dest.Sync()
+	}
+	if closeErr := dest.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
 	}
 
-	// Abandoning a channel is a three step process: remove from the open
-	// channel state, remove from the graph, remove from the contract
-	// court. Between any step it's possible that the users restarts the
-	// process all over again. As a result, each of the steps below are
-	// intended to be idempotent.
-	return db.AbandonChannel(chanPoint, uint32(100000))
+	return backupFile, nil
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	removeTestChannel        = "10279f62619634058b6133cb7ac6c1693a8e6df7caa91c6263ca3d0bf704ad4d:0"
+	removeTestPendingChannel = "9e7004ccf0cb19eb2d967aa0142e3476b4d27874da7048e61b61fdbacf9200d3:0"
+)
+
+func TestRemoveChannel(t *testing.T) {
+	h := newHarness(t)
+
+	// We don't want to modify the test DB, so we work on a copy.
+	dbContent, err := ioutil.ReadFile(h.testdataFile("channel.db"))
+	require.NoError(t, err)
+	dbFile := h.tempFile("channel.db")
+	require.NoError(t, ioutil.WriteFile(dbFile, dbContent, 0600))
+
+	remove := &removeChannelCommand{
+		ChannelDB: dbFile,
+		Channel:   removeTestChannel,
+	}
+	require.NoError(t, remove.Execute(nil, nil))
+	h.assertLogContains("Removing open channel " + removeTestChannel)
+
+	// A backup of the unmodified DB was written next to it.
+	backups, err := filepath.Glob(dbFile + ".*.bak")
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	backupContent, err := ioutil.ReadFile(backups[0])
+	require.NoError(t, err)
+	require.Equal(t, dbContent, backupContent)
+
+	db, err := lnd.OpenDB(dbFile, false)
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	// The channel is neither an open nor a closed channel anymore, so it
+	// can't be removed a second time.
+	chanPoint, err := lnd.ParseOutpoint(removeTestChannel)
+	require.NoError(t, err)
+	chanStateDB := db.ChannelStateDB()
+	_, err = chanStateDB.FetchChannel(nil, *chanPoint)
+	require.ErrorIs(t, err, channeldb.ErrChannelNotFound)
+	_, err = chanStateDB.FetchClosedChannel(chanPoint)
+	require.ErrorIs(t, err, channeldb.ErrClosedChannelNotFound)
+	_, err = chanStateDB.FetchHistoricalChannel(chanPoint)
+	require.ErrorIs(t, err, channeldb.ErrChannelNotFound)
+
+	err = removeChannel(db, chanPoint, false)
+	require.ErrorContains(t, err, "not found in channel DB")
+
+	// A channel with a published closing transaction is only removed with
+	// the --allow_pending_close flag.
+	chanPoint, err = lnd.ParseOutpoint(removeTestPendingChannel)
+	require.NoError(t, err)
+	dbChan, err := chanStateDB.FetchChannel(nil, *chanPoint)
+	require.NoError(t, err)
+	require.NoError(t, dbChan.MarkCoopBroadcasted(wire.NewMsgTx(2), true))
+
+	err = removeChannel(db, chanPoint, false)
+	require.ErrorContains(t, err, "pending on-chain resolution")
+	_, err = chanStateDB.FetchChannel(nil, *chanPoint)
+	require.NoError(t, err)
+
+	require.NoError(t, removeChannel(db, chanPoint, true))
+	h.assertLogContains("lnd won't sweep its funds anymore")
+	_, err = chanStateDB.FetchChannel(nil, *chanPoint)
+	require.ErrorIs(t, err, channeldb.ErrChannelNotFound)
+}
@@ -2,22 +2,27 @@ package main
 
 import (
 	"crypto/subtle"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/btc"
 	"github.com/guggero/chantools/dataformat"
 	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/chanbackup"
 	"github.com/lightningnetwork/lnd/channeldb"
 	"github.com/lightningnetwork/lnd/input"
 	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnwallet"
 	"github.com/spf13/cobra"
 )
 
@@ -40,6 +45,8 @@ type rescueClosedCommand struct {
 	Addr        string
 	CommitPoint string
 	LndLog      string
+	MultiFile   string
+	APIURL      string
 
 	rootKey *rootKey
 	inputs  *inputFlags
@@ -71,7 +78,22 @@ specify the --fromsummary and --lnd_log flags to automatically look for force
 close addresses in the summary and the corresponding commit points in the
 lnd log file. This only works if lnd is running the fund-recovery branch of my
 guggero/lnd (https://github.com/guggero/lnd/releases) fork and only if the
-debuglevel is set to debug (lnd.conf, set 'debuglevel=debug').`,
+debuglevel is set to debug (lnd.conf, set 'debuglevel=debug').
+
+If a channel.backup file is available, the --multi_file flag can be used to
+automatically look up the closing transaction of every channel in the backup
+with the chain backend. For channels of the STATIC_REMOTE_KEY, ANCHOR and
+SIMPLE_TAPROOT types the output that belongs to us in a commitment transaction
+of the remote peer isn't tweaked with a commit point, so the private key can be
+derived directly from the payment base point in the backup. The commitment
+number the channel was closed at is reported as well.
+
+Please note that the commit point of older, tweaked channels can NOT be derived
+from the root key or the channel.backup file, not even by scanning a range of
+commitment numbers. The output that belongs to us in a commitment transaction
+of the remote peer is tweaked with the remote peer's per commitment point,
+which is derived from a secret only the remote peer knows. For those channels
+the --commit_point or --lnd_log flags are needed.`,
 		Example: `chantools rescueclosed \
 	--fromsummary results/summary-xxxxxx.json \
 	--channeldb ~/.lnd/data/graph/mainnet/channel.db
@@ -79,7 +101,10 @@ debuglevel is set to debug (lnd.conf, set 'debuglevel=debug').`,
 chantools rescueclosed --force_close_addr bc1q... --commit_point 03xxxx
 
 chantools rescueclosed --fromsummary results/summary-xxxxxx.json \
-	--lnd_log ~/.lnd/logs/bitcoin/mainnet/lnd.log`,
+	--lnd_log ~/.lnd/logs/bitcoin/mainnet/lnd.log
+
+chantools rescueclosed \
+	--multi_file ~/.lnd/data/chain/bitcoin/mainnet/channel.backup`,
 		RunE: cc.Execute,
 	}
 	cc.cmd.Flags().StringVar(
@@ -99,6 +124,15 @@ chantools rescueclosed --fromsummary results/summary-xxxxxx.json \
 		&cc.LndLog, "lnd_log", "", "the lnd log file to read to get "+
 			"the commit_point values when rescuing multiple "+
 			"channels at the same time")
+	cc.cmd.Flags().StringVar(
+		&cc.MultiFile, "multi_file", "", "lnd channel.backup file "+
+			"(or a directory of channel backup files) to look up "+
+			"the closing transactions of all channels for",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
+			"be esplora compatible)",
+	)
 	cc.rootKey = newRootKey(cc.cmd, "decrypting the backup")
 	cc.inputs = newInputFlags(cc.cmd)
 
@@ -114,6 +148,23 @@ func (c *rescueClosedCommand) Execute(_ *cobra.Command, _ []string) error {
 	// What way of recovery has the user chosen? From summary and DB or from
 	// address and commit point?
 	switch {
+	case c.MultiFile != "":
+		keyRing := &lnd.HDKeyRing{
+			ExtendedKey: extendedKey,
+			ChainParams: chainParams,
+		}
+		multi, err := extractMulti(c.MultiFile, keyRing)
+		if err != nil {
+			return err
+		}
+
+		api, err := newChainBackend(c.APIURL)
+		if err != nil {
+			return err
+		}
+
+		return rescueClosedFromBackup(extendedKey, api, multi)
+
 	case c.ChannelDB != "":
 		db, err := lnd.OpenDB(c.ChannelDB, true)
 		if err != nil {
@@ -168,9 +219,9 @@ func (c *rescueClosedCommand) Execute(_ *cobra.Command, _ []string) error {
 		return rescueClosedChannels(extendedKey, entries, commitPoints)
 
 	default:
-		return fmt.Errorf("you either need to specify --channeldb and " +
-			"--fromsummary or --force_close_addr and " +
-			"--commit_point but not a mixture of them")
+		return fmt.Errorf("you either need to specify --multi_file, " +
+			"--channeldb and --fromsummary or --force_close_addr " +
+			"and --commit_point but not a mixture of them")
 	}
 }
 
@@ -367,6 +418,202 @@ func rescueClosedChannel(extendedKey *hdkeychain.ExtendedKey,
 	}
 }
 
+// rescueClosedFromBackup looks up the closing transaction of every channel in
+// the given backup and reports the private keys of all to_remote outputs that
+// belong to us.
+func rescueClosedFromBackup(extendedKey *hdkeychain.ExtendedKey,
+	api btc.ChainBackend, multi *chanbackup.Multi) error {
+
+	var (
+		result   strings.Builder
+		numFound int
+	)
+	for idx := range multi.StaticBackups {
+		single := multi.StaticBackups[idx]
+		found, description, err := rescueClosedFromSingle(
+			extendedKey, api, &single,
+		)
+		if err != nil {
+			return fmt.Errorf("error checking channel %v: %w",
+				single.FundingOutpoint, err)
+		}
+		if found {
+			numFound++
+		}
+
+		result.WriteString(fmt.Sprintf(
+			"Channel %v with peer %x: %s\n",
+			single.FundingOutpoint,
+			single.RemoteNodePub.SerializeCompressed(), description,
+		))
+	}
+	result.WriteString(fmt.Sprintf(
+		"\nFound the private key of %d of %d channel(s)\n", numFound,
+		len(multi.StaticBackups),
+	))
+
+	fmt.Println(result.String())
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(result.String())
+
+	return nil
+}
+
+// rescueClosedFromSingle looks up the closing transaction of the channel in the
+// backup on chain. If it is a commitment transaction of the remote peer with a
+// to_remote output that isn't tweaked, true and a description that contains the
+// private key of that output is returned.
+func rescueClosedFromSingle(extendedKey *hdkeychain.ExtendedKey,
+	api btc.ChainBackend, single *chanbackup.Single) (bool, string, error) {
+
+	op := single.FundingOutpoint
+	fundingTx, err := api.Transaction(op.Hash.String())
+	switch {
+	case errors.Is(err, btc.ErrTxNotFound):
+		return false, "funding TX not found", nil
+
+	case err != nil:
+		return false, "", fmt.Errorf("error fetching funding TX: %w",
+			err)
+	}
+	if int(op.Index) >= len(fundingTx.Vout) {
+		return false, "", fmt.Errorf("invalid funding output index %d",
+			op.Index)
+	}
+
+	outspend := fundingTx.Vout[op.Index].Outspend
+	if outspend == nil || !outspend.Spent {
+		return false, "channel is still open", nil
+	}
+
+	closeTx, err := api.Transaction(outspend.Txid)
+	if err != nil {
+		return false, "", fmt.Errorf("error fetching closing TX: %w",
+			err)
+	}
+	if !isCommitmentTx(closeTx) {
+		return false, fmt.Sprintf("cooperatively closed in TX %s, "+
+			"the funds were paid to the wallet", closeTx.TXID), nil
+	}
+
+	paymentBasePath := []uint32{
+		lnd.HardenedKeyStart + uint32(keychain.BIP0043Purpose),
+		lnd.HardenedKeyStart + chainParams.HDCoinType,
+		lnd.HardenedKeyStart +
+			uint32(single.LocalChanCfg.PaymentBasePoint.Family),
+		0,
+		single.LocalChanCfg.PaymentBasePoint.Index,
+	}
+	privKey, err := lnd.PrivKeyFromPath(extendedKey, paymentBasePath)
+	if err != nil {
+		return false, "", err
+	}
+	paymentBasePoint := privKey.PubKey()
+
+	// The commitment number is obfuscated with the payment base points of
+	// the initiator and the responder, in that order.
+	remoteBasePoint := single.RemoteChanCfg.PaymentBasePoint.PubKey
+	obfuscator := lnwallet.DeriveStateHintObfuscator(
+		remoteBasePoint, paymentBasePoint,
+	)
+	if single.IsInitiator {
+		obfuscator = lnwallet.DeriveStateHintObfuscator(
+			paymentBasePoint, remoteBasePoint,
+		)
+	}
+	commitNum := commitmentNumber(closeTx, obfuscator)
+
+	var pkScript []byte
+	switch single.Version {
+	case chanbackup.TweaklessCommitVersion:
+		pkScript, err = input.CommitScriptUnencumbered(paymentBasePoint)
+
+	case chanbackup.AnchorsCommitVersion,
+		chanbackup.AnchorsZeroFeeHtlcTxCommitVersion:
+
+		var script []byte
+		script, err = input.CommitScriptToRemoteConfirmed(
+			paymentBasePoint,
+		)
+		if err == nil {
+			pkScript, err = input.WitnessScriptHash(script)
+		}
+
+	case chanbackup.ScriptEnforcedLeaseVersion:
+		var script []byte
+		script, err = input.LeaseCommitScriptToRemoteConfirmed(
+			paymentBasePoint, single.LeaseExpiry,
+		)
+		if err == nil {
+			pkScript, err = input.WitnessScriptHash(script)
+		}
+
+	case chanbackup.SimpleTaprootVersion:
+		var scriptTree *input.CommitScriptTree
+		scriptTree, err = input.NewRemoteCommitScriptTree(
+			paymentBasePoint,
+		)
+		if err == nil {
+			pkScript, err = input.PayToTaprootScript(
+				scriptTree.TaprootKey,
+			)
+		}
+
+	default:
+		return false, fmt.Sprintf("force-closed in TX %s at "+
+			"commitment number %d, the channel uses a tweaked "+
+			"to_remote output, use --commit_point or --lnd_log "+
+			"to find its private key", closeTx.TXID, commitNum), nil
+	}
+	if err != nil {
+		return false, "", fmt.Errorf("error creating to_remote "+
+			"script: %w", err)
+	}
+
+	for idx, vout := range closeTx.Vout {
+		if vout.ScriptPubkey != hex.EncodeToString(pkScript) {
+			continue
+		}
+
+		wif, err := btcutil.NewWIF(privKey, chainParams, true)
+		if err != nil {
+			return false, "", err
+		}
+
+		status := "unspent, sweep it with sweepremoteclosed"
+		if vout.Outspend != nil && vout.Outspend.Spent {
+			status = "swept already"
+		}
+
+		return true, fmt.Sprintf("force-closed by the remote peer in "+
+			"TX %s at commitment number %d, output %d (%d sats, "+
+			"%s) belongs to us, private key %s", closeTx.TXID,
+			commitNum, idx, vout.Value, status, wif.String()), nil
+	}
+
+	return false, fmt.Sprintf("force-closed in TX %s at commitment "+
+		"number %d, no output belongs to our payment base point, the "+
+		"channel was force-closed by us or we had no balance",
+		closeTx.TXID, commitNum), nil
+}
+
+// commitmentNumber returns the commitment number of the given commitment
+// transaction, which is encoded in its sequence and lock time, obfuscated with
+// the given state hint obfuscator.
+func commitmentNumber(tx *btc.TX,
+	obfuscator [lnwallet.StateHintSize]byte) uint64 {
+
+	var obfs [8]byte
+	copy(obfs[2:], obfuscator[:])
+	xorInt := binary.BigEndian.Uint64(obfs[:])
+
+	stateNumXor := uint64(tx.Vin[0].Sequence&0xffffff) << 24
+	stateNumXor |= uint64(tx.Locktime & 0xffffff)
+
+	return stateNumXor ^ xorInt
+}
+
 func addrInCache(addr string, perCommitPoint *btcec.PublicKey) (string, error) {
 	targetPubKeyHash, scriptHash, err := lnd.DecodeAddressHash(
 		addr, chainParams,
@@ -470,8 +717,8 @@ func fillCache(extendedKey *hdkeychain.ExtendedKey) error {
 		}
 
 		if i > 0 && i%10000 == 0 {
-			fmt.Printf("Filled cache with %d of %d keys.\n",
-				i, cacheSize)
+			log.Infof("Filled cache with %d of %d keys", i,
+				cacheSize)
 		}
 	}
 	return nil
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/chanbackup"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRescueClosedFromBackup(t *testing.T) {
+	h := newHarness(t)
+
+	// Create a channel backup from a channel DB file.
+	makeBackup := &chanBackupCommand{
+		ChannelDB: h.testdataFile("channel.db"),
+		MultiFile: h.tempFile("extracted.backup"),
+		rootKey:   &rootKey{RootKey: rootKeyAezeed},
+	}
+	err := makeBackup.Execute(nil, nil)
+	require.NoError(t, err)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	multi, err := extractMulti(makeBackup.MultiFile, &lnd.HDKeyRing{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, multi.StaticBackups)
+
+	// The remote peer force-closes the first channel at commitment number
+	// 1234 and pays our balance to the untweaked to_remote output.
+	single := multi.StaticBackups[0]
+	privKey, err := lnd.PrivKeyFromPath(extendedKey, []uint32{
+		lnd.HardenedKeyStart + uint32(keychain.BIP0043Purpose),
+		lnd.HardenedKeyStart + chainParams.HDCoinType,
+		lnd.HardenedKeyStart +
+			uint32(single.LocalChanCfg.PaymentBasePoint.Family),
+		0,
+		single.LocalChanCfg.PaymentBasePoint.Index,
+	})
+	require.NoError(t, err)
+	wif, err := btcutil.NewWIF(privKey, chainParams, true)
+	require.NoError(t, err)
+
+	var pkScript []byte
+	switch single.Version {
+	case chanbackup.TweaklessCommitVersion:
+		pkScript, err = input.CommitScriptUnencumbered(privKey.PubKey())
+		require.NoError(t, err)
+
+	case chanbackup.AnchorsCommitVersion,
+		chanbackup.AnchorsZeroFeeHtlcTxCommitVersion:
+
+		script, err := input.CommitScriptToRemoteConfirmed(
+			privKey.PubKey(),
+		)
+		require.NoError(t, err)
+		pkScript, err = input.WitnessScriptHash(script)
+		require.NoError(t, err)
+
+	default:
+		t.Fatalf("unexpected channel version %d", single.Version)
+	}
+
+	obfuscator := lnwallet.DeriveStateHintObfuscator(
+		single.RemoteChanCfg.PaymentBasePoint.PubKey, privKey.PubKey(),
+	)
+	if single.IsInitiator {
+		obfuscator = lnwallet.DeriveStateHintObfuscator(
+			privKey.PubKey(),
+			single.RemoteChanCfg.PaymentBasePoint.PubKey,
+		)
+	}
+	var obfs [8]byte
+	copy(obfs[2:], obfuscator[:])
+	stateHint := 1234 ^ binary.BigEndian.Uint64(obfs[:])
+
+	closeTx := &btc.TX{
+		TXID: scbCloseTxid,
+		Locktime: commitLockTimeValue |
+			uint32(stateHint&0xffffff),
+		Vin: []*btc.Vin{{
+			Sequence: commitSequenceValue |
+				uint32(stateHint>>24),
+		}},
+		Vout: []*btc.Vout{{
+			Value: anchorOutputValue,
+		}, {
+			ScriptPubkey: hex.EncodeToString(pkScript),
+			Value:        99_000,
+		}},
+	}
+	chain := newScbTestChain(t, h, &scbTestChain{closeTx: closeTx})
+
+	rescue := &rescueClosedCommand{
+		MultiFile: makeBackup.MultiFile,
+		APIURL:    chain.serve(t),
+		rootKey:   &rootKey{RootKey: rootKeyAezeed},
+	}
+	err = rescue.Execute(nil, nil)
+	require.NoError(t, err)
+
+	h.assertLogContains(fmt.Sprintf("Channel %v with peer %x: "+
+		"force-closed by the remote peer in TX %s at commitment "+
+		"number 1234, output 1 (99000 sats, unspent, sweep it with "+
+		"sweepremoteclosed) belongs to us, private key %s",
+		single.FundingOutpoint,
+		single.RemoteNodePub.SerializeCompressed(), scbCloseTxid, wif))
+	h.assertLogContains(fmt.Sprintf("Found the private key of 1 of %d "+
+		"channel(s)", len(multi.StaticBackups)))
+
+	// Cooperatively closed channels are reported as such.
+	h.clearLog()
+	closeTx.Vin[0].Sequence = 0xffffffff
+	chain.addAPITx(closeTx)
+	err = rescue.Execute(nil, nil)
+	require.NoError(t, err)
+	h.assertLogContains("cooperatively closed in TX " + scbCloseTxid)
+	h.assertLogContains("Found the private key of 0 of")
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/btcutil/psbt"
 	"github.com/btcsuite/btcd/wire"
-	"github.com/guggero/chantools/btc"
 	"github.com/guggero/chantools/lnd"
 	"github.com/lightningnetwork/lnd/input"
 	"github.com/lightningnetwork/lnd/keychain"
@@ -44,9 +43,12 @@ type rescueFundingCommand struct {
 	LocalKeyIndex uint32
 	RemotePubKey  string
 
-	SweepAddr string
-	FeeRate   uint16
-	APIURL    string
+	SweepAddr      string
+	DestDescriptor string
+	DestIndex      uint32
+	DestGapLimit   uint32
+	FeeRate        uint16
+	APIURL         string
 
 	rootKey *rootKey
 	cmd     *cobra.Command
@@ -115,6 +117,9 @@ chantools rescuefunding \
 	cc.cmd.Flags().StringVar(
 		&cc.SweepAddr, "sweepaddr", "", "address to sweep the funds to",
 	)
+	addDestDescriptorFlags(
+		cc.cmd, &cc.DestDescriptor, &cc.DestIndex, &cc.DestGapLimit,
+	)
 	cc.cmd.Flags().Uint16Var(
 		&cc.FeeRate, "feerate", defaultFeeSatPerVByte, "fee rate to "+
 			"use for the sweep transaction in sat/vByte",
@@ -227,6 +232,15 @@ func (c *rescueFundingCommand) Execute(_ *cobra.Command, _ []string) error {
 		}
 	}
 
+	// The sweep address can also be derived from a descriptor.
+	c.SweepAddr, err = sweepDestination(
+		c.SweepAddr, c.DestDescriptor, c.DestIndex, c.DestGapLimit,
+		c.APIURL,
+	)
+	if err != nil {
+		return err
+	}
+
 	// Make sure the sweep addr is a P2WKH address so we can do accurate
 	// fee estimation.
 	sweepScript, err := lnd.GetP2WPKHScript(c.SweepAddr, chainParams)
@@ -240,6 +254,20 @@ func (c *rescueFundingCommand) Execute(_ *cobra.Command, _ []string) error {
 	)
 }
 
+// multiSigVSize returns the estimated virtual size of the given transaction
+// once all its inputs, which spend 2-of-2 multisig outputs, are signed.
+func multiSigVSize(tx *wire.MsgTx) int64 {
+	var estimator input.TxWeightEstimator
+	for range tx.TxIn {
+		estimator.AddWitnessInput(MultiSigWitnessSize)
+	}
+	for _, txOut := range tx.TxOut {
+		estimator.AddTxOutput(txOut)
+	}
+
+	return int64(estimator.VSize())
+}
+
 func rescueFunding(localKeyDesc *keychain.KeyDescriptor,
 	remoteKey *btcec.PublicKey, signer *lnd.Signer,
 	chainPoint *wire.OutPoint, sweepPKScript []byte, feeRate btcutil.Amount,
@@ -255,13 +283,23 @@ func rescueFunding(localKeyDesc *keychain.KeyDescriptor,
 	}
 
 	// Locate the output in the funding TX.
-	api := &btc.ExplorerAPI{BaseURL: apiURL}
+	api, err := newChainBackend(apiURL)
+	if err != nil {
+		return err
+	}
 	tx, err := api.Transaction(chainPoint.Hash.String())
 	if err != nil {
 		return fmt.Errorf("error fetching UTXO info for outpoint %s: "+
 			"%v", chainPoint.String(), err)
 	}
+	if int(chainPoint.Index) >= len(tx.Vout) {
+		return fmt.Errorf("TX %s has no output with index %d",
+			chainPoint.Hash.String(), chainPoint.Index)
+	}
 	apiUtxo := tx.Vout[chainPoint.Index]
+	if apiUtxo.Outspend != nil && apiUtxo.Outspend.Spent {
+		return fmt.Errorf("UTXO %v is already spent", chainPoint)
+	}
 
 	pkScript, err := hex.DecodeString(apiUtxo.ScriptPubkey)
 	if err != nil {
@@ -321,6 +359,13 @@ func rescueFunding(localKeyDesc *keychain.KeyDescriptor,
 	}
 	packet.Inputs[0] = pIn
 
+	err = validatePsbt(
+		api, packet, int64(estimator.VSize()), uint16(feeRate),
+	)
+	if err != nil {
+		return fmt.Errorf("error validating PSBT: %w", err)
+	}
+
 	// Now we add our partial signature.
 	err = signer.AddPartialSignature(
 		packet, *localKeyDesc, utxo, witnessScript, 0,
@@ -330,6 +375,10 @@ func rescueFunding(localKeyDesc *keychain.KeyDescriptor,
 	}
 
 	// We're done, we can now output the finished PSBT.
+	written, err := writePsbtOutput(packet)
+	if err != nil || written {
+		return err
+	}
 	base64, err := packet.B64Encode()
 	if err != nil {
 		return fmt.Errorf("error encoding PSBT: %w", err)
@@ -3,7 +3,9 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -14,12 +16,15 @@ import (
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btclog"
+	"github.com/guggero/chantools/bip39"
 	"github.com/guggero/chantools/btc"
 	"github.com/guggero/chantools/dataformat"
 	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/aezeed"
 	"github.com/lightningnetwork/lnd/build"
 	"github.com/lightningnetwork/lnd/chanbackup"
 	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lncfg"
 	"github.com/spf13/cobra"
 	"golang.org/x/crypto/ssh/terminal"
 )
@@ -33,11 +38,29 @@ const (
 )
 
 var (
-	Testnet bool
-	Regtest bool
+	Testnet         bool
+	Regtest         bool
+	Network         string
+	SignetChallenge string
+
+	ChainBackend    string
+	BitcoindRPCHost string
+	BitcoindRPCUser string
+	BitcoindRPCPass string
+	FallbackAPIURL  string
+	TorProxy        string
+	RPCRetries      int
+	RPCTimeout      time.Duration
+	OutputFile      string
+	OutputFormat    string
+	Force           bool
+	ConfigFile      string
+	LogLevel        string
+	LogFormat       string
+	MnemonicStrict  bool
 
 	logWriter   = build.NewRotatingLogWriter()
-	log         = build.NewSubLogger("CHAN", genSubLogger(logWriter))
+	log         = build.NewSubLogger("CHAN", genSubLogger)
 	chainParams = &chaincfg.MainNetParams
 )
 
@@ -48,27 +71,55 @@ var rootCmd = &cobra.Command{
 funds locked in lnd channels in case lnd itself cannot run properly anymore.
 Complete documentation is available at https://github.com/guggero/chantools/.`,
 	Version: fmt.Sprintf("v%s, commit %s", version, Commit),
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		switch {
-		case Testnet:
-			chainParams = &chaincfg.TestNet3Params
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// The config file can set the network, so it needs to be
+		// loaded first.
+		configFile, warnings, err := loadConfigFile(cmd, ConfigFile)
+		if err != nil {
+			return err
+		}
 
-		case Regtest:
-			chainParams = &chaincfg.RegressionNetParams
+		params, err := selectedChainParams()
+		if err != nil {
+			return err
+		}
+		chainParams = params
 
-		default:
-			chainParams = &chaincfg.MainNetParams
+		policy := bip39.NormalizeLenient
+		if MnemonicStrict {
+			policy = bip39.NormalizeStrict
+		}
+		if err := bip39.SetNormalizationPolicy(policy); err != nil {
+			return err
 		}
 
-		setupLogging()
+		if err := setupLogging(); err != nil {
+			return err
+		}
 
 		log.Infof("chantools version v%s commit %s", version,
 			Commit)
+
+		if configFile != "" {
+			log.Infof("Using config file %s", configFile)
+		}
+		for _, warning := range warnings {
+			log.Warn(warning)
+		}
+
+		return nil
 	},
 	DisableAutoGenTag: true,
 }
 
 func main() {
+	rootCmd.PersistentFlags().StringVar(
+		&ConfigFile, "config", "", "The YAML config file to read "+
+			"default values for flags from; flags on the command "+
+			"line take precedence; defaults to "+
+			"$XDG_CONFIG_HOME/chantools/chantools.yaml or "+
+			"~/.config/chantools/chantools.yaml if it exists",
+	)
 	rootCmd.PersistentFlags().BoolVarP(
 		&Testnet, "testnet", "t", false, "Indicates if testnet "+
 			"parameters should be used",
@@ -77,33 +128,138 @@ func main() {
 		&Regtest, "regtest", "r", false, "Indicates if regtest "+
 			"parameters should be used",
 	)
+	rootCmd.PersistentFlags().StringVar(
+		&Network, "network", "", "The network to use; must be one of "+
+			"mainnet, testnet, testnet4, signet or regtest, "+
+			"defaults to mainnet; can't be combined with "+
+			"--testnet or --regtest",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&SignetChallenge, "signetchallenge", "", "The hex encoded "+
+			"challenge script of a custom signet; only used with "+
+			"--network signet",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&ChainBackend, "chainbackend", btc.ChainBackendEsplora, "The "+
+			"chain backend to use for reading on-chain data and "+
+			"publishing transactions; must be one of esplora "+
+			"(uses the --apiurl of the command) or bitcoind",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&BitcoindRPCHost, "bitcoind.rpchost", "", "The host:port of "+
+			"the bitcoind RPC interface; defaults to the default "+
+			"RPC port of the selected network on localhost",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&BitcoindRPCUser, "bitcoind.rpcuser", "", "The username for "+
+			"the bitcoind RPC interface",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&BitcoindRPCPass, "bitcoind.rpcpass", "", "The password for "+
+			"the bitcoind RPC interface",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&FallbackAPIURL, "fallbackapiurl", "", "A second Esplora API "+
+			"URL that is used if a request to the --apiurl of the "+
+			"command fails",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&TorProxy, "torproxy", "", "The host:port of a Tor SOCKS5 "+
+			"proxy to send all Esplora API requests through; "+
+			".onion API URLs use "+btc.DefaultTorProxy+" if not "+
+			"set",
+	)
+	rootCmd.PersistentFlags().IntVar(
+		&RPCRetries, "rpc-retries", btc.DefaultRetries, "The number "+
+			"of times a failed chain backend call is retried with "+
+			"an exponential backoff before giving up",
+	)
+	rootCmd.PersistentFlags().DurationVar(
+		&RPCTimeout, "rpc-timeout", time.Minute, "The maximum time a "+
+			"single chain backend call may take before it is "+
+			"aborted and retried",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&OutputFile, "output-file", "", "Write the created TX or PSBT "+
+			"(or the keys of exportkeys) to the given file instead "+
+			"of printing it; commands that create multiple TXs "+
+			"add a numeric suffix",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&OutputFormat, "output-format", "", "The format of the "+
+			"--output-file; must be one of hex, psbt or json, "+
+			"defaults to hex for TXs and psbt for PSBTs",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&Force, "force", false, "Overwrite the --output-file if it "+
+			"already exists",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&LogLevel, "loglevel", "debug", "The log level of all "+
+			"subsystems (trace, debug, info, warn, error, "+
+			"critical or off) or a comma separated list of "+
+			"<subsystem>=<level> pairs, e.g. CHAN=info,CHDB=warn",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&LogFormat, "logformat", logFormatText, "The format of the "+
+			"log messages on the terminal and in the log file; "+
+			"must be one of text or json (one object per line)",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&MnemonicStrict, "mnemonic-strict", false, "Reject BIP39 "+
+			"mnemonics with leading, trailing or duplicate "+
+			"whitespace or upper case letters instead of "+
+			"normalizing them, to derive the seed from exactly "+
+			"the given mnemonic like some wallets do",
+	)
 
 	rootCmd.AddCommand(
+		newBumpFeeCommand(),
 		newChanBackupCommand(),
+		newCheckMnemonicCommand(),
 		newClosePoolAccountCommand(),
+		newCombineSeedCommand(),
 		newCompactDBCommand(),
+		newConvertSeedCommand(),
+		newCoopCloseCommand(),
 		newDeletePaymentsCommand(),
 		newDeriveKeyCommand(),
 		newDropChannelGraphCommand(),
 		newDumpBackupCommand(),
 		newDumpChannelsCommand(),
 		newDocCommand(),
+		newExportKeysCommand(),
 		newFakeChanBackupCommand(),
 		newFilterBackupCommand(),
 		newFixOldBackupCommand(),
 		newForceCloseCommand(),
 		newGenImportScriptCommand(),
+		newGenMnemonicCommand(),
+		newListWordsCommand(),
 		newMigrateDBCommand(),
+		newMultisigCommand(),
+		newPullAnchorCommand(),
+		newRebroadcastCommand(),
+		newRecoverLoopInCommand(),
 		newRemoveChannelCommand(),
 		newRescueClosedCommand(),
 		newRescueFundingCommand(),
+		newScbForceCloseCommand(),
 		newShowRootKeyCommand(),
+		newSignMessageCommand(),
 		newSignRescueFundingCommand(),
+		newSplitSeedCommand(),
 		newSummaryCommand(),
+		newSweepFundingAddrCommand(),
+		newSweepHtlcsCommand(),
 		newSweepTimeLockCommand(),
 		newSweepTimeLockManualCommand(),
 		newSweepRemoteClosedCommand(),
+		newTriggerForceCloseCommand(),
 		newVanityGenCommand(),
+		newVerifyAddressCommand(),
+		newVerifyBackupCommand(),
+		newVerifyMessageCommand(),
 		newWalletInfoCommand(),
 		newZombieRecoveryCommand(),
 	)
@@ -114,9 +270,110 @@ func main() {
 	}
 }
 
+// newChainBackend returns the chain backend selected with the --chainbackend
+// flag. The Esplora backend uses the given API URL. Failed calls are retried
+// as configured with the --rpc-retries and --rpc-timeout flags.
+func newChainBackend(apiURL string) (btc.ChainBackend, error) {
+	backend, err := newBaseChainBackend(apiURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &btc.RetryBackend{
+		Backend: backend,
+		Retries: RPCRetries,
+		Timeout: RPCTimeout,
+		Log:     log,
+	}, nil
+}
+
+// newBaseChainBackend returns the chain backend selected with the
+// --chainbackend flag without any retries.
+func newBaseChainBackend(apiURL string) (btc.ChainBackend, error) {
+	switch ChainBackend {
+	case btc.ChainBackendEsplora, "":
+		return &btc.ExplorerAPI{
+			BaseURL:     apiURL,
+			FallbackURL: FallbackAPIURL,
+			TorProxy:    TorProxy,
+			Timeout:     RPCTimeout,
+		}, nil
+
+	case btc.ChainBackendBitcoind:
+		host := BitcoindRPCHost
+		if host == "" {
+			host = fmt.Sprintf("localhost:%s", bitcoindRPCPort())
+		}
+		return btc.NewBitcoindRPC(
+			host, BitcoindRPCUser, BitcoindRPCPass, chainParams,
+		)
+
+	default:
+		return nil, fmt.Errorf("unknown chain backend %s, must be one "+
+			"of %s or %s", ChainBackend, btc.ChainBackendEsplora,
+			btc.ChainBackendBitcoind)
+	}
+}
+
+// selectedChainParams returns the parameters of the network that was selected
+// with the --network flag or one of the older --testnet and --regtest flags.
+func selectedChainParams() (*chaincfg.Params, error) {
+	network := Network
+	switch {
+	case Network != "" && (Testnet || Regtest):
+		return nil, fmt.Errorf("--network cannot be combined with " +
+			"--testnet or --regtest")
+
+	case Testnet:
+		network = btc.NetworkTestnet
+
+	case Regtest:
+		network = btc.NetworkRegtest
+	}
+
+	challenge, err := hex.DecodeString(SignetChallenge)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding signet challenge: %w",
+			err)
+	}
+
+	return btc.NetworkParams(network, challenge)
+}
+
+// bitcoindRPCPort returns the default bitcoind RPC port of the current
+// network.
+func bitcoindRPCPort() string {
+	switch {
+	case chainParams.Net == chaincfg.TestNet3Params.Net:
+		return "18332"
+
+	case chainParams.Net == btc.TestNet4Params.Net:
+		return "48332"
+
+	case btc.IsSignet(chainParams):
+		return "38332"
+
+	case chainParams.Net == chaincfg.RegressionNetParams.Net:
+		return "18443"
+
+	default:
+		return "8332"
+	}
+}
+
 type rootKey struct {
-	RootKey string
-	BIP39   bool
+	RootKey        string
+	BIP39          bool
+	SLIP39         bool
+	SeedFile       string
+	Passphrase     string
+	PassphraseFile string
+	PassphraseEnv  string
+	Interactive    bool
+
+	// validateBIP39 makes sure a BIP39 mnemonic has a valid checksum even
+	// if it isn't read from the terminal.
+	validateBIP39 bool
 }
 
 func newRootKey(cmd *cobra.Command, desc string) *rootKey {
@@ -131,6 +388,41 @@ func newRootKey(cmd *cobra.Command, desc string) *rootKey {
 			"passphrase from the terminal instead of asking for "+
 			"lnd seed format or providing the --rootkey flag",
 	)
+	cmd.Flags().BoolVar(
+		&r.SLIP39, "slip39", false, "read SLIP-0039 (Shamir) seed "+
+			"shares and their passphrase from the terminal "+
+			"instead of asking for lnd seed format or providing "+
+			"the --rootkey flag",
+	)
+	cmd.Flags().StringVar(
+		&r.SeedFile, "seed-file", "", "file to read the lnd 24 word "+
+			"aezeed (or the BIP39 mnemonic if --bip39 is set or "+
+			"one SLIP-0039 share per line if --slip39 is set) or "+
+			"the BIP32 HD root key from instead of prompting for "+
+			"it",
+	)
+	cmd.Flags().StringVar(
+		&r.Passphrase, "passphrase", "", "passphrase of the seed "+
+			"(the BIP39 25th word) to use instead of prompting "+
+			"for it; use a dash (-) for a seed without passphrase",
+	)
+	cmd.Flags().StringVar(
+		&r.PassphraseFile, "passphrase-file", "", "file to read the "+
+			"seed passphrase from instead of prompting for it; an "+
+			"empty file means the seed has no passphrase",
+	)
+	cmd.Flags().StringVar(
+		&r.PassphraseEnv, "passphrase-env", "", "name of the "+
+			"environment variable to read the seed passphrase "+
+			"from instead of prompting for it; an empty variable "+
+			"means the seed has no passphrase",
+	)
+	cmd.Flags().BoolVar(
+		&r.Interactive, "interactive", false, "read the lnd aezeed "+
+			"(or the BIP39 mnemonic if --bip39 is set) word by "+
+			"word from the terminal, without echoing it and with "+
+			"suggestions for words that are not in the word list",
+	)
 
 	return r
 }
@@ -143,19 +435,213 @@ func (r *rootKey) read() (*hdkeychain.ExtendedKey, error) {
 func (r *rootKey) readWithBirthday() (*hdkeychain.ExtendedKey, time.Time,
 	error) {
 
+	if r.RootKey != "" && r.SeedFile != "" {
+		return nil, time.Unix(0, 0), fmt.Errorf("only one of " +
+			"--rootkey and --seed-file can be set")
+	}
+	if r.BIP39 && r.SLIP39 {
+		return nil, time.Unix(0, 0), fmt.Errorf("only one of " +
+			"--bip39 and --slip39 can be set")
+	}
+	if r.Interactive && (r.RootKey != "" || r.SeedFile != "" ||
+		r.SLIP39) {
+
+		return nil, time.Unix(0, 0), fmt.Errorf("--interactive " +
+			"can't be combined with --rootkey, --seed-file or " +
+			"--slip39")
+	}
+
+	numPassphraseFlags := 0
+	for _, flag := range []string{
+		r.Passphrase, r.PassphraseFile, r.PassphraseEnv,
+	} {
+		if flag != "" {
+			numPassphraseFlags++
+		}
+	}
+	if numPassphraseFlags > 1 {
+		return nil, time.Unix(0, 0), fmt.Errorf("only one of " +
+			"--passphrase, --passphrase-file and " +
+			"--passphrase-env can be set")
+	}
+	if r.RootKey != "" && numPassphraseFlags > 0 {
+		return nil, time.Unix(0, 0), fmt.Errorf("a passphrase can't " +
+			"be combined with --rootkey, the root key is already " +
+			"derived with the passphrase")
+	}
+
 	// Check that root key is valid or fall back to console input.
-	switch {
-	case r.RootKey != "":
-		extendedKey, err := hdkeychain.NewKeyFromString(r.RootKey)
+	if r.RootKey != "" {
+		warnSecretOnCommandLine("rootkey")
+		extendedKey, err := btc.ParseExtendedKey(r.RootKey, chainParams)
 		return extendedKey, time.Unix(0, 0), err
+	}
 
+	// The seed and passphrase are read from the environment or the
+	// terminal, unless we're told to read them from somewhere else.
+	mnemonicEnv, passphraseEnv := lnd.MnemonicEnvName, lnd.PassphraseEnvName
+	switch {
 	case r.BIP39:
-		extendedKey, err := btc.ReadMnemonicFromTerminal(chainParams)
-		return extendedKey, time.Unix(0, 0), err
+		mnemonicEnv = btc.BIP39MnemonicEnvName
+		passphraseEnv = btc.BIP39PassphraseEnvName
+
+	case r.SLIP39:
+		mnemonicEnv = btc.SLIP39SharesEnvName
+		passphraseEnv = btc.SLIP39PassphraseEnvName
+	}
+	mnemonic := os.Getenv(mnemonicEnv)
+	passphrase := os.Getenv(passphraseEnv)
+
+	if r.SeedFile != "" {
+		var err error
+		mnemonic, err = readSecretFile(r.SeedFile)
+		if err != nil {
+			return nil, time.Unix(0, 0), err
+		}
+
+		// The file can also contain an extended root key. If it does,
+		// we also want to know if it is for the wrong network.
+		extendedKey, err := btc.ParseExtendedKey(mnemonic, chainParams)
+		switch {
+		case err == nil && numPassphraseFlags > 0:
+			return nil, time.Unix(0, 0), fmt.Errorf("a passphrase "+
+				"can't be combined with the root key in %s, "+
+				"the root key is already derived with the "+
+				"passphrase", r.SeedFile)
+
+		case err == nil:
+			return extendedKey, time.Unix(0, 0), nil
+
+		case errors.Is(err, btc.ErrWrongNetwork):
+			return nil, time.Unix(0, 0), err
+		}
+	}
+
+	if r.Interactive {
+		// An aezeed always has 24 words and its checksum is verified
+		// when deciphering it, the number of words of a BIP39
+		// mnemonic is asked for and its checksum verified right away.
+		numWords := aezeed.NumMnemonicWords
+		if r.BIP39 {
+			numWords = 0
+		}
+
+		var err error
+		mnemonic, err = btc.ReadMnemonicInteractive(
+			numWords, btc.ReadTerminalWord,
+		)
+		if err != nil {
+			return nil, time.Unix(0, 0), err
+		}
+	}
+
+	switch {
+	case r.Passphrase != "":
+		if r.Passphrase != "-" {
+			warnSecretOnCommandLine("passphrase")
+		}
+		passphrase = r.Passphrase
+
+	case r.PassphraseFile != "":
+		var err error
+		passphrase, err = readSecretFile(r.PassphraseFile)
+		if err != nil {
+			return nil, time.Unix(0, 0), err
+		}
+
+		// A dash tells the seed readers to not prompt for a
+		// passphrase.
+		if strings.TrimSpace(passphrase) == "" {
+			passphrase = "-"
+		}
+
+	case r.PassphraseEnv != "":
+		var ok bool
+		passphrase, ok = os.LookupEnv(r.PassphraseEnv)
+		if !ok {
+			return nil, time.Unix(0, 0), fmt.Errorf("environment "+
+				"variable %s is not set", r.PassphraseEnv)
+		}
+
+		// A dash tells the seed readers to not prompt for a
+		// passphrase.
+		if strings.TrimSpace(passphrase) == "" {
+			passphrase = "-"
+		}
+	}
+
+	// The passphrase must never end up in the log.
+	trimmedPassphrase := strings.TrimSpace(passphrase)
+	hasPassphrase := trimmedPassphrase != "" && trimmedPassphrase != "-"
+	if hasPassphrase {
+		redactLogSecret(trimmedPassphrase)
+	}
+
+	var (
+		extendedKey *hdkeychain.ExtendedKey
+		birthday    = time.Unix(0, 0)
+		err         error
+	)
+	switch {
+	case r.BIP39 && r.validateBIP39:
+		extendedKey, err = btc.ReadValidMnemonicWithSecrets(
+			chainParams, mnemonic, passphrase,
+		)
+
+	case r.BIP39:
+		extendedKey, err = btc.ReadMnemonicWithSecrets(
+			chainParams, mnemonic, passphrase,
+		)
+
+	case r.SLIP39:
+		extendedKey, err = btc.ReadSlip39SharesWithSecrets(
+			chainParams, mnemonic, passphrase,
+		)
 
 	default:
-		return lnd.ReadAezeed(chainParams)
+		extendedKey, birthday, err = lnd.ReadAezeedWithSecrets(
+			chainParams, mnemonic, passphrase,
+		)
+	}
+	if err != nil {
+		return nil, time.Unix(0, 0), err
+	}
+
+	// Every passphrase results in a valid but different wallet, so a typo
+	// isn't detected. We show the fingerprint of the master key so the user
+	// can make sure it's the expected wallet.
+	if hasPassphrase {
+		fingerprint, err := rootKeyFingerprint(extendedKey)
+		if err != nil {
+			return nil, time.Unix(0, 0), err
+		}
+		log.Infof("Master key fingerprint of the seed with the given "+
+			"passphrase is %s, make sure it matches your wallet "+
+			"before sweeping any funds", fingerprint)
 	}
+
+	return extendedKey, birthday, nil
+}
+
+// readSecretFile reads a secret like a seed or a password from the given file
+// and removes the trailing newline.
+func readSecretFile(fileName string) (string, error) {
+	content, err := ioutil.ReadFile(lncfg.CleanAndExpandPath(fileName))
+	if err != nil {
+		return "", fmt.Errorf("error reading secret file %s: %w",
+			fileName, err)
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// warnSecretOnCommandLine warns the user that the secret given with the flag
+// of the given name might leak through the process list or the shell history.
+func warnSecretOnCommandLine(flagName string) {
+	log.Warnf("!!! WARNING !!! The secret was passed on the command line "+
+		"with --%s, so it can be seen by other users in the process "+
+		"list and is stored in your shell history! Use a file or an "+
+		"environment variable instead.", flagName)
 }
 
 type inputFlags struct {
@@ -186,6 +672,12 @@ func newInputFlags(cmd *cobra.Command) *inputFlags {
 	return f
 }
 
+// isSet returns true if any of the channel input flags is set.
+func (f *inputFlags) isSet() bool {
+	return f.ListChannels != "" || f.PendingChannels != "" ||
+		f.FromSummary != "" || f.FromChannelDB != ""
+}
+
 func (f *inputFlags) parseInputType() ([]*dataformat.SummaryEntry, error) {
 	var (
 		content []byte
@@ -258,25 +750,33 @@ func passwordFromConsole(userQuery string) ([]byte, error) {
 	return pw, nil
 }
 
-func setupLogging() {
+func setupLogging() error {
+	switch LogFormat {
+	case logFormatText, logFormatJSON:
+	default:
+		return fmt.Errorf("invalid log format %q, must be one of %s "+
+			"or %s", LogFormat, logFormatText, logFormatJSON)
+	}
+
 	setSubLogger("CHAN", log)
 	addSubLogger("CHDB", channeldb.UseLogger)
 	addSubLogger("BCKP", chanbackup.UseLogger)
-	err := logWriter.InitLogRotator("./results/chantools.log", 10, 3)
+	err := initLogFile("./results/chantools.log", 10, 3)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	err = build.ParseAndSetDebugLevels("debug", logWriter)
+	err = build.ParseAndSetDebugLevels(LogLevel, logWriter)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("invalid log level: %w", err)
 	}
+
+	return nil
 }
 
-// genSubLogger creates a sub logger with an empty shutdown function.
-func genSubLogger(logWriter *build.RotatingLogWriter) func(string) btclog.Logger {
-	return func(s string) btclog.Logger {
-		return logWriter.GenSubLogger(s, func() {})
-	}
+// genSubLogger creates a sub logger that writes to the log output of
+// chantools.
+func genSubLogger(subsystem string) btclog.Logger {
+	return newLogger(subsystem)
 }
 
 // addSubLogger is a helper method to conveniently create and register the
@@ -284,7 +784,7 @@ func genSubLogger(logWriter *build.RotatingLogWriter) func(string) btclog.Logger
 func addSubLogger(subsystem string, useLoggers ...func(btclog.Logger)) {
 	// Create and register just a single logger to prevent them from
 	// overwriting each other internally.
-	logger := build.NewSubLogger(subsystem, genSubLogger(logWriter))
+	logger := build.NewSubLogger(subsystem, genSubLogger)
 	setSubLogger(subsystem, logger, useLoggers...)
 }
 
@@ -298,7 +798,3 @@ func setSubLogger(subsystem string, logger btclog.Logger,
 		useLogger(logger)
 	}
 }
-
-func noConsole() ([]byte, error) {
-	return nil, fmt.Errorf("wallet db requires console access")
-}
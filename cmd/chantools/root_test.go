@@ -8,8 +8,10 @@ import (
 	"regexp"
 	"testing"
 
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btclog"
+	"github.com/guggero/chantools/btc"
 	"github.com/lightningnetwork/lnd/chanbackup"
 	"github.com/lightningnetwork/lnd/channeldb"
 	"github.com/stretchr/testify/require"
@@ -30,7 +32,9 @@ const (
 		"hVb3wcoRvgrjvTmjPG2ixoGUUkCyC6yBEy9T5gbLdvD2a5VmJbcFd5Q9pkAs"
 	rootKeyBip39 = "tprv8ZgxMBicQKsPdoVEZRN2MyzEgxGTqJepzhMc66b26zL1siLi" +
 		"WRQAGh9rAgPPJuQeHWWpgcDcS45yi6KBTFeGkQMEb2RNTrP11evJcB4UVSh"
-	rootKeyBip39Passphrase = ""
+	rootKeyBip39Passphrase = "tprv8ZgxMBicQKsPdBoYRiXn75fMZFDfawsfR8p" +
+		"d7XdVbKWkd3U6cNR7pHwhBM1iaQ5sU8BVu3nXTPZcDECGBAFXMChdmS9cey7" +
+		"reb9FzFvYDhr"
 )
 
 var (
@@ -116,3 +120,73 @@ func (h *harness) fileSize(name string) int64 {
 
 	return stat.Size()
 }
+
+func TestSelectedChainParams(t *testing.T) {
+	defer func() {
+		Testnet = false
+		Regtest = false
+		Network = ""
+		SignetChallenge = ""
+	}()
+
+	params, err := selectedChainParams()
+	require.NoError(t, err)
+	require.Equal(t, &chaincfg.MainNetParams, params)
+
+	Testnet = true
+	params, err = selectedChainParams()
+	require.NoError(t, err)
+	require.Equal(t, &chaincfg.TestNet3Params, params)
+
+	Network = btc.NetworkSignet
+	_, err = selectedChainParams()
+	require.ErrorContains(t, err, "cannot be combined with --testnet")
+
+	Testnet = false
+	params, err = selectedChainParams()
+	require.NoError(t, err)
+	require.Equal(t, &chaincfg.SigNetParams, params)
+
+	SignetChallenge = "zz"
+	_, err = selectedChainParams()
+	require.ErrorContains(t, err, "error decoding signet challenge")
+
+	SignetChallenge = "51"
+	params, err = selectedChainParams()
+	require.NoError(t, err)
+	require.True(t, btc.IsSignet(params))
+	require.NotEqual(t, chaincfg.SigNetParams.Net, params.Net)
+
+	Network = btc.NetworkTestnet4
+	SignetChallenge = ""
+	params, err = selectedChainParams()
+	require.NoError(t, err)
+	require.Equal(t, &btc.TestNet4Params, params)
+}
+
+func TestRootKeyWrongNetwork(t *testing.T) {
+	h := newHarness(t)
+
+	mainnetKey, err := hdkeychain.NewMaster(
+		bytes.Repeat([]byte{0x42}, 32), &chaincfg.MainNetParams,
+	)
+	require.NoError(t, err)
+
+	r := &rootKey{RootKey: mainnetKey.String()}
+	_, err = r.read()
+	require.ErrorIs(t, err, btc.ErrWrongNetwork)
+	require.ErrorContains(t, err, "selected network is regtest")
+
+	// An extended key in the seed file is checked the same way.
+	seedFile := h.tempFile("seed.txt")
+	err = ioutil.WriteFile(seedFile, []byte(mainnetKey.String()+"\n"), 0600)
+	require.NoError(t, err)
+
+	r = &rootKey{SeedFile: seedFile}
+	_, err = r.read()
+	require.ErrorIs(t, err, btc.ErrWrongNetwork)
+
+	r = &rootKey{RootKey: rootKeyAezeed}
+	_, err = r.read()
+	require.NoError(t, err)
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/btc"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// scanProgressInterval is the number of derivation indexes after which
+	// the progress of a scan is logged.
+	scanProgressInterval = 50
+
+	// defaultGapLimit is the default number of consecutive derivation
+	// indexes without funds after which a scan stops, once the minimum
+	// number of indexes was scanned.
+	defaultGapLimit = 20
+
+	// defaultGapLimitInternal is the default gap limit of the internal
+	// (change) branch of a wallet. Change addresses are only used by the
+	// wallet itself and are handed out less often, so the gap between them
+	// is smaller.
+	defaultGapLimitInternal = 10
+
+	// scanBatchSize is the maximum number of derivation indexes that are
+	// queried in parallel before their results are applied to the scan
+	// state.
+	scanBatchSize = 100
+)
+
+// scanIndexFunc derives the keys of a single derivation index and queries the
+// chain backend for them. It returns an opaque result that is handed to the
+// scanResultFunc and the funds found at the index. It must be safe for
+// concurrent use.
+type scanIndexFunc func(index uint32) (interface{}, uint64, error)
+
+// scanResultFunc is called with the result of each scanned index, in index
+// order.
+type scanResultFunc func(index uint32, result interface{})
+
+// scanIndexResult is the outcome of querying a single derivation index.
+type scanIndexResult struct {
+	result interface{}
+	funds  uint64
+	err    error
+}
+
+// scanState is the checkpoint of a scan over derived addresses that is written
+// to the state file given with --resume so an interrupted scan can continue
+// where it left off.
+type scanState struct {
+	// RootKeyFingerprint is the hex encoded fingerprint of the root key
+	// the scan was started with. It makes sure a scan is never resumed
+	// with a different key.
+	RootKeyFingerprint string `json:"root_key_fingerprint"`
+
+	// Branches contains the state of each scanned derivation branch,
+	// keyed by the derivation path of the branch.
+	Branches map[string]*scanBranchState `json:"branches"`
+
+	fileName string
+}
+
+// scanBranchState is the scan state of a single derivation branch.
+type scanBranchState struct {
+	// NextIndex is the next derivation index to scan.
+	NextIndex uint32 `json:"next_index"`
+
+	// Gap is the number of consecutive indexes since the last index with
+	// funds (or since the start of the scan).
+	Gap uint32 `json:"gap"`
+
+	// AddressesChecked is the total number of addresses queried.
+	AddressesChecked uint64 `json:"addresses_checked"`
+
+	// FoundIndexes are the derivation indexes funds were found for.
+	FoundIndexes []uint32 `json:"found_indexes"`
+
+	// FundsFound is the total value in satoshis found in this branch.
+	FundsFound uint64 `json:"funds_found"`
+}
+
+// addResumeFlag adds the --resume flag to the given command.
+func addResumeFlag(cmd *cobra.Command, resumeFile *string) {
+	cmd.Flags().StringVar(
+		resumeFile, "resume", "", "JSON file to checkpoint the scan "+
+			"position to; if the file exists, an interrupted "+
+			"scan is continued from there",
+	)
+}
+
+// addGapLimitFlags adds the --gap-limit and --max-index flags to the given
+// command.
+func addGapLimitFlags(cmd *cobra.Command, gapLimit, maxIndex *uint32) {
+	addGapLimitFlag(cmd, gapLimit)
+	cmd.Flags().Uint32Var(
+		maxIndex, "max-index", 0, "the highest derivation index to "+
+			"scan, even if funds were found close to it; 0 means "+
+			"no limit",
+	)
+}
+
+// addGapLimitFlag adds the --gap-limit flag to the given command.
+func addGapLimitFlag(cmd *cobra.Command, gapLimit *uint32) {
+	cmd.Flags().Uint32Var(
+		gapLimit, "gap-limit", defaultGapLimit, "continue the scan "+
+			"beyond the --recoverywindow until this many "+
+			"consecutive indexes without funds were found",
+	)
+}
+
+// addGapLimitInternalFlag adds the --gap-limit-internal flag to the given
+// command that also scans the internal (change) branch of the lnd wallet.
+func addGapLimitInternalFlag(cmd *cobra.Command, gapLimitInternal *uint32) {
+	cmd.Flags().Uint32Var(
+		gapLimitInternal, "gap-limit-internal", defaultGapLimitInternal,
+		"the gap limit of the internal (change) branch of the lnd "+
+			"wallet, --gap-limit is used for the external branch",
+	)
+}
+
+// loadScanState reads the scan state from the given file. If no file name is
+// given or the file doesn't exist yet, a new, empty state is returned.
+func loadScanState(fileName string,
+	extendedKey *hdkeychain.ExtendedKey) (*scanState, error) {
+
+	fingerprint, err := rootKeyFingerprint(extendedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &scanState{
+		RootKeyFingerprint: fingerprint,
+		Branches:           make(map[string]*scanBranchState),
+		fileName:           fileName,
+	}
+	if fileName == "" {
+		return state, nil
+	}
+
+	stateBytes, err := ioutil.ReadFile(fileName)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return state, nil
+
+	case err != nil:
+		return nil, fmt.Errorf("error reading state file: %w", err)
+	}
+
+	if err := json.Unmarshal(stateBytes, state); err != nil {
+		return nil, fmt.Errorf("error parsing state file: %w", err)
+	}
+	if state.RootKeyFingerprint != fingerprint {
+		return nil, fmt.Errorf("state file %s was created with root "+
+			"key %s, cannot resume with root key %s", fileName,
+			state.RootKeyFingerprint, fingerprint)
+	}
+	if state.Branches == nil {
+		state.Branches = make(map[string]*scanBranchState)
+	}
+
+	log.Infof("Resuming scan from state file %s", fileName)
+	return state, nil
+}
+
+// branch returns the state of the branch with the given derivation path,
+// creating it if it doesn't exist yet.
+func (s *scanState) branch(path string) *scanBranchState {
+	branch, ok := s.Branches[path]
+	if !ok {
+		branch = &scanBranchState{}
+		s.Branches[path] = branch
+	}
+
+	return branch
+}
+
+// save writes the scan state to the state file, if one was given.
+func (s *scanState) save() error {
+	if s.fileName == "" {
+		return nil
+	}
+
+	stateBytes, err := json.MarshalIndent(s, "", " ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first and then rename it, so an
+	// interruption while writing doesn't corrupt the state.
+	tmpFileName := s.fileName + ".tmp"
+	if err := ioutil.WriteFile(tmpFileName, stateBytes, 0644); err != nil {
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+
+	return os.Rename(tmpFileName, s.fileName)
+}
+
+// scan scans the branch with the given derivation path from its next index
+// until the scan end is reached. The indexes are derived and queried in batches
+// by up to numWorkers goroutines in parallel. The results of a batch are then
+// applied in index order, so the gap limit is evaluated exactly as in a serial
+// scan. A batch never goes beyond the current scan end, which only grows while
+// results are applied, so no index is queried unnecessarily.
+func (s *scanState) scan(path string, minIndexes, gapLimit, maxIndex uint32,
+	numAddrs, numWorkers int, query scanIndexFunc,
+	found scanResultFunc) error {
+
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+
+	branch := s.branch(path)
+	scanEnd := func() uint32 {
+		return branch.scanEnd(minIndexes, gapLimit, maxIndex)
+	}
+	for branch.NextIndex < scanEnd() {
+		start, end := branch.NextIndex, scanEnd()
+		if end-start > scanBatchSize {
+			end = start + scanBatchSize
+		}
+
+		results := queryIndexes(start, end, numWorkers, query)
+		for idx, result := range results {
+			// The state of all indexes before the failed one is
+			// kept, so a resumed scan continues from there.
+			if result.err != nil {
+				if err := s.save(); err != nil {
+					log.Errorf("Error saving scan "+
+						"state: %v", err)
+				}
+				return result.err
+			}
+
+			index := start + uint32(idx)
+			found(index, result.result)
+			branch.scanned(index, numAddrs, result.funds)
+			branch.logProgress(path, scanEnd())
+		}
+
+		if err := s.save(); err != nil {
+			return err
+		}
+	}
+	branch.logResult(path, gapLimit, maxIndex)
+
+	return nil
+}
+
+// queryIndexes queries all indexes from start (inclusive) to end (exclusive)
+// with a pool of numWorkers goroutines and returns the results in index order.
+func queryIndexes(start, end uint32, numWorkers int,
+	query scanIndexFunc) []scanIndexResult {
+
+	results := make([]scanIndexResult, end-start)
+	indexes := make(chan uint32)
+
+	var wg sync.WaitGroup
+	wg.Add(numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for index := range indexes {
+				result := &results[index-start]
+				result.result, result.funds, result.err = query(
+					index,
+				)
+			}
+		}()
+	}
+
+	for index := start; index < end; index++ {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// scanned updates the branch state after the given index was scanned.
+func (b *scanBranchState) scanned(index uint32, numAddrs int,
+	funds uint64) {
+
+	b.NextIndex = index + 1
+	b.AddressesChecked += uint64(numAddrs)
+
+	if funds == 0 {
+		b.Gap++
+		return
+	}
+
+	b.Gap = 0
+	b.FundsFound += funds
+	b.FoundIndexes = append(b.FoundIndexes, index)
+}
+
+// scanEnd returns the index (exclusive) the scan of the branch needs to reach.
+// At least minIndexes indexes are scanned, after that the scan continues until
+// gapLimit consecutive indexes without funds were found. The scan never goes
+// beyond maxIndex, unless it is 0.
+func (b *scanBranchState) scanEnd(minIndexes, gapLimit,
+	maxIndex uint32) uint32 {
+
+	end := minIndexes
+	if len(b.FoundIndexes) > 0 {
+		lastFound := b.FoundIndexes[len(b.FoundIndexes)-1]
+		if lastFound+1+gapLimit > end {
+			end = lastFound + 1 + gapLimit
+		}
+	}
+
+	if maxIndex > 0 && end > maxIndex+1 {
+		end = maxIndex + 1
+	}
+
+	return end
+}
+
+// logResult logs the highest index of the branch funds were found at, so the
+// user can decide whether the scan should be widened.
+func (b *scanBranchState) logResult(path string, gapLimit, maxIndex uint32) {
+	if len(b.FoundIndexes) == 0 {
+		log.Infof("No funds found in %s up to index %d", path,
+			b.NextIndex)
+		return
+	}
+
+	lastFound := b.FoundIndexes[len(b.FoundIndexes)-1]
+	log.Infof("Highest index with funds in %s is %d, scanned up to "+
+		"index %d", path, lastFound, b.NextIndex)
+
+	if maxIndex > 0 && lastFound+1+gapLimit > maxIndex+1 {
+		log.Warnf("Scan of %s stopped at --max-index %d, there "+
+			"might be funds at higher indexes", path, maxIndex)
+	}
+}
+
+// logProgress logs the progress of the scan of the branch if the progress
+// interval was reached or the scan is complete.
+func (b *scanBranchState) logProgress(path string, endIndex uint32) {
+	if b.NextIndex%scanProgressInterval != 0 && b.NextIndex != endIndex {
+		return
+	}
+
+	log.Infof("Scanned %s up to index %d of %d: %d addresses checked, "+
+		"%d sats found at %d index(es)", path, b.NextIndex, endIndex,
+		b.AddressesChecked, b.FundsFound, len(b.FoundIndexes))
+}
+
+// scanWorkers returns the number of goroutines a scan should query the chain
+// backend with. bitcoind can only run a single scantxoutset at a time, the
+// other backends are queried with one worker per CPU.
+func scanWorkers() int {
+	if ChainBackend == btc.ChainBackendBitcoind {
+		return 1
+	}
+
+	return runtime.GOMAXPROCS(0)
+}
+
+// rootKeyFingerprint returns the hex encoded BIP32 fingerprint of the given
+// root key.
+func rootKeyFingerprint(extendedKey *hdkeychain.ExtendedKey) (string, error) {
+	rootPubKey, err := extendedKey.ECPubKey()
+	if err != nil {
+		return "", fmt.Errorf("error deriving root pubkey: %w", err)
+	}
+
+	fingerprint := btcutil.Hash160(rootPubKey.SerializeCompressed())[:4]
+	return hex.EncodeToString(fingerprint), nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/guggero/chantools/lnd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanStateResume(t *testing.T) {
+	h := newHarness(t)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+
+	// The fake chain backend doesn't know about any funds, so every
+	// address query is a single request.
+	chain := newFakeChainBackend()
+	apiURL := chain.serve(t)
+
+	stateFile := h.tempFile("scan-state.json")
+	err = sweepRemoteClosed(
+		extendedKey, apiURL, "", 60, 0, 0, 10, 0, false, false,
+		false, stateFile,
+	)
+	require.ErrorContains(t, err, "found 0 sweep targets")
+	require.EqualValues(
+		t, 60*sweepRemoteClosedAddrsPerKey,
+		chain.numRequests("/address/"),
+	)
+	h.assertLogContains("No funds found in m/1017'/1'/3'/0 up to index 60")
+	h.assertLogContains("up to index 50 of 60: 150 addresses checked")
+	h.assertLogContains("up to index 60 of 60: 180 addresses checked")
+
+	state, err := loadScanState(stateFile, extendedKey)
+	require.NoError(t, err)
+	require.Len(t, state.Branches, 1)
+	for _, branch := range state.Branches {
+		require.EqualValues(t, 60, branch.NextIndex)
+		require.EqualValues(t, 60, branch.Gap)
+		require.EqualValues(t, 180, branch.AddressesChecked)
+		require.Empty(t, branch.FoundIndexes)
+	}
+
+	// Resuming with a bigger recovery window only scans the new indexes.
+	numRequests := chain.numRequests("/address/")
+	err = sweepRemoteClosed(
+		extendedKey, apiURL, "", 70, 0, 0, 10, 0, false, false,
+		false, stateFile,
+	)
+	require.ErrorContains(t, err, "found 0 sweep targets")
+	require.EqualValues(
+		t, 10*sweepRemoteClosedAddrsPerKey,
+		chain.numRequests("/address/")-numRequests,
+	)
+	h.assertLogContains("Resuming scan from state file")
+
+	// A scan can't be resumed with a different root key.
+	otherKey, err := extendedKey.Derive(0)
+	require.NoError(t, err)
+	_, err = loadScanState(stateFile, otherKey)
+	require.ErrorContains(t, err, "cannot resume with root key")
+}
+
+func TestScanBranchScanEnd(t *testing.T) {
+	h := newHarness(t)
+
+	// Without any funds, only the minimum number of indexes is scanned.
+	branch := &scanBranchState{}
+	require.EqualValues(t, 200, branch.scanEnd(200, 20, 0))
+
+	// Funds close to the end of the minimum range extend the scan by the
+	// gap limit, funds further down don't.
+	branch.FoundIndexes = []uint32{5, 190}
+	require.EqualValues(t, 211, branch.scanEnd(200, 20, 0))
+	require.EqualValues(t, 200, branch.scanEnd(200, 5, 0))
+
+	// The maximum index is a hard ceiling.
+	require.EqualValues(t, 206, branch.scanEnd(200, 20, 205))
+	require.EqualValues(t, 101, branch.scanEnd(200, 20, 100))
+
+	branch.NextIndex = 206
+	branch.logResult("m/0", 20, 205)
+	h.assertLogContains("Highest index with funds in m/0 is 190, scanned " +
+		"up to index 206")
+	h.assertLogContains("Scan of m/0 stopped at --max-index 205")
+}
+
+func TestScanStateParallel(t *testing.T) {
+	h := newHarness(t)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	state, err := loadScanState("", extendedKey)
+	require.NoError(t, err)
+
+	// Funds at index 25 extend the scan of the first 30 indexes to 46,
+	// the funds at index 50 are beyond the gap and must not be found even
+	// though the queries of a batch run in parallel.
+	var numQueries int32
+	query := func(index uint32) (interface{}, uint64, error) {
+		atomic.AddInt32(&numQueries, 1)
+
+		switch index {
+		case 3, 25, 50:
+			return index, 1000, nil
+
+		default:
+			return nil, 0, nil
+		}
+	}
+	var found []uint32
+	err = state.scan(
+		"m/0", 30, 20, 0, 2, 8, query,
+		func(index uint32, result interface{}) {
+			if result != nil {
+				require.Equal(t, index, result)
+				found = append(found, index)
+			}
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{3, 25}, found)
+	require.EqualValues(t, 46, numQueries)
+
+	branch := state.branch("m/0")
+	require.EqualValues(t, 46, branch.NextIndex)
+	require.EqualValues(t, 20, branch.Gap)
+	require.EqualValues(t, 92, branch.AddressesChecked)
+	require.EqualValues(t, 2000, branch.FundsFound)
+	h.assertLogContains("Highest index with funds in m/0 is 25, scanned " +
+		"up to index 46")
+
+	// An error stops the scan after the indexes before the failed one.
+	query = func(index uint32) (interface{}, uint64, error) {
+		if index == 60 {
+			return nil, 0, errors.New("query failed")
+		}
+		return nil, 0, nil
+	}
+	err = state.scan(
+		"m/1", 100, 20, 0, 2, 8, query, func(uint32, interface{}) {},
+	)
+	require.ErrorContains(t, err, "query failed")
+	require.EqualValues(t, 60, state.branch("m/1").NextIndex)
+}
+
+func BenchmarkScanState(b *testing.B) {
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(b, err)
+	branchKey, err := lnd.DeriveChildren(extendedKey, []uint32{
+		lnd.HardenedKey(1017), lnd.HardenedKey(1), lnd.HardenedKey(3),
+		0,
+	})
+	require.NoError(b, err)
+
+	// Deriving the key and address of an index is the part of the scan
+	// that is done in parallel (together with the chain backend query).
+	query := func(index uint32) (interface{}, uint64, error) {
+		childKey, err := branchKey.DeriveNonStandard(index)
+		if err != nil {
+			return nil, 0, err
+		}
+		pubKey, err := childKey.ECPubKey()
+		if err != nil {
+			return nil, 0, err
+		}
+		addr, err := lnd.P2WKHAddr(
+			pubKey, &chaincfg.RegressionNetParams,
+		)
+		return addr, 0, err
+	}
+
+	workers := []int{1}
+	if numCPUs := runtime.GOMAXPROCS(0); numCPUs > 1 {
+		workers = append(workers, numCPUs)
+	}
+	for _, numWorkers := range workers {
+		numWorkers := numWorkers
+		name := fmt.Sprintf("workers=%d", numWorkers)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				state, err := loadScanState("", extendedKey)
+				require.NoError(b, err)
+				err = state.scan(
+					"m/0", 1000, 0, 0, 1, numWorkers, query,
+					func(uint32, interface{}) {},
+				)
+				require.NoError(b, err)
+			}
+		})
+	}
+}
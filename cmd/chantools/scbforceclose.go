@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/chanbackup"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// The masks and values below are used to detect commitment
+	// transactions: lnd encodes the obfuscated state number in the lower
+	// 24 bits of the sequence and lock time and sets the upper 8 bits to
+	// fixed values.
+	commitSequenceMask  = 0xff000000
+	commitSequenceValue = 0x80000000
+	commitLockTimeMask  = 0xff000000
+	commitLockTimeValue = 0x20000000
+)
+
+type scbForceCloseCommand struct {
+	APIURL    string
+	MultiFile string
+
+	rootKey *rootKey
+	cmd     *cobra.Command
+}
+
+func newScbForceCloseCommand() *cobra.Command {
+	cc := &scbForceCloseCommand{}
+	cc.cmd = &cobra.Command{
+		Use: "scbforceclose",
+		Short: "Check which channels of a channel.backup file can be " +
+			"force-closed and how",
+		Long: `If the channel.db of a node is lost but the
+channel.backup file survived, this command decrypts the backup with the root
+key and checks the on-chain state of the funding output of every channel in
+it.
+
+A channel.backup file does NOT contain the latest commitment transaction or the
+remote party's signature for it, so a channel can NOT be force-closed by us from
+the backup alone. Instead the remote party needs to force-close the channel.
+This is what lnd does when the backup is restored with
+'lncli restorechanbackup': it connects to each peer and asks them to
+force-close. For each channel the command reports:
+ - funding unconfirmed: the funding transaction never confirmed, so there are
+   no channel funds to recover.
+ - open: the funding output is unspent; restore the backup in lnd or contact
+   the peer and ask them to force-close the channel.
+ - force-closed: the funding output was spent by a commitment transaction that
+   still has unspent outputs; the funds can be swept with the
+   sweepremoteclosed command once it confirmed.
+ - fully resolved: the funding output was spent by a cooperative close
+   transaction or by a commitment transaction whose outputs (except for
+   anchors) were all swept already. These channels are skipped as there is
+   nothing left to do.`,
+		Example: `chantools scbforceclose \
+	--multi_file ~/.lnd/data/chain/bitcoin/mainnet/channel.backup`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
+			"be esplora compatible)",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.MultiFile, "multi_file", "", "lnd channel.backup file "+
+			"(or a directory of channel backup files) to check "+
+			"the channels of",
+	)
+
+	cc.rootKey = newRootKey(cc.cmd, "decrypting the backup")
+
+	return cc.cmd
+}
+
+func (c *scbForceCloseCommand) Execute(_ *cobra.Command, _ []string) error {
+	extendedKey, err := c.rootKey.read()
+	if err != nil {
+		return fmt.Errorf("error reading root key: %w", err)
+	}
+
+	// Check that we have a backup file.
+	if c.MultiFile == "" {
+		return fmt.Errorf("backup file is required")
+	}
+	keyRing := &lnd.HDKeyRing{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+	multi, err := extractMulti(c.MultiFile, keyRing)
+	if err != nil {
+		return err
+	}
+
+	api, err := newChainBackend(c.APIURL)
+	if err != nil {
+		return err
+	}
+	var (
+		result      strings.Builder
+		numResolved int
+	)
+	for idx := range multi.StaticBackups {
+		single := multi.StaticBackups[idx]
+		status, description, err := scbChannelState(api, &single)
+		if err != nil {
+			return fmt.Errorf("error checking channel %v: %w",
+				single.FundingOutpoint, err)
+		}
+
+		// Channels that are fully resolved don't need any further
+		// action, so we don't want them to show up as something that
+		// still needs to be swept.
+		if status == scbStatusResolved {
+			numResolved++
+			description += ", skipping"
+		}
+
+		result.WriteString(fmt.Sprintf(
+			"Channel %v with peer %x (%d sats): %s: %s\n",
+			single.FundingOutpoint,
+			single.RemoteNodePub.SerializeCompressed(),
+			single.Capacity, status, description,
+		))
+	}
+	result.WriteString(fmt.Sprintf(
+		"\n%d of %d channel(s) need action, %d channel(s) are already "+
+			"fully resolved and were skipped\n",
+		len(multi.StaticBackups)-numResolved,
+		len(multi.StaticBackups), numResolved,
+	))
+
+	fmt.Println(result.String())
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(result.String())
+
+	return nil
+}
+
+// scbChannelStatus is the on-chain status of a channel from a backup.
+type scbChannelStatus uint8
+
+const (
+	// scbStatusUnconfirmed means the funding transaction of the channel
+	// never confirmed.
+	scbStatusUnconfirmed scbChannelStatus = iota
+
+	// scbStatusOpen means the funding output is still unspent.
+	scbStatusOpen
+
+	// scbStatusForceClosed means the channel was force-closed and the
+	// commitment transaction still has outputs that can be swept.
+	scbStatusForceClosed
+
+	// scbStatusResolved means the channel was closed cooperatively or it
+	// was force-closed and all outputs were swept already.
+	scbStatusResolved
+)
+
+// String returns a human readable name of the status.
+func (s scbChannelStatus) String() string {
+	switch s {
+	case scbStatusUnconfirmed:
+		return "funding unconfirmed"
+
+	case scbStatusOpen:
+		return "open"
+
+	case scbStatusForceClosed:
+		return "force-closed"
+
+	case scbStatusResolved:
+		return "fully resolved"
+
+	default:
+		return fmt.Sprintf("unknown status %d", uint8(s))
+	}
+}
+
+// scbChannelState looks up the funding output of the channel on chain and
+// returns its status and a description of what needs to be done to recover
+// the funds.
+func scbChannelState(api btc.ChainBackend,
+	single *chanbackup.Single) (scbChannelStatus, string, error) {
+
+	op := single.FundingOutpoint
+	fundingTx, err := api.Transaction(op.Hash.String())
+	switch {
+	case errors.Is(err, btc.ErrTxNotFound):
+		return scbStatusUnconfirmed, "the funding TX is not known to " +
+			"the chain backend, it was never confirmed or was " +
+			"double spent, there are no channel funds to " +
+			"recover", nil
+
+	case err != nil:
+		return 0, "", fmt.Errorf("error fetching funding TX: %w", err)
+	}
+	if int(op.Index) >= len(fundingTx.Vout) {
+		return 0, "", fmt.Errorf("invalid funding output index %d",
+			op.Index)
+	}
+
+	if fundingTx.Status != nil && !fundingTx.Status.Confirmed {
+		return scbStatusUnconfirmed, "the funding TX is still in the " +
+			"mempool, wait for it to confirm", nil
+	}
+
+	outspend := fundingTx.Vout[op.Index].Outspend
+	if outspend == nil || !outspend.Spent {
+		return scbStatusOpen, "restore the backup in lnd or ask the " +
+			"peer to force-close the channel", nil
+	}
+
+	closeTx, err := api.Transaction(outspend.Txid)
+	if err != nil {
+		return 0, "", fmt.Errorf("error fetching closing TX: %w", err)
+	}
+
+	confirmed := "unconfirmed"
+	if closeTx.Status != nil && closeTx.Status.Confirmed {
+		confirmed = fmt.Sprintf(
+			"confirmed at height %d", closeTx.Status.BlockHeight,
+		)
+	}
+
+	if !isCommitmentTx(closeTx) {
+		return scbStatusResolved, fmt.Sprintf("cooperatively closed "+
+			"in TX %s (%s), the funds were paid to the wallet",
+			closeTx.TXID, confirmed), nil
+	}
+
+	// Anchor outputs can be left unspent forever, so they don't count as
+	// something that still needs to be swept.
+	var numOutputs, numUnspent int
+	for _, vout := range closeTx.Vout {
+		if vout.Value == anchorOutputValue {
+			continue
+		}
+
+		numOutputs++
+		if vout.Outspend == nil || !vout.Outspend.Spent {
+			numUnspent++
+		}
+	}
+	if numUnspent == 0 {
+		return scbStatusResolved, fmt.Sprintf("force-closed in TX %s "+
+			"(%s), all outputs were swept already", closeTx.TXID,
+			confirmed), nil
+	}
+
+	return scbStatusForceClosed, fmt.Sprintf("force-closed in TX %s "+
+		"(%s), sweep the funds with sweepremoteclosed (%d of %d "+
+		"output(s) unspent)", closeTx.TXID, confirmed, numUnspent,
+		numOutputs), nil
+}
+
+// isCommitmentTx returns true if the transaction looks like an lnd commitment
+// transaction, judging by the obfuscated state hint in its sequence and lock
+// time.
+func isCommitmentTx(tx *btc.TX) bool {
+	if len(tx.Vin) != 1 {
+		return false
+	}
+
+	return tx.Vin[0].Sequence&commitSequenceMask == commitSequenceValue &&
+		tx.Locktime&commitLockTimeMask == commitLockTimeValue
+}
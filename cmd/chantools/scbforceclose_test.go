@@ -0,0 +1,185 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	scbFundingTxid = "10279f62619634058b6133cb7ac6c1693a8e6df7caa91c6263" +
+		"ca3d0bf704ad4d"
+	scbCloseTxid = "1111111111111111111111111111111111111111111111111111" +
+		"111111111111"
+)
+
+// scbTestChain describes the on-chain state the fake chain backend reports for
+// all channels in the test channel DB.
+type scbTestChain struct {
+	// fundingMissing makes the backend report all funding TXs as unknown.
+	fundingMissing bool
+
+	// fundingStatus is the confirmation status of the funding TXs. They
+	// are confirmed if it is nil.
+	fundingStatus *btc.Status
+
+	// closeTx, if set, spends all funding outputs.
+	closeTx *btc.TX
+
+	// closeSwept marks all outputs of the close TX as spent.
+	closeSwept bool
+}
+
+// newScbTestChain returns a fake chain backend that knows the funding TXs of
+// the channels in the test channel DB in the given state.
+func newScbTestChain(t *testing.T, h *harness,
+	state *scbTestChain) *fakeChainBackend {
+
+	if state.fundingMissing {
+		return newFakeChainBackend()
+	}
+
+	fundingStatus := state.fundingStatus
+	if fundingStatus == nil {
+		fundingStatus = &btc.Status{Confirmed: true}
+	}
+	chain, chanPoints := newFundingTestChain(t, h, fundingStatus)
+	if state.closeTx == nil {
+		return chain
+	}
+
+	chain.addAPITx(state.closeTx)
+	for _, chanPoint := range chanPoints {
+		chain.spend(t, chanPoint, scbCloseTxid, nil)
+	}
+
+	if state.closeSwept {
+		closeHash, err := chainhash.NewHashFromStr(scbCloseTxid)
+		require.NoError(t, err)
+
+		for idx := range state.closeTx.Vout {
+			chain.spend(t, wire.OutPoint{
+				Hash:  *closeHash,
+				Index: uint32(idx),
+			}, scbCloseTxid, nil)
+		}
+	}
+
+	return chain
+}
+
+func TestScbForceClose(t *testing.T) {
+	h := newHarness(t)
+
+	// Create a channel backup from a channel DB file.
+	makeBackup := &chanBackupCommand{
+		ChannelDB: h.testdataFile("channel.db"),
+		MultiFile: h.tempFile("extracted.backup"),
+		rootKey:   &rootKey{RootKey: rootKeyAezeed},
+	}
+	err := makeBackup.Execute(nil, nil)
+	require.NoError(t, err)
+
+	forceCloseTx := &btc.TX{
+		TXID:     scbCloseTxid,
+		Locktime: 0x20123456,
+		Vin:      []*btc.Vin{{Sequence: 0x80abcdef}},
+		Vout: []*btc.Vout{
+			{Value: anchorOutputValue},
+			{Value: 99_000},
+		},
+		Status: &btc.Status{
+			Confirmed:   true,
+			BlockHeight: 700_000,
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		chain    *scbTestChain
+		expected []string
+	}{{
+		name:  "open",
+		chain: &scbTestChain{},
+		expected: []string{
+			": open: restore the backup in lnd",
+			"need action, 0 channel(s) are already fully resolved",
+		},
+	}, {
+		name:  "funding missing",
+		chain: &scbTestChain{fundingMissing: true},
+		expected: []string{
+			": funding unconfirmed: the funding TX is not known",
+		},
+	}, {
+		name: "funding unconfirmed",
+		chain: &scbTestChain{
+			fundingStatus: &btc.Status{},
+		},
+		expected: []string{
+			": funding unconfirmed: the funding TX is still in " +
+				"the mempool",
+		},
+	}, {
+		name:  "force-closed",
+		chain: &scbTestChain{closeTx: forceCloseTx},
+		expected: []string{
+			": force-closed: force-closed in TX " + scbCloseTxid +
+				" (confirmed at height 700000), sweep the " +
+				"funds with sweepremoteclosed (1 of 1 " +
+				"output(s) unspent)",
+		},
+	}, {
+		name: "force-closed and swept",
+		chain: &scbTestChain{
+			closeTx:    forceCloseTx,
+			closeSwept: true,
+		},
+		expected: []string{
+			": fully resolved: force-closed in TX " + scbCloseTxid +
+				" (confirmed at height 700000), all outputs " +
+				"were swept already, skipping",
+			"\n0 of ",
+		},
+	}, {
+		name: "coop-closed",
+		chain: &scbTestChain{
+			closeTx: &btc.TX{
+				TXID: scbCloseTxid,
+				Vin: []*btc.Vin{{
+					Sequence: 0xffffffff,
+				}},
+				Vout: []*btc.Vout{{Value: 99_000}},
+			},
+		},
+		expected: []string{
+			": fully resolved: cooperatively closed in TX " +
+				scbCloseTxid + " (unconfirmed), the funds " +
+				"were paid to the wallet, skipping",
+		},
+	}}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			chain := newScbTestChain(t, h, tc.chain)
+
+			h.clearLog()
+			scbForceClose := &scbForceCloseCommand{
+				APIURL:    chain.serve(t),
+				MultiFile: makeBackup.MultiFile,
+				rootKey:   &rootKey{RootKey: rootKeyAezeed},
+			}
+			err := scbForceClose.Execute(nil, nil)
+			require.NoError(t, err)
+
+			h.assertLogContains("Channel " + scbFundingTxid + ":0")
+			for _, expected := range tc.expected {
+				h.assertLogContains(expected)
+			}
+		})
+	}
+}
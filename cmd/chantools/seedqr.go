@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/guggero/chantools/bip39"
+	"github.com/spf13/cobra"
+	"rsc.io/qr"
+	"rsc.io/qr/coding"
+)
+
+const (
+	seedQRStandard = "standard"
+	seedQRCompact  = "compact"
+
+	// seedQRPNGScale is the number of image pixels per QR code module
+	// of the PNG image.
+	seedQRPNGScale = 8
+
+	// seedQRQuietZone is the number of light modules around the QR code
+	// in the terminal rendering.
+	seedQRQuietZone = 2
+)
+
+// addSeedQRFlags adds the flags to render a mnemonic as a SeedQR to the given
+// command.
+func addSeedQRFlags(cmd *cobra.Command, format, pngFile *string) {
+	cmd.Flags().StringVar(
+		format, "seedqr", "", "also show the mnemonic as a SeedQR "+
+			"code that can be scanned by hardware wallets like "+
+			"SeedSigner; must be '"+seedQRStandard+"' or '"+
+			seedQRCompact+"'",
+	)
+	cmd.Flags().StringVar(
+		pngFile, "seedqr-png", "", "write the SeedQR code as PNG "+
+			"image to the given file instead of showing it in "+
+			"the terminal; uses the standard format if --seedqr "+
+			"is not set",
+	)
+}
+
+// seedQR encodes the mnemonic as a SeedQR code in the given format. If a PNG
+// file name is given, the code is written to that file and a note is
+// returned. Otherwise the code is rendered for the terminal.
+func seedQR(mnemonic, format, pngFile string) (string, error) {
+	if format == "" {
+		format = seedQRStandard
+	}
+
+	// The standard format uses the numeric mode, the compact one the byte
+	// mode, as the specification requires it.
+	var payload coding.Encoding
+	switch format {
+	case seedQRStandard:
+		digits, err := bip39.SeedQRStandard(mnemonic)
+		if err != nil {
+			return "", err
+		}
+		payload = coding.Num(digits)
+
+	case seedQRCompact:
+		entropy, err := bip39.SeedQRCompact(mnemonic)
+		if err != nil {
+			return "", err
+		}
+		payload = coding.String(entropy)
+
+	default:
+		return "", fmt.Errorf("unknown SeedQR format %s, must be one "+
+			"of %s or %s", format, seedQRStandard, seedQRCompact)
+	}
+
+	code, err := encodeQR(payload)
+	if err != nil {
+		return "", fmt.Errorf("error encoding SeedQR: %w", err)
+	}
+
+	if pngFile != "" {
+		img := &qr.Code{
+			Bitmap: code.Bitmap,
+			Size:   code.Size,
+			Stride: code.Stride,
+			Scale:  seedQRPNGScale,
+		}
+		err := ioutil.WriteFile(pngFile, img.PNG(), 0600)
+		if err != nil {
+			return "", fmt.Errorf("error writing SeedQR image: %w",
+				err)
+		}
+
+		return fmt.Sprintf("SeedQR (%s) written to %s\n", format,
+			pngFile), nil
+	}
+
+	return fmt.Sprintf("SeedQR (%s):\n%s", format, renderQR(code)), nil
+}
+
+// encodeQR encodes the payload in the smallest QR code version that fits it
+// with the lowest error correction level, as SeedQR codes are defined.
+func encodeQR(payload coding.Encoding) (*coding.Code, error) {
+	minVersion := coding.Version(coding.MinVersion)
+	for v := minVersion; v <= coding.MaxVersion; v++ {
+		if payload.Bits(v) > v.DataBytes(coding.L)*8 {
+			continue
+		}
+
+		plan, err := coding.NewPlan(v, coding.L, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return plan.Encode(payload)
+	}
+
+	return nil, fmt.Errorf("payload too large for a QR code")
+}
+
+// renderQR renders the QR code with unicode block characters, two modules per
+// character. Dark modules are drawn in the background color of the terminal,
+// so the code can be scanned from terminals with a dark background.
+func renderQR(code *coding.Code) string {
+	light := func(x, y int) bool {
+		return !code.Black(x-seedQRQuietZone, y-seedQRQuietZone)
+	}
+
+	var result strings.Builder
+	size := code.Size + 2*seedQRQuietZone
+	for y := 0; y < size; y += 2 {
+		for x := 0; x < size; x++ {
+			top := light(x, y)
+			bottom := y+1 < size && light(x, y+1)
+
+			switch {
+			case top && bottom:
+				result.WriteString("█")
+
+			case top:
+				result.WriteString("▀")
+
+			case bottom:
+				result.WriteString("▄")
+
+			default:
+				result.WriteString(" ")
+			}
+		}
+		result.WriteString("\n")
+	}
+
+	return result.String()
+}
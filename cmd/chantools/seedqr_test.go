@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/guggero/chantools/bip39"
+	"github.com/stretchr/testify/require"
+	"rsc.io/qr/coding"
+)
+
+func TestSeedQRSizes(t *testing.T) {
+	mnemonic24, err := bip39.EntropyToMnemonic(make([]byte, 32))
+	require.NoError(t, err)
+
+	// The SeedQR specification defines the size of the code for each
+	// format and mnemonic length.
+	testCases := []struct {
+		mnemonic string
+		format   string
+		size     int
+	}{{
+		mnemonic: seedBip39,
+		format:   seedQRStandard,
+		size:     25,
+	}, {
+		mnemonic: seedBip39,
+		format:   seedQRCompact,
+		size:     21,
+	}, {
+		mnemonic: mnemonic24,
+		format:   seedQRStandard,
+		size:     29,
+	}, {
+		mnemonic: mnemonic24,
+		format:   seedQRCompact,
+		size:     25,
+	}}
+
+	for _, tc := range testCases {
+		var payload coding.Encoding
+		if tc.format == seedQRStandard {
+			digits, err := bip39.SeedQRStandard(tc.mnemonic)
+			require.NoError(t, err)
+			payload = coding.Num(digits)
+		} else {
+			entropy, err := bip39.SeedQRCompact(tc.mnemonic)
+			require.NoError(t, err)
+			payload = coding.String(entropy)
+		}
+
+		code, err := encodeQR(payload)
+		require.NoError(t, err)
+		require.Equal(t, tc.size, code.Size)
+
+		// Two modules are rendered per line, including the quiet
+		// zone.
+		rendered, err := seedQR(tc.mnemonic, tc.format, "")
+		require.NoError(t, err)
+		numLines := strings.Count(rendered, "\n") - 1
+		require.Equal(
+			t, (tc.size+2*seedQRQuietZone+1)/2, numLines,
+		)
+	}
+}
+
+func TestSeedQRPNG(t *testing.T) {
+	h := newHarness(t)
+
+	pngFile := h.tempFile("seedqr.png")
+	gen := &genMnemonicCommand{
+		Entropy:   testEntropyBip39,
+		SeedQRPNG: pngFile,
+	}
+	err := gen.Execute(nil, nil)
+	require.NoError(t, err)
+	h.assertLogContains("SeedQR (standard) written to " + pngFile)
+
+	content, err := ioutil.ReadFile(pngFile)
+	require.NoError(t, err)
+	require.Equal(t, "\x89PNG", string(content[:4]))
+
+	// The terminal rendering can't be combined with JSON output.
+	gen = &genMnemonicCommand{
+		Entropy: testEntropyBip39,
+		JSON:    true,
+		SeedQR:  seedQRCompact,
+	}
+	err = gen.Execute(nil, nil)
+	require.ErrorContains(t, err, "can't be shown in the JSON format")
+
+	check := &checkMnemonicCommand{
+		Mnemonic: seedBip39,
+		SeedQR:   "mini",
+	}
+	err = check.Execute(nil, nil)
+	require.ErrorContains(t, err, "unknown SeedQR format mini")
+}
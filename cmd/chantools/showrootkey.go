@@ -3,14 +3,24 @@ package main
 import (
 	"fmt"
 
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/lnd"
 	"github.com/spf13/cobra"
 )
 
-const showRootKeyFormat = `
+const (
+	showRootKeyFormat = `
 Your BIP32 HD root key is: %v
 `
 
+	showPubKeyFormat = `
+Your extended public key for path %s is: %v
+`
+)
+
 type showRootKeyCommand struct {
+	PubPath string
+
 	rootKey *rootKey
 	cmd     *cobra.Command
 }
@@ -23,10 +33,29 @@ func newShowRootKeyCommand() *cobra.Command {
 			"word lnd aezeed",
 		Long: `This command converts the 24 word lnd aezeed phrase and
 password to the BIP32 HD root key that is used as the --rootkey flag in other
-commands of this tool.`,
-		Example: `chantools showrootkey`,
-		RunE:    cc.Execute,
+commands of this tool.
+
+If the --bip39 flag is set, a BIP39 mnemonic and optional passphrase (as used
+by most third-party wallets) is read instead of an lnd aezeed. Unlike with other
+commands, the mnemonic must consist of words of one of the official BIP39 word
+lists and have a valid checksum.
+
+If the --pub flag is set, only the extended public key of the given (account
+level) derivation path is shown instead of the root key. This can be used to
+set up watch-only wallets. For BIP49 and BIP84 paths the key is shown in the
+ypub/zpub format (upub/vpub on test networks).`,
+		Example: `chantools showrootkey
+
+chantools showrootkey --bip39
+
+chantools showrootkey --pub "m/84'/0'/0'"`,
+		RunE: cc.Execute,
 	}
+	cc.cmd.Flags().StringVar(
+		&cc.PubPath, "pub", "", "only show the extended public key "+
+			"of the given BIP32 derivation path instead of the "+
+			"root key; must start with \"m/\"",
+	)
 
 	cc.rootKey = newRootKey(cc.cmd, "decrypting the backup")
 
@@ -34,12 +63,25 @@ commands of this tool.`,
 }
 
 func (c *showRootKeyCommand) Execute(_ *cobra.Command, _ []string) error {
+	// We show the root key so it can be used for any other command, so we
+	// want to be sure the mnemonic was entered correctly.
+	c.rootKey.validateBIP39 = true
 	extendedKey, err := c.rootKey.read()
 	if err != nil {
 		return fmt.Errorf("error reading root key: %w", err)
 	}
 
-	result := fmt.Sprintf(showRootKeyFormat, extendedKey)
+	var result string
+	if c.PubPath != "" {
+		pubKey, err := deriveAccountPubKey(extendedKey, c.PubPath)
+		if err != nil {
+			return err
+		}
+
+		result = fmt.Sprintf(showPubKeyFormat, c.PubPath, pubKey)
+	} else {
+		result = fmt.Sprintf(showRootKeyFormat, extendedKey)
+	}
 	fmt.Println(result)
 
 	// For the tests, also log as trace level which is disabled by default.
@@ -47,3 +89,36 @@ func (c *showRootKeyCommand) Execute(_ *cobra.Command, _ []string) error {
 
 	return nil
 }
+
+// deriveAccountPubKey derives the extended key of the given path and returns
+// its neutered version, encoded in the format that matches the purpose of the
+// path.
+func deriveAccountPubKey(extendedKey *hdkeychain.ExtendedKey,
+	path string) (*hdkeychain.ExtendedKey, error) {
+
+	parsedPath, err := lnd.ParsePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse derivation path: %w",
+			err)
+	}
+
+	// Everything up to and including the account level should be
+	// hardened, otherwise a single leaked child private key together with
+	// the extended public key would allow deriving the parent private key.
+	for idx := 0; idx < len(parsedPath) && idx < 3; idx++ {
+		if parsedPath[idx] < lnd.HardenedKeyStart {
+			log.Warnf("Path %s is not hardened at level %d, "+
+				"leaking a single child private key together "+
+				"with the extended public key would expose "+
+				"all keys of the account", path, idx+1)
+			break
+		}
+	}
+
+	child, err := lnd.DeriveChildren(extendedKey, parsedPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not derive children: %w", err)
+	}
+
+	return lnd.NeuterForPurpose(child, parsedPath[0], chainParams)
+}
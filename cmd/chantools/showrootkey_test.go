@@ -1,8 +1,14 @@
 package main
 
 import (
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
 	"testing"
 
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/guggero/chantools/bip39"
 	"github.com/guggero/chantools/btc"
 	"github.com/guggero/chantools/lnd"
 	"github.com/stretchr/testify/require"
@@ -58,3 +64,303 @@ func TestShowRootKeyBIP39WithPassphrase(t *testing.T) {
 
 	h.assertLogContains(rootKeyBip39Passphrase)
 }
+
+func TestShowRootKeyBIP39Mainnet(t *testing.T) {
+	h := newHarness(t)
+
+	// The network flag must be honored for the version bytes.
+	chainParams = &chaincfg.MainNetParams
+
+	show := &showRootKeyCommand{
+		rootKey: &rootKey{BIP39: true},
+	}
+
+	t.Setenv(btc.BIP39MnemonicEnvName, seedBip39)
+	t.Setenv(btc.BIP39PassphraseEnvName, "-")
+
+	err := show.Execute(nil, nil)
+	require.NoError(t, err)
+
+	h.assertLogContains(
+		"xprv9s21ZrQH143K2zFhtrWXCLNFNprFbncpf9SVDgAZd1qY67bdX44Qkw" +
+			"nQFWDjJY2Kv4z3gWbrGhWBFEmSL3JKwM5e4PD4oVex6ZAtASwt9KM",
+	)
+}
+
+func TestShowRootKeyBIP39Invalid(t *testing.T) {
+	_ = newHarness(t)
+
+	show := &showRootKeyCommand{
+		rootKey: &rootKey{BIP39: true},
+	}
+
+	// Swapping two words breaks the checksum.
+	t.Setenv(btc.BIP39MnemonicEnvName, "bargain uncover diesel boss "+
+		"local host over divide orient cradle good crumble")
+	t.Setenv(btc.BIP39PassphraseEnvName, "-")
+
+	err := show.Execute(nil, nil)
+	require.ErrorIs(t, err, bip39.ErrChecksumIncorrect)
+}
+
+func TestShowRootKeyBIP39French(t *testing.T) {
+	h := newHarness(t)
+
+	show := &showRootKeyCommand{
+		rootKey: &rootKey{BIP39: true},
+	}
+
+	// The mnemonic is validated against all official word lists.
+	mnemonic := strings.Repeat("abaisser ", 11) + "abeille"
+	t.Setenv(btc.BIP39MnemonicEnvName, mnemonic)
+	t.Setenv(btc.BIP39PassphraseEnvName, "-")
+
+	err := show.Execute(nil, nil)
+	require.NoError(t, err)
+
+	rootKey, err := hdkeychain.NewMaster(
+		bip39.NewSeed(mnemonic, ""), chainParams,
+	)
+	require.NoError(t, err)
+	h.assertLogContains(rootKey.String())
+}
+
+func TestShowRootKeySLIP39(t *testing.T) {
+	h := newHarness(t)
+
+	// Recover the root key from two shares of a 2-of-3 SLIP-0039 share set
+	// of the official test vectors.
+	show := &showRootKeyCommand{
+		rootKey: &rootKey{SLIP39: true},
+	}
+
+	t.Setenv(btc.SLIP39SharesEnvName, "shadow pistol academic always "+
+		"adequate wildlife fancy gross oasis cylinder mustang wrist "+
+		"rescue view short owner flip making coding armed,\n"+
+		"shadow pistol academic acid actress prayer class unknown "+
+		"daughter sweater depict flip twice unkind craft early "+
+		"superior advocate guest smoking")
+	t.Setenv(btc.SLIP39PassphraseEnvName, "TREZOR")
+
+	err := show.Execute(nil, nil)
+	require.NoError(t, err)
+
+	secret, err := hex.DecodeString("b43ceb7e57a0ea8766221624d01b0864")
+	require.NoError(t, err)
+	rootKey, err := hdkeychain.NewMaster(secret, chainParams)
+	require.NoError(t, err)
+	h.assertLogContains(rootKey.String())
+
+	// Only one of the seed formats can be selected.
+	show.rootKey.BIP39 = true
+	err = show.Execute(nil, nil)
+	require.ErrorContains(t, err, "only one of --bip39 and --slip39")
+}
+
+func TestShowRootKeyPub(t *testing.T) {
+	h := newHarness(t)
+
+	show := &showRootKeyCommand{
+		PubPath: "m/84'/1'/0'",
+		rootKey: &rootKey{RootKey: rootKeyAezeed},
+	}
+
+	err := show.Execute(nil, nil)
+	require.NoError(t, err)
+
+	// The private root key must not be shown.
+	require.NotContains(t, h.getLog(), rootKeyAezeed)
+	h.assertLogContains("Your extended public key for path m/84'/1'/0' " +
+		"is: vpub")
+
+	// The vpub must encode the same key as the tpub of the account.
+	rootKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	path, err := lnd.ParsePath(show.PubPath)
+	require.NoError(t, err)
+	account, err := lnd.DeriveChildren(rootKey, path)
+	require.NoError(t, err)
+	accountPub, err := account.Neuter()
+	require.NoError(t, err)
+
+	vpub, err := deriveAccountPubKey(rootKey, show.PubPath)
+	require.NoError(t, err)
+	require.False(t, vpub.IsPrivate())
+
+	vpubKey, err := vpub.ECPubKey()
+	require.NoError(t, err)
+	accountPubKey, err := accountPub.ECPubKey()
+	require.NoError(t, err)
+	require.True(t, vpubKey.IsEqual(accountPubKey))
+	require.Equal(t, accountPub.ChainCode(), vpub.ChainCode())
+
+	// Non-SegWit purposes use the default tpub version.
+	show.PubPath = "m/1017'/1'/0'"
+	err = show.Execute(nil, nil)
+	require.NoError(t, err)
+	h.assertLogContains("is: tpub")
+
+	// Unhardened account levels result in a warning.
+	h.clearLog()
+	show.PubPath = "m/84'/1'/0"
+	err = show.Execute(nil, nil)
+	require.NoError(t, err)
+	h.assertLogContains("is not hardened at level 3")
+
+	show.PubPath = "m/"
+	err = show.Execute(nil, nil)
+	require.Error(t, err)
+}
+
+func TestShowRootKeySeedFile(t *testing.T) {
+	h := newHarness(t)
+
+	// The seed is read from a file, the passphrase from an empty custom
+	// environment variable, which means there is no passphrase.
+	seedFile := h.tempFile("seed.txt")
+	err := ioutil.WriteFile(
+		seedFile, []byte(seedAezeedNoPassphrase+"\n"), 0600,
+	)
+	require.NoError(t, err)
+
+	show := &showRootKeyCommand{
+		rootKey: &rootKey{
+			SeedFile:      seedFile,
+			PassphraseEnv: "MY_PASSPHRASE",
+		},
+	}
+
+	// The passphrase environment variable must be set.
+	err = show.Execute(nil, nil)
+	require.ErrorContains(t, err, "MY_PASSPHRASE is not set")
+
+	t.Setenv("MY_PASSPHRASE", "")
+	err = show.Execute(nil, nil)
+	require.NoError(t, err)
+
+	h.assertLogContains(rootKeyAezeed)
+}
+
+func TestShowRootKeyBIP39SeedFile(t *testing.T) {
+	h := newHarness(t)
+
+	seedFile := h.tempFile("seed.txt")
+	err := ioutil.WriteFile(seedFile, []byte(seedBip39+"\r\n"), 0600)
+	require.NoError(t, err)
+
+	show := &showRootKeyCommand{
+		rootKey: &rootKey{
+			BIP39:         true,
+			SeedFile:      seedFile,
+			PassphraseEnv: "MY_PASSPHRASE",
+		},
+	}
+
+	t.Setenv("MY_PASSPHRASE", testPassPhrase)
+
+	err = show.Execute(nil, nil)
+	require.NoError(t, err)
+
+	h.assertLogContains(rootKeyBip39Passphrase)
+}
+
+func TestShowRootKeyPassphrase(t *testing.T) {
+	h := newHarness(t)
+
+	seedFile := h.tempFile("seed.txt")
+	err := ioutil.WriteFile(seedFile, []byte(seedBip39+"\n"), 0600)
+	require.NoError(t, err)
+	passphraseFile := h.tempFile("passphrase.txt")
+	err = ioutil.WriteFile(
+		passphraseFile, []byte(testPassPhrase+"\n"), 0600,
+	)
+	require.NoError(t, err)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyBip39Passphrase)
+	require.NoError(t, err)
+	fingerprint, err := rootKeyFingerprint(extendedKey)
+	require.NoError(t, err)
+
+	// The passphrase can be read from the command line or from a file. In
+	// both cases the fingerprint of the master key is shown.
+	show := &showRootKeyCommand{
+		rootKey: &rootKey{
+			BIP39:      true,
+			SeedFile:   seedFile,
+			Passphrase: testPassPhrase,
+		},
+	}
+	require.NoError(t, show.Execute(nil, nil))
+	h.assertLogContains(rootKeyBip39Passphrase)
+	h.assertLogContains("Master key fingerprint of the seed with the " +
+		"given passphrase is " + fingerprint)
+	h.assertLogContains("The secret was passed on the command line with " +
+		"--passphrase")
+
+	h.clearLog()
+	show.rootKey.Passphrase = ""
+	show.rootKey.PassphraseFile = passphraseFile
+	require.NoError(t, show.Execute(nil, nil))
+	h.assertLogContains(rootKeyBip39Passphrase)
+	h.assertLogContains("passphrase is " + fingerprint)
+
+	// An empty file means there is no passphrase, so there is no
+	// fingerprint to check either.
+	h.clearLog()
+	err = ioutil.WriteFile(passphraseFile, []byte("\n"), 0600)
+	require.NoError(t, err)
+	require.NoError(t, show.Execute(nil, nil))
+	h.assertLogContains(rootKeyBip39)
+	require.NotContains(t, h.getLog(), "Master key fingerprint")
+
+	// Only one source for the passphrase can be given.
+	show.rootKey.PassphraseEnv = "MY_PASSPHRASE"
+	err = show.Execute(nil, nil)
+	require.ErrorContains(t, err, "only one of --passphrase, "+
+		"--passphrase-file and --passphrase-env can be set")
+
+	// A root key already contains the passphrase.
+	show.rootKey.PassphraseEnv = ""
+	err = ioutil.WriteFile(
+		seedFile, []byte(rootKeyBip39Passphrase+"\n"), 0600,
+	)
+	require.NoError(t, err)
+	err = show.Execute(nil, nil)
+	require.ErrorContains(t, err, "can't be combined with the root key")
+
+	show.rootKey.SeedFile = ""
+	show.rootKey.RootKey = rootKeyBip39Passphrase
+	err = show.Execute(nil, nil)
+	require.ErrorContains(t, err, "can't be combined with --rootkey")
+}
+
+func TestShowRootKeyFromArgs(t *testing.T) {
+	h := newHarness(t)
+
+	// A root key in a file is used as is.
+	keyFile := h.tempFile("rootkey.txt")
+	err := ioutil.WriteFile(keyFile, []byte(rootKeyBip39+"\n"), 0600)
+	require.NoError(t, err)
+
+	show := &showRootKeyCommand{
+		rootKey: &rootKey{SeedFile: keyFile},
+	}
+	err = show.Execute(nil, nil)
+	require.NoError(t, err)
+
+	h.assertLogContains(rootKeyBip39)
+	require.NotContains(t, h.getLog(), "WARNING")
+
+	// Passing the root key on the command line works but warns.
+	h.clearLog()
+	show.rootKey = &rootKey{RootKey: rootKeyBip39}
+	err = show.Execute(nil, nil)
+	require.NoError(t, err)
+
+	h.assertLogContains(rootKeyBip39)
+	h.assertLogContains("The secret was passed on the command line")
+
+	show.rootKey = &rootKey{RootKey: rootKeyBip39, SeedFile: keyFile}
+	err = show.Execute(nil, nil)
+	require.ErrorContains(t, err, "only one of --rootkey and --seed-file")
+}
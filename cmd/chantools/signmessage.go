@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/guggero/chantools/lnd"
+	"github.com/spf13/cobra"
+)
+
+const signMessageFormat = `
+Message:		%s
+Node public key:	%x
+Signature:		%s`
+
+type signMessageCommand struct {
+	Msg string
+
+	rootKey *rootKey
+	cmd     *cobra.Command
+}
+
+func newSignMessageCommand() *cobra.Command {
+	cc := &signMessageCommand{}
+	cc.cmd = &cobra.Command{
+		Use:   "signmessage",
+		Short: "Sign a message with the node's identity key",
+		Long: `Signs a message with the node identity key derived from
+the seed, without the need of a running lnd node. The signature uses the same
+scheme as lnd's signmessage RPC, so it can be verified with the verifymessage
+command of chantools or lncli.
+
+This can be used to prove control of a node to the other party of a zombie
+channel recovery.`,
+		Example: `chantools signmessage --msg "I own this node"`,
+		RunE:    cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.Msg, "msg", "", "the message to sign",
+	)
+
+	cc.rootKey = newRootKey(cc.cmd, "deriving the identity key")
+
+	return cc.cmd
+}
+
+func (c *signMessageCommand) Execute(_ *cobra.Command, _ []string) error {
+	if c.Msg == "" {
+		return fmt.Errorf("message to sign is required")
+	}
+
+	extendedKey, err := c.rootKey.read()
+	if err != nil {
+		return fmt.Errorf("error reading root key: %w", err)
+	}
+
+	_, pubKey, wif, err := lnd.DeriveKey(
+		extendedKey, lnd.IdentityPath(chainParams), chainParams,
+	)
+	if err != nil {
+		return fmt.Errorf("error deriving identity key: %w", err)
+	}
+
+	sig, err := lnd.SignMessage(wif.PrivKey, []byte(c.Msg))
+	if err != nil {
+		return err
+	}
+
+	result := fmt.Sprintf(
+		signMessageFormat, c.Msg, pubKey.SerializeCompressed(), sig,
+	)
+	fmt.Println(result)
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(result)
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/guggero/chantools/lnd"
+	"github.com/stretchr/testify/require"
+)
+
+const testMessage = "I own this node"
+
+func TestSignVerifyMessage(t *testing.T) {
+	h := newHarness(t)
+
+	sign := &signMessageCommand{
+		Msg:     testMessage,
+		rootKey: &rootKey{RootKey: rootKeyAezeed},
+	}
+	err := sign.Execute(nil, nil)
+	require.NoError(t, err)
+
+	extendedKey, err := sign.rootKey.read()
+	require.NoError(t, err)
+	_, pubKey, wif, err := lnd.DeriveKey(
+		extendedKey, lnd.IdentityPath(chainParams), chainParams,
+	)
+	require.NoError(t, err)
+	pubKeyHex := hex.EncodeToString(pubKey.SerializeCompressed())
+	h.assertLogContains(pubKeyHex)
+
+	sig, err := lnd.SignMessage(wif.PrivKey, []byte(testMessage))
+	require.NoError(t, err)
+	h.assertLogContains(sig)
+
+	// The signature must be valid for the node's own key.
+	h.clearLog()
+	verify := &verifyMessageCommand{
+		Msg:    testMessage,
+		Sig:    sig,
+		PubKey: pubKeyHex,
+	}
+	err = verify.Execute(nil, nil)
+	require.NoError(t, err)
+	h.assertLogContains("Signed by node:\t\t" + pubKeyHex)
+
+	// A different message recovers a different key.
+	verify.Msg = "I own another node"
+	err = verify.Execute(nil, nil)
+	require.ErrorContains(t, err, "not by the expected node")
+
+	verify.Sig = "notzbase32!"
+	err = verify.Execute(nil, nil)
+	require.ErrorContains(t, err, "error decoding signature")
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/guggero/chantools/btc"
 	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/input"
 	"github.com/lightningnetwork/lnd/keychain"
 	"github.com/spf13/cobra"
 )
@@ -15,6 +18,11 @@ import (
 type signRescueFundingCommand struct {
 	Psbt string
 
+	LocalPubKey  string
+	RemotePubKey string
+	Amount       int64
+	APIURL       string
+
 	rootKey *rootKey
 	cmd     *cobra.Command
 }
@@ -32,10 +40,18 @@ funding output that was created on chain by accident but never resulted in a
 proper channel and no commitment transactions exist to spend the funds locked in
 the 2-of-2 multisig.
 
+Before signing, the command makes sure the funding output spent by the PSBT pays
+to the 2-of-2 multisig script of the local and the given remote multisig public
+key and has the expected amount. The funding output is also looked up on chain
+to make sure the PSBT doesn't lie about the script or value of the output being
+spent. If any of these checks fail, nothing is signed.
+
 If successful, this will create a final on-chain transaction that can be
 broadcast by any Bitcoin node.`,
 		Example: `chantools signrescuefunding \
-	--psbt <the_base64_encoded_psbt_from_step_1>`,
+	--psbt <the_base64_encoded_psbt_from_step_1> \
+	--remotepubkey 0xxxxxxxxxxxxxxxx \
+	--amount 1000000`,
 		RunE: cc.Execute,
 	}
 	cc.cmd.Flags().StringVar(
@@ -43,6 +59,25 @@ broadcast by any Bitcoin node.`,
 			"that was provided by the initiator of the channel to "+
 			"rescue",
 	)
+	cc.cmd.Flags().StringVar(
+		&cc.LocalPubKey, "localpubkey", "", "the local multisig "+
+			"public key the funding output is expected to pay to; "+
+			"if set it must match the key the PSBT asks us to "+
+			"sign with",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.RemotePubKey, "remotepubkey", "", "the multisig public "+
+			"key of the initiator of the channel the funding "+
+			"output is expected to pay to",
+	)
+	cc.cmd.Flags().Int64Var(
+		&cc.Amount, "amount", 0, "the expected value of the funding "+
+			"output in satoshis",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
+			"be esplora compatible)",
+	)
 
 	cc.rootKey = newRootKey(cc.cmd, "deriving keys")
 
@@ -68,11 +103,62 @@ func (c *signRescueFundingCommand) Execute(_ *cobra.Command, _ []string) error {
 		return fmt.Errorf("error decoding PSBT: %w", err)
 	}
 
-	return signRescueFunding(extendedKey, packet, signer)
+	// We only sign if we know what the funding output should look like.
+	expected := &expectedRescueFunding{
+		amount: c.Amount,
+	}
+	if c.Amount <= 0 {
+		return fmt.Errorf("expected funding amount must be set with " +
+			"--amount")
+	}
+	if c.RemotePubKey == "" {
+		return fmt.Errorf("remote multisig public key must be set " +
+			"with --remotepubkey")
+	}
+	expected.remotePubKey, err = parsePubKeyHex(c.RemotePubKey)
+	if err != nil {
+		return fmt.Errorf("error parsing remote pubkey: %w", err)
+	}
+	if c.LocalPubKey != "" {
+		expected.localPubKey, err = parsePubKeyHex(c.LocalPubKey)
+		if err != nil {
+			return fmt.Errorf("error parsing local pubkey: %w", err)
+		}
+	}
+
+	api, err := newChainBackend(c.APIURL)
+	if err != nil {
+		return err
+	}
+	return signRescueFunding(extendedKey, packet, signer, api, expected)
+}
+
+// expectedRescueFunding describes the funding output the rescue transaction is
+// expected to spend.
+type expectedRescueFunding struct {
+	// localPubKey is our multisig key. If nil, the key the PSBT asks us to
+	// sign with is used.
+	localPubKey *btcec.PublicKey
+
+	// remotePubKey is the multisig key of the channel initiator.
+	remotePubKey *btcec.PublicKey
+
+	// amount is the value of the funding output in satoshis.
+	amount int64
+}
+
+// parsePubKeyHex parses a hex encoded compressed public key.
+func parsePubKeyHex(pubKeyHex string) (*btcec.PublicKey, error) {
+	pubKeyBytes, err := hex.DecodeString(pubKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	return btcec.ParsePubKey(pubKeyBytes)
 }
 
 func signRescueFunding(rootKey *hdkeychain.ExtendedKey,
-	packet *psbt.Packet, signer *lnd.Signer) error {
+	packet *psbt.Packet, signer *lnd.Signer, api btc.ChainBackend,
+	expected *expectedRescueFunding) error {
 
 	// First, we need to derive the correct branch from the local root key.
 	localMultisig, err := lnd.DeriveChildren(rootKey, []uint32{
@@ -123,6 +209,20 @@ func signRescueFunding(rootKey *hdkeychain.ExtendedKey,
 	}
 	utxo := packet.Inputs[0].WitnessUtxo
 
+	// Before we sign anything, make sure the PSBT spends exactly the
+	// funding output we expect, both according to the PSBT and on chain.
+	err = verifyRescueFunding(packet, localKeyDesc.PubKey, expected)
+	if err != nil {
+		return fmt.Errorf("refusing to sign: %w", err)
+	}
+	if err := verifyFundingOutputs(api, packet); err != nil {
+		return fmt.Errorf("refusing to sign: %w", err)
+	}
+	err = validatePsbt(api, packet, multiSigVSize(packet.UnsignedTx), 0)
+	if err != nil {
+		return fmt.Errorf("refusing to sign: %w", err)
+	}
+
 	err = signer.AddPartialSignature(
 		packet, *localKeyDesc, utxo, witnessScript, 0,
 	)
@@ -140,6 +240,11 @@ func signRescueFunding(rootKey *hdkeychain.ExtendedKey,
 	if err != nil {
 		return fmt.Errorf("unable to extract final TX: %w", err)
 	}
+	written, err := writeFinalTxOutput(packet, finalTx)
+	if err != nil || written {
+		return err
+	}
+
 	var buf bytes.Buffer
 	err = finalTx.Serialize(&buf)
 	if err != nil {
@@ -153,6 +258,53 @@ func signRescueFunding(rootKey *hdkeychain.ExtendedKey,
 	return nil
 }
 
+// verifyRescueFunding makes sure the single input of the rescue PSBT spends a
+// P2WSH output of the 2-of-2 multisig script of the local and remote key with
+// the expected value.
+func verifyRescueFunding(packet *psbt.Packet, localPubKey *btcec.PublicKey,
+	expected *expectedRescueFunding) error {
+
+	if expected.localPubKey != nil &&
+		!expected.localPubKey.IsEqual(localPubKey) {
+
+		return fmt.Errorf("PSBT asks us to sign with key %x but "+
+			"expected local key is %x",
+			localPubKey.SerializeCompressed(),
+			expected.localPubKey.SerializeCompressed())
+	}
+
+	expectedScript, err := input.GenMultiSigScript(
+		localPubKey.SerializeCompressed(),
+		expected.remotePubKey.SerializeCompressed(),
+	)
+	if err != nil {
+		return fmt.Errorf("error creating multisig script: %w", err)
+	}
+	expectedPkScript, err := input.WitnessScriptHash(expectedScript)
+	if err != nil {
+		return fmt.Errorf("error creating P2WSH script: %w", err)
+	}
+
+	pIn := packet.Inputs[0]
+	if !bytes.Equal(pIn.WitnessScript, expectedScript) {
+		return fmt.Errorf("witness script %x is not the 2-of-2 "+
+			"multisig script %x of the local and remote key",
+			pIn.WitnessScript, expectedScript)
+	}
+	if !bytes.Equal(pIn.WitnessUtxo.PkScript, expectedPkScript) {
+		return fmt.Errorf("funding output script %x doesn't pay to "+
+			"the expected multisig address %x",
+			pIn.WitnessUtxo.PkScript, expectedPkScript)
+	}
+	if pIn.WitnessUtxo.Value != expected.amount {
+		return fmt.Errorf("funding output has value %d sats, "+
+			"expected %d sats", pIn.WitnessUtxo.Value,
+			expected.amount)
+	}
+
+	return nil
+}
+
 func findLocalMultisigKey(multisigBranch *hdkeychain.ExtendedKey,
 	targetPubkey *btcec.PublicKey) (*keychain.KeyDescriptor, error) {
 
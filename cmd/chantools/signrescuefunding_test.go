@@ -0,0 +1,150 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/stretchr/testify/require"
+)
+
+const rescueTestValue = 500_000
+
+func TestSignRescueFunding(t *testing.T) {
+	_ = newHarness(t)
+
+	localKey, err := hdkeychain.NewKeyFromString(rootKeyBip39)
+	require.NoError(t, err)
+	remoteKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+
+	deriveKey := func(key *hdkeychain.ExtendedKey,
+		index uint32) keychain.KeyDescriptor {
+
+		ring := &lnd.HDKeyRing{
+			ExtendedKey: key,
+			ChainParams: chainParams,
+		}
+		desc, err := ring.DeriveKey(keychain.KeyLocator{
+			Family: keychain.KeyFamilyMultiSig,
+			Index:  index,
+		})
+		require.NoError(t, err)
+		return desc
+	}
+	localDesc, remoteDesc := deriveKey(localKey, 2), deriveKey(remoteKey, 4)
+
+	witnessScript, err := input.GenMultiSigScript(
+		localDesc.PubKey.SerializeCompressed(),
+		remoteDesc.PubKey.SerializeCompressed(),
+	)
+	require.NoError(t, err)
+	pkScript, err := input.WitnessScriptHash(witnessScript)
+	require.NoError(t, err)
+
+	fundingTx := wire.NewMsgTx(2)
+	fundingTx.TxOut = []*wire.TxOut{{
+		Value:    rescueTestValue,
+		PkScript: pkScript,
+	}}
+
+	// The initiator creates the PSBT and adds their signature, just like
+	// the rescuefunding command does.
+	newPacket := func(utxo *wire.TxOut, script []byte) *psbt.Packet {
+		tx := wire.NewMsgTx(2)
+		tx.TxIn = []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{
+				Hash: fundingTx.TxHash(),
+			},
+		}}
+		tx.TxOut = []*wire.TxOut{{
+			Value:    rescueTestValue - 1_000,
+			PkScript: pkScript,
+		}}
+		packet, err := psbt.NewFromUnsignedTx(tx)
+		require.NoError(t, err)
+
+		packet.Inputs[0].WitnessScript = script
+		packet.Inputs[0].WitnessUtxo = utxo
+		packet.Inputs[0].Unknowns = []*psbt.Unknown{{
+			Key:   PsbtKeyTypeOutputMissingSigPubkey,
+			Value: localDesc.PubKey.SerializeCompressed(),
+		}}
+
+		remoteSigner := &lnd.Signer{
+			ExtendedKey: remoteKey,
+			ChainParams: chainParams,
+		}
+		err = remoteSigner.AddPartialSignature(
+			packet, remoteDesc, utxo, script, 0,
+		)
+		require.NoError(t, err)
+
+		return packet
+	}
+
+	api := newFakeChainBackend()
+	api.addTx(t, fundingTx, nil)
+
+	signer := &lnd.Signer{
+		ExtendedKey: localKey,
+		ChainParams: chainParams,
+	}
+	expected := &expectedRescueFunding{
+		remotePubKey: remoteDesc.PubKey,
+		amount:       rescueTestValue,
+	}
+	sign := func(packet *psbt.Packet,
+		expected *expectedRescueFunding) error {
+
+		return signRescueFunding(
+			localKey, packet, signer, api, expected,
+		)
+	}
+
+	// The wrong amount is rejected.
+	err = sign(newPacket(fundingTx.TxOut[0], witnessScript),
+		&expectedRescueFunding{
+			remotePubKey: remoteDesc.PubKey,
+			amount:       rescueTestValue + 1,
+		})
+	require.ErrorContains(t, err, "expected 500001 sats")
+
+	// A different remote key is rejected.
+	err = sign(newPacket(fundingTx.TxOut[0], witnessScript),
+		&expectedRescueFunding{
+			remotePubKey: deriveKey(remoteKey, 5).PubKey,
+			amount:       rescueTestValue,
+		})
+	require.ErrorContains(t, err, "is not the 2-of-2 multisig script")
+
+	// A different local key is rejected.
+	err = sign(newPacket(fundingTx.TxOut[0], witnessScript),
+		&expectedRescueFunding{
+			localPubKey:  deriveKey(localKey, 3).PubKey,
+			remotePubKey: remoteDesc.PubKey,
+			amount:       rescueTestValue,
+		})
+	require.ErrorContains(t, err, "expected local key")
+
+	// A PSBT that lies about the value of the funding output is rejected
+	// because of the on-chain value, even if the user expects that value.
+	wrongUtxo := &wire.TxOut{
+		Value:    rescueTestValue * 2,
+		PkScript: pkScript,
+	}
+	err = sign(newPacket(wrongUtxo, witnessScript),
+		&expectedRescueFunding{
+			remotePubKey: remoteDesc.PubKey,
+			amount:       rescueTestValue * 2,
+		})
+	require.ErrorContains(t, err, "doesn't match the witness UTXO")
+
+	packet := newPacket(fundingTx.TxOut[0], witnessScript)
+	require.NoError(t, sign(packet, expected))
+	require.NotEmpty(t, packet.Inputs[0].FinalScriptWitness)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/guggero/chantools/bip39"
+	"github.com/spf13/cobra"
+)
+
+type splitSeedCommand struct {
+	Mnemonic string
+	Shares   int
+	JSON     bool
+
+	cmd *cobra.Command
+}
+
+func newSplitSeedCommand() *cobra.Command {
+	cc := &splitSeedCommand{}
+	cc.cmd = &cobra.Command{
+		Use:   "splitseed",
+		Short: "Split a BIP39 mnemonic into multiple XOR shares",
+		Long: `This command splits the entropy of a BIP39 mnemonic into
+the given number of shares that can be stored in different locations. All but
+one of the shares are random, the last one is the XOR of the entropy and all
+random shares. Each share is encoded as a BIP39 mnemonic of the same length as
+the original one, so it can be written down and checked the same way.
+
+**All shares are required** to recover the original mnemonic with the
+'chantools combineseed' command, a single lost share means the mnemonic can't
+be recovered anymore. Any subset of the shares reveals nothing about the
+original mnemonic.
+
+Only BIP39 mnemonics are supported, not the aezeed of lnd.`,
+		Example: `chantools splitseed --shares 3
+
+chantools splitseed --shares 2 --json`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.Mnemonic, "mnemonic", "", "the BIP39 mnemonic to split; "+
+			"leave empty to read it from the terminal or stdin",
+	)
+	cc.cmd.Flags().IntVar(
+		&cc.Shares, "shares", 2, "the number of shares to create",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.JSON, "json", false, "print the shares in the JSON format",
+	)
+
+	return cc.cmd
+}
+
+func (c *splitSeedCommand) Execute(_ *cobra.Command, _ []string) error {
+	mnemonic := c.Mnemonic
+	switch {
+	case mnemonic != "":
+		warnSecretOnCommandLine("mnemonic")
+
+	default:
+		fmt.Printf("Input your 12 to 24 word BIP39 mnemonic " +
+			"separated by spaces: ")
+		reader := bufio.NewReader(os.Stdin)
+
+		var err error
+		mnemonic, err = reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+
+	shares, err := splitMnemonic(mnemonic, c.Shares)
+	if err != nil {
+		return err
+	}
+
+	var result string
+	if c.JSON {
+		resultBytes, err := json.MarshalIndent(shares, "", " ")
+		if err != nil {
+			return fmt.Errorf("error encoding result: %w", err)
+		}
+		result = string(resultBytes)
+	} else {
+		for idx, share := range shares {
+			result += fmt.Sprintf("Share %d/%d:\t%s\n", idx+1,
+				len(shares), share)
+		}
+	}
+	fmt.Println(result)
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(result)
+
+	return nil
+}
+
+// splitMnemonic splits the entropy of the given BIP39 mnemonic into the given
+// number of XOR shares and returns each share encoded as a mnemonic.
+func splitMnemonic(mnemonic string, numShares int) ([]string, error) {
+	mnemonic = strings.TrimRight(mnemonic, "\r\n")
+	entropy, err := bip39.EntropyFromMnemonic(mnemonic)
+	if err != nil {
+		return nil, fmt.Errorf("mnemonic is invalid: %w", err)
+	}
+
+	shares, err := bip39.SplitEntropy(entropy, numShares)
+	if err != nil {
+		return nil, fmt.Errorf("error splitting entropy: %w", err)
+	}
+
+	result := make([]string, len(shares))
+	for idx, share := range shares {
+		result[idx], err = bip39.EntropyToMnemonic(share)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding share: %w", err)
+		}
+	}
+
+	return result, nil
+}
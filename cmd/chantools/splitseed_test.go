@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/guggero/chantools/bip39"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCombineSeed(t *testing.T) {
+	h := newHarness(t)
+
+	split := &splitSeedCommand{
+		Mnemonic: seedBip39,
+		Shares:   3,
+	}
+	err := split.Execute(nil, nil)
+	require.NoError(t, err)
+	h.assertLogContains("Share 1/3:\t")
+	h.assertLogContains("Share 3/3:\t")
+
+	shares, err := splitMnemonic(seedBip39, 3)
+	require.NoError(t, err)
+	require.Len(t, shares, 3)
+	for _, share := range shares {
+		require.True(t, bip39.IsMnemonicValid(share))
+		require.NotEqual(t, seedBip39, share)
+	}
+
+	h.clearLog()
+	combine := &combineSeedCommand{
+		Shares: []string{shares[2], shares[0], shares[1]},
+	}
+	err = combine.Execute(nil, nil)
+	require.NoError(t, err)
+	h.assertLogContains("Mnemonic:\t" + seedBip39)
+
+	// A missing share results in a different mnemonic.
+	mnemonic, err := combineMnemonics(shares[:2])
+	require.NoError(t, err)
+	require.NotEqual(t, seedBip39, mnemonic)
+
+	_, err = combineMnemonics(shares[:1])
+	require.ErrorContains(t, err, "need at least two shares")
+
+	_, err = splitMnemonic(seedBip39, 1)
+	require.ErrorIs(t, err, bip39.ErrInvalidShareCount)
+
+	_, err = splitMnemonic("uncover bargain diesel", 2)
+	require.ErrorIs(t, err, bip39.ErrInvalidWordCount)
+}
+
+func TestCombineSeedDifferentLengths(t *testing.T) {
+	mnemonic24, err := bip39.EntropyToMnemonic(make([]byte, 32))
+	require.NoError(t, err)
+	shares24, err := splitMnemonic(mnemonic24, 2)
+	require.NoError(t, err)
+	shares12, err := splitMnemonic(seedBip39, 2)
+	require.NoError(t, err)
+
+	_, err = combineMnemonics([]string{shares12[0], shares24[0]})
+	require.ErrorContains(t, err, "same number of words")
+}
@@ -12,7 +12,11 @@ import (
 )
 
 type summaryCommand struct {
-	APIURL string
+	APIURL     string
+	JSON       bool
+	ClosedOnly bool
+	OpenOnly   bool
+	HasBalance bool
 
 	inputs *inputFlags
 	cmd    *cobra.Command
@@ -25,9 +29,46 @@ func newSummaryCommand() *cobra.Command {
 		Short: "Compile a summary about the current state of " +
 			"channels",
 		Long: `From a list of channels, find out what their state is by
-querying the funding transaction on a block explorer API.`,
+querying the funding transaction on a block explorer API.
+
+With the --json flag, a JSON array with one object per channel is printed to
+stdout and all log output is suppressed, so the result can be processed by
+scripts. Each object has the following fields:
+ - channel_point: the funding outpoint of the channel.
+ - capacity: the capacity of the channel in satoshis.
+ - local_balance: our balance in the channel in satoshis.
+ - remote_balance: the remote peer's balance in the channel in satoshis.
+ - close_type: one of "none" (still open), "cooperative", "force" or "unknown"
+   (funding transaction not found).
+ - closing_txid: the ID of the closing transaction, if the channel is closed.
+ - sweep_status: one of "not_closed", "spent" (all outputs of the closing
+   transaction are spent), "unswept" (there are unspent outputs that
+   potentially belong to us), "not_ours" (the unspent outputs belong to the
+   remote peer) or "unknown".
+
+For force closed channels, the following fields are added if the CSV delay of
+our to_local output is known (from the channel DB, the csv_delay field of
+'lncli listchannels' or a previous summary/forceclose result file):
+ - csv_delay: the relative time lock of our to_local output in blocks.
+ - conf_height: the height of the block the commitment transaction confirmed
+   in.
+ - tip_height: the height of the current best block.
+ - mature_height: the height of the first block our to_local output can be
+   spent in.
+ - blocks_until_mature: the number of blocks to wait until the output can be
+   swept.
+ - maturity: either "mature" (can be swept now) or "immature".
+
+The --closed-only, --open-only and --has-balance flags only show the channels
+that still need attention. They apply to the logged channel details, the JSON
+output and the result file, the totals are always calculated over all
+channels.`,
 		Example: `lncli listchannels | chantools summary --listchannels -
 
+lncli listchannels | chantools summary --listchannels - --json
+
+lncli listchannels | chantools summary --listchannels - --has-balance
+
 chantools summary --fromchanneldb ~/.lnd/data/graph/mainnet/channel.db`,
 		RunE: cc.Execute,
 	}
@@ -35,6 +76,23 @@ chantools summary --fromchanneldb ~/.lnd/data/graph/mainnet/channel.db`,
 		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
 			"be esplora compatible)",
 	)
+	cc.cmd.Flags().BoolVar(
+		&cc.JSON, "json", false, "print the status of each channel as "+
+			"JSON to stdout instead of logging the summary",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.ClosedOnly, "closed-only", false, "only show channels "+
+			"that are closed",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.OpenOnly, "open-only", false, "only show channels that "+
+			"are still open",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.HasBalance, "has-balance", false, "only show closed "+
+			"channels with unspent outputs that potentially "+
+			"belong to us and still need to be swept",
+	)
 
 	cc.inputs = newInputFlags(cc.cmd)
 
@@ -42,21 +100,120 @@ chantools summary --fromchanneldb ~/.lnd/data/graph/mainnet/channel.db`,
 }
 
 func (c *summaryCommand) Execute(_ *cobra.Command, _ []string) error {
+	filter := &summaryFilter{
+		closedOnly: c.ClosedOnly,
+		openOnly:   c.OpenOnly,
+		hasBalance: c.HasBalance,
+	}
+	if err := filter.validate(); err != nil {
+		return err
+	}
+
 	// Parse channel entries from any of the possible input files.
 	entries, err := c.inputs.parseInputType()
 	if err != nil {
 		return err
 	}
-	return summarizeChannels(c.APIURL, entries)
+
+	// The JSON output is meant to be parsed, so we can't have any log
+	// lines mixed into it.
+	if c.JSON {
+		logWriter.SetLogLevels("off")
+	}
+
+	return summarizeChannels(c.APIURL, entries, c.JSON, filter)
 }
 
-func summarizeChannels(apiURL string,
-	channels []*dataformat.SummaryEntry) error {
+// summaryFilter selects the channels that are shown in the summary. The zero
+// value shows all channels.
+type summaryFilter struct {
+	closedOnly bool
+	openOnly   bool
+	hasBalance bool
+}
+
+// validate makes sure the filter doesn't exclude all channels by definition.
+func (f *summaryFilter) validate() error {
+	if f.openOnly && (f.closedOnly || f.hasBalance) {
+		return fmt.Errorf("--open-only can't be combined with " +
+			"--closed-only or --has-balance")
+	}
+
+	return nil
+}
+
+// matches returns true if a channel with the given status should be shown.
+func (f *summaryFilter) matches(status *dataformat.ChannelStatus) bool {
+	closed := status.CloseType == dataformat.CloseTypeCooperative ||
+		status.CloseType == dataformat.CloseTypeForce
+
+	switch {
+	case f.closedOnly && !closed:
+		return false
+
+	case f.openOnly && status.CloseType != dataformat.CloseTypeNone:
+		return false
+
+	case f.hasBalance &&
+		status.SweepStatus != dataformat.SweepStatusUnswept:
+
+		return false
+	}
+
+	return true
+}
+
+// filterSummary removes all channels that don't match the filter from the
+// summary file. The totals of the summary are left unchanged.
+func filterSummary(summaryFile *dataformat.SummaryEntryFile,
+	filter *summaryFilter) {
+
+	if filter == nil {
+		return
+	}
+
+	channels := make(
+		[]*dataformat.SummaryEntry, 0, len(summaryFile.Channels),
+	)
+	for _, channel := range summaryFile.Channels {
+		status := channelStatus(channel, summaryFile.BlockHeight)
+		if filter.matches(status) {
+			channels = append(channels, channel)
+		}
+	}
+
+	if len(channels) != len(summaryFile.Channels) {
+		log.Infof("Showing %d of %d channels that match the filter",
+			len(channels), len(summaryFile.Channels))
+	}
+	summaryFile.Channels = channels
+}
+
+func summarizeChannels(apiURL string, channels []*dataformat.SummaryEntry,
+	printJSON bool, filter *summaryFilter) error {
 
 	summaryFile, err := btc.SummarizeChannels(apiURL, channels, log)
 	if err != nil {
 		return fmt.Errorf("error running summary: %w", err)
 	}
+	filterSummary(summaryFile, filter)
+
+	if printJSON {
+		statuses := make(
+			[]*dataformat.ChannelStatus, len(summaryFile.Channels),
+		)
+		for idx, channel := range summaryFile.Channels {
+			statuses[idx] = channelStatus(
+				channel, summaryFile.BlockHeight,
+			)
+		}
+
+		statusBytes, err := json.MarshalIndent(statuses, "", " ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(statusBytes))
+	}
 
 	log.Info("Finished scanning.")
 	log.Infof("Open channels: %d", summaryFile.OpenChannels)
@@ -79,6 +236,7 @@ func summarizeChannels(apiURL string,
 		summaryFile.FundsForceClose)
 	log.Infof(" --> closed channel sats that are in coop close outputs: %d",
 		summaryFile.FundsCoopClose)
+	logMaturity(summaryFile)
 
 	summaryBytes, err := json.MarshalIndent(summaryFile, "", " ")
 	if err != nil {
@@ -89,3 +247,93 @@ func summarizeChannels(apiURL string,
 	log.Infof("Writing result to %s", fileName)
 	return ioutil.WriteFile(fileName, summaryBytes, 0644)
 }
+
+// logMaturity logs when the to_local outputs of all force closed channels with
+// a known CSV delay can be swept.
+func logMaturity(summaryFile *dataformat.SummaryEntryFile) {
+	if summaryFile.BlockHeight == 0 {
+		return
+	}
+
+	log.Infof("CSV maturity of force closed channels at height %d:",
+		summaryFile.BlockHeight)
+	for _, channel := range summaryFile.Channels {
+		closingTx := channel.ClosingTX
+		if closingTx == nil || closingTx.MatureHeight == 0 {
+			continue
+		}
+
+		if closingTx.Mature {
+			log.Infof(" --> [MATURE] %s: csv_delay=%d, "+
+				"conf_height=%d, mature_height=%d, can be "+
+				"swept now", channel.ChannelPoint,
+				closingTx.CSVDelay, closingTx.ConfHeight,
+				closingTx.MatureHeight)
+			continue
+		}
+
+		log.Infof(" --> [immature] %s: csv_delay=%d, conf_height=%d, "+
+			"mature_height=%d, %d blocks to go",
+			channel.ChannelPoint, closingTx.CSVDelay,
+			closingTx.ConfHeight, closingTx.MatureHeight,
+			closingTx.BlocksUntilMature)
+	}
+}
+
+// channelStatus returns the condensed on-chain status of the given summary
+// entry. The tip height is only used for reporting the CSV maturity of force
+// closed channels.
+func channelStatus(entry *dataformat.SummaryEntry,
+	tipHeight uint32) *dataformat.ChannelStatus {
+
+	status := &dataformat.ChannelStatus{
+		ChannelPoint:  entry.ChannelPoint,
+		Capacity:      entry.Capacity,
+		LocalBalance:  entry.LocalBalance,
+		RemoteBalance: entry.RemoteBalance,
+	}
+
+	switch {
+	case !entry.ChanExists:
+		status.CloseType = dataformat.CloseTypeUnknown
+		status.SweepStatus = dataformat.SweepStatusUnknown
+		return status
+
+	case entry.ClosingTX == nil:
+		status.CloseType = dataformat.CloseTypeNone
+		status.SweepStatus = dataformat.SweepStatusNotClosed
+		return status
+
+	case entry.ClosingTX.ForceClose:
+		status.CloseType = dataformat.CloseTypeForce
+
+	default:
+		status.CloseType = dataformat.CloseTypeCooperative
+	}
+
+	status.ClosingTXID = entry.ClosingTX.TXID
+	switch {
+	case entry.ClosingTX.AllOutsSpent:
+		status.SweepStatus = dataformat.SweepStatusSpent
+
+	case entry.HasPotential:
+		status.SweepStatus = dataformat.SweepStatusUnswept
+
+	default:
+		status.SweepStatus = dataformat.SweepStatusNotOurs
+	}
+
+	if entry.ClosingTX.MatureHeight != 0 {
+		status.CSVDelay = entry.ClosingTX.CSVDelay
+		status.ConfHeight = entry.ClosingTX.ConfHeight
+		status.TipHeight = tipHeight
+		status.MatureHeight = entry.ClosingTX.MatureHeight
+		status.BlocksUntilMature = entry.ClosingTX.BlocksUntilMature
+		status.Maturity = dataformat.MaturityImmature
+		if entry.ClosingTX.Mature {
+			status.Maturity = dataformat.MaturityMature
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/guggero/chantools/dataformat"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelStatus(t *testing.T) {
+	testCases := []struct {
+		name        string
+		entry       *dataformat.SummaryEntry
+		closeType   string
+		closingTXID string
+		sweepStatus string
+	}{{
+		name:        "funding tx not found",
+		entry:       &dataformat.SummaryEntry{},
+		closeType:   dataformat.CloseTypeUnknown,
+		sweepStatus: dataformat.SweepStatusUnknown,
+	}, {
+		name: "open",
+		entry: &dataformat.SummaryEntry{
+			ChanExists: true,
+		},
+		closeType:   dataformat.CloseTypeNone,
+		sweepStatus: dataformat.SweepStatusNotClosed,
+	}, {
+		name: "force closed and swept",
+		entry: &dataformat.SummaryEntry{
+			ChanExists: true,
+			ClosingTX: &dataformat.ClosingTX{
+				TXID:         "aa",
+				ForceClose:   true,
+				AllOutsSpent: true,
+			},
+		},
+		closeType:   dataformat.CloseTypeForce,
+		closingTXID: "aa",
+		sweepStatus: dataformat.SweepStatusSpent,
+	}, {
+		name: "force closed with our funds unswept",
+		entry: &dataformat.SummaryEntry{
+			ChanExists:   true,
+			HasPotential: true,
+			ClosingTX: &dataformat.ClosingTX{
+				TXID:       "bb",
+				ForceClose: true,
+			},
+		},
+		closeType:   dataformat.CloseTypeForce,
+		closingTXID: "bb",
+		sweepStatus: dataformat.SweepStatusUnswept,
+	}, {
+		name: "coop closed with only remote funds unspent",
+		entry: &dataformat.SummaryEntry{
+			ChanExists: true,
+			ClosingTX: &dataformat.ClosingTX{
+				TXID: "cc",
+			},
+		},
+		closeType:   dataformat.CloseTypeCooperative,
+		closingTXID: "cc",
+		sweepStatus: dataformat.SweepStatusNotOurs,
+	}}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.entry.ChannelPoint = "abcd:1"
+			tc.entry.Capacity = 100_000
+			tc.entry.LocalBalance = 60_000
+			tc.entry.RemoteBalance = 40_000
+
+			status := channelStatus(tc.entry, 0)
+			require.Equal(t, &dataformat.ChannelStatus{
+				ChannelPoint:  "abcd:1",
+				Capacity:      100_000,
+				LocalBalance:  60_000,
+				RemoteBalance: 40_000,
+				CloseType:     tc.closeType,
+				ClosingTXID:   tc.closingTXID,
+				SweepStatus:   tc.sweepStatus,
+			}, status)
+		})
+	}
+}
+
+func TestChannelStatusMaturity(t *testing.T) {
+	const tipHeight = 1000
+
+	testCases := []struct {
+		name              string
+		confHeight        uint32
+		csvDelay          uint16
+		matureHeight      uint32
+		blocksUntilMature uint32
+		maturity          string
+	}{{
+		name:              "immature",
+		confHeight:        950,
+		csvDelay:          144,
+		matureHeight:      1094,
+		blocksUntilMature: 93,
+		maturity:          dataformat.MaturityImmature,
+	}, {
+		name:         "spendable in next block",
+		confHeight:   857,
+		csvDelay:     144,
+		matureHeight: 1001,
+		maturity:     dataformat.MaturityMature,
+	}, {
+		name:         "long mature",
+		confHeight:   500,
+		csvDelay:     144,
+		matureHeight: 644,
+		maturity:     dataformat.MaturityMature,
+	}}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			entry := &dataformat.SummaryEntry{
+				ChannelPoint: "abcd:1",
+				ChanExists:   true,
+				HasPotential: true,
+				ClosingTX: &dataformat.ClosingTX{
+					TXID:       "aa",
+					ForceClose: true,
+					ConfHeight: tc.confHeight,
+				},
+				ForceClose: &dataformat.ForceClose{
+					CSVDelay: tc.csvDelay,
+				},
+			}
+			entry.UpdateMaturity(tipHeight)
+
+			status := channelStatus(entry, tipHeight)
+			require.Equal(t, &dataformat.ChannelStatus{
+				ChannelPoint:      "abcd:1",
+				CloseType:         dataformat.CloseTypeForce,
+				ClosingTXID:       "aa",
+				SweepStatus:       dataformat.SweepStatusUnswept,
+				CSVDelay:          tc.csvDelay,
+				ConfHeight:        tc.confHeight,
+				TipHeight:         tipHeight,
+				MatureHeight:      tc.matureHeight,
+				BlocksUntilMature: tc.blocksUntilMature,
+				Maturity:          tc.maturity,
+			}, status)
+		})
+	}
+
+	// Without a known CSV delay, nothing is reported.
+	entry := &dataformat.SummaryEntry{
+		ChanExists: true,
+		ClosingTX: &dataformat.ClosingTX{
+			ForceClose: true,
+			ConfHeight: 900,
+		},
+	}
+	entry.UpdateMaturity(tipHeight)
+	status := channelStatus(entry, tipHeight)
+	require.Empty(t, status.Maturity)
+	require.Zero(t, status.TipHeight)
+}
+
+func TestFilterSummary(t *testing.T) {
+	newSummaryFile := func() *dataformat.SummaryEntryFile {
+		return &dataformat.SummaryEntryFile{
+			Channels: []*dataformat.SummaryEntry{{
+				ChannelPoint: "unknown:0",
+			}, {
+				ChannelPoint: "open:0",
+				ChanExists:   true,
+			}, {
+				ChannelPoint: "swept:0",
+				ChanExists:   true,
+				ClosingTX: &dataformat.ClosingTX{
+					ForceClose:   true,
+					AllOutsSpent: true,
+				},
+			}, {
+				ChannelPoint: "unswept:0",
+				ChanExists:   true,
+				HasPotential: true,
+				ClosingTX: &dataformat.ClosingTX{
+					ForceClose: true,
+				},
+			}, {
+				ChannelPoint: "notours:0",
+				ChanExists:   true,
+				ClosingTX:    &dataformat.ClosingTX{},
+			}},
+			OpenChannels: 1,
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		filter   *summaryFilter
+		channels []string
+	}{{
+		name:   "no filter",
+		filter: &summaryFilter{},
+		channels: []string{
+			"unknown:0", "open:0", "swept:0", "unswept:0",
+			"notours:0",
+		},
+	}, {
+		name:     "closed only",
+		filter:   &summaryFilter{closedOnly: true},
+		channels: []string{"swept:0", "unswept:0", "notours:0"},
+	}, {
+		name:     "open only",
+		filter:   &summaryFilter{openOnly: true},
+		channels: []string{"open:0"},
+	}, {
+		name:     "has balance",
+		filter:   &summaryFilter{hasBalance: true},
+		channels: []string{"unswept:0"},
+	}, {
+		name: "closed with balance",
+		filter: &summaryFilter{
+			closedOnly: true,
+			hasBalance: true,
+		},
+		channels: []string{"unswept:0"},
+	}}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			summaryFile := newSummaryFile()
+			filterSummary(summaryFile, tc.filter)
+
+			channels := make([]string, len(summaryFile.Channels))
+			for idx, channel := range summaryFile.Channels {
+				channels[idx] = channel.ChannelPoint
+			}
+			require.Equal(t, tc.channels, channels)
+
+			// The totals are not changed by the filter.
+			require.EqualValues(t, 1, summaryFile.OpenChannels)
+		})
+	}
+
+	err := (&summaryFilter{openOnly: true, hasBalance: true}).validate()
+	require.ErrorContains(t, err, "can't be combined")
+	err = (&summaryFilter{closedOnly: true, hasBalance: true}).validate()
+	require.NoError(t, err)
+}
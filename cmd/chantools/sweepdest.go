@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/guggero/chantools/btc"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// defaultDestGapLimit is the default number of addresses of a
+	// destination descriptor that are checked for an unused one, starting
+	// at the start index.
+	defaultDestGapLimit = 1000
+)
+
+// addDestDescriptorFlags adds the --dest-descriptor, --dest-index and
+// --dest-gap-limit flags to the given sweep command.
+func addDestDescriptorFlags(cmd *cobra.Command, descriptor *string,
+	startIndex, gapLimit *uint32) {
+
+	cmd.Flags().StringVar(
+		descriptor, "dest-descriptor", "", "ranged wpkh() output "+
+			"descriptor (including its checksum) of the wallet to "+
+			"sweep the funds to instead of --sweepaddr; the first "+
+			"unused address starting at --dest-index is used; "+
+			"only wpkh() descriptors are supported and the "+
+			"bitcoind chain backend can't be used, as it can't "+
+			"tell whether an address was used",
+	)
+	cmd.Flags().Uint32Var(
+		startIndex, "dest-index", 0, "index of the first address of "+
+			"--dest-descriptor that is checked for being unused",
+	)
+	cmd.Flags().Uint32Var(
+		gapLimit, "dest-gap-limit", defaultDestGapLimit, "number of "+
+			"addresses of --dest-descriptor, starting at "+
+			"--dest-index, that are checked for an unused one",
+	)
+}
+
+// sweepDestination returns the address to sweep the funds to. If no
+// destination descriptor is given, the sweep address itself is returned.
+// Otherwise, the first address of the descriptor within gapLimit addresses
+// from the given index that the chain API reports as unused is returned.
+func sweepDestination(sweepAddr, descriptor string, startIndex,
+	gapLimit uint32, apiURL string) (string, error) {
+
+	if descriptor == "" {
+		return sweepAddr, nil
+	}
+	if sweepAddr != "" {
+		return "", fmt.Errorf("only one of --sweepaddr and " +
+			"--dest-descriptor can be set")
+	}
+
+	// bitcoind can only look at the UTXO set, so an address that received
+	// funds that were spent again already would look unused.
+	if ChainBackend == btc.ChainBackendBitcoind {
+		return "", fmt.Errorf("--dest-descriptor can't be used with "+
+			"the %s chain backend, it can't tell whether an "+
+			"address was used before", btc.ChainBackendBitcoind)
+	}
+	if gapLimit == 0 {
+		return "", fmt.Errorf("--dest-gap-limit must be at least 1")
+	}
+
+	api, err := newChainBackend(apiURL)
+	if err != nil {
+		return "", err
+	}
+
+	return nextUnusedAddress(api, descriptor, startIndex, gapLimit)
+}
+
+// nextUnusedAddress returns the first address of the given ranged descriptor
+// within gapLimit addresses from the given index that was never used according
+// to the chain backend.
+func nextUnusedAddress(api btc.ChainBackend, descriptor string, startIndex,
+	gapLimit uint32) (string, error) {
+
+	lastIndex := startIndex + gapLimit - 1
+	for index := startIndex; index <= lastIndex; index++ {
+		addr, err := btc.DescriptorAddress(
+			descriptor, index, chainParams,
+		)
+		if err != nil {
+			return "", fmt.Errorf("error deriving destination "+
+				"address: %w", err)
+		}
+
+		used, err := api.AddressUsed(addr.String())
+		if err != nil {
+			return "", fmt.Errorf("error checking destination "+
+				"address %s: %w", addr, err)
+		}
+		if !used {
+			log.Infof("Using unused address %s at index %d of "+
+				"destination descriptor", addr, index)
+
+			return addr.String(), nil
+		}
+
+		log.Debugf("Destination address %s at index %d was already "+
+			"used", addr, index)
+	}
+
+	return "", fmt.Errorf("no unused address found in destination "+
+		"descriptor between index %d and %d, try increasing "+
+		"--dest-gap-limit", startIndex, lastIndex)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSweepDestination(t *testing.T) {
+	h := newHarness(t)
+
+	rootKey, err := hdkeychain.NewKeyFromString(rootKeyBip39)
+	require.NoError(t, err)
+	path, err := lnd.ParsePath(lnd.WalletDefaultDerivationPath)
+	require.NoError(t, err)
+	desc, err := btc.AccountDescriptor(
+		rootKey, lnd.WalletDefaultDerivationPath, path, 0,
+	)
+	require.NoError(t, err)
+
+	chain := newFakeChainBackend()
+	for _, index := range []uint32{5, 6} {
+		addr, err := btc.DescriptorAddress(desc, index, chainParams)
+		require.NoError(t, err)
+		chain.addAPITx(&btc.TX{
+			TXID: strings.Repeat(fmt.Sprintf("%02x", index), 32),
+			Vout: []*btc.Vout{{
+				ScriptPubkeyAddr: addr.String(),
+				Value:            10_000,
+			}},
+			Status: &btc.Status{Confirmed: true},
+		})
+	}
+	expectedAddr, err := btc.DescriptorAddress(desc, 7, chainParams)
+	require.NoError(t, err)
+
+	apiURL := chain.serve(t)
+
+	sweepAddr, err := sweepDestination("", desc, 5, 3, apiURL)
+	require.NoError(t, err)
+	require.Equal(t, expectedAddr.String(), sweepAddr)
+	h.assertLogContains("at index 7 of destination descriptor")
+
+	// Without a descriptor, the sweep address is used as is.
+	sweepAddr, err = sweepDestination("bcrt1qfoo", "", 5, 3, apiURL)
+	require.NoError(t, err)
+	require.Equal(t, "bcrt1qfoo", sweepAddr)
+
+	_, err = sweepDestination("bcrt1qfoo", desc, 5, 3, apiURL)
+	require.ErrorContains(t, err, "only one of")
+
+	_, err = sweepDestination(
+		"", desc[:len(desc)-1]+"x", 5, 3, apiURL,
+	)
+	require.ErrorContains(t, err, "invalid descriptor checksum")
+
+	// The unused address must be within the gap limit.
+	_, err = sweepDestination("", desc, 5, 2, apiURL)
+	require.ErrorContains(t, err, "no unused address found in "+
+		"destination descriptor between index 5 and 6")
+
+	// Only wpkh() descriptors are supported.
+	trPath, err := lnd.ParsePath("m/86'/0'/0'")
+	require.NoError(t, err)
+	trDesc, err := btc.AccountDescriptor(rootKey, "m/86'/0'/0'", trPath, 0)
+	require.NoError(t, err)
+	_, err = sweepDestination("", trDesc, 5, 3, apiURL)
+	require.ErrorContains(t, err, "only wpkh() descriptors are supported")
+
+	// bitcoind only knows about unspent outputs, so it can't tell whether
+	// an address was used.
+	ChainBackend = btc.ChainBackendBitcoind
+	defer func() {
+		ChainBackend = ""
+	}()
+	_, err = sweepDestination("", desc, 5, 3, apiURL)
+	require.ErrorContains(t, err, "can't be used with the bitcoind "+
+		"chain backend")
+}
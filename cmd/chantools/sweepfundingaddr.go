@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/chanbackup"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// errMissingRemoteKey is returned if a channel backup doesn't contain
+	// the remote funding key.
+	errMissingRemoteKey = errors.New("invalid channel backup, remote " +
+		"multisig pubkey is nil")
+)
+
+type sweepFundingAddrCommand struct {
+	ChannelDB    string
+	MultiFile    string
+	FundingPoint string
+	Outpoint     string
+
+	SweepAddr      string
+	DestDescriptor string
+	DestIndex      uint32
+	DestGapLimit   uint32
+	FeeRate        uint16
+	APIURL         string
+
+	rootKey *rootKey
+	cmd     *cobra.Command
+}
+
+func newSweepFundingAddrCommand() *cobra.Command {
+	cc := &sweepFundingAddrCommand{}
+	cc.cmd = &cobra.Command{
+		Use: "sweepfundingaddr",
+		Short: "Sweep coins that were sent to the 2-of-2 funding " +
+			"address of a channel after it was closed",
+		Long: `Sometimes coins are sent to the 2-of-2 multisig funding
+address of a channel by accident, after the channel was already closed. Because
+lnd doesn't know about those coins, they are locked in the multisig output.
+
+This command looks up the funding keys of the channel with the given
+--fundingpoint in the channel DB (open or closed channels) or the channel
+backup file, derives the local funding key from the seed and makes sure the
+2-of-2 script of both keys matches the script of the UTXO given with
+--outpoint. It then creates a PSBT that sweeps the UTXO and adds the local
+signature.
+
+**You need the cooperation of the channel partner (remote node) for this to
+work**! They need to sign the PSBT with the 'chantools signrescuefunding'
+command.`,
+		Example: `chantools sweepfundingaddr \
+	--channeldb ~/.lnd/data/graph/mainnet/channel.db \
+	--fundingpoint xxxxxxx:xx \
+	--outpoint yyyyyyy:yy \
+	--sweepaddr bc1qxxxxxxxxx \
+	--feerate 10
+
+chantools sweepfundingaddr \
+	--multi_file ~/.lnd/data/chain/bitcoin/mainnet/channel.backup \
+	--fundingpoint xxxxxxx:xx \
+	--outpoint yyyyyyy:yy \
+	--sweepaddr bc1qxxxxxxxxx \
+	--feerate 10`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.ChannelDB, "channeldb", "", "lnd channel.db file to read "+
+			"the funding keys of the channel from",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.MultiFile, "multi_file", "", "lnd channel.backup file "+
+			"(or a directory of channel backup files) to read "+
+			"the funding keys of the channel from",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.FundingPoint, "fundingpoint", "", "funding transaction "+
+			"outpoint of the channel (<txid>:<txindex>)",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.Outpoint, "outpoint", "", "outpoint of the UTXO that was "+
+			"sent to the funding address of the channel "+
+			"(<txid>:<txindex>)",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.SweepAddr, "sweepaddr", "", "address to sweep the funds to",
+	)
+	addDestDescriptorFlags(
+		cc.cmd, &cc.DestDescriptor, &cc.DestIndex, &cc.DestGapLimit,
+	)
+	cc.cmd.Flags().Uint16Var(
+		&cc.FeeRate, "feerate", defaultFeeSatPerVByte, "fee rate to "+
+			"use for the sweep transaction in sat/vByte",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
+			"be esplora compatible)",
+	)
+
+	cc.rootKey = newRootKey(cc.cmd, "deriving keys")
+
+	return cc.cmd
+}
+
+func (c *sweepFundingAddrCommand) Execute(_ *cobra.Command, _ []string) error {
+	extendedKey, err := c.rootKey.read()
+	if err != nil {
+		return fmt.Errorf("error reading root key: %w", err)
+	}
+
+	// Make sure all required flags are set.
+	switch {
+	case (c.ChannelDB == "") == (c.MultiFile == ""):
+		return fmt.Errorf("need to specify either --channeldb or " +
+			"--multi_file")
+
+	case c.FundingPoint == "":
+		return fmt.Errorf("funding point is required")
+
+	case c.Outpoint == "":
+		return fmt.Errorf("outpoint is required")
+	}
+
+	fundingPoint, err := lnd.ParseOutpoint(c.FundingPoint)
+	if err != nil {
+		return fmt.Errorf("error parsing funding point: %w", err)
+	}
+	outpoint, err := lnd.ParseOutpoint(c.Outpoint)
+	if err != nil {
+		return fmt.Errorf("error parsing outpoint: %w", err)
+	}
+
+	// The sweep address can also be derived from a descriptor.
+	c.SweepAddr, err = sweepDestination(
+		c.SweepAddr, c.DestDescriptor, c.DestIndex, c.DestGapLimit,
+		c.APIURL,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Make sure the sweep addr is a P2WKH address so we can do accurate
+	// fee estimation.
+	sweepScript, err := lnd.GetP2WPKHScript(c.SweepAddr, chainParams)
+	if err != nil {
+		return fmt.Errorf("error parsing sweep addr: %w", err)
+	}
+
+	var localKeyDesc, remoteKeyDesc keychain.KeyDescriptor
+	if c.ChannelDB != "" {
+		db, err := lnd.OpenDB(c.ChannelDB, true)
+		if err != nil {
+			return fmt.Errorf("error opening channel DB: %w", err)
+		}
+		defer func() { _ = db.Close() }()
+
+		localKeyDesc, remoteKeyDesc, err = fundingKeysFromDB(
+			db, fundingPoint,
+		)
+		if err != nil {
+			return err
+		}
+	} else {
+		keyRing := &lnd.HDKeyRing{
+			ExtendedKey: extendedKey,
+			ChainParams: chainParams,
+		}
+		multi, err := extractMulti(c.MultiFile, keyRing)
+		if err != nil {
+			return err
+		}
+
+		localKeyDesc, remoteKeyDesc, err = fundingKeysFromBackup(
+			multi, fundingPoint,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	signer := &lnd.Signer{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+	localKey, err := deriveFundingKey(signer, localKeyDesc)
+	if err != nil {
+		return err
+	}
+
+	return rescueFunding(
+		localKey, remoteKeyDesc.PubKey, signer, outpoint, sweepScript,
+		btcutil.Amount(c.FeeRate), c.APIURL,
+	)
+}
+
+// fundingKeysFromDB returns the local and remote funding key of the channel
+// with the given funding point, which can be an open or a closed channel.
+func fundingKeysFromDB(db *channeldb.DB, fundingPoint *wire.OutPoint) (
+	keychain.KeyDescriptor, keychain.KeyDescriptor, error) {
+
+	chanStateDB := db.ChannelStateDB()
+	channel, err := chanStateDB.FetchChannel(nil, *fundingPoint)
+	if err != nil {
+		channel, err = chanStateDB.FetchHistoricalChannel(fundingPoint)
+	}
+	if err != nil {
+		return keychain.KeyDescriptor{}, keychain.KeyDescriptor{},
+			fmt.Errorf("error loading channel %v from DB: %w",
+				fundingPoint, err)
+	}
+
+	if channel.RemoteChanCfg.MultiSigKey.PubKey == nil {
+		return keychain.KeyDescriptor{}, keychain.KeyDescriptor{},
+			fmt.Errorf("invalid channel data in DB, remote " +
+				"multisig pubkey is nil")
+	}
+
+	return channel.LocalChanCfg.MultiSigKey,
+		channel.RemoteChanCfg.MultiSigKey, nil
+}
+
+// fundingKeysFromBackup returns the local and remote funding key of the channel
+// with the given funding point from the channel backup.
+func fundingKeysFromBackup(multi *chanbackup.Multi,
+	fundingPoint *wire.OutPoint) (keychain.KeyDescriptor,
+	keychain.KeyDescriptor, error) {
+
+	for _, single := range multi.StaticBackups {
+		if single.FundingOutpoint != *fundingPoint {
+			continue
+		}
+
+		if single.RemoteChanCfg.MultiSigKey.PubKey == nil {
+			return keychain.KeyDescriptor{},
+				keychain.KeyDescriptor{}, errMissingRemoteKey
+		}
+
+		return single.LocalChanCfg.MultiSigKey,
+			single.RemoteChanCfg.MultiSigKey, nil
+	}
+
+	return keychain.KeyDescriptor{}, keychain.KeyDescriptor{},
+		fmt.Errorf("channel %v not found in backup", fundingPoint)
+}
+
+// deriveFundingKey derives the local funding key from the seed. If the key
+// descriptor contains a public key, it must match the derived key, otherwise
+// the seed doesn't belong to the node of the channel.
+func deriveFundingKey(signer *lnd.Signer,
+	keyDesc keychain.KeyDescriptor) (*keychain.KeyDescriptor, error) {
+
+	privKey, err := signer.FetchPrivKey(&keyDesc)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving local funding key: %w",
+			err)
+	}
+
+	pubKey := privKey.PubKey()
+	if keyDesc.PubKey != nil && !keyDesc.PubKey.IsEqual(pubKey) {
+		return nil, fmt.Errorf("derived local funding key %x doesn't "+
+			"match the key %x of the channel, wrong seed?",
+			pubKey.SerializeCompressed(),
+			keyDesc.PubKey.SerializeCompressed())
+	}
+
+	return &keychain.KeyDescriptor{
+		KeyLocator: keyDesc.KeyLocator,
+		PubKey:     pubKey,
+	}, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/chanbackup"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSweepFundingAddr(t *testing.T) {
+	h := newHarness(t)
+
+	localKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	remoteKey, err := hdkeychain.NewKeyFromString(rootKeyBip39)
+	require.NoError(t, err)
+
+	localSigner := &lnd.Signer{
+		ExtendedKey: localKey,
+		ChainParams: chainParams,
+	}
+	remoteSigner := &lnd.Signer{
+		ExtendedKey: remoteKey,
+		ChainParams: chainParams,
+	}
+	remotePrivKey, err := remoteSigner.FetchPrivKey(&keychain.KeyDescriptor{
+		KeyLocator: keychain.KeyLocator{
+			Family: keychain.KeyFamilyMultiSig,
+			Index:  7,
+		},
+	})
+	require.NoError(t, err)
+
+	// The channel backup only contains the key locator of the local
+	// funding key.
+	fundingPoint := wire.OutPoint{Index: 1}
+	fundingPoint.Hash[0] = 0x42
+	localLocator := keychain.KeyLocator{
+		Family: keychain.KeyFamilyMultiSig,
+		Index:  3,
+	}
+	multi := &chanbackup.Multi{
+		StaticBackups: []chanbackup.Single{{
+			FundingOutpoint: fundingPoint,
+			LocalChanCfg: channeldb.ChannelConfig{
+				MultiSigKey: keychain.KeyDescriptor{
+					KeyLocator: localLocator,
+				},
+			},
+			RemoteChanCfg: channeldb.ChannelConfig{
+				MultiSigKey: keychain.KeyDescriptor{
+					PubKey: remotePrivKey.PubKey(),
+				},
+			},
+		}},
+	}
+
+	_, _, err = fundingKeysFromBackup(multi, &wire.OutPoint{})
+	require.ErrorContains(t, err, "not found in backup")
+
+	localDesc, remoteDesc, err := fundingKeysFromBackup(
+		multi, &fundingPoint,
+	)
+	require.NoError(t, err)
+	localFundingKey, err := deriveFundingKey(localSigner, localDesc)
+	require.NoError(t, err)
+
+	// A key of a different seed is detected.
+	_, err = deriveFundingKey(remoteSigner, keychain.KeyDescriptor{
+		KeyLocator: localLocator,
+		PubKey:     localFundingKey.PubKey,
+	})
+	require.ErrorContains(t, err, "wrong seed?")
+
+	// The stray coins were sent to the funding address after the channel
+	// was closed.
+	witnessScript, err := input.GenMultiSigScript(
+		localFundingKey.PubKey.SerializeCompressed(),
+		remoteDesc.PubKey.SerializeCompressed(),
+	)
+	require.NoError(t, err)
+	pkScript, err := input.WitnessScriptHash(witnessScript)
+	require.NoError(t, err)
+
+	strayTx := wire.NewMsgTx(2)
+	strayTx.TxIn = []*wire.TxIn{{}}
+	strayTx.TxOut = []*wire.TxOut{{
+		Value:    rescueTestValue,
+		PkScript: pkScript,
+	}}
+	strayPoint := &wire.OutPoint{Hash: strayTx.TxHash()}
+
+	chain := newFakeChainBackend()
+	chain.addTx(t, strayTx, nil)
+	apiURL := chain.serve(t)
+
+	sweepAddr, err := lnd.P2WKHAddr(localFundingKey.PubKey, chainParams)
+	require.NoError(t, err)
+	sweepScript, err := txscript.PayToAddrScript(sweepAddr)
+	require.NoError(t, err)
+
+	// The UTXO must pay to the 2-of-2 script of the channel's keys.
+	err = rescueFunding(
+		localFundingKey, localFundingKey.PubKey, localSigner,
+		strayPoint, sweepScript, 10, apiURL,
+	)
+	require.ErrorContains(t, err, "does not match UTXO")
+
+	psbtFile := h.tempFile("sweepfundingaddr.psbt")
+	setOutputFlags(t, psbtFile, outputFormatPsbt, false)
+	err = rescueFunding(
+		localFundingKey, remoteDesc.PubKey, localSigner, strayPoint,
+		sweepScript, 10, apiURL,
+	)
+	require.NoError(t, err)
+
+	packet, err := psbt.NewFromRawBytes(
+		strings.NewReader(readOutputFile(t, psbtFile)), true,
+	)
+	require.NoError(t, err)
+	require.Len(t, packet.Inputs[0].PartialSigs, 1)
+
+	// The remote node can counter sign the PSBT with signrescuefunding.
+	setOutputFlags(t, "", "", false)
+	err = signRescueFunding(
+		remoteKey, packet, remoteSigner,
+		chain,
+		&expectedRescueFunding{
+			remotePubKey: localFundingKey.PubKey,
+			amount:       rescueTestValue,
+		},
+	)
+	require.NoError(t, err)
+
+	finalTx, err := psbt.Extract(packet)
+	require.NoError(t, err)
+	prevOuts := txscript.NewCannedPrevOutputFetcher(
+		pkScript, rescueTestValue,
+	)
+	vm, err := txscript.NewEngine(
+		pkScript, finalTx, 0, txscript.StandardVerifyFlags, nil,
+		txscript.NewTxSigHashes(finalTx, prevOuts), rescueTestValue,
+		prevOuts,
+	)
+	require.NoError(t, err)
+	require.NoError(t, vm.Execute())
+}
@@ -0,0 +1,883 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/spf13/cobra"
+)
+
+type sweepHtlcsCommand struct {
+	ChannelDB      string
+	ChannelPoint   string
+	Preimages      []string
+	SweepAddr      string
+	DestDescriptor string
+	DestIndex      uint32
+	DestGapLimit   uint32
+	FeeRate        uint16
+	APIURL         string
+	Publish        bool
+	DryRun         bool
+
+	rootKey *rootKey
+	cmd     *cobra.Command
+}
+
+func newSweepHtlcsCommand() *cobra.Command {
+	cc := &sweepHtlcsCommand{}
+	cc.cmd = &cobra.Command{
+		Use: "sweephtlcs",
+		Short: "Sweep the HTLC outputs that belong to us from a " +
+			"force-closed commitment transaction",
+		Long: `This command sweeps the HTLC outputs of a force-closed
+channel that belong to us, using the channel state in the channel.db to derive
+the HTLC scripts and keys.
+
+The commitment transaction that spent the funding output is looked up on chain
+and must be either our latest commitment or the latest (or pending) commitment
+of the remote peer.
+
+If the remote peer's commitment was published, the HTLC outputs are swept
+directly: incoming HTLCs with the preimage given with --preimage and outgoing
+HTLCs once their CLTV timeout has expired.
+
+If our own commitment was published, the HTLCs first need to be spent with the
+second level HTLC-success (incoming, requires the preimage) or HTLC-timeout
+(outgoing, after the CLTV timeout) transaction, which contains the signature of
+the peer that is stored in the channel.db. The outputs of those second level
+transactions are time locked with our CSV delay. Run the command again once the
+time lock expired to sweep them. Second level transactions of anchor channels
+require an additional input to pay the fees and are not supported yet.
+
+Every preimage must hash to the payment hash of an incoming HTLC of the
+commitment, otherwise the command fails, as a preimage that doesn't match is
+most likely a typo or was meant for another channel. All outputs that can be
+swept are combined into a single sweep transaction.`,
+		Example: `chantools sweephtlcs \
+	--channeldb ~/.lnd/data/graph/mainnet/channel.db \
+	--channelpoint xxxxxxx:xx \
+	--preimage xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx \
+	--sweepaddr bc1q..... \
+	--feerate 10 \
+	--publish`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.ChannelDB, "channeldb", "", "lnd channel.db file to read "+
+			"the HTLCs of the force-closed channel from",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.ChannelPoint, "channelpoint", "", "funding transaction "+
+			"outpoint of the force-closed channel "+
+			"(<txid>:<txindex>)",
+	)
+	cc.cmd.Flags().StringSliceVar(
+		&cc.Preimages, "preimage", nil, "hex encoded preimage of an "+
+			"incoming HTLC, required to sweep it; can be "+
+			"specified multiple times",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.SweepAddr, "sweepaddr", "", "address to sweep the funds to",
+	)
+	addDestDescriptorFlags(
+		cc.cmd, &cc.DestDescriptor, &cc.DestIndex, &cc.DestGapLimit,
+	)
+	cc.cmd.Flags().Uint16Var(
+		&cc.FeeRate, "feerate", defaultFeeSatPerVByte, "fee rate to "+
+			"use for the sweep transaction in sat/vByte",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
+			"be esplora compatible)",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.Publish, "publish", false, "publish the second level and "+
+			"sweep TXs to the chain API instead of just printing "+
+			"them",
+	)
+	addDryRunFlag(cc.cmd, &cc.DryRun)
+
+	cc.rootKey = newRootKey(cc.cmd, "deriving the HTLC keys")
+
+	return cc.cmd
+}
+
+func (c *sweepHtlcsCommand) Execute(_ *cobra.Command, _ []string) error {
+	extendedKey, err := c.rootKey.read()
+	if err != nil {
+		return fmt.Errorf("error reading root key: %w", err)
+	}
+
+	if c.ChannelDB == "" {
+		return fmt.Errorf("channel DB is required")
+	}
+	chanPoint, err := lnd.ParseOutpoint(c.ChannelPoint)
+	if err != nil {
+		return fmt.Errorf("error parsing channel point: %w", err)
+	}
+	preimages, err := parsePreimages(c.Preimages)
+	if err != nil {
+		return err
+	}
+
+	// The sweep address can also be derived from a descriptor.
+	c.SweepAddr, err = sweepDestination(
+		c.SweepAddr, c.DestDescriptor, c.DestIndex, c.DestGapLimit,
+		c.APIURL,
+	)
+	if err != nil {
+		return err
+	}
+	sweepScript, err := lnd.GetP2WPKHScript(c.SweepAddr, chainParams)
+	if err != nil {
+		return fmt.Errorf("error parsing sweep addr: %w", err)
+	}
+
+	db, err := lnd.OpenDB(c.ChannelDB, true)
+	if err != nil {
+		return fmt.Errorf("error opening channel DB: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	// A channel that lnd already noticed to be closed is moved to the
+	// historical channels.
+	chanStateDB := db.ChannelStateDB()
+	channel, err := chanStateDB.FetchChannel(nil, *chanPoint)
+	if errors.Is(err, channeldb.ErrChannelNotFound) {
+		channel, err = chanStateDB.FetchHistoricalChannel(chanPoint)
+	}
+	if err != nil {
+		return fmt.Errorf("error loading channel %v from DB: %w",
+			chanPoint, err)
+	}
+
+	api, err := newChainBackend(c.APIURL)
+	if err != nil {
+		return err
+	}
+	signer := &lnd.Signer{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+	publish := shouldPublish(c.Publish, c.DryRun, c.APIURL)
+
+	resolution, err := resolveHtlcs(api, signer, channel, preimages)
+	if err != nil {
+		return err
+	}
+
+	for _, tx := range resolution.secondLevelTxs {
+		err := publishTx(api, tx.tx, tx.fee, publish)
+		if err != nil {
+			return err
+		}
+	}
+	if len(resolution.secondLevelTxs) > 0 {
+		log.Infof("Created %d second level HTLC transaction(s), their "+
+			"outputs can be swept with this command after they "+
+			"confirmed and the CSV delay of %d blocks expired",
+			len(resolution.secondLevelTxs),
+			channel.LocalChanCfg.CsvDelay)
+	}
+
+	if len(resolution.sweeps) == 0 {
+		if len(resolution.secondLevelTxs) > 0 {
+			return nil
+		}
+
+		return fmt.Errorf("no HTLC outputs found that can be swept " +
+			"now")
+	}
+
+	sweepTx, fee, err := createHtlcSweepTx(
+		api, signer, resolution.sweeps, sweepScript, c.FeeRate,
+	)
+	if err != nil {
+		return err
+	}
+
+	return publishTx(api, sweepTx, fee, publish)
+}
+
+// parsePreimages decodes the given hex encoded preimages and returns them by
+// their payment hash.
+func parsePreimages(preimages []string) (map[[32]byte][]byte, error) {
+	result := make(map[[32]byte][]byte, len(preimages))
+	for _, preimageHex := range preimages {
+		preimage, err := hex.DecodeString(strings.TrimSpace(
+			preimageHex,
+		))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding preimage %s: %w",
+				preimageHex, err)
+		}
+		if len(preimage) != 32 {
+			return nil, fmt.Errorf("preimage %s must be 32 bytes "+
+				"long", preimageHex)
+		}
+
+		result[sha256.Sum256(preimage)] = preimage
+	}
+
+	return result, nil
+}
+
+// htlcCommitment is the commitment transaction of a channel that was published
+// on chain.
+type htlcCommitment struct {
+	commit  *channeldb.ChannelCommitment
+	local   bool
+	tx      *btc.TX
+	keyRing *lnwallet.CommitmentKeyRing
+}
+
+// findHtlcCommitment looks up the transaction that spent the funding output of
+// the channel and returns the commitment it belongs to.
+func findHtlcCommitment(api btc.ChainBackend,
+	channel *channeldb.OpenChannel) (*htlcCommitment, error) {
+
+	chanPoint := channel.FundingOutpoint
+	fundingTx, err := api.Transaction(chanPoint.Hash.String())
+	if err != nil {
+		return nil, fmt.Errorf("error fetching funding TX %v: %w",
+			chanPoint.Hash, err)
+	}
+	if int(chanPoint.Index) >= len(fundingTx.Vout) {
+		return nil, fmt.Errorf("funding TX %v has no output with "+
+			"index %d", chanPoint.Hash, chanPoint.Index)
+	}
+	outspend := fundingTx.Vout[chanPoint.Index].Outspend
+	if outspend == nil || !outspend.Spent {
+		return nil, fmt.Errorf("funding output %v is not spent, the "+
+			"channel wasn't force-closed yet", chanPoint)
+	}
+
+	// Our commitment point is derived from our revocation producer, the
+	// ones of the remote commitments were sent to us by the peer.
+	revocationPreimage, err := channel.RevocationProducer.AtIndex(
+		channel.LocalCommitment.CommitHeight,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving commitment point: %w",
+			err)
+	}
+	candidates := []struct {
+		commit      *channeldb.ChannelCommitment
+		local       bool
+		commitPoint *btcec.PublicKey
+	}{{
+		commit: &channel.LocalCommitment,
+		local:  true,
+		commitPoint: input.ComputeCommitmentPoint(
+			revocationPreimage[:],
+		),
+	}, {
+		commit:      &channel.RemoteCommitment,
+		commitPoint: channel.RemoteCurrentRevocation,
+	}}
+
+	pendingCommit, err := channel.RemoteCommitChainTip()
+	switch {
+	case err == nil:
+		candidates = append(candidates, struct {
+			commit      *channeldb.ChannelCommitment
+			local       bool
+			commitPoint *btcec.PublicKey
+		}{
+			commit:      &pendingCommit.Commitment,
+			commitPoint: channel.RemoteNextRevocation,
+		})
+
+	case !errors.Is(err, channeldb.ErrNoPendingCommit):
+		return nil, fmt.Errorf("error reading pending commitment: %w",
+			err)
+	}
+
+	for _, candidate := range candidates {
+		if candidate.commit.CommitTx == nil ||
+			candidate.commitPoint == nil ||
+			candidate.commit.CommitTx.TxHash().String() !=
+				outspend.Txid {
+
+			continue
+		}
+
+		tx, err := api.Transaction(outspend.Txid)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching commitment TX "+
+				"%s: %w", outspend.Txid, err)
+		}
+
+		return &htlcCommitment{
+			commit: candidate.commit,
+			local:  candidate.local,
+			tx:     tx,
+			keyRing: lnwallet.DeriveCommitmentKeys(
+				candidate.commitPoint, candidate.local,
+				channel.ChanType, &channel.LocalChanCfg,
+				&channel.RemoteChanCfg,
+			),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("funding output %v was spent by TX %s which "+
+		"is not the latest commitment of us or the peer; it might be "+
+		"a cooperative close or an old state", chanPoint, outspend.Txid)
+}
+
+// htlcSweep is an HTLC output (or the output of a second level HTLC
+// transaction) that can be swept.
+type htlcSweep struct {
+	name        string
+	outpoint    wire.OutPoint
+	sequence    uint32
+	lockTime    uint32
+	witnessSize int
+	signDesc    *input.SignDescriptor
+	witness     func(*wire.MsgTx, *input.SignDescriptor) (wire.TxWitness,
+		error)
+}
+
+// secondLevelTx is a signed second level HTLC transaction.
+type secondLevelTx struct {
+	tx  *wire.MsgTx
+	fee int64
+}
+
+// htlcResolution contains everything that can be done with the HTLCs of a
+// force-closed channel right now.
+type htlcResolution struct {
+	sweeps         []*htlcSweep
+	secondLevelTxs []*secondLevelTx
+}
+
+// resolveHtlcs finds all HTLC outputs of the published commitment of the
+// channel and decides which of them can be swept or need a second level
+// transaction now.
+func resolveHtlcs(api btc.ChainBackend, signer *lnd.Signer,
+	channel *channeldb.OpenChannel,
+	preimages map[[32]byte][]byte) (*htlcResolution, error) {
+
+	switch {
+	case channel.ChanType.IsTaproot():
+		return nil, fmt.Errorf("the HTLC outputs of simple taproot " +
+			"channels are not supported")
+
+	case channel.ChanType.HasLeaseExpiration():
+		return nil, fmt.Errorf("channels with a script enforced " +
+			"lease are not supported")
+	}
+
+	commitment, err := findHtlcCommitment(api, channel)
+	if err != nil {
+		return nil, err
+	}
+	bestHeight, err := api.BlockHeight()
+	if err != nil {
+		return nil, fmt.Errorf("error querying best block height: %w",
+			err)
+	}
+
+	owner := "remote"
+	if commitment.local {
+		owner = "local"
+	}
+	log.Infof("Channel %v was force-closed with the %s commitment %s at "+
+		"height %d, it has %d HTLC(s)", channel.FundingOutpoint, owner,
+		commitment.tx.TXID, commitment.commit.CommitHeight,
+		len(commitment.commit.Htlcs))
+
+	err = checkPreimages(commitment.commit.Htlcs, preimages)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := &htlcResolver{
+		signer:     signer,
+		api:        api,
+		channel:    channel,
+		commitment: commitment,
+		preimages:  preimages,
+		bestHeight: bestHeight,
+		result:     &htlcResolution{},
+	}
+	for idx := range commitment.commit.Htlcs {
+		err := resolver.resolve(&commitment.commit.Htlcs[idx])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resolver.result, nil
+}
+
+// checkPreimages makes sure every preimage belongs to one of the incoming
+// HTLCs. A preimage is only ever needed to claim an incoming HTLC, so one that
+// doesn't match any of them is an error of the user.
+func checkPreimages(htlcs []channeldb.HTLC,
+	preimages map[[32]byte][]byte) error {
+
+	incoming := make(map[[32]byte]bool, len(htlcs))
+	for _, htlc := range htlcs {
+		if htlc.Incoming {
+			incoming[htlc.RHash] = true
+		}
+	}
+	for hash := range preimages {
+		if !incoming[hash] {
+			return fmt.Errorf("preimage of payment hash %x "+
+				"doesn't belong to any incoming HTLC of the "+
+				"commitment", hash[:])
+		}
+	}
+
+	return nil
+}
+
+// htlcResolver resolves the HTLCs of a single commitment.
+type htlcResolver struct {
+	signer     *lnd.Signer
+	api        btc.ChainBackend
+	channel    *channeldb.OpenChannel
+	commitment *htlcCommitment
+	preimages  map[[32]byte][]byte
+	bestHeight uint32
+	result     *htlcResolution
+}
+
+// resolve decides what to do with the given HTLC.
+func (r *htlcResolver) resolve(htlc *channeldb.HTLC) error {
+	direction := "outgoing"
+	if htlc.Incoming {
+		direction = "incoming"
+	}
+	name := fmt.Sprintf("%s HTLC %x (%d sats)", direction, htlc.RHash[:],
+		htlc.Amt.ToSatoshis())
+
+	if htlc.OutputIndex < 0 {
+		log.Infof("Skipping %s, it is dust and has no output", name)
+		return nil
+	}
+	if int(htlc.OutputIndex) >= len(r.commitment.tx.Vout) {
+		return fmt.Errorf("commitment TX %s has no output %d for %s",
+			r.commitment.tx.TXID, htlc.OutputIndex, name)
+	}
+
+	script, err := r.htlcScript(htlc)
+	if err != nil {
+		return err
+	}
+	pkScript, err := input.WitnessScriptHash(script)
+	if err != nil {
+		return err
+	}
+	commitTxid, err := chainhash.NewHashFromStr(r.commitment.tx.TXID)
+	if err != nil {
+		return err
+	}
+	outpoint := wire.OutPoint{
+		Hash:  *commitTxid,
+		Index: uint32(htlc.OutputIndex),
+	}
+	signDesc := &input.SignDescriptor{
+		KeyDesc:       r.channel.LocalChanCfg.HtlcBasePoint,
+		SingleTweak:   r.commitment.keyRing.LocalHtlcKeyTweak,
+		WitnessScript: script,
+		Output: &wire.TxOut{
+			PkScript: pkScript,
+			Value:    int64(htlc.Amt.ToSatoshis()),
+		},
+		HashType: txscript.SigHashAll,
+	}
+
+	preimage, hasPreimage := r.preimages[htlc.RHash]
+	timedOut := r.bestHeight >= htlc.RefundTimeout
+	switch {
+	case htlc.Incoming && !hasPreimage:
+		log.Infof("Skipping %s, the preimage is required to sweep it",
+			name)
+		return nil
+
+	case !htlc.Incoming && !timedOut:
+		log.Infof("Skipping %s, it times out at height %d, in %d "+
+			"block(s)", name, htlc.RefundTimeout,
+			htlc.RefundTimeout-r.bestHeight)
+		return nil
+	}
+
+	if r.commitment.local {
+		return r.resolveLocal(name, htlc, outpoint, signDesc, preimage)
+	}
+
+	outspend := r.commitment.tx.Vout[htlc.OutputIndex].Outspend
+	if outspend != nil && outspend.Spent {
+		log.Infof("Skipping %s, it was already spent by TX %s", name,
+			outspend.Txid)
+		return nil
+	}
+
+	// On the remote commitment, we can sweep the HTLC outputs directly.
+	// Anchor channels require the commitment to be confirmed first.
+	sweep := &htlcSweep{
+		name:     name,
+		outpoint: outpoint,
+		signDesc: signDesc,
+	}
+	anchors := r.channel.ChanType.HasAnchors()
+	if anchors {
+		sweep.sequence = 1
+	}
+	if htlc.Incoming {
+		sweep.witnessSize = input.OfferedHtlcSuccessWitnessSize
+		if anchors {
+			sweep.witnessSize = input.
+				OfferedHtlcSuccessWitnessSizeConfirmed
+		}
+		sweep.witness = func(tx *wire.MsgTx,
+			desc *input.SignDescriptor) (wire.TxWitness, error) {
+
+			return input.SenderHtlcSpendRedeem(
+				r.signer, desc, tx, preimage,
+			)
+		}
+	} else {
+		sweep.lockTime = htlc.RefundTimeout
+		sweep.witnessSize = input.AcceptedHtlcTimeoutWitnessSize
+		if anchors {
+			sweep.witnessSize = input.
+				AcceptedHtlcTimeoutWitnessSizeConfirmed
+		}
+		sweep.witness = func(tx *wire.MsgTx,
+			desc *input.SignDescriptor) (wire.TxWitness, error) {
+
+			return input.ReceiverHtlcSpendTimeout(
+				r.signer, desc, tx, -1,
+			)
+		}
+	}
+
+	log.Infof("Sweeping %s from output %v", name, outpoint)
+	r.result.sweeps = append(r.result.sweeps, sweep)
+
+	return nil
+}
+
+// htlcScript returns the witness script of the HTLC output on the published
+// commitment. An outgoing HTLC is offered by us, so on our commitment we are
+// the sender of the offered HTLC and on the remote commitment the sender of
+// the accepted HTLC.
+func (r *htlcResolver) htlcScript(htlc *channeldb.HTLC) ([]byte, error) {
+	var (
+		keyRing   = r.commitment.keyRing
+		localKey  = keyRing.LocalHtlcKey
+		remoteKey = keyRing.RemoteHtlcKey
+		anchors   = r.channel.ChanType.HasAnchors()
+		offered   = htlc.Incoming != r.commitment.local
+	)
+
+	senderKey, receiverKey := localKey, remoteKey
+	if htlc.Incoming {
+		senderKey, receiverKey = remoteKey, localKey
+	}
+
+	if offered {
+		return input.SenderHTLCScript(
+			senderKey, receiverKey, keyRing.RevocationKey,
+			htlc.RHash[:], anchors,
+		)
+	}
+
+	return input.ReceiverHTLCScript(
+		htlc.RefundTimeout, senderKey, receiverKey,
+		keyRing.RevocationKey, htlc.RHash[:], anchors,
+	)
+}
+
+// resolveLocal creates the second level transaction for an HTLC on our own
+// commitment or, if it already confirmed, sweeps its time locked output.
+func (r *htlcResolver) resolveLocal(name string, htlc *channeldb.HTLC,
+	outpoint wire.OutPoint, signDesc *input.SignDescriptor,
+	preimage []byte) error {
+
+	if r.channel.ChanType.HasAnchors() {
+		log.Infof("Skipping %s, second level transactions of anchor "+
+			"channels are not supported", name)
+		return nil
+	}
+
+	tx, fee, err := r.secondLevelTx(htlc, outpoint, signDesc, preimage)
+	if err != nil {
+		return fmt.Errorf("error creating second level TX for %s: %w",
+			name, err)
+	}
+	txHash := tx.TxHash()
+
+	outspend := r.commitment.tx.Vout[htlc.OutputIndex].Outspend
+	switch {
+	case outspend == nil || !outspend.Spent:
+		log.Infof("Created second level TX %v for %s", txHash, name)
+		r.result.secondLevelTxs = append(
+			r.result.secondLevelTxs, &secondLevelTx{
+				tx:  tx,
+				fee: int64(fee),
+			},
+		)
+		return nil
+
+	case outspend.Txid != txHash.String():
+		log.Infof("Skipping %s, it was already spent by TX %s", name,
+			outspend.Txid)
+		return nil
+	}
+
+	// Our second level TX was published, its output can be swept once the
+	// CSV delay expired.
+	secondLevelOut := wire.OutPoint{Hash: txHash, Index: 0}
+	secondLevel, err := r.api.Transaction(txHash.String())
+	if err != nil {
+		return fmt.Errorf("error fetching second level TX %v: %w",
+			txHash, err)
+	}
+	if len(secondLevel.Vout) > 0 && secondLevel.Vout[0].Outspend != nil &&
+		secondLevel.Vout[0].Outspend.Spent {
+
+		log.Infof("Skipping %s, its second level output was already "+
+			"swept by TX %s", name,
+			secondLevel.Vout[0].Outspend.Txid)
+		return nil
+	}
+	if secondLevel.Status == nil || !secondLevel.Status.Confirmed {
+		log.Infof("Skipping %s, second level TX %v is not confirmed "+
+			"yet", name, txHash)
+		return nil
+	}
+	csvDelay := uint32(r.channel.LocalChanCfg.CsvDelay)
+	matured, blocksLeft := csvMatured(
+		uint32(secondLevel.Status.BlockHeight), r.bestHeight,
+		int32(csvDelay),
+	)
+	if !matured {
+		log.Infof("Skipping %s, time lock of second level TX %v "+
+			"expires in %d block(s)", name, txHash, blocksLeft)
+		return nil
+	}
+
+	keyRing := r.commitment.keyRing
+	script, err := input.SecondLevelHtlcScript(
+		keyRing.RevocationKey, keyRing.ToLocalKey, csvDelay,
+	)
+	if err != nil {
+		return err
+	}
+	pkScript, err := input.WitnessScriptHash(script)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Sweeping %s from second level output %v", name,
+		secondLevelOut)
+	r.result.sweeps = append(r.result.sweeps, &htlcSweep{
+		name:        name,
+		outpoint:    secondLevelOut,
+		sequence:    input.LockTimeToSequence(false, csvDelay),
+		witnessSize: input.ToLocalTimeoutWitnessSize,
+		signDesc: &input.SignDescriptor{
+			KeyDesc:       r.channel.LocalChanCfg.DelayBasePoint,
+			SingleTweak:   keyRing.LocalCommitKeyTweak,
+			WitnessScript: script,
+			Output: &wire.TxOut{
+				PkScript: pkScript,
+				Value:    tx.TxOut[0].Value,
+			},
+			HashType: txscript.SigHashAll,
+		},
+		witness: func(tx *wire.MsgTx,
+			desc *input.SignDescriptor) (wire.TxWitness, error) {
+
+			return input.CommitSpendTimeout(r.signer, desc, tx)
+		},
+	})
+
+	return nil
+}
+
+// secondLevelTx creates and signs the HTLC-success (incoming) or HTLC-timeout
+// (outgoing) transaction of an HTLC on our own commitment. The fee is paid
+// from the HTLC amount at the fee rate of the commitment.
+func (r *htlcResolver) secondLevelTx(htlc *channeldb.HTLC,
+	outpoint wire.OutPoint, signDesc *input.SignDescriptor,
+	preimage []byte) (*wire.MsgTx, btcutil.Amount, error) {
+
+	var (
+		chanType  = r.channel.ChanType
+		keyRing   = r.commitment.keyRing
+		csvDelay  = uint32(r.channel.LocalChanCfg.CsvDelay)
+		feePerKw  = chainfee.SatPerKWeight(r.commitment.commit.FeePerKw)
+		htlcValue = htlc.Amt.ToSatoshis()
+		fee       btcutil.Amount
+		tx        *wire.MsgTx
+		err       error
+	)
+	if htlc.Incoming {
+		fee = lnwallet.HtlcSuccessFee(chanType, feePerKw)
+		tx, err = lnwallet.CreateHtlcSuccessTx(
+			chanType, r.channel.IsInitiator, outpoint,
+			htlcValue-fee, csvDelay, 0, keyRing.RevocationKey,
+			keyRing.ToLocalKey,
+		)
+	} else {
+		fee = lnwallet.HtlcTimeoutFee(chanType, feePerKw)
+		tx, err = lnwallet.CreateHtlcTimeoutTx(
+			chanType, r.channel.IsInitiator, outpoint,
+			htlcValue-fee, htlc.RefundTimeout, csvDelay, 0,
+			keyRing.RevocationKey, keyRing.ToLocalKey,
+		)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The peer's signature for the second level TX is stored in the DB
+	// without the sighash flag.
+	remoteSig, err := ecdsa.ParseDERSignature(htlc.Signature)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error parsing remote HTLC "+
+			"signature: %w", err)
+	}
+	remoteSigHash := lnwallet.HtlcSigHashType(chanType)
+
+	// The fee rate of the commitment wasn't chosen by the user, so only
+	// the hard cap applies.
+	err = validateSweepTx(
+		r.api, tx, []*input.SignDescriptor{signDesc},
+		secondLevelVSize(chanType, htlc.Incoming), 0,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error validating TX: %w", err)
+	}
+
+	signDesc.SigHashes = input.NewTxSigHashesV0Only(tx)
+	signDesc.InputIndex = 0
+	var witness wire.TxWitness
+	if htlc.Incoming {
+		witness, err = input.ReceiverHtlcSpendRedeem(
+			remoteSig, remoteSigHash, preimage, r.signer, signDesc,
+			tx,
+		)
+	} else {
+		witness, err = input.SenderHtlcSpendTimeout(
+			remoteSig, remoteSigHash, r.signer, signDesc, tx,
+		)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	tx.TxIn[0].Witness = witness
+
+	return tx, fee, nil
+}
+
+// secondLevelVSize returns the virtual size of the signed HTLC-success
+// (incoming) or HTLC-timeout (outgoing) transaction of the given channel type.
+func secondLevelVSize(chanType channeldb.ChannelType, incoming bool) int64 {
+	var weight int64
+	switch {
+	case incoming && chanType.HasAnchors():
+		weight = input.HtlcSuccessWeightConfirmed
+
+	case incoming:
+		weight = input.HtlcSuccessWeight
+
+	case chanType.HasAnchors():
+		weight = input.HtlcTimeoutWeightConfirmed
+
+	default:
+		weight = input.HtlcTimeoutWeight
+	}
+
+	return (weight + 3) / 4
+}
+
+// createHtlcSweepTx creates and signs a transaction that sweeps all given
+// outputs to the sweep script.
+func createHtlcSweepTx(api btc.ChainBackend, signer *lnd.Signer,
+	sweeps []*htlcSweep, sweepScript []byte, feeRate uint16) (*wire.MsgTx,
+	int64, error) {
+
+	var (
+		sweepTx    = wire.NewMsgTx(2)
+		totalValue int64
+		estimator  input.TxWeightEstimator
+	)
+	for _, sweep := range sweeps {
+		sweepTx.TxIn = append(sweepTx.TxIn, &wire.TxIn{
+			PreviousOutPoint: sweep.outpoint,
+			Sequence:         sweep.sequence,
+		})
+		if sweep.lockTime > sweepTx.LockTime {
+			sweepTx.LockTime = sweep.lockTime
+		}
+		totalValue += sweep.signDesc.Output.Value
+		estimator.AddWitnessInput(sweep.witnessSize)
+	}
+	estimator.AddP2WKHOutput()
+
+	feeRateKWeight := chainfee.SatPerKVByte(1000 * feeRate).FeePerKWeight()
+	totalFee := int64(feeRateKWeight.FeeForWeight(
+		int64(estimator.Weight()),
+	))
+	if totalValue-totalFee <= 0 {
+		return nil, 0, fmt.Errorf("total value %d sats of the HTLC "+
+			"outputs doesn't cover the fee of %d sats", totalValue,
+			totalFee)
+	}
+
+	log.Infof("Sweeping %d HTLC output(s) with a total value of %d sats, "+
+		"fee %d sats", len(sweeps), totalValue, totalFee)
+
+	sweepTx.TxOut = []*wire.TxOut{{
+		Value:    totalValue - totalFee,
+		PkScript: sweepScript,
+	}}
+
+	signDescs := make([]*input.SignDescriptor, len(sweeps))
+	for idx, sweep := range sweeps {
+		signDescs[idx] = sweep.signDesc
+	}
+	err := validateSweepTx(
+		api, sweepTx, signDescs, int64(estimator.VSize()), feeRate,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error validating sweep TX: %w", err)
+	}
+
+	sigHashes := input.NewTxSigHashesV0Only(sweepTx)
+	for idx, sweep := range sweeps {
+		sweep.signDesc.SigHashes = sigHashes
+		sweep.signDesc.InputIndex = idx
+		witness, err := sweep.witness(sweepTx, sweep.signDesc)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error signing %s: %w",
+				sweep.name, err)
+		}
+		sweepTx.TxIn[idx].Witness = witness
+	}
+
+	return sweepTx, totalFee, nil
+}
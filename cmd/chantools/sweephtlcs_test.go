@@ -0,0 +1,401 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// htlcTestSetup is a channel of the test DB with HTLCs on a fake commitment
+// transaction that spent the funding output.
+type htlcTestSetup struct {
+	channel  *channeldb.OpenChannel
+	signer   *lnd.Signer
+	keyRing  *lnwallet.CommitmentKeyRing
+	commitTx *wire.MsgTx
+	chain    *fakeChainBackend
+	prevOuts map[wire.OutPoint]*wire.TxOut
+}
+
+// newHtlcTestSetup loads the test channel and publishes a fake local or
+// remote commitment that contains the given HTLCs. The output index of each
+// HTLC is set to its position. The HTLC base point of the peer is replaced
+// with a key we know, so we can sign the second level TXs of our commitment
+// as the peer.
+func newHtlcTestSetup(t *testing.T, h *harness, local bool,
+	htlcs []channeldb.HTLC) *htlcTestSetup {
+
+	db, err := lnd.OpenDB(h.testdataFile("channel.db"), true)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	chanPoint, err := lnd.ParseOutpoint(coopCloseInitiatorChannel)
+	require.NoError(t, err)
+	channel, err := db.ChannelStateDB().FetchChannel(nil, *chanPoint)
+	require.NoError(t, err)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+
+	remoteKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	channel.RemoteChanCfg.HtlcBasePoint.PubKey = remoteKey.PubKey()
+
+	commit := &channel.RemoteCommitment
+	commitPoint := channel.RemoteCurrentRevocation
+	if local {
+		commit = &channel.LocalCommitment
+		revocation, err := channel.RevocationProducer.AtIndex(
+			commit.CommitHeight,
+		)
+		require.NoError(t, err)
+		commitPoint = input.ComputeCommitmentPoint(revocation[:])
+	}
+
+	s := &htlcTestSetup{
+		channel: channel,
+		signer: &lnd.Signer{
+			ExtendedKey: extendedKey,
+			ChainParams: chainParams,
+		},
+		keyRing: lnwallet.DeriveCommitmentKeys(
+			commitPoint, local, channel.ChanType,
+			&channel.LocalChanCfg, &channel.RemoteChanCfg,
+		),
+		commitTx: wire.NewMsgTx(2),
+		chain:    newFakeChainBackend(),
+		prevOuts: make(map[wire.OutPoint]*wire.TxOut),
+	}
+	s.commitTx.AddTxIn(&wire.TxIn{PreviousOutPoint: *chanPoint})
+
+	for idx := range htlcs {
+		htlc := &htlcs[idx]
+		htlc.OutputIndex = int32(idx)
+
+		script := s.htlcScript(t, local, htlc)
+		pkScript, err := input.WitnessScriptHash(script)
+		require.NoError(t, err)
+		s.commitTx.AddTxOut(&wire.TxOut{
+			Value:    int64(htlc.Amt.ToSatoshis()),
+			PkScript: pkScript,
+		})
+	}
+	if local {
+		for idx := range htlcs {
+			s.signSecondLevel(
+				t, remoteKey, commitPoint, &htlcs[idx],
+			)
+		}
+	}
+	commit.Htlcs = htlcs
+	commit.CommitTx = s.commitTx
+
+	s.chain.addAPITx(&btc.TX{
+		TXID: chanPoint.Hash.String(),
+		Vout: []*btc.Vout{{
+			Value: uint64(channel.Capacity),
+			Outspend: &btc.Outspend{
+				Spent: true,
+				Txid:  s.commitTx.TxHash().String(),
+			},
+		}},
+	})
+	s.chain.addTx(t, s.commitTx, &btc.Status{Confirmed: true})
+
+	return s
+}
+
+// htlcScript returns the witness script of the given HTLC on the local or
+// remote commitment.
+func (s *htlcTestSetup) htlcScript(t *testing.T, local bool,
+	htlc *channeldb.HTLC) []byte {
+
+	var (
+		script []byte
+		err    error
+		k      = s.keyRing
+	)
+	switch {
+	case local && htlc.Incoming:
+		script, err = input.ReceiverHTLCScript(
+			htlc.RefundTimeout, k.RemoteHtlcKey, k.LocalHtlcKey,
+			k.RevocationKey, htlc.RHash[:], false,
+		)
+
+	case local:
+		script, err = input.SenderHTLCScript(
+			k.LocalHtlcKey, k.RemoteHtlcKey, k.RevocationKey,
+			htlc.RHash[:], false,
+		)
+
+	case htlc.Incoming:
+		script, err = input.SenderHTLCScript(
+			k.RemoteHtlcKey, k.LocalHtlcKey, k.RevocationKey,
+			htlc.RHash[:], false,
+		)
+
+	default:
+		script, err = input.ReceiverHTLCScript(
+			htlc.RefundTimeout, k.LocalHtlcKey, k.RemoteHtlcKey,
+			k.RevocationKey, htlc.RHash[:], false,
+		)
+	}
+	require.NoError(t, err)
+
+	return script
+}
+
+// signSecondLevel adds the signature of the peer for the second level TX of
+// the given HTLC on our commitment.
+func (s *htlcTestSetup) signSecondLevel(t *testing.T,
+	remoteKey *btcec.PrivateKey, commitPoint *btcec.PublicKey,
+	htlc *channeldb.HTLC) {
+
+	var (
+		chanType = s.channel.ChanType
+		k        = s.keyRing
+		csvDelay = uint32(s.channel.LocalChanCfg.CsvDelay)
+		feePerKw = chainfee.SatPerKWeight(
+			s.channel.LocalCommitment.FeePerKw,
+		)
+		htlcValue = htlc.Amt.ToSatoshis()
+		outpoint  = wire.OutPoint{
+			Hash:  s.commitTx.TxHash(),
+			Index: uint32(htlc.OutputIndex),
+		}
+		tx  *wire.MsgTx
+		err error
+	)
+	if htlc.Incoming {
+		tx, err = lnwallet.CreateHtlcSuccessTx(
+			chanType, s.channel.IsInitiator, outpoint,
+			htlcValue-lnwallet.HtlcSuccessFee(chanType, feePerKw),
+			csvDelay, 0, k.RevocationKey, k.ToLocalKey,
+		)
+	} else {
+		tx, err = lnwallet.CreateHtlcTimeoutTx(
+			chanType, s.channel.IsInitiator, outpoint,
+			htlcValue-lnwallet.HtlcTimeoutFee(chanType, feePerKw),
+			htlc.RefundTimeout, csvDelay, 0, k.RevocationKey,
+			k.ToLocalKey,
+		)
+	}
+	require.NoError(t, err)
+
+	script := s.htlcScript(t, true, htlc)
+	txOut := s.commitTx.TxOut[htlc.OutputIndex]
+	sigHashes := txscript.NewTxSigHashes(
+		tx, txscript.NewCannedPrevOutputFetcher(
+			txOut.PkScript, txOut.Value,
+		),
+	)
+	sigHash, err := txscript.CalcWitnessSigHash(
+		script, sigHashes, txscript.SigHashAll, tx, 0, txOut.Value,
+	)
+	require.NoError(t, err)
+
+	tweakedKey := input.TweakPrivKey(
+		remoteKey, input.SingleTweakBytes(
+			commitPoint, remoteKey.PubKey(),
+		),
+	)
+	htlc.Signature = ecdsa.Sign(tweakedKey, sigHash).Serialize()
+}
+
+// addPrevOuts remembers the outputs of the given TX for verifying the
+// transactions that spend them.
+func (s *htlcTestSetup) addPrevOuts(tx *wire.MsgTx) {
+	for idx, txOut := range tx.TxOut {
+		s.prevOuts[wire.OutPoint{
+			Hash:  tx.TxHash(),
+			Index: uint32(idx),
+		}] = txOut
+	}
+}
+
+// verify executes the scripts of all inputs of the given TX.
+func (s *htlcTestSetup) verify(t *testing.T, tx *wire.MsgTx) {
+	fetcher := txscript.NewMultiPrevOutFetcher(s.prevOuts)
+	sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+	for idx, txIn := range tx.TxIn {
+		prevOut := s.prevOuts[txIn.PreviousOutPoint]
+		require.NotNil(t, prevOut)
+
+		vm, err := txscript.NewEngine(
+			prevOut.PkScript, tx, idx, txscript.StandardVerifyFlags,
+			nil, sigHashes, prevOut.Value, fetcher,
+		)
+		require.NoError(t, err)
+		require.NoError(t, vm.Execute(), "input %d", idx)
+	}
+}
+
+// testHtlc returns an HTLC and the preimage of its payment hash.
+func testHtlc(incoming bool, sats int64, cltv uint32,
+	preimageByte byte) (channeldb.HTLC, []byte) {
+
+	preimage := make([]byte, 32)
+	preimage[0] = preimageByte
+
+	return channeldb.HTLC{
+		RHash:         sha256.Sum256(preimage),
+		Amt:           lnwire.NewMSatFromSatoshis(btcutil.Amount(sats)),
+		RefundTimeout: cltv,
+		Incoming:      incoming,
+	}, preimage
+}
+
+func TestSweepHtlcsRemoteCommitment(t *testing.T) {
+	h := newHarness(t)
+
+	incoming, preimage := testHtlc(true, 100_000, 500, 0x01)
+	outgoing, _ := testHtlc(false, 200_000, 1000, 0x02)
+	unknown, _ := testHtlc(true, 300_000, 500, 0x03)
+	s := newHtlcTestSetup(t, h, false, []channeldb.HTLC{
+		incoming, outgoing, unknown,
+	})
+	s.addPrevOuts(s.commitTx)
+	preimages := map[[32]byte][]byte{incoming.RHash: preimage}
+
+	// Only the incoming HTLC we know the preimage of can be swept before
+	// the outgoing HTLC timed out.
+	s.chain.setHeight(999)
+	resolution, err := resolveHtlcs(s.chain, s.signer, s.channel, preimages)
+	require.NoError(t, err)
+	require.Len(t, resolution.sweeps, 1)
+	require.Empty(t, resolution.secondLevelTxs)
+	h.assertLogContains("the preimage is required to sweep it")
+	h.assertLogContains("it times out at height 1000, in 1 block(s)")
+
+	s.chain.setHeight(1000)
+	resolution, err = resolveHtlcs(s.chain, s.signer, s.channel, preimages)
+	require.NoError(t, err)
+	require.Len(t, resolution.sweeps, 2)
+
+	sweepScript, err := lnd.GetP2WPKHScript(
+		testDeliveryAddr(t, 0x01), chainParams,
+	)
+	require.NoError(t, err)
+	sweepTx, fee, err := createHtlcSweepTx(
+		s.chain, s.signer, resolution.sweeps, sweepScript, 10,
+	)
+	require.NoError(t, err)
+	require.EqualValues(t, 1000, sweepTx.LockTime)
+	require.Len(t, sweepTx.TxOut, 1)
+	require.EqualValues(t, 300_000-fee, sweepTx.TxOut[0].Value)
+	s.verify(t, sweepTx)
+
+	// An output that was already spent isn't swept again.
+	commitHash := s.commitTx.TxHash()
+	for idx := uint32(0); idx < 2; idx++ {
+		s.chain.spend(
+			t, wire.OutPoint{Hash: commitHash, Index: idx},
+			sweepTx.TxHash().String(), &btc.Status{Confirmed: true},
+		)
+	}
+	resolution, err = resolveHtlcs(s.chain, s.signer, s.channel, preimages)
+	require.NoError(t, err)
+	require.Empty(t, resolution.sweeps)
+	h.assertLogContains("it was already spent by TX " +
+		sweepTx.TxHash().String())
+
+	// A preimage that doesn't belong to any incoming HTLC is rejected, even
+	// if it's the one of an outgoing HTLC.
+	_, outgoingPreimage := testHtlc(false, 200_000, 1000, 0x02)
+	_, otherPreimage := testHtlc(true, 100_000, 500, 0x04)
+	for _, wrongPreimage := range [][]byte{
+		outgoingPreimage, otherPreimage,
+	} {
+		wrongPreimages, err := parsePreimages([]string{
+			hex.EncodeToString(preimage),
+			hex.EncodeToString(wrongPreimage),
+		})
+		require.NoError(t, err)
+		_, err = resolveHtlcs(
+			s.chain, s.signer, s.channel, wrongPreimages,
+		)
+		hash := sha256.Sum256(wrongPreimage)
+		require.ErrorContains(t, err, fmt.Sprintf("preimage of "+
+			"payment hash %x doesn't belong to any incoming HTLC",
+			hash[:]))
+	}
+}
+
+func TestSweepHtlcsLocalCommitment(t *testing.T) {
+	h := newHarness(t)
+
+	incoming, preimage := testHtlc(true, 100_000, 500, 0x01)
+	outgoing, _ := testHtlc(false, 200_000, 1000, 0x02)
+	s := newHtlcTestSetup(t, h, true, []channeldb.HTLC{
+		incoming, outgoing,
+	})
+	s.addPrevOuts(s.commitTx)
+	preimages := map[[32]byte][]byte{incoming.RHash: preimage}
+
+	// Both second level TXs can be created once the outgoing HTLC timed
+	// out, they contain the signatures of both parties.
+	s.chain.setHeight(1000)
+	resolution, err := resolveHtlcs(s.chain, s.signer, s.channel, preimages)
+	require.NoError(t, err)
+	require.Empty(t, resolution.sweeps)
+	require.Len(t, resolution.secondLevelTxs, 2)
+
+	successTx := resolution.secondLevelTxs[0].tx
+	timeoutTx := resolution.secondLevelTxs[1].tx
+	require.EqualValues(t, 1000, timeoutTx.LockTime)
+	s.verify(t, successTx)
+	s.verify(t, timeoutTx)
+
+	// Once they are confirmed, their outputs can be swept after the CSV
+	// delay.
+	status := &btc.Status{Confirmed: true, BlockHeight: 1001}
+	commitHash := s.commitTx.TxHash()
+	for idx, tx := range []*wire.MsgTx{successTx, timeoutTx} {
+		s.chain.spend(
+			t, wire.OutPoint{Hash: commitHash, Index: uint32(idx)},
+			tx.TxHash().String(), status,
+		)
+		s.chain.addTx(t, tx, status)
+	}
+	s.addPrevOuts(successTx)
+	s.addPrevOuts(timeoutTx)
+
+	csvDelay := uint32(s.channel.LocalChanCfg.CsvDelay)
+	s.chain.setHeight(1000 + csvDelay - 1)
+	resolution, err = resolveHtlcs(s.chain, s.signer, s.channel, preimages)
+	require.NoError(t, err)
+	require.Empty(t, resolution.sweeps)
+	require.Empty(t, resolution.secondLevelTxs)
+	h.assertLogContains("expires in 1 block(s)")
+
+	s.chain.setHeight(1000 + csvDelay)
+	resolution, err = resolveHtlcs(s.chain, s.signer, s.channel, preimages)
+	require.NoError(t, err)
+	require.Len(t, resolution.sweeps, 2)
+
+	sweepScript, err := lnd.GetP2WPKHScript(
+		testDeliveryAddr(t, 0x01), chainParams,
+	)
+	require.NoError(t, err)
+	sweepTx, _, err := createHtlcSweepTx(
+		s.chain, s.signer, resolution.sweeps, sweepScript, 10,
+	)
+	require.NoError(t, err)
+	s.verify(t, sweepTx)
+}
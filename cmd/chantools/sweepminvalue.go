@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/spf13/cobra"
+)
+
+// addMinValueFlag adds the --min-value flag to the given sweep command.
+func addMinValueFlag(cmd *cobra.Command, minValue *uint64) {
+	cmd.Flags().Uint64Var(
+		minValue, "min-value", 0, "don't sweep outputs with a value "+
+			"below the given number of satoshis because they "+
+			"would cost more in fees than they are worth; if not "+
+			"set, the dust limit plus the fee for spending the "+
+			"output at the sweep fee rate is used",
+	)
+}
+
+// minValueFilter decides which outputs are worth being swept and keeps track
+// of the ones that are skipped.
+type minValueFilter struct {
+	minValue uint64
+	feeRate  uint16
+	skipped  []string
+}
+
+// newMinValueFilter creates a filter for the given minimum value. If the
+// minimum value is zero, the threshold is derived from the fee rate.
+func newMinValueFilter(minValue uint64, feeRate uint16) *minValueFilter {
+	return &minValueFilter{
+		minValue: minValue,
+		feeRate:  feeRate,
+	}
+}
+
+// threshold returns the minimum value an output that is spent with a witness
+// of the given size must have to be swept.
+func (f *minValueFilter) threshold(witnessSize int) uint64 {
+	if f.minValue != 0 {
+		return f.minValue
+	}
+
+	// An output is only worth sweeping if it is above the dust limit
+	// after paying for the weight it adds to the sweep transaction.
+	weight := input.InputSize*blockchain.WitnessScaleFactor + witnessSize
+	feeRateKWeight := chainfee.SatPerKVByte(
+		1000 * uint64(f.feeRate),
+	).FeePerKWeight()
+	inputFee := feeRateKWeight.FeeForWeight(int64(weight))
+	dustLimit := lnwallet.DustLimitForSize(input.P2WSHSize)
+
+	return uint64(dustLimit + inputFee)
+}
+
+// keep returns true if the output with the given value should be swept. If
+// not, the output is recorded as skipped under the given name.
+func (f *minValueFilter) keep(name string, value uint64,
+	witnessSize int) bool {
+
+	if value >= f.threshold(witnessSize) {
+		return true
+	}
+
+	f.skipped = append(f.skipped, fmt.Sprintf("%s (%d sats)", name, value))
+
+	return false
+}
+
+// logSkipped logs all outputs that were skipped because of their value.
+func (f *minValueFilter) logSkipped() {
+	if len(f.skipped) == 0 {
+		return
+	}
+
+	log.Infof("Skipped %d output(s) below the minimum value, use "+
+		"--min-value to change the threshold: %s", len(f.skipped),
+		strings.Join(f.skipped, ", "))
+}
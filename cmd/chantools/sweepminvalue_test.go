@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinValueFilter(t *testing.T) {
+	h := newHarness(t)
+
+	// Without a minimum value, the threshold is the dust limit plus the
+	// fee for spending the input at the fee rate.
+	filter := newMinValueFilter(0, 10)
+	require.EqualValues(
+		t, 330+682, filter.threshold(input.P2WKHWitnessSize),
+	)
+
+	require.True(t, filter.keep("a:0", 1012, input.P2WKHWitnessSize))
+	require.False(t, filter.keep("b:1", 1011, input.P2WKHWitnessSize))
+	require.False(t, filter.keep("c:2", 1500, 1000))
+
+	filter.logSkipped()
+	h.assertLogContains("Skipped 2 output(s) below the minimum value")
+	h.assertLogContains("b:1 (1011 sats), c:2 (1500 sats)")
+
+	// A minimum value that is set explicitly is used as is.
+	filter = newMinValueFilter(1, 100)
+	require.EqualValues(t, 1, filter.threshold(input.P2WKHWitnessSize))
+	require.True(t, filter.keep("a:0", 1, input.P2WKHWitnessSize))
+}
@@ -1,8 +1,6 @@
 package main
 
 import (
-	"bytes"
-	"encoding/hex"
 	"fmt"
 
 	"github.com/btcsuite/btcd/btcec/v2"
@@ -22,14 +20,34 @@ import (
 const (
 	sweepRemoteClosedDefaultRecoveryWindow = 200
 	sweepDustLimit                         = 600
+
+	// sweepRemoteClosedAddrsPerKey is the number of addresses that are
+	// queried for each key: the P2WKH, the P2WSH anchor and the P2TR
+	// simple taproot address.
+	sweepRemoteClosedAddrsPerKey = 3
+
+	// rbfSequence is the highest sequence number that still signals
+	// replace-by-fee as defined in BIP125.
+	rbfSequence = wire.MaxTxInSequenceNum - 2
 )
 
 type sweepRemoteClosedCommand struct {
 	RecoveryWindow uint32
+	GapLimit       uint32
+	MaxIndex       uint32
 	APIURL         string
 	Publish        bool
+	DryRun         bool
 	SweepAddr      string
+	DestDescriptor string
+	DestIndex      uint32
+	DestGapLimit   uint32
 	FeeRate        uint16
+	ConfTarget     uint32
+	MinValue       uint64
+	RBF            bool
+	Psbt           bool
+	Resume         string
 
 	rootKey *rootKey
 	cmd     *cobra.Command
@@ -53,6 +71,26 @@ funds can be swept after the force-close transaction was confirmed.
 Supported remote force-closed channel types are:
  - STATIC_REMOTE_KEY (a.k.a. tweakless channels)
  - ANCHOR (a.k.a. anchor output channels)
+ - SIMPLE_TAPROOT (a.k.a. simple taproot channels)
+
+Use the --rbf flag to signal replace-by-fee on all inputs so a sweep
+transaction that is stuck with a too low fee can be replaced later with the
+bumpfee command.
+
+With the --psbt flag, an unsigned PSBT with the witness UTXOs, witness scripts
+and BIP32 derivation paths of all inputs is created instead of a signed
+transaction.
+
+At least --recoverywindow keys are scanned. If funds are found close to the end
+of the recovery window, the scan continues until --gap-limit consecutive keys
+without any funds were found, but never beyond --max-index. The highest key
+index with funds is reported at the end, if it's close to the end of the scan,
+it might be worth running the scan again with a higher --gap-limit.
+
+Scanning a large recovery window can take a long time. The progress is logged
+periodically and with the --resume flag the scan position is checkpointed to a
+JSON state file, so an interrupted scan (for example with Ctrl-C) can be
+continued by running the same command with the same --resume file again.
 `,
 		Example: `chantools sweepremoteclosed \
 	--recoverywindow 300 \
@@ -66,6 +104,7 @@ Supported remote force-closed channel types are:
 		sweepRemoteClosedDefaultRecoveryWindow, "number of keys to "+
 			"scan per derivation path",
 	)
+	addGapLimitFlags(cc.cmd, &cc.GapLimit, &cc.MaxIndex)
 	cc.cmd.Flags().StringVar(
 		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
 			"be esplora compatible)",
@@ -74,13 +113,26 @@ Supported remote force-closed channel types are:
 		&cc.Publish, "publish", false, "publish sweep TX to the chain "+
 			"API instead of just printing the TX",
 	)
+	addDryRunFlag(cc.cmd, &cc.DryRun)
 	cc.cmd.Flags().StringVar(
 		&cc.SweepAddr, "sweepaddr", "", "address to sweep the funds to",
 	)
+	addDestDescriptorFlags(
+		cc.cmd, &cc.DestDescriptor, &cc.DestIndex, &cc.DestGapLimit,
+	)
 	cc.cmd.Flags().Uint16Var(
 		&cc.FeeRate, "feerate", defaultFeeSatPerVByte, "fee rate to "+
 			"use for the sweep transaction in sat/vByte",
 	)
+	addConfTargetFlag(cc.cmd, &cc.ConfTarget)
+	addMinValueFlag(cc.cmd, &cc.MinValue)
+	cc.cmd.Flags().BoolVar(
+		&cc.RBF, "rbf", false, "signal replace-by-fee (BIP125) on "+
+			"all inputs so the sweep transaction can be fee "+
+			"bumped later with the bumpfee command",
+	)
+	addPsbtFlag(cc.cmd, &cc.Psbt)
+	addResumeFlag(cc.cmd, &cc.Resume)
 
 	cc.rootKey = newRootKey(cc.cmd, "sweeping the wallet")
 
@@ -93,10 +145,23 @@ func (c *sweepRemoteClosedCommand) Execute(_ *cobra.Command, _ []string) error {
 		return fmt.Errorf("error reading root key: %w", err)
 	}
 
+	// The sweep address can also be derived from a descriptor.
+	c.SweepAddr, err = sweepDestination(
+		c.SweepAddr, c.DestDescriptor, c.DestIndex, c.DestGapLimit,
+		c.APIURL,
+	)
+	if err != nil {
+		return err
+	}
+
 	// Make sure sweep addr is set.
 	if c.SweepAddr == "" {
 		return fmt.Errorf("sweep addr is required")
 	}
+	if c.Psbt && c.Publish {
+		return fmt.Errorf("cannot publish a PSBT, it must be signed " +
+			"first")
+	}
 
 	// Set default values.
 	if c.RecoveryWindow == 0 {
@@ -105,10 +170,13 @@ func (c *sweepRemoteClosedCommand) Execute(_ *cobra.Command, _ []string) error {
 	if c.FeeRate == 0 {
 		c.FeeRate = defaultFeeSatPerVByte
 	}
+	c.FeeRate = sweepFeeRate(c.APIURL, c.ConfTarget, c.FeeRate)
 
+	publish := shouldPublish(c.Publish, c.DryRun, c.APIURL)
 	return sweepRemoteClosed(
-		extendedKey, c.APIURL, c.SweepAddr, c.RecoveryWindow, c.FeeRate,
-		c.Publish,
+		extendedKey, c.APIURL, c.SweepAddr, c.RecoveryWindow,
+		c.GapLimit, c.MaxIndex, c.FeeRate, c.MinValue, publish, c.RBF,
+		c.Psbt, c.Resume,
 	)
 }
 
@@ -119,52 +187,27 @@ type targetAddr struct {
 	keyDesc *keychain.KeyDescriptor
 	vouts   []*btc.Vout
 	script  []byte
+
+	// scriptTree is the script tree of a simple taproot to_remote output,
+	// script is the leaf script that is spent in that case.
+	scriptTree *input.CommitScriptTree
 }
 
 func sweepRemoteClosed(extendedKey *hdkeychain.ExtendedKey, apiURL,
-	sweepAddr string, recoveryWindow uint32, feeRate uint16,
-	publish bool) error {
+	sweepAddr string, recoveryWindow, gapLimit, maxIndex uint32,
+	feeRate uint16, minValue uint64, publish, rbf, createPsbt bool,
+	resumeFile string) error {
 
-	var (
-		targets []*targetAddr
-		api     = &btc.ExplorerAPI{BaseURL: apiURL}
+	targets, err := scanRemoteClosed(
+		extendedKey, apiURL, recoveryWindow, gapLimit, maxIndex,
+		resumeFile,
 	)
-	for index := uint32(0); index < recoveryWindow; index++ {
-		path := fmt.Sprintf("m/1017'/%d'/%d'/0/%d",
-			chainParams.HDCoinType, keychain.KeyFamilyPaymentBase,
-			index)
-		parsedPath, err := lnd.ParsePath(path)
-		if err != nil {
-			return fmt.Errorf("error parsing path: %w", err)
-		}
-
-		hdKey, err := lnd.DeriveChildren(
-			extendedKey, parsedPath,
-		)
-		if err != nil {
-			return fmt.Errorf("eror deriving children: %w", err)
-		}
-
-		privKey, err := hdKey.ECPrivKey()
-		if err != nil {
-			return fmt.Errorf("could not derive private "+
-				"key: %w", err)
-		}
-
-		foundTargets, err := queryAddressBalances(
-			privKey.PubKey(), path, &keychain.KeyDescriptor{
-				PubKey: privKey.PubKey(),
-				KeyLocator: keychain.KeyLocator{
-					Family: keychain.KeyFamilyPaymentBase,
-					Index:  index,
-				},
-			}, api,
-		)
-		if err != nil {
-			return fmt.Errorf("could not query API for "+
-				"addresses with funds: %w", err)
-		}
-		targets = append(targets, foundTargets...)
+	if err != nil {
+		return err
+	}
+	api, err := newChainBackend(apiURL)
+	if err != nil {
+		return err
 	}
 
 	// Create estimator and transaction template.
@@ -173,12 +216,35 @@ func sweepRemoteClosed(extendedKey *hdkeychain.ExtendedKey, apiURL,
 		signDescs        []*input.SignDescriptor
 		sweepTx          = wire.NewMsgTx(2)
 		totalOutputValue = uint64(0)
+		dust             = newMinValueFilter(minValue, feeRate)
 	)
 
 	// Add all found target outputs.
 	for _, target := range targets {
 		for _, vout := range target.vouts {
+			sequence := wire.MaxTxInSequenceNum
+			if rbf {
+				sequence = rbfSequence
+			}
+			witnessSize := input.P2WKHWitnessSize
+			switch target.addr.(type) {
+			case *btcutil.AddressWitnessScriptHash:
+				witnessSize = input.ToRemoteConfirmedWitnessSize
+				sequence = 1
+
+			case *btcutil.AddressTaproot:
+				witnessSize = input.TaprootToRemoteWitnessSize
+				sequence = 1
+			}
+
+			name := fmt.Sprintf("%s:%d", vout.Outspend.Txid,
+				vout.Outspend.Vin)
+			if !dust.keep(name, vout.Value, witnessSize) {
+				continue
+			}
+
 			totalOutputValue += vout.Value
+			estimator.AddWitnessInput(witnessSize)
 
 			txHash, err := chainhash.NewHashFromStr(
 				vout.Outspend.Txid,
@@ -195,18 +261,6 @@ func sweepRemoteClosed(extendedKey *hdkeychain.ExtendedKey, apiURL,
 					err)
 			}
 
-			sequence := wire.MaxTxInSequenceNum
-			switch target.addr.(type) {
-			case *btcutil.AddressWitnessPubKeyHash:
-				estimator.AddP2WKHInput()
-
-			case *btcutil.AddressWitnessScriptHash:
-				estimator.AddWitnessInput(
-					input.ToRemoteConfirmedWitnessSize,
-				)
-				sequence = 1
-			}
-
 			sweepTx.TxIn = append(sweepTx.TxIn, &wire.TxIn{
 				PreviousOutPoint: wire.OutPoint{
 					Hash:  *txHash,
@@ -215,7 +269,7 @@ func sweepRemoteClosed(extendedKey *hdkeychain.ExtendedKey, apiURL,
 				Sequence: sequence,
 			})
 
-			signDescs = append(signDescs, &input.SignDescriptor{
+			signDesc := &input.SignDescriptor{
 				KeyDesc:       *target.keyDesc,
 				WitnessScript: target.script,
 				Output: &wire.TxOut{
@@ -223,11 +277,21 @@ func sweepRemoteClosed(extendedKey *hdkeychain.ExtendedKey, apiURL,
 					Value:    int64(vout.Value),
 				},
 				HashType: txscript.SigHashAll,
-			})
+			}
+			if target.scriptTree != nil {
+				err := lnd.SetTaprootScriptSpend(
+					signDesc, &target.scriptTree.ScriptTree,
+				)
+				if err != nil {
+					return err
+				}
+			}
+			signDescs = append(signDescs, signDesc)
 		}
 	}
 
-	if len(targets) == 0 || totalOutputValue < sweepDustLimit {
+	dust.logSkipped()
+	if len(signDescs) == 0 || totalOutputValue < sweepDustLimit {
 		return fmt.Errorf("found %d sweep targets with total value "+
 			"of %d satoshis which is below the dust limit of %d",
 			len(targets), totalOutputValue, sweepDustLimit)
@@ -253,19 +317,48 @@ func sweepRemoteClosed(extendedKey *hdkeychain.ExtendedKey, apiURL,
 		PkScript: sweepScript,
 	}}
 
+	err = validateSweepTx(
+		api, sweepTx, signDescs, int64(estimator.VSize()), feeRate,
+	)
+	if err != nil {
+		return fmt.Errorf("error validating sweep TX: %w", err)
+	}
+
+	// Instead of signing the transaction ourselves, we create a PSBT that
+	// can be signed on another machine.
+	if createPsbt {
+		packet, err := newSweepPsbt(extendedKey, sweepTx, signDescs)
+		if err != nil {
+			return err
+		}
+		return logPsbt(packet)
+	}
+
 	// Sign the transaction now.
 	var (
 		signer = &lnd.Signer{
 			ExtendedKey: extendedKey,
 			ChainParams: chainParams,
 		}
-		sigHashes = input.NewTxSigHashesV0Only(sweepTx)
+		prevOuts  = lnd.PrevOutputFetcher(sweepTx, signDescs)
+		sigHashes = txscript.NewTxSigHashes(sweepTx, prevOuts)
 	)
 	for idx, desc := range signDescs {
 		desc.SigHashes = sigHashes
+		desc.PrevOutputFetcher = prevOuts
 		desc.InputIndex = idx
 
-		if len(desc.WitnessScript) > 0 {
+		switch {
+		case desc.SignMethod == input.TaprootScriptSpendSignMethod:
+			witness, err := input.TaprootCommitRemoteSpend(
+				signer, desc, sweepTx, nil,
+			)
+			if err != nil {
+				return err
+			}
+			sweepTx.TxIn[idx].Witness = witness
+
+		case len(desc.WitnessScript) > 0:
 			witness, err := input.CommitSpendToRemoteConfirmed(
 				signer, desc, sweepTx,
 			)
@@ -273,7 +366,8 @@ func sweepRemoteClosed(extendedKey *hdkeychain.ExtendedKey, apiURL,
 				return err
 			}
 			sweepTx.TxIn[idx].Witness = witness
-		} else {
+
+		default:
 			// The txscript library expects the witness script of a
 			// P2WKH descriptor to be set to the pkScript of the
 			// output...
@@ -288,34 +382,116 @@ func sweepRemoteClosed(extendedKey *hdkeychain.ExtendedKey, apiURL,
 		}
 	}
 
-	var buf bytes.Buffer
-	err = sweepTx.Serialize(&buf)
+	return publishTx(api, sweepTx, int64(totalFee), publish)
+}
+
+// scanRemoteClosed scans the payment base keys of the wallet for P2WKH, anchor
+// and simple taproot addresses with funds of channels that were force-closed by
+// the remote party and returns all addresses with unspent outputs.
+func scanRemoteClosed(extendedKey *hdkeychain.ExtendedKey, apiURL string,
+	recoveryWindow, gapLimit, maxIndex uint32,
+	resumeFile string) ([]*targetAddr, error) {
+
+	state, err := loadScanState(resumeFile, extendedKey)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	api, err := newChainBackend(apiURL)
+	if err != nil {
+		return nil, err
 	}
 
-	// Publish TX.
-	if publish {
-		response, err := api.PublishTx(
-			hex.EncodeToString(buf.Bytes()),
+	var (
+		targets    []*targetAddr
+		branchPath = fmt.Sprintf("m/1017'/%d'/%d'/0",
+			chainParams.HDCoinType, keychain.KeyFamilyPaymentBase)
+		branch = state.branch(branchPath)
+	)
+	queryIndex := func(index uint32) ([]*targetAddr, error) {
+		path := fmt.Sprintf("%s/%d", branchPath, index)
+		parsedPath, err := lnd.ParsePath(path)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing path: %w", err)
+		}
+
+		hdKey, err := lnd.DeriveChildren(
+			extendedKey, parsedPath,
 		)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("eror deriving children: %w",
+				err)
 		}
-		log.Infof("Published TX %s, response: %s",
-			sweepTx.TxHash().String(), response)
+
+		privKey, err := hdKey.ECPrivKey()
+		if err != nil {
+			return nil, fmt.Errorf("could not derive private "+
+				"key: %w", err)
+		}
+
+		foundTargets, err := queryAddressBalances(
+			privKey.PubKey(), path, &keychain.KeyDescriptor{
+				PubKey: privKey.PubKey(),
+				KeyLocator: keychain.KeyLocator{
+					Family: keychain.KeyFamilyPaymentBase,
+					Index:  index,
+				},
+			}, api,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("could not query API for "+
+				"addresses with funds: %w", err)
+		}
+
+		return foundTargets, nil
+	}
+
+	// When resuming a scan, we only need to query the indexes again that
+	// had funds in the previous run.
+	for _, index := range branch.FoundIndexes {
+		foundTargets, err := queryIndex(index)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, foundTargets...)
+	}
+
+	err = state.scan(
+		branchPath, recoveryWindow, gapLimit, maxIndex,
+		sweepRemoteClosedAddrsPerKey, scanWorkers(),
+		func(index uint32) (interface{}, uint64, error) {
+			foundTargets, err := queryIndex(index)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			funds := uint64(0)
+			for _, target := range foundTargets {
+				for _, vout := range target.vouts {
+					funds += vout.Value
+				}
+			}
+
+			return foundTargets, funds, nil
+		},
+		func(_ uint32, result interface{}) {
+			targets = append(targets, result.([]*targetAddr)...)
+		},
+	)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Infof("Transaction: %x", buf.Bytes())
-	return nil
+	return targets, nil
 }
 
 func queryAddressBalances(pubKey *btcec.PublicKey, path string,
-	keyDesc *keychain.KeyDescriptor, api *btc.ExplorerAPI) ([]*targetAddr,
+	keyDesc *keychain.KeyDescriptor, api btc.ChainBackend) ([]*targetAddr,
 	error) {
 
 	var targets []*targetAddr
-	queryAddr := func(address btcutil.Address, script []byte) error {
+	queryAddr := func(address btcutil.Address, script []byte,
+		scriptTree *input.CommitScriptTree) error {
+
 		unspent, err := api.Unspent(address.EncodeAddress())
 		if err != nil {
 			return fmt.Errorf("could not query unspent: %w", err)
@@ -331,6 +507,8 @@ func queryAddressBalances(pubKey *btcec.PublicKey, path string,
 				keyDesc: keyDesc,
 				vouts:   unspent,
 				script:  script,
+
+				scriptTree: scriptTree,
 			})
 		}
 
@@ -341,7 +519,7 @@ func queryAddressBalances(pubKey *btcec.PublicKey, path string,
 	if err != nil {
 		return nil, err
 	}
-	if err := queryAddr(p2wkh, nil); err != nil {
+	if err := queryAddr(p2wkh, nil, nil); err != nil {
 		return nil, err
 	}
 
@@ -349,7 +527,16 @@ func queryAddressBalances(pubKey *btcec.PublicKey, path string,
 	if err != nil {
 		return nil, err
 	}
-	if err := queryAddr(p2anchor, script); err != nil {
+	if err := queryAddr(p2anchor, script, nil); err != nil {
+		return nil, err
+	}
+
+	p2tr, scriptTree, err := lnd.P2TaprootStaticRemote(pubKey, chainParams)
+	if err != nil {
+		return nil, err
+	}
+	err = queryAddr(p2tr, scriptTree.SettleLeaf.Script, scriptTree)
+	if err != nil {
 		return nil, err
 	}
 
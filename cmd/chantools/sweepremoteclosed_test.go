@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSweepRemoteClosedTaproot(t *testing.T) {
+	h := newHarness(t)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+
+	// The remote peer force-closed a simple taproot channel and our
+	// to_remote output pays to the payment base key with index 3.
+	keyRing := &lnd.HDKeyRing{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+	keyDesc, err := keyRing.DeriveKey(keychain.KeyLocator{
+		Family: keychain.KeyFamilyPaymentBase,
+		Index:  3,
+	})
+	require.NoError(t, err)
+	addr, _, err := lnd.P2TaprootStaticRemote(keyDesc.PubKey, chainParams)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+	sweepAddr, err := lnd.P2WKHAddr(keyDesc.PubKey, chainParams)
+	require.NoError(t, err)
+
+	const value = 100_000
+	commitTxid := chainhash.Hash{1, 2, 3}
+	prevOut := &wire.TxOut{Value: value, PkScript: pkScript}
+	commitTx := &btc.TX{
+		TXID: commitTxid.String(),
+		Vout: []*btc.Vout{{
+			Value: anchorOutputValue,
+		}, {
+			ScriptPubkey:     hex.EncodeToString(pkScript),
+			ScriptPubkeyAddr: addr.EncodeAddress(),
+			Value:            value,
+		}},
+	}
+	chain := newFakeChainBackend()
+	chain.addAPITx(commitTx)
+
+	err = sweepRemoteClosed(
+		extendedKey, chain.serve(t), sweepAddr.EncodeAddress(), 5, 0, 0,
+		10, 0, false, true, false, "",
+	)
+	require.NoError(t, err)
+
+	// The to_remote output is swept with the script path after the CSV
+	// delay of one block, and the signature must be valid.
+	sweepTx := lastLoggedTx(t, h)
+	require.Len(t, sweepTx.TxIn, 1)
+	require.Equal(
+		t, wire.OutPoint{Hash: commitTxid, Index: 1},
+		sweepTx.TxIn[0].PreviousOutPoint,
+	)
+	require.EqualValues(t, 1, sweepTx.TxIn[0].Sequence)
+	require.Len(t, sweepTx.TxIn[0].Witness, 3)
+	assertSpendValid(t, sweepTx, prevOut)
+
+	// A sweep of a simple taproot output can be replaced with one that
+	// pays a higher fee as well.
+	h.clearLog()
+	err = bumpFee(extendedKey, chain, sweepTx, nil, 10, 50, false)
+	require.NoError(t, err)
+
+	replacementTx := lastLoggedTx(t, h)
+	require.Less(t, replacementTx.TxOut[0].Value, sweepTx.TxOut[0].Value)
+	assertSpendValid(t, replacementTx, prevOut)
+}
+
+// lastLoggedTx returns the transaction that was logged last.
+func lastLoggedTx(t *testing.T, h *harness) *wire.MsgTx {
+	matches := transactionRegex.FindAllStringSubmatch(h.getLog(), -1)
+	require.NotEmpty(t, matches)
+	txBytes, err := hex.DecodeString(matches[len(matches)-1][1])
+	require.NoError(t, err)
+
+	tx := &wire.MsgTx{}
+	require.NoError(t, tx.Deserialize(bytes.NewReader(txBytes)))
+
+	return tx
+}
+
+// assertSpendValid makes sure the first input of the given transaction
+// correctly spends the given output.
+func assertSpendValid(t *testing.T, tx *wire.MsgTx, prevOut *wire.TxOut) {
+	prevOutFetcher := txscript.NewCannedPrevOutputFetcher(
+		prevOut.PkScript, prevOut.Value,
+	)
+	vm, err := txscript.NewEngine(
+		prevOut.PkScript, tx, 0, txscript.StandardVerifyFlags, nil,
+		txscript.NewTxSigHashes(tx, prevOutFetcher), prevOut.Value,
+		prevOutFetcher,
+	)
+	require.NoError(t, err)
+	require.NoError(t, vm.Execute())
+}
@@ -4,13 +4,14 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
-	"github.com/guggero/chantools/btc"
 	"github.com/guggero/chantools/dataformat"
 	"github.com/guggero/chantools/lnd"
 	"github.com/lightningnetwork/lnd/input"
@@ -25,11 +26,19 @@ const (
 )
 
 type sweepTimeLockCommand struct {
-	APIURL      string
-	Publish     bool
-	SweepAddr   string
-	MaxCsvLimit uint16
-	FeeRate     uint16
+	APIURL         string
+	Publish        bool
+	DryRun         bool
+	SweepAddr      string
+	DestDescriptor string
+	DestIndex      uint32
+	DestGapLimit   uint32
+	MaxCsvLimit    uint16
+	FeeRate        uint16
+	ConfTarget     uint32
+	MinValue       uint64
+	ChannelPoints  []string
+	Psbt           bool
 
 	rootKey *rootKey
 	inputs  *inputFlags
@@ -48,12 +57,35 @@ that was created with the forceclose command, otherwise it won't work. You also
 have to wait until the highest time lock (can be up to 2016 blocks which is more
 than two weeks) of all the channels has passed. If you only want to sweep
 channels that have the default CSV limit of 1 day, you can set the --maxcsvlimit
-parameter to 144.`,
+parameter to 144.
+
+All channels of the input file are swept in a single transaction to the same
+sweep address, which saves a lot of fees when many channels were force-closed.
+Outputs whose time lock has not yet expired are skipped and reported, so the
+command can simply be run again later to sweep the remaining ones. Outputs that
+would cost more in fees to sweep than they are worth are skipped and reported as
+well, see the --min-value flag. To only
+sweep some of the channels, use the --channelpoints flag.
+
+Because the inputs are time locked with CSV, the sweep transaction always
+signals replace-by-fee and can be fee bumped with the bumpfee command.
+
+With the --psbt flag, an unsigned PSBT is created instead of a signed
+transaction. The PSBT contains the witness UTXOs, witness scripts and BIP32
+derivation paths of all inputs. Because the keys of time locked outputs are
+tweaked with the commitment point, the tweak is added in the proprietary field
+lnd uses for it (0x51), so the signer must support that field.`,
 		Example: `chantools sweeptimelock \
 	--fromsummary results/forceclose-xxxx-yyyy.json \
 	--sweepaddr bc1q..... \
 	--feerate 10 \
-  	--publish`,
+  	--publish
+
+chantools sweeptimelock \
+	--fromsummary results/forceclose-xxxx-yyyy.json \
+	--channelpoints aaaa...:0,bbbb...:1 \
+	--conftarget 6 \
+	--sweepaddr bc1q.....`,
 		RunE: cc.Execute,
 	}
 	cc.cmd.Flags().StringVar(
@@ -64,9 +96,13 @@ parameter to 144.`,
 		&cc.Publish, "publish", false, "publish sweep TX to the chain "+
 			"API instead of just printing the TX",
 	)
+	addDryRunFlag(cc.cmd, &cc.DryRun)
 	cc.cmd.Flags().StringVar(
 		&cc.SweepAddr, "sweepaddr", "", "address to sweep the funds to",
 	)
+	addDestDescriptorFlags(
+		cc.cmd, &cc.DestDescriptor, &cc.DestIndex, &cc.DestGapLimit,
+	)
 	cc.cmd.Flags().Uint16Var(
 		&cc.MaxCsvLimit, "maxcsvlimit", defaultCsvLimit, "maximum CSV "+
 			"limit to use",
@@ -75,6 +111,15 @@ parameter to 144.`,
 		&cc.FeeRate, "feerate", defaultFeeSatPerVByte, "fee rate to "+
 			"use for the sweep transaction in sat/vByte",
 	)
+	addConfTargetFlag(cc.cmd, &cc.ConfTarget)
+	addMinValueFlag(cc.cmd, &cc.MinValue)
+	addPsbtFlag(cc.cmd, &cc.Psbt)
+	cc.cmd.Flags().StringSliceVar(
+		&cc.ChannelPoints, "channelpoints", nil, "only sweep the "+
+			"channels with the given channel points (comma "+
+			"separated list of <txid>:<output_index>); if not "+
+			"set, all channels of the input are swept",
+	)
 
 	cc.rootKey = newRootKey(cc.cmd, "deriving keys")
 	cc.inputs = newInputFlags(cc.cmd)
@@ -88,10 +133,23 @@ func (c *sweepTimeLockCommand) Execute(_ *cobra.Command, _ []string) error {
 		return fmt.Errorf("error reading root key: %w", err)
 	}
 
+	// The sweep address can also be derived from a descriptor.
+	c.SweepAddr, err = sweepDestination(
+		c.SweepAddr, c.DestDescriptor, c.DestIndex, c.DestGapLimit,
+		c.APIURL,
+	)
+	if err != nil {
+		return err
+	}
+
 	// Make sure sweep addr is set.
 	if c.SweepAddr == "" {
 		return fmt.Errorf("sweep addr is required")
 	}
+	if c.Psbt && c.Publish {
+		return fmt.Errorf("cannot publish a PSBT, it must be signed " +
+			"first")
+	}
 
 	// Parse channel entries from any of the possible input files.
 	entries, err := c.inputs.parseInputType()
@@ -99,6 +157,14 @@ func (c *sweepTimeLockCommand) Execute(_ *cobra.Command, _ []string) error {
 		return err
 	}
 
+	// Only sweep the selected channels if requested.
+	if len(c.ChannelPoints) > 0 {
+		entries, err = filterChannelPoints(entries, c.ChannelPoints)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Set default values.
 	if c.MaxCsvLimit == 0 {
 		c.MaxCsvLimit = defaultCsvLimit
@@ -106,12 +172,103 @@ func (c *sweepTimeLockCommand) Execute(_ *cobra.Command, _ []string) error {
 	if c.FeeRate == 0 {
 		c.FeeRate = defaultFeeSatPerVByte
 	}
+	c.FeeRate = sweepFeeRate(c.APIURL, c.ConfTarget, c.FeeRate)
+
+	publish := shouldPublish(c.Publish, c.DryRun, c.APIURL)
 	return sweepTimeLockFromSummary(
 		extendedKey, c.APIURL, entries, c.SweepAddr, c.MaxCsvLimit,
-		c.Publish, c.FeeRate,
+		publish, c.FeeRate, c.MinValue, c.Psbt,
+	)
+}
+
+// addConfTargetFlag adds the --conftarget flag to the given sweep command.
+func addConfTargetFlag(cmd *cobra.Command, confTarget *uint32) {
+	cmd.Flags().Uint32Var(
+		confTarget, "conftarget", 0, "estimate the fee rate with the "+
+			"chain API for the sweep transaction to confirm "+
+			"within the given number of blocks; falls back to "+
+			"--feerate if the estimation fails",
 	)
 }
 
+// sweepFeeRate returns the fee rate in sat/vByte to use for a sweep
+// transaction. If a confirmation target is set, the fee rate is estimated by
+// the chain API. If no target is set or the estimation fails, the manually
+// specified fee rate is used instead.
+func sweepFeeRate(apiURL string, confTarget uint32, feeRate uint16) uint16 {
+	if confTarget == 0 {
+		log.Infof("Using fee rate of %d sat/vByte", feeRate)
+		return feeRate
+	}
+
+	api, err := newChainBackend(apiURL)
+	if err != nil {
+		log.Errorf("Could not estimate fee rate, falling back to fee "+
+			"rate of %d sat/vByte: %v", feeRate, err)
+		return feeRate
+	}
+	estimate, err := api.FeeEstimate(confTarget)
+	if err != nil {
+		log.Errorf("Could not estimate fee rate, falling back to fee "+
+			"rate of %d sat/vByte: %v", feeRate, err)
+		return feeRate
+	}
+
+	// We always round up to make sure we don't undershoot the target and
+	// never go below the minimum relay fee of 1 sat/vByte.
+	estimatedRate := math.Ceil(estimate)
+	switch {
+	case estimatedRate < 1:
+		estimatedRate = 1
+
+	case estimatedRate > math.MaxUint16:
+		estimatedRate = math.MaxUint16
+	}
+
+	log.Infof("Using estimated fee rate of %d sat/vByte to confirm within "+
+		"%d blocks", uint16(estimatedRate), confTarget)
+
+	return uint16(estimatedRate)
+}
+
+// filterChannelPoints returns only the entries that have one of the given
+// channel points. An error is returned if a channel point can't be found.
+func filterChannelPoints(entries []*dataformat.SummaryEntry,
+	channelPoints []string) ([]*dataformat.SummaryEntry, error) {
+
+	byChanPoint := make(map[string]*dataformat.SummaryEntry, len(entries))
+	for _, entry := range entries {
+		byChanPoint[entry.ChannelPoint] = entry
+	}
+
+	result := make([]*dataformat.SummaryEntry, 0, len(channelPoints))
+	for _, chanPoint := range channelPoints {
+		entry, ok := byChanPoint[strings.TrimSpace(chanPoint)]
+		if !ok {
+			return nil, fmt.Errorf("channel point %s not found in "+
+				"input", chanPoint)
+		}
+		result = append(result, entry)
+	}
+
+	return result, nil
+}
+
+// csvMatured returns true if an output with the given CSV delay that was
+// confirmed at the given height can be spent in the next block after the
+// current best height. If it can't be spent yet, the number of blocks to wait
+// is returned as well.
+func csvMatured(confHeight, bestHeight uint32, csvTimeout int32) (bool,
+	uint32) {
+
+	spendHeight := confHeight + uint32(csvTimeout)
+	if bestHeight+1 >= spendHeight {
+		return true, 0
+	}
+
+	return false, spendHeight - (bestHeight + 1)
+}
+
 type sweepTarget struct {
 	channelPoint        string
 	txid                chainhash.Hash
@@ -125,7 +282,24 @@ type sweepTarget struct {
 
 func sweepTimeLockFromSummary(extendedKey *hdkeychain.ExtendedKey, apiURL string,
 	entries []*dataformat.SummaryEntry, sweepAddr string,
-	maxCsvTimeout uint16, publish bool, feeRate uint16) error {
+	maxCsvTimeout uint16, publish bool, feeRate uint16, minValue uint64,
+	createPsbt bool) error {
+
+	targets, err := sweepTargetsFromSummary(entries)
+	if err != nil {
+		return err
+	}
+
+	return sweepTimeLock(
+		extendedKey, apiURL, targets, sweepAddr, maxCsvTimeout, publish,
+		feeRate, minValue, createPsbt,
+	)
+}
+
+// sweepTargetsFromSummary returns the time locked commitment outputs of all
+// force-closed channels of the given summary entries.
+func sweepTargetsFromSummary(
+	entries []*dataformat.SummaryEntry) ([]*sweepTarget, error) {
 
 	targets := make([]*sweepTarget, 0, len(entries))
 	for _, entry := range entries {
@@ -168,29 +342,31 @@ func sweepTimeLockFromSummary(extendedKey *hdkeychain.ExtendedKey, apiURL string
 		// Prepare sweep script parameters.
 		commitPoint, err := pubKeyFromHex(fc.CommitPoint)
 		if err != nil {
-			return fmt.Errorf("error parsing commit point: %w", err)
+			return nil, fmt.Errorf("error parsing commit point: "+
+				"%w", err)
 		}
 		revBase, err := pubKeyFromHex(fc.RevocationBasePoint.PubKey)
 		if err != nil {
-			return fmt.Errorf("error parsing revocation base "+
+			return nil, fmt.Errorf("error parsing revocation base "+
 				"point: %w", err)
 		}
 		delayDesc, err := fc.DelayBasePoint.Desc()
 		if err != nil {
-			return fmt.Errorf("error parsing delay base point: %w",
-				err)
+			return nil, fmt.Errorf("error parsing delay base "+
+				"point: %w", err)
 		}
 
 		lockScript, err := hex.DecodeString(fc.Outs[txindex].Script)
 		if err != nil {
-			return fmt.Errorf("error parsing target script: %w",
-				err)
+			return nil, fmt.Errorf("error parsing target "+
+				"script: %w", err)
 		}
 
 		// Create the transaction input.
 		txHash, err := chainhash.NewHashFromStr(fc.TXID)
 		if err != nil {
-			return fmt.Errorf("error parsing tx hash: %w", err)
+			return nil, fmt.Errorf("error parsing tx hash: %w",
+				err)
 		}
 
 		targets = append(targets, &sweepTarget{
@@ -205,39 +381,60 @@ func sweepTimeLockFromSummary(extendedKey *hdkeychain.ExtendedKey, apiURL string
 		})
 	}
 
-	return sweepTimeLock(
-		extendedKey, apiURL, targets, sweepAddr, maxCsvTimeout, publish,
-		feeRate,
-	)
+	return targets, nil
 }
 
 func sweepTimeLock(extendedKey *hdkeychain.ExtendedKey, apiURL string,
 	targets []*sweepTarget, sweepAddr string, maxCsvTimeout uint16,
-	publish bool, feeRate uint16) error {
+	publish bool, feeRate uint16, minValue uint64, createPsbt bool) error {
 
 	// Create signer and transaction template.
 	signer := &lnd.Signer{
 		ExtendedKey: extendedKey,
 		ChainParams: chainParams,
 	}
-	api := &btc.ExplorerAPI{BaseURL: apiURL}
+	api, err := newChainBackend(apiURL)
+	if err != nil {
+		return err
+	}
+
+	bestHeight, err := api.BlockHeight()
+	if err != nil {
+		return fmt.Errorf("error querying best block height: %w", err)
+	}
 
 	sweepTx := wire.NewMsgTx(2)
 	totalOutputValue := int64(0)
 	signDescs := make([]*input.SignDescriptor, 0)
-	var estimator input.TxWeightEstimator
+	var (
+		estimator input.TxWeightEstimator
+		immature  []string
+		dust      = newMinValueFilter(minValue, feeRate)
+	)
 
 	for _, target := range targets {
+		witnessSize := input.ToLocalTimeoutWitnessSize
+		if txscript.IsPayToTaproot(target.lockScript) {
+			witnessSize = input.TaprootToLocalWitnessSize
+		}
+		if !dust.keep(
+			target.channelPoint, uint64(target.value), witnessSize,
+		) {
+
+			continue
+		}
+
 		// We can't rely on the CSV delay of the channel DB to be
 		// correct. But it doesn't cost us a lot to just brute force it.
+		delayPubKey := input.TweakPubKey(
+			target.delayBasePointDesc.PubKey, target.commitPoint,
+		)
+		revocationPubKey := input.DeriveRevocationPubkey(
+			target.revocationBasePoint, target.commitPoint,
+		)
 		csvTimeout, script, scriptHash, err := bruteForceDelay(
-			input.TweakPubKey(
-				target.delayBasePointDesc.PubKey,
-				target.commitPoint,
-			), input.DeriveRevocationPubkey(
-				target.revocationBasePoint,
-				target.commitPoint,
-			), target.lockScript, maxCsvTimeout,
+			delayPubKey, revocationPubKey, target.lockScript,
+			maxCsvTimeout,
 		)
 		if err != nil {
 			log.Errorf("Could not create matching script for %s "+
@@ -245,6 +442,29 @@ func sweepTimeLock(extendedKey *hdkeychain.ExtendedKey, apiURL string,
 			continue
 		}
 
+		// Only sweep the output if its time lock has already expired.
+		status, err := api.TxStatus(target.txid.String())
+		if err != nil {
+			return fmt.Errorf("error querying status of TX %v: %w",
+				target.txid, err)
+		}
+		if !status.Confirmed {
+			log.Infof("Not sweeping %s, commitment TX %v not yet "+
+				"confirmed", target.channelPoint, target.txid)
+			immature = append(immature, target.channelPoint)
+			continue
+		}
+		matured, blocksLeft := csvMatured(
+			uint32(status.BlockHeight), bestHeight, csvTimeout,
+		)
+		if !matured {
+			log.Infof("Not sweeping %s, time lock of %d blocks "+
+				"expires in %d block(s)", target.channelPoint,
+				csvTimeout, blocksLeft)
+			immature = append(immature, target.channelPoint)
+			continue
+		}
+
 		// Create the transaction input.
 		sweepTx.TxIn = append(sweepTx.TxIn, &wire.TxIn{
 			PreviousOutPoint: wire.OutPoint{
@@ -270,13 +490,35 @@ func sweepTimeLock(extendedKey *hdkeychain.ExtendedKey, apiURL string,
 			},
 			HashType: txscript.SigHashAll,
 		}
+		if txscript.IsPayToTaproot(scriptHash) {
+			err := setTaprootToLocalSpend(
+				signDesc, csvTimeout, delayPubKey,
+				revocationPubKey,
+			)
+			if err != nil {
+				return err
+			}
+		}
 		totalOutputValue += target.value
 		signDescs = append(signDescs, signDesc)
 
 		// Account for the input weight.
-		estimator.AddWitnessInput(input.ToLocalTimeoutWitnessSize)
+		estimator.AddWitnessInput(witnessSize)
+	}
+
+	dust.logSkipped()
+	if len(immature) > 0 {
+		log.Infof("Skipped %d channel(s) with immature time lock, run "+
+			"the command again later to sweep them: %s",
+			len(immature), strings.Join(immature, ", "))
+	}
+	if len(signDescs) == 0 {
+		return fmt.Errorf("no mature outputs found to sweep")
 	}
 
+	log.Infof("Sweeping %d output(s) in a single transaction",
+		len(signDescs))
+
 	// Add our sweep destination output.
 	sweepScript, err := lnd.GetP2WPKHScript(sweepAddr, chainParams)
 	if err != nil {
@@ -297,38 +539,38 @@ func sweepTimeLock(extendedKey *hdkeychain.ExtendedKey, apiURL string,
 		PkScript: sweepScript,
 	}}
 
-	// Sign the transaction now.
-	sigHashes := input.NewTxSigHashesV0Only(sweepTx)
-	for idx, desc := range signDescs {
-		desc.SigHashes = sigHashes
-		desc.InputIndex = idx
-		witness, err := input.CommitSpendTimeout(signer, desc, sweepTx)
+	err = validateSweepTx(
+		api, sweepTx, signDescs, int64(estimator.VSize()), feeRate,
+	)
+	if err != nil {
+		return fmt.Errorf("error validating sweep TX: %w", err)
+	}
+
+	// Instead of signing the transaction ourselves, we create a PSBT that
+	// can be signed on another machine.
+	if createPsbt {
+		packet, err := newSweepPsbt(extendedKey, sweepTx, signDescs)
 		if err != nil {
 			return err
 		}
-		sweepTx.TxIn[idx].Witness = witness
+		return logPsbt(packet)
 	}
 
-	var buf bytes.Buffer
-	err = sweepTx.Serialize(&buf)
-	if err != nil {
-		return err
-	}
-
-	// Publish TX.
-	if publish {
-		response, err := api.PublishTx(
-			hex.EncodeToString(buf.Bytes()),
-		)
+	// Sign the transaction now.
+	prevOuts := lnd.PrevOutputFetcher(sweepTx, signDescs)
+	sigHashes := txscript.NewTxSigHashes(sweepTx, prevOuts)
+	for idx, desc := range signDescs {
+		desc.SigHashes = sigHashes
+		desc.PrevOutputFetcher = prevOuts
+		desc.InputIndex = idx
+		witness, err := commitSpendTimeout(signer, desc, sweepTx)
 		if err != nil {
 			return err
 		}
-		log.Infof("Published TX %s, response: %s",
-			sweepTx.TxHash().String(), response)
+		sweepTx.TxIn[idx].Witness = witness
 	}
 
-	log.Infof("Transaction: %x", buf.Bytes())
-	return nil
+	return publishTx(api, sweepTx, int64(totalFee), publish)
 }
 
 func pubKeyFromHex(pubKeyHex string) (*btcec.PublicKey, error) {
@@ -339,6 +581,10 @@ func pubKeyFromHex(pubKeyHex string) (*btcec.PublicKey, error) {
 	return btcec.ParsePubKey(pointBytes)
 }
 
+// bruteForceDelay tries all CSV delays up to the given maximum to find the
+// to_local script that results in the target output script. For a P2TR target,
+// the script is the delay leaf of the to_local script tree of a simple taproot
+// channel. The delay, the script and the output script are returned.
 func bruteForceDelay(delayPubkey, revocationPubkey *btcec.PublicKey,
 	targetScript []byte, maxCsvTimeout uint16) (int32, []byte, []byte,
 	error) {
@@ -348,6 +594,20 @@ func bruteForceDelay(delayPubkey, revocationPubkey *btcec.PublicKey,
 			targetScript)
 	}
 	for i := uint16(0); i <= maxCsvTimeout; i++ {
+		if txscript.IsPayToTaproot(targetScript) {
+			script, pkScript, err := taprootToLocalScript(
+				uint32(i), delayPubkey, revocationPubkey,
+			)
+			if err != nil {
+				return 0, nil, nil, err
+			}
+			if bytes.Equal(targetScript, pkScript) {
+				return int32(i), script, pkScript, nil
+			}
+
+			continue
+		}
+
 		s, err := input.CommitScriptToSelf(
 			uint32(i), delayPubkey, revocationPubkey,
 		)
@@ -367,3 +627,53 @@ func bruteForceDelay(delayPubkey, revocationPubkey *btcec.PublicKey,
 	return 0, nil, nil, fmt.Errorf("csv timeout not found for target "+
 		"script %s", targetScript)
 }
+
+// taprootToLocalScript returns the delay leaf script and the output script of
+// the to_local output of a simple taproot channel.
+func taprootToLocalScript(csvTimeout uint32, delayPubkey,
+	revocationPubkey *btcec.PublicKey) ([]byte, []byte, error) {
+
+	scriptTree, err := input.NewLocalCommitScriptTree(
+		csvTimeout, delayPubkey, revocationPubkey,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating script tree: %w",
+			err)
+	}
+	pkScript, err := input.PayToTaprootScript(scriptTree.TaprootKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return scriptTree.SettleLeaf.Script, pkScript, nil
+}
+
+// setTaprootToLocalSpend turns the sign descriptor of a to_local output with
+// the given CSV delay into one for a script spend of the delay leaf of a simple
+// taproot channel.
+func setTaprootToLocalSpend(signDesc *input.SignDescriptor, csvTimeout int32,
+	delayPubkey, revocationPubkey *btcec.PublicKey) error {
+
+	scriptTree, err := input.NewLocalCommitScriptTree(
+		uint32(csvTimeout), delayPubkey, revocationPubkey,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating script tree: %w", err)
+	}
+
+	return lnd.SetTaprootScriptSpend(signDesc, &scriptTree.ScriptTree)
+}
+
+// commitSpendTimeout creates the witness for a to_local output after its CSV
+// delay has passed, for a simple taproot channel or an older channel type.
+func commitSpendTimeout(signer input.Signer, signDesc *input.SignDescriptor,
+	sweepTx *wire.MsgTx) (wire.TxWitness, error) {
+
+	if signDesc.SignMethod == input.TaprootScriptSpendSignMethod {
+		return input.TaprootCommitSpendSuccess(
+			signer, signDesc, sweepTx, nil,
+		)
+	}
+
+	return input.CommitSpendTimeout(signer, signDesc, sweepTx)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/dataformat"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCsvMatured(t *testing.T) {
+	testCases := []struct {
+		name       string
+		confHeight uint32
+		bestHeight uint32
+		csvTimeout int32
+		matured    bool
+		blocksLeft uint32
+	}{{
+		name:       "just confirmed",
+		confHeight: 1000,
+		bestHeight: 1000,
+		csvTimeout: 144,
+		blocksLeft: 143,
+	}, {
+		name:       "one block left",
+		confHeight: 1000,
+		bestHeight: 1142,
+		csvTimeout: 144,
+		blocksLeft: 1,
+	}, {
+		name:       "spendable in next block",
+		confHeight: 1000,
+		bestHeight: 1143,
+		csvTimeout: 144,
+		matured:    true,
+	}, {
+		name:       "long expired",
+		confHeight: 1000,
+		bestHeight: 5000,
+		csvTimeout: 2016,
+		matured:    true,
+	}}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			matured, blocksLeft := csvMatured(
+				tc.confHeight, tc.bestHeight, tc.csvTimeout,
+			)
+			require.Equal(t, tc.matured, matured)
+			require.Equal(t, tc.blocksLeft, blocksLeft)
+		})
+	}
+}
+
+func TestFilterChannelPoints(t *testing.T) {
+	entries := []*dataformat.SummaryEntry{
+		{ChannelPoint: "aaaa:0"},
+		{ChannelPoint: "bbbb:1"},
+		{ChannelPoint: "cccc:2"},
+	}
+
+	filtered, err := filterChannelPoints(
+		entries, []string{"cccc:2", " aaaa:0"},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []*dataformat.SummaryEntry{
+		entries[2], entries[0],
+	}, filtered)
+
+	_, err = filterChannelPoints(entries, []string{"dddd:0"})
+	require.ErrorContains(t, err, "channel point dddd:0 not found")
+}
+
+func TestSweepFeeRate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/fee-estimates", r.URL.Path)
+			_, _ = fmt.Fprint(w, `{"1": 87.8, "2": 60.1, "6": 22.4, `+
+				`"144": 1.2, "1008": 0.4}`)
+		},
+	))
+	defer server.Close()
+
+	// Without a target, the manual fee rate is used.
+	require.EqualValues(t, 10, sweepFeeRate(server.URL, 0, 10))
+
+	// The estimates are rounded up.
+	require.EqualValues(t, 88, sweepFeeRate(server.URL, 1, 10))
+	require.EqualValues(t, 23, sweepFeeRate(server.URL, 6, 10))
+
+	// Targets without an exact estimate use the next lower one.
+	require.EqualValues(t, 23, sweepFeeRate(server.URL, 12, 10))
+	require.EqualValues(t, 2, sweepFeeRate(server.URL, 500, 10))
+
+	// We never go below 1 sat/vByte.
+	require.EqualValues(t, 1, sweepFeeRate(server.URL, 2000, 10))
+
+	// If the estimation fails, we fall back to the manual fee rate.
+	require.EqualValues(t, 10, sweepFeeRate("http://127.0.0.1:1", 6, 10))
+}
+
+func TestSweepTimeLockTaproot(t *testing.T) {
+	h := newHarness(t)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	keyRing := &lnd.HDKeyRing{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+	delayDesc, err := keyRing.DeriveKey(keychain.KeyLocator{
+		Family: keychain.KeyFamilyDelayBase,
+		Index:  2,
+	})
+	require.NoError(t, err)
+	sweepAddr, err := lnd.P2WKHAddr(delayDesc.PubKey, chainParams)
+	require.NoError(t, err)
+
+	// Our to_local output of a simple taproot channel is a script tree
+	// with the delay and the revocation leaf.
+	_, commitPoint := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{1}, 32))
+	_, revBase := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{2}, 32))
+	scriptTree, err := input.NewLocalCommitScriptTree(
+		144, input.TweakPubKey(delayDesc.PubKey, commitPoint),
+		input.DeriveRevocationPubkey(revBase, commitPoint),
+	)
+	require.NoError(t, err)
+	pkScript, err := input.PayToTaprootScript(scriptTree.TaprootKey)
+	require.NoError(t, err)
+	prevOut := &wire.TxOut{Value: 100_000, PkScript: pkScript}
+
+	commitTxid := chainhash.Hash{4, 5, 6}
+	chain := newFakeChainBackend()
+	chain.addAPITx(&btc.TX{
+		TXID: commitTxid.String(),
+		Vout: []*btc.Vout{{
+			ScriptPubkey: hex.EncodeToString(pkScript),
+			Value:        uint64(prevOut.Value),
+		}},
+		Status: &btc.Status{Confirmed: true, BlockHeight: 500},
+	})
+	chain.setHeight(1000)
+
+	err = sweepTimeLock(extendedKey, chain.serve(t), []*sweepTarget{{
+		channelPoint:        commitTxid.String() + ":0",
+		txid:                commitTxid,
+		lockScript:          pkScript,
+		value:               prevOut.Value,
+		commitPoint:         commitPoint,
+		revocationBasePoint: revBase,
+		delayBasePointDesc:  &delayDesc,
+	}}, sweepAddr.EncodeAddress(), 200, false, 10, 0, false)
+	require.NoError(t, err)
+
+	// The delay leaf is spent after the brute forced CSV delay.
+	sweepTx := lastLoggedTx(t, h)
+	require.Len(t, sweepTx.TxIn, 1)
+	require.EqualValues(t, 144, sweepTx.TxIn[0].Sequence)
+	require.Equal(
+		t, scriptTree.SettleLeaf.Script, sweepTx.TxIn[0].Witness[1],
+	)
+	assertSpendValid(t, sweepTx, prevOut)
+}
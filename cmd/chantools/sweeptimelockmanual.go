@@ -1,16 +1,14 @@
 package main
 
 import (
-	"bytes"
-	"encoding/hex"
 	"fmt"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
-	"github.com/guggero/chantools/btc"
 	"github.com/guggero/chantools/lnd"
 	"github.com/lightningnetwork/lnd/input"
 	"github.com/lightningnetwork/lnd/keychain"
@@ -28,9 +26,15 @@ const (
 type sweepTimeLockManualCommand struct {
 	APIURL                    string
 	Publish                   bool
+	DryRun                    bool
 	SweepAddr                 string
+	DestDescriptor            string
+	DestIndex                 uint32
+	DestGapLimit              uint32
 	MaxCsvLimit               uint16
 	FeeRate                   uint16
+	ConfTarget                uint32
+	Psbt                      bool
 	TimeLockAddr              string
 	RemoteRevocationBasePoint string
 
@@ -58,7 +62,11 @@ then look up the value for RemoteChanCfg -> RevocationBasePoint -> PubKey.
 
 To get the value for --timelockaddr you must look up the channel's funding
 output on chain, then follow it to the force close output. The time locked
-address is always the one that's longer (because it's P2WSH and not P2PKH).`,
+address is always the one that's longer (because it's P2WSH and not P2PKH). For
+simple taproot channels, both outputs are P2TR addresses and both can be tried.
+
+With the --psbt flag, an unsigned PSBT is created instead of a signed
+transaction. See the sweeptimelock command for the details.`,
 		Example: `chantools sweeptimelockmanual \
 	--sweepaddr bc1q..... \
 	--timelockaddr bc1q............ \
@@ -75,9 +83,13 @@ address is always the one that's longer (because it's P2WSH and not P2PKH).`,
 		&cc.Publish, "publish", false, "publish sweep TX to the chain "+
 			"API instead of just printing the TX",
 	)
+	addDryRunFlag(cc.cmd, &cc.DryRun)
 	cc.cmd.Flags().StringVar(
 		&cc.SweepAddr, "sweepaddr", "", "address to sweep the funds to",
 	)
+	addDestDescriptorFlags(
+		cc.cmd, &cc.DestDescriptor, &cc.DestIndex, &cc.DestGapLimit,
+	)
 	cc.cmd.Flags().Uint16Var(
 		&cc.MaxCsvLimit, "maxcsvlimit", defaultCsvLimit, "maximum CSV "+
 			"limit to use",
@@ -96,6 +108,8 @@ address is always the one that's longer (because it's P2WSH and not P2PKH).`,
 		&cc.FeeRate, "feerate", defaultFeeSatPerVByte, "fee rate to "+
 			"use for the sweep transaction in sat/vByte",
 	)
+	addConfTargetFlag(cc.cmd, &cc.ConfTarget)
+	addPsbtFlag(cc.cmd, &cc.Psbt)
 	cc.cmd.Flags().StringVar(
 		&cc.TimeLockAddr, "timelockaddr", "", "address of the time "+
 			"locked commitment output where the funds are stuck in",
@@ -118,6 +132,15 @@ func (c *sweepTimeLockManualCommand) Execute(_ *cobra.Command, _ []string) error
 		return fmt.Errorf("error reading root key: %w", err)
 	}
 
+	// The sweep address can also be derived from a descriptor.
+	c.SweepAddr, err = sweepDestination(
+		c.SweepAddr, c.DestDescriptor, c.DestIndex, c.DestGapLimit,
+		c.APIURL,
+	)
+	if err != nil {
+		return err
+	}
+
 	// Make sure the sweep and time lock addrs are set.
 	if c.SweepAddr == "" {
 		return fmt.Errorf("sweep addr is required")
@@ -125,6 +148,10 @@ func (c *sweepTimeLockManualCommand) Execute(_ *cobra.Command, _ []string) error
 	if c.TimeLockAddr == "" {
 		return fmt.Errorf("time lock addr is required")
 	}
+	if c.Psbt && c.Publish {
+		return fmt.Errorf("cannot publish a PSBT, it must be signed " +
+			"first")
+	}
 
 	// The remote revocation base point must also be set and a valid EC
 	// point.
@@ -134,22 +161,29 @@ func (c *sweepTimeLockManualCommand) Execute(_ *cobra.Command, _ []string) error
 			err)
 	}
 
+	// Set default values.
+	if c.FeeRate == 0 {
+		c.FeeRate = defaultFeeSatPerVByte
+	}
+	c.FeeRate = sweepFeeRate(c.APIURL, c.ConfTarget, c.FeeRate)
+
+	publish := shouldPublish(c.Publish, c.DryRun, c.APIURL)
 	return sweepTimeLockManual(
 		extendedKey, c.APIURL, c.SweepAddr, c.TimeLockAddr,
 		remoteRevPoint, c.MaxCsvLimit, c.MaxNumChansTotal,
-		c.MaxNumChanUpdates, c.Publish, c.FeeRate,
+		c.MaxNumChanUpdates, publish, c.FeeRate, c.Psbt,
 	)
 }
 
 func sweepTimeLockManual(extendedKey *hdkeychain.ExtendedKey, apiURL string,
 	sweepAddr, timeLockAddr string, remoteRevPoint *btcec.PublicKey,
 	maxCsvTimeout, maxNumChannels uint16, maxNumChanUpdates uint64,
-	publish bool, feeRate uint16) error {
+	publish bool, feeRate uint16, createPsbt bool) error {
 
 	// First of all, we need to parse the lock addr and make sure we can
 	// brute force the script with the information we have. If not, we can't
 	// continue anyway.
-	lockScript, err := lnd.GetP2WSHScript(timeLockAddr, chainParams)
+	lockScript, err := timeLockScript(timeLockAddr)
 	if err != nil {
 		return fmt.Errorf("invalid time lock addr: %w", err)
 	}
@@ -206,7 +240,10 @@ func sweepTimeLockManual(extendedKey *hdkeychain.ExtendedKey, apiURL string,
 		ExtendedKey: extendedKey,
 		ChainParams: chainParams,
 	}
-	api := &btc.ExplorerAPI{BaseURL: apiURL}
+	api, err := newChainBackend(apiURL)
+	if err != nil {
+		return err
+	}
 
 	// We now know everything we need to construct the sweep transaction,
 	// except for what outpoint to sweep. We'll ask the chain API to give
@@ -237,8 +274,12 @@ func sweepTimeLockManual(extendedKey *hdkeychain.ExtendedKey, apiURL string,
 
 	// Calculate the fee based on the given fee rate and our weight
 	// estimation.
+	witnessSize := input.ToLocalTimeoutWitnessSize
+	if txscript.IsPayToTaproot(lockScript) {
+		witnessSize = input.TaprootToLocalWitnessSize
+	}
 	var estimator input.TxWeightEstimator
-	estimator.AddWitnessInput(input.ToLocalTimeoutWitnessSize)
+	estimator.AddWitnessInput(witnessSize)
 	estimator.AddP2WKHOutput()
 	feeRateKWeight := chainfee.SatPerKVByte(1000 * feeRate).FeePerKWeight()
 	totalFee := feeRateKWeight.FeeForWeight(int64(estimator.Weight()))
@@ -257,7 +298,6 @@ func sweepTimeLockManual(extendedKey *hdkeychain.ExtendedKey, apiURL string,
 		totalFee, sweepValue, estimator.Weight())
 
 	// Create the sign descriptor for the input then sign the transaction.
-	sigHashes := input.NewTxSigHashesV0Only(sweepTx)
 	signDesc := &input.SignDescriptor{
 		KeyDesc: *delayDesc,
 		SingleTweak: input.SingleTweakBytes(
@@ -269,35 +309,63 @@ func sweepTimeLockManual(extendedKey *hdkeychain.ExtendedKey, apiURL string,
 			Value:    sweepValue,
 		},
 		InputIndex: 0,
-		SigHashes:  sigHashes,
 		HashType:   txscript.SigHashAll,
 	}
-	witness, err := input.CommitSpendTimeout(signer, signDesc, sweepTx)
-	if err != nil {
-		return err
+	if txscript.IsPayToTaproot(scriptHash) {
+		err := setTaprootToLocalSpend(
+			signDesc, csvTimeout,
+			input.TweakPubKey(delayDesc.PubKey, commitPoint),
+			input.DeriveRevocationPubkey(
+				remoteRevPoint, commitPoint,
+			),
+		)
+		if err != nil {
+			return err
+		}
 	}
-	sweepTx.TxIn[0].Witness = witness
-
-	var buf bytes.Buffer
-	err = sweepTx.Serialize(&buf)
+	signDescs := []*input.SignDescriptor{signDesc}
+	signDesc.PrevOutputFetcher = lnd.PrevOutputFetcher(sweepTx, signDescs)
+	signDesc.SigHashes = txscript.NewTxSigHashes(
+		sweepTx, signDesc.PrevOutputFetcher,
+	)
+	err = validateSweepTx(
+		api, sweepTx, signDescs, int64(estimator.VSize()), feeRate,
+	)
 	if err != nil {
-		return err
+		return fmt.Errorf("error validating sweep TX: %w", err)
 	}
 
-	// Publish TX.
-	if publish {
-		response, err := api.PublishTx(
-			hex.EncodeToString(buf.Bytes()),
-		)
+	// Instead of signing the transaction ourselves, we create a PSBT that
+	// can be signed on another machine.
+	if createPsbt {
+		packet, err := newSweepPsbt(extendedKey, sweepTx, signDescs)
 		if err != nil {
 			return err
 		}
-		log.Infof("Published TX %s, response: %s",
-			sweepTx.TxHash().String(), response)
+		return logPsbt(packet)
+	}
+
+	witness, err := commitSpendTimeout(signer, signDesc, sweepTx)
+	if err != nil {
+		return err
+	}
+	sweepTx.TxIn[0].Witness = witness
+
+	return publishTx(api, sweepTx, int64(totalFee), publish)
+}
+
+// timeLockScript returns the output script of the given time lock address. That
+// is a P2WSH address or, for a simple taproot channel, a P2TR address.
+func timeLockScript(timeLockAddr string) ([]byte, error) {
+	addr, err := btcutil.DecodeAddress(timeLockAddr, chainParams)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := addr.(*btcutil.AddressTaproot); ok {
+		return lnd.GetWitnessAddrScript(addr, chainParams)
 	}
 
-	log.Infof("Transaction: %x", buf.Bytes())
-	return nil
+	return lnd.GetP2WSHScript(timeLockAddr, chainParams)
 }
 
 func tryKey(baseKey *hdkeychain.ExtendedKey, remoteRevPoint *btcec.PublicKey,
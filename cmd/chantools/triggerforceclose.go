@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// defaultPeerPort is the port we connect to if the peer address
+	// doesn't specify one.
+	defaultPeerPort = "9735"
+
+	// peerTimeout is the time we wait for the connection to be established
+	// and for each message of the peer.
+	peerTimeout = time.Minute
+
+	// errorReplyTimeout is the time we wait for the peer to react to our
+	// error message before disconnecting.
+	errorReplyTimeout = 10 * time.Second
+)
+
+type triggerForceCloseCommand struct {
+	Peer         string
+	ChannelPoint string
+	Socks        string
+
+	rootKey *rootKey
+	cmd     *cobra.Command
+}
+
+func newTriggerForceCloseCommand() *cobra.Command {
+	cc := &triggerForceCloseCommand{}
+	cc.cmd = &cobra.Command{
+		Use: "triggerforceclose",
+		Short: "Connect to a peer and send an error message to " +
+			"trigger a force close of the specified channel",
+		Long: `Connects to the given peer using our node's identity key
+(derived from the root key) and sends an error message for the given channel.
+According to the Lightning Network protocol, a node receiving an error for a
+channel must fail that channel, which usually means the peer force closes it by
+publishing its latest commitment transaction. The funds of our side can then be
+swept with the rescueclosed or sweepremoteclosed commands.
+
+This command does not need a running lnd node. It only does the handshake and
+the init message exchange, sends the error message, waits a few seconds for the
+reply of the peer and then disconnects.
+
+Whether the peer actually force closes the channel depends on its
+implementation and version. Some versions of lnd for example only disconnect
+and don't close the channel when they receive an error from their peer.
+
+The peer's host can be an IPv4 address, an IPv6 address in brackets (for
+example [2001:db8::1]:9735), a host name or a v3 onion address. Onion addresses
+are reached through the Tor SOCKS5 proxy given with --socks, or a local Tor
+daemon on ` + lnd.DefaultSocksProxy + ` if it isn't set. If --socks is set, all
+connections and DNS lookups go through the proxy.`,
+		Example: `chantools triggerforceclose \
+	--peer 03abce...@xx.yy.zz.aa:9735 \
+	--channel_point abcdef01234...:x
+
+chantools triggerforceclose \
+	--peer 03abce...@xxxxxxxx.onion:9735 \
+	--socks 127.0.0.1:9050 \
+	--channel_point abcdef01234...:x`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.Peer, "peer", "", "remote peer address in the format "+
+			"pubkey@host[:port]",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.ChannelPoint, "channel_point", "", "funding transaction "+
+			"outpoint of the channel to trigger the force close "+
+			"of (<txid>:<txindex>)",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.Socks, "socks", "", "host:port of a Tor SOCKS5 proxy to "+
+			"connect to the peer through; onion addresses use "+
+			lnd.DefaultSocksProxy+" if not set",
+	)
+
+	cc.rootKey = newRootKey(cc.cmd, "deriving the identity key")
+
+	return cc.cmd
+}
+
+func (c *triggerForceCloseCommand) Execute(_ *cobra.Command, _ []string) error {
+	extendedKey, err := c.rootKey.read()
+	if err != nil {
+		return fmt.Errorf("error reading root key: %w", err)
+	}
+
+	identityPath, err := lnd.ParsePath(lnd.IdentityPath(chainParams))
+	if err != nil {
+		return fmt.Errorf("could not parse identity path: %w", err)
+	}
+	identityPriv, err := lnd.PrivKeyFromPath(extendedKey, identityPath)
+	if err != nil {
+		return fmt.Errorf("could not derive identity key: %w", err)
+	}
+	identityECDH := &keychain.PrivKeyECDH{
+		PrivKey: identityPriv,
+	}
+
+	peerAddr, err := lnd.ParsePeerAddress(
+		c.Peer, defaultPeerPort, c.Socks,
+	)
+	if err != nil {
+		return fmt.Errorf("error parsing peer address: %w", err)
+	}
+
+	socksAddr := c.Socks
+	if socksAddr == "" && lnd.IsOnionPeer(peerAddr) {
+		socksAddr = lnd.DefaultSocksProxy
+	}
+
+	chanOp, err := lnd.ParseOutpoint(c.ChannelPoint)
+	if err != nil {
+		return fmt.Errorf("error parsing channel point: %w", err)
+	}
+
+	return triggerForceClose(identityECDH, peerAddr, socksAddr, chanOp)
+}
+
+func triggerForceClose(identityECDH keychain.SingleKeyECDH,
+	peerAddr *lnwire.NetAddress, socksAddr string,
+	chanOp *wire.OutPoint) error {
+
+	log.Infof("Connecting to peer %x@%v as node %x, timeout is %v",
+		peerAddr.IdentityKey.SerializeCompressed(), peerAddr.Address,
+		identityECDH.PubKey().SerializeCompressed(), peerTimeout)
+	if socksAddr != "" {
+		log.Infof("Connecting through SOCKS5 proxy %s", socksAddr)
+	}
+
+	peer, err := lnd.ConnectPeer(
+		identityECDH, peerAddr, socksAddr, peerTimeout,
+	)
+	if err != nil {
+		return fmt.Errorf("error connecting to peer: %w", err)
+	}
+	defer func() { _ = peer.Close() }()
+
+	log.Infof("Connection established to peer %x",
+		peer.RemotePub().SerializeCompressed())
+
+	chanID := lnwire.NewChanIDFromOutPoint(chanOp)
+	log.Infof("Sending error message to peer to trigger force close of "+
+		"channel %v (channel ID %v)", chanOp, chanID)
+
+	err = peer.SendMessage(&lnwire.Error{
+		ChanID: chanID,
+		Data: []byte("channel state lost, please force close the " +
+			"channel"),
+	}, peerTimeout)
+	if err != nil {
+		return fmt.Errorf("error sending error message: %w", err)
+	}
+
+	// Give the peer some time to process the message before we close the
+	// connection. Some peers tell us about the result with an error
+	// message of their own.
+	deadline := time.Now().Add(errorReplyTimeout)
+	for time.Now().Before(deadline) {
+		msg, err := peer.ReadMessage(time.Until(deadline))
+		if errors.Is(err, io.EOF) {
+			log.Infof("Peer disconnected")
+			break
+		}
+
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading from peer: %w", err)
+		}
+
+		if errMsg, ok := msg.(*lnwire.Error); ok {
+			log.Infof("Peer replied with error for channel %v: %v",
+				errMsg.ChanID, errMsg.Error())
+		}
+	}
+
+	log.Infof("Error message sent, check the chain for the force close " +
+		"transaction of the peer")
+
+	return nil
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/brontide"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// startTestPeer starts a peer that sends its init message, then expects ours
+// followed by the error and replies with an error of its own. The messages it
+// received are sent to the returned channel.
+func startTestPeer(t *testing.T, peerKey keychain.SingleKeyECDH,
+	chanOp *wire.OutPoint) (net.Addr, <-chan error, <-chan lnwire.Message) {
+
+	listener, err := brontide.NewListener(peerKey, "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	peerErrs := make(chan error, 1)
+	receivedMsgs := make(chan lnwire.Message, 2)
+	go func() {
+		peerErrs <- func() error {
+			conn, err := listener.Accept()
+			if err != nil {
+				return err
+			}
+			defer func() { _ = conn.Close() }()
+			brontideConn := conn.(*brontide.Conn)
+
+			write := func(msg lnwire.Message) error {
+				var buf bytes.Buffer
+				_, err := lnwire.WriteMessage(&buf, msg, 0)
+				if err != nil {
+					return err
+				}
+				err = brontideConn.WriteMessage(buf.Bytes())
+				if err != nil {
+					return err
+				}
+				_, err = brontideConn.Flush()
+				return err
+			}
+			err = write(lnwire.NewInitMessage(
+				lnwire.NewRawFeatureVector(),
+				lnwire.NewRawFeatureVector(),
+			))
+			if err != nil {
+				return err
+			}
+
+			for i := 0; i < 2; i++ {
+				rawMsg, err := brontideConn.ReadNextMessage()
+				if err != nil {
+					return err
+				}
+				msg, err := lnwire.ReadMessage(
+					bytes.NewReader(rawMsg), 0,
+				)
+				if err != nil {
+					return err
+				}
+				receivedMsgs <- msg
+			}
+
+			return write(&lnwire.Error{
+				ChanID: lnwire.NewChanIDFromOutPoint(chanOp),
+				Data:   []byte("closing"),
+			})
+		}()
+	}()
+
+	return listener.Addr(), peerErrs, receivedMsgs
+}
+
+// assertTestPeerMessages waits for the test peer to finish and checks the
+// messages it received.
+func assertTestPeerMessages(t *testing.T, chanOp *wire.OutPoint,
+	peerErrs <-chan error, receivedMsgs <-chan lnwire.Message) {
+
+	select {
+	case err := <-peerErrs:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatalf("peer didn't finish")
+	}
+
+	initMsg := <-receivedMsgs
+	require.IsType(t, &lnwire.Init{}, initMsg)
+
+	errMsg := <-receivedMsgs
+	require.IsType(t, &lnwire.Error{}, errMsg)
+	require.Equal(
+		t, lnwire.NewChanIDFromOutPoint(chanOp),
+		errMsg.(*lnwire.Error).ChanID,
+	)
+}
+
+func newTestPeerKey(t *testing.T) *keychain.PrivKeyECDH {
+	privKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	return &keychain.PrivKeyECDH{PrivKey: privKey}
+}
+
+// testOnionHost is a syntactically valid v3 onion address.
+const testOnionHost = "2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53w" +
+	"id.onion"
+
+func TestTriggerForceClose(t *testing.T) {
+	h := newHarness(t)
+
+	ourKey, peerKey := newTestPeerKey(t), newTestPeerKey(t)
+	chanOp := &wire.OutPoint{Hash: chainhash.Hash{1, 2, 3}, Index: 1}
+	peerAddr, peerErrs, receivedMsgs := startTestPeer(t, peerKey, chanOp)
+
+	err := triggerForceClose(ourKey, &lnwire.NetAddress{
+		IdentityKey: peerKey.PubKey(),
+		Address:     peerAddr,
+	}, "", chanOp)
+	require.NoError(t, err)
+
+	assertTestPeerMessages(t, chanOp, peerErrs, receivedMsgs)
+	h.assertLogContains("Peer replied with error")
+	h.assertLogContains("Peer disconnected")
+}
+
+// startTestSocksProxy starts a minimal SOCKS5 proxy that accepts a single
+// connection without authentication and forwards it to the given address,
+// no matter which host was requested. The requested host is sent to the
+// returned channel.
+func startTestSocksProxy(t *testing.T, target net.Addr) (string,
+	<-chan string) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	requestedHosts := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSocksConn(conn, target, requestedHosts)
+		}
+	}()
+
+	return listener.Addr().String(), requestedHosts
+}
+
+// serveTestSocksConn handles a single SOCKS5 CONNECT request for a domain
+// name.
+func serveTestSocksConn(conn net.Conn, target net.Addr,
+	requestedHosts chan<- string) {
+
+	defer func() { _ = conn.Close() }()
+
+	// The greeting is the version, the number of methods and the methods.
+	// The connection check of the proxy closes the connection right away.
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	if _, err := conn.Write([]byte{0x05, 0x00}); err != nil {
+		return
+	}
+
+	// The request is the version, command, reserved byte, address type 3
+	// (domain name), the length of the name, the name and the port.
+	request := make([]byte, 5)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return
+	}
+	host := make([]byte, request[4]+2)
+	if _, err := io.ReadFull(conn, host); err != nil {
+		return
+	}
+	requestedHosts <- string(host[:len(host)-2])
+
+	targetConn, err := net.Dial("tcp", target.String())
+	if err != nil {
+		return
+	}
+	defer func() { _ = targetConn.Close() }()
+
+	_, err = conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	if err != nil {
+		return
+	}
+
+	go func() { _, _ = io.Copy(targetConn, conn) }()
+	_, _ = io.Copy(conn, targetConn)
+}
+
+func TestTriggerForceCloseOnion(t *testing.T) {
+	h := newHarness(t)
+
+	ourKey, peerKey := newTestPeerKey(t), newTestPeerKey(t)
+	chanOp := &wire.OutPoint{Hash: chainhash.Hash{1, 2, 3}, Index: 1}
+	peerAddr, peerErrs, receivedMsgs := startTestPeer(t, peerKey, chanOp)
+	socksAddr, requestedHosts := startTestSocksProxy(t, peerAddr)
+
+	onionPeer, err := lnd.ParsePeerAddress(
+		fmt.Sprintf("%x@%s", peerKey.PubKey().SerializeCompressed(),
+			testOnionHost), defaultPeerPort, "",
+	)
+	require.NoError(t, err)
+	require.True(t, lnd.IsOnionPeer(onionPeer))
+
+	// Without a proxy, onion addresses can't be reached.
+	err = triggerForceClose(ourKey, onionPeer, "", chanOp)
+	require.ErrorContains(t, err, "a SOCKS5 proxy is required")
+
+	// A proxy that isn't running is detected before dialing.
+	closedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := closedListener.Addr().String()
+	require.NoError(t, closedListener.Close())
+	err = triggerForceClose(ourKey, onionPeer, closedAddr, chanOp)
+	require.ErrorContains(t, err, "is not reachable, make sure Tor")
+
+	// The onion host is sent to the proxy unresolved.
+	err = triggerForceClose(ourKey, onionPeer, socksAddr, chanOp)
+	require.NoError(t, err)
+	require.Equal(t, testOnionHost, <-requestedHosts)
+
+	assertTestPeerMessages(t, chanOp, peerErrs, receivedMsgs)
+	h.assertLogContains("Connecting through SOCKS5 proxy " + socksAddr)
+}
+
+func TestParsePeerAddress(t *testing.T) {
+	pubKey := "03963c1839066aed02489cae3552633d3a988bff8b5e8aaa2fe3be1f" +
+		"556a16830c"
+
+	testCases := []struct {
+		address     string
+		expected    string
+		expectedErr string
+	}{{
+		address:  pubKey + "@127.0.0.1",
+		expected: "127.0.0.1:9735",
+	}, {
+		address:  pubKey + "@[2001:db8::1]:10011",
+		expected: "[2001:db8::1]:10011",
+	}, {
+		address:  pubKey + "@2001:db8::1",
+		expected: "[2001:db8::1]:9735",
+	}, {
+		address:  pubKey + "@" + testOnionHost,
+		expected: testOnionHost + ":9735",
+	}, {
+		address:  pubKey + "@" + testOnionHost + ":1234",
+		expected: testOnionHost + ":1234",
+	}, {
+		address:     pubKey + "@3g2upl4pq6kufc4m.onion",
+		expectedErr: "is not a v3 onion address",
+	}, {
+		address:     "127.0.0.1:9735",
+		expectedErr: "must be of the form <pubkey-hex>@<addr>",
+	}}
+	for _, tc := range testCases {
+		addr, err := lnd.ParsePeerAddress(
+			tc.address, defaultPeerPort, "",
+		)
+		if tc.expectedErr != "" {
+			require.ErrorContains(t, err, tc.expectedErr)
+			continue
+		}
+
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, addr.Address.String())
+	}
+}
@@ -9,8 +9,11 @@ import (
 	"runtime"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/guggero/chantools/btc/fasthd"
 	"github.com/guggero/chantools/lnd"
 	"github.com/lightningnetwork/lnd/aezeed"
@@ -22,9 +25,24 @@ var (
 	nodeKeyDerivationPath = "m/1017'/%d'/%d'/0/0"
 )
 
+const (
+	// minVanityPrefixLen is the minimum length of the hex prefix, the 02 or
+	// 03 public key marker plus at least one more character.
+	minVanityPrefixLen = 3
+
+	// maxVanityPrefixLen is the maximum length of the hex prefix. Anything
+	// longer is unlikely to be found within billions of years.
+	maxVanityPrefixLen = 16
+
+	// longVanityPrefixBits is the number of prefix bits above which we
+	// warn that the search is expected to take a very long time.
+	longVanityPrefixBits = 33
+)
+
 type vanityGenCommand struct {
-	Prefix  string
-	Threads uint8
+	Prefix    string
+	Threads   uint8
+	RandomKey bool
 
 	cmd *cobra.Command
 }
@@ -36,18 +54,34 @@ func newVanityGenCommand() *cobra.Command {
 		Short: "Generate a seed with a custom lnd node identity " +
 			"public key that starts with the given prefix",
 		Long: `Try random lnd compatible seeds until one is found that
-produces a node identity public key that starts with the given prefix.
+produces a node identity public key that starts with the given hex prefix. The
+prefix can have an odd number of characters and must start with 02 or 03.
+
+The number of tried seeds per second and the estimated time until the
+probability of having found a matching key approaches 1.0 are reported every
+second. Each additional hex character makes the search 16 times longer, so
+prefixes of more than 8 characters will take days or longer.
+
+The found seed can be restored into lnd with 'lncli create'. The node identity
+private key and its derivation path are printed as well.
+
+With the --randomkey flag, random private keys are tried instead of seeds,
+which is a lot faster. Such a key is not derived from a seed and can therefore
+not be used as the identity key of an lnd node. It can only be imported into
+implementations that allow setting the node private key directly.
 
 Example output:
 
 <pre>
 Running vanitygen on 8 threads. Prefix bit length is 17, expecting to approach
 probability p=1.0 after 131,072 seeds.
-Tested 185k seeds, p=1.41296, speed=14k/s, elapsed=13s                          
+Tested 185k seeds, p=1.41296, speed=14,125/s, eta=0s, elapsed=13s
 Looking for 022222, found pubkey: 022222f015540ddde9bdf7c95b24f1d44f7ea6ab69bec83d6fbe622296d64b51d6
 with seed: [ability roast pear stomach wink cable tube trumpet shy caught hunt
 someone border organ spoon only prepare calm silent million tobacco chaos normal
 phone]
+derivation path: m/1017'/0'/6'/0/0
+private key: 5f1d2c...
 </pre>
 `,
 		Example: `chantools vanitygen --prefix 022222 --threads 8`,
@@ -58,133 +92,336 @@ phone]
 			"public key",
 	)
 	cc.cmd.Flags().Uint8Var(
-		&cc.Threads, "threads", 4, "number of parallel threads",
+		&cc.Threads, "threads", 0, "number of parallel threads, 0 "+
+			"uses all CPU cores",
+	)
+	cc.cmd.Flags().BoolVar(
+		&cc.RandomKey, "randomkey", false, "try random private keys "+
+			"instead of seeds; the found key can't be used as an "+
+			"lnd node identity key",
 	)
 
 	return cc.cmd
 }
 
 func (c *vanityGenCommand) Execute(_ *cobra.Command, _ []string) error {
-	prefixBytes, err := hex.DecodeString(c.Prefix)
+	prefix, err := parseVanityPrefix(c.Prefix)
 	if err != nil {
-		return fmt.Errorf("hex decoding of prefix failed: %w", err)
+		return err
 	}
 
-	if len(prefixBytes) < 2 {
-		return fmt.Errorf("prefix must be at least 2 bytes")
+	threads := int(c.Threads)
+	if threads == 0 {
+		threads = runtime.NumCPU()
 	}
-	if len(prefixBytes) > 8 {
-		return fmt.Errorf("prefix too long, unlikely to find a key " +
-			"within billions of years")
+
+	path, err := lnd.ParsePath(nodeKeyPath())
+	if err != nil {
+		return err
 	}
-	if !(prefixBytes[0] == 0x02 || prefixBytes[0] == 0x03) {
-		return fmt.Errorf("prefix must start with 02 or 03 because " +
-			"it's an EC public key")
+
+	what := "seeds"
+	if c.RandomKey {
+		what = "keys"
 	}
+	numTries := math.Pow(2, float64(prefix.numBits))
+	log.Infof("Running vanitygen on %d threads. Prefix bit length is %d, "+
+		"expecting to approach probability p=1.0 after %s %s.",
+		threads, prefix.numBits, format(int64(numTries)), what)
+	if prefix.numBits > longVanityPrefixBits {
+		log.Warnf("Prefix is very long, the search will likely take "+
+			"days or longer. Watch the reported ETA and consider "+
+			"using a prefix with fewer than %d characters.",
+			len(c.Prefix))
+	}
+	runtime.GOMAXPROCS(threads)
+
+	var (
+		count    uint64
+		quit     = make(chan struct{})
+		wg       sync.WaitGroup
+		start    = time.Now()
+		progress = time.NewTicker(time.Second)
+	)
+	defer progress.Stop()
+
+	// Report the progress every second until the search is done.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		lastCount := uint64(0)
+		for {
+			select {
+			case <-quit:
+				return
+
+			case <-progress.C:
+			}
+
+			currentCount := atomic.LoadUint64(&count)
+			speed := currentCount - lastCount
+			eta := "-"
+			left := numTries - float64(currentCount)
+			if speed > 0 && left > 0 {
+				seconds := time.Duration(left / float64(speed))
+				eta = (seconds * time.Second).String()
+			}
 
-	path, err := lnd.ParsePath(fmt.Sprintf(
+			msg := fmt.Sprintf("Tested %sk %s, p=%.5f, "+
+				"speed=%s/s, eta=%s, elapsed=%v",
+				format(int64(currentCount/1000)), what,
+				float64(currentCount)/numTries,
+				format(int64(speed)), eta,
+				time.Since(start).Truncate(time.Second),
+			)
+			fmt.Printf("\r%-80s", msg)
+
+			lastCount = currentCount
+		}
+	}()
+
+	result, err := vanitySearch(prefix, path, c.RandomKey, threads, &count)
+	close(quit)
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nLooking for %s, found pubkey: %x\n", c.Prefix,
+		result.pubKey)
+
+	privKeyBytes := result.privKey.Serialize()
+	if result.entropy == nil {
+		wif, err := btcutil.NewWIF(result.privKey, chainParams, true)
+		if err != nil {
+			return fmt.Errorf("error encoding private key: %w", err)
+		}
+
+		fmt.Printf("private key: %x\nprivate key WIF: %s\n",
+			privKeyBytes, wif.String())
+		return nil
+	}
+
+	seed, err := aezeed.New(
+		aezeed.CipherSeedVersion, result.entropy, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("error creating seed: %w", err)
+	}
+	mnemonic, err := seed.ToMnemonic(nil)
+	if err != nil {
+		return fmt.Errorf("error encoding seed: %w", err)
+	}
+	fmt.Printf("with seed: %v\nderivation path: %s\nprivate key: %x\n",
+		mnemonic, nodeKeyPath(), privKeyBytes)
+
+	return nil
+}
+
+// nodeKeyPath returns the derivation path of the lnd node identity key.
+func nodeKeyPath() string {
+	return fmt.Sprintf(
 		nodeKeyDerivationPath, chainParams.HDCoinType,
 		keychain.KeyFamilyNodeKey,
-	))
+	)
+}
+
+// vanityPrefix is a hex prefix a compressed public key must start with. If the
+// prefix has an odd number of characters, only the upper half of the last byte
+// is compared.
+type vanityPrefix struct {
+	prefix   []byte
+	lastMask byte
+	numBits  int
+}
+
+// parseVanityPrefix parses and validates the given hex prefix.
+func parseVanityPrefix(prefixHex string) (*vanityPrefix, error) {
+	switch {
+	case len(prefixHex) < minVanityPrefixLen:
+		return nil, fmt.Errorf("prefix must be at least %d hex "+
+			"characters", minVanityPrefixLen)
+
+	case len(prefixHex) > maxVanityPrefixLen:
+		return nil, fmt.Errorf("prefix longer than %d hex characters, "+
+			"unlikely to find a key within billions of years",
+			maxVanityPrefixLen)
+	}
+
+	lastMask := byte(0xff)
+	if len(prefixHex)%2 == 1 {
+		prefixHex += "0"
+		lastMask = 0xf0
+	}
+	prefixBytes, err := hex.DecodeString(prefixHex)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("hex decoding of prefix failed: %w", err)
+	}
+
+	if !(prefixBytes[0] == 0x02 || prefixBytes[0] == 0x03) {
+		return nil, fmt.Errorf("prefix must start with 02 or 03 " +
+			"because it's an EC public key")
 	}
 
-	numBits := ((len(prefixBytes) - 1) * 8) + 1
-	numTries := math.Pow(2, float64(numBits))
-	fmt.Printf("Running vanitygen on %d threads. Prefix bit length is %d, "+
-		"expecting to approach\nprobability p=1.0 after %s seeds.\n",
-		c.Threads, numBits, format(int64(numTries)))
-	runtime.GOMAXPROCS(int(c.Threads))
+	// The first byte only carries one bit of information, every other
+	// hex character four bits.
+	numBits := (len(prefixBytes)-1)*8 + 1
+	if lastMask != 0xff {
+		numBits -= 4
+	}
+
+	return &vanityPrefix{
+		prefix:   prefixBytes,
+		lastMask: lastMask,
+		numBits:  numBits,
+	}, nil
+}
+
+// matches returns true if the given compressed public key starts with the
+// prefix.
+func (p *vanityPrefix) matches(pubKey []byte) bool {
+	last := len(p.prefix) - 1
+	if !bytes.HasPrefix(pubKey, p.prefix[:last]) {
+		return false
+	}
+
+	return pubKey[last]&p.lastMask == p.prefix[last]
+}
+
+// vanityResult is a key found by the vanity search.
+type vanityResult struct {
+	privKey *btcec.PrivateKey
+	pubKey  []byte
+
+	// entropy is the entropy of the seed the key was derived from. It is
+	// nil if a random key was found.
+	entropy *[aezeed.EntropySize]byte
+}
+
+// vanitySearch tries random seeds or, if randomKey is true, random private
+// keys on the given number of threads until a public key that matches the
+// prefix is found. For seeds, the key at the given derivation path is
+// compared. The number of tried seeds or keys is added to count.
+func vanitySearch(prefix *vanityPrefix, path []uint32, randomKey bool,
+	threads int, count *uint64) (*vanityResult, error) {
+
 	var (
-		mtx         sync.Mutex
-		globalCount uint64
-		abort       = make(chan struct{})
-		start       = time.Now()
+		wg      sync.WaitGroup
+		quit    = make(chan struct{})
+		results = make(chan *vanityResult, threads)
+		errs    = make(chan error, threads)
 	)
 
-	for i := uint8(0); i < c.Threads; i++ {
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
 		go func() {
-			var (
-				entropy [16]byte
-				count   uint64
-			)
+			defer wg.Done()
+
+			var entropy [aezeed.EntropySize]byte
 			for {
 				select {
-				case <-abort:
+				case <-quit:
 					return
 				default:
 				}
 
-				if _, err := rand.Read(entropy[:]); err != nil {
-					log.Error(err)
-				}
-				rootKey, err := fasthd.NewFastDerivation(
-					entropy[:], chainParams,
+				var (
+					result *vanityResult
+					err    error
 				)
-				if err != nil {
-					log.Error(err)
-				}
-				err = rootKey.ChildPath(path)
-				if err != nil {
-					log.Error(err)
-				}
-				pubKeyBytes := rootKey.PubKeyBytes()
-
-				if bytes.HasPrefix(pubKeyBytes, prefixBytes) {
-					seed, err := aezeed.New(
-						aezeed.CipherSeedVersion,
-						&entropy, time.Now(),
+				if randomKey {
+					result, err = tryVanityKey(prefix)
+				} else {
+					result, err = tryVanitySeed(
+						prefix, path, &entropy,
 					)
-					if err != nil {
-						log.Error(err)
-					}
-					mnemonic, err := seed.ToMnemonic(nil)
-					if err != nil {
-						log.Error(err)
-					}
-					fmt.Printf("\nLooking for %x, found "+
-						"pubkey: %x\nwith seed: %v\n",
-						prefixBytes, pubKeyBytes,
-						mnemonic)
-
-					close(abort)
-					return
 				}
+				atomic.AddUint64(count, 1)
+				switch {
+				case err != nil:
+					errs <- err
+					return
 
-				if count > 0 && count%100 == 0 {
-					mtx.Lock()
-					globalCount += count
-					count = 0
-					mtx.Unlock()
+				case result != nil:
+					results <- result
+					return
 				}
-				count++
 			}
 		}()
 	}
 
-	lastCount := uint64(0)
-	for {
-		select {
-		case <-abort:
-			return nil
-		case <-time.After(1 * time.Second):
-			mtx.Lock()
-			currentCount := globalCount
-			mtx.Unlock()
+	defer func() {
+		close(quit)
+		wg.Wait()
+	}()
 
-			msg := fmt.Sprintf("Tested %sk seeds, p=%.5f, "+
-				"speed=%dk/s, elapsed=%v",
-				format(int64(currentCount/1000)),
-				float64(currentCount)/numTries,
-				(currentCount-lastCount)/1000,
-				time.Since(start).Truncate(time.Second),
-			)
-			fmt.Printf("\r%-80s", msg)
+	select {
+	case result := <-results:
+		return result, nil
 
-			lastCount = currentCount
-		}
+	case err := <-errs:
+		return nil, fmt.Errorf("error searching key: %w", err)
+	}
+}
+
+// tryVanityKey creates a random private key and returns it if its public key
+// matches the prefix.
+func tryVanityKey(prefix *vanityPrefix) (*vanityResult, error) {
+	privKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, err
 	}
+
+	pubKey := privKey.PubKey().SerializeCompressed()
+	if !prefix.matches(pubKey) {
+		return nil, nil
+	}
+
+	return &vanityResult{
+		privKey: privKey,
+		pubKey:  pubKey,
+	}, nil
+}
+
+// tryVanitySeed fills the entropy with random bytes and returns the key at the
+// given derivation path of the resulting seed if its public key matches the
+// prefix.
+func tryVanitySeed(prefix *vanityPrefix, path []uint32,
+	entropy *[aezeed.EntropySize]byte) (*vanityResult, error) {
+
+	if _, err := rand.Read(entropy[:]); err != nil {
+		return nil, err
+	}
+
+	// Seeds that result in an invalid key are extremely unlikely, we just
+	// skip them.
+	rootKey, err := fasthd.NewFastDerivation(entropy[:], chainParams)
+	if err == fasthd.ErrUnusableSeed {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = rootKey.ChildPath(path)
+	if err == fasthd.ErrInvalidChild {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey := rootKey.PubKeyBytes()
+	if !prefix.matches(pubKey) {
+		return nil, nil
+	}
+
+	seedEntropy := *entropy
+	return &vanityResult{
+		privKey: rootKey.PrivKey(),
+		pubKey:  pubKey,
+		entropy: &seedEntropy,
+	}, nil
 }
 
 func format(n int64) string {
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/lnd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVanityPrefix(t *testing.T) {
+	prefix, err := parseVanityPrefix("022222")
+	require.NoError(t, err)
+	require.Equal(t, 17, prefix.numBits)
+	require.True(t, prefix.matches([]byte{0x02, 0x22, 0x22, 0x01}))
+	require.False(t, prefix.matches([]byte{0x02, 0x22, 0x23, 0x01}))
+
+	// An odd number of characters only compares the upper half of the
+	// last byte.
+	prefix, err = parseVanityPrefix("03abc")
+	require.NoError(t, err)
+	require.Equal(t, 13, prefix.numBits)
+	require.True(t, prefix.matches([]byte{0x03, 0xab, 0xcf}))
+	require.False(t, prefix.matches([]byte{0x03, 0xab, 0xdc}))
+
+	_, err = parseVanityPrefix("02")
+	require.ErrorContains(t, err, "at least 3 hex characters")
+
+	_, err = parseVanityPrefix("02aaaaaaaaaaaaaaa")
+	require.ErrorContains(t, err, "longer than 16 hex characters")
+
+	_, err = parseVanityPrefix("04aa")
+	require.ErrorContains(t, err, "must start with 02 or 03")
+
+	_, err = parseVanityPrefix("02zz")
+	require.ErrorContains(t, err, "hex decoding of prefix failed")
+}
+
+func TestVanitySearch(t *testing.T) {
+	_ = newHarness(t)
+
+	prefix, err := parseVanityPrefix("02a")
+	require.NoError(t, err)
+	path, err := lnd.ParsePath(nodeKeyPath())
+	require.NoError(t, err)
+
+	// A random key isn't derived from a seed.
+	var count uint64
+	result, err := vanitySearch(prefix, path, true, 2, &count)
+	require.NoError(t, err)
+	require.Nil(t, result.entropy)
+	require.True(t, prefix.matches(result.pubKey))
+	require.Equal(
+		t, result.pubKey, result.privKey.PubKey().SerializeCompressed(),
+	)
+	require.NotZero(t, count)
+
+	// The key found for a seed must be the node identity key lnd derives
+	// from it.
+	result, err = vanitySearch(prefix, path, false, 2, &count)
+	require.NoError(t, err)
+	require.NotNil(t, result.entropy)
+	require.True(t, prefix.matches(result.pubKey))
+
+	rootKey, err := hdkeychain.NewMaster(result.entropy[:], chainParams)
+	require.NoError(t, err)
+	nodeKey, err := lnd.DeriveChildren(rootKey, path)
+	require.NoError(t, err)
+	nodePrivKey, err := nodeKey.ECPrivKey()
+	require.NoError(t, err)
+	require.Equal(
+		t, result.pubKey, nodePrivKey.PubKey().SerializeCompressed(),
+	)
+	require.Equal(t, nodePrivKey.Serialize(), result.privKey.Serialize())
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/lnd"
+	"github.com/spf13/cobra"
+)
+
+const verifyAddressFormat = `
+Address:		%s
+Address type:		%s
+Derivation path:	%s
+`
+
+var (
+	// errAddressNotFound is returned if an address can't be derived from
+	// the seed within the scanned indexes.
+	errAddressNotFound = errors.New("address not found")
+)
+
+type verifyAddressCommand struct {
+	Address                string
+	RecoveryWindow         uint32
+	RecoveryWindowInternal uint32
+
+	rootKey *rootKey
+	cmd     *cobra.Command
+}
+
+func newVerifyAddressCommand() *cobra.Command {
+	cc := &verifyAddressCommand{}
+	cc.cmd = &cobra.Command{
+		Use: "verifyaddress",
+		Short: "Verify that an on-chain address belongs to the " +
+			"wallet of a seed",
+		Long: `This command checks whether the given address can be
+derived from the seed, without connecting to any external services. This can
+be used to make sure funds are sent to (or swept from) an address of the
+expected wallet.
+
+Depending on the type of the address, the external and internal branches of
+the BIP84 (p2wkh), BIP49 (np2wkh) or BIP86 (p2tr) account of the wallet are
+scanned up to --recoverywindow indexes each. The internal (change) branch is
+usually used less, its number of indexes can be set separately with
+--recoverywindow-internal. lnd always uses the coin type 0 for
+its on-chain wallet, other wallets use the coin type of the network, both are
+scanned. If the address is found, its full derivation path is shown.`,
+		Example: `chantools verifyaddress --address bc1q...
+
+chantools verifyaddress --address bc1p... --recoverywindow 5000`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.Address, "address", "", "the p2wkh, np2wkh or p2tr "+
+			"address to look for",
+	)
+	cc.cmd.Flags().Uint32Var(
+		&cc.RecoveryWindow, "recoverywindow", defaultRecoveryWindow,
+		"number of indexes to scan per internal/external branch",
+	)
+	cc.cmd.Flags().Uint32Var(
+		&cc.RecoveryWindowInternal, "recoverywindow-internal", 0,
+		"number of indexes to scan on the internal (change) branch; "+
+			"0 means the same as --recoverywindow",
+	)
+
+	cc.rootKey = newRootKey(cc.cmd, "deriving the addresses")
+
+	return cc.cmd
+}
+
+func (c *verifyAddressCommand) Execute(_ *cobra.Command, _ []string) error {
+	if c.Address == "" {
+		return fmt.Errorf("address is required")
+	}
+	addr, err := btcutil.DecodeAddress(c.Address, chainParams)
+	if err != nil {
+		return fmt.Errorf("error parsing address: %w", err)
+	}
+	if !addr.IsForNet(chainParams) {
+		return fmt.Errorf("address %s is not valid for network %s",
+			c.Address, chainParams.Name)
+	}
+
+	extendedKey, err := c.rootKey.read()
+	if err != nil {
+		return fmt.Errorf("error reading root key: %w", err)
+	}
+
+	if c.RecoveryWindowInternal == 0 {
+		c.RecoveryWindowInternal = c.RecoveryWindow
+	}
+	addrType, path, err := verifyAddress(
+		extendedKey, addr, c.RecoveryWindow, c.RecoveryWindowInternal,
+	)
+	notFound := errors.Is(err, errAddressNotFound)
+	if err != nil && !notFound {
+		return err
+	}
+	if notFound {
+		path = fmt.Sprintf("not found within %d indexes",
+			c.RecoveryWindow)
+	}
+
+	result := fmt.Sprintf(verifyAddressFormat, c.Address, addrType, path)
+	fmt.Println(result)
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(result)
+
+	if notFound {
+		return fmt.Errorf("%w within %d indexes, try increasing "+
+			"--recoverywindow", err, c.RecoveryWindow)
+	}
+
+	return nil
+}
+
+// verifyAddress scans the external and internal branches of the wallet
+// account that matches the type of the given address for the address. The
+// address type and the derivation path of the address are returned, or
+// errAddressNotFound if it's not within the first recoveryWindow indexes of the
+// external or the first recoveryWindowInternal indexes of the internal branch.
+func verifyAddress(extendedKey *hdkeychain.ExtendedKey, addr btcutil.Address,
+	recoveryWindow, recoveryWindowInternal uint32) (string, string, error) {
+
+	var (
+		addrType string
+		purpose  uint32
+	)
+	switch addr.(type) {
+	case *btcutil.AddressWitnessPubKeyHash:
+		addrType, purpose = addrTypeP2WKH, 84
+
+	// A P2SH address may contain any script, we can only find it if it's
+	// a nested P2WKH.
+	case *btcutil.AddressScriptHash:
+		addrType, purpose = addrTypeNP2WKH, 49
+
+	case *btcutil.AddressTaproot:
+		addrType, purpose = addrTypeP2TR, 86
+
+	default:
+		return "", "", fmt.Errorf("unsupported address type, only "+
+			"%s, %s and %s addresses can be verified",
+			addrTypeP2WKH, addrTypeNP2WKH, addrTypeP2TR)
+	}
+
+	// The lnd wallet uses the coin type 0 on all networks.
+	coinTypes := []uint32{0}
+	if chainParams.HDCoinType != 0 {
+		coinTypes = append(coinTypes, chainParams.HDCoinType)
+	}
+
+	for _, coinType := range coinTypes {
+		for branch, window := range []uint32{
+			recoveryWindow, recoveryWindowInternal,
+		} {
+			branchPath := fmt.Sprintf("m/%d'/%d'/0'/%d", purpose,
+				coinType, branch)
+			index, err := findBranchAddress(
+				extendedKey, branchPath, addrType, addr, window,
+			)
+			switch {
+			case err == nil:
+				path := fmt.Sprintf("%s/%d", branchPath, index)
+				return addrType, path, nil
+
+			case !errors.Is(err, errAddressNotFound):
+				return "", "", err
+			}
+		}
+	}
+
+	return addrType, "", errAddressNotFound
+}
+
+// findBranchAddress returns the index of the given address within the first
+// recoveryWindow indexes of the branch with the given derivation path, or
+// errAddressNotFound if it's not one of them.
+func findBranchAddress(extendedKey *hdkeychain.ExtendedKey, branchPath,
+	addrType string, addr btcutil.Address, recoveryWindow uint32) (uint32,
+	error) {
+
+	parsedPath, err := lnd.ParsePath(branchPath)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing path: %w", err)
+	}
+	branchKey, err := lnd.DeriveChildren(extendedKey, parsedPath)
+	if err != nil {
+		return 0, fmt.Errorf("error deriving children: %w", err)
+	}
+
+	encodedAddr := addr.EncodeAddress()
+	for index := uint32(0); index < recoveryWindow; index++ {
+		childKey, err := branchKey.DeriveNonStandard(index)
+		if err != nil {
+			return 0, fmt.Errorf("error deriving child: %w", err)
+		}
+		pubKey, err := childKey.ECPubKey()
+		if err != nil {
+			return 0, fmt.Errorf("error deriving public key: %w",
+				err)
+		}
+		childAddr, err := addressOfType(pubKey, addrType)
+		if err != nil {
+			return 0, err
+		}
+
+		if childAddr.EncodeAddress() == encodedAddr {
+			return index, nil
+		}
+	}
+
+	return 0, errAddressNotFound
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/lnd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyAddress(t *testing.T) {
+	h := newHarness(t)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	deriveAddr := func(path, addrType string) string {
+		_, pubKey, _, err := lnd.DeriveKey(
+			extendedKey, path, chainParams,
+		)
+		require.NoError(t, err)
+		addr, err := addressOfType(pubKey, addrType)
+		require.NoError(t, err)
+
+		return addr.EncodeAddress()
+	}
+
+	testCases := []struct {
+		path     string
+		addrType string
+	}{
+		{"m/84'/0'/0'/0/3", addrTypeP2WKH},
+		{"m/49'/0'/0'/1/7", addrTypeNP2WKH},
+		{"m/86'/0'/0'/0/0", addrTypeP2TR},
+
+		// Other wallets use the coin type of the network.
+		{"m/84'/1'/0'/1/9", addrTypeP2WKH},
+	}
+	for _, tc := range testCases {
+		h.clearLog()
+		verify := &verifyAddressCommand{
+			Address:        deriveAddr(tc.path, tc.addrType),
+			RecoveryWindow: 10,
+			rootKey:        &rootKey{RootKey: rootKeyAezeed},
+		}
+		require.NoError(t, verify.Execute(nil, nil))
+		h.assertLogContains("Address type:\t\t" + tc.addrType + "\n")
+		h.assertLogContains("Derivation path:\t" + tc.path + "\n")
+	}
+
+	// An address beyond the recovery window isn't found.
+	h.clearLog()
+	verify := &verifyAddressCommand{
+		Address:        deriveAddr("m/84'/0'/0'/0/10", addrTypeP2WKH),
+		RecoveryWindow: 10,
+		rootKey:        &rootKey{RootKey: rootKeyAezeed},
+	}
+	err = verify.Execute(nil, nil)
+	require.ErrorIs(t, err, errAddressNotFound)
+	h.assertLogContains("Derivation path:\tnot found within 10 indexes")
+
+	verify.RecoveryWindow = 11
+	require.NoError(t, verify.Execute(nil, nil))
+
+	// The internal branch can be scanned with a different window.
+	verify.Address = deriveAddr("m/84'/0'/0'/1/5", addrTypeP2WKH)
+	verify.RecoveryWindowInternal = 5
+	err = verify.Execute(nil, nil)
+	require.ErrorIs(t, err, errAddressNotFound)
+
+	verify.RecoveryWindowInternal = 6
+	require.NoError(t, verify.Execute(nil, nil))
+
+	// Legacy addresses aren't supported.
+	verify.Address = deriveAddr("m/44'/0'/0'/0/0", addrTypeP2PKH)
+	err = verify.Execute(nil, nil)
+	require.ErrorContains(t, err, "unsupported address type")
+
+	// The address must be for the selected network.
+	verify.Address = "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"
+	err = verify.Execute(nil, nil)
+	require.ErrorContains(t, err, "not valid for network regtest")
+}
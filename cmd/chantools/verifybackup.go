@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/chanbackup"
+	"github.com/spf13/cobra"
+)
+
+type verifyBackupCommand struct {
+	MultiFile string
+
+	rootKey *rootKey
+	cmd     *cobra.Command
+}
+
+func newVerifyBackupCommand() *cobra.Command {
+	cc := &verifyBackupCommand{}
+	cc.cmd = &cobra.Command{
+		Use: "verifybackup",
+		Short: "Verify that a channel.backup file can be decrypted " +
+			"and all channels in it can be parsed",
+		Long: `This command checks that the given channel.backup file
+can be decrypted with the root key and that every channel entry in it can be
+parsed. For each channel the result is printed, followed by a summary. The
+funding multisig key of each channel is also checked to be derivable from the
+root key.
+
+If the backup can't be decrypted (for example because it was created with a
+different seed) or any channel entry is invalid, the command exits with a
+non-zero exit code. Nothing is ever published or written by this command.`,
+		Example: `chantools verifybackup \
+	--multi_file ~/.lnd/data/chain/bitcoin/mainnet/channel.backup`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.MultiFile, "multi_file", "", "lnd channel.backup file to "+
+			"verify",
+	)
+
+	cc.rootKey = newRootKey(cc.cmd, "decrypting the backup")
+
+	return cc.cmd
+}
+
+func (c *verifyBackupCommand) Execute(_ *cobra.Command, _ []string) error {
+	extendedKey, err := c.rootKey.read()
+	if err != nil {
+		return fmt.Errorf("error reading root key: %w", err)
+	}
+
+	// Check that we have a backup file.
+	if c.MultiFile == "" {
+		return fmt.Errorf("backup file is required")
+	}
+	packedMulti, err := ioutil.ReadFile(c.MultiFile)
+	if err != nil {
+		return fmt.Errorf("error reading backup file: %w", err)
+	}
+	keyRing := &lnd.HDKeyRing{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+	return verifyChannelBackup(packedMulti, keyRing)
+}
+
+func verifyChannelBackup(packedMulti []byte, ring *lnd.HDKeyRing) error {
+	plaintext, err := lnd.DecryptMultiBackup(packedMulti, ring)
+	if err != nil {
+		return fmt.Errorf("could not decrypt backup, make sure it was "+
+			"created with the same seed: %w", err)
+	}
+
+	version, results, err := lnd.ParseMultiBackup(plaintext)
+	if err != nil {
+		return fmt.Errorf("could not parse backup: %w", err)
+	}
+
+	var (
+		result    strings.Builder
+		numFailed int
+	)
+	result.WriteString(fmt.Sprintf(
+		"Backup version %d with %d channel(s)\n", version, len(results),
+	))
+	for idx, single := range results {
+		err := single.Err
+		if err == nil {
+			err = verifySingleKeys(single.Single, ring)
+		}
+
+		if err != nil {
+			numFailed++
+			result.WriteString(fmt.Sprintf(
+				"Channel %d: FAILED (%v)\n", idx, err,
+			))
+			continue
+		}
+
+		result.WriteString(fmt.Sprintf(
+			"Channel %d (%v, %d sats): OK\n", idx,
+			single.Single.FundingOutpoint,
+			single.Single.Capacity,
+		))
+	}
+	result.WriteString(fmt.Sprintf(
+		"Summary: %d OK, %d failed\n", len(results)-numFailed,
+		numFailed,
+	))
+
+	fmt.Println(result.String())
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(result.String())
+
+	if numFailed > 0 {
+		return fmt.Errorf("%d of %d channel(s) in the backup are "+
+			"invalid", numFailed, len(results))
+	}
+
+	return nil
+}
+
+// verifySingleKeys makes sure the funding multisig key of the given channel
+// can be derived from the key ring.
+func verifySingleKeys(single *chanbackup.Single, ring *lnd.HDKeyRing) error {
+	keyDesc := single.LocalChanCfg.MultiSigKey
+	derived, err := ring.DeriveKey(keyDesc.KeyLocator)
+	if err != nil {
+		return fmt.Errorf("error deriving multisig key: %w", err)
+	}
+
+	if keyDesc.PubKey != nil && !derived.PubKey.IsEqual(keyDesc.PubKey) {
+		return fmt.Errorf("multisig key %x doesn't match the key "+
+			"derived from the root key",
+			keyDesc.PubKey.SerializeCompressed())
+	}
+
+	return nil
+}
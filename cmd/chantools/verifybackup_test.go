@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/guggero/chantools/lnd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyBackup(t *testing.T) {
+	h := newHarness(t)
+
+	// Create a channel backup from a channel DB file.
+	makeBackup := &chanBackupCommand{
+		ChannelDB: h.testdataFile("channel.db"),
+		MultiFile: h.tempFile("extracted.backup"),
+		rootKey:   &rootKey{RootKey: rootKeyAezeed},
+	}
+	err := makeBackup.Execute(nil, nil)
+	require.NoError(t, err)
+
+	packedMulti, err := ioutil.ReadFile(makeBackup.MultiFile)
+	require.NoError(t, err)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	ring := &lnd.HDKeyRing{
+		ExtendedKey: extendedKey,
+		ChainParams: chainParams,
+	}
+
+	h.clearLog()
+	err = verifyChannelBackup(packedMulti, ring)
+	require.NoError(t, err)
+	h.assertLogContains("Backup version 0 with 4 channel(s)")
+	h.assertLogContains("Summary: 4 OK, 0 failed")
+
+	// A backup can't be decrypted with a different root key.
+	otherKey, err := extendedKey.Derive(0)
+	require.NoError(t, err)
+	err = verifyChannelBackup(packedMulti, &lnd.HDKeyRing{
+		ExtendedKey: otherKey,
+		ChainParams: chainParams,
+	})
+	require.ErrorContains(t, err, "could not decrypt backup")
+
+	// An invalid single backup doesn't prevent the others from being
+	// parsed. The first single backup starts after the multi version byte
+	// and the number of backups.
+	plaintext, err := lnd.DecryptMultiBackup(packedMulti, ring)
+	require.NoError(t, err)
+	plaintext[5] = 0xff
+
+	_, results, err := lnd.ParseMultiBackup(plaintext)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	require.ErrorContains(t, results[0].Err, "unknown version")
+	for _, result := range results[1:] {
+		require.NoError(t, result.Err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/guggero/chantools/lnd"
+	"github.com/spf13/cobra"
+)
+
+const verifyMessageFormat = `
+Message:		%s
+Signature:		%s
+Signed by node:		%x`
+
+type verifyMessageCommand struct {
+	Msg    string
+	Sig    string
+	PubKey string
+
+	cmd *cobra.Command
+}
+
+func newVerifyMessageCommand() *cobra.Command {
+	cc := &verifyMessageCommand{}
+	cc.cmd = &cobra.Command{
+		Use:   "verifymessage",
+		Short: "Verify a message signed with a node's identity key",
+		Long: `Recovers the node identity public key from a signature
+created by the signmessage command of chantools or lncli, without the need of a
+running lnd node.
+
+If the expected node public key is given with --pubkey, the command fails if
+the message was not signed by that node.`,
+		Example: `chantools verifymessage --msg "I own this node" \
+	--sig d7ehm... --pubkey 03abcdef...`,
+		RunE: cc.Execute,
+	}
+	cc.cmd.Flags().StringVar(
+		&cc.Msg, "msg", "", "the message that was signed",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.Sig, "sig", "", "the zbase32 encoded signature of the "+
+			"message",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.PubKey, "pubkey", "", "the hex encoded identity public "+
+			"key of the node that is expected to have signed the "+
+			"message",
+	)
+
+	return cc.cmd
+}
+
+func (c *verifyMessageCommand) Execute(_ *cobra.Command, _ []string) error {
+	if c.Msg == "" {
+		return fmt.Errorf("message to verify is required")
+	}
+	if c.Sig == "" {
+		return fmt.Errorf("signature is required")
+	}
+
+	pubKey, err := lnd.VerifyMessage([]byte(c.Msg), c.Sig)
+	if err != nil {
+		return err
+	}
+
+	if c.PubKey != "" {
+		pubKeyBytes, err := hex.DecodeString(c.PubKey)
+		if err != nil {
+			return fmt.Errorf("error hex decoding pubkey: %w", err)
+		}
+		expectedPubKey, err := btcec.ParsePubKey(pubKeyBytes)
+		if err != nil {
+			return fmt.Errorf("error parsing pubkey: %w", err)
+		}
+
+		if !expectedPubKey.IsEqual(pubKey) {
+			return fmt.Errorf("message was signed by node %x and "+
+				"not by the expected node %x",
+				pubKey.SerializeCompressed(),
+				expectedPubKey.SerializeCompressed())
+		}
+	}
+
+	result := fmt.Sprintf(
+		verifyMessageFormat, c.Msg, c.Sig, pubKey.SerializeCompressed(),
+	)
+	fmt.Println(result)
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(result)
+
+	return nil
+}
@@ -1,23 +1,20 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcwallet/snacl"
 	"github.com/btcsuite/btcwallet/waddrmgr"
-	"github.com/btcsuite/btcwallet/wallet"
 	"github.com/btcsuite/btcwallet/walletdb"
-	_ "github.com/btcsuite/btcwallet/walletdb/bdb"
 	"github.com/guggero/chantools/lnd"
 	"github.com/lightningnetwork/lnd/keychain"
 	"github.com/lightningnetwork/lnd/lncfg"
 	"github.com/lightningnetwork/lnd/lnwallet"
 	"github.com/spf13/cobra"
-	"go.etcd.io/bbolt"
 )
 
 const (
@@ -25,15 +22,26 @@ const (
 
 	walletInfoFormat = `
 Identity Pubkey:		%x
+Watch-only:			%v
+Birthday:			%s
+Birthday block:			%s
+BIP32 root key fingerprint:	%s
 BIP32 HD extended root key:	%s
 Wallet scopes:
 %s
 `
 
 	keyScopeformat = `
-Scope:	m/%d'/%d'
-  Number of internal %s addresses:	%d
-  Number of external %s addresses: 	%d
+Scope:	m/%d'/%d' (%s)
+  Number of internal addresses:	%d
+  Number of external addresses: 	%d
+  Next internal address:	%s
+  Next external address:	%s
+`
+
+	lndScopeFormat = `
+Scope:	m/%d'/%d' (lnd internal keys)
+  Number of key families:	%d
 `
 )
 
@@ -47,15 +55,20 @@ var (
 	cryptoPrivKeyName = []byte("cpriv")
 	masterHDPrivName  = []byte("mhdpriv")
 	defaultAccount    = uint32(waddrmgr.DefaultAccountNum)
-	openCallbacks     = &waddrmgr.OpenCallbacks{
-		ObtainSeed:        noConsole,
-		ObtainPrivatePass: noConsole,
+
+	addrTypeNames = map[waddrmgr.AddressType]string{
+		waddrmgr.PubKeyHash:          "p2pkh",
+		waddrmgr.NestedWitnessPubKey: "np2wkh",
+		waddrmgr.WitnessPubKey:       "p2wkh",
+		waddrmgr.TaprootPubKey:       "p2tr",
 	}
 )
 
 type walletInfoCommand struct {
-	WalletDB    string
-	WithRootKey bool
+	WalletDB           string
+	WalletPasswordFile string
+	Dump               bool
+	WithRootKey        bool
 
 	cmd *cobra.Command
 }
@@ -66,14 +79,23 @@ func newWalletInfoCommand() *cobra.Command {
 		Use: "walletinfo",
 		Short: "Shows info about an lnd wallet.db file and optionally " +
 			"extracts the BIP32 HD root key",
-		Long: `Shows some basic information about an lnd wallet.db file,
-like the node identity the wallet belongs to, how many on-chain addresses are
-used and, if enabled with --withrootkey the BIP32 HD root key of the wallet. The
-latter can be useful to recover funds from a wallet if the wallet password is
-still known but the seed was lost. **The 24 word seed phrase itself cannot be
-extracted** because it is hashed into the extended HD root key before storing it
-in the wallet.db.`,
-		Example: `chantools walletinfo --withrootkey \
+		Long: `Shows some basic information about an lnd wallet.db file
+without starting lnd, like the node identity the wallet belongs to, the
+wallet's birthday, whether it is a watch-only wallet, the fingerprint of its
+BIP32 HD root key and, for each derivation scope in use, how many on-chain
+addresses are used and which addresses come next. This can help to find out
+whether a wallet, a seed and a channel.db belong together.
+
+The wallet.db is opened read-only, so it is never modified. No private key
+material is printed unless --dump is set, in which case the BIP32 HD root key of
+the wallet is shown too. The latter can be useful to recover funds from a
+wallet if the wallet password is still known but the seed was lost. **The 24
+word seed phrase itself cannot be extracted** because it is hashed into the
+extended HD root key before storing it in the wallet.db.`,
+		Example: `chantools walletinfo \
+	--walletdb ~/.lnd/data/chain/bitcoin/mainnet/wallet.db
+
+chantools walletinfo --dump \
 	--walletdb ~/.lnd/data/chain/bitcoin/mainnet/wallet.db`,
 		RunE: cc.Execute,
 	}
@@ -81,10 +103,21 @@ in the wallet.db.`,
 		&cc.WalletDB, "walletdb", "", "lnd wallet.db file to dump the "+
 			"contents from",
 	)
+	cc.cmd.Flags().StringVar(
+		&cc.WalletPasswordFile, "wallet-password-file", "", "file to "+
+			"read the wallet password from instead of prompting "+
+			"for it; an empty file means the default password is "+
+			"used",
+	)
 	cc.cmd.Flags().BoolVar(
-		&cc.WithRootKey, "withrootkey", false, "print BIP32 HD root "+
-			"key of wallet to standard out",
+		&cc.Dump, "dump", false, "print private key material (the "+
+			"BIP32 HD root key of the wallet) to standard out",
 	)
+	cc.cmd.Flags().BoolVar(
+		&cc.WithRootKey, "withrootkey", false, "deprecated, use "+
+			"--dump instead",
+	)
+	_ = cc.cmd.Flags().MarkDeprecated("withrootkey", "use --dump instead")
 
 	return cc.cmd
 }
@@ -105,6 +138,19 @@ func (c *walletInfoCommand) Execute(_ *cobra.Command, _ []string) error {
 	// password from environment variables.
 	pw := []byte(strings.TrimSpace(os.Getenv(passwordEnvName)))
 
+	// A password file takes precedence over the environment.
+	if c.WalletPasswordFile != "" {
+		filePw, err := readSecretFile(c.WalletPasswordFile)
+		if err != nil {
+			return err
+		}
+
+		pw = []byte(filePw)
+		if len(pw) == 0 {
+			pw = []byte("-")
+		}
+	}
+
 	// Because we cannot differentiate between an empty and a non-existent
 	// environment variable, we need a special character that indicates that
 	// no password should be used. We use a single dash (-) for that as that
@@ -132,51 +178,49 @@ func (c *walletInfoCommand) Execute(_ *cobra.Command, _ []string) error {
 		privateWalletPw = pw
 	}
 
-	// Try to load and open the wallet.
-	db, err := walletdb.Open(
-		"bdb", lncfg.CleanAndExpandPath(c.WalletDB), false,
-		lnd.DefaultOpenTimeout,
+	// Try to load and open the wallet in read-only mode. We don't use the
+	// btcwallet wallet here because it would try to migrate and update the
+	// database.
+	db, err := lnd.OpenBackend(
+		lncfg.CleanAndExpandPath(c.WalletDB), true,
 	)
-	if errors.Is(err, bbolt.ErrTimeout) {
-		return fmt.Errorf("error opening wallet database, make sure " +
-			"lnd is not running and holding the exclusive lock " +
-			"on the wallet")
-	}
 	if err != nil {
 		return fmt.Errorf("error opening wallet database: %w", err)
 	}
 	defer func() { _ = db.Close() }()
 
-	w, err := wallet.Open(db, publicWalletPw, openCallbacks, chainParams, 0)
-	if err != nil {
-		return err
-	}
-
-	// Start and unlock the wallet.
-	w.Start()
-	defer w.Stop()
-	err = w.Unlock(privateWalletPw, nil)
+	info, err := walletInfo(db, publicWalletPw)
 	if err != nil {
 		return err
 	}
 
-	// Print the wallet info and if requested the root key.
-	identityKey, scopeInfo, err := walletInfo(w)
-	if err != nil {
-		return err
-	}
-	rootKey := na
-	if c.WithRootKey {
+	// A watch-only wallet doesn't have a root key we could decrypt.
+	fingerprint, rootKey := na, na
+	if !info.watchOnly {
 		masterHDPrivKey, err := decryptRootKey(db, privateWalletPw)
+		if err != nil {
+			return fmt.Errorf("error decrypting root key: %w", err)
+		}
+		extendedKey, err := hdkeychain.NewKeyFromString(
+			string(masterHDPrivKey),
+		)
+		if err != nil {
+			return fmt.Errorf("error parsing root key: %w", err)
+		}
+		fingerprint, err = rootKeyFingerprint(extendedKey)
 		if err != nil {
 			return err
 		}
-		rootKey = string(masterHDPrivKey)
+
+		if c.Dump || c.WithRootKey {
+			rootKey = string(masterHDPrivKey)
+		}
 	}
 
 	result := fmt.Sprintf(
-		walletInfoFormat, identityKey.SerializeCompressed(), rootKey,
-		scopeInfo,
+		walletInfoFormat, info.identityKey.SerializeCompressed(),
+		info.watchOnly, info.birthday, info.birthdayBlock, fingerprint,
+		rootKey, info.scopes,
 	)
 
 	fmt.Println(result)
@@ -187,57 +231,147 @@ func (c *walletInfoCommand) Execute(_ *cobra.Command, _ []string) error {
 	return nil
 }
 
-func walletInfo(w *wallet.Wallet) (*btcec.PublicKey, string, error) {
-	keyRing := keychain.NewBtcWalletKeyRing(w, chainParams.HDCoinType)
-	idPrivKey, err := keyRing.DerivePrivKey(keychain.KeyDescriptor{
-		KeyLocator: keychain.KeyLocator{
-			Family: keychain.KeyFamilyNodeKey,
-			Index:  0,
-		},
-	})
-	if err != nil {
-		return nil, "", fmt.Errorf("unable to open key ring for coin "+
-			"type %d: %v", chainParams.HDCoinType, err)
-	}
+// walletInfoResult is the public information about a wallet that can be read
+// without decrypting any private keys.
+type walletInfoResult struct {
+	identityKey   *btcec.PublicKey
+	watchOnly     bool
+	birthday      string
+	birthdayBlock string
+	scopes        string
+}
 
-	// Collect information about the different addresses in use.
-	scopeNp2wkh, err := printScopeInfo(
-		"np2wkh", w, w.Manager.ScopesForExternalAddrType(
-			waddrmgr.NestedWitnessPubKey,
-		),
-	)
-	if err != nil {
-		return nil, "", err
-	}
-	scopeP2wkh, err := printScopeInfo(
-		"p2wkh", w, w.Manager.ScopesForExternalAddrType(
-			waddrmgr.WitnessPubKey,
-		),
-	)
+// walletInfo reads the public information about the wallet from the address
+// manager. Only the public passphrase is needed for this.
+func walletInfo(db walletdb.DB, publicPw []byte) (*walletInfoResult, error) {
+	result := &walletInfoResult{}
+	err := walletdb.View(db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(waddrmgrNamespaceKey)
+		if ns == nil {
+			return fmt.Errorf("namespace '%s' does not exist",
+				waddrmgrNamespaceKey)
+		}
+
+		mgr, err := waddrmgr.Open(ns, publicPw, chainParams)
+		if err != nil {
+			return fmt.Errorf("error opening address manager: %w",
+				err)
+		}
+		defer mgr.Close()
+
+		result.watchOnly = mgr.WatchOnly()
+		result.birthday = mgr.Birthday().String()
+		result.birthdayBlock = na
+		block, verified, err := mgr.BirthdayBlock(ns)
+		if err == nil {
+			result.birthdayBlock = fmt.Sprintf("%d (%v, verified: "+
+				"%v)", block.Height, block.Hash, verified)
+		}
+
+		lndScope := waddrmgr.KeyScope{
+			Purpose: keychain.BIP0043Purpose,
+			Coin:    chainParams.HDCoinType,
+		}
+		lndMgr, err := mgr.FetchScopedKeyManager(lndScope)
+		if err != nil {
+			return fmt.Errorf("unable to open key ring for coin "+
+				"type %d: %v", chainParams.HDCoinType, err)
+		}
+		nodeKeyFamily := uint32(keychain.KeyFamilyNodeKey)
+		idAddr, err := lndMgr.DeriveFromKeyPath(
+			ns, waddrmgr.DerivationPath{
+				InternalAccount: nodeKeyFamily,
+				Account:         nodeKeyFamily,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("error deriving identity key: %w",
+				err)
+		}
+		idPubKeyAddr, ok := idAddr.(waddrmgr.ManagedPubKeyAddress)
+		if !ok {
+			return fmt.Errorf("identity key is not a public key " +
+				"address")
+		}
+		result.identityKey = idPubKeyAddr.PubKey()
+
+		// Collect information about the different scopes in use.
+		for _, scopedMgr := range mgr.ActiveScopedKeyManagers() {
+			scopeInfo, err := printScopeInfo(ns, scopedMgr)
+			if err != nil {
+				return err
+			}
+			result.scopes += scopeInfo
+		}
+
+		return nil
+	})
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	return idPrivKey.PubKey(), scopeNp2wkh + scopeP2wkh, nil
+	return result, nil
 }
 
-func printScopeInfo(name string, w *wallet.Wallet,
-	scopes []waddrmgr.KeyScope) (string, error) {
+func printScopeInfo(ns walletdb.ReadBucket,
+	scopedMgr *waddrmgr.ScopedKeyManager) (string, error) {
 
-	scopeInfo := ""
-	for _, scope := range scopes {
-		props, err := w.AccountProperties(scope, defaultAccount)
+	scope := scopedMgr.Scope()
+
+	// The lnd scope contains one account per key family and no on-chain
+	// addresses, so the address counts are meaningless.
+	if scope.Purpose == keychain.BIP0043Purpose {
+		lastAccount, err := scopedMgr.LastAccount(ns)
 		if err != nil {
-			return "", fmt.Errorf("error fetching account "+
-				"properties: %w", err)
+			return "", fmt.Errorf("error fetching last account: "+
+				"%w", err)
 		}
-		scopeInfo += fmt.Sprintf(
-			keyScopeformat, scope.Purpose, scope.Coin, name,
-			props.InternalKeyCount, name, props.ExternalKeyCount,
+		return fmt.Sprintf(
+			lndScopeFormat, scope.Purpose, scope.Coin,
+			lastAccount+1,
+		), nil
+	}
+
+	props, err := scopedMgr.AccountProperties(ns, defaultAccount)
+	if err != nil {
+		return "", fmt.Errorf("error fetching account properties: %w",
+			err)
+	}
+
+	nextAddr := func(branch, index uint32) string {
+		addr, err := scopedMgr.DeriveFromKeyPath(
+			ns, waddrmgr.DerivationPath{
+				InternalAccount: defaultAccount,
+				Account:         defaultAccount,
+				Branch:          branch,
+				Index:           index,
+			},
 		)
+		if err != nil {
+			log.Warnf("Could not derive next address of scope "+
+				"m/%d'/%d': %v", scope.Purpose, scope.Coin, err)
+			return na
+		}
+		// Not all address types can be derived from a public key
+		// alone, in that case there's no address.
+		if addr.Address() == nil {
+			return na
+		}
+		return addr.Address().String()
+	}
+
+	addrType := scopedMgr.AddrSchema().ExternalAddrType
+	name, ok := addrTypeNames[addrType]
+	if !ok {
+		name = fmt.Sprintf("address type %d", addrType)
 	}
 
-	return scopeInfo, nil
+	return fmt.Sprintf(
+		keyScopeformat, scope.Purpose, scope.Coin, name,
+		props.InternalKeyCount, props.ExternalKeyCount,
+		nextAddr(waddrmgr.InternalBranch, props.InternalKeyCount),
+		nextAddr(waddrmgr.ExternalBranch, props.ExternalKeyCount),
+	), nil
 }
 
 func decryptRootKey(db walletdb.DB, privPassphrase []byte) ([]byte, error) {
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"io/ioutil"
 	"testing"
 
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/stretchr/testify/require"
 )
 
@@ -16,8 +18,8 @@ func TestWalletInfo(t *testing.T) {
 
 	// Dump the wallet information.
 	info := &walletInfoCommand{
-		WalletDB:    h.testdataFile("wallet.db"),
-		WithRootKey: true,
+		WalletDB: h.testdataFile("wallet.db"),
+		Dump:     true,
 	}
 
 	t.Setenv(passwordEnvName, testPassPhrase)
@@ -28,3 +30,50 @@ func TestWalletInfo(t *testing.T) {
 	h.assertLogContains(walletContent)
 	h.assertLogContains(rootKeyAezeed)
 }
+
+func TestWalletInfoNoDump(t *testing.T) {
+	h := newHarness(t)
+
+	info := &walletInfoCommand{
+		WalletDB: h.testdataFile("wallet.db"),
+	}
+
+	t.Setenv(passwordEnvName, testPassPhrase)
+
+	err := info.Execute(nil, nil)
+	require.NoError(t, err)
+
+	// Without --dump we only show the fingerprint of the root key, never
+	// the key itself.
+	rootKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	fingerprint, err := rootKeyFingerprint(rootKey)
+	require.NoError(t, err)
+
+	h.assertLogContains(walletContent)
+	h.assertLogContains("BIP32 root key fingerprint:\t" + fingerprint)
+	h.assertLogContains("Watch-only:\t\t\tfalse")
+	h.assertLogContains("Next external address:")
+	require.NotContains(t, h.getLog(), rootKeyAezeed)
+}
+
+func TestWalletInfoPasswordFile(t *testing.T) {
+	h := newHarness(t)
+
+	// The password file takes precedence over the environment.
+	passwordFile := h.tempFile("password.txt")
+	err := ioutil.WriteFile(passwordFile, []byte(testPassPhrase+"\n"), 0600)
+	require.NoError(t, err)
+
+	info := &walletInfoCommand{
+		WalletDB:           h.testdataFile("wallet.db"),
+		WalletPasswordFile: passwordFile,
+	}
+
+	t.Setenv(passwordEnvName, "wrong password")
+
+	err = info.Execute(nil, nil)
+	require.NoError(t, err)
+
+	h.assertLogContains(walletContent)
+}
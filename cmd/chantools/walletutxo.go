@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/lnd"
+)
+
+var (
+	// errUtxoSpent is returned if a wallet UTXO was already spent
+	// according to the chain backend.
+	errUtxoSpent = errors.New("UTXO already spent")
+)
+
+// walletUtxo is an unspent P2WKH output of the default lnd wallet account.
+type walletUtxo struct {
+	outpoint  wire.OutPoint
+	value     int64
+	addr      btcutil.Address
+	path      string
+	confirmed bool
+}
+
+// walletAccountBranch returns the derivation path of the external (0) or
+// internal (1) branch of the default lnd wallet account (BIP84).
+func walletAccountBranch(branch uint32) string {
+	return fmt.Sprintf("m/84'/%d'/0'/%d", chainParams.HDCoinType, branch)
+}
+
+// walletBranchAddr derives the P2WKH address with the given index of the
+// branch with the given derivation path.
+func walletBranchAddr(extendedKey *hdkeychain.ExtendedKey, branchPath string,
+	index uint32) (btcutil.Address, string, error) {
+
+	path := fmt.Sprintf("%s/%d", branchPath, index)
+	_, pubKey, _, err := lnd.DeriveKey(extendedKey, path, chainParams)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not derive key %s: %w", path,
+			err)
+	}
+	addr, err := lnd.P2WKHAddr(pubKey, chainParams)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return addr, path, nil
+}
+
+// checkUnspent makes sure the given outpoint is unspent according to the
+// chain backend. It returns whether the transaction of the outpoint is
+// confirmed, or errUtxoSpent if the outpoint was already spent.
+func checkUnspent(api btc.ChainBackend, op wire.OutPoint) (bool, error) {
+	tx, err := api.Transaction(op.Hash.String())
+	if err != nil {
+		return false, fmt.Errorf("error fetching TX %v: %w", op.Hash,
+			err)
+	}
+	if int(op.Index) >= len(tx.Vout) {
+		return false, fmt.Errorf("invalid output index %d of TX %v",
+			op.Index, op.Hash)
+	}
+
+	outspend := tx.Vout[op.Index].Outspend
+	if outspend != nil && outspend.Spent {
+		return false, fmt.Errorf("%w: %v was spent by TX %s",
+			errUtxoSpent, op, outspend.Txid)
+	}
+
+	return tx.Status != nil && tx.Status.Confirmed, nil
+}
+
+// listWalletUtxos scans the external and internal branches of the default lnd
+// wallet account for unspent outputs. The external branch is scanned until
+// gapLimit and the internal branch until gapLimitInternal consecutive addresses
+// without unspent outputs were found, but never beyond the maxIndex. A maxIndex
+// of 0 means there is no limit. The UTXOs are returned sorted by value, largest
+// first.
+func listWalletUtxos(extendedKey *hdkeychain.ExtendedKey, api btc.ChainBackend,
+	gapLimit, gapLimitInternal, maxIndex uint32) ([]*walletUtxo, error) {
+
+	state, err := loadScanState("", extendedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var utxos []*walletUtxo
+	for branch, branchGapLimit := range []uint32{
+		gapLimit, gapLimitInternal,
+	} {
+		branchPath := walletAccountBranch(uint32(branch))
+		err := state.scan(
+			branchPath, branchGapLimit, branchGapLimit, maxIndex, 1,
+			scanWorkers(),
+			func(index uint32) (interface{}, uint64, error) {
+				return queryWalletUtxos(
+					extendedKey, api, branchPath, index,
+				)
+			},
+			func(_ uint32, result interface{}) {
+				utxos = append(
+					utxos, result.([]*walletUtxo)...,
+				)
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(utxos, func(i, j int) bool {
+		return utxos[i].value > utxos[j].value
+	})
+
+	return utxos, nil
+}
+
+// queryWalletUtxos returns the unspent outputs of the wallet address with the
+// given index and their total value.
+func queryWalletUtxos(extendedKey *hdkeychain.ExtendedKey, api btc.ChainBackend,
+	branchPath string, index uint32) ([]*walletUtxo, uint64, error) {
+
+	addr, path, err := walletBranchAddr(extendedKey, branchPath, index)
+	if err != nil {
+		return nil, 0, err
+	}
+	outputs, err := api.Unspent(addr.EncodeAddress())
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not query unspent: %w", err)
+	}
+
+	var (
+		utxos []*walletUtxo
+		funds uint64
+	)
+	for _, output := range outputs {
+		// The outpoint of an unspent output is reported in its
+		// outspend field.
+		hash, err := chainhash.NewHashFromStr(output.Outspend.Txid)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error parsing TXID: %w", err)
+		}
+		op := wire.OutPoint{
+			Hash:  *hash,
+			Index: uint32(output.Outspend.Vin),
+		}
+
+		// Some backends also report outputs that were spent already.
+		confirmed, err := checkUnspent(api, op)
+		if errors.Is(err, errUtxoSpent) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		utxos = append(utxos, &walletUtxo{
+			outpoint:  op,
+			value:     int64(output.Value),
+			addr:      addr,
+			path:      path,
+			confirmed: confirmed,
+		})
+		funds += output.Value
+	}
+
+	return utxos, funds, nil
+}
+
+// selectWalletUtxos lists the given wallet UTXOs and lets the user choose which
+// ones to spend. The user is asked again until the input is valid.
+func selectWalletUtxos(utxos []*walletUtxo,
+	reader *bufio.Reader) ([]wire.OutPoint, error) {
+
+	if len(utxos) == 0 {
+		return nil, fmt.Errorf("no unspent outputs found in the wallet")
+	}
+
+	fmt.Printf("Found %d wallet UTXO(s):\n", len(utxos))
+	for idx, utxo := range utxos {
+		status := ""
+		if !utxo.confirmed {
+			status = " (unconfirmed)"
+		}
+		fmt.Printf("  %d - %v: %d sats, %s (%s)%s\n", idx+1,
+			utxo.outpoint, utxo.value, utxo.addr.EncodeAddress(),
+			utxo.path, status)
+	}
+	fmt.Printf("\nEnter the numbers of the UTXOs to spend, separated " +
+		"by commas: ")
+
+	choice, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println()
+
+	selected, err := parseUtxoChoice(choice, len(utxos))
+	if err != nil {
+		// Let the user try again if they entered something incorrect.
+		fmt.Printf("Invalid choice: %v\n\n", err)
+		return selectWalletUtxos(utxos, reader)
+	}
+
+	outpoints := make([]wire.OutPoint, len(selected))
+	for idx, number := range selected {
+		outpoints[idx] = utxos[number-1].outpoint
+	}
+
+	return outpoints, nil
+}
+
+// parseUtxoChoice parses a comma separated list of the one-based numbers of
+// the chosen UTXOs.
+func parseUtxoChoice(choice string, numUtxos int) ([]int, error) {
+	var (
+		selected []int
+		seen     = make(map[int]bool)
+	)
+	for _, part := range strings.Split(choice, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", part)
+		}
+		if number < 1 || number > numUtxos {
+			return nil, fmt.Errorf("%d is not between 1 and %d",
+				number, numUtxos)
+		}
+		if seen[number] {
+			continue
+		}
+
+		seen[number] = true
+		selected = append(selected, number)
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("at least one UTXO must be chosen")
+	}
+
+	return selected, nil
+}
+
+// freshChangeAddr returns the first address of the internal branch of the
+// default lnd wallet account that never received any funds, searching at most
+// maxIndexes addresses.
+func freshChangeAddr(extendedKey *hdkeychain.ExtendedKey, api btc.ChainBackend,
+	maxIndexes uint32) (btcutil.Address, error) {
+
+	branchPath := walletAccountBranch(1)
+	for index := uint32(0); index < maxIndexes; index++ {
+		addr, path, err := walletBranchAddr(
+			extendedKey, branchPath, index,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		used, err := api.AddressUsed(addr.EncodeAddress())
+		if err != nil {
+			return nil, fmt.Errorf("error checking address %s: %w",
+				addr.EncodeAddress(), err)
+		}
+		if !used {
+			log.Infof("Using fresh wallet address %s (%s) for the "+
+				"change", addr.EncodeAddress(), path)
+			return addr, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no unused change address found in the first "+
+		"%d keys, try increasing --recoverywindow", maxIndexes)
+}
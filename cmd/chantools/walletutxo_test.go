@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/stretchr/testify/require"
+)
+
+// walletTestUtxo is an output of the fake chain backend of the wallet UTXO
+// tests.
+type walletTestUtxo struct {
+	addr      string
+	txid      string
+	vout      int
+	value     uint64
+	spent     bool
+	confirmed bool
+}
+
+// newWalletTestChain returns a fake chain backend that knows about the given
+// outputs, each in its own transaction.
+func newWalletTestChain(t *testing.T,
+	utxos []*walletTestUtxo) *fakeChainBackend {
+
+	chain := newFakeChainBackend()
+	for _, utxo := range utxos {
+		tx := &btc.TX{
+			TXID:   utxo.txid,
+			Status: &btc.Status{Confirmed: utxo.confirmed},
+		}
+		for idx := 0; idx <= utxo.vout; idx++ {
+			tx.Vout = append(tx.Vout, &btc.Vout{})
+		}
+		tx.Vout[utxo.vout].ScriptPubkeyAddr = utxo.addr
+		tx.Vout[utxo.vout].Value = utxo.value
+		chain.addAPITx(tx)
+
+		if utxo.spent {
+			hash, err := chainhash.NewHashFromStr(utxo.txid)
+			require.NoError(t, err)
+			chain.spend(t, wire.OutPoint{
+				Hash:  *hash,
+				Index: uint32(utxo.vout),
+			}, utxo.txid, nil)
+		}
+	}
+
+	return chain
+}
+
+func TestWalletUtxos(t *testing.T) {
+	_ = newHarness(t)
+
+	extendedKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	walletAddr := func(branch, index uint32) string {
+		addr, _, err := walletBranchAddr(
+			extendedKey, walletAccountBranch(branch), index,
+		)
+		require.NoError(t, err)
+		return addr.EncodeAddress()
+	}
+	txid := func(b byte) string {
+		return strings.Repeat(strconv.FormatInt(int64(b), 16), 64)
+	}
+
+	utxos := []*walletTestUtxo{{
+		addr:      walletAddr(0, 2),
+		txid:      txid(1),
+		vout:      0,
+		value:     20_000,
+		confirmed: true,
+	}, {
+		addr:  walletAddr(1, 0),
+		txid:  txid(2),
+		vout:  1,
+		value: 50_000,
+	}, {
+		// Some backends also report outputs that were spent already.
+		addr:      walletAddr(1, 1),
+		txid:      txid(3),
+		vout:      0,
+		value:     70_000,
+		spent:     true,
+		confirmed: true,
+	}, {
+		addr:      walletAddr(1, 1),
+		txid:      txid(4),
+		vout:      0,
+		value:     1_000,
+		confirmed: true,
+	}, {
+		// This one is only found with a large enough internal gap
+		// limit.
+		addr:      walletAddr(1, 5),
+		txid:      txid(6),
+		vout:      0,
+		value:     5_000,
+		confirmed: true,
+	}, {
+		// This one is beyond the scanned indexes.
+		addr:      walletAddr(0, 10),
+		txid:      txid(5),
+		vout:      0,
+		value:     90_000,
+		confirmed: true,
+	}}
+	api := newWalletTestChain(t, utxos)
+
+	found, err := listWalletUtxos(extendedKey, api, 3, 2, 9)
+	require.NoError(t, err)
+	require.Len(t, found, 3)
+	require.Equal(t, "m/84'/1'/0'/0/2", found[1].path)
+	require.EqualValues(t, 1_000, found[2].value)
+
+	found, err = listWalletUtxos(extendedKey, api, 20, 10, 9)
+	require.NoError(t, err)
+	require.Len(t, found, 4)
+	require.EqualValues(t, 5_000, found[2].value)
+	found = append(found[:2], found[3])
+
+	// The UTXOs are sorted by value, largest first.
+	require.EqualValues(t, 50_000, found[0].value)
+	require.Equal(t, "m/84'/1'/0'/1/0", found[0].path)
+	require.Equal(t, txid(2), found[0].outpoint.Hash.String())
+	require.EqualValues(t, 1, found[0].outpoint.Index)
+	require.False(t, found[0].confirmed)
+	require.EqualValues(t, 20_000, found[1].value)
+	require.Equal(t, "m/84'/1'/0'/0/2", found[1].path)
+	require.True(t, found[1].confirmed)
+	require.EqualValues(t, 1_000, found[2].value)
+
+	// Invalid choices are asked for again.
+	reader := bufio.NewReader(strings.NewReader("x\n4\n\n2, 1,2\n"))
+	selected, err := selectWalletUtxos(found, reader)
+	require.NoError(t, err)
+	require.Equal(t, []wire.OutPoint{
+		found[1].outpoint, found[0].outpoint,
+	}, selected)
+
+	_, err = selectWalletUtxos(nil, reader)
+	require.ErrorContains(t, err, "no unspent outputs found")
+
+	// The first two change addresses received funds already.
+	changeAddr, err := freshChangeAddr(extendedKey, api, 10)
+	require.NoError(t, err)
+	require.Equal(t, walletAddr(1, 2), changeAddr.EncodeAddress())
+
+	_, err = freshChangeAddr(extendedKey, api, 2)
+	require.ErrorContains(t, err, "no unused change address found")
+
+	// Spent outputs are detected.
+	hash, err := chainhash.NewHashFromStr(txid(3))
+	require.NoError(t, err)
+	_, err = checkUnspent(api, wire.OutPoint{Hash: *hash})
+	require.ErrorIs(t, err, errUtxoSpent)
+}
+
+func TestParseUtxoChoice(t *testing.T) {
+	selected, err := parseUtxoChoice(" 3,1, 3 ,\n", 3)
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 1}, selected)
+
+	_, err = parseUtxoChoice("1,abc", 3)
+	require.ErrorContains(t, err, `"abc" is not a number`)
+
+	_, err = parseUtxoChoice("0", 3)
+	require.ErrorContains(t, err, "0 is not between 1 and 3")
+
+	_, err = parseUtxoChoice(" \n", 3)
+	require.ErrorContains(t, err, "at least one UTXO must be chosen")
+}
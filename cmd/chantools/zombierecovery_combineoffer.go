@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/btc"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/spf13/cobra"
+)
+
+type zombieRecoveryCombineOfferCommand struct {
+	APIURL string
+	Psbts  []string
+
+	cmd *cobra.Command
+}
+
+func newZombieRecoveryCombineOfferCommand() *cobra.Command {
+	cc := &zombieRecoveryCombineOfferCommand{}
+	cc.cmd = &cobra.Command{
+		Use: "combineoffer",
+		Short: "[3/3] Optional: Combine the independently signed " +
+			"offers of both parties",
+		Long: `Combines the partially signed PSBTs of both parties into
+the final transaction. This is only needed if the offer was created with
+'makeoffer --unsigned' and each party signed it independently with the
+'signoffer' command.
+
+Before the final transaction is shown, the command verifies that all PSBTs are
+for the same transaction, that each input spends a 2-of-2 multisig output with
+the keys contained in the PSBT, that the funding output exists on chain with
+the same script and value and is still unspent and that both signatures of each
+input are valid.
+Nothing is published by this command.`,
+		Example: `chantools zombierecovery combineoffer \
+	--psbt <node1_signed_psbt_base64> \
+	--psbt <node2_signed_psbt_base64>`,
+		RunE: cc.Execute,
+	}
+
+	cc.cmd.Flags().StringVar(
+		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
+			"be esplora compatible)",
+	)
+	cc.cmd.Flags().StringSliceVar(
+		&cc.Psbts, "psbt", nil, "the base64 encoded PSBT signed by "+
+			"one of the parties, must be specified once for each "+
+			"party",
+	)
+
+	return cc.cmd
+}
+
+func (c *zombieRecoveryCombineOfferCommand) Execute(_ *cobra.Command,
+	_ []string) error {
+
+	if len(c.Psbts) < 2 {
+		return fmt.Errorf("need at least two signed PSBTs to combine")
+	}
+
+	packets := make([]*psbt.Packet, len(c.Psbts))
+	for idx, psbtStr := range c.Psbts {
+		var err error
+		packets[idx], err = psbt.NewFromRawBytes(
+			bytes.NewReader([]byte(psbtStr)), true,
+		)
+		if err != nil {
+			return fmt.Errorf("error decoding PSBT %d: %w", idx,
+				err)
+		}
+	}
+
+	packet, err := combineOffers(packets)
+	if err != nil {
+		return fmt.Errorf("error combining PSBTs: %w", err)
+	}
+
+	api, err := newChainBackend(c.APIURL)
+	if err != nil {
+		return err
+	}
+	if err := verifyFundingOutputs(api, packet); err != nil {
+		return err
+	}
+
+	finalTx, err := finalizeOffer(packet)
+	if err != nil {
+		return err
+	}
+
+	written, err := writeFinalTxOutput(packet, finalTx)
+	if err != nil || written {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = finalTx.Serialize(&buf)
+	if err != nil {
+		return fmt.Errorf("unable to serialize final TX: %w", err)
+	}
+
+	fmt.Printf("Success, we combined and verified the signatures of "+
+		"both parties and extracted\nthe final transaction. Please "+
+		"publish this using any bitcoin node:\n\n%x\n\n", buf.Bytes())
+
+	return nil
+}
+
+// combineOffers merges the partial signatures of all given PSBTs into one PSBT
+// and makes sure each input has valid signatures of both multisig keys.
+func combineOffers(packets []*psbt.Packet) (*psbt.Packet, error) {
+	combined := packets[0]
+	txHash := combined.UnsignedTx.TxHash()
+	for idx, packet := range packets[1:] {
+		if packet.UnsignedTx.TxHash() != txHash {
+			return nil, fmt.Errorf("PSBT %d is for transaction "+
+				"%v, expected %v", idx+1,
+				packet.UnsignedTx.TxHash(), txHash)
+		}
+
+		for inputIdx := range packet.Inputs {
+			pIn := &packet.Inputs[inputIdx]
+			cIn := &combined.Inputs[inputIdx]
+			if !bytes.Equal(pIn.WitnessScript, cIn.WitnessScript) {
+				return nil, fmt.Errorf("PSBT %d has different "+
+					"witness script for input %d", idx+1,
+					inputIdx)
+			}
+			if !sameTxOut(pIn.WitnessUtxo, cIn.WitnessUtxo) {
+				return nil, fmt.Errorf("PSBT %d has different "+
+					"witness UTXO for input %d", idx+1,
+					inputIdx)
+			}
+
+			cIn.PartialSigs = mergePartialSigs(
+				cIn.PartialSigs, pIn.PartialSigs,
+			)
+		}
+	}
+
+	for idx := range combined.Inputs {
+		if err := verifyOfferInput(combined, idx); err != nil {
+			return nil, fmt.Errorf("invalid input %d: %w", idx, err)
+		}
+	}
+
+	return combined, nil
+}
+
+// sameTxOut returns true if both outputs are set and have the same value and
+// script.
+func sameTxOut(a, b *wire.TxOut) bool {
+	return a != nil && b != nil && a.Value == b.Value &&
+		bytes.Equal(a.PkScript, b.PkScript)
+}
+
+// mergePartialSigs returns the partial signatures of both lists, without
+// duplicates for the same public key.
+func mergePartialSigs(sigs, newSigs []*psbt.PartialSig) []*psbt.PartialSig {
+	for _, newSig := range newSigs {
+		duplicate := false
+		for _, sig := range sigs {
+			if bytes.Equal(sig.PubKey, newSig.PubKey) {
+				duplicate = true
+				break
+			}
+		}
+
+		if !duplicate {
+			sigs = append(sigs, newSig)
+		}
+	}
+
+	return sigs
+}
+
+// verifyOfferInput makes sure the input with the given index spends a 2-of-2
+// multisig output and has a valid signature of each of the two keys.
+func verifyOfferInput(packet *psbt.Packet, idx int) error {
+	pIn := &packet.Inputs[idx]
+	if pIn.WitnessUtxo == nil {
+		return fmt.Errorf("witness UTXO missing")
+	}
+
+	keys, err := multisigScriptKeys(pIn.WitnessScript)
+	if err != nil {
+		return err
+	}
+	pkScript, err := input.WitnessScriptHash(pIn.WitnessScript)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(pkScript, pIn.WitnessUtxo.PkScript) {
+		return fmt.Errorf("witness UTXO script %x doesn't match the "+
+			"witness script", pIn.WitnessUtxo.PkScript)
+	}
+
+	prevOutFetcher := txscript.NewCannedPrevOutputFetcher(
+		pIn.WitnessUtxo.PkScript, pIn.WitnessUtxo.Value,
+	)
+	sigHashes := txscript.NewTxSigHashes(packet.UnsignedTx, prevOutFetcher)
+	sigHash, err := txscript.CalcWitnessSigHash(
+		pIn.WitnessScript, sigHashes, txscript.SigHashAll,
+		packet.UnsignedTx, idx, pIn.WitnessUtxo.Value,
+	)
+	if err != nil {
+		return fmt.Errorf("error calculating sighash: %w", err)
+	}
+
+	for _, key := range keys {
+		keyBytes := key.SerializeCompressed()
+
+		var partialSig *psbt.PartialSig
+		for _, sig := range pIn.PartialSigs {
+			if bytes.Equal(sig.PubKey, keyBytes) {
+				partialSig = sig
+				break
+			}
+		}
+		if partialSig == nil {
+			return fmt.Errorf("signature for key %x missing",
+				keyBytes)
+		}
+
+		sigLen := len(partialSig.Signature)
+		if sigLen == 0 || partialSig.Signature[sigLen-1] !=
+			byte(txscript.SigHashAll) {
+
+			return fmt.Errorf("signature for key %x is not "+
+				"SIGHASH_ALL", keyBytes)
+		}
+		sig, err := ecdsa.ParseDERSignature(
+			partialSig.Signature[:sigLen-1],
+		)
+		if err != nil {
+			return fmt.Errorf("error parsing signature for key "+
+				"%x: %w", keyBytes, err)
+		}
+		if !sig.Verify(sigHash, key) {
+			return fmt.Errorf("invalid signature for key %x",
+				keyBytes)
+		}
+	}
+
+	return nil
+}
+
+// multisigScriptKeys returns the two public keys of the given 2-of-2 multisig
+// witness script.
+func multisigScriptKeys(witnessScript []byte) ([]*btcec.PublicKey, error) {
+	class, addrs, reqSigs, err := txscript.ExtractPkScriptAddrs(
+		witnessScript, chainParams,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing witness script: %w", err)
+	}
+	if class != txscript.MultiSigTy || reqSigs != 2 || len(addrs) != 2 {
+		return nil, fmt.Errorf("witness script is not a 2-of-2 " +
+			"multisig script")
+	}
+
+	keys := make([]*btcec.PublicKey, len(addrs))
+	for idx, addr := range addrs {
+		pubKeyAddr, ok := addr.(*btcutil.AddressPubKey)
+		if !ok {
+			return nil, fmt.Errorf("invalid multisig key")
+		}
+		keys[idx] = pubKeyAddr.PubKey()
+	}
+
+	return keys, nil
+}
+
+// verifyFundingOutputs makes sure each input of the PSBT spends an unspent
+// output that exists on chain with the script and value that were signed.
+func verifyFundingOutputs(api btc.ChainBackend, packet *psbt.Packet) error {
+	for idx, txIn := range packet.UnsignedTx.TxIn {
+		op := txIn.PreviousOutPoint
+		tx, err := api.Transaction(op.Hash.String())
+		if err != nil {
+			return fmt.Errorf("error fetching funding transaction "+
+				"%v: %w", op.Hash, err)
+		}
+		if int(op.Index) >= len(tx.Vout) {
+			return fmt.Errorf("funding transaction %v has no "+
+				"output %d", op.Hash, op.Index)
+		}
+
+		vout := tx.Vout[op.Index]
+		utxo := packet.Inputs[idx].WitnessUtxo
+		if vout.ScriptPubkey != hex.EncodeToString(utxo.PkScript) ||
+			int64(vout.Value) != utxo.Value {
+
+			return fmt.Errorf("funding output %v doesn't match "+
+				"the witness UTXO of input %d", op, idx)
+		}
+		if vout.Outspend != nil && vout.Outspend.Spent {
+			return fmt.Errorf("funding output %v was already "+
+				"spent in transaction %s", op,
+				vout.Outspend.Txid)
+		}
+
+		log.Infof("Input %d spends funding output %v with %d sats",
+			idx, op, utxo.Value)
+	}
+
+	return nil
+}
+
+// finalizeOffer finalizes all inputs of the PSBT, extracts the final
+// transaction and runs it through the script engine to make sure it is valid.
+func finalizeOffer(packet *psbt.Packet) (*wire.MsgTx, error) {
+	err := psbt.MaybeFinalizeAll(packet)
+	if err != nil {
+		return nil, fmt.Errorf("error finalizing PSBT: %w", err)
+	}
+	finalTx, err := psbt.Extract(packet)
+	if err != nil {
+		return nil, fmt.Errorf("unable to extract final TX: %w", err)
+	}
+
+	prevOutFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for idx, txIn := range finalTx.TxIn {
+		prevOutFetcher.AddPrevOut(
+			txIn.PreviousOutPoint, packet.Inputs[idx].WitnessUtxo,
+		)
+	}
+	sigHashes := txscript.NewTxSigHashes(finalTx, prevOutFetcher)
+	for idx := range finalTx.TxIn {
+		utxo := packet.Inputs[idx].WitnessUtxo
+		vm, err := txscript.NewEngine(
+			utxo.PkScript, finalTx, idx,
+			txscript.StandardVerifyFlags, nil, sigHashes,
+			utxo.Value, prevOutFetcher,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error creating script engine: "+
+				"%w", err)
+		}
+		if err := vm.Execute(); err != nil {
+			return nil, fmt.Errorf("final TX input %d is invalid: "+
+				"%w", idx, err)
+		}
+	}
+
+	return finalTx, nil
+}
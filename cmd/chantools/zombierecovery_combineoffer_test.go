@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/guggero/chantools/lnd"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/stretchr/testify/require"
+)
+
+const zombieTestValue = 1_000_000
+
+// newZombieTestOffer creates an unsigned offer like 'makeoffer --unsigned'
+// does, spending a multisig output of the two given keys.
+func newZombieTestOffer(t *testing.T, makerKey *hdkeychain.ExtendedKey,
+	makerDesc, takerDesc *keychain.KeyDescriptor) (*psbt.Packet,
+	*wire.MsgTx) {
+
+	witnessScript, err := input.GenMultiSigScript(
+		makerDesc.PubKey.SerializeCompressed(),
+		takerDesc.PubKey.SerializeCompressed(),
+	)
+	require.NoError(t, err)
+	pkScript, err := input.WitnessScriptHash(witnessScript)
+	require.NoError(t, err)
+
+	fundingTx := wire.NewMsgTx(2)
+	fundingTx.TxOut = []*wire.TxOut{{
+		Value:    zombieTestValue,
+		PkScript: pkScript,
+	}}
+
+	tx := wire.NewMsgTx(2)
+	tx.TxIn = []*wire.TxIn{{
+		PreviousOutPoint: wire.OutPoint{Hash: fundingTx.TxHash()},
+	}}
+	tx.TxOut = []*wire.TxOut{{
+		Value:    zombieTestValue - 1_000,
+		PkScript: pkScript,
+	}}
+	packet, err := psbt.NewFromUnsignedTx(tx)
+	require.NoError(t, err)
+
+	derivation, err := bip32Derivation(makerKey, *makerDesc)
+	require.NoError(t, err)
+	packet.Inputs[0].WitnessScript = witnessScript
+	packet.Inputs[0].WitnessUtxo = fundingTx.TxOut[0]
+	packet.Inputs[0].Bip32Derivation = []*psbt.Bip32Derivation{derivation}
+	packet.Inputs[0].Unknowns = []*psbt.Unknown{{
+		Key:   PsbtKeyTypeOutputMissingSigPubkey,
+		Value: takerDesc.PubKey.SerializeCompressed(),
+	}}
+
+	return packet, fundingTx
+}
+
+func copyPacket(t *testing.T, packet *psbt.Packet) *psbt.Packet {
+	var buf bytes.Buffer
+	require.NoError(t, packet.Serialize(&buf))
+	packetCopy, err := psbt.NewFromRawBytes(&buf, false)
+	require.NoError(t, err)
+
+	return packetCopy
+}
+
+func TestZombieRecoveryCombineOffer(t *testing.T) {
+	_ = newHarness(t)
+
+	makerKey, err := hdkeychain.NewKeyFromString(rootKeyAezeed)
+	require.NoError(t, err)
+	takerKey, err := hdkeychain.NewKeyFromString(rootKeyBip39)
+	require.NoError(t, err)
+
+	deriveKey := func(key *hdkeychain.ExtendedKey,
+		index uint32) *keychain.KeyDescriptor {
+
+		ring := &lnd.HDKeyRing{
+			ExtendedKey: key,
+			ChainParams: chainParams,
+		}
+		desc, err := ring.DeriveKey(keychain.KeyLocator{
+			Family: keychain.KeyFamilyMultiSig,
+			Index:  index,
+		})
+		require.NoError(t, err)
+		return &desc
+	}
+	makerDesc, takerDesc := deriveKey(makerKey, 3), deriveKey(takerKey, 7)
+
+	offer, fundingTx := newZombieTestOffer(
+		t, makerKey, makerDesc, takerDesc,
+	)
+
+	// The chain backend knows the funding output the offer spends.
+	api := newFakeChainBackend()
+	api.addTx(t, fundingTx, nil)
+
+	// Both parties sign the same unsigned offer independently.
+	signedOffer := func(key *hdkeychain.ExtendedKey) *psbt.Packet {
+		packet := copyPacket(t, offer)
+		err := signOffer(key, packet, &lnd.Signer{
+			ExtendedKey: key,
+			ChainParams: chainParams,
+		}, api)
+		require.NoError(t, err)
+		require.Len(t, packet.Inputs[0].PartialSigs, 1)
+		return packet
+	}
+	makerSigned := signedOffer(makerKey)
+	takerSigned := signedOffer(takerKey)
+
+	// An offer that claims a different channel capacity than the chain
+	// backend is never signed.
+	wrongOffer := copyPacket(t, offer)
+	wrongOffer.Inputs[0].WitnessUtxo.Value++
+	err = signOffer(makerKey, wrongOffer, &lnd.Signer{
+		ExtendedKey: makerKey,
+		ChainParams: chainParams,
+	}, api)
+	require.ErrorContains(t, err, "refusing to sign")
+	require.Empty(t, wrongOffer.Inputs[0].PartialSigs)
+
+	// A single signature is not enough.
+	_, err = combineOffers([]*psbt.Packet{
+		makerSigned, copyPacket(t, makerSigned),
+	})
+	require.ErrorContains(t, err, "missing")
+
+	// A PSBT for a different transaction is rejected.
+	otherOffer := copyPacket(t, takerSigned)
+	otherOffer.UnsignedTx.TxOut[0].Value--
+	_, err = combineOffers([]*psbt.Packet{
+		copyPacket(t, makerSigned), otherOffer,
+	})
+	require.ErrorContains(t, err, "is for transaction")
+
+	combined, err := combineOffers([]*psbt.Packet{
+		makerSigned, takerSigned,
+	})
+	require.NoError(t, err)
+	require.Len(t, combined.Inputs[0].PartialSigs, 2)
+
+	// The funding output must exist on chain with the same script.
+	require.NoError(t, verifyFundingOutputs(api, combined))
+
+	wrongValue := copyPacket(t, combined)
+	wrongValue.Inputs[0].WitnessUtxo.Value++
+	require.ErrorContains(
+		t, verifyFundingOutputs(api, wrongValue), "doesn't match",
+	)
+
+	api.spend(
+		t, combined.UnsignedTx.TxIn[0].PreviousOutPoint,
+		combined.UnsignedTx.TxHash().String(), nil,
+	)
+	require.ErrorContains(
+		t, verifyFundingOutputs(api, combined), "already spent",
+	)
+
+	finalTx, err := finalizeOffer(combined)
+	require.NoError(t, err)
+	require.Len(t, finalTx.TxIn[0].Witness, 4)
+}
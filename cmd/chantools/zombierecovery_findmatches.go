@@ -10,7 +10,6 @@ import (
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/gogo/protobuf/jsonpb"
-	"github.com/guggero/chantools/btc"
 	"github.com/guggero/chantools/lnd"
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/spf13/cobra"
@@ -112,7 +111,10 @@ registered nodes.`,
 func (c *zombieRecoveryFindMatchesCommand) Execute(_ *cobra.Command,
 	_ []string) error {
 
-	api := &btc.ExplorerAPI{BaseURL: c.APIURL}
+	api, err := newChainBackend(c.APIURL)
+	if err != nil {
+		return err
+	}
 
 	logFileBytes, err := ioutil.ReadFile(c.Registrations)
 	if err != nil {
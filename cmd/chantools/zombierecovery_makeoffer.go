@@ -28,6 +28,8 @@ type zombieRecoveryMakeOfferCommand struct {
 	Node2   string
 	FeeRate uint16
 
+	Unsigned bool
+
 	rootKey *rootKey
 	cmd     *cobra.Command
 }
@@ -44,7 +46,13 @@ one party has to create an offer on how to split the funds that are in the
 channels to be rescued.
 If the other party agrees with the offer, they can sign and publish the offer
 with the 'signoffer' command. If the other party does not agree, they can create
-a counter offer.`,
+a counter offer.
+
+If the --unsigned flag is set, the offer is not signed. Instead, both parties
+sign the same unsigned offer independently of each other with the 'signoffer'
+command and then combine the two partially signed PSBTs with the
+'combineoffer' command. That way the two parties don't need to exchange the
+PSBT back and forth.`,
 		Example: `chantools zombierecovery makeoffer \
 	--node1_keys preparedkeys-xxxx-xx-xx-<pubkey1>.json \
 	--node2_keys preparedkeys-xxxx-xx-xx-<pubkey2>.json \
@@ -65,6 +73,12 @@ a counter offer.`,
 			"use for the sweep transaction in sat/vByte",
 	)
 
+	cc.cmd.Flags().BoolVar(
+		&cc.Unsigned, "unsigned", false, "don't sign the offer, "+
+			"both parties sign it independently with 'signoffer' "+
+			"and then combine the PSBTs with 'combineoffer'",
+	)
+
 	cc.rootKey = newRootKey(cc.cmd, "signing the offer")
 
 	return cc.cmd
@@ -419,6 +433,22 @@ channelLoop:
 				Index:  channel.ourKeyIndex,
 			},
 		}
+
+		// If the offer should be signed independently, we only add the
+		// information we need to find our key again later.
+		if c.Unsigned {
+			derivation, err := bip32Derivation(extendedKey, keyDesc)
+			if err != nil {
+				return fmt.Errorf("error deriving key of "+
+					"input %d: %w", idx, err)
+			}
+			packet.Inputs[idx].Bip32Derivation = append(
+				packet.Inputs[idx].Bip32Derivation, derivation,
+			)
+
+			continue
+		}
+
 		utxo := &wire.TxOut{
 			Value: channel.Capacity,
 		}
@@ -432,11 +462,24 @@ channelLoop:
 	}
 
 	// Looks like we're done!
+	written, err := writePsbtOutput(packet)
+	if err != nil || written {
+		return err
+	}
 	base64, err := packet.B64Encode()
 	if err != nil {
 		return fmt.Errorf("error encoding PSBT: %w", err)
 	}
 
+	if c.Unsigned {
+		fmt.Printf("Done creating unsigned offer, please send this "+
+			"PSBT string to \nthe other party to review and sign "+
+			"(if they accept) and sign it yourself\nwith the "+
+			"'signoffer' command: \n%s\n", base64)
+
+		return nil
+	}
+
 	fmt.Printf("Done creating offer, please send this PSBT string to \n"+
 		"the other party to review and sign (if they accept): \n%s\n",
 		base64)
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/guggero/chantools/btc"
+	"github.com/guggero/chantools/dump"
+	"github.com/spf13/cobra"
+)
+
+type zombieRecoveryMatchCommand struct {
+	APIURL        string
+	Node1Channels string
+	Node2Channels string
+
+	cmd *cobra.Command
+}
+
+func newZombieRecoveryMatchCommand() *cobra.Command {
+	cc := &zombieRecoveryMatchCommand{}
+	cc.cmd = &cobra.Command{
+		Use: "match",
+		Short: "[0/3] Find the channels two nodes share from their " +
+			"channel dumps",
+		Long: `Instead of waiting for the match maker, two nodes that
+still have (possibly outdated) channel databases can find the channels they
+share themselves. Each node runs 'chantools dumpchannels --json' and sends the
+output to the other party. This command then compares the two dumps and lists
+all channels that appear in both, matched by funding outpoint and node public
+keys, together with their capacity and a suggested split of the funds based on
+the channel balances of the two nodes.
+
+If the two nodes don't agree on the balance of a channel, the state with the
+higher commitment height is used for the suggested split. Channels with the
+other node that only appear in one of the dumps are listed separately, they
+can't be recovered using the zombie recovery process.
+
+The shared channels are written to a match file that can be used with the
+'preparekeys' command in the next step.`,
+		Example: `chantools zombierecovery match \
+	--node1_channels dumpchannels-node1.json \
+	--node2_channels dumpchannels-node2.json`,
+		RunE: cc.Execute,
+	}
+
+	cc.cmd.Flags().StringVar(
+		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
+			"be esplora compatible)",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.Node1Channels, "node1_channels", "", "the JSON output of "+
+			"'chantools dumpchannels --json' of node 1",
+	)
+	cc.cmd.Flags().StringVar(
+		&cc.Node2Channels, "node2_channels", "", "the JSON output of "+
+			"'chantools dumpchannels --json' of node 2",
+	)
+
+	return cc.cmd
+}
+
+func (c *zombieRecoveryMatchCommand) Execute(_ *cobra.Command,
+	_ []string) error {
+
+	node1Channels, err := readChannelDump(c.Node1Channels)
+	if err != nil {
+		return fmt.Errorf("error reading node 1 channels: %w", err)
+	}
+	node2Channels, err := readChannelDump(c.Node2Channels)
+	if err != nil {
+		return fmt.Errorf("error reading node 2 channels: %w", err)
+	}
+
+	result, err := matchChannelDumps(node1Channels, node2Channels)
+	if err != nil {
+		return err
+	}
+
+	summary := result.summary()
+	fmt.Println(summary)
+
+	// For the tests, also log as trace level which is disabled by default.
+	log.Tracef(summary)
+
+	api, err := newChainBackend(c.APIURL)
+	if err != nil {
+		return err
+	}
+	matchBytes, err := json.MarshalIndent(result.matchFile(api), "", " ")
+	if err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("results/match-%s-%s-%s.json",
+		time.Now().Format("2006-01-02"), result.node1PubKey,
+		result.node2PubKey)
+	log.Infof("Writing result to %s", fileName)
+	return ioutil.WriteFile(fileName, matchBytes, 0644)
+}
+
+// sharedChannel is a channel that appears in the channel dumps of both nodes.
+type sharedChannel struct {
+	node1View *dump.OpenChannelJSON
+	node2View *dump.OpenChannelJSON
+
+	node1Split int64
+	node2Split int64
+	warnings   []string
+}
+
+// channelDumpMatch is the result of comparing the channel dumps of two nodes.
+type channelDumpMatch struct {
+	node1PubKey string
+	node2PubKey string
+
+	shared    []*sharedChannel
+	onlyNode1 []*dump.OpenChannelJSON
+	onlyNode2 []*dump.OpenChannelJSON
+}
+
+// readChannelDump reads a file that contains the JSON output of the
+// dumpchannels command. Because the output is usually redirected to a file,
+// any log lines before the JSON array are skipped.
+func readChannelDump(fileName string) ([]*dump.OpenChannelJSON, error) {
+	dumpBytes, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	start := bytes.Index(dumpBytes, []byte("\n["))
+	if !bytes.HasPrefix(dumpBytes, []byte("[")) && start >= 0 {
+		dumpBytes = dumpBytes[start+1:]
+	}
+
+	var channels []*dump.OpenChannelJSON
+	decoder := json.NewDecoder(bytes.NewReader(dumpBytes))
+	if err := decoder.Decode(&channels); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", fileName, err)
+	}
+
+	return channels, nil
+}
+
+// matchChannelDumps finds all channels that appear in both channel dumps. The
+// identity public keys of the nodes are derived from the remote public keys of
+// the shared channels.
+func matchChannelDumps(node1Channels,
+	node2Channels []*dump.OpenChannelJSON) (*channelDumpMatch, error) {
+
+	node2ByOutpoint := make(map[string]*dump.OpenChannelJSON)
+	for _, channel := range node2Channels {
+		node2ByOutpoint[channel.FundingOutpoint] = channel
+	}
+
+	result := &channelDumpMatch{}
+	for _, channel := range node1Channels {
+		other, ok := node2ByOutpoint[channel.FundingOutpoint]
+		if !ok {
+			continue
+		}
+
+		// Each node sees the other one as the remote node, so all
+		// shared channels must point to the same two keys.
+		switch {
+		case result.node1PubKey == "":
+			result.node1PubKey = other.RemotePubkey
+			result.node2PubKey = channel.RemotePubkey
+
+		case result.node1PubKey != other.RemotePubkey ||
+			result.node2PubKey != channel.RemotePubkey:
+
+			return nil, fmt.Errorf("channel %s is with a "+
+				"different node than the other shared "+
+				"channels, make sure both dumps are from the "+
+				"two nodes that want to recover funds",
+				channel.FundingOutpoint)
+		}
+
+		result.shared = append(
+			result.shared, newSharedChannel(channel, other),
+		)
+	}
+
+	if len(result.shared) == 0 {
+		return nil, fmt.Errorf("the two nodes don't share any channels")
+	}
+	if result.node1PubKey == result.node2PubKey {
+		return nil, fmt.Errorf("both dumps are from the same node %s",
+			result.node1PubKey)
+	}
+
+	// Now find the channels with the other node that only one of the two
+	// nodes knows about.
+	node1ByOutpoint := make(map[string]*dump.OpenChannelJSON)
+	for _, channel := range node1Channels {
+		node1ByOutpoint[channel.FundingOutpoint] = channel
+
+		_, ok := node2ByOutpoint[channel.FundingOutpoint]
+		if !ok && channel.RemotePubkey == result.node2PubKey {
+			result.onlyNode1 = append(result.onlyNode1, channel)
+		}
+	}
+	for _, channel := range node2Channels {
+		_, ok := node1ByOutpoint[channel.FundingOutpoint]
+		if !ok && channel.RemotePubkey == result.node1PubKey {
+			result.onlyNode2 = append(result.onlyNode2, channel)
+		}
+	}
+
+	return result, nil
+}
+
+// newSharedChannel compares the two views of the same channel and suggests
+// how to split the funds of the channel.
+func newSharedChannel(node1View,
+	node2View *dump.OpenChannelJSON) *sharedChannel {
+
+	shared := &sharedChannel{
+		node1View: node1View,
+		node2View: node2View,
+	}
+
+	if node1View.Capacity != node2View.Capacity {
+		shared.warnings = append(shared.warnings, fmt.Sprintf(
+			"capacity differs (node 1: %d sats, node 2: %d sats)",
+			node1View.Capacity, node2View.Capacity,
+		))
+	}
+
+	node1Local := int64(node1View.LocalBalanceMSat / 1000)
+	node1Remote := int64(node1View.RemoteBalanceMSat / 1000)
+	node2Local := int64(node2View.LocalBalanceMSat / 1000)
+	node2Remote := int64(node2View.RemoteBalanceMSat / 1000)
+
+	switch {
+	case node1Local == node2Remote && node1Remote == node2Local:
+		shared.node1Split, shared.node2Split = node1Local, node2Local
+
+	case node1View.LocalCommitHeight >= node2View.LocalCommitHeight:
+		shared.node1Split, shared.node2Split = node1Local, node1Remote
+		shared.warnings = append(shared.warnings, fmt.Sprintf(
+			"balances differ, using the state of node 1 with the "+
+				"higher commitment height %d (node 2 is at %d)",
+			node1View.LocalCommitHeight,
+			node2View.LocalCommitHeight,
+		))
+
+	default:
+		shared.node1Split, shared.node2Split = node2Remote, node2Local
+		shared.warnings = append(shared.warnings, fmt.Sprintf(
+			"balances differ, using the state of node 2 with the "+
+				"higher commitment height %d (node 1 is at %d)",
+			node2View.LocalCommitHeight,
+			node1View.LocalCommitHeight,
+		))
+	}
+
+	return shared
+}
+
+// summary returns a human readable summary of the match.
+func (m *channelDumpMatch) summary() string {
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Node 1: %s\nNode 2: %s\n\n",
+		m.node1PubKey, m.node2PubKey))
+
+	var totalCapacity, totalNode1, totalNode2 int64
+	result.WriteString(fmt.Sprintf("Shared channels (%d):\n",
+		len(m.shared)))
+	for _, shared := range m.shared {
+		channel := shared.node1View
+		totalCapacity += channel.Capacity
+		totalNode1 += shared.node1Split
+		totalNode2 += shared.node2Split
+
+		result.WriteString(fmt.Sprintf("\tChannel %s (%s): capacity "+
+			"%d sats, suggested split: %d sats to node 1, %d sats "+
+			"to node 2\n", channel.FundingOutpoint,
+			channel.ShortChannelID, channel.Capacity,
+			shared.node1Split, shared.node2Split))
+		for _, warning := range shared.warnings {
+			result.WriteString(fmt.Sprintf("\t\tWARNING: %s\n",
+				warning))
+		}
+	}
+	result.WriteString(fmt.Sprintf("\tTotal: capacity %d sats, "+
+		"suggested split: %d sats to node 1, %d sats to node 2 "+
+		"(before fees)\n", totalCapacity, totalNode1, totalNode2))
+
+	writeOneSided := func(name string, channels []*dump.OpenChannelJSON) {
+		if len(channels) == 0 {
+			return
+		}
+
+		result.WriteString(fmt.Sprintf("\nChannels only %s knows "+
+			"about (%d), these can't be recovered together:\n",
+			name, len(channels)))
+		for _, channel := range channels {
+			result.WriteString(fmt.Sprintf("\tChannel %s (%s): "+
+				"capacity %d sats\n", channel.FundingOutpoint,
+				channel.ShortChannelID, channel.Capacity))
+		}
+	}
+	writeOneSided("node 1", m.onlyNode1)
+	writeOneSided("node 2", m.onlyNode2)
+
+	return result.String()
+}
+
+// matchFile returns the shared channels in the format of the match file the
+// next step of the zombie recovery ('preparekeys') expects. The funding
+// address of each channel is looked up using the given API.
+func (m *channelDumpMatch) matchFile(api btc.ChainBackend) *match {
+	result := &match{
+		Node1:    &nodeInfo{PubKey: m.node1PubKey},
+		Node2:    &nodeInfo{PubKey: m.node2PubKey},
+		Channels: make([]*channel, len(m.shared)),
+	}
+	for idx, shared := range m.shared {
+		c := &channel{
+			ChannelID: fmt.Sprintf("%d", shared.node1View.ChanID),
+			ChanPoint: shared.node1View.FundingOutpoint,
+			Capacity:  shared.node1View.Capacity,
+		}
+
+		addr, err := api.Address(c.ChanPoint)
+		if err == nil {
+			c.Address = addr
+		} else {
+			log.Warnf("Could not look up funding address of "+
+				"channel %s: %v", c.ChanPoint, err)
+		}
+
+		result.Channels[idx] = c
+	}
+
+	return result
+}
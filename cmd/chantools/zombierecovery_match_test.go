@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/guggero/chantools/dump"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchChannelDumps(t *testing.T) {
+	h := newHarness(t)
+
+	const (
+		node1 = "02aa"
+		node2 = "03bb"
+		node3 = "03cc"
+	)
+	newChannel := func(outpoint, remote string, capacity int64, local,
+		localHeight uint64) *dump.OpenChannelJSON {
+
+		return &dump.OpenChannelJSON{
+			FundingOutpoint:   outpoint,
+			ShortChannelID:    "1:2:0",
+			RemotePubkey:      remote,
+			Capacity:          capacity,
+			LocalBalanceMSat:  local * 1000,
+			RemoteBalanceMSat: (uint64(capacity) - local) * 1000,
+			LocalCommitHeight: localHeight,
+		}
+	}
+
+	node1Channels := []*dump.OpenChannelJSON{
+		newChannel("aa:0", node2, 100_000, 60_000, 5),
+		newChannel("bb:1", node2, 200_000, 50_000, 10),
+		newChannel("cc:0", node3, 300_000, 150_000, 1),
+		newChannel("dd:0", node2, 400_000, 200_000, 1),
+	}
+	node2Channels := []*dump.OpenChannelJSON{
+		newChannel("aa:0", node1, 100_000, 40_000, 5),
+		newChannel("bb:1", node1, 200_000, 170_000, 7),
+		newChannel("ee:0", node1, 500_000, 250_000, 1),
+	}
+
+	result, err := matchChannelDumps(node1Channels, node2Channels)
+	require.NoError(t, err)
+	require.Equal(t, node1, result.node1PubKey)
+	require.Equal(t, node2, result.node2PubKey)
+
+	require.Len(t, result.shared, 2)
+	require.EqualValues(t, 60_000, result.shared[0].node1Split)
+	require.EqualValues(t, 40_000, result.shared[0].node2Split)
+	require.Empty(t, result.shared[0].warnings)
+
+	// The balances of the second channel differ, node 1 has the higher
+	// commitment height.
+	require.EqualValues(t, 50_000, result.shared[1].node1Split)
+	require.EqualValues(t, 150_000, result.shared[1].node2Split)
+	require.Len(t, result.shared[1].warnings, 1)
+
+	// The channel with node 3 isn't listed as one-sided.
+	require.Len(t, result.onlyNode1, 1)
+	require.Equal(t, "dd:0", result.onlyNode1[0].FundingOutpoint)
+	require.Len(t, result.onlyNode2, 1)
+	require.Equal(t, "ee:0", result.onlyNode2[0].FundingOutpoint)
+
+	log.Tracef(result.summary())
+	h.assertLogContains("Shared channels (2):")
+	h.assertLogContains("suggested split: 110000 sats to node 1, " +
+		"190000 sats to node 2 (before fees)")
+	h.assertLogContains("Channels only node 1 knows about (1)")
+	h.assertLogContains("Channels only node 2 knows about (1)")
+
+	// Dumps without shared channels or with channels of different nodes
+	// are rejected.
+	_, err = matchChannelDumps(node1Channels[2:3], node2Channels)
+	require.ErrorContains(t, err, "don't share any channels")
+
+	node2Channels[1].RemotePubkey = node3
+	_, err = matchChannelDumps(node1Channels, node2Channels)
+	require.ErrorContains(t, err, "different node")
+}
+
+func TestReadChannelDump(t *testing.T) {
+	h := newHarness(t)
+
+	channels := []*dump.OpenChannelJSON{{
+		FundingOutpoint: "aa:0",
+		Capacity:        100_000,
+	}}
+	channelBytes, err := json.MarshalIndent(channels, "", " ")
+	require.NoError(t, err)
+
+	// The log line of the version is skipped.
+	fileName := h.tempFile("dump.json")
+	content := append(
+		[]byte("2022-01-01 12:00:00.000 [INF] CHAN: chantools "+
+			"version v0.10.0\n"), channelBytes...,
+	)
+	require.NoError(t, ioutil.WriteFile(fileName, content, 0644))
+
+	readChannels, err := readChannelDump(fileName)
+	require.NoError(t, err)
+	require.Equal(t, channels, readChannels)
+}
@@ -33,9 +33,11 @@ for more information on how to use these commands.`,
 		// Here the order matters, we don't want them to be
 		// alphabetically sorted but by step number.
 		newZombieRecoveryFindMatchesCommand(),
+		newZombieRecoveryMatchCommand(),
 		newZombieRecoveryPrepareKeysCommand(),
 		newZombieRecoveryMakeOfferCommand(),
 		newZombieRecoverySignOfferCommand(),
+		newZombieRecoveryCombineOfferCommand(),
 	)
 
 	return cc.cmd
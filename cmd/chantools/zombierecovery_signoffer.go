@@ -10,13 +10,15 @@ import (
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/btcutil/psbt"
 	"github.com/btcsuite/btcd/txscript"
+	"github.com/guggero/chantools/btc"
 	"github.com/guggero/chantools/lnd"
 	"github.com/lightningnetwork/lnd/keychain"
 	"github.com/spf13/cobra"
 )
 
 type zombieRecoverySignOfferCommand struct {
-	Psbt string
+	Psbt   string
+	APIURL string
 
 	rootKey *rootKey
 	cmd     *cobra.Command
@@ -29,7 +31,15 @@ func newZombieRecoverySignOfferCommand() *cobra.Command {
 		Short: "[3/3] Sign an offer sent by the remote peer to " +
 			"recover funds",
 		Long: `Inspect and sign an offer that was sent by the remote
-peer to recover funds from one or more channels.`,
+peer to recover funds from one or more channels.
+
+If the offer was created with 'makeoffer --unsigned', the signature of the
+other party is still missing after signing. In that case the partially signed
+PSBT is printed instead of the final transaction and must be combined with the
+PSBT signed by the other party using the 'combineoffer' command.
+
+Before anything is signed, the amount and script of every channel output the
+offer spends are verified with the chain backend.`,
 		Example: `chantools zombierecovery signoffer \
 	--psbt <offered_psbt_base64>`,
 		RunE: cc.Execute,
@@ -39,6 +49,10 @@ peer to recover funds from one or more channels.`,
 		&cc.Psbt, "psbt", "", "the base64 encoded PSBT that the other "+
 			"party sent as an offer to rescue funds",
 	)
+	cc.cmd.Flags().StringVar(
+		&cc.APIURL, "apiurl", defaultAPIURL, "API URL to use (must "+
+			"be esplora compatible)",
+	)
 
 	cc.rootKey = newRootKey(cc.cmd, "signing the offer")
 
@@ -66,11 +80,82 @@ func (c *zombieRecoverySignOfferCommand) Execute(_ *cobra.Command,
 		return fmt.Errorf("error decoding PSBT: %w", err)
 	}
 
-	return signOffer(extendedKey, packet, signer)
+	api, err := newChainBackend(c.APIURL)
+	if err != nil {
+		return err
+	}
+
+	return signOffer(extendedKey, packet, signer, api)
+}
+
+// findOfferKey returns the key descriptor of our multisig key of the given
+// offer input. If the offer was signed by the other party, the key we need to
+// sign with is in the unknown field. If the offer was created unsigned, the
+// key of the party that created it is in the BIP32 derivation info. As a last
+// resort, we look for both keys of the multisig script.
+func findOfferKey(rootKey, multisigBranch *hdkeychain.ExtendedKey,
+	pIn *psbt.PInput) (*keychain.KeyDescriptor, error) {
+
+	for _, derivation := range pIn.Bip32Derivation {
+		if len(derivation.Bip32Path) != 5 {
+			continue
+		}
+
+		derivedKey, err := lnd.DeriveChildren(
+			rootKey, derivation.Bip32Path,
+		)
+		if err != nil {
+			return nil, err
+		}
+		pubKey, err := derivedKey.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+		derivedPubKey := pubKey.SerializeCompressed()
+		if !bytes.Equal(derivedPubKey, derivation.PubKey) {
+			continue
+		}
+
+		return &keychain.KeyDescriptor{
+			PubKey: pubKey,
+			KeyLocator: keychain.KeyLocator{
+				Family: keychain.KeyFamilyMultiSig,
+				Index:  derivation.Bip32Path[4],
+			},
+		}, nil
+	}
+
+	scriptKeys, err := multisigScriptKeys(pIn.WitnessScript)
+	if err != nil {
+		return nil, err
+	}
+
+	// The unknown field contains the key that is still missing a
+	// signature, so we try that one first, it's most likely ours.
+	secondKey := scriptKeys[1].SerializeCompressed()
+	for _, unknown := range pIn.Unknowns {
+		isMissingSigKey := bytes.Equal(
+			unknown.Key, PsbtKeyTypeOutputMissingSigPubkey,
+		)
+		if isMissingSigKey && bytes.Equal(unknown.Value, secondKey) {
+			scriptKeys = []*btcec.PublicKey{
+				scriptKeys[1], scriptKeys[0],
+			}
+		}
+	}
+
+	for _, scriptKey := range scriptKeys {
+		keyDesc, err := findLocalMultisigKey(multisigBranch, scriptKey)
+		if err == nil {
+			return keyDesc, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no matching pubkeys found")
 }
 
 func signOffer(rootKey *hdkeychain.ExtendedKey,
-	packet *psbt.Packet, signer *lnd.Signer) error {
+	packet *psbt.Packet, signer *lnd.Signer, api btc.ChainBackend) error {
 
 	// First, we need to derive the correct branch from the local root key.
 	localMultisig, err := lnd.DeriveChildren(rootKey, []uint32{
@@ -98,6 +183,13 @@ func signOffer(rootKey *hdkeychain.ExtendedKey,
 		}
 	}
 
+	// Make sure we only ever sign for the channel outputs that are
+	// actually on chain, before showing the proposal.
+	err = validatePsbt(api, packet, multiSigVSize(packet.UnsignedTx), 0)
+	if err != nil {
+		return fmt.Errorf("refusing to sign: %w", err)
+	}
+
 	fmt.Printf("The PSBT contains the following proposal:\n\n\t"+
 		"Close %d channels: \n", len(packet.Inputs))
 	var totalInput int64
@@ -135,21 +227,11 @@ func signOffer(rootKey *hdkeychain.ExtendedKey,
 				"key %x, expected %x", unknown.Key,
 				PsbtKeyTypeOutputMissingSigPubkey)
 		}
-		targetKey, err := btcec.ParsePubKey(unknown.Value)
-		if err != nil {
+		if _, err := btcec.ParsePubKey(unknown.Value); err != nil {
 			return fmt.Errorf("invalid PSBT, proprietary key has "+
 				"invalid pubkey: %w", err)
 		}
 
-		// Now we can look up the local key and check the PSBT further,
-		// then add our signature.
-		localKeyDesc, err := findLocalMultisigKey(
-			localMultisig, targetKey,
-		)
-		if err != nil {
-			return fmt.Errorf("could not find local multisig key: "+
-				"%w", err)
-		}
 		if len(packet.Inputs[idx].WitnessScript) == 0 {
 			return fmt.Errorf("invalid PSBT, missing witness " +
 				"script")
@@ -160,6 +242,16 @@ func signOffer(rootKey *hdkeychain.ExtendedKey,
 		}
 		utxo := packet.Inputs[idx].WitnessUtxo
 
+		// Now we can look up the local key and check the PSBT further,
+		// then add our signature.
+		localKeyDesc, err := findOfferKey(
+			rootKey, localMultisig, &packet.Inputs[idx],
+		)
+		if err != nil {
+			return fmt.Errorf("could not find local multisig key: "+
+				"%w", err)
+		}
+
 		err = signer.AddPartialSignature(
 			packet, *localKeyDesc, utxo, witnessScript, idx,
 		)
@@ -169,16 +261,42 @@ func signOffer(rootKey *hdkeychain.ExtendedKey,
 		}
 	}
 
+	// If the offer was created unsigned, the other party signs it
+	// independently and the signatures are combined in the next step.
+	for idx := range packet.Inputs {
+		if len(packet.Inputs[idx].PartialSigs) < 2 {
+			written, err := writePsbtOutput(packet)
+			if err != nil || written {
+				return err
+			}
+			base64, err := packet.B64Encode()
+			if err != nil {
+				return fmt.Errorf("error encoding PSBT: %w",
+					err)
+			}
+
+			fmt.Printf("Success, we signed the PSBT. The "+
+				"signature of the other party is still\n"+
+				"missing. Please combine this PSBT with the "+
+				"one signed by the other party\nusing the "+
+				"'combineoffer' command:\n\n%s\n\n", base64)
+
+			return nil
+		}
+	}
+
 	// We're almost done. Now we just need to make sure we can finalize and
 	// extract the final TX.
-	err = psbt.MaybeFinalizeAll(packet)
+	finalTx, err := finalizeOffer(packet)
 	if err != nil {
-		return fmt.Errorf("error finalizing PSBT: %w", err)
+		return err
 	}
-	finalTx, err := psbt.Extract(packet)
-	if err != nil {
-		return fmt.Errorf("unable to extract final TX: %w", err)
+
+	written, err := writeFinalTxOutput(packet, finalTx)
+	if err != nil || written {
+		return err
 	}
+
 	var buf bytes.Buffer
 	err = finalTx.Serialize(&buf)
 	if err != nil {
@@ -55,6 +55,7 @@ type ListChannelsChannel struct {
 	Initiator     bool         `json:"initiator"`
 	LocalBalance  NumberString `json:"local_balance"`
 	RemoteBalance NumberString `json:"remote_balance"`
+	CSVDelay      NumberString `json:"csv_delay"`
 }
 
 func (c *ListChannelsChannel) AsSummaryEntry() *SummaryEntry {
@@ -67,6 +68,7 @@ func (c *ListChannelsChannel) AsSummaryEntry() *SummaryEntry {
 		Initiator:      c.Initiator,
 		LocalBalance:   uint64(c.LocalBalance),
 		RemoteBalance:  uint64(c.RemoteBalance),
+		CSVDelay:       uint16(c.CSVDelay),
 	}
 }
 
@@ -150,6 +152,7 @@ func (c *ChannelDBFile) AsSummaryEntries() ([]*SummaryEntry, error) {
 			RemoteBalance: uint64(
 				channel.LocalCommitment.RemoteBalance.ToSatoshis(),
 			),
+			CSVDelay: channel.LocalChanCfg.CsvDelay,
 		}
 	}
 	return result, nil
@@ -16,6 +16,21 @@ type ClosingTX struct {
 	ToRemoteAddr string `json:"to_remote_addr"`
 	SweepPrivkey string `json:"sweep_privkey"`
 	ConfHeight   uint32 `json:"conf_height"`
+
+	// CSVDelay is the relative time lock of our to_local output, if the
+	// channel was force closed and the delay is known.
+	CSVDelay uint16 `json:"csv_delay,omitempty"`
+
+	// MatureHeight is the height of the first block our to_local output
+	// can be spent in.
+	MatureHeight uint32 `json:"mature_height,omitempty"`
+
+	// BlocksUntilMature is the number of blocks that need to be mined
+	// before our to_local output can be spent in the next block.
+	BlocksUntilMature uint32 `json:"blocks_until_mature,omitempty"`
+
+	// Mature is set if our to_local output can be spent in the next block.
+	Mature bool `json:"mature,omitempty"`
 }
 
 type BasePoint struct {
@@ -54,6 +69,7 @@ type Out struct {
 type ForceClose struct {
 	TXID                string     `json:"txid"`
 	Serialized          string     `json:"serialized"`
+	Psbt                string     `json:"psbt,omitempty"`
 	CSVDelay            uint16     `json:"csv_delay"`
 	DelayBasePoint      *BasePoint `json:"delay_basepoint"`
 	RevocationBasePoint *BasePoint `json:"revocation_basepoint"`
@@ -74,6 +90,47 @@ type SummaryEntry struct {
 	HasPotential   bool        `json:"has_potential_funds"`
 	ClosingTX      *ClosingTX  `json:"closing_tx,omitempty"`
 	ForceClose     *ForceClose `json:"force_close"`
+
+	// CSVDelay is the relative time lock of our to_local output in case
+	// we force close the channel, if the input contains it.
+	CSVDelay uint16 `json:"csv_delay,omitempty"`
+}
+
+// ToLocalCSVDelay returns the relative time lock of our to_local output, from
+// the channel itself or from the force close information. Zero is returned if
+// the delay is unknown.
+func (e *SummaryEntry) ToLocalCSVDelay() uint16 {
+	if e.CSVDelay == 0 && e.ForceClose != nil {
+		return e.ForceClose.CSVDelay
+	}
+
+	return e.CSVDelay
+}
+
+// UpdateMaturity calculates when the to_local output of a force closed channel
+// matures, given the height of the current best block. Nothing is done if the
+// channel wasn't force closed, the closing transaction is not confirmed yet or
+// the CSV delay is unknown.
+func (e *SummaryEntry) UpdateMaturity(tipHeight uint32) {
+	closingTx := e.ClosingTX
+	csvDelay := e.ToLocalCSVDelay()
+	if closingTx == nil || !closingTx.ForceClose ||
+		closingTx.ConfHeight == 0 || csvDelay == 0 {
+
+		return
+	}
+
+	closingTx.CSVDelay = csvDelay
+	closingTx.MatureHeight = closingTx.ConfHeight + uint32(csvDelay)
+
+	// A transaction that is published now can be included in the next
+	// block at the earliest.
+	closingTx.Mature = tipHeight+1 >= closingTx.MatureHeight
+	closingTx.BlocksUntilMature = 0
+	if !closingTx.Mature {
+		closingTx.BlocksUntilMature = closingTx.MatureHeight -
+			tipHeight - 1
+	}
 }
 
 type SummaryEntryFile struct {
@@ -90,4 +147,69 @@ type SummaryEntryFile struct {
 	FundsClosedSpent      uint64          `json:"funds_closed_channels_spent"`
 	FundsForceClose       uint64          `json:"funds_force_closed_maybe_ours"`
 	FundsCoopClose        uint64          `json:"funds_coop_closed_maybe_ours"`
+	BlockHeight           uint32          `json:"block_height,omitempty"`
+}
+
+const (
+	// CloseTypeNone is the close type of a channel that is still open.
+	CloseTypeNone = "none"
+
+	// CloseTypeCooperative is the close type of a cooperatively closed
+	// channel.
+	CloseTypeCooperative = "cooperative"
+
+	// CloseTypeForce is the close type of a force-closed channel.
+	CloseTypeForce = "force"
+
+	// CloseTypeUnknown is the close type of a channel whose funding
+	// transaction could not be found on chain.
+	CloseTypeUnknown = "unknown"
+
+	// SweepStatusNotClosed is the sweep status of a channel that is not
+	// closed yet.
+	SweepStatusNotClosed = "not_closed"
+
+	// SweepStatusSpent is the sweep status of a closed channel with all
+	// outputs of the closing transaction spent.
+	SweepStatusSpent = "spent"
+
+	// SweepStatusUnswept is the sweep status of a closed channel with
+	// unspent outputs that potentially belong to us.
+	SweepStatusUnswept = "unswept"
+
+	// SweepStatusNotOurs is the sweep status of a closed channel with
+	// unspent outputs that all belong to the remote peer.
+	SweepStatusNotOurs = "not_ours"
+
+	// SweepStatusUnknown is the sweep status of a channel whose funding
+	// transaction could not be found on chain.
+	SweepStatusUnknown = "unknown"
+
+	// MaturityMature is the maturity of a force closed channel's to_local
+	// output that can be spent in the next block.
+	MaturityMature = "mature"
+
+	// MaturityImmature is the maturity of a force closed channel's
+	// to_local output that is still time locked.
+	MaturityImmature = "immature"
+)
+
+// ChannelStatus is the condensed on-chain status of a channel as printed by
+// the summary command with the --json flag. The JSON field names are part of
+// the output format that scripts rely on and must not be changed.
+type ChannelStatus struct {
+	ChannelPoint  string `json:"channel_point"`
+	Capacity      uint64 `json:"capacity"`
+	LocalBalance  uint64 `json:"local_balance"`
+	RemoteBalance uint64 `json:"remote_balance"`
+	CloseType     string `json:"close_type"`
+	ClosingTXID   string `json:"closing_txid,omitempty"`
+	SweepStatus   string `json:"sweep_status"`
+
+	CSVDelay          uint16 `json:"csv_delay,omitempty"`
+	ConfHeight        uint32 `json:"conf_height,omitempty"`
+	TipHeight         uint32 `json:"tip_height,omitempty"`
+	MatureHeight      uint32 `json:"mature_height,omitempty"`
+	BlocksUntilMature uint32 `json:"blocks_until_mature,omitempty"`
+	Maturity          string `json:"maturity,omitempty"`
 }
@@ -93,6 +93,61 @@ type ClosedChannel struct {
 	LocalChanConfig         ChannelConfig
 }
 
+// OpenChannelJSON is the compact information of an open channel in lnd's
+// channel DB that is dumped as JSON. See `channeldb.OpenChannel` for
+// information about the fields.
+type OpenChannelJSON struct {
+	FundingOutpoint         string `json:"funding_outpoint"`
+	ShortChannelID          string `json:"short_channel_id"`
+	ChanID                  uint64 `json:"chan_id"`
+	ChanType                uint64 `json:"chan_type"`
+	ChanStatus              string `json:"chan_status"`
+	IsPending               bool   `json:"is_pending"`
+	IsInitiator             bool   `json:"is_initiator"`
+	RemotePubkey            string `json:"remote_pubkey"`
+	Capacity                int64  `json:"capacity"`
+	LocalBalanceMSat        uint64 `json:"local_balance_msat"`
+	RemoteBalanceMSat       uint64 `json:"remote_balance_msat"`
+	LocalCommitHeight       uint64 `json:"local_commit_height"`
+	RemoteCommitHeight      uint64 `json:"remote_commit_height"`
+	LocalCommitTXID         string `json:"local_commit_txid"`
+	PerCommitPoint          string `json:"per_commit_point"`
+	RemoteCurrentRevocation string `json:"remote_current_revocation"`
+	RemoteNextRevocation    string `json:"remote_next_revocation"`
+}
+
+// ClosedChannelJSON is the compact information of a closed channel in lnd's
+// channel DB that is dumped as JSON. See `channeldb.ChannelCloseSummary` for
+// information about the fields.
+type ClosedChannelJSON struct {
+	ChanPoint         string `json:"chan_point"`
+	ShortChannelID    string `json:"short_channel_id"`
+	ChanID            uint64 `json:"chan_id"`
+	ClosingTXID       string `json:"closing_txid"`
+	RemotePubkey      string `json:"remote_pubkey"`
+	Capacity          int64  `json:"capacity"`
+	CloseHeight       uint32 `json:"close_height"`
+	SettledBalance    int64  `json:"settled_balance"`
+	TimeLockedBalance int64  `json:"time_locked_balance"`
+	CloseType         uint8  `json:"close_type"`
+	IsPending         bool   `json:"is_pending"`
+}
+
+// BackupSingleJSON is the compact information of a single channel backup that
+// is dumped as JSON. See `chanbackup.Single` for information about the fields.
+type BackupSingleJSON struct {
+	FundingOutpoint string   `json:"funding_outpoint"`
+	ShortChannelID  string   `json:"short_channel_id"`
+	ChanID          uint64   `json:"chan_id"`
+	RemotePubkey    string   `json:"remote_pubkey"`
+	Capacity        int64    `json:"capacity"`
+	Version         uint8    `json:"version"`
+	ChanType        string   `json:"chan_type"`
+	IsInitiator     bool     `json:"is_initiator"`
+	Addresses       []string `json:"addresses"`
+	ChainHash       string   `json:"chain_hash"`
+}
+
 // ChannelConfig is the information we want to dump from a channel
 // configuration. See `channeldb.ChannelConfig` for more information about the
 // fields.
@@ -209,6 +264,79 @@ func ClosedChannelDump(channels []*channeldb.ChannelCloseSummary,
 	return dumpChannels, nil
 }
 
+// OpenChannelJSONDump converts the open channels in the given channel DB into
+// a format that can be dumped as JSON.
+func OpenChannelJSONDump(
+	channels []*channeldb.OpenChannel) ([]OpenChannelJSON, error) {
+
+	dumpChannels := make([]OpenChannelJSON, len(channels))
+	for idx, channel := range channels {
+		revPreimage, err := channel.RevocationProducer.AtIndex(
+			channel.LocalCommitment.CommitHeight,
+		)
+		if err != nil {
+			return nil, err
+		}
+		perCommitPoint := input.ComputeCommitmentPoint(revPreimage[:])
+
+		localCommit := channel.LocalCommitment
+		remoteCommit := channel.RemoteCommitment
+		localCommitTXID := ""
+		if localCommit.CommitTx != nil {
+			localCommitTXID = localCommit.CommitTx.TxHash().String()
+		}
+
+		dumpChannels[idx] = OpenChannelJSON{
+			FundingOutpoint:    channel.FundingOutpoint.String(),
+			ShortChannelID:     channel.ShortChannelID.String(),
+			ChanID:             channel.ShortChannelID.ToUint64(),
+			ChanType:           uint64(channel.ChanType),
+			ChanStatus:         channel.ChanStatus().String(),
+			IsPending:          channel.IsPending,
+			IsInitiator:        channel.IsInitiator,
+			RemotePubkey:       pubKeyToHex(channel.IdentityPub),
+			Capacity:           int64(channel.Capacity),
+			LocalBalanceMSat:   uint64(localCommit.LocalBalance),
+			RemoteBalanceMSat:  uint64(localCommit.RemoteBalance),
+			LocalCommitHeight:  localCommit.CommitHeight,
+			RemoteCommitHeight: remoteCommit.CommitHeight,
+			LocalCommitTXID:    localCommitTXID,
+			PerCommitPoint:     pubKeyToHex(perCommitPoint),
+			RemoteCurrentRevocation: pubKeyToHex(
+				channel.RemoteCurrentRevocation,
+			),
+			RemoteNextRevocation: pubKeyToHex(
+				channel.RemoteNextRevocation,
+			),
+		}
+	}
+	return dumpChannels, nil
+}
+
+// ClosedChannelJSONDump converts the closed channels in the given channel DB
+// into a format that can be dumped as JSON.
+func ClosedChannelJSONDump(
+	channels []*channeldb.ChannelCloseSummary) []ClosedChannelJSON {
+
+	dumpChannels := make([]ClosedChannelJSON, len(channels))
+	for idx, channel := range channels {
+		dumpChannels[idx] = ClosedChannelJSON{
+			ChanPoint:         channel.ChanPoint.String(),
+			ShortChannelID:    channel.ShortChanID.String(),
+			ChanID:            channel.ShortChanID.ToUint64(),
+			ClosingTXID:       channel.ClosingTXID.String(),
+			RemotePubkey:      pubKeyToHex(channel.RemotePub),
+			Capacity:          int64(channel.Capacity),
+			CloseHeight:       channel.CloseHeight,
+			SettledBalance:    int64(channel.SettledBalance),
+			TimeLockedBalance: int64(channel.TimeLockedBalance),
+			CloseType:         uint8(channel.CloseType),
+			IsPending:         channel.IsPending,
+		}
+	}
+	return dumpChannels
+}
+
 // BackupDump converts the given multi backup into a dumpable format.
 func BackupDump(multi *chanbackup.Multi,
 	params *chaincfg.Params) []BackupSingle {
@@ -240,6 +368,56 @@ func BackupDump(multi *chanbackup.Multi,
 	return dumpSingles
 }
 
+// BackupJSONDump converts the given multi backup into a format that can be
+// dumped as JSON.
+func BackupJSONDump(multi *chanbackup.Multi) []BackupSingleJSON {
+	dumpSingles := make([]BackupSingleJSON, len(multi.StaticBackups))
+	for idx, single := range multi.StaticBackups {
+		addresses := make([]string, len(single.Addresses))
+		for addrIdx, addr := range single.Addresses {
+			addresses[addrIdx] = addr.String()
+		}
+
+		dumpSingles[idx] = BackupSingleJSON{
+			FundingOutpoint: single.FundingOutpoint.String(),
+			ShortChannelID:  single.ShortChannelID.String(),
+			ChanID:          single.ShortChannelID.ToUint64(),
+			RemotePubkey:    pubKeyToHex(single.RemoteNodePub),
+			Capacity:        int64(single.Capacity),
+			Version:         uint8(single.Version),
+			ChanType:        SingleVersionName(single.Version),
+			IsInitiator:     single.IsInitiator,
+			Addresses:       addresses,
+			ChainHash:       single.ChainHash.String(),
+		}
+	}
+	return dumpSingles
+}
+
+// SingleVersionName returns the channel type the given single channel backup
+// version stands for.
+func SingleVersionName(version chanbackup.SingleBackupVersion) string {
+	switch version {
+	case chanbackup.DefaultSingleVersion:
+		return "legacy"
+
+	case chanbackup.TweaklessCommitVersion:
+		return "static remote key"
+
+	case chanbackup.AnchorsCommitVersion:
+		return "anchors"
+
+	case chanbackup.AnchorsZeroFeeHtlcTxCommitVersion:
+		return "anchors with zero fee HTLCs"
+
+	case chanbackup.ScriptEnforcedLeaseVersion:
+		return "script enforced lease"
+
+	default:
+		return "unknown"
+	}
+}
+
 func ToChannelConfig(params *chaincfg.Params,
 	cfg channeldb.ChannelConfig) ChannelConfig {
 
@@ -277,3 +455,12 @@ func PubKeyToString(pubkey *btcec.PublicKey) string {
 	}
 	return hex.EncodeToString(pubkey.SerializeCompressed())
 }
+
+// pubKeyToHex returns the hex encoded compressed public key or an empty string
+// if the key is nil.
+func pubKeyToHex(pubkey *btcec.PublicKey) string {
+	if pubkey == nil {
+		return ""
+	}
+	return hex.EncodeToString(pubkey.SerializeCompressed())
+}
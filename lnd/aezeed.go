@@ -30,10 +30,41 @@ func ReadAezeed(params *chaincfg.Params) (*hdkeychain.ExtendedKey, time.Time,
 
 	// To automate things with chantools, we also offer reading the seed
 	// from environment variables.
-	mnemonicStr := strings.TrimSpace(os.Getenv(MnemonicEnvName))
+	return ReadAezeedWithSecrets(
+		params, os.Getenv(MnemonicEnvName),
+		os.Getenv(PassphraseEnvName),
+	)
+}
+
+// ReadAezeedWithSecrets decodes the aezeed with the given mnemonic and
+// passphrase. If the mnemonic or the passphrase is empty, it is read from the
+// terminal. A passphrase of a single dash (-) means no passphrase is used.
+func ReadAezeedWithSecrets(params *chaincfg.Params, mnemonicStr,
+	passphrase string) (*hdkeychain.ExtendedKey, time.Time, error) {
+
+	cipherSeed, err := ReadCipherSeedWithSecrets(mnemonicStr, passphrase)
+	if err != nil {
+		return nil, time.Unix(0, 0), err
+	}
 
-	// If nothing is set in the environment, read the seed from the
-	// terminal.
+	rootKey, err := hdkeychain.NewMaster(cipherSeed.Entropy[:], params)
+	if err != nil {
+		return nil, time.Unix(0, 0), fmt.Errorf("failed to derive " +
+			"master extended key")
+	}
+	return rootKey, cipherSeed.BirthdayTime(), nil
+}
+
+// ReadCipherSeedWithSecrets deciphers the aezeed with the given mnemonic and
+// passphrase and returns the cipher seed that contains the raw entropy. The
+// mnemonic and passphrase are read from the terminal in the same way as in
+// ReadAezeedWithSecrets.
+func ReadCipherSeedWithSecrets(mnemonicStr,
+	passphrase string) (*aezeed.CipherSeed, error) {
+
+	mnemonicStr = strings.TrimSpace(mnemonicStr)
+
+	// If no mnemonic was given, read the seed from the terminal.
 	if mnemonicStr == "" {
 		var err error
 		// We'll now prompt the user to enter in their 24-word mnemonic.
@@ -41,7 +72,7 @@ func ReadAezeed(params *chaincfg.Params) (*hdkeychain.ExtendedKey, time.Time,
 		reader := bufio.NewReader(os.Stdin)
 		mnemonicStr, err = reader.ReadString('\n')
 		if err != nil {
-			return nil, time.Unix(0, 0), err
+			return nil, err
 		}
 	}
 
@@ -62,15 +93,14 @@ func ReadAezeed(params *chaincfg.Params) (*hdkeychain.ExtendedKey, time.Time,
 	fmt.Println()
 
 	if len(cipherSeedMnemonic) != 24 {
-		return nil, time.Unix(0, 0), fmt.Errorf("wrong cipher seed "+
+		return nil, fmt.Errorf("wrong cipher seed "+
 			"mnemonic length: got %v words, expecting %v words",
 			len(cipherSeedMnemonic), 24)
 	}
 
 	// Additionally, the user may have a passphrase, that will also need to
 	// be provided so the daemon can properly decipher the cipher seed.
-	// Try the environment variable first.
-	passphrase := strings.TrimSpace(os.Getenv(PassphraseEnvName))
+	passphrase = strings.TrimSpace(passphrase)
 
 	// Because we cannot differentiate between an empty and a non-existent
 	// environment variable, we need a special character that indicates that
@@ -92,7 +122,7 @@ func ReadAezeed(params *chaincfg.Params) (*hdkeychain.ExtendedKey, time.Time,
 			int(syscall.Stdin), // nolint
 		)
 		if err != nil {
-			return nil, time.Unix(0, 0), err
+			return nil, err
 		}
 		fmt.Println()
 
@@ -108,13 +138,9 @@ func ReadAezeed(params *chaincfg.Params) (*hdkeychain.ExtendedKey, time.Time,
 	// mnemonic is wrong, or the passphrase is wrong.
 	cipherSeed, err := mnemonic.ToCipherSeed(passphraseBytes)
 	if err != nil {
-		return nil, time.Unix(0, 0), fmt.Errorf("failed to decrypt "+
+		return nil, fmt.Errorf("failed to decrypt "+
 			"seed with passphrase: %w", err)
 	}
-	rootKey, err := hdkeychain.NewMaster(cipherSeed.Entropy[:], params)
-	if err != nil {
-		return nil, time.Unix(0, 0), fmt.Errorf("failed to derive " +
-			"master extended key")
-	}
-	return rootKey, cipherSeed.BirthdayTime(), nil
+
+	return cipherSeed, nil
 }
@@ -2,13 +2,28 @@ package lnd
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 
 	"github.com/lightningnetwork/lnd/chanbackup"
 	"github.com/lightningnetwork/lnd/channeldb"
 	"github.com/lightningnetwork/lnd/keychain"
+	"golang.org/x/crypto/chacha20poly1305"
 )
 
+// SingleBackupResult is the result of parsing a single channel backup from a
+// multi backup. If the single backup could not be parsed, Err is set.
+type SingleBackupResult struct {
+	Single *chanbackup.Single
+	Err    error
+}
+
 // CreateChannelBackup creates a channel backup file from all channels found in
 // the given DB file, encrypted with the key in the key ring.
 func CreateChannelBackup(db *channeldb.DB, multiFile *chanbackup.MultiFile,
@@ -35,3 +50,190 @@ func CreateChannelBackup(db *channeldb.DB, multiFile *chanbackup.MultiFile,
 	}
 	return nil
 }
+
+// DecryptMultiBackup decrypts the encrypted content of a channel.backup file
+// with the static backup encryption key derived from the key ring. This is
+// the same as lnd does when unpacking a multi backup, but it allows the
+// individual single backups to be parsed independently.
+func DecryptMultiBackup(packedMulti []byte,
+	ring keychain.KeyRing) ([]byte, error) {
+
+	baseKey, err := ring.DeriveKey(keychain.KeyLocator{
+		Family: keychain.KeyFamilyBaseEncryption,
+		Index:  0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error deriving encryption key: %w", err)
+	}
+	encryptionKey := sha256.Sum256(baseKey.PubKey.SerializeCompressed())
+
+	if len(packedMulti) < chacha20poly1305.NonceSizeX {
+		return nil, fmt.Errorf("backup too small, must be at least "+
+			"%d bytes", chacha20poly1305.NonceSizeX)
+	}
+	nonce := packedMulti[:chacha20poly1305.NonceSizeX]
+	ciphertext := packedMulti[chacha20poly1305.NonceSizeX:]
+
+	cipher, err := chacha20poly1305.NewX(encryptionKey[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.Open(nil, nonce, ciphertext, nonce)
+}
+
+// ParseMultiBackup parses the decrypted content of a channel.backup file. In
+// contrast to lnd, which stops at the first invalid single backup, every
+// single backup is parsed independently and a result is returned for each of
+// them. An error is only returned if the multi backup itself can't be parsed.
+func ParseMultiBackup(plaintext []byte) (chanbackup.MultiBackupVersion,
+	[]*SingleBackupResult, error) {
+
+	r := bytes.NewReader(plaintext)
+
+	var header struct {
+		Version    byte
+		NumBackups uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return 0, nil, fmt.Errorf("error reading multi backup "+
+			"header: %w", err)
+	}
+
+	version := chanbackup.MultiBackupVersion(header.Version)
+	if version != chanbackup.DefaultMultiVersion {
+		return 0, nil, fmt.Errorf("unknown multi backup version %d",
+			version)
+	}
+
+	results := make([]*SingleBackupResult, 0, header.NumBackups)
+	for i := uint32(0); i < header.NumBackups; i++ {
+		// Each single backup is prefixed with its version and length,
+		// so we can extract it even if its content is invalid.
+		var singleHeader struct {
+			Version byte
+			Length  uint16
+		}
+		err := binary.Read(r, binary.BigEndian, &singleHeader)
+		if err != nil {
+			return 0, nil, fmt.Errorf("error reading header of "+
+				"single backup %d: %w", i, err)
+		}
+		body := make([]byte, singleHeader.Length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return 0, nil, fmt.Errorf("error reading single "+
+				"backup %d: %w", i, err)
+		}
+
+		var rawSingle bytes.Buffer
+		_ = binary.Write(&rawSingle, binary.BigEndian, &singleHeader)
+		rawSingle.Write(body)
+
+		single := &chanbackup.Single{}
+		err = single.Deserialize(&rawSingle)
+		results = append(results, &SingleBackupResult{
+			Single: single,
+			Err:    err,
+		})
+	}
+
+	return version, results, nil
+}
+
+// BackupDirResult is the result of reading all channel backup files of a
+// directory.
+type BackupDirResult struct {
+	// Multi contains the single backups of all files that could be
+	// decrypted. A channel that is contained in multiple files is only
+	// added once.
+	Multi *chanbackup.Multi
+
+	// NumFiles is the number of files found in the directory.
+	NumFiles int
+
+	// FailedFiles maps the name of each file that could not be decrypted
+	// to the error that occurred.
+	FailedFiles map[string]error
+}
+
+// ReadBackupDir reads all files in the given directory and merges the channel
+// backups they contain into one multi backup. Each file can either contain a
+// multi backup, like a channel.backup file, or a single channel backup as
+// exported by lncli exportchanbackup --chan_point, either in binary form or
+// hex encoded. Sub directories are ignored.
+func ReadBackupDir(dir string, ring keychain.KeyRing) (*BackupDirResult,
+	error) {
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup directory %s: %w",
+			dir, err)
+	}
+
+	result := &BackupDirResult{
+		Multi: &chanbackup.Multi{
+			Version: chanbackup.DefaultMultiVersion,
+		},
+		FailedFiles: make(map[string]error),
+	}
+	knownChannels := make(map[string]struct{})
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		result.NumFiles++
+
+		fileName := filepath.Join(dir, file.Name())
+		singles, err := readBackupFile(fileName, ring)
+		if err != nil {
+			result.FailedFiles[file.Name()] = err
+			continue
+		}
+
+		for _, single := range singles {
+			chanPoint := single.FundingOutpoint.String()
+			if _, ok := knownChannels[chanPoint]; ok {
+				continue
+			}
+
+			knownChannels[chanPoint] = struct{}{}
+			result.Multi.StaticBackups = append(
+				result.Multi.StaticBackups, single,
+			)
+		}
+	}
+
+	return result, nil
+}
+
+// readBackupFile decrypts the multi or single channel backup in the given
+// file and returns all single backups it contains.
+func readBackupFile(fileName string,
+	ring keychain.KeyRing) ([]chanbackup.Single, error) {
+
+	packed, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// A backup that was copied from the lncli output is hex encoded.
+	decoded, err := hex.DecodeString(strings.TrimSpace(string(packed)))
+	if err == nil {
+		packed = decoded
+	}
+
+	multi := &chanbackup.Multi{}
+	multiErr := multi.UnpackFromReader(bytes.NewReader(packed), ring)
+	if multiErr == nil {
+		return multi.StaticBackups, nil
+	}
+
+	single := chanbackup.Single{}
+	err = single.UnpackFromReader(bytes.NewReader(packed), ring)
+	if err != nil {
+		return nil, fmt.Errorf("not a multi backup (%v) or a single "+
+			"backup (%v)", multiErr, err)
+	}
+
+	return []chanbackup.Single{single}, nil
+}
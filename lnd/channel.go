@@ -1,6 +1,7 @@
 package lnd
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -13,6 +14,12 @@ import (
 	"github.com/lightningnetwork/lnd/input"
 )
 
+// ErrSimpleTaprootUnsupported is returned for channels that use the simple
+// taproot channel type. Their funding output is a MuSig2 key that can only be
+// signed for together with the remote peer.
+var ErrSimpleTaprootUnsupported = errors.New("the MuSig2 funding output of " +
+	"simple taproot channels can't be signed without the remote peer")
+
 type LightningChannel struct {
 	LocalChanCfg  channeldb.ChannelConfig
 	RemoteChanCfg channeldb.ChannelConfig
@@ -24,6 +31,10 @@ type LightningChannel struct {
 // CreateSignDesc derives the SignDescriptor for commitment transactions from
 // other fields on the LightningChannel.
 func (lc *LightningChannel) CreateSignDesc() error {
+	if lc.ChannelState.ChanType.IsTaproot() {
+		return ErrSimpleTaprootUnsupported
+	}
+
 	localKey := lc.LocalChanCfg.MultiSigKey.PubKey.SerializeCompressed()
 	remoteKey := lc.RemoteChanCfg.MultiSigKey.PubKey.SerializeCompressed()
 
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/chaincfg"
@@ -21,9 +22,26 @@ const (
 	HardenedKeyStart            = uint32(hdkeychain.HardenedKeyStart)
 	WalletDefaultDerivationPath = "m/84'/0'/0'"
 	WalletBIP49DerivationPath   = "m/49'/0'/0'"
+	WalletBIP86DerivationPath   = "m/86'/0'/0'"
 	LndDerivationPath           = "m/1017'/%d'/%d'"
 )
 
+var (
+	// The SLIP-0132 version bytes of extended public keys for BIP49 (ypub,
+	// upub) and BIP84 (zpub, vpub) accounts.
+	versionYPub = []byte{0x04, 0x9d, 0x7c, 0xb2}
+	versionUPub = []byte{0x04, 0x4a, 0x52, 0x62}
+	versionZPub = []byte{0x04, 0xb2, 0x47, 0x46}
+	versionVPub = []byte{0x04, 0x5f, 0x1c, 0xf6}
+
+	// The matching SLIP-0132 version bytes of extended private keys (yprv,
+	// uprv, zprv and vprv).
+	versionYPrv = []byte{0x04, 0x9d, 0x78, 0x78}
+	versionUPrv = []byte{0x04, 0x4a, 0x4e, 0x28}
+	versionZPrv = []byte{0x04, 0xb2, 0x43, 0x0c}
+	versionVPrv = []byte{0x04, 0x5f, 0x18, 0xbc}
+)
+
 func DeriveChildren(key *hdkeychain.ExtendedKey, path []uint32) (
 	*hdkeychain.ExtendedKey, error) {
 
@@ -94,6 +112,69 @@ func HardenedKey(key uint32) uint32 {
 	return key + HardenedKeyStart
 }
 
+// NeuterForPurpose returns the extended public key of the given key, encoded
+// with the SLIP-0132 version bytes that match the given BIP43 purpose: ypub
+// (upub on test networks) for BIP49, zpub (vpub) for BIP84 and the network's
+// default xpub (tpub) version for any other purpose.
+func NeuterForPurpose(key *hdkeychain.ExtendedKey, purpose uint32,
+	params *chaincfg.Params) (*hdkeychain.ExtendedKey, error) {
+
+	neutered, err := key.Neuter()
+	if err != nil {
+		return nil, fmt.Errorf("could not neuter key: %w", err)
+	}
+
+	mainNetID := chaincfg.MainNetParams.HDPublicKeyID
+	isMainNet := params.HDPublicKeyID == mainNetID
+	switch {
+	case purpose == HardenedKey(49) && isMainNet:
+		return neutered.CloneWithVersion(versionYPub)
+
+	case purpose == HardenedKey(49):
+		return neutered.CloneWithVersion(versionUPub)
+
+	case purpose == HardenedKey(84) && isMainNet:
+		return neutered.CloneWithVersion(versionZPub)
+
+	case purpose == HardenedKey(84):
+		return neutered.CloneWithVersion(versionVPub)
+
+	default:
+		return neutered, nil
+	}
+}
+
+// PrivateForPurpose returns the extended private key encoded with the
+// SLIP-0132 version bytes that match the given BIP43 purpose: yprv (uprv on
+// test networks) for BIP49, zprv (vprv) for BIP84 and the network's default
+// xprv (tprv) version for any other purpose.
+func PrivateForPurpose(key *hdkeychain.ExtendedKey, purpose uint32,
+	params *chaincfg.Params) (*hdkeychain.ExtendedKey, error) {
+
+	if !key.IsPrivate() {
+		return nil, fmt.Errorf("key is not a private key")
+	}
+
+	mainNetID := chaincfg.MainNetParams.HDPrivateKeyID
+	isMainNet := params.HDPrivateKeyID == mainNetID
+	switch {
+	case purpose == HardenedKey(49) && isMainNet:
+		return key.CloneWithVersion(versionYPrv)
+
+	case purpose == HardenedKey(49):
+		return key.CloneWithVersion(versionUPrv)
+
+	case purpose == HardenedKey(84) && isMainNet:
+		return key.CloneWithVersion(versionZPrv)
+
+	case purpose == HardenedKey(84):
+		return key.CloneWithVersion(versionVPrv)
+
+	default:
+		return key.CloneWithVersion(params.HDPrivateKeyID[:])
+	}
+}
+
 // DeriveKey derives the public key and private key in the WIF format for a
 // given key path of the extended key.
 func DeriveKey(extendedKey *hdkeychain.ExtendedKey, path string,
@@ -261,8 +342,14 @@ func GetWitnessAddrScript(addr btcutil.Address,
 			chainParams.Name)
 	}
 
+	// Taproot addresses are segwit v1, all others v0.
+	version := byte(txscript.OP_0)
+	if _, ok := addr.(*btcutil.AddressTaproot); ok {
+		version = txscript.OP_1
+	}
+
 	builder := txscript.NewScriptBuilder()
-	builder.AddOp(txscript.OP_0)
+	builder.AddOp(version)
 	builder.AddData(addr.ScriptAddress())
 
 	return builder.Script()
@@ -350,6 +437,17 @@ func NP2WKHAddr(pubKey *btcec.PublicKey,
 	return btcutil.NewAddressScriptHash(script, params)
 }
 
+// P2TRAddr returns the BIP86 taproot address of the given public key, which
+// commits to the key only, without any script path.
+func P2TRAddr(pubKey *btcec.PublicKey,
+	params *chaincfg.Params) (*btcutil.AddressTaproot, error) {
+
+	taprootKey := txscript.ComputeTaprootKeyNoScript(pubKey)
+	return btcutil.NewAddressTaproot(
+		schnorr.SerializePubKey(taprootKey), params,
+	)
+}
+
 func P2AnchorStaticRemote(pubKey *btcec.PublicKey,
 	params *chaincfg.Params) (*btcutil.AddressWitnessScriptHash, []byte,
 	error) {
@@ -363,6 +461,22 @@ func P2AnchorStaticRemote(pubKey *btcec.PublicKey,
 	return p2wsh, commitScript, err
 }
 
+// P2TaprootStaticRemote returns the address and script tree of the to_remote
+// output of a simple taproot channel that pays to the given payment base point.
+func P2TaprootStaticRemote(pubKey *btcec.PublicKey,
+	params *chaincfg.Params) (*btcutil.AddressTaproot,
+	*input.CommitScriptTree, error) {
+
+	scriptTree, err := input.NewRemoteCommitScriptTree(pubKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create script: %w", err)
+	}
+	addr, err := btcutil.NewAddressTaproot(
+		schnorr.SerializePubKey(scriptTree.TaprootKey), params,
+	)
+	return addr, scriptTree, err
+}
+
 type HDKeyRing struct {
 	ExtendedKey *hdkeychain.ExtendedKey
 	ChainParams *chaincfg.Params
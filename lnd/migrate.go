@@ -0,0 +1,149 @@
+package lnd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/lightningnetwork/lnd/kvdb"
+	"go.etcd.io/bbolt"
+)
+
+// sequencer is implemented by all buckets that have a sequence number. The
+// walletdb.ReadBucket interface doesn't expose it, even though all backends
+// know the sequence of read-only buckets too.
+type sequencer interface {
+	Sequence() uint64
+}
+
+// OpenBackend opens the bbolt database at the given path as a generic kvdb
+// backend, without applying any channel DB migrations.
+func OpenBackend(dbPath string, readonly bool) (kvdb.Backend, error) {
+	backend, err := openDB(dbPath, false, readonly, DefaultOpenTimeout)
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return nil, fmt.Errorf("error opening %s: make sure lnd is "+
+			"not running, database is locked by another process",
+			dbPath)
+	}
+
+	return backend, err
+}
+
+// CopyDB copies all top level buckets with all their nested buckets, key/value
+// pairs and sequence numbers from the source to the destination database. The
+// destination database must not yet contain any of the top level buckets of
+// the source database. Each top level bucket is copied in its own
+// transaction.
+func CopyDB(src, dst kvdb.Backend) error {
+	var topLevelKeys [][]byte
+	err := kvdb.View(src, func(tx kvdb.RTx) error {
+		return tx.ForEachBucket(func(key []byte) error {
+			topLevelKeys = append(topLevelKeys, copyBytes(key))
+			return nil
+		})
+	}, func() {
+		topLevelKeys = nil
+	})
+	if err != nil {
+		return fmt.Errorf("error listing top level buckets: %w", err)
+	}
+
+	for _, key := range topLevelKeys {
+		err := kvdb.View(src, func(srcTx kvdb.RTx) error {
+			srcBucket := srcTx.ReadBucket(key)
+			if srcBucket == nil {
+				return fmt.Errorf("bucket %x not found", key)
+			}
+
+			return kvdb.Update(dst, func(dstTx kvdb.RwTx) error {
+				bucket, err := dstTx.CreateTopLevelBucket(key)
+				if err != nil {
+					return err
+				}
+
+				return copyBucket(srcBucket, bucket)
+			}, func() {})
+		}, func() {})
+		if err != nil {
+			return fmt.Errorf("error copying top level bucket %x: "+
+				"%w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// copyBucket recursively copies all nested buckets and key/value pairs from
+// the source to the destination bucket.
+func copyBucket(src walletdb.ReadBucket, dst walletdb.ReadWriteBucket) error {
+	if seqBucket, ok := src.(sequencer); ok {
+		if err := dst.SetSequence(seqBucket.Sequence()); err != nil {
+			return err
+		}
+	}
+
+	return src.ForEach(func(k, v []byte) error {
+		// A nil value means the key is a nested bucket.
+		if v != nil {
+			return dst.Put(k, v)
+		}
+
+		srcNested := src.NestedReadBucket(k)
+		if srcNested == nil {
+			return fmt.Errorf("nested bucket %x not found", k)
+		}
+		dstNested, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+
+		return copyBucket(srcNested, dstNested)
+	})
+}
+
+// CountDB returns the total number of buckets (including the top level
+// buckets) and key/value pairs in the given database.
+func CountDB(db kvdb.Backend) (uint64, uint64, error) {
+	var numBuckets, numKeys uint64
+	err := kvdb.View(db, func(tx kvdb.RTx) error {
+		return tx.ForEachBucket(func(key []byte) error {
+			numBuckets++
+			return countBucket(
+				tx.ReadBucket(key), &numBuckets, &numKeys,
+			)
+		})
+	}, func() {
+		numBuckets, numKeys = 0, 0
+	})
+
+	return numBuckets, numKeys, err
+}
+
+// countBucket recursively counts all nested buckets and key/value pairs of the
+// given bucket.
+func countBucket(b walletdb.ReadBucket, numBuckets, numKeys *uint64) error {
+	if b == nil {
+		return nil
+	}
+
+	return b.ForEach(func(k, v []byte) error {
+		// A nil value means the key is a nested bucket. But the sqlite
+		// backend also returns nil for keys with an empty value.
+		nested := b.NestedReadBucket(k)
+		if v != nil || nested == nil {
+			*numKeys++
+			return nil
+		}
+
+		*numBuckets++
+		return countBucket(nested, numBuckets, numKeys)
+	})
+}
+
+// copyBytes returns a copy of the given byte slice. Keys returned by bbolt are
+// only valid for the lifetime of the transaction.
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
@@ -0,0 +1,233 @@
+package lnd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/lightningnetwork/lnd/brontide"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lncfg"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/tor"
+)
+
+const (
+	// DefaultSocksProxy is the SOCKS5 proxy of a local Tor daemon that is
+	// used to connect to onion addresses if no proxy is configured.
+	DefaultSocksProxy = "127.0.0.1:9050"
+
+	// socksCheckTimeout is the time we wait for the SOCKS5 proxy to accept
+	// a connection before we give up.
+	socksCheckTimeout = 5 * time.Second
+)
+
+// PeerConn is a minimal, encrypted and authenticated connection to a
+// Lightning Network peer. It only does the init handshake and then allows
+// sending and receiving raw wire messages, it doesn't know anything about
+// channels or gossip.
+type PeerConn struct {
+	conn *brontide.Conn
+
+	// RemoteInit is the init message the remote peer sent us.
+	RemoteInit *lnwire.Init
+}
+
+// defaultFeatures returns the features we advertise to the remote peer. These
+// are the features most implementations require a peer to understand before
+// they accept a connection.
+func defaultFeatures() *lnwire.RawFeatureVector {
+	return lnwire.NewRawFeatureVector(
+		lnwire.DataLossProtectRequired,
+		lnwire.GossipQueriesOptional,
+		lnwire.TLVOnionPayloadOptional,
+		lnwire.StaticRemoteKeyRequired,
+		lnwire.PaymentAddrOptional,
+		lnwire.MPPOptional,
+		lnwire.AnchorsZeroFeeHtlcTxOptional,
+		lnwire.ExplicitChannelTypeOptional,
+	)
+}
+
+// ParsePeerAddress parses a peer address in the format pubkey@host[:port]. The
+// host can be an IPv4 address, an IPv6 address (in brackets if a port is
+// given), a host name or a v3 onion address. If a SOCKS5 proxy is given, host
+// names are resolved through it, so no DNS requests are sent outside of Tor.
+func ParsePeerAddress(address, defaultPort,
+	socksAddr string) (*lnwire.NetAddress, error) {
+
+	resolver := net.ResolveTCPAddr
+	if socksAddr != "" {
+		resolver = func(_, address string) (*net.TCPAddr, error) {
+			return tor.ResolveTCPAddr(address, socksAddr)
+		}
+	}
+
+	peerAddr, err := lncfg.ParseLNAddressString(
+		address, defaultPort, resolver,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tor doesn't support v2 onion services anymore.
+	onionAddr, ok := peerAddr.Address.(*tor.OnionAddr)
+	if ok && len(onionAddr.OnionService) != tor.V3Len {
+		return nil, fmt.Errorf("onion address %s is not a v3 onion "+
+			"address", onionAddr.OnionService)
+	}
+
+	return peerAddr, nil
+}
+
+// IsOnionPeer returns true if the given peer address is an onion address that
+// can only be reached through Tor.
+func IsOnionPeer(addr *lnwire.NetAddress) bool {
+	_, ok := addr.Address.(*tor.OnionAddr)
+	return ok
+}
+
+// ConnectPeer dials the given peer using the brontide (noise) protocol with the
+// given identity key and exchanges the init messages. If a SOCKS5 proxy is
+// given, the connection is made through it. Onion addresses require a proxy.
+func ConnectPeer(idKey keychain.SingleKeyECDH, addr *lnwire.NetAddress,
+	socksAddr string, timeout time.Duration) (*PeerConn, error) {
+
+	dial := net.DialTimeout
+	switch {
+	case socksAddr != "":
+		// Make sure the proxy is running, otherwise the error of the
+		// dialer isn't very helpful.
+		proxyConn, err := net.DialTimeout(
+			"tcp", socksAddr, socksCheckTimeout,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("SOCKS5 proxy %s is not "+
+				"reachable, make sure Tor is running: %w",
+				socksAddr, err)
+		}
+		_ = proxyConn.Close()
+
+		dial = func(_, address string,
+			timeout time.Duration) (net.Conn, error) {
+
+			return tor.Dial(
+				address, socksAddr, false, false, timeout,
+			)
+		}
+
+	case IsOnionPeer(addr):
+		return nil, fmt.Errorf("a SOCKS5 proxy is required to connect "+
+			"to onion address %v", addr.Address)
+	}
+
+	conn, err := brontide.Dial(idKey, addr, timeout, dial)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing peer %v: %w", addr, err)
+	}
+
+	p := &PeerConn{
+		conn: conn,
+	}
+
+	// Both sides send their init message right after the handshake, we
+	// don't need to wait for the remote one first.
+	err = p.SendMessage(lnwire.NewInitMessage(
+		lnwire.NewRawFeatureVector(), defaultFeatures(),
+	), timeout)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("error sending init message: %w", err)
+	}
+
+	for p.RemoteInit == nil {
+		msg, err := p.ReadMessage(timeout)
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("error waiting for init "+
+				"message: %w", err)
+		}
+
+		switch msg := msg.(type) {
+		case *lnwire.Init:
+			p.RemoteInit = msg
+
+		case *lnwire.Error:
+			_ = conn.Close()
+			return nil, fmt.Errorf("peer sent error instead of "+
+				"init message: %v", msg.Error())
+		}
+	}
+
+	return p, nil
+}
+
+// SendMessage writes the given message to the peer.
+func (p *PeerConn) SendMessage(msg lnwire.Message,
+	timeout time.Duration) error {
+
+	var buf bytes.Buffer
+	if _, err := lnwire.WriteMessage(&buf, msg, 0); err != nil {
+		return fmt.Errorf("error encoding message: %w", err)
+	}
+
+	err := p.conn.SetWriteDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return err
+	}
+	if err := p.conn.WriteMessage(buf.Bytes()); err != nil {
+		return err
+	}
+	_, err = p.conn.Flush()
+	return err
+}
+
+// ReadMessage reads the next message from the peer that we know how to
+// decode. Messages of an unknown type are skipped and pings are answered
+// automatically but still returned.
+func (p *PeerConn) ReadMessage(timeout time.Duration) (lnwire.Message, error) {
+	for {
+		err := p.conn.SetReadDeadline(time.Now().Add(timeout))
+		if err != nil {
+			return nil, err
+		}
+		rawMsg, err := p.conn.ReadNextMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		msg, err := lnwire.ReadMessage(bytes.NewReader(rawMsg), 0)
+		var unknownErr *lnwire.UnknownMessage
+		switch {
+		case errors.As(err, &unknownErr):
+			continue
+
+		case err != nil:
+			return nil, fmt.Errorf("error decoding message: %w",
+				err)
+		}
+
+		if ping, ok := msg.(*lnwire.Ping); ok {
+			pong := lnwire.NewPong(make([]byte, ping.NumPongBytes))
+			if err := p.SendMessage(pong, timeout); err != nil {
+				return nil, fmt.Errorf("error sending pong: %w",
+					err)
+			}
+		}
+
+		return msg, nil
+	}
+}
+
+// RemotePub returns the identity public key of the remote peer.
+func (p *PeerConn) RemotePub() *btcec.PublicKey {
+	return p.conn.RemotePub()
+}
+
+// Close closes the connection to the peer.
+func (p *PeerConn) Close() error {
+	return p.conn.Close()
+}
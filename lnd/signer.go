@@ -6,6 +6,7 @@ import (
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/btcutil/psbt"
 	"github.com/btcsuite/btcd/chaincfg"
@@ -35,6 +36,13 @@ func (s *Signer) SignOutputRaw(tx *wire.MsgTx,
 	}
 
 	privKey = maybeTweakPrivKey(signDesc, privKey)
+
+	// Taproot outputs are signed with a Schnorr signature over the BIP341
+	// sighash, which commits to the previous outputs of all inputs.
+	if txscript.IsPayToTaproot(signDesc.Output.PkScript) {
+		return signTaproot(tx, signDesc, privKey)
+	}
+
 	amt := signDesc.Output.Value
 	sig, err := txscript.RawTxInWitnessSignature(
 		tx, signDesc.SigHashes, signDesc.InputIndex, amt,
@@ -48,6 +56,103 @@ func (s *Signer) SignOutputRaw(tx *wire.MsgTx,
 	return ecdsa.ParseDERSignature(sig[:len(sig)-1])
 }
 
+// signTaproot creates a Schnorr signature for the taproot input described by
+// the sign descriptor, either for a key spend or a script spend of the witness
+// script.
+func signTaproot(tx *wire.MsgTx, signDesc *input.SignDescriptor,
+	privKey *btcec.PrivateKey) (input.Signature, error) {
+
+	if signDesc.PrevOutputFetcher == nil {
+		return nil, fmt.Errorf("previous outputs are required to sign "+
+			"taproot input %d", signDesc.InputIndex)
+	}
+	sigHashes := txscript.NewTxSigHashes(tx, signDesc.PrevOutputFetcher)
+
+	var (
+		rawSig []byte
+		err    error
+	)
+	switch signDesc.SignMethod {
+	case input.TaprootKeySpendBIP0086SignMethod,
+		input.TaprootKeySpendSignMethod:
+
+		rawSig, err = txscript.RawTxInTaprootSignature(
+			tx, sigHashes, signDesc.InputIndex,
+			signDesc.Output.Value, signDesc.Output.PkScript,
+			signDesc.TapTweak, signDesc.HashType, privKey,
+		)
+
+	case input.TaprootScriptSpendSignMethod:
+		leaf := txscript.TapLeaf{
+			LeafVersion: txscript.BaseLeafVersion,
+			Script:      signDesc.WitnessScript,
+		}
+		rawSig, err = txscript.RawTxInTapscriptSignature(
+			tx, sigHashes, signDesc.InputIndex,
+			signDesc.Output.Value, signDesc.Output.PkScript, leaf,
+			signDesc.HashType, privKey,
+		)
+
+	default:
+		return nil, fmt.Errorf("unsupported sign method %v for "+
+			"taproot input %d", signDesc.SignMethod,
+			signDesc.InputIndex)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Chop off the sighash flag if it was appended to the signature.
+	return schnorr.ParseSignature(rawSig[:schnorr.SignatureSize])
+}
+
+// PrevOutputFetcher returns a fetcher for the outputs that are spent by the
+// inputs of the given transaction, as described by the sign descriptors with
+// the same index. Taproot signatures commit to all of them.
+func PrevOutputFetcher(tx *wire.MsgTx,
+	signDescs []*input.SignDescriptor) *txscript.MultiPrevOutFetcher {
+
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for idx, txIn := range tx.TxIn {
+		if idx < len(signDescs) && signDescs[idx].Output != nil {
+			fetcher.AddPrevOut(
+				txIn.PreviousOutPoint, signDescs[idx].Output,
+			)
+		}
+	}
+
+	return fetcher
+}
+
+// SetTaprootScriptSpend turns the sign descriptor into one for a script spend
+// of its witness script, which must be a leaf of the given script tree. The
+// control block of the leaf is added as well.
+func SetTaprootScriptSpend(signDesc *input.SignDescriptor,
+	scriptTree *input.ScriptTree) error {
+
+	leafHash := txscript.NewBaseTapLeaf(signDesc.WitnessScript).TapHash()
+	_, ok := scriptTree.TapscriptTree.LeafProofIndex[leafHash]
+	if !ok {
+		return fmt.Errorf("script %x is not part of the script tree",
+			signDesc.WitnessScript)
+	}
+
+	ctrlBlock := input.MakeTaprootCtrlBlock(
+		signDesc.WitnessScript, scriptTree.InternalKey,
+		scriptTree.TapscriptTree,
+	)
+	ctrlBlockBytes, err := ctrlBlock.ToBytes()
+	if err != nil {
+		return fmt.Errorf("error serializing control block: %w", err)
+	}
+
+	signDesc.SignMethod = input.TaprootScriptSpendSignMethod
+	signDesc.HashType = txscript.SigHashDefault
+	signDesc.ControlBlock = ctrlBlockBytes
+
+	return nil
+}
+
 func (s *Signer) ComputeInputScript(_ *wire.MsgTx, _ *input.SignDescriptor) (
 	*input.Script, error) {
 
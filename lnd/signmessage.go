@@ -0,0 +1,50 @@
+package lnd
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/tv42/zbase32"
+)
+
+// signedMsgPrefix is the prefix lnd adds to every message before signing it
+// with the node identity key.
+var signedMsgPrefix = []byte("Lightning Signed Message:")
+
+// SignMessage signs the given message with the private key in the same way
+// lnd's signmessage RPC does, so the signature can be verified with lncli
+// verifymessage. The returned signature is zbase32 encoded.
+func SignMessage(privKey *btcec.PrivateKey, msg []byte) (string, error) {
+	sig, err := ecdsa.SignCompact(privKey, messageDigest(msg), true)
+	if err != nil {
+		return "", fmt.Errorf("error signing message: %w", err)
+	}
+
+	return zbase32.EncodeToString(sig), nil
+}
+
+// VerifyMessage recovers the public key that created the given zbase32
+// encoded signature of the message, as lnd's verifymessage RPC does.
+func VerifyMessage(msg []byte, sig string) (*btcec.PublicKey, error) {
+	sigBytes, err := zbase32.DecodeString(sig)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding signature: %w", err)
+	}
+
+	pubKey, _, err := ecdsa.RecoverCompact(sigBytes, messageDigest(msg))
+	if err != nil {
+		return nil, fmt.Errorf("error recovering public key: %w", err)
+	}
+
+	return pubKey, nil
+}
+
+// messageDigest returns the double SHA256 hash of the prefixed message.
+func messageDigest(msg []byte) []byte {
+	prefixedMsg := append([]byte{}, signedMsgPrefix...)
+	prefixedMsg = append(prefixedMsg, msg...)
+
+	return chainhash.DoubleHashB(prefixedMsg)
+}
@@ -0,0 +1,141 @@
+package slip39
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+const (
+	// secretIndex is the x coordinate of the shared secret.
+	secretIndex = 255
+
+	// digestIndex is the x coordinate of the digest share that is used to
+	// verify the shared secret.
+	digestIndex = 254
+
+	// digestLength is the length of the digest of the shared secret.
+	digestLength = 4
+)
+
+var (
+	// ErrInvalidDigest is returned if the digest of a recovered secret
+	// doesn't match, which means the shares don't belong together.
+	ErrInvalidDigest = errors.New("invalid digest of the shared secret, " +
+		"the shares don't belong to the same secret")
+
+	// expTable and logTable are the exponent and logarithm tables of the
+	// field GF(256) with the Rijndael polynomial x^8 + x^4 + x^3 + x + 1,
+	// using 3 as the generator.
+	expTable, logTable = gf256Tables()
+)
+
+// point is a share of a secret, the value being the y coordinates of the
+// polynomials at the x coordinate of the share.
+type point struct {
+	x     byte
+	value []byte
+}
+
+// gf256Tables calculates the exponent and logarithm tables of GF(256).
+func gf256Tables() ([255]byte, [256]byte) {
+	var (
+		exp  [255]byte
+		log  [256]byte
+		poly uint16 = 1
+	)
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(poly)
+		log[poly] = byte(i)
+
+		// Multiply poly by the generator 3 (x + 1), reducing by the
+		// Rijndael polynomial if needed.
+		poly = (poly << 1) ^ poly
+		if poly&0x100 != 0 {
+			poly ^= 0x11b
+		}
+	}
+
+	return exp, log
+}
+
+// interpolate returns the value of the polynomials that go through the given
+// points at the x coordinate x, using Lagrange interpolation in GF(256).
+func interpolate(points []*point, x byte) ([]byte, error) {
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no points to interpolate")
+	}
+
+	valueLen := len(points[0].value)
+	seen := make(map[byte]bool, len(points))
+	for _, p := range points {
+		if len(p.value) != valueLen {
+			return nil, fmt.Errorf("all share values must have " +
+				"the same length")
+		}
+		if seen[p.x] {
+			return nil, fmt.Errorf("duplicate share index %d", p.x)
+		}
+		seen[p.x] = true
+
+		if p.x == x {
+			return p.value, nil
+		}
+	}
+
+	// The logarithm of the product of all (x_i - x), subtraction being XOR
+	// in GF(256).
+	logProd := 0
+	for _, p := range points {
+		logProd += int(logTable[p.x^x])
+	}
+
+	result := make([]byte, valueLen)
+	for _, p := range points {
+		// The logarithm of the Lagrange basis polynomial of this point,
+		// evaluated at x. The sum includes the point itself, which adds
+		// log(0) = 0 and doesn't change the result.
+		logBasis := logProd - int(logTable[p.x^x])
+		for _, other := range points {
+			logBasis -= int(logTable[p.x^other.x])
+		}
+		logBasis = ((logBasis % 255) + 255) % 255
+
+		for i, y := range p.value {
+			if y == 0 {
+				continue
+			}
+			result[i] ^= expTable[(int(logTable[y])+logBasis)%255]
+		}
+	}
+
+	return result, nil
+}
+
+// recoverSecret recovers a secret from threshold shares. The digest share is
+// used to verify that the shares belong to the same secret.
+func recoverSecret(threshold int, points []*point) ([]byte, error) {
+	if threshold == 1 {
+		return points[0].value, nil
+	}
+
+	secret, err := interpolate(points, secretIndex)
+	if err != nil {
+		return nil, err
+	}
+	digestShare, err := interpolate(points, digestIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := digestShare[:digestLength]
+	randomPart := digestShare[digestLength:]
+	mac := hmac.New(sha256.New, randomPart)
+	_, _ = mac.Write(secret)
+	if !hmac.Equal(digest, mac.Sum(nil)[:digestLength]) {
+		return nil, ErrInvalidDigest
+	}
+
+	return secret, nil
+}
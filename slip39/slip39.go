@@ -0,0 +1,439 @@
+// Package slip39 implements the recovery of a master secret from mnemonic
+// shares as defined by SLIP-0039 (Shamir's Secret-Sharing for Mnemonic Codes).
+//
+// The official SLIP-0039 spec can be found at
+// https://github.com/satoshilabs/slips/blob/master/slip-0039.md
+package slip39
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// radixBits is the number of bits encoded by a single word.
+	radixBits = 10
+
+	// headerWords is the number of words that encode the identifier,
+	// extendable flag, iteration exponent, group and member information.
+	headerWords = 4
+
+	// checksumWords is the number of words of the RS1024 checksum.
+	checksumWords = 3
+
+	// minMnemonicWords is the minimum number of words of a share, which
+	// encodes a master secret of 128 bits.
+	minMnemonicWords = 20
+
+	// minSecretBytes is the minimum length of a master secret.
+	minSecretBytes = 16
+
+	// baseIterationCount is the number of PBKDF2 iterations of all rounds
+	// of the Feistel cipher combined, for an iteration exponent of zero.
+	baseIterationCount = 10000
+
+	// roundCount is the number of rounds of the Feistel cipher.
+	roundCount = 4
+
+	// customizationString is the customization string of the checksum
+	// and the salt prefix of non-extendable shares.
+	customizationString = "shamir"
+
+	// customizationStringExtendable is the customization string of the
+	// checksum of extendable shares.
+	customizationStringExtendable = "shamir_extendable"
+)
+
+var (
+	// ErrInvalidChecksum is returned if the checksum of a share mnemonic
+	// is wrong, which usually means a word was mistyped.
+	ErrInvalidChecksum = errors.New("invalid share mnemonic checksum")
+
+	// ErrInsufficientShares is returned if not enough shares were given to
+	// recover the master secret.
+	ErrInsufficientShares = errors.New("insufficient number of shares")
+
+	// rs1024Generator are the generator coefficients of the Reed-Solomon
+	// code over GF(1024) that is used as the checksum.
+	rs1024Generator = [10]uint32{
+		0xe0e040, 0x1c1c080, 0x3838100, 0x7070200, 0xe0e0009,
+		0x1c0c2412, 0x38086c24, 0x3090fc48, 0x21b1f890, 0x3f3f120,
+	}
+
+	// wordMap maps each word of the word list to its index.
+	wordMap = newWordMap(WordList)
+)
+
+// Share is a single decoded SLIP-0039 mnemonic share.
+type Share struct {
+	// Identifier is the random identifier that all shares of the same
+	// master secret have in common.
+	Identifier uint16
+
+	// Extendable is set if the share set can be extended with more shares
+	// later. It changes the checksum and the encryption salt.
+	Extendable bool
+
+	// IterationExponent is the exponent of the PBKDF2 iteration count used
+	// by the encryption of the master secret.
+	IterationExponent uint8
+
+	// GroupIndex is the index of the group the share belongs to.
+	GroupIndex uint8
+
+	// GroupThreshold is the number of groups that are required to recover
+	// the master secret.
+	GroupThreshold uint8
+
+	// GroupCount is the total number of groups.
+	GroupCount uint8
+
+	// MemberIndex is the index of the share within its group.
+	MemberIndex uint8
+
+	// MemberThreshold is the number of shares of the group that are
+	// required to recover the group's secret.
+	MemberThreshold uint8
+
+	// Value is the share value.
+	Value []byte
+}
+
+// newWordMap returns a map from each word of the list to its index.
+func newWordMap(list []string) map[string]int {
+	words := make(map[string]int, len(list))
+	for idx, word := range list {
+		words[word] = idx
+	}
+
+	return words
+}
+
+// rs1024Polymod calculates the RS1024 checksum polynomial of the given values.
+func rs1024Polymod(values []uint32) uint32 {
+	chk := uint32(1)
+	for _, value := range values {
+		top := chk >> 20
+		chk = (chk&0xfffff)<<radixBits ^ value
+		for i := 0; i < 10; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= rs1024Generator[i]
+			}
+		}
+	}
+
+	return chk
+}
+
+// verifyChecksum returns true if the RS1024 checksum of the given word indices
+// is correct.
+func verifyChecksum(indices []uint32, extendable bool) bool {
+	customization := customizationString
+	if extendable {
+		customization = customizationStringExtendable
+	}
+
+	values := make([]uint32, 0, len(customization)+len(indices))
+	for _, char := range []byte(customization) {
+		values = append(values, uint32(char))
+	}
+	values = append(values, indices...)
+
+	return rs1024Polymod(values) == 1
+}
+
+// ParseShare decodes a single mnemonic share and verifies its checksum.
+func ParseShare(mnemonic string) (*Share, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) < minMnemonicWords {
+		return nil, fmt.Errorf("invalid share mnemonic length %d, "+
+			"must be at least %d words", len(words),
+			minMnemonicWords)
+	}
+
+	indices := make([]uint32, len(words))
+	for idx, word := range words {
+		wordIndex, ok := wordMap[word]
+		if !ok {
+			return nil, fmt.Errorf("word %d (%s) is not in the "+
+				"SLIP-0039 word list", idx+1, word)
+		}
+		indices[idx] = uint32(wordIndex)
+	}
+
+	// The value words must result in a padding of at most 8 bits, so the
+	// value is a whole number of bytes.
+	valueWords := indices[headerWords : len(indices)-checksumWords]
+	valueBits := len(valueWords) * radixBits
+	paddingBits := valueBits % 16
+	if paddingBits > 8 {
+		return nil, fmt.Errorf("invalid share mnemonic length %d",
+			len(words))
+	}
+
+	// The first 40 bits encode the identifier (15 bits), the extendable
+	// flag (1 bit), the iteration exponent, the group index, the group
+	// threshold, the group count, the member index and the member
+	// threshold (4 bits each).
+	var header uint64
+	for _, index := range indices[:headerWords] {
+		header = header<<radixBits | uint64(index)
+	}
+	share := &Share{
+		Identifier:        uint16(header >> 25),
+		Extendable:        (header>>24)&1 == 1,
+		IterationExponent: uint8((header >> 20) & 0xf),
+		GroupIndex:        uint8((header >> 16) & 0xf),
+		GroupThreshold:    uint8((header>>12)&0xf) + 1,
+		GroupCount:        uint8((header>>8)&0xf) + 1,
+		MemberIndex:       uint8((header >> 4) & 0xf),
+		MemberThreshold:   uint8(header&0xf) + 1,
+	}
+
+	if !verifyChecksum(indices, share.Extendable) {
+		return nil, ErrInvalidChecksum
+	}
+
+	if share.GroupThreshold > share.GroupCount {
+		return nil, fmt.Errorf("invalid share, group threshold %d is "+
+			"greater than the group count %d",
+			share.GroupThreshold, share.GroupCount)
+	}
+
+	value := new(big.Int)
+	for _, index := range valueWords {
+		value.Lsh(value, radixBits)
+		value.Or(value, big.NewInt(int64(index)))
+	}
+	valueLen := (valueBits - paddingBits) / 8
+	if value.BitLen() > valueLen*8 {
+		return nil, fmt.Errorf("invalid share, padding bits must be " +
+			"zero")
+	}
+	share.Value = value.FillBytes(make([]byte, valueLen))
+
+	return share, nil
+}
+
+// CombineMnemonics recovers the master secret from the given mnemonic shares
+// and decrypts it with the passphrase. Shares of groups that don't have enough
+// shares to reach their member threshold are ignored, as long as enough other
+// groups are complete. A wrong passphrase doesn't result in an error but in a
+// different master secret, as defined by the spec.
+func CombineMnemonics(mnemonics []string, passphrase string) ([]byte,
+	error) {
+
+	if len(mnemonics) == 0 {
+		return nil, fmt.Errorf("no shares given")
+	}
+	for _, char := range passphrase {
+		if char < 32 || char > 126 {
+			return nil, fmt.Errorf("passphrase must only contain " +
+				"printable ASCII characters")
+		}
+	}
+
+	shares := make([]*Share, len(mnemonics))
+	for idx, mnemonic := range mnemonics {
+		share, err := ParseShare(mnemonic)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing share %d: %w",
+				idx+1, err)
+		}
+		shares[idx] = share
+	}
+
+	encryptedSecret, err := combineShares(shares)
+	if err != nil {
+		return nil, err
+	}
+
+	first := shares[0]
+	return decrypt(
+		encryptedSecret, []byte(passphrase), first.IterationExponent,
+		first.Identifier, first.Extendable,
+	), nil
+}
+
+// MasterKeyFromShares recovers the master secret from the given mnemonic
+// shares and returns the BIP32 master key that uses it as its seed.
+func MasterKeyFromShares(mnemonics []string, passphrase string,
+	net *chaincfg.Params) (*hdkeychain.ExtendedKey, error) {
+
+	secret, err := CombineMnemonics(mnemonics, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	masterKey, err := hdkeychain.NewMaster(secret, net)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive master extended "+
+			"key: %w", err)
+	}
+
+	return masterKey, nil
+}
+
+// combineShares validates that the shares belong to the same secret and
+// recovers the encrypted master secret from them by first recovering the
+// secret of each group and then the master secret from the group secrets.
+func combineShares(shares []*Share) ([]byte, error) {
+	first := shares[0]
+	groups := make(map[uint8][]*Share)
+	for idx, share := range shares {
+		switch {
+		case share.Identifier != first.Identifier ||
+			share.Extendable != first.Extendable ||
+			share.IterationExponent != first.IterationExponent:
+
+			return nil, fmt.Errorf("share %d doesn't belong to "+
+				"the same set of shares as share 1", idx+1)
+
+		case share.GroupThreshold != first.GroupThreshold ||
+			share.GroupCount != first.GroupCount:
+
+			return nil, fmt.Errorf("share %d has a different "+
+				"group threshold or count than share 1", idx+1)
+
+		case share.GroupIndex >= share.GroupCount:
+			return nil, fmt.Errorf("share %d has group index %d "+
+				"but there are only %d groups", idx+1,
+				share.GroupIndex, share.GroupCount)
+		}
+
+		group := groups[share.GroupIndex]
+		for _, other := range group {
+			if other.MemberThreshold != share.MemberThreshold {
+				return nil, fmt.Errorf("share %d has a "+
+					"different member threshold than the "+
+					"other shares of group %d", idx+1,
+					share.GroupIndex)
+			}
+		}
+		groups[share.GroupIndex] = append(group, share)
+	}
+
+	// Sort the groups for a deterministic choice if more than the required
+	// number of groups is complete.
+	groupIndexes := make([]int, 0, len(groups))
+	for groupIndex := range groups {
+		groupIndexes = append(groupIndexes, int(groupIndex))
+	}
+	sort.Ints(groupIndexes)
+
+	var groupSecrets []*point
+	for _, groupIndex := range groupIndexes {
+		if len(groupSecrets) == int(first.GroupThreshold) {
+			break
+		}
+
+		points, err := uniqueMembers(groups[uint8(groupIndex)])
+		if err != nil {
+			return nil, err
+		}
+		threshold := int(groups[uint8(groupIndex)][0].MemberThreshold)
+		if len(points) < threshold {
+			continue
+		}
+
+		secret, err := recoverSecret(threshold, points[:threshold])
+		if err != nil {
+			return nil, fmt.Errorf("error recovering secret of "+
+				"group %d: %w", groupIndex, err)
+		}
+		groupSecrets = append(groupSecrets, &point{
+			x:     uint8(groupIndex),
+			value: secret,
+		})
+	}
+
+	if len(groupSecrets) < int(first.GroupThreshold) {
+		return nil, fmt.Errorf("%w: %d complete groups are required "+
+			"but only %d were given", ErrInsufficientShares,
+			first.GroupThreshold, len(groupSecrets))
+	}
+
+	encryptedSecret, err := recoverSecret(
+		int(first.GroupThreshold), groupSecrets,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error recovering master secret: %w",
+			err)
+	}
+
+	if len(encryptedSecret) < minSecretBytes ||
+		len(encryptedSecret)%2 != 0 {
+
+		return nil, fmt.Errorf("invalid master secret length %d",
+			len(encryptedSecret))
+	}
+
+	return encryptedSecret, nil
+}
+
+// uniqueMembers returns the points of the given shares of a group. Shares that
+// were given more than once are only used once.
+func uniqueMembers(shares []*Share) ([]*point, error) {
+	var points []*point
+	seen := make(map[uint8][]byte, len(shares))
+	for _, share := range shares {
+		value, ok := seen[share.MemberIndex]
+		switch {
+		case !ok:
+			seen[share.MemberIndex] = share.Value
+			points = append(points, &point{
+				x:     share.MemberIndex,
+				value: share.Value,
+			})
+
+		case string(value) != string(share.Value):
+			return nil, fmt.Errorf("different shares with member "+
+				"index %d in group %d", share.MemberIndex,
+				share.GroupIndex)
+		}
+	}
+
+	return points, nil
+}
+
+// decrypt decrypts the encrypted master secret with the passphrase, using the
+// four round Feistel cipher defined by the spec.
+func decrypt(encryptedSecret, passphrase []byte, iterationExponent uint8,
+	identifier uint16, extendable bool) []byte {
+
+	half := len(encryptedSecret) / 2
+	left := append([]byte{}, encryptedSecret[:half]...)
+	right := append([]byte{}, encryptedSecret[half:]...)
+
+	var salt []byte
+	if !extendable {
+		salt = append(
+			[]byte(customizationString), byte(identifier>>8),
+			byte(identifier),
+		)
+	}
+
+	iterations := (baseIterationCount << iterationExponent) / roundCount
+	for round := roundCount - 1; round >= 0; round-- {
+		password := append([]byte{byte(round)}, passphrase...)
+		roundSalt := append(append([]byte{}, salt...), right...)
+		key := pbkdf2.Key(
+			password, roundSalt, iterations, len(right), sha256.New,
+		)
+
+		for i := range left {
+			left[i] ^= key[i]
+		}
+		left, right = right, left
+	}
+
+	return append(right, left...)
+}
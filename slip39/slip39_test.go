@@ -0,0 +1,285 @@
+package slip39
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// testPassphrase is the passphrase of the official test vectors.
+	testPassphrase = "TREZOR"
+
+	// testSingleShare is the single share of test vector 1 of the official
+	// SLIP-0039 test vectors, a 1-of-1 share of a 128 bit secret.
+	testSingleShare = "duckling enlarge academic academic agency result length " +
+		"solution fridge kidney coal piece deal husband erode duke " +
+		"ajar critical decision keyboard"
+
+	// testSingleSecret is the master secret of testSingleShare.
+	testSingleSecret = "bb54aac4b89dc868ba37d9cc21b2cece"
+
+	// testBasicSecret is the master secret of testBasicShares.
+	testBasicSecret = "b43ceb7e57a0ea8766221624d01b0864"
+
+	// testGroupPassphrase is the passphrase of testGroupShares.
+	testGroupPassphrase = "chantools"
+
+	// testGroupSecret is the master secret of testGroupShares.
+	testGroupSecret = "000102030405060708090a0b0c0d0e0f101112131415161718191a" +
+		"1b1c1d1e1f"
+)
+
+var (
+	// testBasicShares are two shares of a 2-of-3 share set of test vector 4
+	// of the official SLIP-0039 test vectors.
+	testBasicShares = []string{
+		"shadow pistol academic always adequate wildlife fancy gross " +
+			"oasis cylinder mustang wrist rescue view short owner flip " +
+			"making coding armed",
+		"shadow pistol academic acid actress prayer class unknown " +
+			"daughter sweater depict flip twice unkind craft early " +
+			"superior advocate guest smoking",
+	}
+
+	// testGroupShares are the extendable shares of a 256 bit secret that is
+	// split into three groups, two of which are required. The groups
+	// require 2 of 3, 1 of 1 and 3 of 5 shares.
+	testGroupShares = []string{
+		// Group 0, member 0.
+		"chemical moment acrobat leaf alpha sugar favorite scholar " +
+			"secret crucial dryer mandate client dream ambition submit " +
+			"prepare retreat acrobat spine firm snapshot human junction " +
+			"presence spider priority timely unknown leaves pink spider " +
+			"permit",
+		// Group 0, member 1.
+		"chemical moment acrobat lily already counter admit slice " +
+			"coastal smirk tendency exchange spray fawn taught finger " +
+			"swimming exhaust declare raisin cinema slow epidemic " +
+			"sidewalk verify frequent clay spirit payment crucial photo " +
+			"punish midst",
+		// Group 0, member 2.
+		"chemical moment acrobat lungs alcohol ivory walnut reject " +
+			"already petition overall adequate inside plot satisfy " +
+			"premium withdraw carbon genre blimp result unfair civil " +
+			"budget advocate aircraft penalty trash spit extend exotic " +
+			"kernel photo",
+		// Group 1, member 0.
+		"chemical moment beard leader aviation climate silent sharp " +
+			"music crisis echo shrimp satisfy river crowd clock rich " +
+			"depart bolt prize laser software welcome sprinkle demand " +
+			"vocal airline merit drink famous yoga stay knife",
+		// Group 2, member 0.
+		"chemical moment ceramic learn average carpet marvel uncover " +
+			"lift trust dish anatomy fused source umbrella blimp priest " +
+			"repeat medal purple educate evidence parking width organize " +
+			"belong carbon wealthy fumes home justice tenant promise",
+		// Group 2, member 1.
+		"chemical moment ceramic lips afraid lunar welcome erode " +
+			"sunlight testify grill lend leaf unfair level elbow blanket " +
+			"therapy tenant step clogs spit dwarf theater silent gesture " +
+			"much employer crystal guard rocky angel ambition",
+		// Group 2, member 2.
+		"chemical moment ceramic luxury arena spelling floral devote " +
+			"wildlife syndrome patent best prevent merit legs provide " +
+			"racism hanger muscle idea enemy avoid coding volume market " +
+			"sugar preach treat flip stilt verify location deadline",
+		// Group 2, member 3.
+		"chemical moment ceramic march academic evening cylinder " +
+			"midst peasant station wits nervous imply process unhappy " +
+			"tracks apart diminish webcam bumpy chew mineral regret " +
+			"unfold style pleasure center findings credit snapshot dining " +
+			"holiday lying",
+		// Group 2, member 4.
+		"chemical moment ceramic method airport early stadium " +
+			"increase testify military memory pipeline drove diagnose " +
+			"similar aircraft uncover guitar mama screw tenant practice " +
+			"arena phantom process upstairs remove smith home fluff large " +
+			"crazy taxi",
+	}
+)
+
+func TestWordList(t *testing.T) {
+	require.Len(t, WordList, 1024)
+
+	// The words are sorted and uniquely identified by their first four
+	// letters.
+	prefixes := make(map[string]bool, len(WordList))
+	for idx, word := range WordList {
+		if idx > 0 {
+			require.Less(t, WordList[idx-1], word)
+		}
+		prefixes[word[:4]] = true
+	}
+	require.Len(t, prefixes, 1024)
+}
+
+func TestParseShare(t *testing.T) {
+	share, err := ParseShare(testBasicShares[0])
+	require.NoError(t, err)
+	require.False(t, share.Extendable)
+	require.EqualValues(t, 2, share.IterationExponent)
+	require.EqualValues(t, 1, share.GroupThreshold)
+	require.EqualValues(t, 1, share.GroupCount)
+	require.EqualValues(t, 2, share.MemberIndex)
+	require.EqualValues(t, 2, share.MemberThreshold)
+	require.Len(t, share.Value, 16)
+
+	share, err = ParseShare(testGroupShares[len(testGroupShares)-1])
+	require.NoError(t, err)
+	require.True(t, share.Extendable)
+	require.EqualValues(t, 2, share.GroupIndex)
+	require.EqualValues(t, 2, share.GroupThreshold)
+	require.EqualValues(t, 3, share.GroupCount)
+	require.EqualValues(t, 4, share.MemberIndex)
+	require.EqualValues(t, 3, share.MemberThreshold)
+	require.Len(t, share.Value, 32)
+
+	// Upper case words are accepted as well.
+	_, err = ParseShare(strings.ToUpper(testSingleShare))
+	require.NoError(t, err)
+
+	// Swapping two words breaks the checksum.
+	words := strings.Fields(testSingleShare)
+	words[5], words[6] = words[6], words[5]
+	_, err = ParseShare(strings.Join(words, " "))
+	require.ErrorIs(t, err, ErrInvalidChecksum)
+
+	_, err = ParseShare(strings.Join(words[:19], " "))
+	require.ErrorContains(t, err, "must be at least 20 words")
+
+	words[3] = "abandon"
+	_, err = ParseShare(strings.Join(words, " "))
+	require.ErrorContains(t, err, "word 4 (abandon) is not in the")
+}
+
+func TestCombineMnemonics(t *testing.T) {
+	secret, err := CombineMnemonics(
+		[]string{testSingleShare}, testPassphrase,
+	)
+	require.NoError(t, err)
+	require.Equal(t, testSingleSecret, hex.EncodeToString(secret))
+
+	// The order of the shares doesn't matter.
+	secret, err = CombineMnemonics(
+		[]string{testBasicShares[1], testBasicShares[0]},
+		testPassphrase,
+	)
+	require.NoError(t, err)
+	require.Equal(t, testBasicSecret, hex.EncodeToString(secret))
+
+	// A different passphrase results in a different secret.
+	secret, err = CombineMnemonics(testBasicShares, "")
+	require.NoError(t, err)
+	require.NotEqual(t, testBasicSecret, hex.EncodeToString(secret))
+
+	_, err = CombineMnemonics(testBasicShares, "TRÉZOR")
+	require.ErrorContains(t, err, "printable ASCII")
+
+	// One share of a 2-of-3 set is not enough, giving the same share twice
+	// doesn't change that.
+	_, err = CombineMnemonics(
+		[]string{testBasicShares[0], testBasicShares[0]},
+		testPassphrase,
+	)
+	require.ErrorIs(t, err, ErrInsufficientShares)
+
+	// Shares of different sets can't be combined.
+	_, err = CombineMnemonics(
+		[]string{testBasicShares[0], testSingleShare}, testPassphrase,
+	)
+	require.ErrorContains(t, err, "doesn't belong to the same set")
+}
+
+func TestCombineMnemonicsGroups(t *testing.T) {
+	testCases := []struct {
+		name    string
+		shares  []int
+		wantErr error
+	}{{
+		name:   "group 0 and 1",
+		shares: []int{1, 0, 3},
+	}, {
+		name:   "group 1 and 2",
+		shares: []int{3, 4, 6, 8},
+	}, {
+		// The incomplete group 2 is ignored.
+		name:   "group 0 and 1 with incomplete group 2",
+		shares: []int{5, 2, 0, 4, 3},
+	}, {
+		// All groups are complete, only two of them are used.
+		name:   "all groups",
+		shares: []int{0, 1, 3, 4, 5, 6},
+	}, {
+		name:    "only one group",
+		shares:  []int{0, 1, 2},
+		wantErr: ErrInsufficientShares,
+	}, {
+		name:    "incomplete groups",
+		shares:  []int{0, 4, 5},
+		wantErr: ErrInsufficientShares,
+	}}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			mnemonics := make([]string, len(tc.shares))
+			for idx, share := range tc.shares {
+				mnemonics[idx] = testGroupShares[share]
+			}
+
+			secret, err := CombineMnemonics(
+				mnemonics, testGroupPassphrase,
+			)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(
+				t, testGroupSecret, hex.EncodeToString(secret),
+			)
+		})
+	}
+}
+
+func TestMasterKeyFromShares(t *testing.T) {
+	masterKey, err := MasterKeyFromShares(
+		testBasicShares, testPassphrase, &chaincfg.MainNetParams,
+	)
+	require.NoError(t, err)
+
+	secret, err := hex.DecodeString(testBasicSecret)
+	require.NoError(t, err)
+	expectedKey, err := hdkeychain.NewMaster(
+		secret, &chaincfg.MainNetParams,
+	)
+	require.NoError(t, err)
+	require.Equal(t, expectedKey.String(), masterKey.String())
+}
+
+func TestInterpolate(t *testing.T) {
+	// The polynomial f(x) = 42 + 7x goes through (1, 45) and (2, 56) in
+	// GF(256), where addition is XOR and 7*1 = 7, 7*2 = 14.
+	points := []*point{
+		{x: 1, value: []byte{42 ^ 7}},
+		{x: 2, value: []byte{42 ^ 14}},
+	}
+	value, err := interpolate(points, 0)
+	require.NoError(t, err)
+	require.Equal(t, []byte{42}, value)
+
+	// The value of a known point is returned as is.
+	value, err = interpolate(points, 2)
+	require.NoError(t, err)
+	require.Equal(t, []byte{42 ^ 14}, value)
+
+	_, err = interpolate(append(points, points[0]), 0)
+	require.ErrorContains(t, err, "duplicate share index 1")
+}